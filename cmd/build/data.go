@@ -7,10 +7,14 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync/atomic"
 )
 
+// dataSubdirs 需要随 data 一起构建的子目录（其余子目录为运行时存储，不复制）
+var dataSubdirs = []string{"email-templates"}
+
 func buildBackendData() error {
 	log.Println("[BUILD] Building backend data...")
 
@@ -26,30 +30,28 @@ func buildBackendData() error {
 
 	var processedCount int
 	for _, src := range files {
-		// data 下的子目录（如运行时本地存储 data/avatars）不复制进 dist
+		// data 下的子目录（如运行时本地存储 data/avatars）不复制进 dist，dataSubdirs 除外
 		info, err := os.Stat(src)
 		if err != nil {
 			return fmt.Errorf("failed to stat %s: %w", src, err)
 		}
-		if info.IsDir() {
-			continue
-		}
-
 		filename := filepath.Base(src)
 		dst := filepath.Join(distDir, dataDir, filename)
-		ext := strings.ToLower(filepath.Ext(src))
-
-		switch ext {
-		case ".json":
-			err = minifyJSONFile(src, dst)
-		case ".html":
-			err = minifyHTMLFileTo(src, dst)
-		default:
-			err = copyFile(src, dst)
+
+		if info.IsDir() {
+			if !slices.Contains(dataSubdirs, filename) {
+				continue
+			}
+			count, err := buildDataSubdir(src, dst)
+			if err != nil {
+				return err
+			}
+			processedCount += count
+			continue
 		}
 
-		if err != nil {
-			return fmt.Errorf("failed to process %s: %w", src, err)
+		if err := processDataFile(src, dst); err != nil {
+			return err
 		}
 		processedCount++
 	}
@@ -59,6 +61,47 @@ func buildBackendData() error {
 	return nil
 }
 
+// buildDataSubdir 处理子目录下的文件（不递归）
+func buildDataSubdir(srcDir, dstDir string) (int, error) {
+	if err := os.MkdirAll(dstDir, dirPerm); err != nil {
+		return 0, fmt.Errorf("failed to create directory %s: %w", dstDir, err)
+	}
+
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read directory %s: %w", srcDir, err)
+	}
+
+	var count int
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if err := processDataFile(filepath.Join(srcDir, entry.Name()), filepath.Join(dstDir, entry.Name())); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// processDataFile 按扩展名压缩 JSON / HTML，其余文件原样复制
+func processDataFile(src, dst string) error {
+	var err error
+	switch strings.ToLower(filepath.Ext(src)) {
+	case ".json":
+		err = minifyJSONFile(src, dst)
+	case ".html":
+		err = minifyHTMLFileTo(src, dst)
+	default:
+		err = copyFile(src, dst)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to process %s: %w", src, err)
+	}
+	return nil
+}
+
 func minifyJSONFile(src, dst string) error {
 	data, err := os.ReadFile(src)
 	if err != nil {
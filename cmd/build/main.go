@@ -35,6 +35,9 @@ var (
 		"modules/account/assets/js/dashboard.ts",
 		"modules/account/assets/js/link.ts",
 		"modules/account/assets/js/oauth.ts",
+		"modules/account/assets/js/device.ts",
+		"modules/account/assets/js/secure.ts",
+		"modules/account/assets/js/unlock.ts",
 		"modules/account/assets/js/404.ts",
 	}
 
@@ -17,9 +17,11 @@ import (
 	"auth-system/internal/handlers/admin"
 	"auth-system/internal/handlers/auth"
 	"auth-system/internal/handlers/oauth"
+	oidcauth "auth-system/internal/handlers/oauth/generic"
 	googleauth "auth-system/internal/handlers/oauth/google"
 	msauth "auth-system/internal/handlers/oauth/microsoft"
 	"auth-system/internal/handlers/qrlogin"
+	"auth-system/internal/handlers/scim"
 	userhandler "auth-system/internal/handlers/user"
 	"auth-system/internal/middleware"
 	"auth-system/internal/models"
@@ -40,12 +42,22 @@ const (
 	userCacheMaxSize = 1000
 	userCacheTTL     = 15 * time.Minute
 
-	tokenCleanupInterval = 5 * time.Minute
+	tokenCleanupInterval      = 5 * time.Minute
+	oauthStateCleanupInterval = 5 * time.Minute
+	webhookDeliveryInterval   = 5 * time.Second
 
 	defaultMaxBodySize = 1 << 20
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			utils.LogError("MIGRATE", "main", err, "Migration command failed")
+			os.Exit(1)
+		}
+		return
+	}
+
 	utils.LogInfo("SERVER", "Starting authentication server...")
 
 	if err := run(); err != nil {
@@ -81,6 +93,12 @@ func run() error {
 		return fmt.Errorf("services init failed: %w", err)
 	}
 
+	// 账户生命周期事件随用户日志发布到 Webhook 并发送安全通知邮件，须在 Handler 取用 UserLogRepo 之前包装
+	repos.UserLogRepo = services.NewWebhookUserLogStore(repos.UserLogRepo, svcs.WebhookService)
+	repos.UserLogRepo = services.NewEmailNoticeUserLogStore(repos.UserLogRepo, repos.UserRepo, svcs.EmailService, cfg.BaseURL)
+	svcs.LoginAlerts = services.NewLoginAlertService(repos.KnownDeviceRepo, repos.UserRepo, svcs.EmailService, cfg.BaseURL)
+	svcs.LoginLockout = services.NewLoginLockoutService(repos.UserRepo, svcs.EmailService, cfg.BaseURL)
+
 	hdlrs, err := initHandlers(cfg, repos, svcs)
 	if err != nil {
 		return fmt.Errorf("handlers init failed: %w", err)
@@ -139,12 +157,17 @@ type Repos struct {
 	AdminLogRepo       models.AdminLogStore
 	EmailWhitelistRepo models.EmailWhitelistStore
 	DataExportRepo     models.DataExportImportStore
+	WebAuthnRepo       models.WebAuthnCredentialStore
+	UserIdentityRepo   models.UserIdentityStore
+	UserGroupRepo      models.UserGroupStore
+	KnownDeviceRepo    models.KnownDeviceStore
 }
 
 // Services 业务服务层容器
 type Services struct {
 	TokenService       services.TokenManager
 	SessionService     services.SessionManager
+	IDTokenSigner      services.IDTokenSigner
 	CaptchaService     services.CaptchaVerifier
 	WSService          services.WebSocketManager
 	EmailService       services.EmailSender
@@ -154,7 +177,16 @@ type Services struct {
 	OAuthService       services.OAuthClientManager
 	ExportService      services.ExportManager
 	ExportTokenService services.ExportTokenManager
+	TwoFactorService   services.TwoFactorManager
+	WebAuthnService    services.WebAuthnManager
 	LimiterMgr         middleware.RateLimiterManager
+	OAuthStates        oauth.StateStore
+	WebhookService     services.WebhookManager
+	EmailQueue         services.EmailQueueManager
+	EmailPreviewer     services.EmailTemplatePreviewer
+	LoginAlerts        services.LoginRiskChecker
+	LoginLockout       services.LoginLockoutManager
+	PasswordScreener   services.PasswordScreener
 }
 
 func initRepos(cfg *config.Config, pool *pgxpool.Pool) *Repos {
@@ -167,6 +199,10 @@ func initRepos(cfg *config.Config, pool *pgxpool.Pool) *Repos {
 	repos.EmailWhitelistRepo = models.NewEmailWhitelistRepository(pool)
 	repos.AdminLogRepo = models.NewAdminLogRepository(pool)
 	repos.DataExportRepo = models.NewDataExportImportRepository(pool)
+	repos.WebAuthnRepo = models.NewWebAuthnCredentialRepository(pool)
+	repos.UserIdentityRepo = models.NewUserIdentityRepository(pool)
+	repos.UserGroupRepo = models.NewUserGroupRepository(pool)
+	repos.KnownDeviceRepo = models.NewKnownDeviceRepository(pool)
 
 	utils.LogInfo("REPOS", "All repositories initialized")
 	return repos
@@ -196,42 +232,97 @@ func initServices(cfg *config.Config, pool *pgxpool.Pool) (*Services, error) {
 		return nil, utils.LogError("SERVICES", "initServices", fmt.Errorf("captcha service init failed: %w", err))
 	}
 	svcs.CaptchaService = captchaSvc
-	svcs.WSService = services.NewWebSocketService(cfg, models.NewQRLoginRepository(pool))
+	var wsBroker services.WSBroker
+	if cfg.WebSocketBackend == config.BackendPostgres {
+		wsBroker = services.NewPGNotifyBroker(pool)
+	}
+	svcs.WSService = services.NewWebSocketService(cfg, models.NewQRLoginRepository(pool), wsBroker)
 	svcs.OAuthService = services.NewOAuthService(pool)
+	svcs.WebhookService = services.NewWebhookService(models.NewWebhookRepository(pool))
 	svcs.ExportService = services.NewExportService()
-	svcs.LimiterMgr = middleware.NewRateLimiterManager()
+	if cfg.RateLimitBackend == config.BackendPostgres {
+		svcs.LimiterMgr = middleware.NewSharedRateLimiterManager(models.NewRateLimitRepository(pool))
+	} else {
+		svcs.LimiterMgr = middleware.NewRateLimiterManager()
+	}
+	// 外部登录 state 与两步验证 / Passkey 挑战共用同一后端：多实例部署时后续请求可能落到其他实例
+	var flowStates models.OAuthFlowStateStore
+	if cfg.OAuthStateBackend == config.BackendPostgres {
+		flowRepo := models.NewOAuthFlowStateRepository(pool)
+		flowStates = flowRepo
+		svcs.OAuthStates = oauth.NewDBStateStore(flowRepo)
+	} else {
+		memoryFlowStates, err := services.NewMemoryFlowStateStore()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create flow state store: %w", err)
+		}
+		flowStates = memoryFlowStates
+		svcs.OAuthStates = oauth.NewMemoryStateStore()
+	}
+	utils.LogInfo("SERVICES", "Shared state backends selected", "rate_limit", cfg.RateLimitBackend, "oauth_state", cfg.OAuthStateBackend, "websocket", cfg.WebSocketBackend)
 
-	svcs.SessionService, err = services.NewSessionService(cfg, pool)
+	sessionSvc, err := services.NewSessionService(cfg, pool)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create SessionService: %w", err)
 	}
+	svcs.SessionService = sessionSvc
+	svcs.IDTokenSigner = sessionSvc
 
 	svcs.ExportTokenService, err = services.NewExportTokenService()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ExportTokenService: %w", err)
 	}
 
+	svcs.TwoFactorService, err = services.NewTwoFactorService(cfg.TOTPEncryptionKey, cfg.TOTPIssuer, flowStates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TwoFactorService: %w", err)
+	}
+
+	svcs.WebAuthnService, err = services.NewWebAuthnService(cfg.BaseURL, cfg.WebAuthnRPID, cfg.WebAuthnRPName, flowStates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WebAuthnService: %w", err)
+	}
+
+	var breachCorpus *services.PasswordBreachCorpus
+	if cfg.PasswordBreachFile != "" {
+		breachCorpus, err = services.LoadPasswordBreachCorpus(cfg.PasswordBreachFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load password breach corpus: %w", err)
+		}
+		utils.LogInfo("SERVICES", "Password breach corpus loaded", "hashes", breachCorpus.Len())
+	} else {
+		utils.LogWarn("SERVICES", "PASSWORD_BREACH_FILE not set, breached password screening disabled")
+	}
+	svcs.PasswordScreener = services.NewPasswordScreenService(breachCorpus)
+
 	svcs.UserCache, err = cache.NewUserCache(userCacheMaxSize, userCacheTTL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create UserCache: %w", err)
 	}
 	utils.LogInfo("SERVICES", "UserCache initialized", "max_size", userCacheMaxSize, "ttl", userCacheTTL)
 
-	emailSvc, err := services.NewEmailService(cfg)
-	// 服务高度依赖邮件（注册/重置/注销验证），未配置 SMTP 直接拒绝启动
+	// 服务高度依赖邮件（注册/重置/注销验证），未配置发送后端直接拒绝启动
+	emailTransport, err := services.NewEmailTransport(cfg)
 	if err != nil {
+		return nil, utils.LogError("SERVICES", "initServices", fmt.Errorf("email transport is required: %w", err))
+	}
+	emailSvc, err := services.NewEmailService(emailTransport, models.NewEmailQueueRepository(pool), cfg.EmailEncryptionKey)
+	if err != nil {
+		emailTransport.Close()
 		return nil, utils.LogError("SERVICES", "initServices", fmt.Errorf("email service is required: %w", err))
 	}
 	if emailSvc == nil {
 		return nil, utils.LogError("SERVICES", "initServices", fmt.Errorf("email service is required"))
 	}
 	if err := emailSvc.VerifyConnection(); err != nil {
-		// 连接验证失败视为瞬时故障（发送时会自动重连），仅警告不阻断启动
-		utils.LogWarn("SERVICES", "SMTP verification failed", "error", err)
+		// 连接验证失败视为瞬时故障（邮件留在队列中重试），仅警告不阻断启动
+		utils.LogWarn("SERVICES", "Email transport verification failed", "transport", cfg.EmailTransport, "error", err)
 	} else {
-		utils.LogInfo("SERVICES", "EmailService initialized and SMTP verified")
+		utils.LogInfo("SERVICES", "EmailService initialized and transport verified", "transport", cfg.EmailTransport)
 	}
 	svcs.EmailService = emailSvc
+	svcs.EmailQueue = emailSvc
+	svcs.EmailPreviewer = emailSvc
 
 	storageSvc, err := services.NewLocalStorageService(cfg)
 	if err != nil || storageSvc == nil {
@@ -252,11 +343,13 @@ type Handlers struct {
 	userHandler          *userhandler.UserHandler
 	microsoftHandler     *msauth.MicrosoftHandler
 	googleHandler        *googleauth.GoogleHandler
+	oidcRegistry         *oidcauth.Registry
 	oauthProviderHandler *oauth.OAuthProviderHandler
 	qrLoginHandler       *qrlogin.QRLoginHandler
 	staticHandler        *handlers.StaticHandler
 	policyHandler        *handlers.PolicyHandler
 	adminHandler         *admin.AdminHandler
+	scimHandler          *scim.SCIMHandler
 }
 
 func initHandlers(cfg *config.Config, repos *Repos, svcs *Services) (*Handlers, error) {
@@ -269,6 +362,9 @@ func initHandlers(cfg *config.Config, repos *Repos, svcs *Services) (*Handlers,
 		cfg, repos.UserRepo, repos.UserLogRepo, repos.UserConsentRepo, svcs.TokenService,
 		svcs.SessionService, svcs.EmailService, svcs.CaptchaService,
 		svcs.UserCache, repos.EmailWhitelistRepo, svcs.LimiterMgr,
+		repos.UserRepo, svcs.TwoFactorService,
+		repos.WebAuthnRepo, svcs.WebAuthnService, repos.UserGroupRepo,
+		svcs.LoginAlerts, svcs.LoginLockout, svcs.PasswordScreener,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("AuthHandler: %w", err)
@@ -276,10 +372,10 @@ func initHandlers(cfg *config.Config, repos *Repos, svcs *Services) (*Handlers,
 	utils.LogInfo("HANDLERS", "AuthHandler initialized")
 
 	hdlrs.userHandler, err = userhandler.NewUserHandler(
-		repos.UserRepo, repos.UserLogRepo, svcs.TokenService,
+		repos.UserRepo, repos.UserLogRepo, svcs.TokenService, svcs.SessionService,
 		svcs.EmailService, svcs.CaptchaService, svcs.UserCache,
-		svcs.StorageService, svcs.OAuthService, svcs.LimiterMgr,
-		svcs.ExportTokenService, cfg.BaseURL, cfg.DefaultAvatarURL,
+		svcs.StorageService, svcs.OAuthService, repos.EmailWhitelistRepo,
+		svcs.LimiterMgr, svcs.ExportTokenService, cfg.BaseURL, cfg.DefaultAvatarURL,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("UserHandler: %w", err)
@@ -287,8 +383,8 @@ func initHandlers(cfg *config.Config, repos *Repos, svcs *Services) (*Handlers,
 	utils.LogInfo("HANDLERS", "UserHandler initialized")
 
 	hdlrs.microsoftHandler, err = msauth.NewMicrosoftHandler(
-		cfg, repos.UserRepo, repos.UserLogRepo, svcs.SessionService,
-		svcs.UserCache, svcs.StorageService,
+		cfg, repos.UserRepo, repos.UserLogRepo, repos.UserIdentityRepo, svcs.SessionService,
+		svcs.UserCache, svcs.StorageService, svcs.OAuthStates, svcs.LoginAlerts,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("MicrosoftHandler: %w", err)
@@ -296,23 +392,32 @@ func initHandlers(cfg *config.Config, repos *Repos, svcs *Services) (*Handlers,
 	utils.LogInfo("HANDLERS", "MicrosoftHandler initialized")
 
 	hdlrs.googleHandler, err = googleauth.NewGoogleHandler(
-		cfg, repos.UserRepo, repos.UserLogRepo, svcs.SessionService,
-		svcs.UserCache,
+		cfg, repos.UserRepo, repos.UserLogRepo, repos.UserIdentityRepo, svcs.SessionService,
+		svcs.UserCache, svcs.OAuthStates, svcs.LoginAlerts,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("GoogleHandler: %w", err)
 	}
 	utils.LogInfo("HANDLERS", "GoogleHandler initialized")
 
+	hdlrs.oidcRegistry, err = oidcauth.NewRegistry(
+		cfg, repos.UserRepo, repos.UserLogRepo, repos.UserIdentityRepo,
+		svcs.SessionService, svcs.UserCache, svcs.OAuthStates, svcs.LoginAlerts,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("OIDC providers: %w", err)
+	}
+	utils.LogInfo("HANDLERS", "OIDC provider registry initialized", "providers", len(cfg.OIDCProviders))
+
 	hdlrs.oauthProviderHandler = oauth.NewOAuthProviderHandler(
-		svcs.OAuthService, repos.UserRepo, repos.UserLogRepo,
-		svcs.UserCache, svcs.SessionService, cfg.BaseURL,
+		svcs.OAuthService, repos.UserRepo, repos.UserLogRepo, repos.UserGroupRepo,
+		svcs.UserCache, svcs.SessionService, svcs.IDTokenSigner, cfg.BaseURL,
 	)
 	utils.LogInfo("HANDLERS", "OAuthProviderHandler initialized")
 
 	hdlrs.qrLoginHandler, err = qrlogin.NewQRLoginHandler(
 		svcs.SessionService, svcs.WSService, repos.QRLoginRepo,
-		cfg.QREncryptionKey, cfg.QRKeyDerivationSalt,
+		cfg.QREncryptionKey, cfg.QRKeyDerivationSalt, svcs.LoginAlerts,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("QRLoginHandler: %w", err)
@@ -337,12 +442,23 @@ func initHandlers(cfg *config.Config, repos *Repos, svcs *Services) (*Handlers,
 		repos.UserRepo, svcs.UserCache, repos.AdminLogRepo,
 		repos.UserLogRepo, svcs.OAuthService, repos.EmailWhitelistRepo,
 		svcs.ExportService, cfg.DataExportSalt, repos.DataExportRepo,
+		svcs.LimiterMgr, repos.UserGroupRepo, svcs.SessionService,
+		svcs.WebhookService, svcs.EmailQueue, svcs.EmailPreviewer,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("AdminHandler: %w", err)
 	}
 	utils.LogInfo("HANDLERS", "AdminHandler initialized")
 
+	hdlrs.scimHandler, err = scim.NewSCIMHandler(
+		repos.UserRepo, svcs.UserCache, repos.AdminLogRepo,
+		repos.UserLogRepo, svcs.OAuthService, repos.UserGroupRepo, svcs.PasswordScreener, cfg.BaseURL,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("SCIMHandler: %w", err)
+	}
+	utils.LogInfo("HANDLERS", "SCIMHandler initialized")
+
 	utils.LogInfo("HANDLERS", "All handlers initialized successfully")
 	return hdlrs, nil
 }
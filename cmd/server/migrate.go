@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"auth-system/internal/config"
+	"auth-system/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const migrateUsage = `usage: server migrate <command>
+
+commands:
+  up          apply all pending migrations
+  down N      roll back the last N migrations
+  status      show current version and pending migrations
+  force V     set the recorded version to V and clear the dirty flag (no SQL is run)`
+
+var errMigrateUsage = errors.New("invalid migrate arguments")
+
+// runMigrate 执行 migrate 子命令：只需要 DATABASE_URL，不启动 HTTP 服务
+func runMigrate(args []string) error {
+	command, n, err := parseMigrateArgs(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return err
+	}
+
+	cfg, err := config.LoadDatabase()
+	if err != nil {
+		return fmt.Errorf("config load failed: %w", err)
+	}
+
+	pool, err := models.OpenDB(cfg)
+	if err != nil {
+		return fmt.Errorf("database connect failed: %w", err)
+	}
+	defer models.CloseDB(pool)
+
+	switch command {
+	case "up":
+		return models.MigrateUp(pool)
+	case "down":
+		return models.MigrateDown(pool, n)
+	case "force":
+		return models.MigrateForce(pool, n)
+	default:
+		return printMigrationStatus(pool)
+	}
+}
+
+// parseMigrateArgs 解析 migrate 子命令参数，返回命令与 down / force 的整数参数
+func parseMigrateArgs(args []string) (string, int, error) {
+	if len(args) == 0 {
+		return "", 0, errMigrateUsage
+	}
+
+	command := args[0]
+	switch command {
+	case "up", "status":
+		if len(args) != 1 {
+			return "", 0, fmt.Errorf("%w: %s takes no arguments", errMigrateUsage, command)
+		}
+		return command, 0, nil
+	case "down", "force":
+		if len(args) != 2 {
+			return "", 0, fmt.Errorf("%w: %s requires one argument", errMigrateUsage, command)
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return "", 0, fmt.Errorf("%w: %s %q is not an integer", errMigrateUsage, command, args[1])
+		}
+		// down 0 或负数无意义；force 允许 -1（清空版本记录）
+		if (command == "down" && n <= 0) || (command == "force" && n < -1) {
+			return "", 0, fmt.Errorf("%w: %s %d out of range", errMigrateUsage, command, n)
+		}
+		return command, n, nil
+	default:
+		return "", 0, fmt.Errorf("%w: unknown command %q", errMigrateUsage, command)
+	}
+}
+
+// printMigrationStatus 输出当前版本与待执行的迁移
+func printMigrationStatus(pool *pgxpool.Pool) error {
+	status, err := models.GetMigrationStatus(pool)
+	if err != nil {
+		return err
+	}
+
+	state := "clean"
+	if status.Dirty {
+		state = "dirty"
+	}
+	fmt.Printf("current version: %d (%s)\n", status.Version, state)
+	fmt.Printf("latest version:  %d\n", status.Latest)
+
+	if len(status.Pending) == 0 {
+		fmt.Println("pending:         none")
+		return nil
+	}
+	fmt.Printf("pending:         %d\n", len(status.Pending))
+	for _, m := range status.Pending {
+		fmt.Printf("  %06d_%s\n", m.Version, m.Name)
+	}
+	return nil
+}
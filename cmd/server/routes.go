@@ -7,6 +7,7 @@ import (
 	"auth-system/internal/handlers"
 	"auth-system/internal/middleware"
 	adminmw "auth-system/internal/middleware/admin"
+	"auth-system/internal/models"
 	"auth-system/internal/paths"
 	"auth-system/internal/utils"
 
@@ -47,6 +48,8 @@ func setupMiddleware(r *gin.Engine, cfg *config.Config) {
 
 	r.Use(gin.Recovery())
 
+	r.Use(middleware.ClientInfo())
+
 	r.Use(middleware.BodySizeLimit(defaultMaxBodySize, "/admin/api/data/import"))
 
 	r.Use(loggerMiddleware())
@@ -86,12 +89,15 @@ func setupPageRoutes(r *gin.Engine, cfg *config.Config, repos *Repos, svcs *Serv
 		accountPages.GET("/dashboard", handlers.ServeDashboardPage)
 		accountPages.GET("/link", handlers.ServeLinkConfirmPage)
 		accountPages.GET("/oauth", handlers.ServeOAuthPage)
+		accountPages.GET("/device", handlers.ServeDevicePage)
+		accountPages.GET("/secure", handlers.ServeSecurePage)
+		accountPages.GET("/unlock", handlers.ServeUnlockPage)
 	}
 
 	r.GET("/policy", handlers.ServePolicyPage)
 
 	adminPage := r.Group("/admin")
-	adminPage.Use(adminmw.AdminPageMiddleware(repos.UserRepo, svcs.SessionService, cfg.CDNURL))
+	adminPage.Use(adminmw.AdminPageMiddleware(repos.UserRepo, repos.UserGroupRepo, svcs.SessionService, cfg.CDNURL))
 	{
 		adminPage.GET("", handlers.ServeAdminPage)
 	}
@@ -145,6 +151,8 @@ func setupAPIRoutes(r *gin.Engine, hdlrs *Handlers, repos *Repos, svcs *Services
 
 	setupAdminAPI(apiGroup, r, hdlrs, repos, svcs)
 
+	setupSCIMAPI(apiGroup, hdlrs)
+
 	setupOAuthProviderAPI(r, hdlrs, repos, svcs)
 
 	utils.LogInfo("ROUTER", "API routes configured")
@@ -169,7 +177,7 @@ func setupPolicyAPI(r gin.IRouter, hdlrs *Handlers, svcs *Services) {
 		consentAPI.Use(middleware.AuthMiddleware(svcs.SessionService))
 		{
 			consentAPI.GET("/pending-consent", hdlrs.policyHandler.GetPendingConsent)
-			consentAPI.POST("/consent", hdlrs.policyHandler.RecordConsent)
+			consentAPI.POST("/consent", middleware.BlockImpersonation(), hdlrs.policyHandler.RecordConsent)
 		}
 	}
 }
@@ -185,24 +193,76 @@ func setupAuthAPI(r gin.IRouter, hdlrs *Handlers, repos *Repos, svcs *Services)
 
 		authAPI.POST("/register", svcs.LimiterMgr.RegisterRateLimit(), hdlrs.authHandler.Register)
 		authAPI.POST("/login", svcs.LimiterMgr.LoginRateLimit(), hdlrs.authHandler.Login)
+		authAPI.POST("/login/2fa", svcs.LimiterMgr.LoginRateLimit(), hdlrs.authHandler.LoginTwoFactor)
 		authAPI.POST("/logout", hdlrs.authHandler.Logout)
 		authAPI.POST("/refresh", hdlrs.authHandler.Refresh)
 		authAPI.GET("/me", middleware.AuthMiddleware(svcs.SessionService), hdlrs.authHandler.GetMe)
 
 		authAPI.POST("/send-reset-code", svcs.LimiterMgr.ResetPasswordRateLimit(), hdlrs.authHandler.SendResetCode)
 		authAPI.POST("/reset-password", hdlrs.authHandler.ResetPassword)
+		authAPI.POST("/password-strength", svcs.LimiterMgr.PasswordStrengthRateLimit(), hdlrs.authHandler.CheckPasswordStrength)
+		authAPI.POST("/secure-account", svcs.LimiterMgr.VerifyCodeRateLimit(), hdlrs.authHandler.SecureAccount)
+		authAPI.POST("/unlock", svcs.LimiterMgr.VerifyCodeRateLimit(), hdlrs.authHandler.UnlockAccount)
 		authAPI.POST("/change-password",
 			middleware.AuthMiddleware(svcs.SessionService),
 			middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+			middleware.BlockImpersonation(),
 			hdlrs.authHandler.ChangePassword)
 
+		authAPI.POST("/2fa/setup",
+			middleware.AuthMiddleware(svcs.SessionService),
+			middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+			middleware.BlockImpersonation(),
+			hdlrs.authHandler.SetupTwoFactor)
+		authAPI.POST("/2fa/enable",
+			middleware.AuthMiddleware(svcs.SessionService),
+			middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+			middleware.BlockImpersonation(),
+			hdlrs.authHandler.EnableTwoFactor)
+		authAPI.POST("/2fa/disable",
+			svcs.LimiterMgr.VerifyCodeRateLimit(),
+			middleware.AuthMiddleware(svcs.SessionService),
+			middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+			middleware.BlockImpersonation(),
+			hdlrs.authHandler.DisableTwoFactor)
+
+		authAPI.POST("/webauthn/login/begin", svcs.LimiterMgr.LoginRateLimit(), hdlrs.authHandler.BeginPasskeyLogin)
+		authAPI.POST("/webauthn/login/finish", svcs.LimiterMgr.LoginRateLimit(), hdlrs.authHandler.FinishPasskeyLogin)
+		authAPI.POST("/webauthn/register/begin",
+			svcs.LimiterMgr.VerifyCodeRateLimit(),
+			middleware.AuthMiddleware(svcs.SessionService),
+			middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+			middleware.BlockImpersonation(),
+			hdlrs.authHandler.BeginPasskeyRegistration)
+		authAPI.POST("/webauthn/register/finish",
+			middleware.AuthMiddleware(svcs.SessionService),
+			middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+			middleware.BlockImpersonation(),
+			hdlrs.authHandler.FinishPasskeyRegistration)
+		authAPI.GET("/webauthn/credentials",
+			middleware.AuthMiddleware(svcs.SessionService),
+			middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+			hdlrs.authHandler.ListPasskeys)
+		authAPI.PATCH("/webauthn/credentials/:id",
+			middleware.AuthMiddleware(svcs.SessionService),
+			middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+			middleware.BlockImpersonation(),
+			hdlrs.authHandler.RenamePasskey)
+		authAPI.DELETE("/webauthn/credentials/:id",
+			middleware.AuthMiddleware(svcs.SessionService),
+			middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+			middleware.BlockImpersonation(),
+			hdlrs.authHandler.DeletePasskey)
+
 		authAPI.POST("/send-delete-code",
 			middleware.AuthMiddleware(svcs.SessionService),
 			middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+			middleware.BlockImpersonation(),
 			hdlrs.userHandler.SendDeleteCode)
 		authAPI.POST("/delete-account",
 			middleware.AuthMiddleware(svcs.SessionService),
 			middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+			middleware.BlockImpersonation(),
 			hdlrs.userHandler.DeleteAccount)
 
 		authAPI.GET("/microsoft", hdlrs.microsoftHandler.Auth)
@@ -210,6 +270,7 @@ func setupAuthAPI(r gin.IRouter, hdlrs *Handlers, repos *Repos, svcs *Services)
 		authAPI.POST("/microsoft/unlink",
 			middleware.AuthMiddleware(svcs.SessionService),
 			middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+			middleware.BlockImpersonation(),
 			hdlrs.microsoftHandler.Unlink)
 		authAPI.GET("/microsoft/pending-link", hdlrs.microsoftHandler.GetPendingLinkInfo)
 		authAPI.POST("/microsoft/confirm-link",
@@ -220,10 +281,26 @@ func setupAuthAPI(r gin.IRouter, hdlrs *Handlers, repos *Repos, svcs *Services)
 		authAPI.POST("/google/unlink",
 			middleware.AuthMiddleware(svcs.SessionService),
 			middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+			middleware.BlockImpersonation(),
 			hdlrs.googleHandler.Unlink)
 		authAPI.GET("/google/pending-link", hdlrs.googleHandler.GetPendingLinkInfo)
 		authAPI.POST("/google/confirm-link",
 			hdlrs.googleHandler.ConfirmLink)
+
+		// 通用 OIDC Provider：每个 OIDC_PROVIDERS 条目挂载一组与 Microsoft / Google 相同的路由
+		authAPI.GET("/providers", hdlrs.oidcRegistry.ListProviders)
+		for _, h := range hdlrs.oidcRegistry.Handlers() {
+			base := "/" + h.RoutePath()
+			authAPI.GET(base, h.Auth)
+			authAPI.GET(base+"/callback", h.Callback)
+			authAPI.POST(base+"/unlink",
+				middleware.AuthMiddleware(svcs.SessionService),
+				middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+				middleware.BlockImpersonation(),
+				h.Unlink)
+			authAPI.GET(base+"/pending-link", h.GetPendingLinkInfo)
+			authAPI.POST(base+"/confirm-link", h.ConfirmLink)
+		}
 	}
 }
 
@@ -232,13 +309,22 @@ func setupUserAPI(r gin.IRouter, hdlrs *Handlers, repos *Repos, svcs *Services)
 	userAPI.Use(middleware.AuthMiddleware(svcs.SessionService))
 	userAPI.Use(middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService))
 	{
-		userAPI.PATCH("/username", hdlrs.userHandler.UpdateUsername)
-		userAPI.PATCH("/avatar", hdlrs.userHandler.UpdateAvatar)
+		userAPI.PATCH("/username", middleware.BlockImpersonation(), hdlrs.userHandler.UpdateUsername)
+		userAPI.PATCH("/avatar", middleware.BlockImpersonation(), hdlrs.userHandler.UpdateAvatar)
+		userAPI.POST("/email/send-code", middleware.BlockImpersonation(), hdlrs.userHandler.SendChangeEmailCode)
+		userAPI.POST("/email/send-new-code", middleware.BlockImpersonation(), hdlrs.userHandler.SendNewEmailCode)
+		userAPI.PATCH("/email", middleware.BlockImpersonation(), hdlrs.userHandler.ChangeEmail)
 		userAPI.GET("/logs", hdlrs.userHandler.GetLogs)
-		userAPI.POST("/export/request", hdlrs.userHandler.RequestDataExport)
+		userAPI.POST("/export/request", middleware.BlockImpersonation(), hdlrs.userHandler.RequestDataExport)
+
+		userAPI.GET("/identities", hdlrs.oidcRegistry.ListIdentities)
 
 		userAPI.GET("/oauth/grants", hdlrs.userHandler.GetOAuthGrants)
-		userAPI.DELETE("/oauth/grants/:client_id", hdlrs.userHandler.RevokeOAuthGrant)
+		userAPI.DELETE("/oauth/grants/:client_id", middleware.BlockImpersonation(), hdlrs.userHandler.RevokeOAuthGrant)
+
+		userAPI.GET("/sessions", hdlrs.userHandler.GetSessions)
+		userAPI.POST("/sessions/revoke-others", middleware.BlockImpersonation(), hdlrs.userHandler.RevokeOtherSessions)
+		userAPI.DELETE("/sessions/:family", middleware.BlockImpersonation(), hdlrs.userHandler.RevokeSession)
 	}
 
 	r.GET("/api/user/export/:token", hdlrs.userHandler.DownloadUserData)
@@ -253,6 +339,7 @@ func setupQRLoginAPI(r gin.IRouter, hdlrs *Handlers, repos *Repos, svcs *Service
 		qrAPI.POST("/confirm",
 			middleware.AuthMiddleware(svcs.SessionService),
 			middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+			middleware.BlockImpersonation(),
 			hdlrs.qrLoginHandler.MobileConfirm)
 		qrAPI.POST("/cancel", hdlrs.qrLoginHandler.MobileCancel)
 		qrAPI.PATCH("/:token/session", hdlrs.qrLoginHandler.SetSession)
@@ -264,58 +351,103 @@ func setupAdminAPI(r gin.IRouter, engine *gin.Engine, hdlrs *Handlers, repos *Re
 
 	adminAPI.Use(middleware.AuthMiddleware(svcs.SessionService))
 
-	adminAPI.Use(adminmw.AdminMiddleware(repos.UserRepo))
+	adminAPI.Use(adminmw.AdminMiddleware(repos.UserRepo, repos.UserGroupRepo))
 
 	{
-		adminAPI.GET("/stats", hdlrs.adminHandler.GetStats)
-
-		adminAPI.GET("/users", hdlrs.adminHandler.GetUsers)
-		adminAPI.GET("/users/:uid", hdlrs.adminHandler.GetUser)
-
-		adminAPI.PATCH("/users/:uid/ban", hdlrs.adminHandler.BanUser)
-		adminAPI.PATCH("/users/:uid/unban", hdlrs.adminHandler.UnbanUser)
-
-		superAdminAPI := adminAPI.Group("")
-		superAdminAPI.Use(adminmw.SuperAdminMiddleware(repos.UserRepo))
-		{
-			superAdminAPI.PUT("/users/:uid/role", hdlrs.adminHandler.SetUserRole)
-			superAdminAPI.DELETE("/users/:uid", hdlrs.adminHandler.DeleteUser)
-			superAdminAPI.GET("/logs", hdlrs.adminHandler.GetLogs)
-
-			superAdminAPI.GET("/oauth/clients", hdlrs.adminHandler.GetOAuthClients)
-			superAdminAPI.GET("/oauth/clients/:id", hdlrs.adminHandler.GetOAuthClient)
-			superAdminAPI.POST("/oauth/clients", hdlrs.adminHandler.CreateOAuthClient)
-			superAdminAPI.PUT("/oauth/clients/:id", hdlrs.adminHandler.UpdateOAuthClient)
-			superAdminAPI.DELETE("/oauth/clients/:id", hdlrs.adminHandler.DeleteOAuthClient)
-			superAdminAPI.POST("/oauth/clients/:id/secret", hdlrs.adminHandler.RegenerateOAuthClientSecret)
-			superAdminAPI.PATCH("/oauth/clients/:id", hdlrs.adminHandler.ToggleOAuthClient)
-
-			superAdminAPI.GET("/email-whitelist", hdlrs.adminHandler.GetEmailWhitelist)
-			superAdminAPI.GET("/email-whitelist/:id", hdlrs.adminHandler.GetEmailWhitelistByID)
-			superAdminAPI.POST("/email-whitelist", hdlrs.adminHandler.CreateEmailWhitelist)
-			superAdminAPI.PUT("/email-whitelist/:id", hdlrs.adminHandler.UpdateEmailWhitelist)
-			superAdminAPI.DELETE("/email-whitelist/:id", hdlrs.adminHandler.DeleteEmailWhitelist)
-
-			superAdminAPI.POST("/data/export/request", hdlrs.adminHandler.RequestExport)
-			superAdminAPI.GET("/data/export/:requestId/download", hdlrs.adminHandler.DownloadExport)
-			superAdminAPI.POST("/data/import/execute", hdlrs.adminHandler.ExecuteImport)
-			superAdminAPI.DELETE("/data/one-time-access-code", hdlrs.adminHandler.RevokeOTAC)
-		}
+		adminAPI.GET("/stats", adminmw.RequirePermission(models.PermStatsRead), hdlrs.adminHandler.GetStats)
+
+		adminAPI.GET("/users", adminmw.RequirePermission(models.PermUsersRead), hdlrs.adminHandler.GetUsers)
+		adminAPI.GET("/users/:uid", adminmw.RequirePermission(models.PermUsersRead), hdlrs.adminHandler.GetUser)
+
+		adminAPI.PATCH("/users/:uid/ban", adminmw.RequirePermission(models.PermUsersBan), hdlrs.adminHandler.BanUser)
+		adminAPI.PATCH("/users/:uid/unban", adminmw.RequirePermission(models.PermUsersBan), hdlrs.adminHandler.UnbanUser)
+		adminAPI.PUT("/users/:uid/role", adminmw.RequirePermission(models.PermUsersRole), hdlrs.adminHandler.SetUserRole)
+		adminAPI.DELETE("/users/:uid", adminmw.RequirePermission(models.PermUsersDelete), hdlrs.adminHandler.DeleteUser)
+		adminAPI.POST("/users/:uid/2fa/reset", adminmw.RequirePermission(models.PermUsers2FAReset), hdlrs.adminHandler.ResetUserTwoFactor)
+		adminAPI.POST("/users/:uid/impersonate", adminmw.SuperAdminMiddleware(repos.UserRepo), hdlrs.adminHandler.ImpersonateUser)
+
+		adminAPI.GET("/rate-limits", adminmw.RequirePermission(models.PermRateLimitsRead), hdlrs.adminHandler.GetRateLimits)
+		adminAPI.GET("/logs", adminmw.RequirePermission(models.PermLogsRead), hdlrs.adminHandler.GetLogs)
+
+		adminAPI.GET("/groups", adminmw.RequirePermission(models.PermGroupsRead), hdlrs.adminHandler.GetUserGroups)
+		adminAPI.GET("/groups/:id/members", adminmw.RequirePermission(models.PermGroupsRead), hdlrs.adminHandler.GetUserGroupMembers)
+		adminAPI.POST("/groups", adminmw.RequirePermission(models.PermGroupsWrite), hdlrs.adminHandler.CreateUserGroup)
+		adminAPI.PUT("/groups/:id", adminmw.RequirePermission(models.PermGroupsWrite), hdlrs.adminHandler.UpdateUserGroup)
+		adminAPI.DELETE("/groups/:id", adminmw.RequirePermission(models.PermGroupsWrite), hdlrs.adminHandler.DeleteUserGroup)
+		adminAPI.PUT("/groups/:id/members/:uid", adminmw.RequirePermission(models.PermGroupsWrite), hdlrs.adminHandler.AddUserGroupMember)
+		adminAPI.DELETE("/groups/:id/members/:uid", adminmw.RequirePermission(models.PermGroupsWrite), hdlrs.adminHandler.RemoveUserGroupMember)
+
+		adminAPI.GET("/webhooks", adminmw.RequirePermission(models.PermWebhooksRead), hdlrs.adminHandler.GetWebhooks)
+		adminAPI.POST("/webhooks", adminmw.RequirePermission(models.PermWebhooksWrite), hdlrs.adminHandler.CreateWebhook)
+		adminAPI.PUT("/webhooks/:id", adminmw.RequirePermission(models.PermWebhooksWrite), hdlrs.adminHandler.UpdateWebhook)
+		adminAPI.DELETE("/webhooks/:id", adminmw.RequirePermission(models.PermWebhooksWrite), hdlrs.adminHandler.DeleteWebhook)
+		adminAPI.POST("/webhooks/:id/secret", adminmw.RequirePermission(models.PermWebhooksWrite), hdlrs.adminHandler.RegenerateWebhookSecret)
+		adminAPI.GET("/webhooks/:id/deliveries", adminmw.RequirePermission(models.PermWebhooksRead), hdlrs.adminHandler.GetWebhookDeliveries)
+		adminAPI.POST("/webhooks/:id/deliveries/:deliveryId/redeliver", adminmw.RequirePermission(models.PermWebhooksWrite), hdlrs.adminHandler.RedeliverWebhook)
+
+		adminAPI.GET("/email-templates", adminmw.RequirePermission(models.PermEmailTemplatesRead), hdlrs.adminHandler.GetEmailTemplates)
+		adminAPI.GET("/email-templates/:type/preview", adminmw.RequirePermission(models.PermEmailTemplatesRead), hdlrs.adminHandler.PreviewEmailTemplate)
+
+		adminAPI.GET("/oauth/clients", adminmw.RequirePermission(models.PermOAuthClientsRead), hdlrs.adminHandler.GetOAuthClients)
+		adminAPI.GET("/oauth/clients/:id", adminmw.RequirePermission(models.PermOAuthClientsRead), hdlrs.adminHandler.GetOAuthClient)
+		adminAPI.POST("/oauth/clients", adminmw.RequirePermission(models.PermOAuthClientsWrite), hdlrs.adminHandler.CreateOAuthClient)
+		adminAPI.PUT("/oauth/clients/:id", adminmw.RequirePermission(models.PermOAuthClientsWrite), hdlrs.adminHandler.UpdateOAuthClient)
+		adminAPI.DELETE("/oauth/clients/:id", adminmw.RequirePermission(models.PermOAuthClientsWrite), hdlrs.adminHandler.DeleteOAuthClient)
+		adminAPI.POST("/oauth/clients/:id/secret", adminmw.RequirePermission(models.PermOAuthClientsWrite), hdlrs.adminHandler.RegenerateOAuthClientSecret)
+		adminAPI.PATCH("/oauth/clients/:id", adminmw.RequirePermission(models.PermOAuthClientsWrite), hdlrs.adminHandler.ToggleOAuthClient)
+		adminAPI.GET("/oauth/scopes", adminmw.RequirePermission(models.PermOAuthClientsRead), hdlrs.adminHandler.GetOAuthScopes)
+		adminAPI.POST("/oauth/scopes", adminmw.RequirePermission(models.PermOAuthScopesWrite), hdlrs.adminHandler.CreateOAuthScope)
+		adminAPI.PUT("/oauth/scopes/:id", adminmw.RequirePermission(models.PermOAuthScopesWrite), hdlrs.adminHandler.UpdateOAuthScope)
+		adminAPI.DELETE("/oauth/scopes/:id", adminmw.RequirePermission(models.PermOAuthScopesWrite), hdlrs.adminHandler.DeleteOAuthScope)
+
+		adminAPI.GET("/email-whitelist", adminmw.RequirePermission(models.PermEmailWhitelistRead), hdlrs.adminHandler.GetEmailWhitelist)
+		adminAPI.GET("/email-whitelist/:id", adminmw.RequirePermission(models.PermEmailWhitelistRead), hdlrs.adminHandler.GetEmailWhitelistByID)
+		adminAPI.POST("/email-whitelist", adminmw.RequirePermission(models.PermEmailWhitelistWrite), hdlrs.adminHandler.CreateEmailWhitelist)
+		adminAPI.PUT("/email-whitelist/:id", adminmw.RequirePermission(models.PermEmailWhitelistWrite), hdlrs.adminHandler.UpdateEmailWhitelist)
+		adminAPI.DELETE("/email-whitelist/:id", adminmw.RequirePermission(models.PermEmailWhitelistWrite), hdlrs.adminHandler.DeleteEmailWhitelist)
+
+		adminAPI.POST("/data/export/request", adminmw.RequirePermission(models.PermDataExport), hdlrs.adminHandler.RequestExport)
+		adminAPI.GET("/data/export/:requestId/download", adminmw.RequirePermission(models.PermDataExport), hdlrs.adminHandler.DownloadExport)
+		adminAPI.DELETE("/data/one-time-access-code", adminmw.RequirePermission(models.PermDataExport), hdlrs.adminHandler.RevokeOTAC)
+		adminAPI.POST("/data/import/execute", adminmw.RequirePermission(models.PermDataImport), hdlrs.adminHandler.ExecuteImport)
 	}
 
 	// 数据导入上传接口使用 5MB 限制（独立路由组，不继承 apiGroup 的 64KB 限制）
 	dataImportGroup := engine.Group("/admin/api/data/import")
 	dataImportGroup.Use(middleware.UploadBodySizeLimit())
 	dataImportGroup.Use(middleware.AuthMiddleware(svcs.SessionService))
-	dataImportGroup.Use(adminmw.AdminMiddleware(repos.UserRepo))
-	dataImportGroup.Use(adminmw.SuperAdminMiddleware(repos.UserRepo))
+	dataImportGroup.Use(adminmw.AdminMiddleware(repos.UserRepo, repos.UserGroupRepo))
+	dataImportGroup.Use(adminmw.RequirePermission(models.PermDataImport))
 	{
 		dataImportGroup.POST("/preview", hdlrs.adminHandler.PreviewImport)
 	}
 
+	// 结束模拟登录：模拟会话无法通过 AdminMiddleware，独立路由组只要求登录
+	impersonationGroup := r.Group("/admin/api/impersonation")
+	impersonationGroup.Use(middleware.AuthMiddleware(svcs.SessionService))
+	{
+		impersonationGroup.POST("/stop", hdlrs.adminHandler.StopImpersonation)
+	}
+
 	utils.LogInfo("ROUTER", "Admin API routes configured")
 }
 
+// setupSCIMAPI SCIM 2.0 用户供应接口，使用带 scim scope 的 client_credentials Token 认证
+func setupSCIMAPI(r gin.IRouter, hdlrs *Handlers) {
+	scimAPI := r.Group("/scim/v2")
+	scimAPI.Use(hdlrs.scimHandler.Authenticate)
+	{
+		scimAPI.GET("/ServiceProviderConfig", hdlrs.scimHandler.ServiceProviderConfig)
+		scimAPI.GET("/Users", hdlrs.scimHandler.ListUsers)
+		scimAPI.POST("/Users", hdlrs.scimHandler.CreateUser)
+		scimAPI.GET("/Users/:id", hdlrs.scimHandler.GetUser)
+		scimAPI.PATCH("/Users/:id", hdlrs.scimHandler.PatchUser)
+		scimAPI.DELETE("/Users/:id", hdlrs.scimHandler.DeleteUser)
+	}
+
+	utils.LogInfo("ROUTER", "SCIM API routes configured")
+}
+
 func setupOAuthProviderAPI(r *gin.Engine, hdlrs *Handlers, repos *Repos, svcs *Services) {
 	oauthGroup := r.Group("/oauth")
 	oauthGroup.Use(middleware.APIBodySizeLimit())
@@ -327,6 +459,7 @@ func setupOAuthProviderAPI(r *gin.Engine, hdlrs *Handlers, repos *Repos, svcs *S
 		oauthGroup.POST("/authorize",
 			middleware.AuthMiddleware(svcs.SessionService),
 			middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+			middleware.BlockImpersonation(),
 			middleware.CSRFTokenMiddleware(),
 			hdlrs.oauthProviderHandler.AuthorizePost)
 
@@ -339,11 +472,44 @@ func setupOAuthProviderAPI(r *gin.Engine, hdlrs *Handlers, repos *Repos, svcs *S
 			svcs.LimiterMgr.OAuthTokenRateLimit(),
 			hdlrs.oauthProviderHandler.Token)
 
+		oauthGroup.POST("/device_authorization",
+			svcs.LimiterMgr.OAuthTokenRateLimit(),
+			hdlrs.oauthProviderHandler.DeviceAuthorization)
+		oauthGroup.GET("/device/info",
+			middleware.AuthMiddleware(svcs.SessionService),
+			middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+			middleware.CSRFTokenMiddleware(),
+			svcs.LimiterMgr.VerifyCodeRateLimit(),
+			hdlrs.oauthProviderHandler.DeviceInfo)
+		oauthGroup.POST("/device",
+			middleware.AuthMiddleware(svcs.SessionService),
+			middleware.BanCheckMiddleware(svcs.UserCache, repos.UserRepo, svcs.SessionService),
+			middleware.BlockImpersonation(),
+			middleware.CSRFTokenMiddleware(),
+			svcs.LimiterMgr.VerifyCodeRateLimit(),
+			hdlrs.oauthProviderHandler.DeviceApprove)
+
 		oauthGroup.GET("/userinfo", hdlrs.oauthProviderHandler.UserInfo)
 
 		oauthGroup.POST("/revoke", hdlrs.oauthProviderHandler.Revoke)
+
+		oauthGroup.POST("/introspect",
+			svcs.LimiterMgr.OAuthTokenRateLimit(),
+			hdlrs.oauthProviderHandler.Introspect)
+
+		oauthGroup.GET("/jwks", hdlrs.oauthProviderHandler.JWKS)
+
+		// RP 发起的登出：跨站表单 POST 无 CSRF token，由 id_token_hint 证明请求来自 RP
+		oauthGroup.GET("/logout",
+			middleware.OptionalAuthMiddleware(svcs.SessionService),
+			hdlrs.oauthProviderHandler.EndSession)
+		oauthGroup.POST("/logout",
+			middleware.OptionalAuthMiddleware(svcs.SessionService),
+			hdlrs.oauthProviderHandler.EndSession)
 	}
 
+	r.GET("/.well-known/openid-configuration", hdlrs.oauthProviderHandler.OpenIDConfiguration)
+
 	utils.LogInfo("ROUTER", "OAuth Provider API routes configured")
 }
 
@@ -18,8 +18,8 @@ import (
 func startBackgroundTasks(_ *Handlers, repos *Repos, svcs *Services) {
 	utils.LogInfo("TASKS", "Starting background tasks...")
 
-	oauth.StartCleanup()
-	utils.LogInfo("TASKS", "OAuth cleanup task started")
+	go runOAuthStateCleanup(svcs.OAuthStates)
+	utils.LogInfo("TASKS", "OAuth state cleanup task started", "interval", oauthStateCleanupInterval)
 
 	go runTokenCleanup(svcs.TokenService)
 	utils.LogInfo("TASKS", "Token cleanup task started", "interval", tokenCleanupInterval)
@@ -27,6 +27,18 @@ func startBackgroundTasks(_ *Handlers, repos *Repos, svcs *Services) {
 	go runUserLogCleanup(repos.UserLogRepo)
 	utils.LogInfo("TASKS", "User log cleanup task started: interval=24h, retention=6 months")
 
+	go runWebhookDelivery(svcs.WebhookService)
+	utils.LogInfo("TASKS", "Webhook delivery task started", "interval", webhookDeliveryInterval)
+
+	go runWebhookDeliveryCleanup(svcs.WebhookService)
+	utils.LogInfo("TASKS", "Webhook delivery cleanup task started: interval=24h, retention=30 days")
+
+	go runEmailQueueCleanup(svcs.EmailQueue)
+	utils.LogInfo("TASKS", "Email queue cleanup task started: interval=24h, retention=7 days")
+
+	go runLoginAlertCleanup(svcs.LoginAlerts)
+	utils.LogInfo("TASKS", "Login alert cleanup task started: interval=24h, device retention=180 days")
+
 	utils.LogInfo("TASKS", "All background tasks started")
 }
 
@@ -55,6 +67,36 @@ func runTokenCleanup(tokenService services.TokenManager) {
 	}
 }
 
+func runOAuthStateCleanup(store oauth.StateStore) {
+	if store == nil {
+		utils.LogWarn("TASKS", "OAuth state store is nil, cleanup task disabled")
+		return
+	}
+
+	ticker := time.NewTicker(oauthStateCleanupInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					utils.LogError("TASKS", "runOAuthStateCleanup", fmt.Errorf("panic: %v", r))
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			count, err := store.CleanupExpired(ctx)
+			if err != nil {
+				utils.LogError("TASKS", "CleanupExpiredOAuthStates", err)
+			} else if count > 0 {
+				utils.LogInfo("TASKS", "OAuth state cleanup completed", "deleted", count)
+			}
+		}()
+	}
+}
+
 func runUserLogCleanup(userLogRepo models.UserLogStore) {
 	if userLogRepo == nil {
 		utils.LogWarn("TASKS", "User log repository is nil, cleanup task disabled")
@@ -103,6 +145,125 @@ func runUserLogCleanup(userLogRepo models.UserLogStore) {
 	}
 }
 
+// runWebhookDelivery 定期领取到期的 Webhook 投递并发送。多实例同时运行时由数据库行锁保证每条投递只被一个实例领取
+func runWebhookDelivery(deliverer services.WebhookDeliverer) {
+	if deliverer == nil {
+		utils.LogWarn("TASKS", "Webhook service is nil, delivery task disabled")
+		return
+	}
+
+	ticker := time.NewTicker(webhookDeliveryInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					utils.LogError("TASKS", "runWebhookDelivery", fmt.Errorf("panic: %v", r))
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+			defer cancel()
+
+			if _, err := deliverer.DeliverDue(ctx); err != nil {
+				utils.LogError("TASKS", "DeliverDueWebhooks", err)
+			}
+		}()
+	}
+}
+
+func runWebhookDeliveryCleanup(deliverer services.WebhookDeliverer) {
+	if deliverer == nil {
+		utils.LogWarn("TASKS", "Webhook service is nil, delivery cleanup task disabled")
+		return
+	}
+
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					utils.LogError("TASKS", "runWebhookDeliveryCleanup", fmt.Errorf("panic: %v", r))
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+			defer cancel()
+
+			count, err := deliverer.CleanupDeliveries(ctx)
+			if err != nil {
+				utils.LogError("TASKS", "CleanupWebhookDeliveries", err)
+			} else if count > 0 {
+				utils.LogInfo("TASKS", "Webhook delivery cleanup completed", "deleted", count)
+			}
+		}()
+	}
+}
+
+func runEmailQueueCleanup(queue services.EmailQueueManager) {
+	if queue == nil {
+		utils.LogWarn("TASKS", "Email queue is nil, cleanup task disabled")
+		return
+	}
+
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					utils.LogError("TASKS", "runEmailQueueCleanup", fmt.Errorf("panic: %v", r))
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+			defer cancel()
+
+			count, err := queue.CleanupMessages(ctx)
+			if err != nil {
+				utils.LogError("TASKS", "CleanupEmailMessages", err)
+			} else if count > 0 {
+				utils.LogInfo("TASKS", "Email queue cleanup completed", "deleted", count)
+			}
+		}()
+	}
+}
+
+// runLoginAlertCleanup 定期清理过期的新设备登录提醒与长期未登录的已知设备
+func runLoginAlertCleanup(checker services.LoginRiskChecker) {
+	if checker == nil {
+		utils.LogWarn("TASKS", "Login alert service is nil, cleanup task disabled")
+		return
+	}
+
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					utils.LogError("TASKS", "runLoginAlertCleanup", fmt.Errorf("panic: %v", r))
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+			defer cancel()
+
+			count, err := checker.CleanupExpired(ctx)
+			if err != nil {
+				utils.LogError("TASKS", "CleanupLoginAlerts", err)
+			} else if count > 0 {
+				utils.LogInfo("TASKS", "Login alert cleanup completed", "deleted", count)
+			}
+		}()
+	}
+}
+
 func loggerMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
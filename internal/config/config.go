@@ -18,6 +18,21 @@ var (
 	ErrInvalidValue    = errors.New("INVALID_CONFIG_VALUE")
 )
 
+// 跨请求状态的存储后端（RATE_LIMIT_BACKEND、OAUTH_STATE_BACKEND、WEBSOCKET_BACKEND）
+const (
+	BackendMemory   = "memory"   // 进程内存：单实例部署，重启后清空
+	BackendPostgres = "postgres" // 数据库：多实例共享，重启保留
+)
+
+// 邮件发送后端（EMAIL_TRANSPORT）
+const (
+	EmailTransportSMTP     = "smtp"
+	EmailTransportResend   = "resend"
+	EmailTransportPostmark = "postmark"
+	EmailTransportSendGrid = "sendgrid"
+	EmailTransportFile     = "file" // 写入本地 maildir，不真正发信，用于本地调试
+)
+
 // Config 应用配置，包含所有服务运行所需的配置项
 type Config struct {
 	Port             string
@@ -27,6 +42,16 @@ type Config struct {
 	DatabaseURL string
 	DBMaxConns  int
 
+	// RateLimitBackend 限流状态后端（RATE_LIMIT_BACKEND）：memory 为进程内存（默认，单实例），
+	// postgres 为数据库共享（多实例共用配额，重启不清零）
+	RateLimitBackend string
+	// OAuthStateBackend 外部登录 state / 待确认绑定、两步验证与 Passkey 挑战的存储后端（OAUTH_STATE_BACKEND），取值同上。
+	// 多实例部署时回调可能落到其他实例，需使用 postgres
+	OAuthStateBackend string
+	// WebSocketBackend 扫码登录状态推送的跨实例广播（WEBSOCKET_BACKEND）：memory 只推送本实例的连接，
+	// postgres 经 LISTEN/NOTIFY 广播到所有实例
+	WebSocketBackend string
+
 	JWTPrivateKey      string
 	JWTExpiresIn       time.Duration
 	AccessTokenExpiry  time.Duration
@@ -40,6 +65,19 @@ type Config struct {
 	SMTPPassword string
 	SMTPPort     int
 
+	// EmailTransport 邮件发送后端（EMAIL_TRANSPORT）：smtp（默认）、resend / postmark / sendgrid（HTTP API）或 file
+	EmailTransport string
+	// EmailFrom 发件人地址（EMAIL_FROM），缺省沿用 SMTP_FROM
+	EmailFrom string
+	// EmailAPIKey HTTP API 后端的密钥（EMAIL_API_KEY）
+	EmailAPIKey string
+	// EmailAPIURL 覆盖 HTTP API 后端的默认端点（EMAIL_API_URL，可选，如区域化端点）
+	EmailAPIURL string
+	// EmailFileDir file 后端写入的 maildir 目录（EMAIL_FILE_DIR）
+	EmailFileDir string
+	// EmailEncryptionKey 含一次性链接的邮件正文入队时以 AES-256-GCM 加密，密钥由此派生（EMAIL_ENCRYPTION_KEY，可选）
+	EmailEncryptionKey string
+
 	TurnstileSiteKey   string
 	TurnstileSecretKey string
 
@@ -63,9 +101,23 @@ type Config struct {
 	// Google id_token 验证：代理 Worker 签名背书的 Ed25519 验签公钥（PEM 全文）
 	WorkerSigningPublicKey string
 
+	// OIDCProviders 通过 OIDC_PROVIDERS 配置的通用上游身份提供商（Keycloak、Okta、GitHub 等）
+	OIDCProviders []OIDCProviderConfig
+
 	QREncryptionKey     string
 	QRKeyDerivationSalt string
 
+	// TOTP 两步验证：共享密钥以 AES-256-GCM 加密入库，密钥由 TOTPEncryptionKey 派生
+	TOTPEncryptionKey string
+	TOTPIssuer        string
+
+	// WebAuthn / Passkey：RP ID 为空时取 BASE_URL 的主机名，Origin 固定为 BASE_URL 的源
+	WebAuthnRPID   string
+	WebAuthnRPName string
+
+	// PasswordBreachFile 离线泄露密码库（每行 "SHA1[:次数]"），为空时新密码只做强度估算
+	PasswordBreachFile string
+
 	AvatarDir        string
 	DefaultAvatarURL string
 	DataExportSalt   string
@@ -77,11 +129,7 @@ type Config struct {
 
 // Load 从 .env 文件和系统环境变量加载配置，验证必需项后返回
 func Load() (*Config, error) {
-	if err := godotenv.Load(".env"); err != nil {
-		utils.LogWarn("CONFIG", ".env file not found (this is OK if using system env vars)")
-	} else {
-		utils.LogInfo("CONFIG", "Loaded .env")
-	}
+	loadDotEnv()
 
 	newCfg := &Config{}
 
@@ -89,12 +137,18 @@ func Load() (*Config, error) {
 	newCfg.BaseURL = getEnv("BASE_URL", "http://localhost:3000")
 	newCfg.CORSAllowOrigins = getEnv("CORS_ALLOW_ORIGINS", "")
 
-	newCfg.DatabaseURL = getEnv("DATABASE_URL", "")
-	dbMaxConns, err := getEnvInt("DB_MAX_CONNS", 10)
-	if err != nil {
-		utils.LogWarn("CONFIG", "Invalid DB_MAX_CONNS, using default", "error", err)
+	loadDatabaseConfig(newCfg)
+
+	var err error
+	if newCfg.RateLimitBackend, err = getEnvBackend("RATE_LIMIT_BACKEND"); err != nil {
+		return nil, err
+	}
+	if newCfg.OAuthStateBackend, err = getEnvBackend("OAUTH_STATE_BACKEND"); err != nil {
+		return nil, err
+	}
+	if newCfg.WebSocketBackend, err = getEnvBackend("WEBSOCKET_BACKEND"); err != nil {
+		return nil, err
 	}
-	newCfg.DBMaxConns = dbMaxConns
 
 	newCfg.JWTPrivateKey = getEnv("JWT_PRIVATE_KEY", "")
 	newCfg.JWTIssuer = getEnv("JWT_ISSUER", "")
@@ -127,6 +181,15 @@ func Load() (*Config, error) {
 	}
 	newCfg.SMTPPort = smtpPort
 
+	if newCfg.EmailTransport, err = getEnvEmailTransport("EMAIL_TRANSPORT"); err != nil {
+		return nil, err
+	}
+	newCfg.EmailFrom = getEnv("EMAIL_FROM", newCfg.SMTPFrom)
+	newCfg.EmailAPIKey = getEnv("EMAIL_API_KEY", "")
+	newCfg.EmailAPIURL = getEnv("EMAIL_API_URL", "")
+	newCfg.EmailFileDir = getEnv("EMAIL_FILE_DIR", "./data/maildir")
+	newCfg.EmailEncryptionKey = getEnv("EMAIL_ENCRYPTION_KEY", "")
+
 	newCfg.TurnstileSiteKey = getEnv("TURNSTILE_SITE_KEY", "")
 	newCfg.TurnstileSecretKey = getEnv("TURNSTILE_SECRET_KEY", "")
 
@@ -150,9 +213,23 @@ func Load() (*Config, error) {
 	newCfg.ProxyAccessClientSecret = getEnv("GOOGLE_PROXY_ACCESS_CLIENT_SECRET", "")
 	newCfg.WorkerSigningPublicKey = getEnv("WORKER_SIGNING_PUBLIC_KEY", "")
 
+	oidcProviders, err := parseOIDCProviders(getEnv("OIDC_PROVIDERS", ""))
+	if err != nil {
+		return nil, err
+	}
+	newCfg.OIDCProviders = oidcProviders
+
 	newCfg.QREncryptionKey = getEnv("QR_ENCRYPTION_KEY", "")
 	newCfg.QRKeyDerivationSalt = getEnv("QR_KEY_DERIVATION_SALT", "")
 
+	newCfg.TOTPEncryptionKey = getEnv("TOTP_ENCRYPTION_KEY", "")
+	newCfg.TOTPIssuer = getEnv("TOTP_ISSUER", "Nebula Studios")
+
+	newCfg.WebAuthnRPID = getEnv("WEBAUTHN_RP_ID", "")
+	newCfg.WebAuthnRPName = getEnv("WEBAUTHN_RP_NAME", "Nebula Studios")
+
+	newCfg.PasswordBreachFile = getEnv("PASSWORD_BREACH_FILE", "")
+
 	newCfg.AvatarDir = getEnv("AVATAR_DIR", "./data/avatars")
 	newCfg.CDNURL = getEnv("CDN_URL", "")
 
@@ -169,6 +246,38 @@ func Load() (*Config, error) {
 	return newCfg, nil
 }
 
+// LoadDatabase 只加载数据库连接配置（migrate 子命令使用），不要求其他必需项
+func LoadDatabase() (*Config, error) {
+	loadDotEnv()
+
+	newCfg := &Config{}
+	loadDatabaseConfig(newCfg)
+
+	if newCfg.DatabaseURL == "" {
+		utils.LogError("CONFIG", "Validate", ErrMissingRequired, "missing required config: DATABASE_URL")
+		return nil, fmt.Errorf("%w: DATABASE_URL", ErrMissingRequired)
+	}
+
+	return newCfg, nil
+}
+
+func loadDotEnv() {
+	if err := godotenv.Load(".env"); err != nil {
+		utils.LogWarn("CONFIG", ".env file not found (this is OK if using system env vars)")
+	} else {
+		utils.LogInfo("CONFIG", "Loaded .env")
+	}
+}
+
+func loadDatabaseConfig(c *Config) {
+	c.DatabaseURL = getEnv("DATABASE_URL", "")
+	dbMaxConns, err := getEnvInt("DB_MAX_CONNS", 10)
+	if err != nil {
+		utils.LogWarn("CONFIG", "Invalid DB_MAX_CONNS, using default", "error", err)
+	}
+	c.DBMaxConns = dbMaxConns
+}
+
 func validateConfig(c *Config) error {
 	var missingKeys []string
 	var warnings []string
@@ -195,14 +304,31 @@ func validateConfig(c *Config) error {
 		missingKeys = append(missingKeys, "TURNSTILE_SITE_KEY/TURNSTILE_SECRET_KEY (required when CAPTCHA_ENABLED=true)")
 	}
 
-	if c.SMTPUser == "" || c.SMTPPassword == "" {
-		warnings = append(warnings, "SMTP credentials incomplete (email sending will fail)")
+	switch c.EmailTransport {
+	case EmailTransportSMTP:
+		if c.SMTPUser == "" || c.SMTPPassword == "" {
+			warnings = append(warnings, "SMTP credentials incomplete (email sending will fail)")
+		}
+	case EmailTransportFile:
+		warnings = append(warnings, "EMAIL_TRANSPORT=file: emails are written to "+c.EmailFileDir+" and never delivered")
+	default:
+		if c.EmailAPIKey == "" {
+			warnings = append(warnings, "EMAIL_API_KEY is empty (email sending will fail)")
+		}
+	}
+
+	if c.EmailEncryptionKey == "" {
+		warnings = append(warnings, "EMAIL_ENCRYPTION_KEY is empty (emails containing links are sent directly without queue retries)")
 	}
 
 	if c.QREncryptionKey == "" {
 		warnings = append(warnings, "QR_ENCRYPTION_KEY is empty (QR login will fail)")
 	}
 
+	if c.TOTPEncryptionKey == "" {
+		warnings = append(warnings, "TOTP_ENCRYPTION_KEY is empty (two-factor enrolment and TOTP verification disabled)")
+	}
+
 	for _, w := range warnings {
 		utils.LogWarn("CONFIG", w)
 	}
@@ -217,7 +343,14 @@ func validateConfig(c *Config) error {
 }
 
 func (c *Config) IsEmailConfigured() bool {
-	return c.SMTPHost != "" && c.SMTPUser != "" && c.SMTPPassword != ""
+	switch c.EmailTransport {
+	case EmailTransportFile:
+		return c.EmailFileDir != ""
+	case EmailTransportResend, EmailTransportPostmark, EmailTransportSendGrid:
+		return c.EmailAPIKey != "" && c.EmailFrom != ""
+	default:
+		return c.SMTPHost != "" && c.SMTPUser != "" && c.SMTPPassword != ""
+	}
 }
 
 func (c *Config) IsCaptchaConfigured() bool {
@@ -248,6 +381,10 @@ func (c *Config) IsQRLoginConfigured() bool {
 	return c.QREncryptionKey != ""
 }
 
+func (c *Config) IsTOTPConfigured() bool {
+	return c.TOTPEncryptionKey != ""
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -273,6 +410,25 @@ func getEnvInt(key string, defaultValue int) (int, error) {
 	return intVal, nil
 }
 
+// getEnvBackend 解析存储后端环境变量，缺省为 memory
+func getEnvBackend(key string) (string, error) {
+	value := getEnv(key, BackendMemory)
+	if value != BackendMemory && value != BackendPostgres {
+		return BackendMemory, fmt.Errorf("%w: %s=%s must be %s or %s", ErrInvalidValue, key, value, BackendMemory, BackendPostgres)
+	}
+	return value, nil
+}
+
+// getEnvEmailTransport 解析邮件发送后端环境变量，缺省为 smtp
+func getEnvEmailTransport(key string) (string, error) {
+	value := getEnv(key, EmailTransportSMTP)
+	switch value {
+	case EmailTransportSMTP, EmailTransportResend, EmailTransportPostmark, EmailTransportSendGrid, EmailTransportFile:
+		return value, nil
+	}
+	return EmailTransportSMTP, fmt.Errorf("%w: %s=%s must be one of smtp, resend, postmark, sendgrid, file", ErrInvalidValue, key, value)
+}
+
 // getEnvDuration 解析时间间隔环境变量，支持 Go duration 格式（1h, 30m）和纯数字（视为小时）
 func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
 	value := os.Getenv(key)
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// oidcProviderNamePattern Provider 名称同时用作路由段（/api/auth/oidc/<name>）与身份存储标识
+var oidcProviderNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// OIDCProviderConfig 通用 OIDC 上游身份提供商配置（OIDC_PROVIDERS，JSON 数组的一项）
+// 配置 issuer 时通过 /.well-known/openid-configuration 发现端点；
+// 不支持发现的 OAuth 2.0 Provider（如 GitHub）可省略 issuer，显式配置三个端点。
+type OIDCProviderConfig struct {
+	Name                  string           `json:"name"`         // 唯一标识，小写字母、数字与连字符；绑定关系以此存储，上线后不应修改
+	DisplayName           string           `json:"display_name"` // 登录按钮与账户页展示名称
+	IconURL               string           `json:"icon_url"`     // 可选，按钮图标；需满足 CSP img-src（同源、CDN 或 data:）
+	Issuer                string           `json:"issuer"`
+	ClientID              string           `json:"client_id"`
+	ClientSecret          string           `json:"client_secret"`
+	Scopes                []string         `json:"scopes"` // 默认 openid profile email
+	AuthorizationEndpoint string           `json:"authorization_endpoint"`
+	TokenEndpoint         string           `json:"token_endpoint"`
+	UserInfoEndpoint      string           `json:"userinfo_endpoint"`
+	Claims                OIDCClaimMapping `json:"claims"`
+	// TrustEmail 为 true 时即使缺少 email_verified 声明也视邮箱为已验证（仅用于确认不会下发未验证邮箱的 Provider）
+	TrustEmail bool `json:"trust_email"`
+}
+
+// OIDCClaimMapping 用户信息字段映射，留空使用 OIDC 标准声明名
+type OIDCClaimMapping struct {
+	Subject       string `json:"subject"`        // 默认 sub（GitHub 为 id）
+	Email         string `json:"email"`          // 默认 email
+	EmailVerified string `json:"email_verified"` // 默认 email_verified
+	Name          string `json:"name"`           // 默认 name
+	Picture       string `json:"picture"`        // 默认 picture（GitHub 为 avatar_url）
+}
+
+// 未配置映射时使用的 OIDC 标准声明名与默认 scope
+var (
+	defaultOIDCClaims = OIDCClaimMapping{
+		Subject:       "sub",
+		Email:         "email",
+		EmailVerified: "email_verified",
+		Name:          "name",
+		Picture:       "picture",
+	}
+	defaultOIDCScopes = []string{"openid", "profile", "email"}
+)
+
+// reservedOIDCProviderNames 已被内置 Provider 或其他路由占用的名称
+var reservedOIDCProviderNames = map[string]bool{
+	"microsoft": true,
+	"google":    true,
+	"providers": true,
+}
+
+// parseOIDCProviders 解析并校验 OIDC_PROVIDERS，补全默认 scope 与声明映射
+// 配置错误直接返回 error，避免带着半可用的 Provider 启动
+func parseOIDCProviders(raw string) ([]OIDCProviderConfig, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	var providers []OIDCProviderConfig
+	if err := json.Unmarshal([]byte(raw), &providers); err != nil {
+		return nil, fmt.Errorf("%w: OIDC_PROVIDERS is not a valid JSON array: %v", ErrInvalidValue, err)
+	}
+
+	seen := make(map[string]bool, len(providers))
+	for i := range providers {
+		p := &providers[i]
+		p.Name = strings.TrimSpace(p.Name)
+
+		if !oidcProviderNamePattern.MatchString(p.Name) {
+			return nil, fmt.Errorf("%w: OIDC_PROVIDERS[%d].name %q must match %s", ErrInvalidValue, i, p.Name, oidcProviderNamePattern)
+		}
+		if reservedOIDCProviderNames[p.Name] {
+			return nil, fmt.Errorf("%w: OIDC_PROVIDERS[%d].name %q is reserved", ErrInvalidValue, i, p.Name)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("%w: OIDC_PROVIDERS name %q is duplicated", ErrInvalidValue, p.Name)
+		}
+		seen[p.Name] = true
+
+		if p.ClientID == "" || p.ClientSecret == "" {
+			return nil, fmt.Errorf("%w: OIDC_PROVIDERS[%s] requires client_id and client_secret", ErrInvalidValue, p.Name)
+		}
+		if p.Issuer == "" && (p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" || p.UserInfoEndpoint == "") {
+			return nil, fmt.Errorf("%w: OIDC_PROVIDERS[%s] requires issuer or authorization/token/userinfo endpoints", ErrInvalidValue, p.Name)
+		}
+		for field, value := range map[string]string{
+			"issuer":                 p.Issuer,
+			"authorization_endpoint": p.AuthorizationEndpoint,
+			"token_endpoint":         p.TokenEndpoint,
+			"userinfo_endpoint":      p.UserInfoEndpoint,
+		} {
+			if value != "" && !isSecureEndpoint(value) {
+				return nil, fmt.Errorf("%w: OIDC_PROVIDERS[%s].%s must be an https URL", ErrInvalidValue, p.Name, field)
+			}
+		}
+
+		if p.DisplayName == "" {
+			p.DisplayName = p.Name
+		}
+		if len(p.Scopes) == 0 {
+			p.Scopes = defaultOIDCScopes
+		}
+		p.Claims = p.Claims.withDefaults()
+	}
+
+	return providers, nil
+}
+
+// withDefaults 为未配置的映射项填入标准声明名
+func (m OIDCClaimMapping) withDefaults() OIDCClaimMapping {
+	if m.Subject == "" {
+		m.Subject = defaultOIDCClaims.Subject
+	}
+	if m.Email == "" {
+		m.Email = defaultOIDCClaims.Email
+	}
+	if m.EmailVerified == "" {
+		m.EmailVerified = defaultOIDCClaims.EmailVerified
+	}
+	if m.Name == "" {
+		m.Name = defaultOIDCClaims.Name
+	}
+	if m.Picture == "" {
+		m.Picture = defaultOIDCClaims.Picture
+	}
+	return m
+}
+
+// isSecureEndpoint 端点须为 https；回环地址允许 http，便于本地联调 IdP
+func isSecureEndpoint(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if u.Scheme == "https" {
+		return true
+	}
+	if u.Scheme != "http" {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseOIDCProviders(t *testing.T) {
+	providers, err := parseOIDCProviders(`[
+		{"name": "keycloak", "issuer": "https://sso.example.com/realms/main", "client_id": "a", "client_secret": "b"},
+		{"name": "github", "display_name": "GitHub", "client_id": "c", "client_secret": "d",
+		 "authorization_endpoint": "https://github.com/login/oauth/authorize",
+		 "token_endpoint": "https://github.com/login/oauth/access_token",
+		 "userinfo_endpoint": "https://api.github.com/user",
+		 "scopes": ["read:user", "user:email"],
+		 "claims": {"subject": "id", "picture": "avatar_url"}}
+	]`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(providers) != 2 {
+		t.Fatalf("providers = %d, want 2", len(providers))
+	}
+
+	kc := providers[0]
+	if kc.DisplayName != "keycloak" || len(kc.Scopes) != 3 || kc.Claims != defaultOIDCClaims {
+		t.Errorf("defaults not applied: %+v", kc)
+	}
+
+	gh := providers[1]
+	if gh.Claims.Subject != "id" || gh.Claims.Picture != "avatar_url" || gh.Claims.Email != "email" {
+		t.Errorf("claim mapping = %+v", gh.Claims)
+	}
+
+	if providers, err := parseOIDCProviders(""); err != nil || providers != nil {
+		t.Errorf("empty = %v, %v; want nil, nil", providers, err)
+	}
+}
+
+func TestParseOIDCProvidersInvalid(t *testing.T) {
+	cases := map[string]string{
+		"not json":       `{`,
+		"bad name":       `[{"name": "Key Cloak", "issuer": "https://a.test", "client_id": "a", "client_secret": "b"}]`,
+		"reserved name":  `[{"name": "google", "issuer": "https://a.test", "client_id": "a", "client_secret": "b"}]`,
+		"duplicate":      `[{"name": "a", "issuer": "https://a.test", "client_id": "a", "client_secret": "b"}, {"name": "a", "issuer": "https://b.test", "client_id": "a", "client_secret": "b"}]`,
+		"no secret":      `[{"name": "a", "issuer": "https://a.test", "client_id": "a"}]`,
+		"no endpoints":   `[{"name": "a", "client_id": "a", "client_secret": "b", "token_endpoint": "https://a.test/token"}]`,
+		"plain http":     `[{"name": "a", "issuer": "http://sso.example.com", "client_id": "a", "client_secret": "b"}]`,
+		"unknown scheme": `[{"name": "a", "issuer": "ftp://sso.example.com", "client_id": "a", "client_secret": "b"}]`,
+	}
+	for name, raw := range cases {
+		if _, err := parseOIDCProviders(raw); !errors.Is(err, ErrInvalidValue) {
+			t.Errorf("%s: err = %v, want ErrInvalidValue", name, err)
+		}
+	}
+
+	// 回环地址允许 http，便于本地联调
+	if _, err := parseOIDCProviders(`[{"name": "dev", "issuer": "http://127.0.0.1:8080/realms/dev", "client_id": "a", "client_secret": "b"}]`); err != nil {
+		t.Errorf("loopback http: %v", err)
+	}
+}
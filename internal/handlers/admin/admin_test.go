@@ -8,7 +8,9 @@ import (
 	"testing"
 
 	"auth-system/internal/middleware"
+	adminmw "auth-system/internal/middleware/admin"
 	"auth-system/internal/models"
+	"auth-system/internal/services"
 	"auth-system/internal/testutil"
 
 	"github.com/gin-gonic/gin"
@@ -16,8 +18,14 @@ import (
 
 // adminTestDeps 测试依赖集合
 type adminTestDeps struct {
-	userRepo *testutil.FakeUserRepo
-	oauth    *testutil.FakeOAuthAdmin
+	userRepo     *testutil.FakeUserRepo
+	oauth        *testutil.FakeOAuthAdmin
+	limiter      *testutil.FakeLimiter
+	groups       *testutil.FakeUserGroupRepo
+	sessions     *testutil.FakeSessionManager
+	webhooks     *testutil.FakeWebhookManager
+	emailQueue   *testutil.FakeEmailQueue
+	emailPreview *testutil.FakeEmailPreviewer
 }
 
 func newTestAdminHandler(t *testing.T) (*AdminHandler, *adminTestDeps) {
@@ -25,8 +33,14 @@ func newTestAdminHandler(t *testing.T) (*AdminHandler, *adminTestDeps) {
 	gin.SetMode(gin.TestMode)
 
 	deps := &adminTestDeps{
-		userRepo: testutil.NewFakeUserRepo(),
-		oauth:    &testutil.FakeOAuthAdmin{},
+		userRepo:     testutil.NewFakeUserRepo(),
+		oauth:        &testutil.FakeOAuthAdmin{},
+		limiter:      &testutil.FakeLimiter{},
+		groups:       testutil.NewFakeUserGroupRepo(),
+		sessions:     &testutil.FakeSessionManager{},
+		webhooks:     testutil.NewFakeWebhookManager(),
+		emailQueue:   &testutil.FakeEmailQueue{Stats: &models.EmailQueueStats{Pending: 3, Bounced24h: 1}},
+		emailPreview: &testutil.FakeEmailPreviewer{Types: []string{services.EmailTypeNewLogin}},
 	}
 
 	h, err := NewAdminHandler(
@@ -39,6 +53,12 @@ func newTestAdminHandler(t *testing.T) (*AdminHandler, *adminTestDeps) {
 		&testutil.FakeExportManager{},
 		"test-salt",
 		&testutil.FakeDataExportRepo{},
+		deps.limiter,
+		deps.groups,
+		deps.sessions,
+		deps.webhooks,
+		deps.emailQueue,
+		deps.emailPreview,
 	)
 	if err != nil {
 		t.Fatalf("NewAdminHandler() error = %v", err)
@@ -46,11 +66,12 @@ func newTestAdminHandler(t *testing.T) (*AdminHandler, *adminTestDeps) {
 	return h, deps
 }
 
-// postAdminJSON 以管理员（uid-admin）身份请求；带 uid 参数可覆盖 target
+// postAdminJSON 以拥有全部权限的管理员（uid-admin）身份请求；带 uid 参数可覆盖 target
 func postAdminJSON(h gin.HandlerFunc, body string) *httptest.ResponseRecorder {
 	r := gin.New()
 	r.POST("/test/:uid", func(c *gin.Context) {
 		c.Set(middleware.ContextKeyUID, "uid-admin")
+		c.Set(adminmw.ContextKeyPermissions, models.RolePermissions(models.RoleSuperAdmin))
 		h(c)
 	})
 	req := httptest.NewRequest(http.MethodPost, "/test/target-uid", bytes.NewBufferString(body))
@@ -203,6 +224,94 @@ func TestSetUserRoleInvalid(t *testing.T) {
 	}
 }
 
+func TestSetUserRoleRequiresHeldPermissions(t *testing.T) {
+	h, deps := newTestAdminHandler(t)
+	seedAdminUser(deps)
+
+	// 操作者仅持有 users.role，不能授予管理员角色自带的权限
+	r := gin.New()
+	r.POST("/test/:uid", func(c *gin.Context) {
+		c.Set(middleware.ContextKeyUID, "uid-admin")
+		c.Set(adminmw.ContextKeyPermissions, []string{models.PermUsersRole})
+		h.SetUserRole(c)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/test/target-uid", bytes.NewBufferString(`{"role":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "PERMISSION_ESCALATION") {
+		t.Errorf("status = %d body = %s, want 403 PERMISSION_ESCALATION", w.Code, w.Body.String())
+	}
+}
+
+func TestUserGroupCRUDAndMembership(t *testing.T) {
+	h, deps := newTestAdminHandler(t)
+	seedAdminUser(deps)
+
+	// 操作者持有 groups.write 与 users.read、users.ban，但没有 data.export
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(middleware.ContextKeyUID, "uid-admin")
+		c.Set(adminmw.ContextKeyPermissions, []string{models.PermUsersRead, models.PermUsersBan, models.PermGroupsWrite})
+	})
+	r.POST("/groups", h.CreateUserGroup)
+	r.PUT("/groups/:id", h.UpdateUserGroup)
+	r.DELETE("/groups/:id", h.DeleteUserGroup)
+	r.PUT("/groups/:id/members/:uid", h.AddUserGroupMember)
+	r.DELETE("/groups/:id/members/:uid", h.RemoveUserGroupMember)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	privileged := deps.groups.Seed(&models.UserGroup{Name: "exporters", Permissions: []string{models.PermDataExport}})
+
+	cases := []struct {
+		method, path, body string
+		status             int
+		code               string
+	}{
+		{http.MethodPost, "/groups", `{"name":"support","permissions":["users.read"," users.ban","users.read"]}`, http.StatusOK, `"permissions":["users.read","users.ban"]`},
+		{http.MethodPost, "/groups", `{"name":"support"}`, http.StatusConflict, "GROUP_EXISTS"},
+		{http.MethodPost, "/groups", `{"name":"Bad Name"}`, http.StatusBadRequest, "INVALID_GROUP_NAME"},
+		{http.MethodPost, "/groups", `{"name":"x","permissions":["root.all"]}`, http.StatusBadRequest, "INVALID_PERMISSION"},
+		{http.MethodPost, "/groups", `{"name":"x","permissions":["data.export"]}`, http.StatusForbidden, "PERMISSION_ESCALATION"},
+		{http.MethodPut, "/groups/2", `{"name":"support","permissions":["users.read","data.export"]}`, http.StatusForbidden, "PERMISSION_ESCALATION"},
+		{http.MethodPut, "/groups/9", `{"name":"support"}`, http.StatusNotFound, "GROUP_NOT_FOUND"},
+		{http.MethodPut, "/groups/2/members/target-uid", ``, http.StatusOK, ""},
+		{http.MethodPut, "/groups/2/members/ghost", ``, http.StatusNotFound, "USER_NOT_FOUND"},
+		{http.MethodPut, "/groups/1/members/target-uid", ``, http.StatusForbidden, "PERMISSION_ESCALATION"},
+		{http.MethodDelete, "/groups/1", ``, http.StatusForbidden, "PERMISSION_ESCALATION"},
+	}
+	for _, tc := range cases {
+		w := do(tc.method, tc.path, tc.body)
+		if w.Code != tc.status || !strings.Contains(w.Body.String(), tc.code) {
+			t.Errorf("%s %s %s = %d %s, want %d %s", tc.method, tc.path, tc.body, w.Code, w.Body.String(), tc.status, tc.code)
+		}
+	}
+
+	if got := deps.groups.Members(2); len(got) != 1 || got[0] != "target-uid" {
+		t.Errorf("support members = %v, want [target-uid]", got)
+	}
+	if got := deps.groups.Members(privileged); len(got) != 0 {
+		t.Errorf("exporters members = %v, want none", got)
+	}
+
+	if w := do(http.MethodDelete, "/groups/2/members/target-uid", ``); w.Code != http.StatusOK {
+		t.Errorf("remove member = %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodDelete, "/groups/2/members/target-uid", ``); w.Code != http.StatusNotFound {
+		t.Errorf("remove non-member = %d, want 404", w.Code)
+	}
+	if w := do(http.MethodDelete, "/groups/2", ``); w.Code != http.StatusOK {
+		t.Errorf("delete group = %d %s", w.Code, w.Body.String())
+	}
+}
+
 func TestDeleteUserSuccess(t *testing.T) {
 	h, deps := newTestAdminHandler(t)
 	seedAdminUser(deps)
@@ -211,6 +320,164 @@ func TestDeleteUserSuccess(t *testing.T) {
 	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "User deleted") {
 		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
 	}
+
+	// 管理员删除不经过用户日志，直接发布 user.deleted
+	published := deps.webhooks.Published
+	if len(published) != 1 || published[0].EventType != models.WebhookEventUserDeleted {
+		t.Fatalf("published = %+v, want one user.deleted", published)
+	}
+	if data := published[0].Data.(services.WebhookUserDeletedData); data.UserUID != "target-uid" || data.DeletedBy != services.WebhookDeletedByAdmin {
+		t.Errorf("user.deleted data = %+v", data)
+	}
+}
+
+func TestWebhookCRUDAndRedeliver(t *testing.T) {
+	h, deps := newTestAdminHandler(t)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(middleware.ContextKeyUID, "uid-admin")
+	})
+	r.POST("/webhooks", h.CreateWebhook)
+	r.PUT("/webhooks/:id", h.UpdateWebhook)
+	r.DELETE("/webhooks/:id", h.DeleteWebhook)
+	r.POST("/webhooks/:id/secret", h.RegenerateWebhookSecret)
+	r.GET("/webhooks/:id/deliveries", h.GetWebhookDeliveries)
+	r.POST("/webhooks/:id/deliveries/:deliveryId/redeliver", h.RedeliverWebhook)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	disabled := deps.webhooks.Seed(&models.WebhookEndpoint{URL: "https://old.example.com/hook", Events: []string{models.WebhookEventUserBanned}})
+	deps.webhooks.Deliveries[7] = &models.WebhookDelivery{ID: 7, EndpointID: disabled, EventID: "evt_old", Status: models.WebhookDeliveryFailed}
+
+	cases := []struct {
+		method, path, body string
+		status             int
+		code               string
+	}{
+		{http.MethodPost, "/webhooks", `{"url":"https://svc.example.com/hook","events":["user.deleted"," user.banned","user.deleted"]}`, http.StatusOK, `"secret":"whsec_fake"`},
+		{http.MethodPost, "/webhooks", `{"url":"ftp://svc.example.com","events":["user.deleted"]}`, http.StatusBadRequest, "INVALID_WEBHOOK_URL"},
+		{http.MethodPost, "/webhooks", `{"url":"https://svc.example.com","events":["user.login"]}`, http.StatusBadRequest, "INVALID_WEBHOOK_EVENT"},
+		{http.MethodPost, "/webhooks", `{"url":"https://svc.example.com","events":[]}`, http.StatusBadRequest, "INVALID_WEBHOOK_EVENT"},
+		{http.MethodPut, "/webhooks/2", `{"url":"https://svc.example.com/v2","events":["user.deleted"],"is_enabled":false}`, http.StatusOK, `"is_enabled":false`},
+		{http.MethodPut, "/webhooks/9", `{"url":"https://svc.example.com","events":["user.deleted"]}`, http.StatusNotFound, "WEBHOOK_NOT_FOUND"},
+		{http.MethodPost, "/webhooks/2/secret", ``, http.StatusOK, "whsec_rotated"},
+		{http.MethodGet, "/webhooks/1/deliveries", ``, http.StatusOK, `"event_id":"evt_old"`},
+		{http.MethodGet, "/webhooks/9/deliveries", ``, http.StatusNotFound, "WEBHOOK_NOT_FOUND"},
+		{http.MethodPost, "/webhooks/1/deliveries/7/redeliver", ``, http.StatusConflict, "WEBHOOK_DISABLED"},
+		{http.MethodPost, "/webhooks/2/deliveries/7/redeliver", ``, http.StatusNotFound, "WEBHOOK_DELIVERY_NOT_FOUND"},
+		{http.MethodPost, "/webhooks/1/deliveries/x/redeliver", ``, http.StatusBadRequest, "INVALID_ID"},
+	}
+	for _, tc := range cases {
+		w := do(tc.method, tc.path, tc.body)
+		if w.Code != tc.status || !strings.Contains(w.Body.String(), tc.code) {
+			t.Errorf("%s %s %s = %d %s, want %d %s", tc.method, tc.path, tc.body, w.Code, w.Body.String(), tc.status, tc.code)
+		}
+	}
+
+	created, _ := deps.webhooks.GetEndpoint(t.Context(), 2)
+	if created == nil || len(created.Events) != 1 || created.IsEnabled {
+		t.Errorf("updated webhook = %+v", created)
+	}
+
+	// 端点重新启用后可手动重新投递，沿用原事件 ID
+	endpoint, _ := deps.webhooks.GetEndpoint(t.Context(), disabled)
+	endpoint.IsEnabled = true
+	w := do(http.MethodPost, "/webhooks/1/deliveries/7/redeliver", ``)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"event_id":"evt_old"`) || !strings.Contains(w.Body.String(), `"status":"pending"`) {
+		t.Errorf("redeliver = %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do(http.MethodDelete, "/webhooks/2", ``); w.Code != http.StatusOK {
+		t.Errorf("delete webhook = %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodDelete, "/webhooks/2", ``); w.Code != http.StatusNotFound {
+		t.Errorf("delete missing webhook = %d, want 404", w.Code)
+	}
+}
+
+func TestResetUserTwoFactor(t *testing.T) {
+	h, deps := newTestAdminHandler(t)
+	target := seedAdminUser(deps)
+
+	w := postAdminJSON(h.ResetUserTwoFactor, "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "No change") {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+
+	target.TOTPEnabled = true
+	w = postAdminJSON(h.ResetUserTwoFactor, "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Two-factor authentication reset") {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if len(deps.userRepo.TOTPDisabled) != 1 || target.TOTPEnabled {
+		t.Errorf("DisableTOTP calls = %v", deps.userRepo.TOTPDisabled)
+	}
+}
+
+func TestImpersonateUser(t *testing.T) {
+	h, deps := newTestAdminHandler(t)
+	target := seedAdminUser(deps)
+
+	w := postAdminJSON(h.ImpersonateUser, `{"reason":"ticket #42"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "expiresAt") {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if deps.sessions.ImpersonatedUID != "target-uid" || deps.sessions.ImpersonatorUID != "uid-admin" {
+		t.Errorf("impersonation token for %q by %q", deps.sessions.ImpersonatedUID, deps.sessions.ImpersonatorUID)
+	}
+	if cookie := w.Header().Get("Set-Cookie"); !strings.Contains(cookie, "fake-impersonation-token") {
+		t.Errorf("Set-Cookie = %q, want impersonation token", cookie)
+	}
+
+	// 不能模拟超级管理员
+	target.Role = models.RoleSuperAdmin
+	w = postAdminJSON(h.ImpersonateUser, `{}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "CANNOT_IMPERSONATE_SUPER_ADMIN") {
+		t.Errorf("status = %d body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestStopImpersonation(t *testing.T) {
+	h, deps := newTestAdminHandler(t)
+	seedAdminUser(deps)
+
+	run := func(impersonator string) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.POST("/stop", func(c *gin.Context) {
+			c.Set(middleware.ContextKeyUID, "target-uid")
+			if impersonator != "" {
+				c.Set(middleware.ContextKeyImpersonator, impersonator)
+				c.Set(middleware.ContextKeySessionID, "fam-imp")
+			}
+			h.StopImpersonation(c)
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/stop", nil))
+		return w
+	}
+
+	if w := run(""); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "NOT_IMPERSONATING") {
+		t.Errorf("status = %d body = %s", w.Code, w.Body.String())
+	}
+
+	deps.sessions.Sessions = []*models.SessionToken{{UserUID: "target-uid", FamilyID: "fam-imp"}}
+	w := run("uid-admin")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	// 模拟会话被撤销，已签发的模拟 token 随之失效
+	if len(deps.sessions.Sessions) != 0 {
+		t.Errorf("impersonation session not revoked: %+v", deps.sessions.Sessions)
+	}
+	if cookie := w.Header().Get("Set-Cookie"); !strings.Contains(cookie, "Max-Age=0") && !strings.Contains(cookie, "Max-Age=-1") {
+		t.Errorf("Set-Cookie = %q, want token cookie cleared", cookie)
+	}
 }
 
 func TestCreateOAuthClient(t *testing.T) {
@@ -223,7 +490,7 @@ func TestCreateOAuthClient(t *testing.T) {
 		c.Set(middleware.ContextKeyUID, "uid-admin")
 		h.CreateOAuthClient(c)
 	})
-	req := httptest.NewRequest(http.MethodPost, "/test", bytes.NewBufferString(`{"name":"My App","description":"test","redirect_uri":"https://app.example.com/cb"}`))
+	req := httptest.NewRequest(http.MethodPost, "/test", bytes.NewBufferString(`{"name":"My App","description":"test","redirect_uris":["https://app.example.com/cb","http://127.0.0.1/cb"],"post_logout_redirect_uris":["https://app.example.com/bye"]}`))
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
@@ -234,6 +501,136 @@ func TestCreateOAuthClient(t *testing.T) {
 	if len(deps.oauth.Created) != 1 || deps.oauth.Created[0] != "My App" {
 		t.Errorf("created clients = %v", deps.oauth.Created)
 	}
+	if !strings.Contains(w.Body.String(), `"redirect_uris":["https://app.example.com/cb","http://127.0.0.1/cb"]`) {
+		t.Errorf("response should list redirect_uris, got %s", w.Body.String())
+	}
+}
+
+// 授予 scim scope 等同于获得用户供应能力，须持有 users.provision
+func TestCreateOAuthClientSCIMScopeRequiresProvision(t *testing.T) {
+	h, deps := newTestAdminHandler(t)
+	seedAdminUser(deps)
+
+	for _, tc := range []struct {
+		perms []string
+		want  int
+	}{
+		{models.RolePermissions(models.RoleAdmin), http.StatusForbidden},
+		{models.RolePermissions(models.RoleSuperAdmin), http.StatusOK},
+	} {
+		r := gin.New()
+		r.POST("/test", func(c *gin.Context) {
+			c.Set(middleware.ContextKeyUID, "uid-admin")
+			c.Set(adminmw.ContextKeyPermissions, tc.perms)
+			h.CreateOAuthClient(c)
+		})
+		req := httptest.NewRequest(http.MethodPost, "/test", bytes.NewBufferString(`{"name":"HR","redirect_uris":["https://hr.example.com/cb"],"client_scopes":["scim"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tc.want {
+			t.Errorf("perms %v: status = %d, want %d (body=%s)", tc.perms, w.Code, tc.want, w.Body.String())
+		}
+	}
+	if len(deps.oauth.Created) != 1 {
+		t.Errorf("created clients = %v, want only the super admin's", deps.oauth.Created)
+	}
+}
+
+func TestUpdateOAuthClientValidatesRedirectURIs(t *testing.T) {
+	h, deps := newTestAdminHandler(t)
+	seedAdminUser(deps)
+	deps.oauth.Client = &models.OAuthClient{ID: 1, Name: "app"}
+
+	r := gin.New()
+	r.PUT("/test/:id", func(c *gin.Context) {
+		c.Set(middleware.ContextKeyUID, "uid-admin")
+		h.UpdateOAuthClient(c)
+	})
+	cases := map[string]int{
+		`{"redirect_uris":["https://app.example.com/cb"]}`:                                http.StatusOK,
+		`{"redirect_uris":["https://app.example.com/cb"],"post_logout_redirect_uris":[]}`: http.StatusOK,
+		`{"redirect_uris":[]}`:            http.StatusBadRequest,
+		`{"redirect_uris":["not a url"]}`: http.StatusBadRequest,
+		`{"redirect_uris":["https://app.example.com/cb"],"post_logout_redirect_uris":["bad uri"]}`: http.StatusBadRequest,
+	}
+	for body, want := range cases {
+		req := httptest.NewRequest(http.MethodPut, "/test/1", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s: status = %d, want %d (body=%s)", body, w.Code, want, w.Body.String())
+		}
+	}
+}
+
+func TestUpdateOAuthClientUnknownScope(t *testing.T) {
+	h, deps := newTestAdminHandler(t)
+	seedAdminUser(deps)
+	deps.oauth.Client = &models.OAuthClient{ID: 1, Name: "app"}
+	deps.oauth.UpdateErr = services.ErrOAuthUnknownScope
+
+	r := gin.New()
+	r.PUT("/test/:id", func(c *gin.Context) {
+		c.Set(middleware.ContextKeyUID, "uid-admin")
+		h.UpdateOAuthClient(c)
+	})
+	req := httptest.NewRequest(http.MethodPut, "/test/1", bytes.NewBufferString(`{"redirect_uris":["https://app.example.com/cb"],"allowed_scopes":["openid","files:read"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "UNKNOWN_SCOPE") {
+		t.Errorf("status = %d %s, want 400 UNKNOWN_SCOPE", w.Code, w.Body.String())
+	}
+}
+
+func TestOAuthScopeCRUD(t *testing.T) {
+	h, deps := newTestAdminHandler(t)
+	seedAdminUser(deps)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(middleware.ContextKeyUID, "uid-admin") })
+	r.POST("/scopes", h.CreateOAuthScope)
+	r.PUT("/scopes/:id", h.UpdateOAuthScope)
+	r.DELETE("/scopes/:id", h.DeleteOAuthScope)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/scopes", `{"name":"files:read","descriptions":{"en":" Read your files ","ja":""}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("create status = %d (body=%s)", w.Code, w.Body.String())
+	}
+	if len(deps.oauth.Scopes) != 1 || deps.oauth.Scopes[0].Descriptions["en"] != "Read your files" || len(deps.oauth.Scopes[0].Descriptions) != 1 {
+		t.Fatalf("stored scopes = %+v, want trimmed en description only", deps.oauth.Scopes)
+	}
+
+	cases := []struct {
+		method, path, body string
+		status             int
+		code               string
+	}{
+		{http.MethodPost, "/scopes", `{"name":"files:read"}`, http.StatusConflict, "SCOPE_EXISTS"},
+		{http.MethodPost, "/scopes", `{"name":"openid"}`, http.StatusBadRequest, "INVALID_SCOPE_NAME"},
+		{http.MethodPost, "/scopes", `{"name":"bad scope"}`, http.StatusBadRequest, "INVALID_SCOPE_NAME"},
+		{http.MethodPost, "/scopes", `{"name":"files:write","descriptions":{"fr":"x"}}`, http.StatusBadRequest, "INVALID_SCOPE_DESCRIPTION"},
+		{http.MethodPut, "/scopes/9", `{"descriptions":{"en":"x"}}`, http.StatusNotFound, "SCOPE_NOT_FOUND"},
+		{http.MethodPut, "/scopes/1", `{"descriptions":{"zh-CN":"读取你的文件"}}`, http.StatusOK, ""},
+		{http.MethodDelete, "/scopes/1", ``, http.StatusOK, ""},
+		{http.MethodDelete, "/scopes/1", ``, http.StatusNotFound, "SCOPE_NOT_FOUND"},
+	}
+	for _, tc := range cases {
+		w := do(tc.method, tc.path, tc.body)
+		if w.Code != tc.status || !strings.Contains(w.Body.String(), tc.code) {
+			t.Errorf("%s %s %s = %d %s, want %d %s", tc.method, tc.path, tc.body, w.Code, w.Body.String(), tc.status, tc.code)
+		}
+	}
 }
 
 func TestToggleOAuthClient(t *testing.T) {
@@ -280,4 +677,52 @@ func TestGetStats(t *testing.T) {
 	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"totalUsers":2`) {
 		t.Errorf("status = %d body = %s", w.Code, w.Body.String())
 	}
+	if !strings.Contains(w.Body.String(), `"emailQueue":{"pending":3,"sent24h":0,"bounced24h":1,"failed24h":0}`) {
+		t.Errorf("email queue stats missing: %s", w.Body.String())
+	}
+}
+
+func TestGetRateLimits(t *testing.T) {
+	h, deps := newTestAdminHandler(t)
+	deps.limiter.Limited = map[string][]models.LimitedKey{
+		"login": {{Key: "203.0.113.7", RetryAfter: 12}},
+		"email": {},
+	}
+
+	r := gin.New()
+	r.GET("/test", h.GetRateLimits)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, `"backend":"memory"`) ||
+		!strings.Contains(body, `"login":[{"key":"203.0.113.7","retryAfter":12}]`) {
+		t.Errorf("status = %d body = %s", w.Code, body)
+	}
+}
+
+func TestPreviewEmailTemplate(t *testing.T) {
+	h, _ := newTestAdminHandler(t)
+
+	r := gin.New()
+	r.GET("/email-templates", h.GetEmailTemplates)
+	r.GET("/email-templates/:type/preview", h.PreviewEmailTemplate)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/email-templates", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"types":["new_login"]`) {
+		t.Errorf("list status = %d body = %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/email-templates/new_login/preview?lang=en", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"subject":"new_login/en"`) {
+		t.Errorf("preview status = %d body = %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/email-templates/nope/preview", nil))
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "EMAIL_TEMPLATE_NOT_FOUND") {
+		t.Errorf("unknown type status = %d body = %s", w.Code, w.Body.String())
+	}
 }
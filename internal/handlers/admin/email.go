@@ -0,0 +1,62 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"auth-system/internal/services"
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// emailPreviewResponse 邮件模板预览响应
+type emailPreviewResponse struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+// GetEmailTemplates 获取可预览的邮件类型与语言
+// GET /admin/api/email-templates
+//
+// 权限：email_templates.read
+func (h *AdminHandler) GetEmailTemplates(c *gin.Context) {
+	if h.emailPreviewer == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "EMAIL_NOT_CONFIGURED")
+		return
+	}
+
+	utils.RespondSuccessWithData(c, gin.H{
+		"types":     h.emailPreviewer.EmailTemplateTypes(),
+		"languages": h.emailPreviewer.EmailLanguages(),
+	})
+}
+
+// PreviewEmailTemplate 用示例数据渲染指定类型的邮件，lang 缺省或不支持时使用默认语言
+// GET /admin/api/email-templates/:type/preview?lang=en
+//
+// 权限：email_templates.read
+func (h *AdminHandler) PreviewEmailTemplate(c *gin.Context) {
+	if h.emailPreviewer == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "EMAIL_NOT_CONFIGURED")
+		return
+	}
+
+	emailType := c.Param("type")
+	rendered, err := h.emailPreviewer.PreviewEmail(emailType, c.Query("lang"))
+	if errors.Is(err, services.ErrEmailUnknownType) {
+		utils.RespondError(c, http.StatusNotFound, "EMAIL_TEMPLATE_NOT_FOUND")
+		return
+	}
+	if err != nil {
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, "RENDER_FAILED", err.Error())
+		return
+	}
+
+	utils.RespondSuccessWithData(c, emailPreviewResponse{
+		Subject: rendered.Subject,
+		HTML:    rendered.HTMLBody,
+		Text:    rendered.TextBody,
+	})
+}
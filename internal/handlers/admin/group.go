@@ -0,0 +1,351 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"auth-system/internal/middleware"
+	adminmw "auth-system/internal/middleware/admin"
+	"auth-system/internal/models"
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// userGroupRequest 创建/更新用户组请求
+type userGroupRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// GetUserGroups 获取用户组列表及可分配的权限清单
+// GET /admin/api/groups
+//
+// 权限：groups.read
+func (h *AdminHandler) GetUserGroups(c *gin.Context) {
+	if h.userGroupRepo == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "USER_GROUPS_NOT_CONFIGURED")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	groups, err := h.userGroupRepo.FindAll(ctx)
+	if err != nil {
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, "DATABASE_ERROR", err.Error())
+		return
+	}
+
+	utils.RespondSuccessWithData(c, gin.H{
+		"groups":      groups,
+		"permissions": models.Permissions,
+	})
+}
+
+// GetUserGroupMembers 获取用户组成员
+// GET /admin/api/groups/:id/members
+//
+// 权限：groups.read
+func (h *AdminHandler) GetUserGroupMembers(c *gin.Context) {
+	if h.userGroupRepo == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "USER_GROUPS_NOT_CONFIGURED")
+		return
+	}
+
+	id, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	group, err := h.userGroupRepo.FindByID(ctx, id)
+	if err != nil {
+		respondUserGroupError(c, err, "QUERY_FAILED")
+		return
+	}
+
+	members, err := h.userGroupRepo.ListMembers(ctx, id)
+	if err != nil {
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, "QUERY_FAILED", err.Error())
+		return
+	}
+
+	utils.RespondSuccessWithData(c, gin.H{"group": group, "members": members})
+}
+
+// CreateUserGroup 创建用户组
+// POST /admin/api/groups
+//
+// 权限：groups.write（只能授予自己拥有的权限）
+func (h *AdminHandler) CreateUserGroup(c *gin.Context) {
+	if h.userGroupRepo == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "USER_GROUPS_NOT_CONFIGURED")
+		return
+	}
+
+	var req userGroupRequest
+	if !utils.BindJSONOrError(c, "ADMIN", &req, "INVALID_REQUEST") {
+		return
+	}
+
+	group := req.toUserGroup()
+	if !canManagePermissions(c, group.Permissions) {
+		return
+	}
+
+	operatorUID, _ := middleware.GetUID(c)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	if err := h.userGroupRepo.Create(ctx, group); err != nil {
+		respondUserGroupError(c, err, "CREATE_FAILED")
+		return
+	}
+
+	if err := h.logRepo.LogUserGroupCreate(ctx, operatorUID, group); err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to log create user group", "error", err)
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "ADMIN", "User group created", "operator_uid", operatorUID, "group", group.Name)
+	utils.RespondSuccessWithData(c, gin.H{"group": group})
+}
+
+// UpdateUserGroup 更新用户组名称、描述与权限
+// PUT /admin/api/groups/:id
+//
+// 权限：groups.write（只能修改权限全部为自己所拥有的组）
+func (h *AdminHandler) UpdateUserGroup(c *gin.Context) {
+	if h.userGroupRepo == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "USER_GROUPS_NOT_CONFIGURED")
+		return
+	}
+
+	id, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+
+	var req userGroupRequest
+	if !utils.BindJSONOrError(c, "ADMIN", &req, "INVALID_REQUEST") {
+		return
+	}
+
+	operatorUID, _ := middleware.GetUID(c)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	existing, err := h.userGroupRepo.FindByID(ctx, id)
+	if err != nil {
+		respondUserGroupError(c, err, "QUERY_FAILED")
+		return
+	}
+
+	group := req.toUserGroup()
+	group.ID = id
+	if !canManagePermissions(c, existing.Permissions) || !canManagePermissions(c, group.Permissions) {
+		return
+	}
+
+	if err := h.userGroupRepo.Update(ctx, group); err != nil {
+		respondUserGroupError(c, err, "UPDATE_FAILED")
+		return
+	}
+
+	if err := h.logRepo.LogUserGroupUpdate(ctx, operatorUID, group); err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to log update user group", "error", err)
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "ADMIN", "User group updated", "operator_uid", operatorUID, "group", group.Name)
+	utils.RespondSuccessWithData(c, gin.H{"group": group})
+}
+
+// DeleteUserGroup 删除用户组，成员随之失去该组授予的权限
+// DELETE /admin/api/groups/:id
+//
+// 权限：groups.write（只能删除权限全部为自己所拥有的组）
+func (h *AdminHandler) DeleteUserGroup(c *gin.Context) {
+	if h.userGroupRepo == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "USER_GROUPS_NOT_CONFIGURED")
+		return
+	}
+
+	id, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+
+	operatorUID, _ := middleware.GetUID(c)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	existing, err := h.userGroupRepo.FindByID(ctx, id)
+	if err != nil {
+		respondUserGroupError(c, err, "QUERY_FAILED")
+		return
+	}
+	if !canManagePermissions(c, existing.Permissions) {
+		return
+	}
+
+	group, err := h.userGroupRepo.Delete(ctx, id)
+	if err != nil {
+		respondUserGroupError(c, err, "DELETE_FAILED")
+		return
+	}
+
+	if err := h.logRepo.LogUserGroupDelete(ctx, operatorUID, group); err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to log delete user group", "error", err)
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "ADMIN", "User group deleted", "operator_uid", operatorUID, "group", group.Name)
+	utils.RespondSuccess(c, gin.H{"message": "Group deleted"})
+}
+
+// AddUserGroupMember 将用户加入用户组（已是成员时视为成功）
+// PUT /admin/api/groups/:id/members/:uid
+//
+// 权限：groups.write（只能分配权限全部为自己所拥有的组）
+func (h *AdminHandler) AddUserGroupMember(c *gin.Context) {
+	h.changeUserGroupMember(c, true)
+}
+
+// RemoveUserGroupMember 将用户移出用户组
+// DELETE /admin/api/groups/:id/members/:uid
+//
+// 权限：groups.write（只能调整权限全部为自己所拥有的组）
+func (h *AdminHandler) RemoveUserGroupMember(c *gin.Context) {
+	h.changeUserGroupMember(c, false)
+}
+
+func (h *AdminHandler) changeUserGroupMember(c *gin.Context, add bool) {
+	if h.userGroupRepo == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "USER_GROUPS_NOT_CONFIGURED")
+		return
+	}
+
+	id, ok := parseGroupID(c)
+	if !ok {
+		return
+	}
+
+	targetUserUID := c.Param("uid")
+	if targetUserUID == "" {
+		utils.RespondError(c, http.StatusBadRequest, "INVALID_USER_UID")
+		return
+	}
+
+	operatorUID, _ := middleware.GetUID(c)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	group, err := h.userGroupRepo.FindByID(ctx, id)
+	if err != nil {
+		respondUserGroupError(c, err, "QUERY_FAILED")
+		return
+	}
+	if !canManagePermissions(c, group.Permissions) {
+		return
+	}
+
+	targetUser, err := h.userRepo.FindByUID(ctx, targetUserUID)
+	if err != nil {
+		if utils.IsDatabaseNotFound(err) {
+			utils.RespondError(c, http.StatusNotFound, "USER_NOT_FOUND")
+			return
+		}
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, "QUERY_FAILED", err.Error())
+		return
+	}
+
+	if add {
+		if targetUser.CheckBanned() {
+			utils.HTTPErrorResponse(c, "ADMIN", http.StatusBadRequest, "CANNOT_PROMOTE_BANNED_USER", "Attempted to add banned user to group")
+			return
+		}
+		err = h.userGroupRepo.AddMember(ctx, id, targetUserUID)
+	} else {
+		err = h.userGroupRepo.RemoveMember(ctx, id, targetUserUID)
+	}
+	if err != nil {
+		respondUserGroupError(c, err, "UPDATE_FAILED")
+		return
+	}
+
+	if add {
+		err = h.logRepo.LogUserGroupAddMember(ctx, operatorUID, targetUserUID, targetUser.Username, group)
+	} else {
+		err = h.logRepo.LogUserGroupRemoveMember(ctx, operatorUID, targetUserUID, targetUser.Username, group)
+	}
+	if err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to log user group membership change", "error", err)
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "ADMIN", "User group membership changed", "operator_uid", operatorUID, "group", group.Name, "target_uid", targetUserUID, "added", add)
+	utils.RespondSuccess(c, gin.H{"message": "Group membership updated"})
+}
+
+// toUserGroup 去除首尾空白并去重权限
+func (req *userGroupRequest) toUserGroup() *models.UserGroup {
+	perms := make([]string, 0, len(req.Permissions))
+	for _, perm := range req.Permissions {
+		if perm = strings.TrimSpace(perm); perm != "" && !slices.Contains(perms, perm) {
+			perms = append(perms, perm)
+		}
+	}
+	return &models.UserGroup{
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+		Permissions: perms,
+	}
+}
+
+// canManagePermissions 操作者只能授予或调整自己拥有的权限，防止借用户组提权；不满足时直接响应 403
+func canManagePermissions(c *gin.Context, perms []string) bool {
+	for _, perm := range perms {
+		if models.IsValidPermission(perm) && !adminmw.HasPermission(c, perm) {
+			uid, _ := middleware.GetUID(c)
+			utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Attempted to grant permission not held", "operator_uid", uid, "permission", perm)
+			utils.RespondError(c, http.StatusForbidden, "PERMISSION_ESCALATION")
+			return false
+		}
+	}
+	return true
+}
+
+// parseGroupID 解析路径中的用户组 ID，失败时直接响应 400
+func parseGroupID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		utils.RespondError(c, http.StatusBadRequest, "INVALID_ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// respondUserGroupError 将用户组错误映射为 HTTP 状态码与错误码，其余错误按 fallbackCode 返回 500
+func respondUserGroupError(c *gin.Context, err error, fallbackCode string) {
+	switch {
+	case errors.Is(err, models.ErrUserGroupNotFound):
+		utils.RespondError(c, http.StatusNotFound, "GROUP_NOT_FOUND")
+	case errors.Is(err, models.ErrUserGroupMemberNotFound):
+		utils.RespondError(c, http.StatusNotFound, "GROUP_MEMBER_NOT_FOUND")
+	case errors.Is(err, models.ErrUserGroupExists):
+		utils.RespondError(c, http.StatusConflict, "GROUP_EXISTS")
+	case errors.Is(err, models.ErrUserGroupInvalidName):
+		utils.RespondError(c, http.StatusBadRequest, "INVALID_GROUP_NAME")
+	case errors.Is(err, models.ErrUserGroupInvalidDesc):
+		utils.RespondError(c, http.StatusBadRequest, "INVALID_GROUP_DESCRIPTION")
+	case errors.Is(err, models.ErrInvalidPermission):
+		utils.RespondError(c, http.StatusBadRequest, "INVALID_PERMISSION")
+	default:
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, fallbackCode, err.Error())
+	}
+}
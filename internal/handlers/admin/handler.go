@@ -1,11 +1,12 @@
 // Package admin 提供管理后台 API Handler，包括用户管理、数据导入导出、OAuth 配置和系统操作。
-// 所有接口需要管理员权限，敏感操作需要超级管理员权限（SuperAdminMiddleware）。
+// 所有接口经 AdminMiddleware 进入，再由路由上的 RequirePermission 按具体权限把关。
 package admin
 
 import (
 	"errors"
 	"time"
 
+	"auth-system/internal/middleware"
 	"auth-system/internal/models"
 	"auth-system/internal/services"
 	"auth-system/internal/utils"
@@ -34,11 +35,17 @@ type AdminHandler struct {
 	exportService      services.ExportManager
 	dataExportSalt     string
 	dataExportRepo     models.DataExportImportStore
+	limiterMgr         middleware.RateLimiterManager
+	userGroupRepo      models.UserGroupStore
+	sessionService     services.SessionManager
+	webhookService     services.WebhookManager
+	emailQueue         services.EmailQueueManager
+	emailPreviewer     services.EmailTemplatePreviewer
 }
 
 // NewAdminHandler 创建管理后台 Handler，验证必需依赖（userRepo、userCache、logRepo）后初始化。
-// oauthService 和 emailWhitelistRepo 为可选参数。
-func NewAdminHandler(userRepo models.UserStore, userCache services.UserCacheStore, logRepo models.AdminLogStore, userLogRepo models.UserLogStore, oauthService services.OAuthAdminManager, emailWhitelistRepo models.EmailWhitelistStore, exportService services.ExportManager, dataExportSalt string, dataExportRepo models.DataExportImportStore) (*AdminHandler, error) {
+// oauthService、emailWhitelistRepo、limiterMgr、userGroupRepo、sessionService、webhookService、emailQueue 和 emailPreviewer 为可选参数。
+func NewAdminHandler(userRepo models.UserStore, userCache services.UserCacheStore, logRepo models.AdminLogStore, userLogRepo models.UserLogStore, oauthService services.OAuthAdminManager, emailWhitelistRepo models.EmailWhitelistStore, exportService services.ExportManager, dataExportSalt string, dataExportRepo models.DataExportImportStore, limiterMgr middleware.RateLimiterManager, userGroupRepo models.UserGroupStore, sessionService services.SessionManager, webhookService services.WebhookManager, emailQueue services.EmailQueueManager, emailPreviewer services.EmailTemplatePreviewer) (*AdminHandler, error) {
 	if userRepo == nil {
 		return nil, ErrAdminNilUserRepo
 	}
@@ -61,5 +68,11 @@ func NewAdminHandler(userRepo models.UserStore, userCache services.UserCacheStor
 		exportService:      exportService,
 		dataExportSalt:     dataExportSalt,
 		dataExportRepo:     dataExportRepo,
+		limiterMgr:         limiterMgr,
+		userGroupRepo:      userGroupRepo,
+		sessionService:     sessionService,
+		webhookService:     webhookService,
+		emailQueue:         emailQueue,
+		emailPreviewer:     emailPreviewer,
 	}, nil
 }
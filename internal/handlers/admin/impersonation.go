@@ -0,0 +1,128 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"auth-system/internal/middleware"
+	"auth-system/internal/services"
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxImpersonationReasonLength = 255
+
+// impersonateRequest 模拟登录请求
+type impersonateRequest struct {
+	Reason string `json:"reason"` // 可选，写入操作日志
+}
+
+// ImpersonateUser 以目标用户身份登录（模拟登录），用于排查用户看到的页面
+// POST /admin/api/users/:uid/impersonate
+//
+// 权限：仅超级管理员（不能模拟自己或其他超级管理员）。
+// 签发带 act claim 的短期 access_token 覆盖当前 token cookie，不签发 refresh_token；
+// 管理员自己的 refresh_token cookie 保持不变，结束模拟后由 /api/auth/refresh 恢复原会话
+func (h *AdminHandler) ImpersonateUser(c *gin.Context) {
+	operatorUID, _ := middleware.GetUID(c)
+
+	if h.sessionService == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	targetUserUID := c.Param("uid")
+	if targetUserUID == "" {
+		utils.RespondError(c, http.StatusBadRequest, "INVALID_USER_UID")
+		return
+	}
+
+	if targetUserUID == operatorUID {
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusBadRequest, "CANNOT_IMPERSONATE_SELF", "Attempted to impersonate self")
+		return
+	}
+
+	var req impersonateRequest
+	if !utils.BindJSONOrError(c, "ADMIN", &req, "INVALID_REQUEST") {
+		return
+	}
+	if len([]rune(req.Reason)) > maxImpersonationReasonLength {
+		utils.RespondError(c, http.StatusBadRequest, "INVALID_REASON")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	targetUser, err := h.userRepo.FindByUID(ctx, targetUserUID)
+	if err != nil {
+		if utils.IsDatabaseNotFound(err) {
+			utils.RespondError(c, http.StatusNotFound, "USER_NOT_FOUND")
+			return
+		}
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, "QUERY_FAILED", err.Error())
+		return
+	}
+
+	if targetUser.IsSuperAdmin() {
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusForbidden, "CANNOT_IMPERSONATE_SUPER_ADMIN", "Attempted to impersonate super admin")
+		return
+	}
+
+	accessToken, expiresAt, err := h.sessionService.GenerateImpersonationToken(ctx, targetUserUID, operatorUID)
+	if err != nil {
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, "IMPERSONATION_FAILED", err.Error())
+		return
+	}
+
+	if err := h.logRepo.LogImpersonationStart(ctx, operatorUID, targetUserUID, targetUser.Username, req.Reason, expiresAt); err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to log impersonation_start", "error", err)
+	}
+
+	utils.SetTokenCookieGin(c, accessToken)
+
+	utils.LogInfoCtx(c.Request.Context(), "ADMIN", "Impersonation started", "operator_uid", operatorUID, "target_uid", targetUserUID, "expires_at", expiresAt)
+
+	utils.RespondSuccess(c, gin.H{"message": "Impersonation started", "expiresAt": expiresAt.Format(time.RFC3339)})
+}
+
+// StopImpersonation 结束模拟登录：撤销模拟会话、记录日志并清除模拟 token cookie
+// POST /admin/api/impersonation/stop
+//
+// 仅要求 AuthMiddleware（模拟会话无法通过 AdminMiddleware）；调用方随后请求 /api/auth/refresh 恢复管理员会话
+func (h *AdminHandler) StopImpersonation(c *gin.Context) {
+	impersonatorUID := middleware.GetImpersonator(c)
+	if impersonatorUID == "" {
+		utils.RespondError(c, http.StatusBadRequest, "NOT_IMPERSONATING")
+		return
+	}
+	targetUserUID, _ := middleware.GetUID(c)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	var targetUsername string
+	if targetUser, err := h.userRepo.FindByUID(ctx, targetUserUID); err == nil {
+		targetUsername = targetUser.Username
+	}
+
+	// 撤销模拟会话，使已签发的模拟 token 立即失效，而不只是从本浏览器移除
+	if h.sessionService != nil {
+		if err := h.sessionService.RevokeTokenFamily(ctx, targetUserUID, middleware.GetSessionID(c)); err != nil && !errors.Is(err, services.ErrSessionNotFound) {
+			utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to revoke impersonation session", "target_uid", targetUserUID, "error", err)
+		}
+	}
+
+	if err := h.logRepo.LogImpersonationStop(ctx, impersonatorUID, targetUserUID, targetUsername); err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to log impersonation_stop", "error", err)
+	}
+
+	utils.ClearTokenCookieGin(c)
+
+	utils.LogInfoCtx(c.Request.Context(), "ADMIN", "Impersonation stopped", "operator_uid", impersonatorUID, "target_uid", targetUserUID)
+
+	utils.RespondSuccess(c, gin.H{"message": "Impersonation stopped"})
+}
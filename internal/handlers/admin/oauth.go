@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 
 	"auth-system/internal/middleware"
 	"auth-system/internal/models"
@@ -31,16 +33,25 @@ type createOAuthClientResponse struct {
 
 // createOAuthClientRequest 创建 OAuth 客户端请求
 type createOAuthClientRequest struct {
-	Name        string `json:"name" binding:"required,min=1,max=100"`
-	Description string `json:"description" binding:"max=500"`
-	RedirectURI string `json:"redirect_uri" binding:"required,url"`
+	Name                   string                   `json:"name" binding:"required,min=1,max=100"`
+	Description            string                   `json:"description" binding:"max=500"`
+	RedirectURIs           []string                 `json:"redirect_uris" binding:"required,min=1,dive,url"`
+	PostLogoutRedirectURIs []string                 `json:"post_logout_redirect_uris" binding:"omitempty,dive,url"`
+	ClientScopes           []string                 `json:"client_scopes"`
+	AllowedScopes          []string                 `json:"allowed_scopes"` // 省略时使用默认白名单 openid/profile/email
+	ClaimMapping           models.OAuthClaimMapping `json:"claim_mapping"`
 }
 
 // updateOAuthClientRequest 更新 OAuth 客户端请求
+// post_logout_redirect_uris、client_scopes、allowed_scopes、claim_mapping 省略时保持不变，传空数组（对象）表示清空
 type updateOAuthClientRequest struct {
-	Name        string  `json:"name" binding:"omitempty,min=1,max=100"`
-	Description *string `json:"description" binding:"max=500"`
-	RedirectURI string  `json:"redirect_uri" binding:"required,url"`
+	Name                   string                    `json:"name" binding:"omitempty,min=1,max=100"`
+	Description            *string                   `json:"description" binding:"omitempty,max=500"`
+	RedirectURIs           []string                  `json:"redirect_uris" binding:"required,min=1,dive,url"`
+	PostLogoutRedirectURIs *[]string                 `json:"post_logout_redirect_uris" binding:"omitempty,dive,url"`
+	ClientScopes           *[]string                 `json:"client_scopes"`
+	AllowedScopes          *[]string                 `json:"allowed_scopes"`
+	ClaimMapping           *models.OAuthClaimMapping `json:"claim_mapping"`
 }
 
 // regenerateSecretResponse 重新生成密钥响应
@@ -56,7 +67,7 @@ type toggleOAuthClientRequest struct {
 // GetOAuthClients 获取 OAuth 客户端列表
 // GET /admin/api/oauth/clients?page=1&pageSize=20&search=xxx
 //
-// 权限：超级管理员
+// 权限：oauth.clients.read
 func (h *AdminHandler) GetOAuthClients(c *gin.Context) {
 	if h.oauthService == nil {
 		utils.RespondError(c, http.StatusServiceUnavailable, "OAUTH_NOT_CONFIGURED")
@@ -100,7 +111,7 @@ func (h *AdminHandler) GetOAuthClients(c *gin.Context) {
 // GetOAuthClient 获取 OAuth 客户端详情
 // GET /admin/api/oauth/clients/:id
 //
-// 权限：超级管理员
+// 权限：oauth.clients.read
 func (h *AdminHandler) GetOAuthClient(c *gin.Context) {
 	if h.oauthService == nil {
 		utils.RespondError(c, http.StatusServiceUnavailable, "OAUTH_NOT_CONFIGURED")
@@ -128,7 +139,7 @@ func (h *AdminHandler) GetOAuthClient(c *gin.Context) {
 // CreateOAuthClient 创建 OAuth 客户端
 // POST /admin/api/oauth/clients
 //
-// 权限：超级管理员
+// 权限：oauth.clients.write
 func (h *AdminHandler) CreateOAuthClient(c *gin.Context) {
 	if h.oauthService == nil {
 		utils.RespondError(c, http.StatusServiceUnavailable, "OAUTH_NOT_CONFIGURED")
@@ -142,13 +153,18 @@ func (h *AdminHandler) CreateOAuthClient(c *gin.Context) {
 		return
 	}
 
+	// scim scope 允许客户端经 SCIM 创建、修改与停用用户，授予时要求操作者持有 users.provision
+	if grantsSCIM(req.ClientScopes) && !canManagePermissions(c, []string{models.PermUsersProvision}) {
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
 	defer cancel()
 
-	client, clientSecret, err := h.oauthService.CreateClient(ctx, req.Name, req.Description, req.RedirectURI)
+	client, clientSecret, err := h.oauthService.CreateClient(ctx, req.Name, req.Description, req.RedirectURIs, req.PostLogoutRedirectURIs, req.ClientScopes, req.AllowedScopes, req.ClaimMapping)
 	if err != nil {
-		if errors.Is(err, services.ErrOAuthInvalidRedirect) {
-			utils.RespondError(c, http.StatusBadRequest, "INVALID_REDIRECT_URI")
+		if code, ok := oauthClientValidationError(err); ok {
+			utils.RespondError(c, http.StatusBadRequest, code)
 			return
 		}
 		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, "CREATE_FAILED", err.Error())
@@ -170,7 +186,7 @@ func (h *AdminHandler) CreateOAuthClient(c *gin.Context) {
 // UpdateOAuthClient 更新 OAuth 客户端
 // PUT /admin/api/oauth/clients/:id
 //
-// 权限：超级管理员
+// 权限：oauth.clients.write
 func (h *AdminHandler) UpdateOAuthClient(c *gin.Context) {
 	if h.oauthService == nil {
 		utils.RespondError(c, http.StatusServiceUnavailable, "OAUTH_NOT_CONFIGURED")
@@ -199,10 +215,15 @@ func (h *AdminHandler) UpdateOAuthClient(c *gin.Context) {
 		return
 	}
 
-	err = h.oauthService.UpdateClient(ctx, clientID, req.Name, req.Description, req.RedirectURI)
+	if req.ClientScopes != nil && grantsSCIM(*req.ClientScopes) != grantsSCIM(client.ClientScopes) &&
+		!canManagePermissions(c, []string{models.PermUsersProvision}) {
+		return
+	}
+
+	err = h.oauthService.UpdateClient(ctx, clientID, req.Name, req.Description, req.RedirectURIs, req.PostLogoutRedirectURIs, req.ClientScopes, req.AllowedScopes, req.ClaimMapping)
 	if err != nil {
-		if errors.Is(err, services.ErrOAuthInvalidRedirect) {
-			utils.RespondError(c, http.StatusBadRequest, "INVALID_REDIRECT_URI")
+		if code, ok := oauthClientValidationError(err); ok {
+			utils.RespondError(c, http.StatusBadRequest, code)
 			return
 		}
 		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, "UPDATE_FAILED", err.Error())
@@ -221,7 +242,7 @@ func (h *AdminHandler) UpdateOAuthClient(c *gin.Context) {
 // DeleteOAuthClient 删除 OAuth 客户端
 // DELETE /admin/api/oauth/clients/:id
 //
-// 权限：超级管理员
+// 权限：oauth.clients.write
 func (h *AdminHandler) DeleteOAuthClient(c *gin.Context) {
 	if h.oauthService == nil {
 		utils.RespondError(c, http.StatusServiceUnavailable, "OAUTH_NOT_CONFIGURED")
@@ -263,7 +284,7 @@ func (h *AdminHandler) DeleteOAuthClient(c *gin.Context) {
 // RegenerateOAuthClientSecret 重新生成 OAuth 客户端密钥
 // POST /admin/api/oauth/clients/:id/secret
 //
-// 权限：超级管理员
+// 权限：oauth.clients.write
 func (h *AdminHandler) RegenerateOAuthClientSecret(c *gin.Context) {
 	if h.oauthService == nil {
 		utils.RespondError(c, http.StatusServiceUnavailable, "OAUTH_NOT_CONFIGURED")
@@ -307,7 +328,7 @@ func (h *AdminHandler) RegenerateOAuthClientSecret(c *gin.Context) {
 // ToggleOAuthClient 启用/禁用 OAuth 客户端
 // PATCH /admin/api/oauth/clients/:id
 //
-// 权限：超级管理员
+// 权限：oauth.clients.write
 func (h *AdminHandler) ToggleOAuthClient(c *gin.Context) {
 	if h.oauthService == nil {
 		utils.RespondError(c, http.StatusServiceUnavailable, "OAUTH_NOT_CONFIGURED")
@@ -363,3 +384,203 @@ func (h *AdminHandler) ToggleOAuthClient(c *gin.Context) {
 
 	utils.RespondSuccess(c, gin.H{"message": "Client " + status})
 }
+
+// oauthScopeRequest 创建/更新自定义 scope 请求（更新时忽略 name）
+type oauthScopeRequest struct {
+	Name         string            `json:"name"`
+	Descriptions map[string]string `json:"descriptions"`
+}
+
+// GetOAuthScopes 获取自定义 scope 列表
+// GET /admin/api/oauth/scopes
+//
+// 权限：oauth.clients.read
+func (h *AdminHandler) GetOAuthScopes(c *gin.Context) {
+	if h.oauthService == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "OAUTH_NOT_CONFIGURED")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	scopes, err := h.oauthService.ListScopes(ctx)
+	if err != nil {
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, "DATABASE_ERROR", err.Error())
+		return
+	}
+
+	utils.RespondSuccessWithData(c, gin.H{
+		"scopes":    scopes,
+		"builtin":   models.BuiltinOAuthScopes,
+		"languages": models.OAuthScopeLanguages,
+	})
+}
+
+// CreateOAuthScope 创建自定义 scope
+// POST /admin/api/oauth/scopes
+//
+// 权限：oauth.scopes.write
+func (h *AdminHandler) CreateOAuthScope(c *gin.Context) {
+	if h.oauthService == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "OAUTH_NOT_CONFIGURED")
+		return
+	}
+
+	var req oauthScopeRequest
+	if !utils.BindJSONOrError(c, "ADMIN", &req, "INVALID_REQUEST") {
+		return
+	}
+
+	operatorUID, _ := middleware.GetUID(c)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	scope, err := h.oauthService.CreateScope(ctx, req.Name, trimScopeDescriptions(req.Descriptions))
+	if err != nil {
+		if status, code, ok := oauthScopeError(err); ok {
+			utils.RespondError(c, status, code)
+			return
+		}
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, "CREATE_FAILED", err.Error())
+		return
+	}
+
+	if err := h.logRepo.LogOAuthScopeCreate(ctx, operatorUID, scope); err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to log create OAuth scope", "error", err)
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "ADMIN", "OAuth scope created", "operator_uid", operatorUID, "scope", scope.Name)
+	utils.RespondSuccessWithData(c, gin.H{"scope": scope})
+}
+
+// UpdateOAuthScope 更新自定义 scope 的多语言描述（名称不可修改）
+// PUT /admin/api/oauth/scopes/:id
+//
+// 权限：oauth.scopes.write
+func (h *AdminHandler) UpdateOAuthScope(c *gin.Context) {
+	if h.oauthService == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "OAUTH_NOT_CONFIGURED")
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		utils.RespondError(c, http.StatusBadRequest, "INVALID_ID")
+		return
+	}
+
+	var req oauthScopeRequest
+	if !utils.BindJSONOrError(c, "ADMIN", &req, "INVALID_REQUEST") {
+		return
+	}
+
+	operatorUID, _ := middleware.GetUID(c)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	scope, err := h.oauthService.UpdateScope(ctx, id, trimScopeDescriptions(req.Descriptions))
+	if err != nil {
+		if status, code, ok := oauthScopeError(err); ok {
+			utils.RespondError(c, status, code)
+			return
+		}
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, "UPDATE_FAILED", err.Error())
+		return
+	}
+
+	if err := h.logRepo.LogOAuthScopeUpdate(ctx, operatorUID, scope); err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to log update OAuth scope", "error", err)
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "ADMIN", "OAuth scope updated", "operator_uid", operatorUID, "scope", scope.Name)
+	utils.RespondSuccessWithData(c, gin.H{"scope": scope})
+}
+
+// DeleteOAuthScope 删除自定义 scope，并从所有客户端的 scope 白名单中移除
+// DELETE /admin/api/oauth/scopes/:id
+//
+// 权限：oauth.scopes.write
+func (h *AdminHandler) DeleteOAuthScope(c *gin.Context) {
+	if h.oauthService == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "OAUTH_NOT_CONFIGURED")
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		utils.RespondError(c, http.StatusBadRequest, "INVALID_ID")
+		return
+	}
+
+	operatorUID, _ := middleware.GetUID(c)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	scope, err := h.oauthService.DeleteScope(ctx, id)
+	if err != nil {
+		if status, code, ok := oauthScopeError(err); ok {
+			utils.RespondError(c, status, code)
+			return
+		}
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, "DELETE_FAILED", err.Error())
+		return
+	}
+
+	if err := h.logRepo.LogOAuthScopeDelete(ctx, operatorUID, scope); err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to log delete OAuth scope", "error", err)
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "ADMIN", "OAuth scope deleted", "operator_uid", operatorUID, "scope", scope.Name)
+	utils.RespondSuccess(c, gin.H{"message": "Scope deleted"})
+}
+
+// trimScopeDescriptions 去除描述首尾空白，丢弃空描述
+func trimScopeDescriptions(descriptions map[string]string) map[string]string {
+	trimmed := make(map[string]string, len(descriptions))
+	for lang, desc := range descriptions {
+		if desc = strings.TrimSpace(desc); desc != "" {
+			trimmed[lang] = desc
+		}
+	}
+	return trimmed
+}
+
+// oauthScopeError 将自定义 scope 错误映射为 HTTP 状态码与错误码
+func oauthScopeError(err error) (int, string, bool) {
+	switch {
+	case errors.Is(err, services.ErrOAuthScopeNotFound):
+		return http.StatusNotFound, "SCOPE_NOT_FOUND", true
+	case errors.Is(err, services.ErrOAuthScopeExists):
+		return http.StatusConflict, "SCOPE_EXISTS", true
+	case errors.Is(err, services.ErrOAuthScopeInvalidName):
+		return http.StatusBadRequest, "INVALID_SCOPE_NAME", true
+	case errors.Is(err, services.ErrOAuthScopeInvalidDesc):
+		return http.StatusBadRequest, "INVALID_SCOPE_DESCRIPTION", true
+	default:
+		return 0, "", false
+	}
+}
+
+// oauthClientValidationError 将客户端参数校验错误映射为错误码
+func oauthClientValidationError(err error) (string, bool) {
+	switch {
+	case errors.Is(err, services.ErrOAuthInvalidRedirect):
+		return "INVALID_REDIRECT_URI", true
+	case errors.Is(err, services.ErrOAuthInvalidClientScope):
+		return "INVALID_CLIENT_SCOPE", true
+	case errors.Is(err, services.ErrOAuthUnknownScope):
+		return "UNKNOWN_SCOPE", true
+	case errors.Is(err, services.ErrOAuthInvalidClaimMapping):
+		return "INVALID_CLAIM_MAPPING", true
+	default:
+		return "", false
+	}
+}
+
+// grantsSCIM 判断客户端级 scope 列表是否包含 scim（与服务层规范化一致，忽略首尾空白）
+func grantsSCIM(scopes []string) bool {
+	return slices.ContainsFunc(scopes, func(scope string) bool {
+		return strings.TrimSpace(scope) == models.OAuthScopeSCIM
+	})
+}
@@ -18,10 +18,8 @@ import (
 
 // statsResponse 统计响应
 type statsResponse struct {
-	TotalUsers    int64 `json:"totalUsers"`
-	TodayNewUsers int64 `json:"todayNewUsers"`
-	AdminCount    int64 `json:"adminCount"`
-	BannedCount   int64 `json:"bannedCount"`
+	*models.UserStats
+	EmailQueue *models.EmailQueueStats `json:"emailQueue,omitempty"`
 }
 
 // logListResponse 日志列表响应
@@ -33,6 +31,12 @@ type logListResponse struct {
 	TotalPages int                      `json:"totalPages"`
 }
 
+// rateLimitsResponse 限流状态响应
+type rateLimitsResponse struct {
+	Backend  string                         `json:"backend"`
+	Limiters map[string][]models.LimitedKey `json:"limiters"`
+}
+
 // emailWhitelistListResponse 邮箱白名单列表响应
 type emailWhitelistListResponse struct {
 	Whitelist  []*models.EmailWhitelist `json:"whitelist"`
@@ -45,7 +49,7 @@ type emailWhitelistListResponse struct {
 // GetStats 获取系统统计
 // GET /admin/api/stats
 //
-// 权限：管理员
+// 权限：stats.read
 func (h *AdminHandler) GetStats(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
 	defer cancel()
@@ -56,13 +60,46 @@ func (h *AdminHandler) GetStats(c *gin.Context) {
 		return
 	}
 
-	utils.RespondSuccessWithData(c, stats)
+	resp := statsResponse{UserStats: stats}
+	if h.emailQueue != nil {
+		// 邮件队列统计失败不影响用户统计展示
+		if resp.EmailQueue, err = h.emailQueue.QueueStats(ctx); err != nil {
+			utils.LogWarn("ADMIN", "Failed to get email queue stats", "error", err)
+		}
+	}
+
+	utils.RespondSuccessWithData(c, resp)
+}
+
+// GetRateLimits 查看各限流器当前受限的 key（IP、邮箱或用户 UID）及剩余等待秒数
+// GET /admin/api/rate-limits
+//
+// 权限：ratelimits.read
+func (h *AdminHandler) GetRateLimits(c *gin.Context) {
+	if h.limiterMgr == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "RATE_LIMITER_NOT_CONFIGURED")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	limited, err := h.limiterMgr.LimitedKeys(ctx)
+	if err != nil {
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, "QUERY_FAILED", err.Error())
+		return
+	}
+
+	utils.RespondSuccessWithData(c, rateLimitsResponse{
+		Backend:  h.limiterMgr.Backend(),
+		Limiters: limited,
+	})
 }
 
 // GetLogs 获取操作日志列表
 // GET /admin/api/logs?page=1&pageSize=20
 //
-// 权限：超级管理员
+// 权限：logs.read
 func (h *AdminHandler) GetLogs(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(defaultPageSize)))
@@ -100,7 +137,7 @@ func (h *AdminHandler) GetLogs(c *gin.Context) {
 // GetEmailWhitelist 获取邮箱白名单
 // GET /admin/api/email-whitelist?page=1&pageSize=20
 //
-// 权限：仅超级管理员
+// 权限：email_whitelist.read
 func (h *AdminHandler) GetEmailWhitelist(c *gin.Context) {
 	if h.emailWhitelistRepo == nil {
 		utils.RespondError(c, http.StatusServiceUnavailable, "EMAIL_WHITELIST_NOT_CONFIGURED")
@@ -143,7 +180,7 @@ func (h *AdminHandler) GetEmailWhitelist(c *gin.Context) {
 // GetEmailWhitelistByID 获取单个邮箱白名单条目
 // GET /admin/api/email-whitelist/:id
 //
-// 权限：仅超级管理员
+// 权限：email_whitelist.read
 func (h *AdminHandler) GetEmailWhitelistByID(c *gin.Context) {
 	if h.emailWhitelistRepo == nil {
 		utils.RespondError(c, http.StatusServiceUnavailable, "EMAIL_WHITELIST_NOT_CONFIGURED")
@@ -175,7 +212,7 @@ func (h *AdminHandler) GetEmailWhitelistByID(c *gin.Context) {
 // CreateEmailWhitelist 创建邮箱白名单条目
 // POST /admin/api/email-whitelist
 //
-// 权限：仅超级管理员
+// 权限：email_whitelist.write
 func (h *AdminHandler) CreateEmailWhitelist(c *gin.Context) {
 	if h.emailWhitelistRepo == nil {
 		utils.RespondError(c, http.StatusServiceUnavailable, "EMAIL_WHITELIST_NOT_CONFIGURED")
@@ -241,7 +278,7 @@ func (h *AdminHandler) CreateEmailWhitelist(c *gin.Context) {
 // UpdateEmailWhitelist 更新邮箱白名单条目
 // PUT /admin/api/email-whitelist/:id
 //
-// 权限：仅超级管理员
+// 权限：email_whitelist.write
 func (h *AdminHandler) UpdateEmailWhitelist(c *gin.Context) {
 	if h.emailWhitelistRepo == nil {
 		utils.RespondError(c, http.StatusServiceUnavailable, "EMAIL_WHITELIST_NOT_CONFIGURED")
@@ -341,7 +378,7 @@ func (h *AdminHandler) UpdateEmailWhitelist(c *gin.Context) {
 // DeleteEmailWhitelist 删除邮箱白名单条目
 // DELETE /admin/api/email-whitelist/:id
 //
-// 权限：仅超级管理员
+// 权限：email_whitelist.write
 func (h *AdminHandler) DeleteEmailWhitelist(c *gin.Context) {
 	if h.emailWhitelistRepo == nil {
 		utils.RespondError(c, http.StatusServiceUnavailable, "EMAIL_WHITELIST_NOT_CONFIGURED")
@@ -9,6 +9,7 @@ import (
 	"auth-system/internal/middleware"
 	adminmw "auth-system/internal/middleware/admin"
 	"auth-system/internal/models"
+	"auth-system/internal/services"
 	"auth-system/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -37,7 +38,7 @@ type banUserRequest struct {
 // GetUsers 获取用户列表
 // GET /admin/api/users?page=1&pageSize=20&search=xxx
 //
-// 权限：管理员
+// 权限：users.read
 func (h *AdminHandler) GetUsers(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(defaultPageSize)))
@@ -81,7 +82,7 @@ func (h *AdminHandler) GetUsers(c *gin.Context) {
 // GetUser 获取用户详情
 // GET /admin/api/users/:uid
 //
-// 权限：管理员
+// 权限：users.read
 func (h *AdminHandler) GetUser(c *gin.Context) {
 	userUID := c.Param("uid")
 	if userUID == "" {
@@ -108,7 +109,7 @@ func (h *AdminHandler) GetUser(c *gin.Context) {
 // SetUserRole 设置用户角色
 // PUT /admin/api/users/:uid/role
 //
-// 权限：超级管理员
+// 权限：users.role
 func (h *AdminHandler) SetUserRole(c *gin.Context) {
 	operatorUID, _ := middleware.GetUID(c)
 	operatorRole, _ := adminmw.GetUserRole(c)
@@ -152,6 +153,11 @@ func (h *AdminHandler) SetUserRole(c *gin.Context) {
 		return
 	}
 
+	// 角色自带内置权限，与用户组一样只能授予或收回操作者自己拥有的权限
+	if !canManagePermissions(c, models.RolePermissions(targetUser.Role)) || !canManagePermissions(c, models.RolePermissions(req.Role)) {
+		return
+	}
+
 	if req.Role > models.RoleUser && targetUser.CheckBanned() {
 		utils.HTTPErrorResponse(c, "ADMIN", http.StatusBadRequest, "CANNOT_PROMOTE_BANNED_USER", "Attempted to promote banned user")
 		return
@@ -184,7 +190,7 @@ func (h *AdminHandler) SetUserRole(c *gin.Context) {
 // DeleteUser 删除用户
 // DELETE /admin/api/users/:uid
 //
-// 权限：超级管理员
+// 权限：users.delete
 func (h *AdminHandler) DeleteUser(c *gin.Context) {
 	operatorUID, _ := middleware.GetUID(c)
 
@@ -234,6 +240,14 @@ func (h *AdminHandler) DeleteUser(c *gin.Context) {
 		utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to log delete_user", "error", err)
 	}
 
+	// 管理员删除只记入操作日志、不经过用户日志，故在此直接发布事件
+	if h.webhookService != nil {
+		data := services.WebhookUserDeletedData{UserUID: targetUserUID, DeletedBy: services.WebhookDeletedByAdmin}
+		if err := h.webhookService.Publish(ctx, models.WebhookEventUserDeleted, data); err != nil {
+			utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to publish user deleted webhook", "error", err)
+		}
+	}
+
 	utils.LogInfoCtx(c.Request.Context(), "ADMIN", "User deleted", "operator_uid", operatorUID, "target_uid", targetUserUID, "target_username", targetUser.Username)
 
 	utils.RespondSuccess(c, gin.H{"message": "User deleted"})
@@ -242,7 +256,7 @@ func (h *AdminHandler) DeleteUser(c *gin.Context) {
 // BanUser 封禁用户
 // PATCH /admin/api/users/:uid/ban
 //
-// 权限：管理员（不能封禁管理员及以上）
+// 权限：users.ban（不能封禁管理员及以上）
 func (h *AdminHandler) BanUser(c *gin.Context) {
 	operatorUID, _ := middleware.GetUID(c)
 
@@ -331,7 +345,7 @@ func (h *AdminHandler) BanUser(c *gin.Context) {
 // UnbanUser 解封用户
 // PATCH /admin/api/users/:uid/unban
 //
-// 权限：管理员
+// 权限：users.ban
 func (h *AdminHandler) UnbanUser(c *gin.Context) {
 	operatorUID, _ := middleware.GetUID(c)
 
@@ -381,3 +395,54 @@ func (h *AdminHandler) UnbanUser(c *gin.Context) {
 
 	utils.RespondSuccess(c, gin.H{"message": "User unbanned"})
 }
+
+// ResetUserTwoFactor 重置用户两步验证（users.2fa.reset，用于用户丢失认证器与恢复码的场景）
+// POST /admin/api/users/:uid/2fa/reset
+func (h *AdminHandler) ResetUserTwoFactor(c *gin.Context) {
+	operatorUID, _ := middleware.GetUID(c)
+
+	targetUserUID := c.Param("uid")
+	if targetUserUID == "" {
+		utils.RespondError(c, http.StatusBadRequest, "INVALID_USER_UID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	targetUser, err := h.userRepo.FindByUID(ctx, targetUserUID)
+	if err != nil {
+		if utils.IsDatabaseNotFound(err) {
+			utils.RespondError(c, http.StatusNotFound, "USER_NOT_FOUND")
+			return
+		}
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, "QUERY_FAILED", err.Error())
+		return
+	}
+
+	if !targetUser.TOTPEnabled && !targetUser.TOTPSecret.Valid {
+		utils.RespondSuccess(c, gin.H{"message": "No change: two-factor authentication is not enabled"})
+		return
+	}
+
+	if err := h.userRepo.DisableTOTP(ctx, targetUserUID); err != nil {
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, "RESET_2FA_FAILED", err.Error())
+		return
+	}
+
+	h.userCache.Invalidate(targetUserUID)
+
+	if err := h.logRepo.LogReset2FA(ctx, operatorUID, targetUserUID, targetUser.Username); err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to log reset_2fa", "error", err)
+	}
+
+	if h.userLogRepo != nil {
+		if err := h.userLogRepo.LogDisable2FA(ctx, targetUserUID, operatorUID); err != nil {
+			utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to log user disable_2fa", "error", err)
+		}
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "ADMIN", "User 2FA reset", "operator_uid", operatorUID, "target_uid", targetUserUID)
+
+	utils.RespondSuccess(c, gin.H{"message": "Two-factor authentication reset"})
+}
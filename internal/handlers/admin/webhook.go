@@ -0,0 +1,348 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"auth-system/internal/middleware"
+	"auth-system/internal/models"
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// webhookRequest 创建/更新 Webhook 端点请求；IsEnabled 缺省为 true
+type webhookRequest struct {
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Events      []string `json:"events"`
+	IsEnabled   *bool    `json:"is_enabled"`
+}
+
+// webhookDeliveryListResponse 投递日志分页响应
+type webhookDeliveryListResponse struct {
+	Deliveries []*models.WebhookDelivery `json:"deliveries"`
+	Total      int64                     `json:"total"`
+	Page       int                       `json:"page"`
+	PageSize   int                       `json:"pageSize"`
+	TotalPages int                       `json:"totalPages"`
+}
+
+// GetWebhooks 获取 Webhook 端点列表及可订阅的事件清单
+// GET /admin/api/webhooks
+//
+// 权限：webhooks.read
+func (h *AdminHandler) GetWebhooks(c *gin.Context) {
+	if h.webhookService == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "WEBHOOKS_NOT_CONFIGURED")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	endpoints, err := h.webhookService.ListEndpoints(ctx)
+	if err != nil {
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, "QUERY_FAILED", err.Error())
+		return
+	}
+
+	utils.RespondSuccessWithData(c, gin.H{
+		"webhooks": endpoints,
+		"events":   models.WebhookEvents,
+	})
+}
+
+// CreateWebhook 创建 Webhook 端点，响应中的 secret 仅返回这一次
+// POST /admin/api/webhooks
+//
+// 权限：webhooks.write
+func (h *AdminHandler) CreateWebhook(c *gin.Context) {
+	if h.webhookService == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "WEBHOOKS_NOT_CONFIGURED")
+		return
+	}
+
+	var req webhookRequest
+	if !utils.BindJSONOrError(c, "ADMIN", &req, "INVALID_REQUEST") {
+		return
+	}
+
+	endpoint := req.toWebhookEndpoint()
+	operatorUID, _ := middleware.GetUID(c)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	secret, err := h.webhookService.CreateEndpoint(ctx, endpoint)
+	if err != nil {
+		respondWebhookError(c, err, "CREATE_FAILED")
+		return
+	}
+
+	if err := h.logRepo.LogWebhookCreate(ctx, operatorUID, endpoint); err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to log create webhook", "error", err)
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "ADMIN", "Webhook created", "operator_uid", operatorUID, "webhook_id", endpoint.ID, "url", endpoint.URL)
+	utils.RespondSuccessWithData(c, gin.H{"webhook": endpoint, "secret": secret})
+}
+
+// UpdateWebhook 更新 Webhook 端点的 URL、描述、订阅事件与启用状态
+// PUT /admin/api/webhooks/:id
+//
+// 权限：webhooks.write
+func (h *AdminHandler) UpdateWebhook(c *gin.Context) {
+	if h.webhookService == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "WEBHOOKS_NOT_CONFIGURED")
+		return
+	}
+
+	id, ok := parseWebhookID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req webhookRequest
+	if !utils.BindJSONOrError(c, "ADMIN", &req, "INVALID_REQUEST") {
+		return
+	}
+
+	endpoint := req.toWebhookEndpoint()
+	endpoint.ID = id
+	operatorUID, _ := middleware.GetUID(c)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	if err := h.webhookService.UpdateEndpoint(ctx, endpoint); err != nil {
+		respondWebhookError(c, err, "UPDATE_FAILED")
+		return
+	}
+
+	if err := h.logRepo.LogWebhookUpdate(ctx, operatorUID, endpoint); err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to log update webhook", "error", err)
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "ADMIN", "Webhook updated", "operator_uid", operatorUID, "webhook_id", id)
+	utils.RespondSuccessWithData(c, gin.H{"webhook": endpoint})
+}
+
+// DeleteWebhook 删除 Webhook 端点及其投递记录
+// DELETE /admin/api/webhooks/:id
+//
+// 权限：webhooks.write
+func (h *AdminHandler) DeleteWebhook(c *gin.Context) {
+	if h.webhookService == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "WEBHOOKS_NOT_CONFIGURED")
+		return
+	}
+
+	id, ok := parseWebhookID(c, "id")
+	if !ok {
+		return
+	}
+
+	operatorUID, _ := middleware.GetUID(c)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	endpoint, err := h.webhookService.DeleteEndpoint(ctx, id)
+	if err != nil {
+		respondWebhookError(c, err, "DELETE_FAILED")
+		return
+	}
+
+	if err := h.logRepo.LogWebhookDelete(ctx, operatorUID, endpoint); err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to log delete webhook", "error", err)
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "ADMIN", "Webhook deleted", "operator_uid", operatorUID, "webhook_id", id)
+	utils.RespondSuccess(c, gin.H{"message": "Webhook deleted"})
+}
+
+// RegenerateWebhookSecret 重新生成签名密钥，旧密钥立即失效
+// POST /admin/api/webhooks/:id/secret
+//
+// 权限：webhooks.write
+func (h *AdminHandler) RegenerateWebhookSecret(c *gin.Context) {
+	if h.webhookService == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "WEBHOOKS_NOT_CONFIGURED")
+		return
+	}
+
+	id, ok := parseWebhookID(c, "id")
+	if !ok {
+		return
+	}
+
+	operatorUID, _ := middleware.GetUID(c)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	endpoint, err := h.webhookService.GetEndpoint(ctx, id)
+	if err != nil {
+		respondWebhookError(c, err, "QUERY_FAILED")
+		return
+	}
+
+	secret, err := h.webhookService.RegenerateSecret(ctx, id)
+	if err != nil {
+		respondWebhookError(c, err, "REGENERATE_FAILED")
+		return
+	}
+
+	if err := h.logRepo.LogWebhookRegenerateSecret(ctx, operatorUID, endpoint); err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to log regenerate webhook secret", "error", err)
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "ADMIN", "Webhook secret regenerated", "operator_uid", operatorUID, "webhook_id", id)
+	utils.RespondSuccessWithData(c, gin.H{"secret": secret})
+}
+
+// GetWebhookDeliveries 获取端点的投递日志（新的在前）
+// GET /admin/api/webhooks/:id/deliveries?page=1&pageSize=20
+//
+// 权限：webhooks.read
+func (h *AdminHandler) GetWebhookDeliveries(c *gin.Context) {
+	if h.webhookService == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "WEBHOOKS_NOT_CONFIGURED")
+		return
+	}
+
+	id, ok := parseWebhookID(c, "id")
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(defaultPageSize)))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > maxPageSize {
+		pageSize = defaultPageSize
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	if _, err := h.webhookService.GetEndpoint(ctx, id); err != nil {
+		respondWebhookError(c, err, "QUERY_FAILED")
+		return
+	}
+
+	deliveries, total, err := h.webhookService.ListDeliveries(ctx, id, page, pageSize)
+	if err != nil {
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, "QUERY_FAILED", err.Error())
+		return
+	}
+
+	totalPages := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPages++
+	}
+
+	utils.RespondSuccessWithData(c, webhookDeliveryListResponse{
+		Deliveries: deliveries,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	})
+}
+
+// RedeliverWebhook 以原事件 ID 与 payload 重新投递，新记录进入队列由后台任务发送
+// POST /admin/api/webhooks/:id/deliveries/:deliveryId/redeliver
+//
+// 权限：webhooks.write
+func (h *AdminHandler) RedeliverWebhook(c *gin.Context) {
+	if h.webhookService == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "WEBHOOKS_NOT_CONFIGURED")
+		return
+	}
+
+	id, ok := parseWebhookID(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := parseWebhookID(c, "deliveryId")
+	if !ok {
+		return
+	}
+
+	operatorUID, _ := middleware.GetUID(c)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), adminTimeout)
+	defer cancel()
+
+	endpoint, err := h.webhookService.GetEndpoint(ctx, id)
+	if err != nil {
+		respondWebhookError(c, err, "QUERY_FAILED")
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(ctx, id, deliveryID)
+	if err != nil {
+		respondWebhookError(c, err, "REDELIVER_FAILED")
+		return
+	}
+
+	if err := h.logRepo.LogWebhookRedeliver(ctx, operatorUID, endpoint, delivery.ID); err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "ADMIN", "Failed to log redeliver webhook", "error", err)
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "ADMIN", "Webhook delivery requeued", "operator_uid", operatorUID, "webhook_id", id, "source_delivery_id", deliveryID, "delivery_id", delivery.ID)
+	utils.RespondSuccessWithData(c, gin.H{"delivery": delivery})
+}
+
+// toWebhookEndpoint 去除首尾空白并去重事件
+func (req *webhookRequest) toWebhookEndpoint() *models.WebhookEndpoint {
+	events := make([]string, 0, len(req.Events))
+	for _, event := range req.Events {
+		if event = strings.TrimSpace(event); event != "" && !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	enabled := true
+	if req.IsEnabled != nil {
+		enabled = *req.IsEnabled
+	}
+	return &models.WebhookEndpoint{
+		URL:         strings.TrimSpace(req.URL),
+		Description: strings.TrimSpace(req.Description),
+		Events:      events,
+		IsEnabled:   enabled,
+	}
+}
+
+// parseWebhookID 解析路径中的端点或投递记录 ID，失败时直接响应 400
+func parseWebhookID(c *gin.Context, param string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(param), 10, 64)
+	if err != nil || id <= 0 {
+		utils.RespondError(c, http.StatusBadRequest, "INVALID_ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// respondWebhookError 将 Webhook 错误映射为 HTTP 状态码与错误码，其余错误按 fallbackCode 返回 500
+func respondWebhookError(c *gin.Context, err error, fallbackCode string) {
+	switch {
+	case errors.Is(err, models.ErrWebhookNotFound):
+		utils.RespondError(c, http.StatusNotFound, "WEBHOOK_NOT_FOUND")
+	case errors.Is(err, models.ErrWebhookDeliveryNotFound):
+		utils.RespondError(c, http.StatusNotFound, "WEBHOOK_DELIVERY_NOT_FOUND")
+	case errors.Is(err, models.ErrWebhookDisabled):
+		utils.RespondError(c, http.StatusConflict, "WEBHOOK_DISABLED")
+	case errors.Is(err, models.ErrWebhookInvalidURL):
+		utils.RespondError(c, http.StatusBadRequest, "INVALID_WEBHOOK_URL")
+	case errors.Is(err, models.ErrWebhookInvalidDesc):
+		utils.RespondError(c, http.StatusBadRequest, "INVALID_WEBHOOK_DESCRIPTION")
+	case errors.Is(err, models.ErrWebhookInvalidEvent):
+		utils.RespondError(c, http.StatusBadRequest, "INVALID_WEBHOOK_EVENT")
+	default:
+		utils.HTTPErrorResponse(c, "ADMIN", http.StatusInternalServerError, fallbackCode, err.Error())
+	}
+}
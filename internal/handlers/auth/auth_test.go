@@ -9,7 +9,9 @@ import (
 	"testing"
 
 	"auth-system/internal/config"
+	"auth-system/internal/middleware"
 	"auth-system/internal/models"
+	"auth-system/internal/services"
 	"auth-system/internal/testutil"
 	"auth-system/internal/utils"
 
@@ -25,6 +27,10 @@ type testDeps struct {
 	whitelist   *testutil.FakeEmailWhitelist
 	limiter     *testutil.FakeLimiter
 	emailSender *testutil.FakeEmailSender
+	twoFactor   *testutil.FakeTwoFactor
+	passkeys    *testutil.FakeWebAuthnRepo
+	webauthn    *testutil.FakeWebAuthn
+	loginRisk   *testutil.FakeLoginRiskChecker
 }
 
 func newTestAuthHandler(t *testing.T, useWhitelist bool) (*AuthHandler, *testDeps) {
@@ -38,6 +44,10 @@ func newTestAuthHandler(t *testing.T, useWhitelist bool) (*AuthHandler, *testDep
 		captcha:     &testutil.FakeCaptcha{},
 		limiter:     &testutil.FakeLimiter{EmailAllowed: true},
 		emailSender: &testutil.FakeEmailSender{},
+		twoFactor:   &testutil.FakeTwoFactor{ValidCode: "123456", Step: 100},
+		passkeys:    testutil.NewFakeWebAuthnRepo(),
+		webauthn:    &testutil.FakeWebAuthn{},
+		loginRisk:   &testutil.FakeLoginRiskChecker{},
 	}
 
 	var whitelist models.EmailWhitelistStore
@@ -59,6 +69,14 @@ func newTestAuthHandler(t *testing.T, useWhitelist bool) (*AuthHandler, *testDep
 		&testutil.FakeUserCache{},
 		whitelist,
 		deps.limiter,
+		deps.userRepo,
+		deps.twoFactor,
+		deps.passkeys,
+		deps.webauthn,
+		nil,
+		deps.loginRisk,
+		services.NewLoginLockoutService(deps.userRepo, deps.emailSender, cfg.BaseURL),
+		services.NewPasswordScreenService(nil),
 	)
 	if err != nil {
 		t.Fatalf("NewAuthHandler() error = %v", err)
@@ -270,3 +288,37 @@ func TestLoginEmptyParams(t *testing.T) {
 		t.Errorf("status = %d body = %s", w.Code, w.Body.String())
 	}
 }
+
+func TestGetMeImpersonation(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	deps.userRepo.Seed(&models.User{UID: "u1", Username: "alice", Email: "alice@example.com", Role: models.RoleAdmin})
+	deps.userRepo.Seed(&models.User{UID: "root", Username: "root", Email: "root@example.com", Role: models.RoleSuperAdmin})
+
+	run := func(impersonator string) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.GET("/me", func(c *gin.Context) {
+			c.Set(middleware.ContextKeyUID, "u1")
+			if impersonator != "" {
+				c.Set(middleware.ContextKeyImpersonator, impersonator)
+			}
+			h.GetMe(c)
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/me", nil))
+		return w
+	}
+
+	w := run("")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "impersonation") {
+		t.Fatalf("regular session: status = %d body = %s", w.Code, w.Body.String())
+	}
+
+	w = run("root")
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, `"impersonator_username":"root"`) {
+		t.Fatalf("impersonation session: status = %d body = %s", w.Code, body)
+	}
+	if !strings.Contains(body, `"permissions":[]`) {
+		t.Errorf("impersonation session should not expose admin permissions: %s", body)
+	}
+}
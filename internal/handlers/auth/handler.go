@@ -44,12 +44,22 @@ type AuthHandler struct {
 	userCache          services.UserCacheStore
 	emailWhitelistRepo models.EmailWhitelistStore
 	limiterMgr         middleware.RateLimiterManager
+	twoFactorRepo      models.UserTwoFactorStore
+	twoFactorService   services.TwoFactorManager
+	webauthnRepo       models.WebAuthnCredentialStore
+	webauthnService    services.WebAuthnManager
+	permRepo           models.UserPermissionReader
+	loginRisk          services.LoginRiskChecker
+	loginLockout       services.LoginLockoutManager
+	passwordScreener   services.PasswordScreener
 	baseURL            string
 	dummyPasswordHash  string // 用于用户不存在时执行 dummy 密码验证，实现恒定时间防枚举
 }
 
 // NewAuthHandler 创建认证 Handler，验证所有必需依赖（userRepo、tokenService、sessionService、
-// emailService、captchaService、userCache）后初始化。emailWhitelistRepo、userConsentRepo 为可选参数。
+// emailService、captchaService、userCache、twoFactorRepo、twoFactorService、webauthnRepo、
+// webauthnService、passwordScreener）后初始化。
+// emailWhitelistRepo、userConsentRepo、permRepo、loginRisk、loginLockout 为可选参数。
 func NewAuthHandler(
 	cfg *config.Config,
 	userRepo models.UserReadWriter,
@@ -62,6 +72,14 @@ func NewAuthHandler(
 	userCache services.UserCacheStore,
 	emailWhitelistRepo models.EmailWhitelistStore,
 	limiterMgr middleware.RateLimiterManager,
+	twoFactorRepo models.UserTwoFactorStore,
+	twoFactorService services.TwoFactorManager,
+	webauthnRepo models.WebAuthnCredentialStore,
+	webauthnService services.WebAuthnManager,
+	permRepo models.UserPermissionReader,
+	loginRisk services.LoginRiskChecker,
+	loginLockout services.LoginLockoutManager,
+	passwordScreener services.PasswordScreener,
 ) (*AuthHandler, error) {
 	if userRepo == nil {
 		return nil, utils.LogError("AUTH", "NewAuthHandler", errors.New("userRepo is required"))
@@ -81,6 +99,22 @@ func NewAuthHandler(
 	if userCache == nil {
 		return nil, utils.LogError("AUTH", "NewAuthHandler", errors.New("userCache is required"))
 	}
+	// 两步验证依赖必须存在：缺失时已启用 2FA 的账户将无法完成登录，宁可启动失败也不降级为单因素
+	if twoFactorRepo == nil {
+		return nil, utils.LogError("AUTH", "NewAuthHandler", errors.New("twoFactorRepo is required"))
+	}
+	if twoFactorService == nil {
+		return nil, utils.LogError("AUTH", "NewAuthHandler", errors.New("twoFactorService is required"))
+	}
+	if webauthnRepo == nil {
+		return nil, utils.LogError("AUTH", "NewAuthHandler", errors.New("webauthnRepo is required"))
+	}
+	if webauthnService == nil {
+		return nil, utils.LogError("AUTH", "NewAuthHandler", errors.New("webauthnService is required"))
+	}
+	if passwordScreener == nil {
+		return nil, utils.LogError("AUTH", "NewAuthHandler", errors.New("passwordScreener is required"))
+	}
 
 	baseURL := cfg.BaseURL
 
@@ -103,6 +137,14 @@ func NewAuthHandler(
 		userCache:          userCache,
 		emailWhitelistRepo: emailWhitelistRepo,
 		limiterMgr:         limiterMgr,
+		twoFactorRepo:      twoFactorRepo,
+		twoFactorService:   twoFactorService,
+		webauthnRepo:       webauthnRepo,
+		webauthnService:    webauthnService,
+		permRepo:           permRepo,
+		loginRisk:          loginRisk,
+		loginLockout:       loginLockout,
+		passwordScreener:   passwordScreener,
 		baseURL:            baseURL,
 		dummyPasswordHash:  dummyHash,
 	}, nil
@@ -204,6 +246,10 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	if h.rejectScreenedPassword(c, req.Password, []string{usernameResult.Value, emailResult.Value}, "Password rejected by screening in Register") {
+		return
+	}
+
 	code := strings.TrimSpace(req.VerificationCode)
 	if code == "" {
 		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "MISSING_PARAMETERS", "Empty verification code in Register request")
@@ -333,22 +379,60 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// 账户处于失败延迟或锁定期：不校验密码直接拒绝，使针对该账户的猜测无法继续
+	if h.rejectThrottledLogin(c, user, clientIP) {
+		return
+	}
+
 	match, err := utils.VerifyPassword(password, user.Password)
 	if err != nil {
 		utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "INTERNAL_ERROR", "Password verification error")
 		return
 	}
 	if !match {
+		if until, locked := h.recordLoginFailure(c, user, clientIP); locked {
+			respondAccountLocked(c, until, user.UID, clientIP)
+			return
+		}
 		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "INVALID_CREDENTIALS", fmt.Sprintf("Login failed - invalid password: email=%s, userUID=%s", email, user.UID))
 		return
 	}
 
+	// 用户通过登录提醒确认过可疑登录：密码视为已泄露，须先通过邮件重置密码
+	if user.PasswordResetRequired {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusForbidden, "PASSWORD_RESET_REQUIRED", fmt.Sprintf("Login blocked until password reset: userUID=%s, ip=%s", user.UID, clientIP))
+		return
+	}
+
+	// 已启用两步验证：密码正确只换取短期挑战，令牌在 LoginTwoFactor 校验第二因素后签发
+	if user.TOTPEnabled {
+		challenge, err := h.twoFactorService.CreateChallenge(c.Request.Context(), user.UID)
+		if err != nil {
+			utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "INTERNAL_ERROR", fmt.Sprintf("Failed to create 2FA challenge: userUID=%s", user.UID))
+			return
+		}
+		utils.LogInfoCtx(c.Request.Context(), "AUTH", "Password verified, 2FA required", "user_uid", user.UID, "ip", clientIP)
+		utils.RespondSuccess(c, gin.H{
+			"message": "Two-factor authentication required",
+			"data": gin.H{
+				"twoFactorRequired": true,
+				"challenge":         challenge,
+			},
+		})
+		return
+	}
+
+	h.completeLogin(c, user, clientIP)
+}
+
+// completeLogin 签发会话令牌并写入 Cookie（密码登录、两步验证登录与 Passkey 登录共用）
+func (h *AuthHandler) completeLogin(c *gin.Context, user *models.User, clientIP string) {
 	// NOTE(Intentional): 此处未调用 user.CheckBanned() 是有意为之的设计决策。
 	// 被封禁的用户允许正常登录，以便其在 Dashboard 页面查看封禁信息与解封时间。
 	// 封禁用户的其他所有操作已在业务层（中间件/服务层）冻结，因此无需在登录阶段拦截。
 
 	isBanned := user.CheckBanned()
-	accessToken, refreshToken, err := h.sessionService.GenerateTokens(c.Request.Context(), user.UID, isBanned)
+	accessToken, refreshToken, familyID, err := h.sessionService.GenerateTokens(c.Request.Context(), user.UID, isBanned)
 	if err != nil {
 		utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "TOKEN_GENERATION_FAILED", fmt.Sprintf("Token generation failed: userUID=%s", user.UID))
 		return
@@ -357,7 +441,11 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	h.setAuthCookie(c, accessToken)
 	if !isBanned {
 		utils.SetRefreshTokenCookieGin(c, refreshToken)
+		if h.loginRisk != nil {
+			h.loginRisk.CheckLogin(c.Request.Context(), user.UID, familyID, utils.ClientInfoFrom(c.Request.Context()))
+		}
 	}
+	h.resetLoginFailures(c, user)
 	h.userCache.Set(user.UID, user)
 
 	utils.LogInfoCtx(c.Request.Context(), "AUTH", "User logged in", "username", user.Username, "user_uid", user.UID, "ip", clientIP)
@@ -400,11 +488,43 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
 		return
 	}
 
+	// 模拟登录会话进不了后台，不返回后台权限，只返回提示横幅所需的操作者信息
+	if impersonatorUID := middleware.GetImpersonator(c); impersonatorUID != "" {
+		impersonation := &meImpersonation{ImpersonatorUID: impersonatorUID}
+		if impersonator, err := h.userCache.GetOrLoad(ctx, impersonatorUID, h.userRepo.FindByUID); err == nil && impersonator != nil {
+			impersonation.ImpersonatorUsername = impersonator.Username
+		}
+		utils.RespondSuccess(c, gin.H{
+			"data": meResponse{UserPublic: user.ToPublic(), Permissions: []string{}, Impersonation: impersonation},
+		})
+		return
+	}
+
+	// 后台权限供管理界面隐藏无权操作；计算失败时降级为仅角色权限
+	perms, err := models.ResolvePermissions(ctx, user, h.permRepo)
+	if err != nil {
+		utils.LogWarnCtx(ctx, "AUTH", "GetMe: failed to load group permissions", "user_uid", userUID, "error", err)
+	}
+
 	utils.RespondSuccess(c, gin.H{
-		"data": user.ToPublic(),
+		"data": meResponse{UserPublic: user.ToPublic(), Permissions: perms},
 	})
 }
 
+// meResponse /api/auth/me 响应：公开用户信息附带有效后台权限；
+// 模拟登录会话额外返回 impersonation，前端据此显示横幅
+type meResponse struct {
+	*models.UserPublic
+	Permissions   []string         `json:"permissions"`
+	Impersonation *meImpersonation `json:"impersonation,omitempty"`
+}
+
+// meImpersonation 模拟登录的操作者
+type meImpersonation struct {
+	ImpersonatorUID      string `json:"impersonator_uid"`
+	ImpersonatorUsername string `json:"impersonator_username"`
+}
+
 // Logout 用户登出，撤销 refresh_token 并清除认证 Cookie
 // POST /api/auth/logout
 func (h *AuthHandler) Logout(c *gin.Context) {
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"auth-system/internal/models"
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// retryAfterSeconds 剩余等待时间转为秒（向上取整，避免返回 0 秒）
+func retryAfterSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// respondAccountLocked 返回账户锁定错误，retryAfter 为距锁定到期的秒数
+func respondAccountLocked(c *gin.Context, until time.Time, userUID, clientIP string) {
+	utils.LogWarnCtx(c.Request.Context(), "AUTH", "Login rejected - account locked", "user_uid", userUID, "ip", clientIP, "locked_until", until)
+	c.JSON(http.StatusForbidden, gin.H{
+		"success":    false,
+		"errorCode":  "ACCOUNT_LOCKED",
+		"retryAfter": retryAfterSeconds(time.Until(until)),
+	})
+}
+
+// rejectThrottledLogin 账户处于失败延迟或锁定期时直接拒绝本次密码登录，返回 true 表示已响应
+func (h *AuthHandler) rejectThrottledLogin(c *gin.Context, user *models.User, clientIP string) bool {
+	if h.loginLockout == nil {
+		return false
+	}
+
+	now := time.Now()
+	wait, locked := h.loginLockout.RetryAfter(user, now)
+	if wait <= 0 {
+		return false
+	}
+	if locked {
+		respondAccountLocked(c, now.Add(wait), user.UID, clientIP)
+		return true
+	}
+
+	utils.LogWarnCtx(c.Request.Context(), "AUTH", "Login rejected - retry delay not elapsed", "user_uid", user.UID, "ip", clientIP, "failures", user.FailedLoginCount)
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"success":    false,
+		"errorCode":  "LOGIN_DELAYED",
+		"retryAfter": retryAfterSeconds(wait),
+	})
+	return true
+}
+
+// recordLoginFailure 记录一次密码错误并写入 login_failed 用户日志；本次失败触发锁定时返回锁定截止时间与 true
+func (h *AuthHandler) recordLoginFailure(c *gin.Context, user *models.User, clientIP string) (time.Time, bool) {
+	ctx := c.Request.Context()
+
+	var until time.Time
+	if h.loginLockout != nil {
+		var err error
+		until, err = h.loginLockout.RecordFailure(ctx, user, clientIP)
+		if err != nil {
+			utils.LogWarnCtx(ctx, "AUTH", "Failed to record login failure", "user_uid", user.UID, "error", err)
+		}
+	}
+	locked := !until.IsZero()
+
+	if h.userLogRepo != nil {
+		if err := h.userLogRepo.LogLoginFailed(ctx, user.UID, clientIP, c.GetHeader("User-Agent"), locked); err != nil {
+			utils.LogWarnCtx(ctx, "AUTH", "Failed to log login failure", "user_uid", user.UID)
+		}
+	}
+	return until, locked
+}
+
+// resetLoginFailures 登录成功后清零失败计数（无失败记录时跳过，避免每次登录都写库）
+func (h *AuthHandler) resetLoginFailures(c *gin.Context, user *models.User) {
+	if h.loginLockout == nil || (user.FailedLoginCount == 0 && !user.LockedUntil.Valid) {
+		return
+	}
+	if err := h.loginLockout.ResetFailures(c.Request.Context(), user.UID); err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "AUTH", "Failed to reset login failures", "user_uid", user.UID, "error", err)
+		return
+	}
+	user.FailedLoginCount = 0
+	user.LastFailedLoginAt.Valid = false
+	user.LockedUntil.Valid = false
+}
+
+// UnlockAccount 账户锁定通知邮件中的解锁链接：立即解除锁定并清零失败计数
+// POST /api/auth/unlock
+func (h *AuthHandler) UnlockAccount(c *gin.Context) {
+	if h.loginLockout == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "LOGIN_LOCKOUT_DISABLED")
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+
+	if !utils.BindJSONOrError(c, "AUTH", &req, "MISSING_TOKEN") {
+		return
+	}
+
+	token := strings.TrimSpace(req.Token)
+	if token == "" {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "MISSING_TOKEN", "Empty token in UnlockAccount")
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	userUID, err := h.loginLockout.Unlock(ctx, token)
+	if errors.Is(err, models.ErrUnlockTokenNotFound) {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "INVALID_OR_EXPIRED_TOKEN", fmt.Sprintf("Unlock token not found or lock expired: ip=%s", utils.GetClientIP(c)))
+		return
+	}
+	if err != nil {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to unlock account")
+		return
+	}
+
+	h.userCache.Invalidate(userUID)
+
+	utils.LogInfoCtx(ctx, "AUTH", "Account unlocked via email link", "user_uid", userUID, "ip", utils.GetClientIP(c))
+	utils.RespondSuccess(c, gin.H{})
+}
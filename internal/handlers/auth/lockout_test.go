@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"auth-system/internal/services"
+	"auth-system/internal/utils"
+)
+
+func TestLoginWrongPasswordCountsFailure(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedUserWithPassword(deps, "uid-1", "alice@example.com", testStrongPassword)
+
+	w := postJSON(h.Login, `{"email":"alice@example.com","password":"Wrong1!@#password"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_CREDENTIALS") {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if u.FailedLoginCount != 1 || !u.LastFailedLoginAt.Valid {
+		t.Errorf("failed count = %d, last = %v; want 1 and set", u.FailedLoginCount, u.LastFailedLoginAt)
+	}
+}
+
+func TestLoginDelayedAfterRepeatedFailures(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedUserWithPassword(deps, "uid-1", "alice@example.com", testStrongPassword)
+	u.FailedLoginCount = 3
+	u.LastFailedLoginAt = sql.NullTime{Valid: true, Time: time.Now()}
+
+	// 等待期内即使密码正确也拒绝，且不计入失败次数
+	w := postJSON(h.Login, `{"email":"alice@example.com","password":"`+testStrongPassword+`"}`)
+	if w.Code != http.StatusTooManyRequests || !strings.Contains(w.Body.String(), "LOGIN_DELAYED") {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if u.FailedLoginCount != 3 {
+		t.Errorf("failed count = %d, want 3", u.FailedLoginCount)
+	}
+}
+
+func TestLoginLocksAccountAtThreshold(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedUserWithPassword(deps, "uid-1", "alice@example.com", testStrongPassword)
+	u.FailedLoginCount = 9
+	u.LastFailedLoginAt = sql.NullTime{Valid: true, Time: time.Now().Add(-2 * time.Minute)}
+
+	w := postJSON(h.Login, `{"email":"alice@example.com","password":"Wrong1!@#password"}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "ACCOUNT_LOCKED") {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if !u.IsLoginLocked(time.Now()) {
+		t.Error("account should be locked")
+	}
+	if !slices.Contains(deps.emailSender.Notices, services.EmailTypeAccountLocked+":alice@example.com") {
+		t.Errorf("notices = %v, want account_locked notice", deps.emailSender.Notices)
+	}
+
+	// 锁定期内正确密码同样被拒绝
+	w = postJSON(h.Login, `{"email":"alice@example.com","password":"`+testStrongPassword+`"}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "ACCOUNT_LOCKED") {
+		t.Errorf("locked login: status = %d body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestLoginSuccessResetsFailures(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedUserWithPassword(deps, "uid-1", "alice@example.com", testStrongPassword)
+	u.FailedLoginCount = 5
+	u.LastFailedLoginAt = sql.NullTime{Valid: true, Time: time.Now().Add(-2 * time.Minute)}
+
+	w := postJSON(h.Login, `{"email":"alice@example.com","password":"`+testStrongPassword+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body=%s)", w.Code, w.Body.String())
+	}
+	if u.FailedLoginCount != 0 || u.LastFailedLoginAt.Valid {
+		t.Errorf("failed count = %d, last = %v; want reset", u.FailedLoginCount, u.LastFailedLoginAt)
+	}
+}
+
+func TestLoginTwoFactorWrongCodeCountsFailure(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedTwoFactorUser(deps)
+	challenge, _ := deps.twoFactor.CreateChallenge(context.Background(), u.UID)
+
+	w := postJSON(h.LoginTwoFactor, `{"challenge":"`+challenge+`","code":"654321"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_TWO_FACTOR_CODE") {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if u.FailedLoginCount != 1 {
+		t.Errorf("failed count = %d, want 1", u.FailedLoginCount)
+	}
+
+	// 等待期内即使验证码正确也拒绝：换新挑战无法绕过账户级延迟
+	u.FailedLoginCount = 3
+	u.LastFailedLoginAt = sql.NullTime{Valid: true, Time: time.Now()}
+	challenge, _ = deps.twoFactor.CreateChallenge(context.Background(), u.UID)
+	w = postJSON(h.LoginTwoFactor, `{"challenge":"`+challenge+`","code":"123456"}`)
+	if w.Code != http.StatusTooManyRequests || !strings.Contains(w.Body.String(), "LOGIN_DELAYED") {
+		t.Errorf("delayed: status = %d body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestLoginTwoFactorLocksAccountAtThreshold(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedTwoFactorUser(deps)
+	u.FailedLoginCount = 9
+	u.LastFailedLoginAt = sql.NullTime{Valid: true, Time: time.Now().Add(-2 * time.Minute)}
+	challenge, _ := deps.twoFactor.CreateChallenge(context.Background(), u.UID)
+
+	w := postJSON(h.LoginTwoFactor, `{"challenge":"`+challenge+`","code":"654321"}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "ACCOUNT_LOCKED") {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if !u.IsLoginLocked(time.Now()) {
+		t.Error("account should be locked")
+	}
+	if _, ok := deps.twoFactor.Challenges[challenge]; ok {
+		t.Error("challenge should be revoked once the account is locked")
+	}
+}
+
+func TestUnlockAccountSuccess(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedUserWithPassword(deps, "uid-1", "alice@example.com", testStrongPassword)
+	u.FailedLoginCount = 10
+	if err := deps.userRepo.LockLogin(context.Background(), "uid-1", time.Now().Add(15*time.Minute), utils.HashToken("unlock-token")); err != nil {
+		t.Fatalf("LockLogin() error = %v", err)
+	}
+
+	w := postJSON(h.UnlockAccount, `{"token":"unlock-token"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body=%s)", w.Code, w.Body.String())
+	}
+	if u.IsLoginLocked(time.Now()) || u.FailedLoginCount != 0 {
+		t.Errorf("user should be unlocked with failures reset, got locked_until=%v count=%d", u.LockedUntil, u.FailedLoginCount)
+	}
+
+	// 链接一次性：再次提交视为无效
+	w = postJSON(h.UnlockAccount, `{"token":"unlock-token"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_OR_EXPIRED_TOKEN") {
+		t.Errorf("reuse: status = %d body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUnlockAccountInvalidToken(t *testing.T) {
+	h, _ := newTestAuthHandler(t, false)
+
+	w := postJSON(h.UnlockAccount, `{"token":"nope"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_OR_EXPIRED_TOKEN") {
+		t.Errorf("status = %d body = %s", w.Code, w.Body.String())
+	}
+
+	w = postJSON(h.UnlockAccount, `{}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "MISSING_TOKEN") {
+		t.Errorf("missing token: status = %d body = %s", w.Code, w.Body.String())
+	}
+}
@@ -130,6 +130,10 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
+	if h.rejectScreenedPassword(c, password, []string{user.Username, user.Email}, "Password rejected by screening in ResetPassword") {
+		return
+	}
+
 	// 原子消费重置码：并发重放同一验证码时只有一个请求能走到改密码，
 	// 消除 VerifyCode（标记已验证）与改密码之间的重放窗口
 	if err := h.tokenService.UseCode(ctx, code, normalizedEmail); err != nil {
@@ -226,6 +230,10 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
+	if h.rejectScreenedPassword(c, newPassword, []string{user.Username, user.Email}, "Password rejected by screening in ChangePassword") {
+		return
+	}
+
 	if err := h.userRepo.UpdatePassword(ctx, userUID, newPassword); err != nil {
 		utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "UPDATE_FAILED", fmt.Sprintf("Password update failed in ChangePassword: userUID=%s", userUID))
 		return
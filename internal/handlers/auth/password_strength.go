@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"net/http"
+
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rejectScreenedPassword 新密码出现在泄露库或强度不足时返回 400 及强度反馈，返回 true 表示已响应。
+// userInputs 为用户名、邮箱等个人信息，出现在密码中会降低评分
+func (h *AuthHandler) rejectScreenedPassword(c *gin.Context, password string, userInputs []string, logMessage string) bool {
+	result := h.passwordScreener.Screen(password, userInputs...)
+	errorCode := result.ErrorCode()
+	if errorCode == "" {
+		return false
+	}
+
+	utils.LogWarnCtx(c.Request.Context(), "AUTH", logMessage, "reason", errorCode, "score", result.Strength.Score)
+	c.JSON(http.StatusBadRequest, gin.H{
+		"success":   false,
+		"errorCode": errorCode,
+		"score":     result.Strength.Score,
+		"feedback":  result.Strength.Feedback,
+	})
+	return true
+}
+
+// CheckPasswordStrength 设置新密码时的实时强度提示，不保存任何内容
+// POST /api/auth/password-strength
+func (h *AuthHandler) CheckPasswordStrength(c *gin.Context) {
+	var req struct {
+		Password string `json:"password"`
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	}
+
+	if !utils.BindJSONOrError(c, "AUTH", &req, "MISSING_PARAMETERS") {
+		return
+	}
+
+	if req.Password == "" {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "MISSING_PARAMETERS", "Empty password in CheckPasswordStrength")
+		return
+	}
+	// 超长密码本就不会被接受，直接拒绝以限制估算开销
+	if result := utils.ValidatePassword(req.Password); result.ErrorCode == utils.ErrPasswordTooLong {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, result.ErrorCode, "Password too long in CheckPasswordStrength")
+		return
+	}
+
+	result := h.passwordScreener.Screen(req.Password, req.Username, req.Email)
+	utils.RespondSuccess(c, gin.H{
+		"score":      result.Strength.Score,
+		"feedback":   result.Strength.Feedback,
+		"breached":   result.Breached,
+		"acceptable": result.ErrorCode() == "",
+	})
+}
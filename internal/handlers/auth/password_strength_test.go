@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"auth-system/internal/services"
+)
+
+// breachedTestPassword 满足格式与强度要求，仅因出现在泄露库中被拒绝
+const breachedTestPassword = "Tv8#quartz-Lumen-Otter"
+
+// useBreachCorpus 让 handler 使用只含给定密码的离线泄露库
+func useBreachCorpus(t *testing.T, h *AuthHandler, passwords ...string) {
+	t.Helper()
+	var lines []string
+	for _, p := range passwords {
+		sum := sha1.Sum([]byte(p))
+		lines = append(lines, strings.ToUpper(hex.EncodeToString(sum[:]))+":1")
+	}
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	corpus, err := services.LoadPasswordBreachCorpus(path)
+	if err != nil {
+		t.Fatalf("LoadPasswordBreachCorpus() error = %v", err)
+	}
+	h.passwordScreener = services.NewPasswordScreenService(corpus)
+}
+
+func TestRegisterRejectsPasswordFromUserInputs(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+
+	w := postJSON(h.Register, `{"username":"alice","email":"alice@example.com","password":"Alice2024!Alice2024!","verificationCode":"A1b2C3"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "PASSWORD_TOO_WEAK") {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Feedback struct {
+			Warning string `json:"warning"`
+		} `json:"feedback"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Feedback.Warning == "" {
+		t.Errorf("want structured feedback, got %s", w.Body.String())
+	}
+	if len(deps.userRepo.CreatedUsers) != 0 {
+		t.Error("user should not be created")
+	}
+}
+
+func TestResetPasswordBreached(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	seedUserWithPassword(deps, "u1", "reset@test.local", testStrongPassword)
+	useBreachCorpus(t, h, breachedTestPassword)
+
+	body := `{"email":"reset@test.local","code":"123456","password":"` + breachedTestPassword + `"}`
+	w := postJSON(h.ResetPassword, body)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "PASSWORD_BREACHED") {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if len(deps.userRepo.PasswordUpdates) != 0 {
+		t.Errorf("UpdatePassword should not be called for breached password, got %v", deps.userRepo.PasswordUpdates)
+	}
+}
+
+func TestCheckPasswordStrength(t *testing.T) {
+	h, _ := newTestAuthHandler(t, false)
+	useBreachCorpus(t, h, breachedTestPassword)
+
+	tests := []struct {
+		name           string
+		body           string
+		wantAcceptable bool
+		wantBreached   bool
+	}{
+		{"strong", `{"password":"kX9#mQ2$vL7&nR4!"}`, true, false},
+		{"breached", `{"password":"` + breachedTestPassword + `"}`, false, true},
+		{"contains username", `{"password":"Alice2024!Alice2024!","username":"alice","email":"alice@example.com"}`, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postJSON(h.CheckPasswordStrength, tt.body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				Score      int  `json:"score"`
+				Breached   bool `json:"breached"`
+				Acceptable bool `json:"acceptable"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Acceptable != tt.wantAcceptable || resp.Breached != tt.wantBreached {
+				t.Errorf("got %+v, want acceptable=%v breached=%v", resp, tt.wantAcceptable, tt.wantBreached)
+			}
+		})
+	}
+
+	w := postJSON(h.CheckPasswordStrength, `{"password":""}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "MISSING_PARAMETERS") {
+		t.Errorf("empty password: status = %d body = %s", w.Code, w.Body.String())
+	}
+}
@@ -101,7 +101,7 @@ func TestResetPasswordSuccess(t *testing.T) {
 	h, deps := newTestAuthHandler(t, false)
 	u := seedUserWithPassword(deps, "u1", "reset@test.local", testStrongPassword)
 
-	body := `{"email":"reset@test.local","code":"123456","password":"NewPassword2!xabc"}`
+	body := `{"email":"reset@test.local","code":"123456","password":"` + testNewPassword + `"}`
 	w := postJSON(h.ResetPassword, body)
 	if w.Code != http.StatusOK {
 		t.Fatalf("status = %d, want 200, body = %s", w.Code, w.Body.String())
@@ -116,7 +116,7 @@ func TestResetPasswordInvalidCode(t *testing.T) {
 	seedUserWithPassword(deps, "u1", "reset@test.local", testStrongPassword)
 	deps.tokenMgr.VerifyCodeErr = models.ErrInvalidCode
 
-	body := `{"email":"reset@test.local","code":"000000","password":"NewPassword2!xabc"}`
+	body := `{"email":"reset@test.local","code":"000000","password":"` + testNewPassword + `"}`
 	w := postJSON(h.ResetPassword, body)
 	if w.Code != http.StatusBadRequest {
 		t.Fatalf("status = %d, want 400", w.Code)
@@ -163,7 +163,7 @@ func TestResetPasswordWeakPassword(t *testing.T) {
 func TestResetPasswordUserNotFound(t *testing.T) {
 	h, _ := newTestAuthHandler(t, false)
 
-	body := `{"email":"nobody@test.local","code":"123456","password":"NewPassword2!xabc"}`
+	body := `{"email":"nobody@test.local","code":"123456","password":"` + testNewPassword + `"}`
 	w := postJSON(h.ResetPassword, body)
 	if w.Code != http.StatusNotFound {
 		t.Fatalf("status = %d, want 404, body = %s", w.Code, w.Body.String())
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"auth-system/internal/models"
+	"auth-system/internal/services"
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SecureAccount 新设备登录提醒邮件中的 "不是我本人"：撤销该次登录的会话族，
+// 删除对应的已知设备，并要求用户通过邮件重置密码后才能再用密码登录
+// POST /api/auth/secure-account
+func (h *AuthHandler) SecureAccount(c *gin.Context) {
+	if h.loginRisk == nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "LOGIN_ALERTS_DISABLED")
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+
+	if !utils.BindJSONOrError(c, "AUTH", &req, "MISSING_TOKEN") {
+		return
+	}
+
+	token := strings.TrimSpace(req.Token)
+	if token == "" {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "MISSING_TOKEN", "Empty token in SecureAccount")
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	alert, err := h.loginRisk.ConsumeLoginAlert(ctx, token)
+	if errors.Is(err, models.ErrLoginAlertNotFound) {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "INVALID_OR_EXPIRED_TOKEN", fmt.Sprintf("Login alert not found or expired: ip=%s", utils.GetClientIP(c)))
+		return
+	}
+	if err != nil {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to consume login alert")
+		return
+	}
+
+	// 会话族已过期或已轮转失效时无法精确定位，退化为撤销全部会话
+	revokeAll := alert.FamilyID == ""
+	if !revokeAll {
+		err := h.sessionService.RevokeTokenFamily(ctx, alert.UserUID, alert.FamilyID)
+		if errors.Is(err, services.ErrSessionNotFound) {
+			revokeAll = true
+		} else if err != nil {
+			utils.LogWarnCtx(ctx, "AUTH", "Failed to revoke suspicious session", "user_uid", alert.UserUID, "error", err)
+			revokeAll = true
+		}
+	}
+	if revokeAll {
+		if err := h.sessionService.RevokeUserTokens(ctx, alert.UserUID); err != nil {
+			utils.LogWarnCtx(ctx, "AUTH", "Failed to revoke user tokens in SecureAccount", "user_uid", alert.UserUID)
+		}
+	}
+
+	if err := h.userRepo.Update(ctx, alert.UserUID, map[string]any{"password_reset_required": true}); err != nil {
+		utils.HTTPDatabaseError(c, "AUTH", err, "USER_NOT_FOUND")
+		return
+	}
+
+	if err := h.loginRisk.ForgetDevice(ctx, alert.UserUID, alert.DeviceID); err != nil {
+		utils.LogWarnCtx(ctx, "AUTH", "Failed to forget suspicious device", "user_uid", alert.UserUID, "device_id", alert.DeviceID)
+	}
+
+	h.userCache.Invalidate(alert.UserUID)
+
+	if h.userLogRepo != nil {
+		if err := h.userLogRepo.LogSecureAccount(ctx, alert.UserUID, alert.IP, alert.UserAgent, alert.FamilyID); err != nil {
+			utils.LogWarnCtx(ctx, "AUTH", "Failed to log secure account", "user_uid", alert.UserUID)
+		}
+	}
+
+	utils.LogInfoCtx(ctx, "AUTH", "Account secured from login alert", "user_uid", alert.UserUID, "suspicious_ip", alert.IP, "revoked_all", revokeAll)
+	utils.RespondSuccess(c, gin.H{})
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"auth-system/internal/models"
+)
+
+func TestLoginRunsLoginRiskCheck(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	seedUserWithPassword(deps, "uid-1", "alice@example.com", testStrongPassword)
+	deps.sessionMgr.FamilyID = "family-1"
+
+	w := postJSON(h.Login, `{"email":"alice@example.com","password":"`+testStrongPassword+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body=%s)", w.Code, w.Body.String())
+	}
+	if len(deps.loginRisk.Checks) != 1 {
+		t.Fatalf("CheckLogin calls = %d, want 1", len(deps.loginRisk.Checks))
+	}
+	if check := deps.loginRisk.Checks[0]; check.UserUID != "uid-1" || check.FamilyID != "family-1" {
+		t.Errorf("check = %+v, want uid-1/family-1", check)
+	}
+}
+
+func TestLoginPasswordResetRequired(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedUserWithPassword(deps, "uid-1", "alice@example.com", testStrongPassword)
+	u.PasswordResetRequired = true
+
+	w := postJSON(h.Login, `{"email":"alice@example.com","password":"`+testStrongPassword+`"}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "PASSWORD_RESET_REQUIRED") {
+		t.Errorf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("no session cookies should be set before the password is reset")
+	}
+	if len(deps.loginRisk.Checks) != 0 {
+		t.Error("CheckLogin must not run when login is refused")
+	}
+}
+
+func TestSecureAccountSuccess(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedUserWithPassword(deps, "uid-1", "alice@example.com", testStrongPassword)
+	deps.sessionMgr.Sessions = []*models.SessionToken{
+		{UserUID: "uid-1", FamilyID: "family-suspicious"},
+		{UserUID: "uid-1", FamilyID: "family-mine"},
+	}
+	deps.loginRisk.Alerts = map[string]*models.LoginAlert{
+		"alert-token": {UserUID: "uid-1", FamilyID: "family-suspicious", DeviceID: 7, IP: "203.0.113.9"},
+	}
+
+	w := postJSON(h.SecureAccount, `{"token":"alert-token"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body=%s)", w.Code, w.Body.String())
+	}
+	if !u.PasswordResetRequired {
+		t.Error("password_reset_required should be set")
+	}
+	if len(deps.sessionMgr.Sessions) != 1 || deps.sessionMgr.Sessions[0].FamilyID != "family-mine" {
+		t.Errorf("only the suspicious session should be revoked, left %+v", deps.sessionMgr.Sessions)
+	}
+	if len(deps.loginRisk.Forgotten) != 1 || deps.loginRisk.Forgotten[0] != 7 {
+		t.Errorf("forgotten devices = %v, want [7]", deps.loginRisk.Forgotten)
+	}
+
+	// 链接一次性：再次提交视为无效
+	w = postJSON(h.SecureAccount, `{"token":"alert-token"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_OR_EXPIRED_TOKEN") {
+		t.Errorf("reuse: status = %d body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSecureAccountInvalidToken(t *testing.T) {
+	h, _ := newTestAuthHandler(t, false)
+
+	w := postJSON(h.SecureAccount, `{"token":"unknown"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_OR_EXPIRED_TOKEN") {
+		t.Errorf("status = %d body = %s", w.Code, w.Body.String())
+	}
+
+	w = postJSON(h.SecureAccount, `{"token":"  "}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "MISSING_TOKEN") {
+		t.Errorf("empty: status = %d body = %s", w.Code, w.Body.String())
+	}
+}
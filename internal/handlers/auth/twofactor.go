@@ -0,0 +1,332 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"auth-system/internal/middleware"
+	"auth-system/internal/models"
+	"auth-system/internal/services"
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// verifySecondFactor 校验 TOTP 验证码或恢复码：6 位数字按 TOTP 处理，其余按恢复码处理。
+// 成功时已原子持久化（推进时间步 / 移除恢复码），同一凭据无法重放。
+func (h *AuthHandler) verifySecondFactor(ctx context.Context, user *models.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return false, nil
+	}
+
+	if utils.IsTOTPCodeFormat(code) {
+		step, ok, err := h.twoFactorService.VerifyCode(user.TOTPSecret.String, code, user.TOTPLastStep)
+		if err != nil || !ok {
+			return false, err
+		}
+		return h.twoFactorRepo.AdvanceTOTPStep(ctx, user.UID, step)
+	}
+
+	remaining, ok, err := h.twoFactorRepo.ConsumeRecoveryCode(ctx, user.UID, h.twoFactorService.HashRecoveryCode(code))
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if h.userLogRepo != nil {
+		if err := h.userLogRepo.LogUse2FARecoveryCode(ctx, user.UID, remaining); err != nil {
+			utils.LogWarnCtx(ctx, "AUTH", "Failed to log recovery code use", "user_uid", user.UID)
+		}
+	}
+	return true, nil
+}
+
+// LoginTwoFactor 两步验证登录第二步：校验挑战 + TOTP/恢复码后签发令牌
+// 验证码错误与密码错误一样计入账户登录失败次数，并受同一递增延迟与锁定约束：
+// 否则已知密码的攻击者可不断申请新挑战，仅受按 IP 限流约束地猜测验证码
+// POST /api/auth/login/2fa
+func (h *AuthHandler) LoginTwoFactor(c *gin.Context) {
+	var req struct {
+		Challenge string `json:"challenge"`
+		Code      string `json:"code"`
+	}
+
+	if !utils.BindJSONOrError(c, "AUTH", &req, "MISSING_PARAMETERS") {
+		return
+	}
+
+	if req.Challenge == "" || strings.TrimSpace(req.Code) == "" {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "MISSING_PARAMETERS", "Missing challenge or code in LoginTwoFactor")
+		return
+	}
+
+	clientIP := utils.GetClientIP(c)
+	ctx := c.Request.Context()
+
+	// 先计入尝试次数再校验：并行提交同一挑战无法绕过次数上限
+	userUID, ok := h.twoFactorService.AttemptChallenge(ctx, req.Challenge)
+	if !ok {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusUnauthorized, "TWO_FACTOR_CHALLENGE_EXPIRED", fmt.Sprintf("Invalid or expired 2FA challenge: ip=%s", clientIP))
+		return
+	}
+
+	user, err := h.userRepo.FindByUID(ctx, userUID)
+	if err != nil {
+		h.twoFactorService.ConsumeChallenge(ctx, req.Challenge)
+		utils.HTTPDatabaseError(c, "AUTH", err, "USER_NOT_FOUND")
+		return
+	}
+
+	if h.rejectThrottledLogin(c, user, clientIP) {
+		return
+	}
+
+	// 挑战签发后 2FA 被超级管理员重置：密码已验证，直接完成登录
+	if !user.TOTPEnabled {
+		h.twoFactorService.ConsumeChallenge(ctx, req.Challenge)
+		h.completeLogin(c, user, clientIP)
+		return
+	}
+
+	verified, err := h.verifySecondFactor(ctx, user, req.Code)
+	if err != nil {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "INTERNAL_ERROR", fmt.Sprintf("2FA verification error: userUID=%s, err=%v", user.UID, err))
+		return
+	}
+	if !verified {
+		if until, locked := h.recordLoginFailure(c, user, clientIP); locked {
+			h.twoFactorService.ConsumeChallenge(ctx, req.Challenge)
+			respondAccountLocked(c, until, user.UID, clientIP)
+			return
+		}
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "INVALID_TWO_FACTOR_CODE", fmt.Sprintf("Invalid 2FA code: userUID=%s, ip=%s", user.UID, clientIP))
+		return
+	}
+
+	h.twoFactorService.ConsumeChallenge(ctx, req.Challenge)
+	h.completeLogin(c, user, clientIP)
+}
+
+// SetupTwoFactor 开始绑定两步验证：生成密钥并返回绑定 URI（确认前不生效）
+// POST /api/auth/2fa/setup
+func (h *AuthHandler) SetupTwoFactor(c *gin.Context) {
+	user, ok := h.currentUser(c, "SetupTwoFactor")
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabled {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusConflict, "TWO_FACTOR_ALREADY_ENABLED", fmt.Sprintf("2FA already enabled: userUID=%s", user.UID))
+		return
+	}
+
+	if !h.twoFactorService.IsConfigured() {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusServiceUnavailable, "TWO_FACTOR_NOT_CONFIGURED", "TOTP encryption key not configured")
+		return
+	}
+
+	enrollment, err := h.twoFactorService.NewEnrollment(user.Email)
+	if err != nil {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "INTERNAL_ERROR", fmt.Sprintf("Failed to create TOTP enrollment: userUID=%s", user.UID))
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := h.twoFactorRepo.SetPendingTOTPSecret(ctx, user.UID, enrollment.EncryptedSecret); err != nil {
+		if errors.Is(err, models.ErrTOTPAlreadyEnabled) {
+			utils.HTTPErrorResponse(c, "AUTH", http.StatusConflict, "TWO_FACTOR_ALREADY_ENABLED", fmt.Sprintf("2FA already enabled: userUID=%s", user.UID))
+			return
+		}
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "UPDATE_FAILED", fmt.Sprintf("Failed to store pending TOTP secret: userUID=%s", user.UID))
+		return
+	}
+	h.userCache.Invalidate(user.UID)
+
+	utils.RespondSuccess(c, gin.H{
+		"data": gin.H{
+			"secret":     enrollment.Secret,
+			"otpauthUrl": enrollment.URI,
+		},
+	})
+}
+
+// EnableTwoFactor 确认绑定：校验认证器生成的验证码后启用 2FA，返回一次性恢复码（仅展示一次）
+// POST /api/auth/2fa/enable
+func (h *AuthHandler) EnableTwoFactor(c *gin.Context) {
+	var req struct {
+		Code string `json:"code"`
+	}
+
+	if !utils.BindJSONOrError(c, "AUTH", &req, "MISSING_PARAMETERS") {
+		return
+	}
+
+	user, ok := h.currentUser(c, "EnableTwoFactor")
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabled {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusConflict, "TWO_FACTOR_ALREADY_ENABLED", fmt.Sprintf("2FA already enabled: userUID=%s", user.UID))
+		return
+	}
+	if !user.TOTPSecret.Valid {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "TWO_FACTOR_SETUP_REQUIRED", fmt.Sprintf("EnableTwoFactor without pending secret: userUID=%s", user.UID))
+		return
+	}
+
+	step, verified, err := h.twoFactorService.VerifyCode(user.TOTPSecret.String, req.Code, 0)
+	if err != nil {
+		if errors.Is(err, services.ErrTwoFactorNotConfigured) {
+			utils.HTTPErrorResponse(c, "AUTH", http.StatusServiceUnavailable, "TWO_FACTOR_NOT_CONFIGURED", "TOTP encryption key not configured")
+			return
+		}
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "INTERNAL_ERROR", fmt.Sprintf("TOTP verification error: userUID=%s", user.UID))
+		return
+	}
+	if !verified {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "INVALID_TWO_FACTOR_CODE", fmt.Sprintf("Invalid TOTP code in EnableTwoFactor: userUID=%s", user.UID))
+		return
+	}
+
+	codes, hashes, err := h.twoFactorService.GenerateRecoveryCodes()
+	if err != nil {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "INTERNAL_ERROR", fmt.Sprintf("Failed to generate recovery codes: userUID=%s", user.UID))
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := h.twoFactorRepo.EnableTOTP(ctx, user.UID, hashes, step); err != nil {
+		if errors.Is(err, models.ErrTOTPNotPending) {
+			utils.HTTPErrorResponse(c, "AUTH", http.StatusConflict, "TWO_FACTOR_SETUP_REQUIRED", fmt.Sprintf("TOTP not pending in EnableTwoFactor: userUID=%s", user.UID))
+			return
+		}
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "UPDATE_FAILED", fmt.Sprintf("Failed to enable TOTP: userUID=%s", user.UID))
+		return
+	}
+	h.userCache.Invalidate(user.UID)
+
+	if h.userLogRepo != nil {
+		if err := h.userLogRepo.LogEnable2FA(ctx, user.UID); err != nil {
+			utils.LogWarnCtx(ctx, "AUTH", "Failed to log enable 2FA", "user_uid", user.UID)
+		}
+	}
+
+	utils.LogInfoCtx(ctx, "AUTH", "2FA enabled", "user_uid", user.UID)
+	utils.RespondSuccess(c, gin.H{
+		"data": gin.H{
+			"recoveryCodes": codes,
+		},
+	})
+}
+
+// DisableTwoFactor 关闭两步验证，需同时提供当前密码与 TOTP 验证码或恢复码
+// POST /api/auth/2fa/disable
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	var req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+
+	if !utils.BindJSONOrError(c, "AUTH", &req, "MISSING_PARAMETERS") {
+		return
+	}
+
+	user, ok := h.currentUser(c, "DisableTwoFactor")
+	if !ok {
+		return
+	}
+
+	if !user.TOTPEnabled {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "TWO_FACTOR_NOT_ENABLED", fmt.Sprintf("DisableTwoFactor while 2FA disabled: userUID=%s", user.UID))
+		return
+	}
+
+	if !h.reauthenticate(c, user, req.Password, req.Code, "DisableTwoFactor") {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if err := h.twoFactorRepo.DisableTOTP(ctx, user.UID); err != nil {
+		utils.HTTPDatabaseError(c, "AUTH", err, "USER_NOT_FOUND")
+		return
+	}
+	h.userCache.Invalidate(user.UID)
+
+	if h.userLogRepo != nil {
+		if err := h.userLogRepo.LogDisable2FA(ctx, user.UID, ""); err != nil {
+			utils.LogWarnCtx(ctx, "AUTH", "Failed to log disable 2FA", "user_uid", user.UID)
+		}
+	}
+
+	utils.LogInfoCtx(ctx, "AUTH", "2FA disabled", "user_uid", user.UID)
+	utils.RespondSuccess(c, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// reauthenticate 敏感操作前要求重新验证身份：当前密码，已启用两步验证时还需 TOTP 验证码或恢复码。
+// 密码或验证码错误均计入账户登录失败次数（与密码登录共用递增延迟与锁定），防止被盗会话暴力猜测；
+// 返回 false 表示已响应错误
+func (h *AuthHandler) reauthenticate(c *gin.Context, user *models.User, password, code, operation string) bool {
+	if password == "" || (user.TOTPEnabled && code == "") {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "MISSING_PARAMETERS", fmt.Sprintf("Missing re-authentication in %s: password=%v, code=%v", operation, password != "", code != ""))
+		return false
+	}
+
+	clientIP := utils.GetClientIP(c)
+	if h.rejectThrottledLogin(c, user, clientIP) {
+		return false
+	}
+
+	match, err := utils.VerifyPassword(password, user.Password)
+	if err != nil {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "INTERNAL_ERROR", "Password verification error in "+operation)
+		return false
+	}
+	if !match {
+		if until, locked := h.recordLoginFailure(c, user, clientIP); locked {
+			respondAccountLocked(c, until, user.UID, clientIP)
+			return false
+		}
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "WRONG_PASSWORD", fmt.Sprintf("Wrong password in %s: userUID=%s", operation, user.UID))
+		return false
+	}
+
+	if user.TOTPEnabled {
+		verified, err := h.verifySecondFactor(c.Request.Context(), user, code)
+		if err != nil {
+			utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "INTERNAL_ERROR", fmt.Sprintf("2FA verification error in %s: userUID=%s", operation, user.UID))
+			return false
+		}
+		if !verified {
+			if until, locked := h.recordLoginFailure(c, user, clientIP); locked {
+				respondAccountLocked(c, until, user.UID, clientIP)
+				return false
+			}
+			utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "INVALID_TWO_FACTOR_CODE", fmt.Sprintf("Invalid 2FA code in %s: userUID=%s", operation, user.UID))
+			return false
+		}
+	}
+
+	h.resetLoginFailures(c, user)
+	return true
+}
+
+// currentUser 读取当前登录用户（绕过缓存，确保 2FA 状态为最新）
+func (h *AuthHandler) currentUser(c *gin.Context, operation string) (*models.User, bool) {
+	userUID, ok := middleware.GetUID(c)
+	if !ok || userUID == "" {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusUnauthorized, "UNAUTHORIZED", operation+" called without valid userUID")
+		return nil, false
+	}
+
+	user, err := h.userRepo.FindByUID(c.Request.Context(), userUID)
+	if err != nil {
+		utils.HTTPDatabaseError(c, "AUTH", err, "USER_NOT_FOUND")
+		return nil, false
+	}
+	return user, true
+}
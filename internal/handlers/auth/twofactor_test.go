@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"auth-system/internal/middleware"
+	"auth-system/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// seedTwoFactorUser 预置已启用 2FA 的用户（恢复码哈希与 FakeTwoFactor 一致）
+func seedTwoFactorUser(deps *testDeps) *models.User {
+	u := seedUserWithPassword(deps, "u1", "alice@example.com", testStrongPassword)
+	u.TOTPEnabled = true
+	u.TOTPSecret = sql.NullString{Valid: true, String: "enc:FAKESECRET"}
+	u.TOTPRecoveryCodes = []string{"hash:aaaaa-bbbbb", "hash:ccccc-ddddd"}
+	return u
+}
+
+func postAuthedJSON(h gin.HandlerFunc, uid, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(middleware.ContextKeyUID, uid)
+	c.Request = httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	h(c)
+	return w
+}
+
+func TestLoginRequiresTwoFactor(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	seedTwoFactorUser(deps)
+
+	w := postJSON(h.Login, `{"email":"alice@example.com","password":"`+testStrongPassword+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body=%s)", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"twoFactorRequired":true`) || !strings.Contains(w.Body.String(), "challenge-u1") {
+		t.Errorf("response should carry a 2FA challenge, got %s", w.Body.String())
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("login should not set cookies before the second factor")
+	}
+}
+
+func TestLoginTwoFactorSuccess(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedTwoFactorUser(deps)
+	challenge, _ := deps.twoFactor.CreateChallenge(context.Background(), u.UID)
+
+	w := postJSON(h.LoginTwoFactor, `{"challenge":"`+challenge+`","code":"123456"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body=%s)", w.Code, w.Body.String())
+	}
+	if len(w.Result().Cookies()) == 0 {
+		t.Error("2FA login should set cookies")
+	}
+	if u.TOTPLastStep != deps.twoFactor.Step {
+		t.Errorf("TOTPLastStep = %d, want %d", u.TOTPLastStep, deps.twoFactor.Step)
+	}
+	if _, ok := deps.twoFactor.Challenges[challenge]; ok {
+		t.Error("challenge should be consumed after success")
+	}
+
+	// 同一时间步的验证码不可重放
+	challenge, _ = deps.twoFactor.CreateChallenge(context.Background(), u.UID)
+	w = postJSON(h.LoginTwoFactor, `{"challenge":"`+challenge+`","code":"123456"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("replayed code status = %d, want 400", w.Code)
+	}
+}
+
+func TestLoginTwoFactorInvalidCode(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedTwoFactorUser(deps)
+	challenge, _ := deps.twoFactor.CreateChallenge(context.Background(), u.UID)
+
+	w := postJSON(h.LoginTwoFactor, `{"challenge":"`+challenge+`","code":"654321"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "INVALID_TWO_FACTOR_CODE") {
+		t.Errorf("want INVALID_TWO_FACTOR_CODE, got %s", w.Body.String())
+	}
+	if len(deps.twoFactor.Attempts) != 1 {
+		t.Errorf("attempt should be counted before verification, got %v", deps.twoFactor.Attempts)
+	}
+}
+
+func TestLoginTwoFactorExpiredChallenge(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	seedTwoFactorUser(deps)
+
+	w := postJSON(h.LoginTwoFactor, `{"challenge":"unknown","code":"123456"}`)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "TWO_FACTOR_CHALLENGE_EXPIRED") {
+		t.Errorf("want TWO_FACTOR_CHALLENGE_EXPIRED, got %s", w.Body.String())
+	}
+}
+
+func TestLoginTwoFactorRecoveryCode(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedTwoFactorUser(deps)
+	challenge, _ := deps.twoFactor.CreateChallenge(context.Background(), u.UID)
+
+	w := postJSON(h.LoginTwoFactor, `{"challenge":"`+challenge+`","code":"aaaaa-bbbbb"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body=%s)", w.Code, w.Body.String())
+	}
+	if len(u.TOTPRecoveryCodes) != 1 {
+		t.Errorf("recovery code should be consumed, remaining %v", u.TOTPRecoveryCodes)
+	}
+
+	// 恢复码一次性
+	challenge, _ = deps.twoFactor.CreateChallenge(context.Background(), u.UID)
+	w = postJSON(h.LoginTwoFactor, `{"challenge":"`+challenge+`","code":"aaaaa-bbbbb"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("reused recovery code status = %d, want 400", w.Code)
+	}
+}
+
+func TestSetupAndEnableTwoFactor(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedUserWithPassword(deps, "u1", "alice@example.com", testStrongPassword)
+
+	w := postAuthedJSON(h.EnableTwoFactor, u.UID, `{"code":"123456"}`)
+	if !strings.Contains(w.Body.String(), "TWO_FACTOR_SETUP_REQUIRED") {
+		t.Fatalf("enable before setup should fail, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = postAuthedJSON(h.SetupTwoFactor, u.UID, `{}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("setup status = %d, want 200 (body=%s)", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "otpauth://") {
+		t.Errorf("setup should return otpauth URL, got %s", w.Body.String())
+	}
+
+	w = postAuthedJSON(h.EnableTwoFactor, u.UID, `{"code":"000000"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("enable with wrong code status = %d, want 400", w.Code)
+	}
+
+	w = postAuthedJSON(h.EnableTwoFactor, u.UID, `{"code":"123456"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("enable status = %d, want 200 (body=%s)", w.Code, w.Body.String())
+	}
+	if !u.TOTPEnabled || !strings.Contains(w.Body.String(), "aaaaa-bbbbb") {
+		t.Errorf("2FA should be enabled with recovery codes, got %s", w.Body.String())
+	}
+
+	w = postAuthedJSON(h.SetupTwoFactor, u.UID, `{}`)
+	if w.Code != http.StatusConflict {
+		t.Errorf("setup when enabled status = %d, want 409", w.Code)
+	}
+}
+
+func TestSetupTwoFactorNotConfigured(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedUserWithPassword(deps, "u1", "alice@example.com", testStrongPassword)
+	deps.twoFactor.NotConfigured = true
+
+	w := postAuthedJSON(h.SetupTwoFactor, u.UID, `{}`)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedTwoFactorUser(deps)
+
+	w := postAuthedJSON(h.DisableTwoFactor, u.UID, `{"code":"ccccc-ddddd"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "MISSING_PARAMETERS") {
+		t.Fatalf("disable without password status = %d (body=%s), want 400 MISSING_PARAMETERS", w.Code, w.Body.String())
+	}
+
+	w = postAuthedJSON(h.DisableTwoFactor, u.UID, `{"password":"wrong-password","code":"ccccc-ddddd"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "WRONG_PASSWORD") {
+		t.Fatalf("disable with wrong password status = %d (body=%s), want 400 WRONG_PASSWORD", w.Code, w.Body.String())
+	}
+
+	w = postAuthedJSON(h.DisableTwoFactor, u.UID, `{"password":"`+testStrongPassword+`","code":"111111"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_TWO_FACTOR_CODE") {
+		t.Fatalf("disable with wrong code status = %d (body=%s), want 400", w.Code, w.Body.String())
+	}
+	if u.FailedLoginCount != 2 {
+		t.Errorf("FailedLoginCount = %d, want 2 (wrong password and wrong code)", u.FailedLoginCount)
+	}
+
+	// 失败已达递增延迟阈值：即使凭据正确也需等待
+	u.LastFailedLoginAt = sql.NullTime{Valid: true, Time: time.Now()}
+	u.FailedLoginCount = 5
+	w = postAuthedJSON(h.DisableTwoFactor, u.UID, `{"password":"`+testStrongPassword+`","code":"ccccc-ddddd"}`)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("disable during retry delay status = %d, want 429", w.Code)
+	}
+	u.LastFailedLoginAt = sql.NullTime{}
+
+	w = postAuthedJSON(h.DisableTwoFactor, u.UID, `{"password":"`+testStrongPassword+`","code":"ccccc-ddddd"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body=%s)", w.Code, w.Body.String())
+	}
+	if u.TOTPEnabled || len(deps.userRepo.TOTPDisabled) != 1 {
+		t.Error("2FA should be disabled")
+	}
+}
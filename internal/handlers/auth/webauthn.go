@@ -0,0 +1,311 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"auth-system/internal/models"
+	"auth-system/internal/services"
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxPasskeysPerUser 单个用户可注册的 Passkey 上限
+	maxPasskeysPerUser = 10
+	// maxPasskeyNameLength Passkey 名称最大字符数（与 webauthn_credentials.name 列宽一致）
+	maxPasskeyNameLength = 64
+	defaultPasskeyName   = "Passkey"
+)
+
+// normalizePasskeyName 规范化 Passkey 名称：去除首尾空白，空名称使用默认值，超长返回 false
+func normalizePasskeyName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return defaultPasskeyName, true
+	}
+	if utf8.RuneCountInString(name) > maxPasskeyNameLength {
+		return "", false
+	}
+	return name, true
+}
+
+// respondWebAuthnError 将仪式校验错误映射为 HTTP 响应
+func respondWebAuthnError(c *gin.Context, err error, logMessage string) {
+	if errors.Is(err, services.ErrWebAuthnChallengeInvalid) {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "PASSKEY_CHALLENGE_EXPIRED", logMessage)
+		return
+	}
+	utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "PASSKEY_VERIFICATION_FAILED", fmt.Sprintf("%s: %v", logMessage, err))
+}
+
+// BeginPasskeyRegistration 开始注册 Passkey：返回 PublicKeyCredentialCreationOptions。
+// Passkey 登录不再要求两步验证，需先验证当前密码（已启用两步验证时还需验证码），避免被盗会话留下永久凭据
+// POST /api/auth/webauthn/register/begin
+func (h *AuthHandler) BeginPasskeyRegistration(c *gin.Context) {
+	var req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+
+	if !utils.BindJSONOrError(c, "AUTH", &req, "MISSING_PARAMETERS") {
+		return
+	}
+
+	user, ok := h.currentUser(c, "BeginPasskeyRegistration")
+	if !ok {
+		return
+	}
+
+	if !h.reauthenticate(c, user, req.Password, req.Code, "BeginPasskeyRegistration") {
+		return
+	}
+
+	ctx := c.Request.Context()
+	existing, err := h.webauthnRepo.ListByUser(ctx, user.UID)
+	if err != nil {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "DATABASE_ERROR", fmt.Sprintf("Failed to list passkeys: userUID=%s", user.UID))
+		return
+	}
+	if len(existing) >= maxPasskeysPerUser {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusConflict, "PASSKEY_LIMIT_REACHED", fmt.Sprintf("Passkey limit reached: userUID=%s", user.UID))
+		return
+	}
+
+	options, err := h.webauthnService.BeginRegistration(ctx, user.UID, user.Email, user.Username, existing)
+	if err != nil {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "INTERNAL_ERROR", fmt.Sprintf("Failed to begin passkey registration: userUID=%s", user.UID))
+		return
+	}
+
+	utils.RespondSuccess(c, gin.H{"data": options})
+}
+
+// FinishPasskeyRegistration 完成注册 Passkey：校验认证器返回的凭据并入库
+// POST /api/auth/webauthn/register/finish
+func (h *AuthHandler) FinishPasskeyRegistration(c *gin.Context) {
+	var req struct {
+		Name       string                                 `json:"name"`
+		Credential *services.WebAuthnRegistrationResponse `json:"credential"`
+	}
+
+	if !utils.BindJSONOrError(c, "AUTH", &req, "MISSING_PARAMETERS") {
+		return
+	}
+
+	if req.Credential == nil {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "MISSING_PARAMETERS", "Missing credential in FinishPasskeyRegistration")
+		return
+	}
+
+	name, valid := normalizePasskeyName(req.Name)
+	if !valid {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "INVALID_PASSKEY_NAME", "Passkey name too long")
+		return
+	}
+
+	user, ok := h.currentUser(c, "FinishPasskeyRegistration")
+	if !ok {
+		return
+	}
+
+	newCred, err := h.webauthnService.FinishRegistration(c.Request.Context(), user.UID, req.Credential)
+	if err != nil {
+		respondWebAuthnError(c, err, fmt.Sprintf("Passkey registration rejected: userUID=%s", user.UID))
+		return
+	}
+
+	cred := &models.WebAuthnCredential{
+		UserUID:        user.UID,
+		CredentialID:   newCred.CredentialID,
+		PublicKey:      newCred.PublicKey,
+		SignCount:      int64(newCred.SignCount),
+		AAGUID:         newCred.AAGUID,
+		Transports:     newCred.Transports,
+		BackupEligible: newCred.BackupEligible,
+		BackedUp:       newCred.BackedUp,
+		Name:           name,
+	}
+
+	ctx := c.Request.Context()
+	if err := h.webauthnRepo.Create(ctx, cred); err != nil {
+		if errors.Is(err, models.ErrWebAuthnCredentialExists) {
+			utils.HTTPErrorResponse(c, "AUTH", http.StatusConflict, "PASSKEY_ALREADY_REGISTERED", fmt.Sprintf("Passkey already registered: userUID=%s", user.UID))
+			return
+		}
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "DATABASE_ERROR", fmt.Sprintf("Failed to store passkey: userUID=%s", user.UID))
+		return
+	}
+
+	if h.userLogRepo != nil {
+		if err := h.userLogRepo.LogRegisterPasskey(ctx, user.UID, cred.ID, cred.Name); err != nil {
+			utils.LogWarnCtx(ctx, "AUTH", "Failed to log passkey registration", "user_uid", user.UID)
+		}
+	}
+
+	utils.LogInfoCtx(ctx, "AUTH", "Passkey registered", "user_uid", user.UID, "credential_id", cred.ID)
+	utils.RespondSuccess(c, gin.H{"data": cred})
+}
+
+// BeginPasskeyLogin 开始 Passkey 登录：返回 PublicKeyCredentialRequestOptions（可发现凭据）
+// POST /api/auth/webauthn/login/begin
+func (h *AuthHandler) BeginPasskeyLogin(c *gin.Context) {
+	options, err := h.webauthnService.BeginLogin(c.Request.Context())
+	if err != nil {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to begin passkey login")
+		return
+	}
+
+	utils.RespondSuccess(c, gin.H{"data": options})
+}
+
+// FinishPasskeyLogin 完成 Passkey 登录：校验断言后签发会话令牌。
+// Passkey 已强制用户验证（生物识别/PIN），本身即为多因素，因此不再要求 TOTP。
+// POST /api/auth/webauthn/login/finish
+func (h *AuthHandler) FinishPasskeyLogin(c *gin.Context) {
+	var req struct {
+		Credential *services.WebAuthnAssertionResponse `json:"credential"`
+	}
+
+	if !utils.BindJSONOrError(c, "AUTH", &req, "MISSING_PARAMETERS") {
+		return
+	}
+
+	if req.Credential == nil || req.Credential.RawID == "" {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "MISSING_PARAMETERS", "Missing credential in FinishPasskeyLogin")
+		return
+	}
+
+	clientIP := utils.GetClientIP(c)
+
+	credentialID, err := utils.DecodeWebAuthnBase64(req.Credential.RawID)
+	if err != nil || len(credentialID) == 0 {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "PASSKEY_VERIFICATION_FAILED", fmt.Sprintf("Malformed credential id in passkey login: ip=%s", clientIP))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	cred, err := h.webauthnRepo.FindByCredentialID(ctx, credentialID)
+	if err != nil {
+		if utils.IsDatabaseNotFound(err) {
+			utils.HTTPErrorResponse(c, "AUTH", http.StatusUnauthorized, "PASSKEY_NOT_FOUND", fmt.Sprintf("Unknown passkey: ip=%s", clientIP))
+			return
+		}
+		utils.HTTPDatabaseError(c, "AUTH", err, "PASSKEY_NOT_FOUND")
+		return
+	}
+
+	signCount, err := h.webauthnService.FinishLogin(ctx, req.Credential, cred)
+	if err != nil {
+		respondWebAuthnError(c, err, fmt.Sprintf("Passkey login rejected: userUID=%s, ip=%s", cred.UserUID, clientIP))
+		return
+	}
+
+	if err := h.webauthnRepo.UpdateUsage(ctx, cred.ID, int64(signCount)); err != nil {
+		utils.LogWarnCtx(ctx, "AUTH", "Failed to update passkey usage", "user_uid", cred.UserUID, "credential_id", cred.ID)
+	}
+
+	user, err := h.userRepo.FindByUID(ctx, cred.UserUID)
+	if err != nil {
+		utils.HTTPDatabaseError(c, "AUTH", err, "USER_NOT_FOUND")
+		return
+	}
+
+	h.completeLogin(c, user, clientIP)
+}
+
+// ListPasskeys 列出当前用户的 Passkey
+// GET /api/auth/webauthn/credentials
+func (h *AuthHandler) ListPasskeys(c *gin.Context) {
+	user, ok := h.currentUser(c, "ListPasskeys")
+	if !ok {
+		return
+	}
+
+	creds, err := h.webauthnRepo.ListByUser(c.Request.Context(), user.UID)
+	if err != nil {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusInternalServerError, "DATABASE_ERROR", fmt.Sprintf("Failed to list passkeys: userUID=%s", user.UID))
+		return
+	}
+
+	utils.RespondSuccess(c, gin.H{"data": gin.H{"credentials": creds}})
+}
+
+// RenamePasskey 重命名当前用户的 Passkey
+// PATCH /api/auth/webauthn/credentials/:id
+func (h *AuthHandler) RenamePasskey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "INVALID_REQUEST", "Invalid passkey id in RenamePasskey")
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+
+	if !utils.BindJSONOrError(c, "AUTH", &req, "MISSING_PARAMETERS") {
+		return
+	}
+
+	name, valid := normalizePasskeyName(req.Name)
+	if !valid {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "INVALID_PASSKEY_NAME", "Passkey name too long")
+		return
+	}
+
+	user, ok := h.currentUser(c, "RenamePasskey")
+	if !ok {
+		return
+	}
+
+	if err := h.webauthnRepo.Rename(c.Request.Context(), user.UID, id, name); err != nil {
+		utils.HTTPDatabaseError(c, "AUTH", err, "PASSKEY_NOT_FOUND")
+		return
+	}
+
+	utils.RespondSuccess(c, gin.H{"message": "Passkey renamed"})
+}
+
+// DeletePasskey 删除当前用户的 Passkey
+// DELETE /api/auth/webauthn/credentials/:id
+func (h *AuthHandler) DeletePasskey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		utils.HTTPErrorResponse(c, "AUTH", http.StatusBadRequest, "INVALID_REQUEST", "Invalid passkey id in DeletePasskey")
+		return
+	}
+
+	user, ok := h.currentUser(c, "DeletePasskey")
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	cred, err := h.webauthnRepo.FindByID(ctx, user.UID, id)
+	if err != nil {
+		utils.HTTPDatabaseError(c, "AUTH", err, "PASSKEY_NOT_FOUND")
+		return
+	}
+
+	if err := h.webauthnRepo.Delete(ctx, user.UID, id); err != nil {
+		utils.HTTPDatabaseError(c, "AUTH", err, "PASSKEY_NOT_FOUND")
+		return
+	}
+
+	if h.userLogRepo != nil {
+		if err := h.userLogRepo.LogDeletePasskey(ctx, user.UID, cred.ID, cred.Name); err != nil {
+			utils.LogWarnCtx(ctx, "AUTH", "Failed to log passkey deletion", "user_uid", user.UID)
+		}
+	}
+
+	utils.LogInfoCtx(ctx, "AUTH", "Passkey deleted", "user_uid", user.UID, "credential_id", cred.ID)
+	utils.RespondSuccess(c, gin.H{"message": "Passkey deleted"})
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"auth-system/internal/middleware"
+	"auth-system/internal/models"
+	"auth-system/internal/services"
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// seedPasskey 为用户预置一个 Passkey（凭据 ID 为 credID 的字节）
+func seedPasskey(t *testing.T, deps *testDeps, userUID, credID string) *models.WebAuthnCredential {
+	t.Helper()
+	cred := &models.WebAuthnCredential{UserUID: userUID, CredentialID: []byte(credID), Name: "Laptop"}
+	if err := deps.passkeys.Create(t.Context(), cred); err != nil {
+		t.Fatalf("seed passkey: %v", err)
+	}
+	return cred
+}
+
+func authedRequest(h gin.HandlerFunc, method, path, route, uid, body string) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.Handle(method, route, func(c *gin.Context) {
+		c.Set(middleware.ContextKeyUID, uid)
+		h(c)
+	})
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestFinishPasskeyRegistration(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedUserWithPassword(deps, "u1", "alice@example.com", testStrongPassword)
+	deps.webauthn.NewCredential = &services.WebAuthnNewCredential{CredentialID: []byte("cred-1"), PublicKey: []byte("key")}
+
+	w := postAuthedJSON(h.FinishPasskeyRegistration, u.UID, `{"name":"  YubiKey  ","credential":{"id":"x"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body=%s)", w.Code, w.Body.String())
+	}
+	creds, _ := deps.passkeys.ListByUser(t.Context(), u.UID)
+	if len(creds) != 1 || creds[0].Name != "YubiKey" {
+		t.Fatalf("stored passkeys = %+v, want one named YubiKey", creds)
+	}
+
+	// 同一认证器重复注册
+	w = postAuthedJSON(h.FinishPasskeyRegistration, u.UID, `{"credential":{"id":"x"}}`)
+	if w.Code != http.StatusConflict {
+		t.Errorf("duplicate status = %d, want 409", w.Code)
+	}
+
+	deps.webauthn.FinishErr = services.ErrWebAuthnChallengeInvalid
+	w = postAuthedJSON(h.FinishPasskeyRegistration, u.UID, `{"credential":{"id":"x"}}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "PASSKEY_CHALLENGE_EXPIRED") {
+		t.Errorf("expired challenge = %d %s, want 400 PASSKEY_CHALLENGE_EXPIRED", w.Code, w.Body.String())
+	}
+}
+
+func TestBeginPasskeyRegistrationLimit(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedUserWithPassword(deps, "u1", "alice@example.com", testStrongPassword)
+	for i := range maxPasskeysPerUser {
+		seedPasskey(t, deps, u.UID, "cred-"+string(rune('a'+i)))
+	}
+
+	w := postAuthedJSON(h.BeginPasskeyRegistration, u.UID, `{"password":"`+testStrongPassword+`"}`)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "PASSKEY_LIMIT_REACHED") {
+		t.Errorf("status = %d %s, want 409 PASSKEY_LIMIT_REACHED", w.Code, w.Body.String())
+	}
+}
+
+func TestBeginPasskeyRegistrationReauth(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedTwoFactorUser(deps)
+
+	w := postAuthedJSON(h.BeginPasskeyRegistration, u.UID, `{}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "MISSING_PARAMETERS") {
+		t.Errorf("no password = %d %s, want 400 MISSING_PARAMETERS", w.Code, w.Body.String())
+	}
+	// 已启用两步验证：仅凭密码不够
+	w = postAuthedJSON(h.BeginPasskeyRegistration, u.UID, `{"password":"`+testStrongPassword+`"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "MISSING_PARAMETERS") {
+		t.Errorf("no code = %d %s, want 400 MISSING_PARAMETERS", w.Code, w.Body.String())
+	}
+	w = postAuthedJSON(h.BeginPasskeyRegistration, u.UID, `{"password":"wrong-password","code":"ccccc-ddddd"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "WRONG_PASSWORD") {
+		t.Errorf("wrong password = %d %s, want 400 WRONG_PASSWORD", w.Code, w.Body.String())
+	}
+	if u.FailedLoginCount != 1 {
+		t.Errorf("FailedLoginCount = %d, want 1", u.FailedLoginCount)
+	}
+
+	w = postAuthedJSON(h.BeginPasskeyRegistration, u.UID, `{"password":"`+testStrongPassword+`","code":"ccccc-ddddd"}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d %s, want 200", w.Code, w.Body.String())
+	}
+}
+
+func TestFinishPasskeyLogin(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedTwoFactorUser(deps)
+	cred := seedPasskey(t, deps, u.UID, "cred-1")
+	deps.webauthn.SignCount = 7
+
+	body := `{"credential":{"rawId":"` + utils.EncodeWebAuthnBase64([]byte("cred-1")) + `"}}`
+	w := postJSON(h.FinishPasskeyLogin, body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body=%s)", w.Code, w.Body.String())
+	}
+	// Passkey 已包含用户验证，不再触发 TOTP 挑战
+	if len(w.Result().Cookies()) == 0 || strings.Contains(w.Body.String(), "twoFactorRequired") {
+		t.Errorf("passkey login should issue session directly, got %s", w.Body.String())
+	}
+	if cred.SignCount != 7 || cred.LastUsedAt == nil {
+		t.Errorf("usage not updated: sign_count=%d last_used_at=%v", cred.SignCount, cred.LastUsedAt)
+	}
+
+	w = postJSON(h.FinishPasskeyLogin, `{"credential":{"rawId":"`+utils.EncodeWebAuthnBase64([]byte("unknown"))+`"}}`)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unknown credential status = %d, want 401", w.Code)
+	}
+
+	deps.webauthn.FinishErr = utils.ErrWebAuthnInvalidSignature
+	w = postJSON(h.FinishPasskeyLogin, body)
+	if w.Code != http.StatusBadRequest || len(w.Result().Cookies()) != 0 {
+		t.Errorf("invalid signature = %d (cookies=%d), want 400 without cookies", w.Code, len(w.Result().Cookies()))
+	}
+}
+
+func TestRenameAndDeletePasskey(t *testing.T) {
+	h, deps := newTestAuthHandler(t, false)
+	u := seedUserWithPassword(deps, "u1", "alice@example.com", testStrongPassword)
+	seedUserWithPassword(deps, "u2", "bob@example.com", testStrongPassword)
+	cred := seedPasskey(t, deps, u.UID, "cred-1")
+
+	w := authedRequest(h.RenamePasskey, http.MethodPatch, "/credentials/1", "/credentials/:id", u.UID, `{"name":"Phone"}`)
+	if w.Code != http.StatusOK || cred.Name != "Phone" {
+		t.Fatalf("rename = %d, name=%s", w.Code, cred.Name)
+	}
+
+	w = authedRequest(h.RenamePasskey, http.MethodPatch, "/credentials/1", "/credentials/:id", u.UID, `{"name":"`+strings.Repeat("x", maxPasskeyNameLength+1)+`"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("long name status = %d, want 400", w.Code)
+	}
+
+	// 不能删除他人的 Passkey
+	w = authedRequest(h.DeletePasskey, http.MethodDelete, "/credentials/1", "/credentials/:id", "u2", "")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("foreign delete status = %d, want 404", w.Code)
+	}
+
+	w = authedRequest(h.DeletePasskey, http.MethodDelete, "/credentials/1", "/credentials/:id", u.UID, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete status = %d (body=%s)", w.Code, w.Body.String())
+	}
+	if creds, _ := deps.passkeys.ListByUser(t.Context(), u.UID); len(creds) != 0 {
+		t.Errorf("passkey should be deleted, got %d", len(creds))
+	}
+}
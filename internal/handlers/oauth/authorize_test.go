@@ -1,11 +1,13 @@
 package oauth
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"auth-system/internal/middleware"
 	"auth-system/internal/models"
@@ -29,12 +31,13 @@ func getQuery(h gin.HandlerFunc, urlStr string) *httptest.ResponseRecorder {
 
 func seedOAuthClient(deps *providerTestDeps) {
 	deps.oauth.Client = &models.OAuthClient{
-		ID:          1,
-		ClientID:    "client-1",
-		Name:        "Test App",
-		Description: "desc",
-		RedirectURI: "https://app.example.com/cb",
-		IsEnabled:   true,
+		ID:            1,
+		ClientID:      "client-1",
+		Name:          "Test App",
+		Description:   "desc",
+		RedirectURIs:  []string{"https://app.example.com/cb"},
+		AllowedScopes: []string{"openid", "profile", "email", "files:read"},
+		IsEnabled:     true,
 	}
 }
 
@@ -155,6 +158,23 @@ func TestAuthorizeBannedUser(t *testing.T) {
 	}
 }
 
+func TestAuthorizeScopeOutsideWhitelist(t *testing.T) {
+	h, deps := newTestProvider(t)
+	seedOAuthClient(deps)
+	deps.oauth.Client.AllowedScopes = []string{"openid"}
+
+	// profile 不在客户端白名单内，过滤后为空
+	w := getQuery(h.Authorize, "/oauth/authorize?client_id=client-1&redirect_uri="+
+		url.QueryEscape("https://app.example.com/cb")+"&response_type=code&scope=profile%20files:write&state=xyz&code_challenge="+
+		validChallenge+"&code_challenge_method=plain")
+	if w.Code != http.StatusFound {
+		t.Fatalf("status = %d, want 302", w.Code)
+	}
+	if !strings.Contains(w.Header().Get("Location"), "error=invalid_scope") {
+		t.Errorf("want invalid_scope, got %s", w.Header().Get("Location"))
+	}
+}
+
 // ---------- AuthorizeInfo ----------
 
 func TestAuthorizeInfoMissingParams(t *testing.T) {
@@ -203,8 +223,46 @@ func TestAuthorizeInfoSuccess(t *testing.T) {
 	}
 }
 
+func TestAuthorizeInfoCustomScopeDescriptions(t *testing.T) {
+	h, deps := newTestProvider(t)
+	seedOAuthClient(deps)
+	deps.userRepo.Seed(&models.User{UID: "u1", Username: "alice", Email: "alice@example.com"})
+	deps.oauth.Scopes = []*models.OAuthScope{
+		{ID: 1, Name: "files:read", Descriptions: map[string]string{"en": "Read your files", "zh-CN": "读取你的文件"}},
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(middleware.ContextKeyUID, "u1")
+	c.Request = httptest.NewRequest(http.MethodGet,
+		"/oauth/authorize/info?client_id=client-1&redirect_uri="+url.QueryEscape("https://app.example.com/cb")+"&scope=openid%20files:read%20files:write", nil)
+	h.AuthorizeInfo(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200, body = %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data struct {
+			Scopes            []string                     `json:"scopes"`
+			ScopeDescriptions map[string]map[string]string `json:"scopeDescriptions"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if strings.Join(resp.Data.Scopes, " ") != "openid files:read" {
+		t.Errorf("scopes = %v, want [openid files:read]", resp.Data.Scopes)
+	}
+	if resp.Data.ScopeDescriptions["files:read"]["en"] != "Read your files" {
+		t.Errorf("scopeDescriptions = %v, want files:read descriptions", resp.Data.ScopeDescriptions)
+	}
+}
+
 // ---------- AuthorizePost ----------
 
+// testLoginTime 已登录请求的会话登录时间（AuthMiddleware 取自 access_token 的 auth_time）
+var testLoginTime = time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
 func authorizePost(h *OAuthProviderHandler, deps *providerTestDeps, t *testing.T, decision string, loggedIn bool) *httptest.ResponseRecorder {
 	t.Helper()
 	seedOAuthClient(deps)
@@ -216,6 +274,7 @@ func authorizePost(h *OAuthProviderHandler, deps *providerTestDeps, t *testing.T
 	r.POST("/test", func(c *gin.Context) {
 		if loggedIn {
 			c.Set(middleware.ContextKeyUID, "u1")
+			c.Set(middleware.ContextKeyAuthTime, testLoginTime)
 		}
 		h.AuthorizePost(c)
 	})
@@ -246,6 +305,10 @@ func TestAuthorizePostSuccess(t *testing.T) {
 	if !strings.Contains(loc, "code=auth-code") || !strings.Contains(loc, "state=xyz") {
 		t.Errorf("want redirect_uri with code+state, got %s", loc)
 	}
+	// 授权码记录会话登录时间，而非授权确认时刻
+	if !deps.oauth.CodeAuthTime.Equal(testLoginTime) {
+		t.Errorf("code auth_time = %v, want %v", deps.oauth.CodeAuthTime, testLoginTime)
+	}
 }
 
 func TestAuthorizePostDeny(t *testing.T) {
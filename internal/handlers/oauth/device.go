@@ -0,0 +1,222 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"auth-system/internal/middleware"
+	"auth-system/internal/models"
+	"auth-system/internal/paths"
+	"auth-system/internal/services"
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// grantTypeDeviceCode 设备授权 grant_type（RFC 8628 3.4）
+const grantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+// deviceDecisionRequest 用户对设备授权请求的决定
+type deviceDecisionRequest struct {
+	UserCode string `json:"user_code" binding:"required,max=32"`
+	Decision string `json:"decision" binding:"required,oneof=approve deny"`
+}
+
+// DeviceAuthorization 设备授权端点（RFC 8628 3.1），供 CLI、电视等输入受限设备发起授权
+// 客户端以 client_id + client_secret 认证，返回 device_code 与供用户输入的 user_code
+// POST /oauth/device_authorization
+func (h *OAuthProviderHandler) DeviceAuthorization(c *gin.Context) {
+	clientID := c.PostForm("client_id")
+	clientSecret := c.PostForm("client_secret")
+
+	if clientID == "" || clientSecret == "" {
+		h.respondTokenError(c, http.StatusUnauthorized, "invalid_client", "Missing client credentials")
+		return
+	}
+
+	client, err := h.oauthService.ValidateClient(c.Request.Context(), clientID, clientSecret)
+	if err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "OAUTH-PROVIDER", "Client validation failed for device authorization", "client_id", clientID)
+		h.respondTokenError(c, http.StatusUnauthorized, "invalid_client", "Invalid client credentials")
+		return
+	}
+
+	scope := c.PostForm("scope")
+	if scope == "" {
+		h.respondTokenError(c, http.StatusBadRequest, "invalid_scope", "Missing scope parameter")
+		return
+	}
+	normalizedScope := h.normalizeScope(client, scope)
+	if normalizedScope == "" {
+		h.respondTokenError(c, http.StatusBadRequest, "invalid_scope", "Invalid scope")
+		return
+	}
+
+	auth, err := h.oauthService.CreateDeviceAuthorization(c.Request.Context(), clientID, normalizedScope)
+	if err != nil {
+		utils.LogErrorCtx(c.Request.Context(), "OAUTH-PROVIDER", "DeviceAuthorization", err, "client_id", clientID)
+		h.respondTokenError(c, http.StatusInternalServerError, "server_error", "Failed to create device authorization")
+		return
+	}
+
+	verificationURI := h.baseURL + paths.PathAccountDevice
+	c.JSON(http.StatusOK, gin.H{
+		"device_code":               auth.DeviceCode,
+		"user_code":                 auth.UserCode,
+		"verification_uri":          verificationURI,
+		"verification_uri_complete": verificationURI + "?user_code=" + url.QueryEscape(auth.UserCode),
+		"expires_in":                auth.ExpiresIn,
+		"interval":                  auth.Interval,
+	})
+}
+
+// DeviceInfo 按 user_code 获取设备授权请求的展示信息（客户端、scope、当前用户）
+// GET /oauth/device/info?user_code=
+func (h *OAuthProviderHandler) DeviceInfo(c *gin.Context) {
+	userUID, ok := middleware.GetUID(c)
+	if !ok || userUID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "errorCode": "unauthorized"})
+		return
+	}
+
+	dc, client, ok := h.lookupDeviceAuthorization(c, c.Query("user_code"))
+	if !ok {
+		return
+	}
+
+	user, err := h.userCache.GetOrLoad(c.Request.Context(), userUID, h.userRepo.FindByUID)
+	if err != nil {
+		utils.LogErrorCtx(c.Request.Context(), "OAUTH-PROVIDER", "DeviceInfo", err, "user_uid", userUID)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "errorCode": "server_error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.buildConsentInfo(c, client, user, dc.Scope),
+	})
+}
+
+// DeviceApprove 用户确认或拒绝设备授权请求（设备授权页与手机扫码确认共用）
+// POST /oauth/device
+func (h *OAuthProviderHandler) DeviceApprove(c *gin.Context) {
+	userUID, ok := middleware.GetUID(c)
+	if !ok || userUID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "errorCode": "unauthorized"})
+		return
+	}
+
+	var req deviceDecisionRequest
+	if !utils.BindJSONOrError(c, "OAUTH-PROVIDER", &req, "invalid_request") {
+		return
+	}
+
+	_, client, ok := h.lookupDeviceAuthorization(c, req.UserCode)
+	if !ok {
+		return
+	}
+
+	approve := req.Decision == "approve"
+	dc, err := h.oauthService.DecideDeviceAuthorization(c.Request.Context(), req.UserCode, userUID, approve)
+	if err != nil {
+		h.respondDeviceLookupError(c, err)
+		return
+	}
+
+	if !approve {
+		utils.LogInfoCtx(c.Request.Context(), "OAUTH-PROVIDER", "User denied device authorization", "user_uid", userUID, "client_id", dc.ClientID)
+		utils.RespondSuccess(c, gin.H{})
+		return
+	}
+
+	if h.userLogRepo != nil {
+		if err := h.userLogRepo.LogOAuthAuthorize(c.Request.Context(), userUID, dc.ClientID, client.Name, dc.Scope); err != nil {
+			utils.LogWarnCtx(c.Request.Context(), "OAUTH-PROVIDER", "Failed to log OAuth authorize", "user_uid", userUID)
+		}
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "OAUTH-PROVIDER", "Device authorization granted", "user_uid", userUID, "client_id", dc.ClientID)
+	utils.RespondSuccess(c, gin.H{})
+}
+
+// lookupDeviceAuthorization 查询待确认的设备授权请求及其客户端，失败时已写入响应
+func (h *OAuthProviderHandler) lookupDeviceAuthorization(c *gin.Context, userCode string) (*models.OAuthDeviceCode, *models.OAuthClient, bool) {
+	if userCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "errorCode": "invalid_request"})
+		return nil, nil, false
+	}
+
+	dc, err := h.oauthService.GetDeviceAuthorization(c.Request.Context(), userCode)
+	if err != nil {
+		h.respondDeviceLookupError(c, err)
+		return nil, nil, false
+	}
+
+	// 客户端在请求发起后被禁用或删除时不再允许授权
+	client, err := h.oauthService.ValidateClientID(c.Request.Context(), dc.ClientID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "errorCode": "invalid_client"})
+		return nil, nil, false
+	}
+
+	return dc, client, true
+}
+
+// respondDeviceLookupError user_code 无效、过期或已处理时统一返回 invalid_user_code
+func (h *OAuthProviderHandler) respondDeviceLookupError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrOAuthUserCodeInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "errorCode": "invalid_user_code"})
+		return
+	}
+	utils.LogErrorCtx(c.Request.Context(), "OAUTH-PROVIDER", "DeviceAuthorizationLookup", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"success": false, "errorCode": "server_error"})
+}
+
+// handleDeviceCodeGrant 处理设备端轮询换取 Token（RFC 8628 3.4/3.5）
+func (h *OAuthProviderHandler) handleDeviceCodeGrant(c *gin.Context, client *models.OAuthClient) {
+	clientID := client.ClientID
+	deviceCode := c.PostForm("device_code")
+	if deviceCode == "" {
+		h.respondTokenError(c, http.StatusBadRequest, "invalid_request", "Missing device_code parameter")
+		return
+	}
+
+	tokenResp, userUID, err := h.oauthService.ExchangeDeviceCode(c.Request.Context(), deviceCode, clientID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrOAuthAuthorizationPending):
+			h.respondTokenError(c, http.StatusBadRequest, "authorization_pending", "The user has not yet completed authorization")
+		case errors.Is(err, services.ErrOAuthSlowDown):
+			h.respondTokenError(c, http.StatusBadRequest, "slow_down", "Polling too frequently, increase the interval")
+		case errors.Is(err, services.ErrOAuthAccessDenied):
+			h.respondTokenError(c, http.StatusBadRequest, "access_denied", "The user denied the authorization request")
+		case errors.Is(err, services.ErrOAuthExpiredToken):
+			h.respondTokenError(c, http.StatusBadRequest, "expired_token", "The device code has expired")
+		default:
+			utils.LogWarnCtx(c.Request.Context(), "OAUTH-PROVIDER", "Device code exchange failed", "client_id", clientID, "error", err)
+			h.respondTokenError(c, http.StatusBadRequest, "invalid_grant", "Invalid device code")
+		}
+		return
+	}
+
+	user, err := h.userCache.GetOrLoad(c.Request.Context(), userUID, h.userRepo.FindByUID)
+	if err != nil || user.CheckBanned() {
+		utils.LogWarnCtx(c.Request.Context(), "OAUTH-PROVIDER", "User banned or not found during device code exchange", "user_uid", userUID)
+		h.respondTokenError(c, http.StatusBadRequest, "invalid_grant", "User is banned or not found")
+		return
+	}
+
+	if hasScope(tokenResp.Scope, ScopeOpenID) {
+		idToken, err := h.issueIDToken(c.Request.Context(), user, client, tokenResp)
+		if err != nil {
+			utils.LogErrorCtx(c.Request.Context(), "OAUTH-PROVIDER", "handleDeviceCodeGrant", err, "client_id", clientID, "user_uid", userUID)
+			h.respondTokenError(c, http.StatusInternalServerError, "server_error", "Failed to issue id_token")
+			return
+		}
+		tokenResp.IDToken = idToken
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "OAUTH-PROVIDER", "Token issued via device code", "client_id", clientID, "user_uid", userUID)
+	c.JSON(http.StatusOK, tokenResp)
+}
@@ -0,0 +1,201 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"auth-system/internal/middleware"
+	"auth-system/internal/models"
+	"auth-system/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func seedDeviceCode(deps *providerTestDeps) {
+	seedOAuthClient(deps)
+	deps.oauth.DeviceCode = &models.OAuthDeviceCode{
+		ID:        1,
+		ClientID:  "client-1",
+		Scope:     "openid files:read",
+		Status:    models.QRStatusPending,
+		ExpiresAt: time.Now().Add(10 * time.Minute),
+	}
+	deps.userRepo.Seed(&models.User{UID: "u1", Username: "alice", Email: "alice@example.com"})
+}
+
+// postDeviceDecision 以登录用户身份提交设备授权决定
+func postDeviceDecision(h *OAuthProviderHandler, body string) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.POST("/oauth/device", func(c *gin.Context) {
+		c.Set(middleware.ContextKeyUID, "u1")
+		h.DeviceApprove(c)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/oauth/device", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestDeviceAuthorization(t *testing.T) {
+	h, deps := newTestProvider(t)
+	seedOAuthClient(deps)
+
+	w := postForm(h.DeviceAuthorization, url.Values{
+		"client_id":     {"client-1"},
+		"client_secret": {"secret-1"},
+		"scope":         {"openid profile"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp["verification_uri"] != "https://test.local/account/device" ||
+		resp["verification_uri_complete"] != "https://test.local/account/device?user_code=BCDF-GHJK" {
+		t.Errorf("verification uris = %v / %v", resp["verification_uri"], resp["verification_uri_complete"])
+	}
+	if resp["device_code"] != "device-code" || resp["interval"] != float64(5) {
+		t.Errorf("response = %v", resp)
+	}
+
+	w = postForm(h.DeviceAuthorization, url.Values{
+		"client_id":     {"client-1"},
+		"client_secret": {"secret-1"},
+		"scope":         {"admin"},
+	})
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_scope") {
+		t.Errorf("scope outside whitelist = %d %s, want 400 invalid_scope", w.Code, w.Body.String())
+	}
+
+	deps.oauth.ValidateErr = errTestInvalidClient
+	w = postForm(h.DeviceAuthorization, url.Values{
+		"client_id":     {"client-1"},
+		"client_secret": {"wrong"},
+		"scope":         {"openid"},
+	})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("invalid client status = %d, want 401", w.Code)
+	}
+}
+
+func TestTokenDeviceCodeGrantErrors(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{services.ErrOAuthAuthorizationPending, "authorization_pending"},
+		{services.ErrOAuthSlowDown, "slow_down"},
+		{services.ErrOAuthAccessDenied, "access_denied"},
+		{services.ErrOAuthExpiredToken, "expired_token"},
+		{services.ErrOAuthInvalidGrant, "invalid_grant"},
+	}
+	for _, tc := range cases {
+		h, deps := newTestProvider(t)
+		deps.oauth.DeviceExchangeErr = tc.err
+
+		w := postForm(h.Token, url.Values{
+			"grant_type":    {grantTypeDeviceCode},
+			"client_id":     {"client-1"},
+			"client_secret": {"secret-1"},
+			"device_code":   {"device-code"},
+		})
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"error":"`+tc.want+`"`) {
+			t.Errorf("%v: status = %d body = %s, want 400 %s", tc.err, w.Code, w.Body.String(), tc.want)
+		}
+	}
+}
+
+func TestTokenDeviceCodeGrant(t *testing.T) {
+	h, deps := newTestProvider(t)
+	deps.oauth.DeviceResp = tokenResp()
+	deps.oauth.DeviceUserUID = "uid-1"
+	deps.userRepo.Seed(&models.User{UID: "uid-1", Username: "alice", Email: "a@b.com"})
+
+	form := url.Values{
+		"grant_type":    {grantTypeDeviceCode},
+		"client_id":     {"client-1"},
+		"client_secret": {"secret-1"},
+		"device_code":   {"device-code"},
+	}
+	w := postForm(h.Token, form)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"id_token":"fake-id-token"`) {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+
+	form.Del("device_code")
+	w = postForm(h.Token, form)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_request") {
+		t.Errorf("missing device_code = %d %s, want 400 invalid_request", w.Code, w.Body.String())
+	}
+}
+
+func TestDeviceInfo(t *testing.T) {
+	h, deps := newTestProvider(t)
+	seedDeviceCode(deps)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(middleware.ContextKeyUID, "u1")
+	c.Request = httptest.NewRequest(http.MethodGet, "/oauth/device/info?user_code=bcdf-ghjk", nil)
+	h.DeviceInfo(c)
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Test App") || !strings.Contains(w.Body.String(), "files:read") {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+
+	deps.oauth.DeviceCode = nil
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Set(middleware.ContextKeyUID, "u1")
+	c.Request = httptest.NewRequest(http.MethodGet, "/oauth/device/info?user_code=XXXX-XXXX", nil)
+	h.DeviceInfo(c)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_user_code") {
+		t.Errorf("unknown user_code = %d %s, want 400 invalid_user_code", w.Code, w.Body.String())
+	}
+}
+
+func TestDeviceApprove(t *testing.T) {
+	h, deps := newTestProvider(t)
+	seedDeviceCode(deps)
+
+	w := postDeviceDecision(h, `{"user_code":"BCDF-GHJK","decision":"approve"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if len(deps.oauth.DeviceDecisions) != 1 || !deps.oauth.DeviceDecisions[0] || deps.oauth.DeviceCode.UserUID != "u1" {
+		t.Errorf("decisions = %v, device code = %+v", deps.oauth.DeviceDecisions, deps.oauth.DeviceCode)
+	}
+
+	// 已处理的请求不可再次确认
+	w = postDeviceDecision(h, `{"user_code":"BCDF-GHJK","decision":"deny"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_user_code") {
+		t.Errorf("second decision = %d %s, want 400 invalid_user_code", w.Code, w.Body.String())
+	}
+
+	w = postDeviceDecision(h, `{"user_code":"BCDF-GHJK","decision":"maybe"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid decision status = %d, want 400", w.Code)
+	}
+}
+
+func TestDeviceApproveDisabledClient(t *testing.T) {
+	h, deps := newTestProvider(t)
+	seedDeviceCode(deps)
+	deps.oauth.ValidateErr = services.ErrOAuthClientDisabled
+
+	w := postDeviceDecision(h, `{"user_code":"BCDF-GHJK","decision":"approve"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_client") {
+		t.Errorf("status = %d body = %s, want 400 invalid_client", w.Code, w.Body.String())
+	}
+	if len(deps.oauth.DeviceDecisions) != 0 {
+		t.Errorf("decision should not be recorded for disabled client")
+	}
+}
@@ -0,0 +1,335 @@
+package generic
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"auth-system/internal/config"
+	"auth-system/internal/handlers/oauth"
+	"auth-system/internal/utils"
+)
+
+var (
+	errIdentityRepoRequired = errors.New("identityRepo is required")
+	errDiscovery            = errors.New("OIDC_DISCOVERY_FAILED")
+	errNoAccessToken        = errors.New("no access_token in token response")
+	errInvalidIDToken       = errors.New("INVALID_ID_TOKEN")
+	errSubjectMismatch      = errors.New("userinfo sub does not match id_token sub")
+)
+
+// maxResponseBytes 上游响应体读取上限，防止异常 Provider 耗尽内存
+const maxResponseBytes = 1 << 20
+
+// discoveryDocument OpenID Provider 元数据中用到的字段
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// resolveEndpoints 返回 Provider 端点；配置了 issuer 且端点不全时读取发现文档补全，成功后缓存
+func (h *GenericHandler) resolveEndpoints(ctx context.Context) (*endpoints, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.resolved != nil {
+		return h.resolved, nil
+	}
+
+	ep := &endpoints{
+		Issuer:        h.provider.Issuer,
+		Authorization: h.provider.AuthorizationEndpoint,
+		Token:         h.provider.TokenEndpoint,
+		UserInfo:      h.provider.UserInfoEndpoint,
+	}
+
+	if h.provider.Issuer != "" && (ep.Authorization == "" || ep.Token == "" || ep.UserInfo == "") {
+		doc, err := h.fetchDiscovery(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if ep.Authorization == "" {
+			ep.Authorization = doc.AuthorizationEndpoint
+		}
+		if ep.Token == "" {
+			ep.Token = doc.TokenEndpoint
+		}
+		if ep.UserInfo == "" {
+			ep.UserInfo = doc.UserInfoEndpoint
+		}
+	}
+
+	if ep.Authorization == "" || ep.Token == "" {
+		return nil, fmt.Errorf("%w: authorization or token endpoint unavailable", oauth.ErrOAuthNotConfigured)
+	}
+
+	h.resolved = ep
+	return ep, nil
+}
+
+// fetchDiscovery 读取 {issuer}/.well-known/openid-configuration 并校验 issuer 一致
+func (h *GenericHandler) fetchDiscovery(ctx context.Context) (*discoveryDocument, error) {
+	discoveryURL := strings.TrimSuffix(h.provider.Issuer, "/") + "/.well-known/openid-configuration"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDiscovery, err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	body, status, err := h.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDiscovery, err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", errDiscovery, status)
+	}
+
+	var doc discoveryDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse response: %v", errDiscovery, err)
+	}
+	// OIDC Discovery 4.3：元数据中的 issuer 必须与配置的 issuer 完全一致
+	if doc.Issuer != h.provider.Issuer {
+		return nil, fmt.Errorf("%w: issuer mismatch %q", errDiscovery, doc.Issuer)
+	}
+
+	utils.LogInfo(logModule, "OIDC discovery completed", "provider", h.provider.Name)
+	return &doc, nil
+}
+
+// exchangeCodeForToken 用授权码换取 token（client_secret_post），同时提交 code_verifier
+func (h *GenericHandler) exchangeCodeForToken(ctx context.Context, ep *endpoints, code, codeVerifier string) (map[string]any, error) {
+	if code == "" {
+		return nil, fmt.Errorf("%w: empty code", oauth.ErrOAuthTokenExchange)
+	}
+	if codeVerifier == "" {
+		return nil, fmt.Errorf("%w: empty code_verifier", oauth.ErrOAuthTokenExchange)
+	}
+
+	data := url.Values{}
+	data.Set("client_id", h.ClientID)
+	data.Set("client_secret", h.ClientSecret)
+	data.Set("code", code)
+	data.Set("redirect_uri", h.RedirectURI)
+	data.Set("grant_type", "authorization_code")
+	data.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.Token, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to create request: %v", oauth.ErrOAuthTokenExchange, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// GitHub 等 Provider 默认返回 form 编码，显式要求 JSON
+	req.Header.Set("Accept", "application/json")
+
+	body, status, err := h.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: request failed: %v", oauth.ErrOAuthTokenExchange, err)
+	}
+
+	result, err := decodeJSONObject(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse response: %v", oauth.ErrOAuthTokenExchange, err)
+	}
+
+	if errCode, ok := result["error"].(string); ok {
+		errDesc, _ := result["error_description"].(string)
+		utils.LogErrorCtx(ctx, logModule, "exchangeCodeForToken", fmt.Errorf("%s", errCode), "provider", h.provider.Name, "error_code", errCode, "error_description", errDesc)
+		return nil, fmt.Errorf("%w: %s", oauth.ErrOAuthTokenExchange, errCode)
+	}
+	if status != http.StatusOK {
+		utils.LogErrorCtx(ctx, logModule, "exchangeCodeForToken", fmt.Errorf("status %d", status), "provider", h.provider.Name, "status", status)
+		return nil, fmt.Errorf("%w: status %d", oauth.ErrOAuthTokenExchange, status)
+	}
+
+	return result, nil
+}
+
+// getUserInfo 以 access_token 读取 userinfo 端点
+func (h *GenericHandler) getUserInfo(ctx context.Context, ep *endpoints, accessToken string) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ep.UserInfo, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to create request: %v", oauth.ErrOAuthUserInfo, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	body, status, err := h.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: request failed: %v", oauth.ErrOAuthUserInfo, err)
+	}
+	if status != http.StatusOK {
+		utils.LogErrorCtx(ctx, logModule, "getUserInfo", fmt.Errorf("status %d", status), "provider", h.provider.Name, "status", status)
+		return nil, fmt.Errorf("%w: status %d", oauth.ErrOAuthUserInfo, status)
+	}
+
+	result, err := decodeJSONObject(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse response: %v", oauth.ErrOAuthUserInfo, err)
+	}
+	return result, nil
+}
+
+// do 发送请求并读取响应体（限制大小）
+func (h *GenericHandler) do(req *http.Request) ([]byte, int, error) {
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func(Body io.ReadCloser) {
+		if Body != nil {
+			_ = Body.Close()
+		}
+	}(resp.Body)
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	return body, resp.StatusCode, nil
+}
+
+// mergeClaims 合并 id_token 与 userinfo 声明（userinfo 优先）
+// id_token 由本服务经 TLS 直接从 token 端点取得，按 OIDC Core 3.1.3.7 可不验签，
+// 但仍校验 iss / aud / exp；两者都带 sub 时必须一致（OIDC Core 5.3.2）。
+func (h *GenericHandler) mergeClaims(ep *endpoints, tokenData, userInfo map[string]any) (map[string]any, error) {
+	claims := map[string]any{}
+
+	if idToken, _ := tokenData["id_token"].(string); idToken != "" {
+		idClaims, err := parseIDTokenClaims(idToken, ep.Issuer, h.ClientID, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(claims, idClaims)
+	}
+
+	if userInfo != nil {
+		idSub, infoSub := claimString(claims, "sub"), claimString(userInfo, "sub")
+		if idSub != "" && infoSub != "" && idSub != infoSub {
+			return nil, errSubjectMismatch
+		}
+		maps.Copy(claims, userInfo)
+	}
+
+	return claims, nil
+}
+
+// parseIDTokenClaims 解码 id_token 载荷并校验 iss（配置了 issuer 时）、aud 与 exp
+func parseIDTokenClaims(idToken, issuer, clientID string, now time.Time) (map[string]any, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", errInvalidIDToken)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid payload encoding", errInvalidIDToken)
+	}
+	claims, err := decodeJSONObject(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid payload", errInvalidIDToken)
+	}
+
+	if issuer != "" && claimString(claims, "iss") != issuer {
+		return nil, fmt.Errorf("%w: issuer mismatch", errInvalidIDToken)
+	}
+
+	var audiences []string
+	switch aud := claims["aud"].(type) {
+	case string:
+		audiences = []string{aud}
+	case []any:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+	if !slices.Contains(audiences, clientID) {
+		return nil, fmt.Errorf("%w: audience mismatch", errInvalidIDToken)
+	}
+
+	exp, err := strconv.ParseInt(claimString(claims, "exp"), 10, 64)
+	if err != nil || now.Unix() >= exp {
+		return nil, fmt.Errorf("%w: token expired", errInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+// mapIdentity 按声明映射提取身份；邮箱仅在 Provider 声明已验证（或配置信任）时保留
+func mapIdentity(claims map[string]any, mapping config.OIDCClaimMapping, trustEmail bool) oauth.ProviderIdentity {
+	email := claimString(claims, mapping.Email)
+	if !trustEmail && !claimBool(claims, mapping.EmailVerified) {
+		email = ""
+	}
+
+	displayName := claimString(claims, mapping.Name)
+	if displayName == "" {
+		displayName = claimString(claims, "preferred_username")
+	}
+	if displayName == "" {
+		displayName = "User"
+	}
+
+	return oauth.ProviderIdentity{
+		ProviderID:  claimString(claims, mapping.Subject),
+		Email:       email,
+		DisplayName: displayName,
+		AvatarURL:   claimString(claims, mapping.Picture),
+	}
+}
+
+// claimString 读取字符串声明；数字 ID（如 GitHub id）按原样转为十进制字符串
+func claimString(claims map[string]any, key string) string {
+	switch v := claims[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// claimBool 读取布尔声明，兼容以字符串 "true" 下发的 Provider
+func claimBool(claims map[string]any, key string) bool {
+	switch v := claims[key].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	default:
+		return false
+	}
+}
+
+// decodeJSONObject 解析 JSON 对象，数字保留为 json.Number 以免大整数 ID 丢失精度
+func decodeJSONObject(data []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var result map[string]any
+	if err := dec.Decode(&result); err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, errors.New("not a JSON object")
+	}
+	return result, nil
+}
@@ -0,0 +1,181 @@
+package generic
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"auth-system/internal/config"
+	"auth-system/internal/handlers/oauth"
+)
+
+// fakeIDToken 构造未签名的 id_token（本包不验签，只校验声明）
+func fakeIDToken(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal claims: %v", err)
+	}
+	return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+// newTestIdP 启动提供发现文档、token 与 userinfo 端点的模拟 IdP，id_token 声明由 idClaims(issuer) 生成
+func newTestIdP(t *testing.T, idClaims func(issuer string) map[string]any, userInfo map[string]any) (*httptest.Server, *GenericHandler) {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 srv.URL,
+			"authorization_endpoint": srv.URL + "/authorize",
+			"token_endpoint":         srv.URL + "/token",
+			"userinfo_endpoint":      srv.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("code") != "code-1" || r.PostForm.Get("code_verifier") != "verifier-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "at-1",
+			"token_type":   "Bearer",
+			"id_token":     fakeIDToken(t, idClaims(srv.URL)),
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer at-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(userInfo)
+	})
+
+	h := &GenericHandler{
+		ExternalProviderHandler: &oauth.ExternalProviderHandler{
+			ClientID:     "client-1",
+			ClientSecret: "secret-1",
+			RedirectURI:  "https://auth.test/api/auth/oidc/corp/callback",
+		},
+		provider: config.OIDCProviderConfig{
+			Name:        "corp",
+			DisplayName: "Corp SSO",
+			Issuer:      srv.URL,
+			Scopes:      []string{"openid", "profile", "email"},
+			Claims:      config.OIDCClaimMapping{Subject: "sub", Email: "email", EmailVerified: "email_verified", Name: "name", Picture: "picture"},
+		},
+		httpClient: srv.Client(),
+	}
+	return srv, h
+}
+
+func validIDClaims(issuer string) map[string]any {
+	return map[string]any{
+		"iss":            issuer,
+		"aud":            "client-1",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"sub":            "user-42",
+		"email":          "alice@corp.test",
+		"email_verified": true,
+	}
+}
+
+func TestGenericHandlerLoginFlow(t *testing.T) {
+	srv, h := newTestIdP(t, validIDClaims, map[string]any{"sub": "user-42", "name": "Alice", "picture": "https://cdn.test/a.png"})
+
+	authURL := h.buildAuthURL("state-1", "challenge-1")
+	u, err := url.Parse(authURL)
+	if err != nil || !strings.HasPrefix(authURL, srv.URL+"/authorize?") {
+		t.Fatalf("auth url = %q", authURL)
+	}
+	q := u.Query()
+	if q.Get("code_challenge") != "challenge-1" || q.Get("code_challenge_method") != "S256" || q.Get("scope") != "openid profile email" {
+		t.Errorf("auth url query = %v", q)
+	}
+
+	tokenData, userInfo, err := h.exchangeAndFetch(context.Background(), "code-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("exchangeAndFetch: %v", err)
+	}
+	identity := h.parseIdentity(context.Background(), tokenData, userInfo)
+	want := oauth.ProviderIdentity{ProviderID: "user-42", Email: "alice@corp.test", DisplayName: "Alice", AvatarURL: "https://cdn.test/a.png"}
+	if !reflect.DeepEqual(identity, want) {
+		t.Errorf("identity = %+v, want %+v", identity, want)
+	}
+
+	if _, _, err := h.exchangeAndFetch(context.Background(), "wrong", "verifier-1"); !errors.Is(err, oauth.ErrOAuthTokenExchange) {
+		t.Errorf("bad code err = %v, want ErrOAuthTokenExchange", err)
+	}
+}
+
+func TestGenericHandlerRejectsSubjectMismatch(t *testing.T) {
+	_, h := newTestIdP(t, validIDClaims, map[string]any{"sub": "someone-else"})
+
+	tokenData, userInfo, err := h.exchangeAndFetch(context.Background(), "code-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("exchangeAndFetch: %v", err)
+	}
+	if identity := h.parseIdentity(context.Background(), tokenData, userInfo); identity.ProviderID != "" {
+		t.Errorf("identity = %+v, want empty on sub mismatch", identity)
+	}
+}
+
+func TestParseIDTokenClaims(t *testing.T) {
+	now := time.Now()
+	base := func() map[string]any {
+		return map[string]any{"iss": "https://idp.test", "aud": []any{"other", "client-1"}, "exp": now.Add(time.Minute).Unix(), "sub": "s"}
+	}
+
+	if _, err := parseIDTokenClaims(fakeIDToken(t, base()), "https://idp.test", "client-1", now); err != nil {
+		t.Fatalf("valid token: %v", err)
+	}
+
+	cases := map[string]func(map[string]any){
+		"issuer":   func(c map[string]any) { c["iss"] = "https://evil.test" },
+		"audience": func(c map[string]any) { c["aud"] = "other" },
+		"expired":  func(c map[string]any) { c["exp"] = now.Add(-time.Minute).Unix() },
+	}
+	for name, mutate := range cases {
+		claims := base()
+		mutate(claims)
+		if _, err := parseIDTokenClaims(fakeIDToken(t, claims), "https://idp.test", "client-1", now); !errors.Is(err, errInvalidIDToken) {
+			t.Errorf("%s: err = %v, want errInvalidIDToken", name, err)
+		}
+	}
+
+	if _, err := parseIDTokenClaims("not-a-jwt", "", "client-1", now); !errors.Is(err, errInvalidIDToken) {
+		t.Errorf("malformed: err = %v, want errInvalidIDToken", err)
+	}
+}
+
+func TestMapIdentityGitHubStyle(t *testing.T) {
+	// GitHub /user：数字 id、avatar_url，无 email_verified 声明
+	claims, err := decodeJSONObject([]byte(`{"id": 123456789012, "login": "octocat", "name": null, "email": "octo@github.test", "avatar_url": "https://avatars.test/u/1"}`))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	mapping := config.OIDCClaimMapping{Subject: "id", Email: "email", EmailVerified: "email_verified", Name: "login", Picture: "avatar_url"}
+
+	identity := mapIdentity(claims, mapping, false)
+	if identity.ProviderID != "123456789012" || identity.DisplayName != "octocat" || identity.AvatarURL != "https://avatars.test/u/1" {
+		t.Errorf("identity = %+v", identity)
+	}
+	if identity.Email != "" {
+		t.Errorf("unverified email should be dropped, got %q", identity.Email)
+	}
+
+	if identity := mapIdentity(claims, mapping, true); identity.Email != "octo@github.test" {
+		t.Errorf("trust_email: email = %q", identity.Email)
+	}
+}
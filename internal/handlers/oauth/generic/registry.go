@@ -0,0 +1,124 @@
+package generic
+
+import (
+	"net/http"
+	"time"
+
+	"auth-system/internal/config"
+	"auth-system/internal/handlers/oauth"
+	"auth-system/internal/middleware"
+	"auth-system/internal/models"
+	"auth-system/internal/services"
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Registry 已配置的通用 OIDC Provider 集合，负责路由枚举与前端展示接口
+type Registry struct {
+	handlers   []*GenericHandler
+	identities models.UserIdentityStore
+}
+
+// providerView 登录页 / 账户页展示的 Provider 信息
+type providerView struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	IconURL     string `json:"icon_url,omitempty"`
+}
+
+// identityView 当前用户在某 Provider 下的绑定状态（不暴露 Provider 侧 subject）
+type identityView struct {
+	providerView
+	Linked      bool       `json:"linked"`
+	AccountName string     `json:"account_name,omitempty"`
+	Email       string     `json:"email,omitempty"`
+	LinkedAt    *time.Time `json:"linked_at,omitempty"`
+}
+
+// NewRegistry 按 cfg.OIDCProviders 创建全部通用 Provider Handler
+func NewRegistry(
+	cfg *config.Config,
+	userRepo models.UserReadWriter,
+	userLogRepo models.UserLogStore,
+	identityRepo models.UserIdentityStore,
+	sessionService services.SessionManager,
+	userCache services.UserCacheStore,
+	states oauth.StateStore,
+	loginRisk services.LoginRiskChecker,
+) (*Registry, error) {
+	r := &Registry{identities: identityRepo}
+	for _, p := range cfg.OIDCProviders {
+		h, err := NewGenericHandler(cfg, p, userRepo, userLogRepo, identityRepo, sessionService, userCache, states, loginRisk)
+		if err != nil {
+			return nil, err
+		}
+		r.handlers = append(r.handlers, h)
+	}
+	return r, nil
+}
+
+// Handlers 按配置顺序返回全部 Provider Handler
+func (r *Registry) Handlers() []*GenericHandler {
+	return r.handlers
+}
+
+func (r *Registry) views() []providerView {
+	views := make([]providerView, 0, len(r.handlers))
+	for _, h := range r.handlers {
+		views = append(views, providerView{
+			Name:        h.provider.Name,
+			DisplayName: h.provider.DisplayName,
+			IconURL:     h.provider.IconURL,
+		})
+	}
+	return views
+}
+
+// ListProviders 列出可用于登录的通用 Provider（登录页渲染按钮）
+// GET /api/auth/providers
+func (r *Registry) ListProviders(c *gin.Context) {
+	utils.RespondSuccess(c, gin.H{"providers": r.views()})
+}
+
+// ListIdentities 列出当前用户在各通用 Provider 下的绑定状态（账户页渲染绑定/解绑）
+// GET /api/user/identities
+func (r *Registry) ListIdentities(c *gin.Context) {
+	userUID, ok := middleware.GetUID(c)
+	if !ok {
+		utils.RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED")
+		return
+	}
+
+	if len(r.handlers) == 0 {
+		utils.RespondSuccess(c, gin.H{"identities": []identityView{}})
+		return
+	}
+
+	linked, err := r.identities.ListByUser(c.Request.Context(), userUID)
+	if err != nil {
+		utils.LogErrorCtx(c.Request.Context(), logModule, "ListIdentities", err, "user_uid", userUID)
+		utils.RespondError(c, http.StatusInternalServerError, "DATABASE_ERROR")
+		return
+	}
+
+	byProvider := make(map[string]*models.UserIdentity, len(linked))
+	for _, identity := range linked {
+		byProvider[identity.Provider] = identity
+	}
+
+	// 仅返回仍在配置中的 Provider；已移除 Provider 的历史绑定保留在库中但不再展示
+	views := make([]identityView, 0, len(r.handlers))
+	for _, p := range r.views() {
+		view := identityView{providerView: p}
+		if identity, ok := byProvider[p.Name]; ok {
+			view.Linked = true
+			view.AccountName = identity.DisplayName
+			view.Email = identity.Email
+			view.LinkedAt = &identity.CreatedAt
+		}
+		views = append(views, view)
+	}
+
+	utils.RespondSuccess(c, gin.H{"identities": views})
+}
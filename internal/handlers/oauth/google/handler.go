@@ -22,23 +22,31 @@ type GoogleHandler struct {
 	verifier                *WorkerTokenVerifier // 代理签名验签器（含 id_token claims 校验）
 	proxyAccessClientID     string               // CF Access Service Token（可选）
 	proxyAccessClientSecret string
+	identities              models.UserIdentityStore
 }
 
-// NewGoogleHandler 创建 Google OAuth Handler，验证必需依赖后初始化。
+// NewGoogleHandler 创建 Google OAuth Handler，验证必需依赖后初始化；loginRisk 为可选参数。
 func NewGoogleHandler(
 	cfg *config.Config,
 	userRepo models.UserReadWriter,
 	userLogRepo models.UserLogStore,
+	identityRepo models.UserIdentityStore,
 	sessionService services.SessionManager,
 	userCache services.UserCacheStore,
+	states oauth.StateStore,
+	loginRisk services.LoginRiskChecker,
 ) (*GoogleHandler, error) {
-	base, err := oauth.NewExternalProviderHandler(cfg, userRepo, userLogRepo, sessionService, userCache, nil)
+	base, err := oauth.NewExternalProviderHandler(cfg, userRepo, userLogRepo, sessionService, userCache, nil, states, loginRisk)
 	if err != nil {
 		return nil, err
 	}
+	if identityRepo == nil {
+		return nil, utils.LogError("OAUTH-GOOGLE", "NewGoogleHandler", fmt.Errorf("identityRepo is required"))
+	}
 
 	h := &GoogleHandler{
 		ExternalProviderHandler: base,
+		identities:              identityRepo,
 		proxyURLs:               cfg.GoogleProxyURLs(),
 		proxyAccessClientID:     cfg.ProxyAccessClientID,
 		proxyAccessClientSecret: cfg.ProxyAccessClientSecret,
@@ -68,7 +76,8 @@ func NewGoogleHandler(
 	h.Spec = oauth.ProviderSpec{
 		LogModule:          "OAUTH-GOOGLE",
 		Name:               "Google",
-		NameLower:          "google",
+		NameLower:          models.ProviderGoogle,
+		RoutePath:          "google",
 		AvatarStateValue:   "google",
 		AlreadyLinkedError: "GOOGLE_ALREADY_LINKED",
 		AlreadyLinkedRedir: "google_already_linked",
@@ -83,9 +92,9 @@ func NewGoogleHandler(
 		GetLinkedInfo:      h.getLinkedInfo,
 		LogLink:            h.logLink,
 		LogUnlink:          h.logUnlink,
-		LinkFields:         h.linkFields,
-		ProfileFields:      h.profileFields,
-		UnlinkFields:       h.unlinkFields,
+		SaveLink:           h.saveLink,
+		SaveProfile:        h.saveProfile,
+		RemoveLink:         h.removeLink,
 		AfterLink:          nil,
 		AfterLogin:         nil,
 		AfterUnlink:        nil,
@@ -183,21 +192,24 @@ func (h *GoogleHandler) parseIdentity(ctx context.Context, tokenData, userInfo m
 }
 
 func (h *GoogleHandler) findByID(ctx context.Context, id string) (*models.User, error) {
-	return h.UserRepo.FindByGoogleID(ctx, id)
+	identity, err := h.identities.FindBySubject(ctx, models.ProviderGoogle, id)
+	if err != nil {
+		return nil, err
+	}
+	return h.UserRepo.FindByUID(ctx, identity.UserUID)
 }
 
-func (h *GoogleHandler) isLinked(user *models.User) bool {
-	return user.GoogleID.Valid && user.GoogleID.String != ""
+func (h *GoogleHandler) isLinked(ctx context.Context, user *models.User) bool {
+	_, err := h.identities.FindByUser(ctx, user.UID, models.ProviderGoogle)
+	return err == nil
 }
 
-func (h *GoogleHandler) getLinkedInfo(user *models.User) (id, name string) {
-	if user.GoogleID.Valid {
-		id = user.GoogleID.String
-	}
-	if user.GoogleName.Valid {
-		name = user.GoogleName.String
+func (h *GoogleHandler) getLinkedInfo(ctx context.Context, user *models.User) (id, name string) {
+	identity, err := h.identities.FindByUser(ctx, user.UID, models.ProviderGoogle)
+	if err != nil {
+		return "", ""
 	}
-	return id, name
+	return identity.Subject, identity.DisplayName
 }
 
 func (h *GoogleHandler) logLink(ctx context.Context, userUID, id, displayName string) error {
@@ -208,36 +220,39 @@ func (h *GoogleHandler) logUnlink(ctx context.Context, userUID, id, displayName
 	return h.UserLogRepo.LogUnlinkGoogle(ctx, userUID, id, displayName)
 }
 
-func (h *GoogleHandler) linkFields(identity oauth.ProviderIdentity) map[string]any {
-	fields := map[string]any{
-		"google_id":   identity.ProviderID,
-		"google_name": identity.DisplayName,
-	}
-	if identity.AvatarURL != "" {
-		fields["google_avatar_url"] = identity.AvatarURL
+func (h *GoogleHandler) saveLink(ctx context.Context, userUID string, identity oauth.ProviderIdentity) error {
+	return h.identities.Link(ctx, toUserIdentity(userUID, identity))
+}
+
+// saveProfile 登录时同步名称与头像；本次未返回头像时保留已保存的头像
+func (h *GoogleHandler) saveProfile(ctx context.Context, userUID string, identity oauth.ProviderIdentity) error {
+	if identity.AvatarURL == "" {
+		if existing, err := h.identities.FindByUser(ctx, userUID, models.ProviderGoogle); err == nil {
+			identity.AvatarURL = existing.AvatarURL
+		}
 	}
-	return fields
+	return h.identities.UpdateProfile(ctx, toUserIdentity(userUID, identity))
 }
 
-func (h *GoogleHandler) profileFields(identity oauth.ProviderIdentity) map[string]any {
-	fields := map[string]any{
-		"google_name": identity.DisplayName,
+// removeLink 删除绑定身份；用户正在使用 Google 头像时回退为默认头像
+func (h *GoogleHandler) removeLink(ctx context.Context, user *models.User) error {
+	if err := h.identities.Delete(ctx, user.UID, models.ProviderGoogle); err != nil {
+		return err
 	}
-	if identity.AvatarURL != "" {
-		fields["google_avatar_url"] = identity.AvatarURL
+	if user.AvatarURL != h.Spec.AvatarStateValue {
+		return nil
 	}
-	return fields
+	utils.LogInfo("OAUTH-GOOGLE", "User was using Google avatar, resetting to default", "user_uid", user.UID)
+	return h.UserRepo.Update(ctx, user.UID, map[string]any{"avatar_url": h.DefaultAvatarURL})
 }
 
-func (h *GoogleHandler) unlinkFields(user *models.User) map[string]any {
-	fields := map[string]any{
-		"google_id":         nil,
-		"google_name":       nil,
-		"google_avatar_url": nil,
-	}
-	if user.AvatarURL == h.Spec.AvatarStateValue {
-		fields["avatar_url"] = h.DefaultAvatarURL
-		utils.LogInfo("OAUTH-GOOGLE", "User was using Google avatar, resetting to default", "user_uid", user.UID)
+func toUserIdentity(userUID string, identity oauth.ProviderIdentity) *models.UserIdentity {
+	return &models.UserIdentity{
+		UserUID:     userUID,
+		Provider:    models.ProviderGoogle,
+		Subject:     identity.ProviderID,
+		DisplayName: identity.DisplayName,
+		Email:       identity.Email,
+		AvatarURL:   identity.AvatarURL,
 	}
-	return fields
 }
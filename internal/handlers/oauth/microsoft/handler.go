@@ -24,24 +24,31 @@ const (
 // MicrosoftHandler Microsoft OAuth Handler
 type MicrosoftHandler struct {
 	*oauth.ExternalProviderHandler
+	identities models.UserIdentityStore
 }
 
-// NewMicrosoftHandler 创建 Microsoft OAuth Handler，验证必需依赖（userRepo、sessionService、userCache）后初始化。
-// storageService 和 userLogRepo 为可选参数。
+// NewMicrosoftHandler 创建 Microsoft OAuth Handler，验证必需依赖（userRepo、identityRepo、sessionService、userCache、states）后初始化。
+// storageService、userLogRepo 和 loginRisk 为可选参数。
 func NewMicrosoftHandler(
 	cfg *config.Config,
 	userRepo models.UserReadWriter,
 	userLogRepo models.UserLogStore,
+	identityRepo models.UserIdentityStore,
 	sessionService services.SessionManager,
 	userCache services.UserCacheStore,
 	storageService services.StorageService,
+	states oauth.StateStore,
+	loginRisk services.LoginRiskChecker,
 ) (*MicrosoftHandler, error) {
-	base, err := oauth.NewExternalProviderHandler(cfg, userRepo, userLogRepo, sessionService, userCache, storageService)
+	base, err := oauth.NewExternalProviderHandler(cfg, userRepo, userLogRepo, sessionService, userCache, storageService, states, loginRisk)
 	if err != nil {
 		return nil, err
 	}
+	if identityRepo == nil {
+		return nil, utils.LogError("OAUTH-MS", "NewMicrosoftHandler", fmt.Errorf("identityRepo is required"))
+	}
 
-	h := &MicrosoftHandler{ExternalProviderHandler: base}
+	h := &MicrosoftHandler{ExternalProviderHandler: base, identities: identityRepo}
 	h.ClientID = cfg.MicrosoftClientID
 	h.ClientSecret = cfg.MicrosoftClientSecret
 	h.RedirectURI = cfg.BaseURL + "/api/auth/microsoft/callback"
@@ -53,7 +60,8 @@ func NewMicrosoftHandler(
 	h.Spec = oauth.ProviderSpec{
 		LogModule:          "OAUTH-MS",
 		Name:               "Microsoft",
-		NameLower:          "microsoft",
+		NameLower:          models.ProviderMicrosoft,
+		RoutePath:          "microsoft",
 		AvatarStateValue:   "microsoft",
 		AlreadyLinkedError: "MICROSOFT_ALREADY_LINKED",
 		AlreadyLinkedRedir: "microsoft_already_linked",
@@ -68,9 +76,9 @@ func NewMicrosoftHandler(
 		GetLinkedInfo:      h.getLinkedInfo,
 		LogLink:            h.logLink,
 		LogUnlink:          h.logUnlink,
-		LinkFields:         h.linkFields,
-		ProfileFields:      h.profileFields,
-		UnlinkFields:       h.unlinkFields,
+		SaveLink:           h.saveLink,
+		SaveProfile:        h.saveProfile,
+		RemoveLink:         h.removeLink,
 		AfterLink:          h.afterLink,
 		AfterLogin:         h.afterLogin,
 		AfterUnlink:        h.afterUnlink,
@@ -146,21 +154,24 @@ func (h *MicrosoftHandler) parseIdentity(ctx context.Context, tokenData, userInf
 }
 
 func (h *MicrosoftHandler) findByID(ctx context.Context, id string) (*models.User, error) {
-	return h.UserRepo.FindByMicrosoftID(ctx, id)
+	identity, err := h.identities.FindBySubject(ctx, models.ProviderMicrosoft, id)
+	if err != nil {
+		return nil, err
+	}
+	return h.UserRepo.FindByUID(ctx, identity.UserUID)
 }
 
-func (h *MicrosoftHandler) isLinked(user *models.User) bool {
-	return user.MicrosoftID.Valid && user.MicrosoftID.String != ""
+func (h *MicrosoftHandler) isLinked(ctx context.Context, user *models.User) bool {
+	_, err := h.identities.FindByUser(ctx, user.UID, models.ProviderMicrosoft)
+	return err == nil
 }
 
-func (h *MicrosoftHandler) getLinkedInfo(user *models.User) (id, name string) {
-	if user.MicrosoftID.Valid {
-		id = user.MicrosoftID.String
-	}
-	if user.MicrosoftName.Valid {
-		name = user.MicrosoftName.String
+func (h *MicrosoftHandler) getLinkedInfo(ctx context.Context, user *models.User) (id, name string) {
+	identity, err := h.identities.FindByUser(ctx, user.UID, models.ProviderMicrosoft)
+	if err != nil {
+		return "", ""
 	}
-	return id, name
+	return identity.Subject, identity.DisplayName
 }
 
 func (h *MicrosoftHandler) logLink(ctx context.Context, userUID, id, displayName string) error {
@@ -179,25 +190,23 @@ func (h *MicrosoftHandler) logUnlink(ctx context.Context, userUID, id, displayNa
 	return h.UserLogRepo.LogUnlinkMicrosoft(ctx, userUID, id, displayName)
 }
 
-// linkFields Microsoft 头像不走字段更新：图片文件经 AfterLink 异步转存到本地存储/R2，
+// saveLink Microsoft 头像不随身份保存：图片文件经 AfterLink 异步转存到本地存储/R2，
 // 数据库中的 microsoft_avatar_url 由 processAvatarAsync 更新
-func (h *MicrosoftHandler) linkFields(identity oauth.ProviderIdentity) map[string]any {
-	return map[string]any{
-		"microsoft_id":   identity.ProviderID,
-		"microsoft_name": identity.DisplayName,
-	}
+func (h *MicrosoftHandler) saveLink(ctx context.Context, userUID string, identity oauth.ProviderIdentity) error {
+	return h.identities.Link(ctx, toUserIdentity(userUID, identity))
 }
 
-func (h *MicrosoftHandler) profileFields(identity oauth.ProviderIdentity) map[string]any {
-	return map[string]any{
-		"microsoft_name": identity.DisplayName,
-	}
+func (h *MicrosoftHandler) saveProfile(ctx context.Context, userUID string, identity oauth.ProviderIdentity) error {
+	return h.identities.UpdateProfile(ctx, toUserIdentity(userUID, identity))
 }
 
-func (h *MicrosoftHandler) unlinkFields(user *models.User) map[string]any {
+// removeLink 删除绑定身份，并清理已转存的头像字段（含头像回退）
+func (h *MicrosoftHandler) removeLink(ctx context.Context, user *models.User) error {
+	if err := h.identities.Delete(ctx, user.UID, models.ProviderMicrosoft); err != nil {
+		return err
+	}
+
 	fields := map[string]any{
-		"microsoft_id":          nil,
-		"microsoft_name":        nil,
 		"microsoft_avatar_url":  nil,
 		"microsoft_avatar_hash": nil,
 		"microsoft_avatar_sync": false, // 解绑后同步终止，状态置 false 保持一致
@@ -206,7 +215,17 @@ func (h *MicrosoftHandler) unlinkFields(user *models.User) map[string]any {
 		fields["avatar_url"] = h.DefaultAvatarURL
 		utils.LogInfo("OAUTH-MS", "User was using Microsoft avatar, resetting to default", "user_uid", user.UID)
 	}
-	return fields
+	return h.UserRepo.Update(ctx, user.UID, fields)
+}
+
+func toUserIdentity(userUID string, identity oauth.ProviderIdentity) *models.UserIdentity {
+	return &models.UserIdentity{
+		UserUID:     userUID,
+		Provider:    models.ProviderMicrosoft,
+		Subject:     identity.ProviderID,
+		DisplayName: identity.DisplayName,
+		Email:       identity.Email,
+	}
 }
 
 // afterLink 绑定成功后异步转存头像
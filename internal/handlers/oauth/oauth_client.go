@@ -1,10 +1,11 @@
 // Package oauth 的公共外部登录处理器基类。
-// Google 与 Microsoft OAuth 登录流程高度相似（发起授权、回调、绑定、待绑定确认、解绑），
-// 本文件提取其公共骨架，Provider 差异通过 ProviderSpec 策略函数注入，消除两份重复实现。
+// Google、Microsoft 与通用 OIDC Provider 的登录流程高度相似（发起授权、回调、绑定、待绑定确认、解绑），
+// 本文件提取其公共骨架，Provider 差异通过 ProviderSpec 策略函数注入，消除重复实现。
 package oauth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -23,7 +24,7 @@ import (
 
 // ProviderIdentity 一次 OAuth 登录/绑定中从 Provider 提取到的用户身份数据
 type ProviderIdentity struct {
-	ProviderID  string // Provider 侧的用户 ID（Microsoft id / Google id）
+	ProviderID  string // Provider 侧的用户 ID（Microsoft id / Google id / OIDC sub）
 	Email       string // 已验证的邮箱（可能为空）
 	DisplayName string // 显示名称
 	AvatarURL   string // 头像 URL：google 为 picture；Microsoft 为 data URL（含 base64 数据）
@@ -36,6 +37,7 @@ type ProviderSpec struct {
 	LogModule          string // "OAUTH-MS" / "OAUTH-GOOGLE"
 	Name               string // "Microsoft" / "Google"（日志文案）
 	NameLower          string // "microsoft" / "google"（JSON key / 状态值）
+	RoutePath          string // /api/auth/ 之后的路由段："microsoft" / "google" / "oidc/keycloak"
 	AvatarStateValue   string // user.AvatarURL 指向本 Provider 头像时的标记值
 	AlreadyLinkedError string // "MICROSOFT_ALREADY_LINKED"
 	AlreadyLinkedRedir string // 重定向错误码 "microsoft_already_linked"
@@ -47,16 +49,16 @@ type ProviderSpec struct {
 	ExchangeAndFetch func(ctx context.Context, code, codeVerifier string) (tokenData, userInfo map[string]any, err error)
 	ParseIdentity    func(ctx context.Context, tokenData, userInfo map[string]any) ProviderIdentity
 	FindByID         func(ctx context.Context, id string) (*models.User, error)
-	IsLinked         func(user *models.User) bool
-	GetLinkedInfo    func(user *models.User) (id, name string)
+	IsLinked         func(ctx context.Context, user *models.User) bool
+	GetLinkedInfo    func(ctx context.Context, user *models.User) (id, name string)
 	LogLink          func(ctx context.Context, userUID, id, displayName string) error
 	LogUnlink        func(ctx context.Context, userUID, id, displayName string) error
-	// LinkFields 绑定/确认绑定时写入的字段（含 ID、名称及可选头像）
-	LinkFields func(identity ProviderIdentity) map[string]any
-	// ProfileFields 登录时更新已存在用户资料的字段
-	ProfileFields func(identity ProviderIdentity) map[string]any
-	// UnlinkFields 解绑时清空的字段（含头像回退）
-	UnlinkFields func(user *models.User) map[string]any
+	// SaveLink 绑定/确认绑定时持久化外部身份（user_identities）
+	SaveLink func(ctx context.Context, userUID string, identity ProviderIdentity) error
+	// SaveProfile 登录时同步已绑定身份的 Provider 侧资料
+	SaveProfile func(ctx context.Context, userUID string, identity ProviderIdentity) error
+	// RemoveLink 解绑时删除外部身份（含 Provider 特有的头像回退）
+	RemoveLink func(ctx context.Context, user *models.User) error
 	// AfterLink 绑定成功后的 Provider 特有处理（Microsoft 异步转存头像）
 	AfterLink func(ctx context.Context, userUID string, identity ProviderIdentity)
 	// AfterLogin 已存在用户登录成功后的 Provider 特有处理
@@ -65,22 +67,24 @@ type ProviderSpec struct {
 	AfterUnlink func(ctx context.Context, userUID string, user *models.User)
 }
 
-// ExternalProviderHandler 外部 OAuth 登录处理器基类（Google / Microsoft 共用）
+// ExternalProviderHandler 外部 OAuth 登录处理器基类（Google / Microsoft / 通用 OIDC 共用）
 type ExternalProviderHandler struct {
 	UserRepo         models.UserReadWriter
 	UserLogRepo      models.UserLogStore
 	SessionService   services.SessionManager
 	UserCache        services.UserCacheStore
 	StorageService   services.StorageService
+	States           StateStore
 	ClientID         string
 	ClientSecret     string
 	RedirectURI      string
 	BaseURL          string
 	DefaultAvatarURL string
+	LoginRisk        services.LoginRiskChecker // 新设备登录检查（可选）
 	Spec             ProviderSpec
 }
 
-// NewExternalProviderHandler 创建基类，验证必需依赖
+// NewExternalProviderHandler 创建基类，验证必需依赖；storageService、loginRisk 为可选参数
 func NewExternalProviderHandler(
 	cfg *config.Config,
 	userRepo models.UserReadWriter,
@@ -88,6 +92,8 @@ func NewExternalProviderHandler(
 	sessionService services.SessionManager,
 	userCache services.UserCacheStore,
 	storageService services.StorageService,
+	states StateStore,
+	loginRisk services.LoginRiskChecker,
 ) (*ExternalProviderHandler, error) {
 	if userRepo == nil {
 		return nil, fmt.Errorf("userRepo is required")
@@ -98,12 +104,17 @@ func NewExternalProviderHandler(
 	if userCache == nil {
 		return nil, fmt.Errorf("userCache is required")
 	}
+	if states == nil {
+		return nil, fmt.Errorf("state store is required")
+	}
 	return &ExternalProviderHandler{
 		UserRepo:         userRepo,
 		UserLogRepo:      userLogRepo,
 		SessionService:   sessionService,
 		UserCache:        userCache,
 		StorageService:   storageService,
+		States:           states,
+		LoginRisk:        loginRisk,
 		BaseURL:          cfg.BaseURL,
 		DefaultAvatarURL: cfg.DefaultAvatarURL,
 	}, nil
@@ -157,6 +168,9 @@ func (h *ExternalProviderHandler) Auth(c *gin.Context) {
 		}
 
 		claims, err := h.SessionService.VerifyToken(token)
+		if err == nil {
+			err = h.SessionService.CheckSession(c.Request.Context(), claims)
+		}
 		if err != nil {
 			utils.LogWarnCtx(c.Request.Context(), h.Spec.LogModule, "Link action but invalid session")
 			RedirectWithError(c, h.BaseURL, paths.PathAccountDashboard, "session_expired")
@@ -169,6 +183,13 @@ func (h *ExternalProviderHandler) Auth(c *gin.Context) {
 			return
 		}
 
+		// 模拟登录会话不能为目标用户绑定外部身份：否则模拟结束后管理员仍可用自己的外部账号登录该用户
+		if impersonator := claims.Impersonator(); impersonator != "" {
+			utils.LogWarnCtx(c.Request.Context(), h.Spec.LogModule, "Link action with impersonation session", "user_uid", claims.UID, "impersonator", impersonator)
+			RedirectWithError(c, h.BaseURL, paths.PathAccountDashboard, "impersonation_forbidden")
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 		defer cancel()
 		user, err := h.UserCache.GetOrLoad(ctx, claims.UID, h.UserRepo.FindByUID)
@@ -187,7 +208,11 @@ func (h *ExternalProviderHandler) Auth(c *gin.Context) {
 		utils.LogInfoCtx(c.Request.Context(), h.Spec.LogModule, "Link action initiated", "user_uid", claims.UID)
 	}
 
-	SaveState(state, stateData)
+	if err := h.States.SaveState(c.Request.Context(), state, stateData); err != nil {
+		utils.LogErrorCtx(c.Request.Context(), h.Spec.LogModule, "Auth", err, "Failed to save state")
+		RedirectWithError(c, h.BaseURL, paths.PathAccountLogin, "oauth_error")
+		return
+	}
 
 	redirectURL := h.Spec.BuildAuthURL(state, codeChallenge)
 	utils.LogInfoCtx(c.Request.Context(), h.Spec.LogModule, "Redirecting to "+h.Spec.Name+" auth with PKCE", "action", action)
@@ -220,12 +245,17 @@ func (h *ExternalProviderHandler) Callback(c *gin.Context) {
 		return
 	}
 
-	stateData, exists := GetAndDeleteState(state)
-	if !exists {
+	stateData, err := h.States.GetAndDeleteState(c.Request.Context(), state)
+	if errors.Is(err, ErrOAuthStateNotFound) {
 		utils.LogWarnCtx(c.Request.Context(), h.Spec.LogModule, "Invalid state - not found in storage (may be duplicate request)")
 		RedirectWithError(c, h.BaseURL, paths.PathAccountLogin, "oauth_invalid")
 		return
 	}
+	if err != nil {
+		utils.LogErrorCtx(c.Request.Context(), h.Spec.LogModule, "Callback", err, "Failed to load state")
+		RedirectWithError(c, h.BaseURL, paths.PathAccountLogin, "oauth_error")
+		return
+	}
 
 	if stateData == nil {
 		utils.LogErrorCtx(c.Request.Context(), h.Spec.LogModule, "Callback", fmt.Errorf("state data is nil"), "State data is nil")
@@ -319,15 +349,15 @@ func (h *ExternalProviderHandler) Unlink(c *gin.Context) {
 		return
 	}
 
-	if !h.Spec.IsLinked(user) {
+	if !h.Spec.IsLinked(ctx, user) {
 		utils.LogWarnCtx(c.Request.Context(), h.Spec.LogModule, h.Spec.NotLinkedLog, "user_uid", userUID)
 		utils.RespondError(c, http.StatusBadRequest, "NOT_LINKED")
 		return
 	}
 
-	oldProviderID, oldProviderName := h.Spec.GetLinkedInfo(user)
+	oldProviderID, oldProviderName := h.Spec.GetLinkedInfo(ctx, user)
 
-	err = h.UserRepo.Update(ctx, userUID, h.Spec.UnlinkFields(user))
+	err = h.removeLink(ctx, user)
 	if err != nil {
 		utils.LogErrorCtx(c.Request.Context(), h.Spec.LogModule, "Unlink", err, "user_uid", userUID)
 		utils.RespondError(c, http.StatusInternalServerError, "UNLINK_FAILED")
@@ -360,23 +390,34 @@ func (h *ExternalProviderHandler) GetPendingLinkInfo(c *gin.Context) {
 		return
 	}
 
-	pendingData, exists := GetPendingLink(token)
-	if !exists {
+	pendingData, err := h.States.GetPendingLink(c.Request.Context(), token)
+	if errors.Is(err, ErrInvalidLinkToken) {
 		utils.LogWarnCtx(c.Request.Context(), h.Spec.LogModule, "Pending link not found", "token", utils.TruncateIdentifier(token))
 		utils.RespondError(c, http.StatusBadRequest, "INVALID_TOKEN")
 		return
 	}
+	if err != nil {
+		utils.LogErrorCtx(c.Request.Context(), h.Spec.LogModule, "GetPendingLinkInfo", err, "token", utils.TruncateIdentifier(token))
+		utils.RespondError(c, http.StatusInternalServerError, "DATABASE_ERROR")
+		return
+	}
+
+	if pendingData != nil && pendingData.Provider != h.Spec.RoutePath {
+		utils.LogWarnCtx(c.Request.Context(), h.Spec.LogModule, "Pending link belongs to another provider", "provider", pendingData.Provider)
+		utils.RespondError(c, http.StatusBadRequest, "INVALID_TOKEN")
+		return
+	}
 
 	if pendingData == nil {
 		utils.LogErrorCtx(c.Request.Context(), h.Spec.LogModule, "GetPendingLinkInfo", fmt.Errorf("pending link data is nil"), "token", utils.TruncateIdentifier(token))
-		DeletePendingLink(token)
+		_ = h.States.DeletePendingLink(c.Request.Context(), token)
 		utils.RespondError(c, http.StatusBadRequest, "INVALID_TOKEN")
 		return
 	}
 
 	if time.Now().UnixMilli()-pendingData.Timestamp > StateExpiryMS {
 		utils.LogWarnCtx(c.Request.Context(), h.Spec.LogModule, "Pending link expired", "token", utils.TruncateIdentifier(token))
-		DeletePendingLink(token)
+		_ = h.States.DeletePendingLink(c.Request.Context(), token)
 		utils.RespondError(c, http.StatusBadRequest, "TOKEN_EXPIRED")
 		return
 	}
@@ -401,6 +442,9 @@ func (h *ExternalProviderHandler) GetPendingLinkInfo(c *gin.Context) {
 		"data": gin.H{
 			h.Spec.NameLower + "Name":   pendingData.DisplayName,
 			h.Spec.NameLower + "Avatar": pendingData.ProviderAvatarURL,
+			"providerLabel":             h.Spec.Name,
+			"providerName":              pendingData.DisplayName,
+			"providerAvatar":            pendingData.ProviderAvatarURL,
 			"username":                  user.Username,
 			"userAvatar":                user.AvatarURL,
 		},
@@ -419,12 +463,17 @@ func (h *ExternalProviderHandler) ConfirmLink(c *gin.Context) {
 		return
 	}
 
-	pendingData, exists := GetAndDeletePendingLink(token)
-	if !exists {
+	pendingData, err := h.States.GetAndDeletePendingLink(c.Request.Context(), token)
+	if errors.Is(err, ErrInvalidLinkToken) {
 		utils.LogWarnCtx(c.Request.Context(), h.Spec.LogModule, "Pending link not found in ConfirmLink", "token", utils.TruncateIdentifier(token))
 		utils.RespondError(c, http.StatusBadRequest, "INVALID_TOKEN")
 		return
 	}
+	if err != nil {
+		utils.LogErrorCtx(c.Request.Context(), h.Spec.LogModule, "ConfirmLink", err, "token", utils.TruncateIdentifier(token))
+		utils.RespondError(c, http.StatusInternalServerError, "DATABASE_ERROR")
+		return
+	}
 
 	if pendingData == nil {
 		utils.LogErrorCtx(c.Request.Context(), h.Spec.LogModule, "ConfirmLink", fmt.Errorf("pending link data is nil"), "token", utils.TruncateIdentifier(token))
@@ -432,6 +481,13 @@ func (h *ExternalProviderHandler) ConfirmLink(c *gin.Context) {
 		return
 	}
 
+	// 待绑定数据只能由发起它的 Provider 确认，防止 A Provider 的身份被写入 B Provider 的绑定
+	if pendingData.Provider != h.Spec.RoutePath {
+		utils.LogWarnCtx(c.Request.Context(), h.Spec.LogModule, "Pending link belongs to another provider in ConfirmLink", "provider", pendingData.Provider)
+		utils.RespondError(c, http.StatusBadRequest, "INVALID_TOKEN")
+		return
+	}
+
 	if time.Now().UnixMilli()-pendingData.Timestamp > StateExpiryMS {
 		utils.LogWarnCtx(c.Request.Context(), h.Spec.LogModule, "Pending link expired in ConfirmLink", "token", utils.TruncateIdentifier(token))
 		utils.RespondError(c, http.StatusBadRequest, "TOKEN_EXPIRED")
@@ -477,7 +533,7 @@ func (h *ExternalProviderHandler) ConfirmLink(c *gin.Context) {
 		Email:       pendingData.Email,
 	}
 
-	err = h.UserRepo.Update(ctx, pendingData.UserUID, h.Spec.LinkFields(identity))
+	err = h.saveLink(ctx, pendingData.UserUID, identity)
 	if err != nil {
 		utils.LogErrorCtx(c.Request.Context(), h.Spec.LogModule, "ConfirmLink", err, "user_uid", pendingData.UserUID)
 		utils.RespondError(c, http.StatusInternalServerError, "LINK_FAILED")
@@ -496,7 +552,7 @@ func (h *ExternalProviderHandler) ConfirmLink(c *gin.Context) {
 		h.Spec.AfterLink(ctx, pendingData.UserUID, identity)
 	}
 
-	accessToken, refreshToken, err := h.SessionService.GenerateTokens(c.Request.Context(), user.UID, false)
+	accessToken, refreshToken, familyID, err := h.SessionService.GenerateTokens(c.Request.Context(), user.UID, false)
 	if err != nil {
 		utils.LogErrorCtx(c.Request.Context(), h.Spec.LogModule, "ConfirmLink", err, "user_uid", user.UID)
 		utils.RespondError(c, http.StatusInternalServerError, "TOKEN_GENERATION_FAILED")
@@ -505,6 +561,7 @@ func (h *ExternalProviderHandler) ConfirmLink(c *gin.Context) {
 
 	SetAuthCookie(c, accessToken)
 	utils.SetRefreshTokenCookieGin(c, refreshToken)
+	h.checkLoginRisk(c, user, familyID)
 
 	utils.ClearLinkTokenCookieGin(c)
 
@@ -525,7 +582,7 @@ func (h *ExternalProviderHandler) handleLinkAction(c *gin.Context, ctx context.C
 		return
 	}
 
-	err = h.UserRepo.Update(ctx, currentUserUID, h.Spec.LinkFields(identity))
+	err = h.saveLink(ctx, currentUserUID, identity)
 	if err != nil {
 		utils.LogErrorCtx(c.Request.Context(), h.Spec.LogModule, "handleLinkAction", err, "user_uid", currentUserUID)
 		RedirectWithError(c, h.BaseURL, paths.PathAccountDashboard, "link_failed")
@@ -556,7 +613,7 @@ func (h *ExternalProviderHandler) handleLoginAction(c *gin.Context, ctx context.
 	}
 
 	if user != nil {
-		err = h.UserRepo.Update(ctx, user.UID, h.Spec.ProfileFields(identity))
+		err = h.saveProfile(ctx, user.UID, identity)
 		if err != nil {
 			utils.LogWarnCtx(c.Request.Context(), h.Spec.LogModule, "Failed to update "+h.Spec.Name+" name", "user_uid", user.UID)
 		}
@@ -573,7 +630,7 @@ func (h *ExternalProviderHandler) handleLoginAction(c *gin.Context, ctx context.
 			utils.LogDebugCtx(c.Request.Context(), h.Spec.LogModule, "FindByEmail error in handleLoginAction")
 		}
 
-		if existingUser != nil && !h.Spec.IsLinked(existingUser) {
+		if existingUser != nil && !h.Spec.IsLinked(ctx, existingUser) {
 			linkToken, err := GenerateLinkToken()
 			if err != nil {
 				utils.LogErrorCtx(c.Request.Context(), h.Spec.LogModule, "handleLoginAction", err, "Failed to generate link token")
@@ -585,7 +642,8 @@ func (h *ExternalProviderHandler) handleLoginAction(c *gin.Context, ctx context.
 				return
 			}
 
-			SavePendingLink(linkToken, &PendingLink{
+			err = h.States.SavePendingLink(ctx, linkToken, &PendingLink{
+				Provider:          h.Spec.RoutePath,
 				UserUID:           existingUser.UID,
 				ProviderID:        identity.ProviderID,
 				DisplayName:       identity.DisplayName,
@@ -593,10 +651,19 @@ func (h *ExternalProviderHandler) handleLoginAction(c *gin.Context, ctx context.
 				Email:             identity.Email,
 				Timestamp:         time.Now().UnixMilli(),
 			})
+			if err != nil {
+				utils.LogErrorCtx(c.Request.Context(), h.Spec.LogModule, "handleLoginAction", err, "Failed to save pending link")
+				if returnURL != "" {
+					RedirectWithError(c, h.BaseURL, paths.PathAccountLogin+"?return="+url.QueryEscape(returnURL), "oauth_error")
+				} else {
+					RedirectWithError(c, h.BaseURL, paths.PathAccountLogin, "oauth_error")
+				}
+				return
+			}
 
 			utils.LogInfoCtx(c.Request.Context(), h.Spec.LogModule, "Found existing user with same email, redirecting to confirm", "email", identity.Email, "user_uid", existingUser.UID)
 			utils.SetLinkTokenCookieGin(c, linkToken)
-			c.Redirect(http.StatusFound, h.BaseURL+paths.PathAccountLink)
+			c.Redirect(http.StatusFound, h.BaseURL+paths.PathAccountLink+"?provider="+url.QueryEscape(h.Spec.RoutePath))
 			return
 		}
 	}
@@ -611,7 +678,7 @@ func (h *ExternalProviderHandler) handleLoginAction(c *gin.Context, ctx context.
 		return
 	}
 
-	accessToken, refreshToken, err := h.SessionService.GenerateTokens(c.Request.Context(), user.UID, false)
+	accessToken, refreshToken, familyID, err := h.SessionService.GenerateTokens(c.Request.Context(), user.UID, false)
 	if err != nil {
 		utils.LogErrorCtx(c.Request.Context(), h.Spec.LogModule, "handleLoginAction", err, "user_uid", user.UID)
 		if returnURL != "" {
@@ -624,6 +691,7 @@ func (h *ExternalProviderHandler) handleLoginAction(c *gin.Context, ctx context.
 
 	SetAuthCookie(c, accessToken)
 	utils.SetRefreshTokenCookieGin(c, refreshToken)
+	h.checkLoginRisk(c, user, familyID)
 	utils.LogInfoCtx(c.Request.Context(), h.Spec.LogModule, h.Spec.Name+" login successful", "username", user.Username, "user_uid", user.UID)
 	safeReturn := SafeReturnURL(returnURL, h.BaseURL, "")
 	if safeReturn != "" {
@@ -632,3 +700,26 @@ func (h *ExternalProviderHandler) handleLoginAction(c *gin.Context, ctx context.
 		c.Redirect(http.StatusFound, h.BaseURL+paths.PathAccountDashboard)
 	}
 }
+
+// checkLoginRisk 对第三方登录新开的会话执行新设备登录检查（未配置登录提醒时跳过）
+func (h *ExternalProviderHandler) checkLoginRisk(c *gin.Context, user *models.User, familyID string) {
+	if h.LoginRisk == nil {
+		return
+	}
+	h.LoginRisk.CheckLogin(c.Request.Context(), user.UID, familyID, utils.ClientInfoFrom(c.Request.Context()))
+}
+
+// saveLink 持久化绑定关系
+func (h *ExternalProviderHandler) saveLink(ctx context.Context, userUID string, identity ProviderIdentity) error {
+	return h.Spec.SaveLink(ctx, userUID, identity)
+}
+
+// saveProfile 登录时同步 Provider 侧资料
+func (h *ExternalProviderHandler) saveProfile(ctx context.Context, userUID string, identity ProviderIdentity) error {
+	return h.Spec.SaveProfile(ctx, userUID, identity)
+}
+
+// removeLink 解除绑定关系
+func (h *ExternalProviderHandler) removeLink(ctx context.Context, user *models.User) error {
+	return h.Spec.RemoveLink(ctx, user)
+}
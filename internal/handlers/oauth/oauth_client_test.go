@@ -0,0 +1,41 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"auth-system/internal/services"
+	"auth-system/internal/testutil"
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthLinkRejectsImpersonation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	states := NewMemoryStateStore()
+	h := &ExternalProviderHandler{
+		SessionService: &testutil.FakeSessionManager{VerifyResult: &services.Claims{UID: "target-uid", SID: "fam-imp", Act: &services.ActorClaim{Sub: "uid-root"}}},
+		States:         states,
+		BaseURL:        "https://auth.test",
+		Spec: ProviderSpec{
+			LogModule:    "OAUTH-TEST",
+			Name:         "Test",
+			IsConfigured: func() bool { return true },
+			BuildAuthURL: func(state, _ string) string { return "https://idp.test/authorize?state=" + state },
+		},
+	}
+
+	r := gin.New()
+	r.GET("/auth", h.Auth)
+	req := httptest.NewRequest(http.MethodGet, "/auth?action=link", nil)
+	req.AddCookie(&http.Cookie{Name: utils.TokenCookieName, Value: "impersonation-token"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusFound || !strings.Contains(w.Header().Get("Location"), "error=impersonation_forbidden") {
+		t.Errorf("status = %d location = %q, want redirect with impersonation_forbidden", w.Code, w.Header().Get("Location"))
+	}
+}
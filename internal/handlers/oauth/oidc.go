@@ -0,0 +1,244 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"auth-system/internal/middleware"
+	"auth-system/internal/models"
+	"auth-system/internal/paths"
+	"auth-system/internal/services"
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	oidcJWKSPath       = "/oauth/jwks"
+	oidcEndSessionPath = "/oauth/logout"
+
+	// oidcMetadataMaxAge 发现文档与 JWKS 的缓存时间（秒），密钥轮换后最迟 1 小时生效
+	oidcMetadataMaxAge = 3600
+)
+
+// OpenIDConfiguration OIDC 发现文档（OpenID Connect Discovery 1.0）
+// GET /.well-known/openid-configuration
+func (h *OAuthProviderHandler) OpenIDConfiguration(c *gin.Context) {
+	scopes := make([]string, 0, len(validScopes))
+	for s := range validScopes {
+		scopes = append(scopes, s)
+	}
+	slices.Sort(scopes)
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", oidcMetadataMaxAge))
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                        h.baseURL,
+		"authorization_endpoint":                        h.baseURL + "/oauth/authorize",
+		"token_endpoint":                                h.baseURL + "/oauth/token",
+		"userinfo_endpoint":                             h.baseURL + "/oauth/userinfo",
+		"revocation_endpoint":                           h.baseURL + "/oauth/revoke",
+		"introspection_endpoint":                        h.baseURL + "/oauth/introspect",
+		"device_authorization_endpoint":                 h.baseURL + "/oauth/device_authorization",
+		"end_session_endpoint":                          h.baseURL + oidcEndSessionPath,
+		"jwks_uri":                                      h.baseURL + oidcJWKSPath,
+		"scopes_supported":                              scopes,
+		"response_types_supported":                      []string{"code"},
+		"grant_types_supported":                         []string{"authorization_code", "refresh_token", "client_credentials", grantTypeDeviceCode},
+		"subject_types_supported":                       []string{"public"},
+		"id_token_signing_alg_values_supported":         []string{"ES256"},
+		"token_endpoint_auth_methods_supported":         []string{"client_secret_post"},
+		"introspection_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"code_challenge_methods_supported":              []string{"S256", "plain"},
+		"claims_supported":                              []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "username", "avatar_url", "email", "email_verified", "roles", "groups"},
+	})
+}
+
+// JWKS 公钥集合端点，供 RP 验证 ID Token 签名
+// GET /oauth/jwks
+func (h *OAuthProviderHandler) JWKS(c *gin.Context) {
+	if h.idTokenSigner == nil {
+		utils.LogErrorCtx(c.Request.Context(), "OAUTH-PROVIDER", "JWKS", fmt.Errorf("id token signer is nil"))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":             "server_error",
+			"error_description": "Signing key unavailable",
+		})
+		return
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", oidcMetadataMaxAge))
+	c.JSON(http.StatusOK, h.idTokenSigner.JWKS())
+}
+
+// EndSession RP 发起的登出端点（OpenID Connect RP-Initiated Logout 1.0）
+// GET/POST /oauth/logout
+// 仅当 id_token_hint 验签通过且属于当前登录用户时结束会话，避免第三方页面诱导登出；
+// post_logout_redirect_uri 须与客户端登记的登出回调地址完全一致，未提供时回到登录页
+func (h *OAuthProviderHandler) EndSession(c *gin.Context) {
+	idTokenHint := c.Request.FormValue("id_token_hint")
+	clientID := c.Request.FormValue("client_id")
+	redirectURI := c.Request.FormValue("post_logout_redirect_uri")
+	state := c.Request.FormValue("state")
+
+	var hint *services.IDTokenClaims
+	if idTokenHint != "" {
+		claims, err := h.verifyIDTokenHint(idTokenHint)
+		if err != nil {
+			utils.LogWarnCtx(c.Request.Context(), "OAUTH-PROVIDER", "Invalid id_token_hint", "error", err)
+			h.redirectToErrorPage(c, "invalid_request", "Invalid id_token_hint")
+			return
+		}
+		if clientID == "" {
+			clientID = claims.Audience[0]
+		} else if !slices.Contains(claims.Audience, clientID) {
+			h.redirectToErrorPage(c, "invalid_request", "client_id does not match id_token_hint")
+			return
+		}
+		hint = claims
+	}
+
+	var client *models.OAuthClient
+	if clientID != "" {
+		var err error
+		client, err = h.oauthService.ValidateClientID(c.Request.Context(), clientID)
+		if err != nil {
+			utils.LogWarnCtx(c.Request.Context(), "OAUTH-PROVIDER", "Invalid client_id in end session", "client_id", clientID)
+			h.redirectToErrorPage(c, "invalid_client", "Invalid client_id")
+			return
+		}
+	}
+
+	if redirectURI != "" {
+		if client == nil {
+			h.redirectToErrorPage(c, "invalid_request", "Missing client_id or id_token_hint")
+			return
+		}
+		if !slices.Contains(client.PostLogoutRedirectURIs, redirectURI) {
+			utils.LogWarnCtx(c.Request.Context(), "OAUTH-PROVIDER", "Invalid post_logout_redirect_uri", "client_id", client.ClientID, "post_logout_redirect_uri", redirectURI)
+			h.redirectToErrorPage(c, "invalid_request", "Invalid post_logout_redirect_uri")
+			return
+		}
+	}
+
+	userUID, ok := middleware.GetUID(c)
+	switch {
+	case !ok || userUID == "":
+		utils.LogInfoCtx(c.Request.Context(), "OAUTH-PROVIDER", "End session without active session", "client_id", clientID)
+	case hint == nil || hint.Subject != userUID:
+		utils.LogInfoCtx(c.Request.Context(), "OAUTH-PROVIDER", "End session kept current session: id_token_hint missing or for another user", "user_uid", userUID, "client_id", clientID)
+	default:
+		// 只结束当前浏览器的会话，用户在其他设备上的登录不受影响
+		err := h.sessionService.RevokeTokenFamily(c.Request.Context(), userUID, middleware.GetSessionID(c))
+		if err != nil && !errors.Is(err, services.ErrSessionNotFound) {
+			utils.LogWarnCtx(c.Request.Context(), "OAUTH-PROVIDER", "Failed to revoke session during end session", "user_uid", userUID, "error", err)
+		}
+		utils.ClearTokenCookieGin(c)
+		utils.ClearRefreshTokenCookieGin(c)
+		utils.LogInfoCtx(c.Request.Context(), "OAUTH-PROVIDER", "User logged out by relying party", "user_uid", userUID, "client_id", clientID)
+	}
+
+	if redirectURI == "" {
+		c.Redirect(http.StatusFound, h.baseURL+paths.PathAccountLogin)
+		return
+	}
+
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		h.redirectToErrorPage(c, "invalid_request", "Invalid post_logout_redirect_uri")
+		return
+	}
+	if state != "" {
+		q := u.Query()
+		q.Set("state", state)
+		u.RawQuery = q.Encode()
+	}
+	c.Redirect(http.StatusFound, u.String())
+}
+
+// verifyIDTokenHint 校验 id_token_hint 为本服务签发的 ID Token（access_token 无 sub，不会被误用）
+func (h *OAuthProviderHandler) verifyIDTokenHint(token string) (*services.IDTokenClaims, error) {
+	if h.idTokenSigner == nil {
+		return nil, fmt.Errorf("id token signer is nil")
+	}
+	claims, err := h.idTokenSigner.VerifyIDToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Issuer != h.baseURL || claims.Subject == "" || len(claims.Audience) == 0 {
+		return nil, services.ErrInvalidIDToken
+	}
+	return claims, nil
+}
+
+// issueIDToken 为授权码换取的 Token 签发 ID Token，有效期与 Access Token 一致
+func (h *OAuthProviderHandler) issueIDToken(ctx context.Context, user *models.User, client *models.OAuthClient, tokenResp *services.OAuthTokenResponse) (string, error) {
+	if h.idTokenSigner == nil {
+		return "", fmt.Errorf("id token signer is nil")
+	}
+
+	roles, groups, err := h.roleGroupClaims(ctx, user, client, tokenResp.Scope)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := &services.IDTokenClaims{
+		Nonce:  tokenResp.Nonce,
+		Roles:  roles,
+		Groups: groups,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    h.baseURL,
+			Subject:   user.UID,
+			Audience:  jwt.ClaimStrings{client.ClientID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Duration(tokenResp.ExpiresIn) * time.Second)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	// auth_time 为用户登录时间（授权码记录的会话 auth_time），而非授权确认时刻
+	if !tokenResp.AuthTime.IsZero() {
+		claims.AuthTime = tokenResp.AuthTime.Unix()
+	}
+
+	if hasScope(tokenResp.Scope, ScopeEmail) {
+		verified := true
+		claims.Email = user.Email
+		claims.EmailVerified = &verified
+	}
+
+	return h.idTokenSigner.SignIDToken(claims)
+}
+
+// roleGroupClaims 计算 roles / groups claim：roles 为用户的内置角色名，groups 为所属用户组名，
+// 均按客户端的 claim 映射转换；对应 scope 未授权时返回 nil
+func (h *OAuthProviderHandler) roleGroupClaims(ctx context.Context, user *models.User, client *models.OAuthClient, scope string) ([]string, []string, error) {
+	var roles, groups []string
+	if hasScope(scope, ScopeRoles) {
+		roles = client.ClaimMapping.MapRoles(user.Role)
+	}
+	if hasScope(scope, ScopeGroups) {
+		var names []string
+		if h.userGroupRepo != nil {
+			userGroups, err := h.userGroupRepo.FindByUser(ctx, user.UID)
+			if err != nil {
+				return nil, nil, fmt.Errorf("find user groups: %w", err)
+			}
+			for _, group := range userGroups {
+				names = append(names, group.Name)
+			}
+		}
+		groups = client.ClaimMapping.MapGroups(names)
+	}
+	return roles, groups, nil
+}
+
+// hasScope 判断空格分隔的 scope 字符串中是否包含指定 scope
+func hasScope(scope, target string) bool {
+	return slices.Contains(strings.Fields(scope), target)
+}
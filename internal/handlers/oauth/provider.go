@@ -1,8 +1,10 @@
 package oauth
 
 import (
+	"errors"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 
 	"auth-system/internal/middleware"
@@ -18,13 +20,20 @@ const (
 	ScopeOpenID  = "openid"
 	ScopeProfile = "profile"
 	ScopeEmail   = "email"
+	ScopeRoles   = "roles"
+	ScopeGroups  = "groups"
+
+	// maxNonceLength 与 oauth_auth_codes.nonce 列宽一致
+	maxNonceLength = 255
 )
 
-// 有效的 scope 集合
+// 内置 scope 集合（发现文档中公开；自定义 scope 由管理员按客户端配置）
 var validScopes = map[string]bool{
 	ScopeOpenID:  true,
 	ScopeProfile: true,
 	ScopeEmail:   true,
+	ScopeRoles:   true,
+	ScopeGroups:  true,
 }
 
 // acceptsJSON 判断请求是否期望 JSON 响应
@@ -54,8 +63,10 @@ type OAuthProviderHandler struct {
 	oauthService   services.OAuthProviderStore
 	userRepo       models.UserReader
 	userLogRepo    models.UserLogStore
+	userGroupRepo  models.UserGroupReader
 	userCache      services.UserCacheStore
 	sessionService services.SessionManager
+	idTokenSigner  services.IDTokenSigner
 	baseURL        string
 }
 
@@ -64,16 +75,20 @@ func NewOAuthProviderHandler(
 	oauthService services.OAuthProviderStore,
 	userRepo models.UserReader,
 	userLogRepo models.UserLogStore,
+	userGroupRepo models.UserGroupReader,
 	userCache services.UserCacheStore,
 	sessionService services.SessionManager,
+	idTokenSigner services.IDTokenSigner,
 	baseURL string,
 ) *OAuthProviderHandler {
 	return &OAuthProviderHandler{
 		oauthService:   oauthService,
 		userRepo:       userRepo,
 		userLogRepo:    userLogRepo,
+		userGroupRepo:  userGroupRepo,
 		userCache:      userCache,
 		sessionService: sessionService,
+		idTokenSigner:  idTokenSigner,
 		baseURL:        baseURL,
 	}
 }
@@ -88,6 +103,7 @@ func (h *OAuthProviderHandler) Authorize(c *gin.Context) {
 	state := c.Query("state")
 	codeChallenge := c.Query("code_challenge")
 	codeChallengeMethod := c.Query("code_challenge_method")
+	nonce := c.Query("nonce")
 
 	if codeChallenge == "" {
 		h.redirectToErrorPage(c, "invalid_request", "Missing code_challenge parameter")
@@ -116,7 +132,7 @@ func (h *OAuthProviderHandler) Authorize(c *gin.Context) {
 	}
 
 	if !h.oauthService.ValidateRedirectURI(client, redirectURI) {
-		utils.LogWarnCtx(c.Request.Context(), "OAUTH-PROVIDER", "Invalid redirect_uri", "redirect_uri", redirectURI, "expected", client.RedirectURI)
+		utils.LogWarnCtx(c.Request.Context(), "OAUTH-PROVIDER", "Invalid redirect_uri", "redirect_uri", redirectURI, "registered", client.RedirectURIs)
 		h.redirectToErrorPage(c, "invalid_request", "Invalid redirect_uri")
 		return
 	}
@@ -131,16 +147,21 @@ func (h *OAuthProviderHandler) Authorize(c *gin.Context) {
 		return
 	}
 
-	normalizedScope := h.normalizeScope(scope)
+	normalizedScope := h.normalizeScope(client, scope)
 	if normalizedScope == "" {
 		h.redirectWithError(c, redirectURI, state, "invalid_scope", "Invalid scope")
 		return
 	}
 
+	if len(nonce) > maxNonceLength {
+		h.redirectWithError(c, redirectURI, state, "invalid_request", "nonce too long")
+		return
+	}
+
 	userUID, ok := middleware.GetUID(c)
 	if !ok || userUID == "" {
 		// 未登录，重定向到登录页面，登录后返回
-		returnURL := h.buildAuthorizeURL(clientID, redirectURI, responseType, scope, state, codeChallenge, codeChallengeMethod)
+		returnURL := h.buildAuthorizeURL(clientID, redirectURI, responseType, scope, state, codeChallenge, codeChallengeMethod, nonce)
 		loginURL := h.baseURL + paths.PathAccountLogin + "?return=" + url.QueryEscape(returnURL)
 		c.Redirect(http.StatusFound, loginURL)
 		return
@@ -159,7 +180,7 @@ func (h *OAuthProviderHandler) Authorize(c *gin.Context) {
 		return
 	}
 
-	authPageURL := h.buildAuthPageURL(clientID, redirectURI, normalizedScope, state, codeChallenge, codeChallengeMethod)
+	authPageURL := h.buildAuthPageURL(clientID, redirectURI, normalizedScope, state, codeChallenge, codeChallengeMethod, nonce)
 	c.Redirect(http.StatusFound, authPageURL)
 }
 
@@ -213,25 +234,46 @@ func (h *OAuthProviderHandler) AuthorizeInfo(c *gin.Context) {
 		return
 	}
 
-	normalizedScope := h.normalizeScope(scope)
-
-	avatarURL := user.AvatarURL
-	if avatarURL == "microsoft" && user.MicrosoftAvatarURL.Valid {
-		avatarURL = user.MicrosoftAvatarURL.String
-	}
+	normalizedScope := h.normalizeScope(client, scope)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data": gin.H{
-			"clientName":        client.Name,
-			"clientDescription": client.Description,
-			"scopes":            h.parseScopeList(normalizedScope),
-			"username":          user.Username,
-			"userAvatar":        avatarURL,
-		},
+		"data":    h.buildConsentInfo(c, client, user, normalizedScope),
 	})
 }
 
+// buildConsentInfo 构建授权确认页展示信息（授权码与设备授权共用）
+func (h *OAuthProviderHandler) buildConsentInfo(c *gin.Context, client *models.OAuthClient, user *models.User, scope string) gin.H {
+	scopeList := h.parseScopeList(scope)
+
+	// 自定义 scope 的多语言描述（内置 scope 由前端翻译文件提供）
+	scopeDescriptions := make(map[string]map[string]string)
+	customScopes, err := h.oauthService.GetScopes(c.Request.Context(), scopeList)
+	if err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "OAUTH-PROVIDER", "Failed to load scope descriptions", "error", err)
+	}
+	for _, s := range customScopes {
+		scopeDescriptions[s.Name] = s.Descriptions
+	}
+
+	return gin.H{
+		"clientName":        client.Name,
+		"clientDescription": client.Description,
+		"scopes":            scopeList,
+		"scopeDescriptions": scopeDescriptions,
+		"username":          user.Username,
+		"userAvatar":        userAvatarURL(user),
+	}
+}
+
+// userAvatarURL 返回用户头像地址（Microsoft 头像存储为标记值，需替换为实际地址）
+func userAvatarURL(user *models.User) string {
+	if user.AvatarURL == "microsoft" && user.MicrosoftAvatarURL.Valid {
+		return user.MicrosoftAvatarURL.String
+	}
+	return user.AvatarURL
+}
+
 // AuthorizePost 处理授权决定（approve/deny），JSON 模式返回 redirect_url，否则 302 重定向
 // POST /oauth/authorize
 func (h *OAuthProviderHandler) AuthorizePost(c *gin.Context) {
@@ -243,6 +285,7 @@ func (h *OAuthProviderHandler) AuthorizePost(c *gin.Context) {
 	state := c.PostForm("state")
 	codeChallenge := c.PostForm("code_challenge")
 	codeChallengeMethod := c.PostForm("code_challenge_method")
+	nonce := c.PostForm("nonce")
 	decision := c.PostForm("decision")
 
 	if clientID == "" || redirectURI == "" || scope == "" {
@@ -288,13 +331,18 @@ func (h *OAuthProviderHandler) AuthorizePost(c *gin.Context) {
 		return
 	}
 
-	normalizedScope := h.normalizeScope(scope)
+	normalizedScope := h.normalizeScope(client, scope)
 	if normalizedScope == "" {
 		h.respondAuthorizeError(c, isJSON, "invalid_scope", redirectURI, state, "Invalid scope")
 		return
 	}
 
-	code, err := h.oauthService.CreateAuthorizationCode(c.Request.Context(), clientID, userUID, redirectURI, normalizedScope, codeChallenge, codeChallengeMethod)
+	if len(nonce) > maxNonceLength {
+		h.respondAuthorizeError(c, isJSON, "invalid_request", redirectURI, state, "nonce too long")
+		return
+	}
+
+	code, err := h.oauthService.CreateAuthorizationCode(c.Request.Context(), clientID, userUID, redirectURI, normalizedScope, codeChallenge, codeChallengeMethod, nonce, middleware.GetAuthTime(c))
 	if err != nil {
 		utils.LogErrorCtx(c.Request.Context(), "OAUTH-PROVIDER", "AuthorizePost", err, "user_uid", userUID, "client_id", clientID)
 		h.respondAuthorizeError(c, isJSON, "server_error", redirectURI, state, "Failed to create authorization code")
@@ -312,7 +360,7 @@ func (h *OAuthProviderHandler) AuthorizePost(c *gin.Context) {
 	h.respondAuthorizeSuccess(c, isJSON, redirectURL)
 }
 
-// Token 端点，支持 authorization_code 和 refresh_token 两种 grant_type
+// Token 端点，支持 authorization_code、refresh_token、client_credentials 和设备码（RFC 8628）四种 grant_type
 // POST /oauth/token
 func (h *OAuthProviderHandler) Token(c *gin.Context) {
 	grantType := c.PostForm("grant_type")
@@ -324,7 +372,7 @@ func (h *OAuthProviderHandler) Token(c *gin.Context) {
 		return
 	}
 
-	_, err := h.oauthService.ValidateClient(c.Request.Context(), clientID, clientSecret)
+	client, err := h.oauthService.ValidateClient(c.Request.Context(), clientID, clientSecret)
 	if err != nil {
 		utils.LogWarnCtx(c.Request.Context(), "OAUTH-PROVIDER", "Client validation failed", "client_id", clientID)
 		h.respondTokenError(c, http.StatusUnauthorized, "invalid_client", "Invalid client credentials")
@@ -333,16 +381,21 @@ func (h *OAuthProviderHandler) Token(c *gin.Context) {
 
 	switch grantType {
 	case "authorization_code":
-		h.handleAuthorizationCodeGrant(c, clientID)
+		h.handleAuthorizationCodeGrant(c, client)
 	case "refresh_token":
 		h.handleRefreshTokenGrant(c, clientID)
+	case "client_credentials":
+		h.handleClientCredentialsGrant(c, client)
+	case grantTypeDeviceCode:
+		h.handleDeviceCodeGrant(c, client)
 	default:
 		h.respondTokenError(c, http.StatusBadRequest, "unsupported_grant_type", "Unsupported grant type")
 	}
 }
 
 // handleAuthorizationCodeGrant 处理授权码换取 Token
-func (h *OAuthProviderHandler) handleAuthorizationCodeGrant(c *gin.Context, clientID string) {
+func (h *OAuthProviderHandler) handleAuthorizationCodeGrant(c *gin.Context, client *models.OAuthClient) {
+	clientID := client.ClientID
 	code := c.PostForm("code")
 	redirectURI := c.PostForm("redirect_uri")
 	codeVerifier := c.PostForm("code_verifier")
@@ -371,6 +424,16 @@ func (h *OAuthProviderHandler) handleAuthorizationCodeGrant(c *gin.Context, clie
 		return
 	}
 
+	if hasScope(tokenResp.Scope, ScopeOpenID) {
+		idToken, err := h.issueIDToken(c.Request.Context(), user, client, tokenResp)
+		if err != nil {
+			utils.LogErrorCtx(c.Request.Context(), "OAUTH-PROVIDER", "handleAuthorizationCodeGrant", err, "client_id", clientID, "user_uid", userUID)
+			h.respondTokenError(c, http.StatusInternalServerError, "server_error", "Failed to issue id_token")
+			return
+		}
+		tokenResp.IDToken = idToken
+	}
+
 	utils.LogInfoCtx(c.Request.Context(), "OAUTH-PROVIDER", "Token issued", "client_id", clientID, "user_uid", userUID)
 	c.JSON(http.StatusOK, tokenResp)
 }
@@ -402,7 +465,27 @@ func (h *OAuthProviderHandler) handleRefreshTokenGrant(c *gin.Context, clientID
 	c.JSON(http.StatusOK, tokenResp)
 }
 
-// UserInfo 用户信息端点，根据 scope（openid/profile/email）返回对应的用户信息
+// handleClientCredentialsGrant 处理客户端凭据授权（机器对机器，无用户参与）
+func (h *OAuthProviderHandler) handleClientCredentialsGrant(c *gin.Context, client *models.OAuthClient) {
+	tokenResp, err := h.oauthService.IssueClientCredentialsToken(c.Request.Context(), client, c.PostForm("scope"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrOAuthUnauthorizedClient):
+			h.respondTokenError(c, http.StatusBadRequest, "unauthorized_client", "Client is not allowed to use client_credentials")
+		case errors.Is(err, services.ErrOAuthInvalidScope):
+			h.respondTokenError(c, http.StatusBadRequest, "invalid_scope", "Requested scope is not allowed for this client")
+		default:
+			utils.LogErrorCtx(c.Request.Context(), "OAUTH-PROVIDER", "handleClientCredentialsGrant", err, "client_id", client.ClientID)
+			h.respondTokenError(c, http.StatusInternalServerError, "server_error", "Failed to issue token")
+		}
+		return
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "OAUTH-PROVIDER", "Client credentials token issued", "client_id", client.ClientID, "scope", tokenResp.Scope)
+	c.JSON(http.StatusOK, tokenResp)
+}
+
+// UserInfo 用户信息端点，根据 scope（openid/profile/email/roles/groups）返回对应的用户信息
 // GET /oauth/userinfo
 func (h *OAuthProviderHandler) UserInfo(c *gin.Context) {
 	authHeader := c.GetHeader("Authorization")
@@ -424,6 +507,13 @@ func (h *OAuthProviderHandler) UserInfo(c *gin.Context) {
 		return
 	}
 
+	// client_credentials 签发的 Token 不代表任何用户
+	if tokenInfo.UserUID == "" {
+		utils.LogWarnCtx(c.Request.Context(), "OAUTH-PROVIDER", "Client token used at userinfo", "client_id", tokenInfo.ClientID)
+		h.respondUserInfoError(c, http.StatusUnauthorized, "invalid_token", "Access token is not associated with a user")
+		return
+	}
+
 	user, err := h.userCache.GetOrLoad(c.Request.Context(), tokenInfo.UserUID, h.userRepo.FindByUID)
 	if err != nil {
 		utils.LogErrorCtx(c.Request.Context(), "OAUTH-PROVIDER", "UserInfo", err, "user_uid", tokenInfo.UserUID)
@@ -438,10 +528,33 @@ func (h *OAuthProviderHandler) UserInfo(c *gin.Context) {
 	}
 
 	response := h.buildUserInfoResponse(user, tokenInfo.Scope)
+
+	// roles / groups 的取值依赖客户端的 claim 映射
+	if hasScope(tokenInfo.Scope, ScopeRoles) || hasScope(tokenInfo.Scope, ScopeGroups) {
+		client, err := h.oauthService.ValidateClientID(c.Request.Context(), tokenInfo.ClientID)
+		if err != nil {
+			utils.LogWarnCtx(c.Request.Context(), "OAUTH-PROVIDER", "Client unavailable at userinfo", "client_id", tokenInfo.ClientID, "error", err)
+			h.respondUserInfoError(c, http.StatusUnauthorized, "invalid_token", "Client is disabled or not found")
+			return
+		}
+		roles, groups, err := h.roleGroupClaims(c.Request.Context(), user, client, tokenInfo.Scope)
+		if err != nil {
+			utils.LogErrorCtx(c.Request.Context(), "OAUTH-PROVIDER", "UserInfo", err, "user_uid", tokenInfo.UserUID, "client_id", tokenInfo.ClientID)
+			h.respondUserInfoError(c, http.StatusInternalServerError, "server_error", "Failed to get user info")
+			return
+		}
+		if roles != nil {
+			response["roles"] = roles
+		}
+		if groups != nil {
+			response["groups"] = groups
+		}
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
-// buildUserInfoResponse 根据 scope 构建用户信息响应
+// buildUserInfoResponse 根据 scope 构建用户信息响应（roles / groups 由 roleGroupClaims 按客户端映射补充）
 func (h *OAuthProviderHandler) buildUserInfoResponse(user *models.User, scope string) gin.H {
 	response := gin.H{}
 	scopes := strings.SplitSeq(scope, " ")
@@ -452,13 +565,11 @@ func (h *OAuthProviderHandler) buildUserInfoResponse(user *models.User, scope st
 			response["sub"] = user.UID
 		case ScopeProfile:
 			response["username"] = user.Username
-			avatarURL := user.AvatarURL
-			if avatarURL == "microsoft" && user.MicrosoftAvatarURL.Valid {
-				avatarURL = user.MicrosoftAvatarURL.String
-			}
-			response["avatar_url"] = avatarURL
+			response["avatar_url"] = userAvatarURL(user)
 		case ScopeEmail:
 			response["email"] = user.Email
+			// 注册需邮箱验证码、外部登录仅接受 Provider 已验证邮箱，系统内邮箱均已验证
+			response["email_verified"] = true
 		}
 	}
 
@@ -481,13 +592,84 @@ func (h *OAuthProviderHandler) Revoke(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
-// normalizeScope 规范化 scope 字符串，过滤无效 scope，返回空字符串表示全部无效
-func (h *OAuthProviderHandler) normalizeScope(scope string) string {
+// Introspect Token 内省端点（RFC 7662），供资源服务器校验不透明 Token
+// 调用方需以客户端凭据认证（HTTP Basic 或表单 client_id/client_secret）；
+// Token 不存在、已过期或所属用户已封禁时仅返回 {"active": false}，不泄露原因。
+// 客户端只能内省签发给自己的 Token；client_scopes 含 introspect 的资源服务器另可内省其他客户端的 Access Token，
+// Refresh Token 始终只对所属客户端可见
+// POST /oauth/introspect
+func (h *OAuthProviderHandler) Introspect(c *gin.Context) {
+	clientID, clientSecret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientID = c.PostForm("client_id")
+		clientSecret = c.PostForm("client_secret")
+	}
+
+	if clientID == "" || clientSecret == "" {
+		h.respondTokenError(c, http.StatusUnauthorized, "invalid_client", "Missing client credentials")
+		return
+	}
+
+	client, err := h.oauthService.ValidateClient(c.Request.Context(), clientID, clientSecret)
+	if err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "OAUTH-PROVIDER", "Client validation failed for introspection", "client_id", clientID)
+		h.respondTokenError(c, http.StatusUnauthorized, "invalid_client", "Invalid client credentials")
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		h.respondTokenError(c, http.StatusBadRequest, "invalid_request", "Missing token parameter")
+		return
+	}
+
+	info, err := h.oauthService.IntrospectToken(c.Request.Context(), token, c.PostForm("token_type_hint"))
+	if err != nil {
+		if !errors.Is(err, services.ErrOAuthTokenNotFound) && !errors.Is(err, services.ErrOAuthTokenExpired) {
+			utils.LogErrorCtx(c.Request.Context(), "OAUTH-PROVIDER", "Introspect", err, "client_id", clientID)
+		}
+		c.JSON(http.StatusOK, gin.H{"active": false})
+		return
+	}
+
+	if info.ClientID != client.ClientID &&
+		(info.TokenType == services.OAuthTokenTypeRefresh || !slices.Contains(client.ClientScopes, models.OAuthScopeIntrospect)) {
+		utils.LogDebugCtx(c.Request.Context(), "OAUTH-PROVIDER", "Cross-client introspection denied", "client_id", client.ClientID, "token_client_id", info.ClientID)
+		c.JSON(http.StatusOK, gin.H{"active": false})
+		return
+	}
+
+	resp := gin.H{
+		"active":     true,
+		"scope":      info.Scope,
+		"client_id":  info.ClientID,
+		"exp":        info.ExpiresAt.Unix(),
+		"iat":        info.IssuedAt.Unix(),
+		"token_type": info.TokenType,
+	}
+
+	// client_credentials 签发的 Token 无用户，不返回 sub
+	if info.UserUID != "" {
+		user, err := h.userCache.GetOrLoad(c.Request.Context(), info.UserUID, h.userRepo.FindByUID)
+		if err != nil || user.CheckBanned() {
+			utils.LogDebugCtx(c.Request.Context(), "OAUTH-PROVIDER", "Introspected token belongs to banned or missing user", "user_uid", info.UserUID)
+			c.JSON(http.StatusOK, gin.H{"active": false})
+			return
+		}
+		resp["sub"] = info.UserUID
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// normalizeScope 规范化 scope 字符串，仅保留客户端 allowed_scopes 白名单内的 scope（去重），
+// 返回空字符串表示全部无效
+func (h *OAuthProviderHandler) normalizeScope(client *models.OAuthClient, scope string) string {
 	parts := strings.Fields(scope)
 	validParts := make([]string, 0, len(parts))
 
 	for _, part := range parts {
-		if validScopes[part] {
+		if slices.Contains(client.AllowedScopes, part) && !slices.Contains(validParts, part) {
 			validParts = append(validParts, part)
 		}
 	}
@@ -505,7 +687,7 @@ func (h *OAuthProviderHandler) parseScopeList(scope string) []string {
 }
 
 // buildAuthorizeURL 构建授权 URL
-func (h *OAuthProviderHandler) buildAuthorizeURL(clientID, redirectURI, responseType, scope, state, codeChallenge, codeChallengeMethod string) string {
+func (h *OAuthProviderHandler) buildAuthorizeURL(clientID, redirectURI, responseType, scope, state, codeChallenge, codeChallengeMethod, nonce string) string {
 	params := url.Values{}
 	params.Set("client_id", clientID)
 	params.Set("redirect_uri", redirectURI)
@@ -520,6 +702,9 @@ func (h *OAuthProviderHandler) buildAuthorizeURL(clientID, redirectURI, response
 			params.Set("code_challenge_method", codeChallengeMethod)
 		}
 	}
+	if nonce != "" {
+		params.Set("nonce", nonce)
+	}
 	return h.baseURL + "/oauth/authorize?" + params.Encode()
 }
 
@@ -613,7 +798,7 @@ func (h *OAuthProviderHandler) redirectToErrorPage(c *gin.Context, errorCode, er
 }
 
 // buildAuthPageURL 构建授权页面 URL
-func (h *OAuthProviderHandler) buildAuthPageURL(clientID, redirectURI, scope, state, codeChallenge, codeChallengeMethod string) string {
+func (h *OAuthProviderHandler) buildAuthPageURL(clientID, redirectURI, scope, state, codeChallenge, codeChallengeMethod, nonce string) string {
 	params := url.Values{}
 	params.Set("client_id", clientID)
 	params.Set("redirect_uri", redirectURI)
@@ -627,6 +812,9 @@ func (h *OAuthProviderHandler) buildAuthPageURL(clientID, redirectURI, scope, st
 			params.Set("code_challenge_method", codeChallengeMethod)
 		}
 	}
+	if nonce != "" {
+		params.Set("nonce", nonce)
+	}
 	return h.baseURL + paths.PathAccountOAuth + "?" + params.Encode()
 }
 
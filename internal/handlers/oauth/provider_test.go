@@ -5,26 +5,35 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"auth-system/internal/middleware"
 	"auth-system/internal/models"
 	"auth-system/internal/services"
 	"auth-system/internal/testutil"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 var (
 	errTestInvalidClient = errors.New("invalid client")
 	errTestInvalidGrant  = errors.New("invalid grant")
 	errTestInvalidToken  = errors.New("invalid token")
+	errTestSignFailed    = errors.New("sign failed")
 )
 
 // providerTestDeps 测试依赖集合
 type providerTestDeps struct {
 	oauth    *testutil.FakeOAuthProvider
 	userRepo *testutil.FakeUserRepo
+	signer   *testutil.FakeIDTokenSigner
+	groups   *testutil.FakeUserGroupRepo
+	sessions *testutil.FakeSessionManager
 }
 
 func newTestProvider(t *testing.T) (*OAuthProviderHandler, *providerTestDeps) {
@@ -32,16 +41,21 @@ func newTestProvider(t *testing.T) (*OAuthProviderHandler, *providerTestDeps) {
 	gin.SetMode(gin.TestMode)
 
 	deps := &providerTestDeps{
-		oauth:    &testutil.FakeOAuthProvider{},
+		oauth:    &testutil.FakeOAuthProvider{Client: &models.OAuthClient{ClientID: "client-1"}},
 		userRepo: testutil.NewFakeUserRepo(),
+		signer:   &testutil.FakeIDTokenSigner{},
+		groups:   testutil.NewFakeUserGroupRepo(),
+		sessions: &testutil.FakeSessionManager{},
 	}
 
 	h := NewOAuthProviderHandler(
 		deps.oauth,
 		deps.userRepo,
 		&testutil.FakeUserLogStore{},
+		deps.groups,
 		&testutil.FakeUserCache{},
-		&testutil.FakeSessionManager{},
+		deps.sessions,
+		deps.signer,
 		"https://test.local",
 	)
 	return h, deps
@@ -88,6 +102,118 @@ func TestTokenAuthorizationCodeGrant(t *testing.T) {
 	}
 }
 
+func TestTokenAuthorizationCodeGrantIDToken(t *testing.T) {
+	h, deps := newTestProvider(t)
+	resp := tokenResp()
+	resp.Scope = "openid email"
+	resp.Nonce = "n-0S6_WzA2Mj"
+	resp.AuthTime = time.Unix(1700000000, 0)
+	deps.oauth.ExchangeResp = resp
+	deps.oauth.ExchangeUserUID = "uid-1"
+	deps.userRepo.Seed(&models.User{UID: "uid-1", Username: "alice", Email: "a@b.com"})
+
+	w := postForm(h.Token, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"client-1"},
+		"client_secret": {"secret-1"},
+		"code":          {"auth-code"},
+		"redirect_uri":  {"https://app.example.com/cb"},
+		"code_verifier": {"verifier"},
+	})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"id_token":"fake-id-token"`) {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "n-0S6_WzA2Mj") {
+		t.Errorf("nonce must not leak into token response body: %s", w.Body.String())
+	}
+
+	if len(deps.signer.Signed) != 1 {
+		t.Fatalf("signed = %d, want 1", len(deps.signer.Signed))
+	}
+	claims := deps.signer.Signed[0]
+	if claims.Subject != "uid-1" || claims.Issuer != "https://test.local" {
+		t.Errorf("sub/iss = %q/%q", claims.Subject, claims.Issuer)
+	}
+	if len(claims.Audience) != 1 || claims.Audience[0] != "client-1" {
+		t.Errorf("aud = %v", claims.Audience)
+	}
+	if claims.Nonce != "n-0S6_WzA2Mj" || claims.AuthTime != 1700000000 {
+		t.Errorf("nonce/auth_time = %q/%d", claims.Nonce, claims.AuthTime)
+	}
+	if claims.Email != "a@b.com" || claims.EmailVerified == nil || !*claims.EmailVerified {
+		t.Errorf("email claims = %q/%v", claims.Email, claims.EmailVerified)
+	}
+}
+
+func TestTokenIDTokenRolesAndGroups(t *testing.T) {
+	h, deps := newTestProvider(t)
+	resp := tokenResp()
+	resp.Scope = "openid roles groups"
+	deps.oauth.ExchangeResp = resp
+	deps.oauth.ExchangeUserUID = "uid-1"
+	deps.userRepo.Seed(&models.User{UID: "uid-1", Username: "alice", Email: "a@b.com", Role: models.RoleSuperAdmin})
+	deps.groups.Seed(&models.UserGroup{Name: "support"}, "uid-1")
+	deps.groups.Seed(&models.UserGroup{Name: "billing"}, "uid-1")
+
+	w := postForm(h.Token, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"client-1"},
+		"client_secret": {"secret-1"},
+		"code":          {"auth-code"},
+		"redirect_uri":  {"https://app.example.com/cb"},
+	})
+	if w.Code != http.StatusOK || len(deps.signer.Signed) != 1 {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+
+	// 客户端未配置映射时原样下发角色名与组名
+	claims := deps.signer.Signed[0]
+	if !slices.Equal(claims.Roles, []string{"super_admin"}) || !slices.Equal(claims.Groups, []string{"billing", "support"}) {
+		t.Errorf("roles/groups = %v/%v", claims.Roles, claims.Groups)
+	}
+}
+
+func TestTokenAuthorizationCodeGrantWithoutOpenID(t *testing.T) {
+	h, deps := newTestProvider(t)
+	resp := tokenResp()
+	resp.Scope = "profile"
+	deps.oauth.ExchangeResp = resp
+	deps.oauth.ExchangeUserUID = "uid-1"
+	deps.userRepo.Seed(&models.User{UID: "uid-1", Username: "alice", Email: "a@b.com"})
+
+	w := postForm(h.Token, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"client-1"},
+		"client_secret": {"secret-1"},
+		"code":          {"auth-code"},
+		"redirect_uri":  {"https://app.example.com/cb"},
+		"code_verifier": {"verifier"},
+	})
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "id_token") {
+		t.Errorf("status = %d body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTokenIDTokenSignFailed(t *testing.T) {
+	h, deps := newTestProvider(t)
+	deps.signer.SignErr = errTestSignFailed
+	deps.oauth.ExchangeResp = tokenResp()
+	deps.oauth.ExchangeUserUID = "uid-1"
+	deps.userRepo.Seed(&models.User{UID: "uid-1", Username: "alice", Email: "a@b.com"})
+
+	w := postForm(h.Token, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"client-1"},
+		"client_secret": {"secret-1"},
+		"code":          {"auth-code"},
+		"redirect_uri":  {"https://app.example.com/cb"},
+		"code_verifier": {"verifier"},
+	})
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "server_error") {
+		t.Errorf("status = %d body = %s", w.Code, w.Body.String())
+	}
+}
+
 func TestTokenMissingClientCredentials(t *testing.T) {
 	h, _ := newTestProvider(t)
 	w := postForm(h.Token, url.Values{"grant_type": {"authorization_code"}})
@@ -187,6 +313,53 @@ func TestTokenRefreshGrant(t *testing.T) {
 	}
 }
 
+func TestTokenClientCredentialsGrant(t *testing.T) {
+	h, deps := newTestProvider(t)
+	deps.oauth.Client = &models.OAuthClient{ClientID: "svc-a", ClientScopes: []string{"billing:read", "billing:write"}}
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"svc-a"},
+		"client_secret": {"secret"},
+		"scope":         {"billing:read"},
+	}
+
+	w := postForm(h.Token, form)
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, `"scope":"billing:read"`) {
+		t.Fatalf("status = %d body = %s", w.Code, body)
+	}
+	// 无用户参与，不签发 refresh_token 与 id_token
+	if strings.Contains(body, "refresh_token") || strings.Contains(body, "id_token") {
+		t.Errorf("client credentials response should carry access token only: %s", body)
+	}
+
+	deps.oauth.ClientCredsErr = services.ErrOAuthInvalidScope
+	if w = postForm(h.Token, form); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_scope") {
+		t.Errorf("invalid scope = %d %s, want 400 invalid_scope", w.Code, w.Body.String())
+	}
+
+	deps.oauth.ClientCredsErr = services.ErrOAuthUnauthorizedClient
+	if w = postForm(h.Token, form); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "unauthorized_client") {
+		t.Errorf("no client scopes = %d %s, want 400 unauthorized_client", w.Code, w.Body.String())
+	}
+}
+
+func TestUserInfoRejectsClientToken(t *testing.T) {
+	h, deps := newTestProvider(t)
+	deps.oauth.AccessToken = &models.OAuthAccessToken{ClientID: "svc-a", Scope: "billing:read"}
+
+	r := gin.New()
+	r.GET("/test", h.UserInfo)
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer client-token")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "invalid_token") {
+		t.Errorf("status = %d body = %s, want 401 invalid_token", w.Code, w.Body.String())
+	}
+}
+
 func TestUserInfoValid(t *testing.T) {
 	h, deps := newTestProvider(t)
 	deps.oauth.AccessToken = &models.OAuthAccessToken{UserUID: "uid-1", Scope: "openid profile"}
@@ -204,6 +377,52 @@ func TestUserInfoValid(t *testing.T) {
 	}
 }
 
+func TestUserInfoRolesAndGroups(t *testing.T) {
+	h, deps := newTestProvider(t)
+	deps.oauth.AccessToken = &models.OAuthAccessToken{ClientID: "client-1", UserUID: "uid-1", Scope: "openid roles groups"}
+	deps.oauth.Client.ClaimMapping = models.OAuthClaimMapping{
+		Roles:  map[string]string{"admin": "Editor"},
+		Groups: map[string]string{"support": "helpdesk"},
+	}
+	deps.userRepo.Seed(&models.User{UID: "uid-1", Username: "alice", Email: "a@b.com", Role: models.RoleAdmin})
+	deps.groups.Seed(&models.UserGroup{Name: "support"}, "uid-1")
+	deps.groups.Seed(&models.UserGroup{Name: "billing"}, "uid-1")
+
+	r := gin.New()
+	r.GET("/test", h.UserInfo)
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// 未出现在映射中的用户组（billing）不下发；未授权 profile 时不返回 username
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, `"roles":["Editor"]`) || !strings.Contains(body, `"groups":["helpdesk"]`) {
+		t.Fatalf("status = %d body = %s", w.Code, body)
+	}
+	if strings.Contains(body, "billing") || strings.Contains(body, "username") {
+		t.Errorf("unexpected claims: %s", body)
+	}
+}
+
+func TestUserInfoGroupLookupFailed(t *testing.T) {
+	h, deps := newTestProvider(t)
+	deps.oauth.AccessToken = &models.OAuthAccessToken{ClientID: "client-1", UserUID: "uid-1", Scope: "openid groups"}
+	deps.userRepo.Seed(&models.User{UID: "uid-1", Username: "alice", Email: "a@b.com"})
+	deps.groups.FindErr = errors.New("db down")
+
+	r := gin.New()
+	r.GET("/test", h.UserInfo)
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "server_error") {
+		t.Errorf("status = %d body = %s, want 500 server_error", w.Code, w.Body.String())
+	}
+}
+
 func TestUserInfoMissingHeader(t *testing.T) {
 	h, _ := newTestProvider(t)
 
@@ -245,3 +464,279 @@ func TestRevoke(t *testing.T) {
 		t.Errorf("revoked = %v", deps.oauth.Revoked)
 	}
 }
+
+func TestIntrospectActiveToken(t *testing.T) {
+	h, deps := newTestProvider(t)
+	deps.oauth.Client = &models.OAuthClient{ClientID: "resource-server", ClientScopes: []string{models.OAuthScopeIntrospect}}
+	deps.userRepo.Seed(&models.User{UID: "uid-1", Username: "alice", Email: "a@b.com"})
+	issued := time.Now().Add(-time.Minute).Truncate(time.Second)
+	deps.oauth.TokenInfo = &services.OAuthTokenInfo{
+		TokenType: services.OAuthTokenTypeAccess,
+		ClientID:  "client-a",
+		UserUID:   "uid-1",
+		Scope:     "openid profile",
+		ExpiresAt: issued.Add(time.Hour),
+		IssuedAt:  issued,
+	}
+
+	r := gin.New()
+	r.POST("/test", h.Introspect)
+	req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(url.Values{"token": {"access-token-123"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("resource-server", "secret")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	for _, want := range []string{`"active":true`, `"client_id":"client-a"`, `"sub":"uid-1"`, `"scope":"openid profile"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %s: %s", want, body)
+		}
+	}
+	if !strings.Contains(body, `"iat":`+strconv.FormatInt(issued.Unix(), 10)) {
+		t.Errorf("iat mismatch: %s", body)
+	}
+}
+
+func TestIntrospectInactiveToken(t *testing.T) {
+	h, deps := newTestProvider(t)
+	form := url.Values{"client_id": {"rs"}, "client_secret": {"secret"}, "token": {"unknown"}}
+
+	w := postForm(h.Introspect, form)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"active":false}` {
+		t.Errorf("unknown token = %d %s, want active=false only", w.Code, w.Body.String())
+	}
+
+	// 所属用户被封禁的 Token 视为无效
+	deps.userRepo.Seed(&models.User{UID: "uid-1", IsBanned: true})
+	deps.oauth.TokenInfo = &services.OAuthTokenInfo{ClientID: "client-1", UserUID: "uid-1", ExpiresAt: time.Now().Add(time.Hour)}
+	w = postForm(h.Introspect, form)
+	if !strings.Contains(w.Body.String(), `"active":false`) {
+		t.Errorf("banned user token = %s, want active=false", w.Body.String())
+	}
+}
+
+func TestIntrospectClientToken(t *testing.T) {
+	h, deps := newTestProvider(t)
+	deps.oauth.Client = &models.OAuthClient{ClientID: "rs", ClientScopes: []string{models.OAuthScopeIntrospect}}
+	deps.oauth.TokenInfo = &services.OAuthTokenInfo{
+		TokenType: services.OAuthTokenTypeAccess,
+		ClientID:  "svc-a",
+		Scope:     "billing:read",
+		ExpiresAt: time.Now().Add(time.Hour),
+		IssuedAt:  time.Now(),
+	}
+
+	w := postForm(h.Introspect, url.Values{"client_id": {"rs"}, "client_secret": {"secret"}, "token": {"client-token"}})
+	body := w.Body.String()
+	if !strings.Contains(body, `"active":true`) || !strings.Contains(body, `"client_id":"svc-a"`) {
+		t.Fatalf("client token = %d %s, want active", w.Code, body)
+	}
+	if strings.Contains(body, `"sub"`) {
+		t.Errorf("client token should not carry sub: %s", body)
+	}
+}
+
+func TestIntrospectCrossClient(t *testing.T) {
+	h, deps := newTestProvider(t)
+	deps.userRepo.Seed(&models.User{UID: "uid-1", Username: "alice", Email: "a@b.com"})
+	form := url.Values{"client_id": {"client-1"}, "client_secret": {"secret"}, "token": {"t"}}
+	info := func(tokenType, clientID string) *services.OAuthTokenInfo {
+		return &services.OAuthTokenInfo{TokenType: tokenType, ClientID: clientID, UserUID: "uid-1", Scope: "openid", ExpiresAt: time.Now().Add(time.Hour), IssuedAt: time.Now()}
+	}
+
+	// 自己的 Token（含 Refresh Token）可内省
+	for _, tokenType := range []string{services.OAuthTokenTypeAccess, services.OAuthTokenTypeRefresh} {
+		deps.oauth.TokenInfo = info(tokenType, "client-1")
+		if w := postForm(h.Introspect, form); !strings.Contains(w.Body.String(), `"active":true`) {
+			t.Errorf("own %s token = %s, want active", tokenType, w.Body.String())
+		}
+	}
+
+	// 普通客户端不能内省其他客户端的任何 Token
+	for _, tokenType := range []string{services.OAuthTokenTypeAccess, services.OAuthTokenTypeRefresh} {
+		deps.oauth.TokenInfo = info(tokenType, "client-2")
+		if w := postForm(h.Introspect, form); strings.TrimSpace(w.Body.String()) != `{"active":false}` {
+			t.Errorf("foreign %s token = %s, want active=false only", tokenType, w.Body.String())
+		}
+	}
+
+	// 资源服务器可内省其他客户端的 Access Token，但不能内省其 Refresh Token
+	deps.oauth.Client.ClientScopes = []string{models.OAuthScopeIntrospect}
+	deps.oauth.TokenInfo = info(services.OAuthTokenTypeAccess, "client-2")
+	if w := postForm(h.Introspect, form); !strings.Contains(w.Body.String(), `"active":true`) {
+		t.Errorf("resource server foreign access token = %s, want active", w.Body.String())
+	}
+	deps.oauth.TokenInfo = info(services.OAuthTokenTypeRefresh, "client-2")
+	if w := postForm(h.Introspect, form); strings.TrimSpace(w.Body.String()) != `{"active":false}` {
+		t.Errorf("resource server foreign refresh token = %s, want active=false only", w.Body.String())
+	}
+}
+
+func TestIntrospectClientAuth(t *testing.T) {
+	h, deps := newTestProvider(t)
+
+	w := postForm(h.Introspect, url.Values{"token": {"t"}})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("missing credentials status = %d, want 401", w.Code)
+	}
+
+	deps.oauth.ValidateErr = errTestInvalidClient
+	w = postForm(h.Introspect, url.Values{"client_id": {"rs"}, "client_secret": {"bad"}, "token": {"t"}})
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "invalid_client") {
+		t.Errorf("invalid client = %d %s, want 401 invalid_client", w.Code, w.Body.String())
+	}
+}
+
+func TestOpenIDConfiguration(t *testing.T) {
+	h, _ := newTestProvider(t)
+
+	r := gin.New()
+	r.GET("/test", h.OpenIDConfiguration)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	for _, want := range []string{
+		`"issuer":"https://test.local"`,
+		`"jwks_uri":"https://test.local/oauth/jwks"`,
+		`"token_endpoint":"https://test.local/oauth/token"`,
+		`"device_authorization_endpoint":"https://test.local/oauth/device_authorization"`,
+		`"end_session_endpoint":"https://test.local/oauth/logout"`,
+		`"id_token_signing_alg_values_supported":["ES256"]`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("body missing %s: %s", want, w.Body.String())
+		}
+	}
+}
+
+// endSession 以指定登录用户（空为未登录）请求登出端点
+func endSession(h *OAuthProviderHandler, userUID string, values url.Values) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.GET("/test", func(c *gin.Context) {
+		if userUID != "" {
+			c.Set(middleware.ContextKeyUID, userUID)
+			c.Set(middleware.ContextKeySessionID, "fam-current")
+		}
+		h.EndSession(c)
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test?"+values.Encode(), nil))
+	return w
+}
+
+func TestEndSession(t *testing.T) {
+	hint := &services.IDTokenClaims{RegisteredClaims: jwt.RegisteredClaims{
+		Issuer:   "https://test.local",
+		Subject:  "uid-1",
+		Audience: jwt.ClaimStrings{"client-1"},
+	}}
+	newHandler := func(t *testing.T) (*OAuthProviderHandler, *providerTestDeps) {
+		h, deps := newTestProvider(t)
+		deps.oauth.Client.PostLogoutRedirectURIs = []string{"https://rp.example/logged-out"}
+		deps.signer.Issued = map[string]*services.IDTokenClaims{"hint-1": hint}
+		deps.sessions.Sessions = []*models.SessionToken{
+			{UserUID: "uid-1", FamilyID: "fam-current"},
+			{UserUID: "uid-1", FamilyID: "fam-other-device"},
+		}
+		return h, deps
+	}
+
+	t.Run("valid hint logs out and redirects with state", func(t *testing.T) {
+		h, deps := newHandler(t)
+		w := endSession(h, "uid-1", url.Values{
+			"id_token_hint":            {"hint-1"},
+			"post_logout_redirect_uri": {"https://rp.example/logged-out"},
+			"state":                    {"s1"},
+		})
+		if w.Code != http.StatusFound || w.Header().Get("Location") != "https://rp.example/logged-out?state=s1" {
+			t.Fatalf("status = %d location = %q", w.Code, w.Header().Get("Location"))
+		}
+		// 只撤销当前会话，其他设备保持登录
+		if len(deps.sessions.RevokedUIDs) != 0 || len(deps.sessions.Sessions) != 1 || deps.sessions.Sessions[0].FamilyID != "fam-other-device" {
+			t.Errorf("revoked uids = %v, remaining sessions = %v, want only fam-current revoked", deps.sessions.RevokedUIDs, deps.sessions.Sessions)
+		}
+	})
+
+	t.Run("unregistered redirect uri", func(t *testing.T) {
+		h, deps := newHandler(t)
+		w := endSession(h, "uid-1", url.Values{
+			"id_token_hint":            {"hint-1"},
+			"post_logout_redirect_uri": {"https://evil.example/"},
+		})
+		if loc := w.Header().Get("Location"); !strings.Contains(loc, "/account/oauth?") || strings.Contains(loc, "evil.example") {
+			t.Errorf("location = %q, want error page", loc)
+		}
+		if len(deps.sessions.Sessions) != 2 {
+			t.Error("session should not be revoked on invalid request")
+		}
+	})
+
+	t.Run("redirect uri without client", func(t *testing.T) {
+		h, _ := newHandler(t)
+		w := endSession(h, "uid-1", url.Values{"post_logout_redirect_uri": {"https://rp.example/logged-out"}})
+		if loc := w.Header().Get("Location"); !strings.Contains(loc, "error=invalid_request") {
+			t.Errorf("location = %q, want invalid_request", loc)
+		}
+	})
+
+	t.Run("client_id mismatch", func(t *testing.T) {
+		h, _ := newHandler(t)
+		w := endSession(h, "uid-1", url.Values{"id_token_hint": {"hint-1"}, "client_id": {"client-2"}})
+		if loc := w.Header().Get("Location"); !strings.Contains(loc, "error=invalid_request") {
+			t.Errorf("location = %q, want invalid_request", loc)
+		}
+	})
+
+	t.Run("invalid hint", func(t *testing.T) {
+		h, _ := newHandler(t)
+		w := endSession(h, "uid-1", url.Values{"id_token_hint": {"forged"}})
+		if loc := w.Header().Get("Location"); !strings.Contains(loc, "error=invalid_request") {
+			t.Errorf("location = %q, want invalid_request", loc)
+		}
+	})
+
+	t.Run("without hint keeps session", func(t *testing.T) {
+		h, deps := newHandler(t)
+		w := endSession(h, "uid-1", url.Values{
+			"client_id":                {"client-1"},
+			"post_logout_redirect_uri": {"https://rp.example/logged-out"},
+		})
+		if w.Header().Get("Location") != "https://rp.example/logged-out" {
+			t.Errorf("location = %q", w.Header().Get("Location"))
+		}
+		if len(deps.sessions.Sessions) != 2 {
+			t.Error("session should be kept without id_token_hint")
+		}
+	})
+
+	t.Run("hint for another user keeps session", func(t *testing.T) {
+		h, deps := newHandler(t)
+		w := endSession(h, "uid-2", url.Values{"id_token_hint": {"hint-1"}})
+		if w.Header().Get("Location") != "https://test.local/account/login" {
+			t.Errorf("location = %q", w.Header().Get("Location"))
+		}
+		if len(deps.sessions.Sessions) != 2 {
+			t.Error("session of another user should be kept")
+		}
+	})
+}
+
+func TestJWKS(t *testing.T) {
+	h, _ := newTestProvider(t)
+
+	r := gin.New()
+	r.GET("/test", h.JWKS)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"kid":"fake-kid"`) {
+		t.Errorf("status = %d body = %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,313 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"auth-system/internal/models"
+	"auth-system/internal/utils"
+)
+
+// StateStore 外部登录流程的短期状态存储（OAuth state 与待确认绑定）
+//
+// 发起授权与回调、待绑定查询与确认可能由不同实例处理，多实例部署须使用共享实现（DBStateStore）；
+// 单实例部署可使用 MemoryStateStore。GetAndDelete* 为一次性消费，并发调用只有一方成功。
+// 不存在或已过期时分别返回 ErrOAuthStateNotFound / ErrInvalidLinkToken。
+type StateStore interface {
+	SaveState(ctx context.Context, state string, data *State) error
+	GetAndDeleteState(ctx context.Context, state string) (*State, error)
+	SavePendingLink(ctx context.Context, token string, data *PendingLink) error
+	GetPendingLink(ctx context.Context, token string) (*PendingLink, error)
+	DeletePendingLink(ctx context.Context, token string) error
+	GetAndDeletePendingLink(ctx context.Context, token string) (*PendingLink, error)
+	// CleanupExpired 删除过期条目，返回删除数量
+	CleanupExpired(ctx context.Context) (int64, error)
+}
+
+// ---------- MemoryStateStore ----------
+
+// MemoryStateStore 进程内存实现：服务重启丢失全部进行中的流程，多实例间不共享。
+// 带有最大容量限制，达到上限时按 FIFO 淘汰旧条目。
+type MemoryStateStore struct {
+	states         map[string]*State       // OAuth state 存储
+	pendingLinks   map[string]*PendingLink // 待绑定数据存储
+	stateMu        sync.Mutex              // states 锁
+	linkMu         sync.Mutex              // pendingLinks 锁
+	stateIndex     map[string]int64        // state 插入序号（用于 FIFO）
+	pendingIndex   map[string]int64        // pendingLink 插入序号（用于 FIFO）
+	stateCounter   int64                   // state 自增计数器
+	pendingCounter int64                   // pendingLink 自增计数器
+}
+
+// NewMemoryStateStore 创建内存状态存储
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		states:       make(map[string]*State),
+		pendingLinks: make(map[string]*PendingLink),
+		stateIndex:   make(map[string]int64),
+		pendingIndex: make(map[string]int64),
+	}
+}
+
+// SaveState 保存 OAuth state，达到容量上限时按 FIFO 淘汰旧条目
+func (s *MemoryStateStore) SaveState(_ context.Context, state string, data *State) error {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	if len(s.states) >= maxStatesCapacity {
+		fifoEvictLocked(s.states, s.stateIndex, maxStatesCapacity/10)
+	}
+
+	s.stateCounter++
+	s.states[state] = data
+	s.stateIndex[state] = s.stateCounter
+	return nil
+}
+
+// GetAndDeleteState 获取并删除 OAuth state（原子操作），用于防止重复提交攻击
+func (s *MemoryStateStore) GetAndDeleteState(_ context.Context, state string) (*State, error) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	data, exists := s.states[state]
+	if !exists {
+		return nil, ErrOAuthStateNotFound
+	}
+	delete(s.states, state)
+	delete(s.stateIndex, state)
+	return data, nil
+}
+
+// SavePendingLink 保存待绑定数据，达到容量上限时按 FIFO 淘汰旧条目
+func (s *MemoryStateStore) SavePendingLink(_ context.Context, token string, data *PendingLink) error {
+	s.linkMu.Lock()
+	defer s.linkMu.Unlock()
+
+	if len(s.pendingLinks) >= maxPendingLinksCapacity {
+		fifoEvictLocked(s.pendingLinks, s.pendingIndex, maxPendingLinksCapacity/10)
+	}
+
+	s.pendingCounter++
+	s.pendingLinks[token] = data
+	s.pendingIndex[token] = s.pendingCounter
+	return nil
+}
+
+func (s *MemoryStateStore) GetPendingLink(_ context.Context, token string) (*PendingLink, error) {
+	s.linkMu.Lock()
+	defer s.linkMu.Unlock()
+
+	data, exists := s.pendingLinks[token]
+	if !exists {
+		return nil, ErrInvalidLinkToken
+	}
+	return data, nil
+}
+
+func (s *MemoryStateStore) DeletePendingLink(_ context.Context, token string) error {
+	s.linkMu.Lock()
+	defer s.linkMu.Unlock()
+
+	delete(s.pendingLinks, token)
+	delete(s.pendingIndex, token)
+	return nil
+}
+
+// GetAndDeletePendingLink 获取并删除待绑定数据（原子操作）
+func (s *MemoryStateStore) GetAndDeletePendingLink(_ context.Context, token string) (*PendingLink, error) {
+	s.linkMu.Lock()
+	defer s.linkMu.Unlock()
+
+	data, exists := s.pendingLinks[token]
+	if !exists {
+		return nil, ErrInvalidLinkToken
+	}
+	delete(s.pendingLinks, token)
+	delete(s.pendingIndex, token)
+	return data, nil
+}
+
+// CleanupExpired 清理超过 StateExpiryMS 的 state 与待绑定数据
+func (s *MemoryStateStore) CleanupExpired(context.Context) (int64, error) {
+	now := time.Now().UnixMilli()
+	var count int64
+
+	s.stateMu.Lock()
+	for state, data := range s.states {
+		if data == nil || now-data.Timestamp > StateExpiryMS {
+			delete(s.states, state)
+			delete(s.stateIndex, state)
+			count++
+		}
+	}
+	s.stateMu.Unlock()
+
+	s.linkMu.Lock()
+	for token, data := range s.pendingLinks {
+		if data == nil || now-data.Timestamp > StateExpiryMS {
+			delete(s.pendingLinks, token)
+			delete(s.pendingIndex, token)
+			count++
+		}
+	}
+	s.linkMu.Unlock()
+
+	return count, nil
+}
+
+// fifoEvictLocked 按 FIFO 原则淘汰最旧的 N 个条目（持有锁的情况下调用）
+func fifoEvictLocked[V any](dataMap map[string]V, indexMap map[string]int64, count int) {
+	if count <= 0 {
+		return
+	}
+
+	for _, key := range findOldestKeys(indexMap, count) {
+		delete(dataMap, key)
+		delete(indexMap, key)
+	}
+}
+
+// findOldestKeys 找出序号最小的 N 个 key（使用 max-heap）
+func findOldestKeys(indexMap map[string]int64, count int) []string {
+	if len(indexMap) <= count {
+		keys := make([]string, 0, len(indexMap))
+		for k := range indexMap {
+			keys = append(keys, k)
+		}
+		return keys
+	}
+
+	heap := make([]fifoKv, 0, count)
+
+	for k, v := range indexMap {
+		if len(heap) < count {
+			heap = append(heap, fifoKv{k, v})
+			if len(heap) == count {
+				buildFifoMaxHeap(heap)
+			}
+		} else if v < heap[0].value {
+			heap[0] = fifoKv{k, v}
+			fifoMaxHeapify(heap, 0)
+		}
+	}
+
+	result := make([]string, count)
+	for i := range heap {
+		result[i] = heap[i].key
+	}
+	return result
+}
+
+type fifoKv struct {
+	key   string
+	value int64
+}
+
+func buildFifoMaxHeap(h []fifoKv) {
+	for i := len(h)/2 - 1; i >= 0; i-- {
+		fifoMaxHeapify(h, i)
+	}
+}
+
+func fifoMaxHeapify(h []fifoKv, i int) {
+	max := i
+	left := 2*i + 1
+	right := 2*i + 2
+	if left < len(h) && h[left].value > h[max].value {
+		max = left
+	}
+	if right < len(h) && h[right].value > h[max].value {
+		max = right
+	}
+	if max != i {
+		h[i], h[max] = h[max], h[i]
+		fifoMaxHeapify(h, max)
+	}
+}
+
+// ---------- DBStateStore ----------
+
+// 流程状态在 oauth_flow_states 表中的 kind
+const (
+	flowKindState       = "state"
+	flowKindPendingLink = "pending_link"
+)
+
+// DBStateStore 数据库实现：多实例共享，重启不丢失。
+// 行以 StateExpiryDuration 为 TTL，过期行读取时视为不存在，由 CleanupExpired 定期删除。
+type DBStateStore struct {
+	repo models.OAuthFlowStateStore
+}
+
+// NewDBStateStore 创建数据库状态存储
+func NewDBStateStore(repo models.OAuthFlowStateStore) *DBStateStore {
+	return &DBStateStore{repo: repo}
+}
+
+func (s *DBStateStore) SaveState(ctx context.Context, state string, data *State) error {
+	return s.save(ctx, flowKindState, state, data)
+}
+
+func (s *DBStateStore) GetAndDeleteState(ctx context.Context, state string) (*State, error) {
+	payload, err := s.repo.GetAndDelete(ctx, flowKindState, state)
+	if errors.Is(err, models.ErrOAuthFlowStateNotFound) {
+		return nil, ErrOAuthStateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeFlowState[State](payload)
+}
+
+func (s *DBStateStore) SavePendingLink(ctx context.Context, token string, data *PendingLink) error {
+	return s.save(ctx, flowKindPendingLink, token, data)
+}
+
+func (s *DBStateStore) GetPendingLink(ctx context.Context, token string) (*PendingLink, error) {
+	payload, err := s.repo.Get(ctx, flowKindPendingLink, token)
+	if errors.Is(err, models.ErrOAuthFlowStateNotFound) {
+		return nil, ErrInvalidLinkToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeFlowState[PendingLink](payload)
+}
+
+func (s *DBStateStore) DeletePendingLink(ctx context.Context, token string) error {
+	return s.repo.Delete(ctx, flowKindPendingLink, token)
+}
+
+func (s *DBStateStore) GetAndDeletePendingLink(ctx context.Context, token string) (*PendingLink, error) {
+	payload, err := s.repo.GetAndDelete(ctx, flowKindPendingLink, token)
+	if errors.Is(err, models.ErrOAuthFlowStateNotFound) {
+		return nil, ErrInvalidLinkToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeFlowState[PendingLink](payload)
+}
+
+func (s *DBStateStore) CleanupExpired(ctx context.Context) (int64, error) {
+	return s.repo.DeleteExpired(ctx)
+}
+
+func (s *DBStateStore) save(ctx context.Context, kind, key string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return utils.LogError("OAUTH", "SaveFlowState", err, "kind", kind)
+	}
+	return s.repo.Save(ctx, kind, key, payload, StateExpiryDuration)
+}
+
+func decodeFlowState[T any](payload []byte) (*T, error) {
+	var data T
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return nil, utils.LogError("OAUTH", "DecodeFlowState", err)
+	}
+	return &data, nil
+}
@@ -0,0 +1,159 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"auth-system/internal/models"
+)
+
+func TestMemoryStateStoreConsumeOnce(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStateStore()
+
+	if err := s.SaveState(ctx, "st-1", &State{Action: ActionLogin, CodeVerifier: "v", Timestamp: time.Now().UnixMilli()}); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	got, err := s.GetAndDeleteState(ctx, "st-1")
+	if err != nil || got.CodeVerifier != "v" {
+		t.Fatalf("GetAndDeleteState = %+v, %v", got, err)
+	}
+	if _, err := s.GetAndDeleteState(ctx, "st-1"); !errors.Is(err, ErrOAuthStateNotFound) {
+		t.Errorf("second consume err = %v, want ErrOAuthStateNotFound", err)
+	}
+
+	_ = s.SavePendingLink(ctx, "lk-1", &PendingLink{UserUID: "u1", Timestamp: time.Now().UnixMilli()})
+	if link, err := s.GetPendingLink(ctx, "lk-1"); err != nil || link.UserUID != "u1" {
+		t.Fatalf("GetPendingLink = %+v, %v", link, err)
+	}
+	if _, err := s.GetAndDeletePendingLink(ctx, "lk-1"); err != nil {
+		t.Fatalf("GetAndDeletePendingLink: %v", err)
+	}
+	if _, err := s.GetPendingLink(ctx, "lk-1"); !errors.Is(err, ErrInvalidLinkToken) {
+		t.Errorf("after consume err = %v, want ErrInvalidLinkToken", err)
+	}
+}
+
+func TestMemoryStateStoreFIFOAndCleanup(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStateStore()
+	now := time.Now().UnixMilli()
+
+	for i := range maxStatesCapacity {
+		_ = s.SaveState(ctx, "st-"+strconv.Itoa(i), &State{Timestamp: now})
+	}
+	// 达到上限后再写入，淘汰最早的 10%
+	_ = s.SaveState(ctx, "st-new", &State{Timestamp: now})
+	if _, err := s.GetAndDeleteState(ctx, "st-0"); !errors.Is(err, ErrOAuthStateNotFound) {
+		t.Errorf("oldest state should be evicted, err = %v", err)
+	}
+	if _, err := s.GetAndDeleteState(ctx, "st-new"); err != nil {
+		t.Errorf("newest state should be kept, err = %v", err)
+	}
+
+	s = NewMemoryStateStore()
+	_ = s.SaveState(ctx, "old", &State{Timestamp: now - StateExpiryMS - 1})
+	_ = s.SavePendingLink(ctx, "old", &PendingLink{Timestamp: now - StateExpiryMS - 1})
+	_ = s.SaveState(ctx, "fresh", &State{Timestamp: now})
+	if n, err := s.CleanupExpired(ctx); err != nil || n != 2 {
+		t.Errorf("CleanupExpired = %d, %v; want 2", n, err)
+	}
+	if _, err := s.GetAndDeleteState(ctx, "fresh"); err != nil {
+		t.Errorf("fresh state removed by cleanup: %v", err)
+	}
+}
+
+// fakeFlowStateRepo models.OAuthFlowStateStore 的内存 fake（忽略 TTL）
+type fakeFlowStateRepo struct {
+	mu   sync.Mutex
+	rows map[string][]byte
+	ttl  time.Duration
+	err  error
+}
+
+func (f *fakeFlowStateRepo) Save(_ context.Context, kind, key string, payload []byte, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	if f.rows == nil {
+		f.rows = map[string][]byte{}
+	}
+	f.rows[kind+"/"+key] = payload
+	f.ttl = ttl
+	return nil
+}
+
+func (f *fakeFlowStateRepo) Get(_ context.Context, kind, key string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	payload, ok := f.rows[kind+"/"+key]
+	if !ok {
+		return nil, models.ErrOAuthFlowStateNotFound
+	}
+	return payload, nil
+}
+
+func (f *fakeFlowStateRepo) GetAndDelete(ctx context.Context, kind, key string) ([]byte, error) {
+	payload, err := f.Get(ctx, kind, key)
+	if err == nil {
+		_ = f.Delete(ctx, kind, key)
+	}
+	return payload, err
+}
+
+func (f *fakeFlowStateRepo) Delete(_ context.Context, kind, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.rows, kind+"/"+key)
+	return nil
+}
+
+func (f *fakeFlowStateRepo) DeleteExpired(context.Context) (int64, error) { return 0, nil }
+
+func TestDBStateStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeFlowStateRepo{}
+	s := NewDBStateStore(repo)
+
+	want := &State{Action: ActionLink, UserUID: "u1", CodeVerifier: "v", ReturnURL: "/x", Timestamp: 42}
+	if err := s.SaveState(ctx, "st-1", want); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	if repo.ttl != StateExpiryDuration {
+		t.Errorf("ttl = %v, want %v", repo.ttl, StateExpiryDuration)
+	}
+	got, err := s.GetAndDeleteState(ctx, "st-1")
+	if err != nil || *got != *want {
+		t.Fatalf("GetAndDeleteState = %+v, %v; want %+v", got, err, want)
+	}
+	if _, err := s.GetAndDeleteState(ctx, "st-1"); !errors.Is(err, ErrOAuthStateNotFound) {
+		t.Errorf("second consume err = %v, want ErrOAuthStateNotFound", err)
+	}
+
+	// state 与待绑定使用不同 kind，同名 key 互不影响
+	_ = s.SavePendingLink(ctx, "st-1", &PendingLink{Provider: "google", UserUID: "u2"})
+	if _, err := s.GetAndDeleteState(ctx, "st-1"); !errors.Is(err, ErrOAuthStateNotFound) {
+		t.Errorf("pending link must not be readable as state, err = %v", err)
+	}
+	link, err := s.GetAndDeletePendingLink(ctx, "st-1")
+	if err != nil || link.Provider != "google" || link.UserUID != "u2" {
+		t.Fatalf("GetAndDeletePendingLink = %+v, %v", link, err)
+	}
+	if _, err := s.GetPendingLink(ctx, "st-1"); !errors.Is(err, ErrInvalidLinkToken) {
+		t.Errorf("after consume err = %v, want ErrInvalidLinkToken", err)
+	}
+
+	repo.err = errors.New("connection refused")
+	if _, err := s.GetAndDeleteState(ctx, "st-2"); err == nil || errors.Is(err, ErrOAuthStateNotFound) {
+		t.Errorf("store failure err = %v, want underlying error", err)
+	}
+}
@@ -9,8 +9,6 @@ import (
 	"auth-system/internal/models"
 	"auth-system/internal/services"
 	"auth-system/internal/utils"
-
-	"github.com/ua-parser/uap-go/uaparser"
 )
 
 var (
@@ -45,16 +43,18 @@ type QRLoginHandler struct {
 	qrLoginRepo    models.QRLoginStore       // 扫码登录仓库
 	encryptKey     []byte                    // AES-256-GCM 加密密钥
 	isConfigured   bool                      // 是否已配置（加密密钥有效）
+	loginRisk      services.LoginRiskChecker // 新设备登录检查（可选）
 }
 
 // NewQRLoginHandler 创建扫码登录 Handler，验证必需依赖（sessionService、wsService、qrLoginRepo、derivationSalt）后初始化。
-// encryptKey 为空时 QR 登录功能将被禁用。
+// encryptKey 为空时 QR 登录功能将被禁用；loginRisk 为 nil 时不做新设备登录检查。
 func NewQRLoginHandler(
 	sessionService services.SessionManager,
 	wsService services.WebSocketManager,
 	qrLoginRepo models.QRLoginStore,
 	encryptKey string,
 	derivationSalt string,
+	loginRisk services.LoginRiskChecker,
 ) (*QRLoginHandler, error) {
 	if sessionService == nil {
 		return nil, errors.New("sessionService is required")
@@ -93,6 +93,7 @@ func NewQRLoginHandler(
 		qrLoginRepo:    qrLoginRepo,
 		encryptKey:     derivedKey,
 		isConfigured:   isConfigured,
+		loginRisk:      loginRisk,
 	}
 
 	// 向 WebSocket 服务注册解密回调，使 WS 握手时能校验加密 token
@@ -125,31 +126,6 @@ func (h *QRLoginHandler) decryptToken(encryptedToken string) (string, error) {
 	return originalToken, nil
 }
 
-// uaParser 全局 UAParser 实例（线程安全，内置 LRU 缓存）
-var uaParser = uaparser.NewFromSaved()
-
-// parseUserAgent 解析 User-Agent 提取浏览器和操作系统信息
-func parseUserAgent(userAgent string) (browser, os string) {
-	browser = "Unknown"
-	os = "Unknown"
-
-	if userAgent == "" {
-		return
-	}
-
-	client := uaParser.Parse(userAgent)
-
-	if client.UserAgent.Family != "" {
-		browser = client.UserAgent.Family
-	}
-
-	if client.Os.Family != "" {
-		os = client.Os.Family
-	}
-
-	return
-}
-
 // notifyStatusChange 通过 WebSocket 通知 PC 端状态变化
 func (h *QRLoginHandler) notifyStatusChange(encryptedToken, status string, data map[string]string) {
 	if h.wsService == nil {
@@ -55,7 +55,7 @@ func (h *QRLoginHandler) Scan(c *gin.Context) {
 		return
 	}
 
-	browser, os := parseUserAgent(qrToken.PcUserAgent)
+	browser, os := utils.ParseUserAgent(qrToken.PcUserAgent)
 
 	now := time.Now().UnixMilli()
 	success, err := h.qrLoginRepo.UpdateStatusWithCondition(ctx, tokenHash, QRStatusPending, QRStatusScanned, &now)
@@ -107,6 +107,9 @@ func (h *QRLoginHandler) MobileConfirm(c *gin.Context) {
 	}
 
 	claims, err := h.sessionService.VerifyToken(sessionToken)
+	if err == nil {
+		err = h.sessionService.CheckSession(c.Request.Context(), claims)
+	}
 	if err != nil {
 		utils.HTTPErrorResponse(c, "QR-LOGIN", http.StatusUnauthorized, "INVALID_SESSION", "Invalid session in MobileConfirm")
 		return
@@ -141,7 +144,7 @@ func (h *QRLoginHandler) MobileConfirm(c *gin.Context) {
 		return
 	}
 
-	pcSessionToken, _, err := h.sessionService.GenerateTokens(c.Request.Context(), userUID, false)
+	pcSessionToken, _, familyID, err := h.sessionService.GenerateTokens(c.Request.Context(), userUID, false)
 	if err != nil {
 		utils.LogErrorCtx(c.Request.Context(), "QR-LOGIN", "MobileConfirm", err, "user_uid", userUID)
 		utils.RespondError(c, http.StatusInternalServerError, "SESSION_CREATE_FAILED")
@@ -164,6 +167,15 @@ func (h *QRLoginHandler) MobileConfirm(c *gin.Context) {
 		"sessionToken": pcSessionToken,
 	})
 
+	// 新会话属于扫码的 PC 端，按 PC 端的 IP、UA 与位置做新设备检查
+	if h.loginRisk != nil {
+		h.loginRisk.CheckLogin(ctx, userUID, familyID, utils.ClientInfo{
+			IP:        qrToken.PcIP,
+			UserAgent: qrToken.PcUserAgent,
+			Location:  qrToken.PcLocation,
+		})
+	}
+
 	utils.LogInfoCtx(c.Request.Context(), "QR-LOGIN", "Mobile confirmed login", "user_uid", userUID)
 	utils.RespondSuccess(c, gin.H{})
 }
@@ -41,6 +41,7 @@ func (h *QRLoginHandler) Generate(c *gin.Context) {
 		Status:      QRStatusPending,
 		PcIP:        pcIP,
 		PcUserAgent: pcUserAgent,
+		PcLocation:  utils.GetClientLocation(c),
 		CreatedAt:   now,
 		ExpireTime:  expireTime,
 	}
@@ -146,6 +147,12 @@ func (h *QRLoginHandler) SetSession(c *gin.Context) {
 		return
 	}
 
+	// 手机端会话已被撤销时，其未过期的 access_token 不能再为 PC 端换取新会话
+	if err := h.sessionService.CheckSession(ctx, claims); err != nil {
+		utils.HTTPErrorResponse(c, "QR-LOGIN", http.StatusBadRequest, "INVALID_SESSION", "Revoked session token in SetSession")
+		return
+	}
+
 	userUID, err := h.qrLoginRepo.ConsumeAndSetSession(ctx, utils.HashToken(originalToken), utils.HashToken(sessionToken))
 	if err != nil {
 		errStr := err.Error()
@@ -169,7 +176,7 @@ func (h *QRLoginHandler) SetSession(c *gin.Context) {
 		return
 	}
 
-	accessToken, refreshToken, tokenErr := h.sessionService.GenerateTokens(c.Request.Context(), userUID, false)
+	accessToken, refreshToken, _, tokenErr := h.sessionService.GenerateTokens(c.Request.Context(), userUID, false)
 	if tokenErr != nil {
 		utils.HTTPErrorResponse(c, "QR-LOGIN", http.StatusInternalServerError, "TOKEN_GENERATION_FAILED", "Failed to generate session tokens")
 		return
@@ -45,7 +45,7 @@ func newTestQR(t *testing.T, configured bool) (*QRLoginHandler, *qrTestDeps) {
 		key = testEncryptKey
 	}
 
-	h, err := NewQRLoginHandler(deps.session, deps.ws, deps.qrRepo, key, testDeriveSalt)
+	h, err := NewQRLoginHandler(deps.session, deps.ws, deps.qrRepo, key, testDeriveSalt, nil)
 	if err != nil {
 		t.Fatalf("NewQRLoginHandler() error = %v", err)
 	}
@@ -463,6 +463,22 @@ func TestSetSessionInvalidUserErr(t *testing.T) {
 	}
 }
 
+func TestSetSessionRevokedSession(t *testing.T) {
+	h, deps := newTestQR(t, true)
+	deps.session.VerifyResult = &services.Claims{UID: "u1", SID: "fam-1"}
+	deps.session.RevokedSIDs = []string{"fam-1"}
+	deps.qrRepo.ConsumeUserUID = "u1"
+	enc := encryptQRToken(t, testQRToken)
+
+	w := postQRJSONPath(h.SetSession, enc, `{"sessionToken":"`+testSessionTok+`"}`, "")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_SESSION") {
+		t.Fatalf("status = %d body = %s, want 400 INVALID_SESSION", w.Code, w.Body.String())
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("revoked session must not be bound to the PC")
+	}
+}
+
 func TestSetSessionNotFoundErr(t *testing.T) {
 	h, deps := newTestQR(t, true)
 	deps.session.VerifyResult = &services.Claims{UID: "u1"}
@@ -0,0 +1,214 @@
+// Package scim 提供 SCIM 2.0（RFC 7643 / 7644）用户供应接口，供 HR 等外部系统创建、修改与停用账户。
+// 调用方为带 scim scope 的 OAuth 客户端（client_credentials Token），所有写操作记入 admin_logs。
+package scim
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"auth-system/internal/models"
+	"auth-system/internal/services"
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrSCIMNilUserRepo     = errors.New("user repository is nil")
+	ErrSCIMNilUserCache    = errors.New("user cache is nil")
+	ErrSCIMNilLogRepo      = errors.New("admin log repository is nil")
+	ErrSCIMNilOAuthService = errors.New("oauth service is nil")
+	ErrSCIMNilPermReader   = errors.New("permission reader is nil")
+	ErrSCIMNilScreener     = errors.New("password screener is nil")
+)
+
+// SCIM 协议 schema URI
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// SCIM 错误的 scimType（RFC 7644 3.12）
+const (
+	scimTypeInvalidFilter = "invalidFilter"
+	scimTypeInvalidSyntax = "invalidSyntax"
+	scimTypeInvalidValue  = "invalidValue"
+	scimTypeUniqueness    = "uniqueness"
+	scimTypeMutability    = "mutability"
+)
+
+const (
+	scimContentType  = "application/scim+json"
+	scimTimeout      = 10 * time.Second
+	defaultListCount = 100
+	maxListCount     = 200
+
+	// BanReasonDeprovisioned SCIM 停用账户时写入的封禁原因；SCIM 重新启用只解除该原因的封禁
+	BanReasonDeprovisioned = "deprovisioned"
+
+	contextKeyClient = "auth-system:scim_client"
+)
+
+// SCIMHandler SCIM 2.0 Handler
+type SCIMHandler struct {
+	userRepo     models.UserStore
+	userCache    services.UserCacheStore
+	logRepo      models.AdminLogStore
+	userLogRepo  models.UserLogStore
+	oauthService services.OAuthProviderStore
+	// permissionReader 解析用户组授予的后台权限，拥有任何后台权限的用户不允许经 SCIM 修改
+	permissionReader models.UserPermissionReader
+	// passwordScreener 对客户端提供的初始密码做泄露与强度筛查，与注册、重置密码一致
+	passwordScreener services.PasswordScreener
+	baseURL          string
+}
+
+// NewSCIMHandler 创建 SCIM Handler，userLogRepo 为可选参数
+func NewSCIMHandler(userRepo models.UserStore, userCache services.UserCacheStore, logRepo models.AdminLogStore, userLogRepo models.UserLogStore, oauthService services.OAuthProviderStore, permissionReader models.UserPermissionReader, passwordScreener services.PasswordScreener, baseURL string) (*SCIMHandler, error) {
+	if userRepo == nil {
+		return nil, ErrSCIMNilUserRepo
+	}
+	if userCache == nil {
+		return nil, ErrSCIMNilUserCache
+	}
+	if logRepo == nil {
+		return nil, ErrSCIMNilLogRepo
+	}
+	if oauthService == nil {
+		return nil, ErrSCIMNilOAuthService
+	}
+	if permissionReader == nil {
+		return nil, ErrSCIMNilPermReader
+	}
+	if passwordScreener == nil {
+		return nil, ErrSCIMNilScreener
+	}
+
+	utils.LogInfo("SCIM", "SCIM handler initialized")
+
+	return &SCIMHandler{
+		userRepo:         userRepo,
+		userCache:        userCache,
+		logRepo:          logRepo,
+		userLogRepo:      userLogRepo,
+		oauthService:     oauthService,
+		permissionReader: permissionReader,
+		passwordScreener: passwordScreener,
+		baseURL:          strings.TrimSuffix(baseURL, "/"),
+	}, nil
+}
+
+// Authenticate SCIM 认证中间件：要求 client_credentials 签发、带 scim scope 的 Bearer Token，
+// 且客户端仍处于启用状态、client_scopes 仍包含 scim（撤销 scope 或禁用客户端后已签发的 Token 立即失效）
+func (h *SCIMHandler) Authenticate(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accessToken, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || accessToken == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+		respondError(c, http.StatusUnauthorized, "", "Missing bearer token")
+		return
+	}
+
+	token, err := h.oauthService.ValidateAccessToken(ctx, accessToken)
+	if err != nil {
+		if !errors.Is(err, services.ErrOAuthTokenNotFound) && !errors.Is(err, services.ErrOAuthTokenExpired) {
+			utils.LogErrorCtx(ctx, "SCIM", "Authenticate", err)
+			respondError(c, http.StatusInternalServerError, "", "Failed to validate token")
+			return
+		}
+		c.Header("WWW-Authenticate", `Bearer realm="scim", error="invalid_token"`)
+		respondError(c, http.StatusUnauthorized, "", "Invalid or expired access token")
+		return
+	}
+
+	// 用户授权签发的 Token 代表某个用户，不能用于供应
+	if token.UserUID != "" || !slices.Contains(strings.Fields(token.Scope), models.OAuthScopeSCIM) {
+		utils.LogWarnCtx(ctx, "SCIM", "Token without scim scope rejected", "client_id", token.ClientID)
+		respondError(c, http.StatusForbidden, "", "Access token does not grant the scim scope")
+		return
+	}
+
+	client, err := h.oauthService.ValidateClientID(ctx, token.ClientID)
+	if err != nil {
+		if !errors.Is(err, services.ErrOAuthInvalidClient) && !errors.Is(err, services.ErrOAuthClientDisabled) {
+			utils.LogErrorCtx(ctx, "SCIM", "Authenticate", err, "client_id", token.ClientID)
+			respondError(c, http.StatusInternalServerError, "", "Failed to validate client")
+			return
+		}
+		c.Header("WWW-Authenticate", `Bearer realm="scim", error="invalid_token"`)
+		respondError(c, http.StatusUnauthorized, "", "Client is disabled or no longer exists")
+		return
+	}
+	if !slices.Contains(client.ClientScopes, models.OAuthScopeSCIM) {
+		utils.LogWarnCtx(ctx, "SCIM", "Client no longer holds scim scope", "client_id", client.ClientID)
+		respondError(c, http.StatusForbidden, "", "Client is not allowed to use SCIM")
+		return
+	}
+
+	c.Set(contextKeyClient, client)
+	c.Next()
+}
+
+// getClient 获取 Authenticate 挂载的调用方客户端
+func getClient(c *gin.Context) *models.OAuthClient {
+	client, _ := c.MustGet(contextKeyClient).(*models.OAuthClient)
+	return client
+}
+
+// scimError SCIM 错误响应体
+type scimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// respond 以 application/scim+json 输出响应
+func respond(c *gin.Context, status int, body any) {
+	c.Header("Content-Type", scimContentType)
+	c.JSON(status, body)
+}
+
+// respondError 输出 SCIM 错误响应并中止后续处理
+func respondError(c *gin.Context, status int, scimType, detail string) {
+	c.Header("Content-Type", scimContentType)
+	c.AbortWithStatusJSON(status, scimError{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   detail,
+	})
+}
+
+// ServiceProviderConfig 服务能力声明
+// GET /scim/v2/ServiceProviderConfig
+func (h *SCIMHandler) ServiceProviderConfig(c *gin.Context) {
+	supported := func(ok bool) gin.H { return gin.H{"supported": ok} }
+
+	respond(c, http.StatusOK, gin.H{
+		"schemas":        []string{SchemaServiceProviderConfig},
+		"patch":          supported(true),
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": maxListCount},
+		"changePassword": supported(false),
+		"sort":           supported(false),
+		"etag":           supported(false),
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "client_credentials access token with the scim scope",
+		}},
+		"meta": gin.H{
+			"resourceType": "ServiceProviderConfig",
+			"location":     h.baseURL + "/scim/v2/ServiceProviderConfig",
+		},
+	})
+}
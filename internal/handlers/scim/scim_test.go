@@ -0,0 +1,322 @@
+package scim
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"auth-system/internal/models"
+	"auth-system/internal/services"
+	"auth-system/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scimTestDeps 测试依赖集合
+type scimTestDeps struct {
+	userRepo *testutil.FakeUserRepo
+	logs     *testutil.FakeAdminLogStore
+	oauth    *testutil.FakeOAuthProvider
+	groups   *testutil.FakeUserGroupRepo
+}
+
+// newTestRouter 组装挂载了 Authenticate 的 /scim/v2 路由；默认 Token 为带 scim scope 的 client_credentials Token
+func newTestRouter(t *testing.T) (*gin.Engine, *scimTestDeps) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	deps := &scimTestDeps{
+		userRepo: testutil.NewFakeUserRepo(),
+		logs:     &testutil.FakeAdminLogStore{},
+		groups:   testutil.NewFakeUserGroupRepo(),
+		oauth: &testutil.FakeOAuthProvider{
+			AccessToken: &models.OAuthAccessToken{ClientID: "hr-client", Scope: "scim"},
+			Client:      &models.OAuthClient{ClientID: "hr-client", Name: "HR", ClientScopes: []string{"scim"}, IsEnabled: true},
+		},
+	}
+
+	h, err := NewSCIMHandler(deps.userRepo, &testutil.FakeUserCache{}, deps.logs, &testutil.FakeUserLogStore{}, deps.oauth, deps.groups, services.NewPasswordScreenService(nil), "https://auth.example.com/")
+	if err != nil {
+		t.Fatalf("NewSCIMHandler() error = %v", err)
+	}
+
+	r := gin.New()
+	g := r.Group("/scim/v2", h.Authenticate)
+	g.GET("/ServiceProviderConfig", h.ServiceProviderConfig)
+	g.GET("/Users", h.ListUsers)
+	g.POST("/Users", h.CreateUser)
+	g.GET("/Users/:id", h.GetUser)
+	g.PATCH("/Users/:id", h.PatchUser)
+	g.DELETE("/Users/:id", h.DeleteUser)
+	return r, deps
+}
+
+func doSCIM(r *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Content-Type", scimContentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decodeUser(t *testing.T, w *httptest.ResponseRecorder) userResource {
+	t.Helper()
+	var resource userResource
+	if err := json.Unmarshal(w.Body.Bytes(), &resource); err != nil {
+		t.Fatalf("decode body %s: %v", w.Body.String(), err)
+	}
+	return resource
+}
+
+func seedUser(deps *scimTestDeps) *models.User {
+	user := &models.User{UID: "uid-alice", Username: "alice", Email: "alice@example.com", Role: models.RoleUser}
+	deps.userRepo.Seed(user)
+	return user
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(*testutil.FakeOAuthProvider)
+		want  int
+	}{
+		{"expired token", func(f *testutil.FakeOAuthProvider) { f.AccessTokenErr = services.ErrOAuthTokenExpired }, http.StatusUnauthorized},
+		{"user token", func(f *testutil.FakeOAuthProvider) { f.AccessToken.UserUID = "uid-alice" }, http.StatusForbidden},
+		{"token without scim scope", func(f *testutil.FakeOAuthProvider) { f.AccessToken.Scope = "openid" }, http.StatusForbidden},
+		{"disabled client", func(f *testutil.FakeOAuthProvider) { f.ValidateErr = services.ErrOAuthClientDisabled }, http.StatusUnauthorized},
+		{"scope revoked from client", func(f *testutil.FakeOAuthProvider) { f.Client.ClientScopes = []string{"openid"} }, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, deps := newTestRouter(t)
+			tt.setup(deps.oauth)
+			w := doSCIM(r, http.MethodGet, "/scim/v2/Users", "")
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+
+	r, _ := newTestRouter(t)
+	req := httptest.NewRequest(http.MethodGet, "/scim/v2/Users", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("missing token: status = %d, WWW-Authenticate = %q", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	r, deps := newTestRouter(t)
+
+	body := `{"schemas":["` + SchemaUser + `"],"userName":"bob","emails":[{"value":"other@example.com"},{"value":"Bob@Example.com","primary":true}],"active":true}`
+	w := doSCIM(r, http.MethodPost, "/scim/v2/Users", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != scimContentType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	if len(deps.userRepo.CreatedUsers) != 1 {
+		t.Fatalf("created users = %d, want 1", len(deps.userRepo.CreatedUsers))
+	}
+	created := deps.userRepo.CreatedUsers[0]
+	if created.Username != "bob" || created.Email != "bob@example.com" || created.Password == "" {
+		t.Errorf("created user = %+v", created)
+	}
+
+	resource := decodeUser(t, w)
+	if !resource.Active || resource.UserName != "bob" || w.Header().Get("Location") != resource.Meta.Location {
+		t.Errorf("resource = %+v, Location = %q", resource, w.Header().Get("Location"))
+	}
+
+	if len(deps.logs.SCIMLogs) != 1 || deps.logs.SCIMLogs[0].Action != models.ActionSCIMUserCreate || deps.logs.SCIMLogs[0].ClientID != "hr-client" {
+		t.Errorf("scim logs = %+v", deps.logs.SCIMLogs)
+	}
+
+	deps.userRepo.CreateErr = models.ErrUsernameExists
+	if w := doSCIM(r, http.MethodPost, "/scim/v2/Users", body); w.Code != http.StatusConflict {
+		t.Errorf("duplicate status = %d, want 409", w.Code)
+	}
+
+	if w := doSCIM(r, http.MethodPost, "/scim/v2/Users", `{"userName":"carol","emails":[]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("missing email status = %d, want 400", w.Code)
+	}
+}
+
+func TestCreateUserScreensPassword(t *testing.T) {
+	r, deps := newTestRouter(t)
+
+	// 由用户名拼出的密码满足字符规则，但强度筛查不通过
+	body := `{"userName":"bob","emails":[{"value":"bob@example.com"}],"password":"Bob2024!Bob2024!Bob"}`
+	w := doSCIM(r, http.MethodPost, "/scim/v2/Users", body)
+	if w.Code != http.StatusBadRequest || !bytes.Contains(w.Body.Bytes(), []byte("PASSWORD_TOO_WEAK")) {
+		t.Fatalf("weak password: status = %d body = %s", w.Code, w.Body.String())
+	}
+	if len(deps.userRepo.CreatedUsers) != 0 {
+		t.Errorf("user created with a screened password: %+v", deps.userRepo.CreatedUsers)
+	}
+
+	body = `{"userName":"bob","emails":[{"value":"bob@example.com"}],"password":"Vq8#mZt2!pLw9$Rk"}`
+	if w := doSCIM(r, http.MethodPost, "/scim/v2/Users", body); w.Code != http.StatusCreated {
+		t.Errorf("strong password: status = %d body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestListUsersFilter(t *testing.T) {
+	r, deps := newTestRouter(t)
+	seedUser(deps)
+
+	tests := []struct {
+		filter string
+		want   int
+		total  int64
+	}{
+		{`userName eq "alice"`, http.StatusOK, 1},
+		{`emails.value eq "ALICE@example.com"`, http.StatusOK, 1},
+		{`id eq "uid-missing"`, http.StatusOK, 0},
+		{`userName co "ali"`, http.StatusBadRequest, 0},
+		{`displayName eq "alice"`, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		w := doSCIM(r, http.MethodGet, "/scim/v2/Users?filter="+url.QueryEscape(tt.filter), "")
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.filter, w.Code, tt.want)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		var list listResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatalf("decode list: %v", err)
+		}
+		if list.TotalResults != tt.total || len(list.Resources) != int(tt.total) {
+			t.Errorf("%s: total = %d, resources = %d, want %d", tt.filter, list.TotalResults, len(list.Resources), tt.total)
+		}
+	}
+}
+
+func TestPatchUser(t *testing.T) {
+	r, deps := newTestRouter(t)
+	seedUser(deps)
+
+	body := `{"schemas":["` + SchemaPatchOp + `"],"Operations":[
+		{"op":"Replace","path":"userName","value":"alice2"},
+		{"op":"replace","value":{"active":"False","displayName":"ignored"}}
+	]}`
+	w := doSCIM(r, http.MethodPatch, "/scim/v2/Users/uid-alice", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	resource := decodeUser(t, w)
+	if resource.UserName != "alice2" || resource.Active {
+		t.Errorf("resource = %+v", resource)
+	}
+
+	if len(deps.userRepo.BanCalls) != 1 {
+		t.Fatalf("ban calls = %d, want 1", len(deps.userRepo.BanCalls))
+	}
+	if call := deps.userRepo.BanCalls[0]; call.AdminUID != models.SCIMActorUID || call.Reason != BanReasonDeprovisioned || call.UnbanAt != nil {
+		t.Errorf("ban call = %+v", call)
+	}
+
+	actions := make([]string, 0, len(deps.logs.SCIMLogs))
+	for _, log := range deps.logs.SCIMLogs {
+		actions = append(actions, log.Action)
+	}
+	if len(actions) != 2 || actions[0] != models.ActionSCIMUserUpdate || actions[1] != models.ActionSCIMUserDeactivate {
+		t.Errorf("scim log actions = %v", actions)
+	}
+	if attrs := deps.logs.SCIMLogs[0].Attributes; len(attrs) != 1 || attrs[0] != "userName" {
+		t.Errorf("update attributes = %v", attrs)
+	}
+
+	w = doSCIM(r, http.MethodPatch, "/scim/v2/Users/uid-alice", `{"Operations":[{"op":"replace","path":"active","value":true}]}`)
+	if w.Code != http.StatusOK || !decodeUser(t, w).Active || len(deps.userRepo.UnbanCalls) != 1 {
+		t.Errorf("reactivate: status = %d, unban calls = %v", w.Code, deps.userRepo.UnbanCalls)
+	}
+
+	if w := doSCIM(r, http.MethodPatch, "/scim/v2/Users/uid-alice", `{"Operations":[{"op":"remove","path":"emails"}]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("remove status = %d, want 400", w.Code)
+	}
+}
+
+// 管理员因其他原因封禁的账户，SCIM 重新启用不得解封
+func TestReactivateKeepsAdminBan(t *testing.T) {
+	r, deps := newTestRouter(t)
+	user := seedUser(deps)
+	user.IsBanned = true
+	user.BanReason.String, user.BanReason.Valid = "spam", true
+
+	w := doSCIM(r, http.MethodPatch, "/scim/v2/Users/uid-alice", `{"Operations":[{"op":"replace","path":"active","value":true}]}`)
+	if w.Code != http.StatusOK || decodeUser(t, w).Active || len(deps.userRepo.UnbanCalls) != 0 {
+		t.Errorf("status = %d, unban calls = %v", w.Code, deps.userRepo.UnbanCalls)
+	}
+}
+
+func TestDeleteUserDeactivates(t *testing.T) {
+	r, deps := newTestRouter(t)
+	seedUser(deps)
+
+	if w := doSCIM(r, http.MethodDelete, "/scim/v2/Users/uid-alice", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", w.Code)
+	}
+	if len(deps.userRepo.BanCalls) != 1 || deps.userRepo.UIDs["uid-alice"] == nil {
+		t.Errorf("ban calls = %v (账户应停用而非删除)", deps.userRepo.BanCalls)
+	}
+
+	// 重复停用不再写库
+	doSCIM(r, http.MethodDelete, "/scim/v2/Users/uid-alice", "")
+	if len(deps.userRepo.BanCalls) != 1 {
+		t.Errorf("ban calls after repeat = %d, want 1", len(deps.userRepo.BanCalls))
+	}
+
+	if w := doSCIM(r, http.MethodDelete, "/scim/v2/Users/uid-missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("missing user status = %d, want 404", w.Code)
+	}
+}
+
+func TestAdminAccountsProtected(t *testing.T) {
+	r, deps := newTestRouter(t)
+	deps.userRepo.Seed(&models.User{UID: "uid-root", Username: "root", Email: "root@example.com", Role: models.RoleAdmin})
+
+	if w := doSCIM(r, http.MethodDelete, "/scim/v2/Users/uid-root", ""); w.Code != http.StatusForbidden {
+		t.Errorf("delete admin status = %d, want 403", w.Code)
+	}
+	if w := doSCIM(r, http.MethodPatch, "/scim/v2/Users/uid-root", `{"Operations":[{"op":"replace","path":"userName","value":"owned"}]}`); w.Code != http.StatusForbidden {
+		t.Errorf("patch admin status = %d, want 403", w.Code)
+	}
+	if len(deps.userRepo.BanCalls) != 0 || len(deps.logs.SCIMLogs) != 0 {
+		t.Errorf("admin account was modified: bans = %v, logs = %v", deps.userRepo.BanCalls, deps.logs.SCIMLogs)
+	}
+}
+
+func TestDelegatedAdminProtected(t *testing.T) {
+	r, deps := newTestRouter(t)
+	user := seedUser(deps)
+	deps.groups.Seed(&models.UserGroup{Name: "support", Permissions: []string{models.PermUsersRead}}, user.UID)
+
+	// 普通角色但经用户组持有后台权限：改邮箱后可经重置密码接管，必须拒绝
+	if w := doSCIM(r, http.MethodPatch, "/scim/v2/Users/"+user.UID, `{"Operations":[{"op":"replace","path":"emails","value":[{"value":"attacker@example.com","primary":true}]}]}`); w.Code != http.StatusForbidden {
+		t.Errorf("patch delegated admin status = %d, want 403", w.Code)
+	}
+	if w := doSCIM(r, http.MethodDelete, "/scim/v2/Users/"+user.UID, ""); w.Code != http.StatusForbidden {
+		t.Errorf("delete delegated admin status = %d, want 403", w.Code)
+	}
+
+	// 权限查询失败时拒绝写入
+	deps.groups.PermErr = errors.New("db down")
+	if w := doSCIM(r, http.MethodDelete, "/scim/v2/Users/"+user.UID, ""); w.Code != http.StatusInternalServerError {
+		t.Errorf("delete with permission lookup error status = %d, want 500", w.Code)
+	}
+	if len(deps.userRepo.BanCalls) != 0 || len(deps.logs.SCIMLogs) != 0 {
+		t.Errorf("delegated admin was modified: bans = %v, logs = %v", deps.userRepo.BanCalls, deps.logs.SCIMLogs)
+	}
+}
@@ -0,0 +1,642 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"auth-system/internal/models"
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// filterPattern 仅支持 `属性 eq "值"` 形式的过滤器（RFC 7644 3.4.2.2 的最小子集）
+var filterPattern = regexp.MustCompile(`(?i)^\s*([a-z][a-z0-9.]*)\s+eq\s+("(?:[^"\\]|\\.)*")\s*$`)
+
+// userAttributePrefix 属性路径可带完整 schema 前缀
+const userAttributePrefix = "urn:ietf:params:scim:schemas:core:2.0:user:"
+
+// scimEmail 多值属性 emails 的元素
+type scimEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// scimMeta 资源元数据
+type scimMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+// userResource SCIM User 资源（id 为用户 UID）
+type userResource struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id"`
+	UserName    string      `json:"userName"`
+	DisplayName string      `json:"displayName"`
+	Emails      []scimEmail `json:"emails"`
+	Active      bool        `json:"active"`
+	Meta        scimMeta    `json:"meta"`
+}
+
+// listResponse 列表响应
+type listResponse struct {
+	Schemas      []string        `json:"schemas"`
+	TotalResults int64           `json:"totalResults"`
+	StartIndex   int             `json:"startIndex"`
+	ItemsPerPage int             `json:"itemsPerPage"`
+	Resources    []*userResource `json:"Resources"`
+}
+
+// createUserRequest POST /Users 请求体，未列出的属性忽略
+type createUserRequest struct {
+	UserName string      `json:"userName"`
+	Emails   []scimEmail `json:"emails"`
+	Password string      `json:"password"`
+	Active   *bool       `json:"active"`
+}
+
+// patchRequest PATCH /Users/:id 请求体
+type patchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []patchOperation `json:"Operations"`
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// userChanges PATCH 解析出的变更，nil 表示未涉及
+type userChanges struct {
+	username *string
+	email    *string
+	active   *bool
+}
+
+// requestError 请求内容错误，由调用方转为 SCIM 错误响应
+type requestError struct {
+	status   int
+	scimType string
+	detail   string
+}
+
+func (e *requestError) Error() string { return e.detail }
+
+func invalidValue(detail string) error {
+	return &requestError{status: http.StatusBadRequest, scimType: scimTypeInvalidValue, detail: detail}
+}
+
+// respondRequestError 输出 requestError；其他错误视为内部错误
+func respondRequestError(c *gin.Context, err error) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		respondError(c, reqErr.status, reqErr.scimType, reqErr.detail)
+		return
+	}
+	respondError(c, http.StatusInternalServerError, "", "Internal server error")
+}
+
+// toResource 转换为 SCIM User 资源
+func (h *SCIMHandler) toResource(user *models.User) *userResource {
+	return &userResource{
+		Schemas:     []string{SchemaUser},
+		ID:          user.UID,
+		UserName:    user.Username,
+		DisplayName: user.Username,
+		Emails:      []scimEmail{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      !user.CheckBanned(),
+		Meta: scimMeta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     h.baseURL + "/scim/v2/Users/" + user.UID,
+		},
+	}
+}
+
+// findUser 按 UID 查询用户，未找到时返回 (nil, nil)
+func (h *SCIMHandler) findUser(ctx context.Context, uid string) (*models.User, error) {
+	user, err := h.userRepo.FindByUID(ctx, uid)
+	if err != nil {
+		if utils.IsDatabaseNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// ListUsers 列出或按过滤器查询用户
+// GET /scim/v2/Users?filter=userName eq "alice"&startIndex=1&count=100
+func (h *SCIMHandler) ListUsers(c *gin.Context) {
+	startIndex, _ := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	startIndex = max(startIndex, 1)
+	count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(defaultListCount)))
+	if err != nil {
+		count = defaultListCount
+	}
+	count = min(max(count, 0), maxListCount)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), scimTimeout)
+	defer cancel()
+
+	var users []*models.User
+	var total int64
+
+	if filter := c.Query("filter"); filter != "" {
+		user, err := h.findByFilter(ctx, filter)
+		if err != nil {
+			var reqErr *requestError
+			if !errors.As(err, &reqErr) {
+				utils.LogErrorCtx(ctx, "SCIM", "ListUsers", err, "filter", filter)
+			}
+			respondRequestError(c, err)
+			return
+		}
+		if user != nil {
+			total = 1
+			if startIndex == 1 && count > 0 {
+				users = []*models.User{user}
+			}
+		}
+	} else {
+		users, total, err = h.userRepo.FindRange(ctx, startIndex-1, count)
+		if err != nil {
+			utils.LogErrorCtx(ctx, "SCIM", "ListUsers", err)
+			respondError(c, http.StatusInternalServerError, "", "Failed to list users")
+			return
+		}
+	}
+
+	resources := make([]*userResource, 0, len(users))
+	for _, user := range users {
+		resources = append(resources, h.toResource(user))
+	}
+
+	respond(c, http.StatusOK, listResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// findByFilter 解析过滤器并查询，未匹配时返回 (nil, nil)
+func (h *SCIMHandler) findByFilter(ctx context.Context, filter string) (*models.User, error) {
+	match := filterPattern.FindStringSubmatch(filter)
+	if match == nil {
+		return nil, &requestError{status: http.StatusBadRequest, scimType: scimTypeInvalidFilter, detail: `Only 'attribute eq "value"' filters are supported`}
+	}
+	value, err := strconv.Unquote(match[2])
+	if err != nil {
+		return nil, &requestError{status: http.StatusBadRequest, scimType: scimTypeInvalidFilter, detail: "Invalid filter value"}
+	}
+
+	var user *models.User
+	switch strings.TrimPrefix(strings.ToLower(match[1]), userAttributePrefix) {
+	case "id":
+		return h.findUser(ctx, value)
+	case "username":
+		user, err = h.userRepo.FindByUsername(ctx, value)
+	case "emails", "emails.value":
+		result := utils.ValidateEmail(value)
+		if !result.Valid {
+			return nil, nil
+		}
+		user, err = h.userRepo.FindByEmail(ctx, result.Value)
+	default:
+		return nil, &requestError{status: http.StatusBadRequest, scimType: scimTypeInvalidFilter, detail: "Unsupported filter attribute: " + match[1]}
+	}
+	if err != nil {
+		if utils.IsDatabaseNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetUser 获取单个用户
+// GET /scim/v2/Users/:id
+func (h *SCIMHandler) GetUser(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), scimTimeout)
+	defer cancel()
+
+	user, err := h.findUser(ctx, c.Param("id"))
+	if err != nil {
+		utils.LogErrorCtx(ctx, "SCIM", "GetUser", err, "uid", c.Param("id"))
+		respondError(c, http.StatusInternalServerError, "", "Failed to query user")
+		return
+	}
+	if user == nil {
+		respondError(c, http.StatusNotFound, "", "User not found")
+		return
+	}
+
+	respond(c, http.StatusOK, h.toResource(user))
+}
+
+// CreateUser 创建用户：不经过邮箱验证码与邮箱域名白名单；未提供密码时设置随机密码，
+// 用户需通过找回密码或第三方登录进入账户
+// POST /scim/v2/Users
+func (h *SCIMHandler) CreateUser(c *gin.Context) {
+	client := getClient(c)
+
+	var req createUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, scimTypeInvalidSyntax, "Invalid request body")
+		return
+	}
+
+	usernameResult := utils.ValidateUsername(req.UserName)
+	if !usernameResult.Valid {
+		respondError(c, http.StatusBadRequest, scimTypeInvalidValue, "Invalid userName: "+usernameResult.ErrorCode)
+		return
+	}
+	email, err := primaryEmail(req.Emails)
+	if err != nil {
+		respondRequestError(c, err)
+		return
+	}
+
+	password := req.Password
+	if password != "" {
+		if result := utils.ValidatePassword(password); !result.Valid {
+			respondError(c, http.StatusBadRequest, scimTypeInvalidValue, "Invalid password: "+result.ErrorCode)
+			return
+		}
+		if code := h.passwordScreener.Screen(password, usernameResult.Value, email).ErrorCode(); code != "" {
+			utils.LogWarnCtx(c.Request.Context(), "SCIM", "Provisioned password rejected by screening", "client_id", client.ClientID, "reason", code)
+			respondError(c, http.StatusBadRequest, scimTypeInvalidValue, "Invalid password: "+code)
+			return
+		}
+	} else if password, err = utils.GenerateSecureToken(); err != nil {
+		utils.LogErrorCtx(c.Request.Context(), "SCIM", "CreateUser", err)
+		respondError(c, http.StatusInternalServerError, "", "Failed to create user")
+		return
+	}
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "", "Failed to create user")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), scimTimeout)
+	defer cancel()
+
+	user := &models.User{
+		Username: usernameResult.Value,
+		Email:    email,
+		Password: hashedPassword,
+	}
+	if err := h.userRepo.Create(ctx, user); err != nil {
+		if errors.Is(err, models.ErrEmailExists) || errors.Is(err, models.ErrUsernameExists) {
+			respondError(c, http.StatusConflict, scimTypeUniqueness, err.Error())
+			return
+		}
+		utils.LogErrorCtx(ctx, "SCIM", "CreateUser", err, "client_id", client.ClientID)
+		respondError(c, http.StatusInternalServerError, "", "Failed to create user")
+		return
+	}
+
+	if h.userLogRepo != nil {
+		if err := h.userLogRepo.LogRegister(ctx, user.UID); err != nil {
+			utils.LogWarnCtx(ctx, "SCIM", "Failed to log register", "user_uid", user.UID, "error", err)
+		}
+	}
+	if err := h.logRepo.LogSCIMUserCreate(ctx, client, user.UID, user.Username); err != nil {
+		utils.LogWarnCtx(ctx, "SCIM", "Failed to log scim_user_create", "error", err)
+	}
+
+	if req.Active != nil && !*req.Active {
+		if err := h.deactivate(ctx, client, user); err != nil {
+			utils.LogErrorCtx(ctx, "SCIM", "CreateUser", err, "user_uid", user.UID)
+			respondError(c, http.StatusInternalServerError, "", "User created but could not be deactivated")
+			return
+		}
+		user.IsBanned = true
+	}
+
+	utils.LogInfoCtx(ctx, "SCIM", "User provisioned", "client_id", client.ClientID, "user_uid", user.UID, "username", user.Username)
+
+	resource := h.toResource(user)
+	c.Header("Location", resource.Meta.Location)
+	respond(c, http.StatusCreated, resource)
+}
+
+// PatchUser 修改用户：支持 add / replace 操作的 userName、emails、active 属性，其余属性忽略。
+// 管理员账户不允许通过 SCIM 修改
+// PATCH /scim/v2/Users/:id
+func (h *SCIMHandler) PatchUser(c *gin.Context) {
+	client := getClient(c)
+
+	var req patchRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Operations) == 0 {
+		respondError(c, http.StatusBadRequest, scimTypeInvalidSyntax, "Invalid PatchOp request body")
+		return
+	}
+
+	changes, err := parsePatch(req.Operations)
+	if err != nil {
+		respondRequestError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), scimTimeout)
+	defer cancel()
+
+	user, ok := h.findWritableUser(ctx, c)
+	if !ok {
+		return
+	}
+
+	updates := map[string]any{}
+	var attributes []string
+	if changes.username != nil && *changes.username != user.Username {
+		updates["username"] = *changes.username
+		attributes = append(attributes, "userName")
+	}
+	if changes.email != nil && *changes.email != user.Email {
+		updates["email"] = *changes.email
+		attributes = append(attributes, "emails")
+	}
+
+	oldUsername, oldEmail := user.Username, user.Email
+	if len(updates) > 0 {
+		if err := h.userRepo.Update(ctx, user.UID, updates); err != nil {
+			if errors.Is(err, models.ErrEmailExists) || errors.Is(err, models.ErrUsernameExists) {
+				respondError(c, http.StatusConflict, scimTypeUniqueness, err.Error())
+				return
+			}
+			utils.LogErrorCtx(ctx, "SCIM", "PatchUser", err, "user_uid", user.UID)
+			respondError(c, http.StatusInternalServerError, "", "Failed to update user")
+			return
+		}
+		h.userCache.Invalidate(user.UID)
+
+		if _, ok := updates["username"]; ok && h.userLogRepo != nil {
+			if err := h.userLogRepo.LogChangeUsername(ctx, user.UID, oldUsername, *changes.username); err != nil {
+				utils.LogWarnCtx(ctx, "SCIM", "Failed to log username change", "user_uid", user.UID, "error", err)
+			}
+		}
+		if _, ok := updates["email"]; ok && h.userLogRepo != nil {
+			if err := h.userLogRepo.LogChangeEmail(ctx, user.UID, oldEmail, *changes.email); err != nil {
+				utils.LogWarnCtx(ctx, "SCIM", "Failed to log email change", "user_uid", user.UID, "error", err)
+			}
+		}
+		if err := h.logRepo.LogSCIMUserUpdate(ctx, client, user.UID, oldUsername, attributes); err != nil {
+			utils.LogWarnCtx(ctx, "SCIM", "Failed to log scim_user_update", "error", err)
+		}
+	}
+
+	if changes.active != nil {
+		if *changes.active {
+			err = h.reactivate(ctx, client, user)
+		} else {
+			err = h.deactivate(ctx, client, user)
+		}
+		if err != nil {
+			utils.LogErrorCtx(ctx, "SCIM", "PatchUser", err, "user_uid", user.UID)
+			respondError(c, http.StatusInternalServerError, "", "Failed to update user status")
+			return
+		}
+	}
+
+	user, err = h.findUser(ctx, user.UID)
+	if err != nil || user == nil {
+		respondError(c, http.StatusInternalServerError, "", "Failed to reload user")
+		return
+	}
+
+	utils.LogInfoCtx(ctx, "SCIM", "User patched", "client_id", client.ClientID, "user_uid", user.UID, "attributes", attributes)
+
+	respond(c, http.StatusOK, h.toResource(user))
+}
+
+// DeleteUser 停用用户（不删除账户，等价于 active=false）
+// DELETE /scim/v2/Users/:id
+func (h *SCIMHandler) DeleteUser(c *gin.Context) {
+	client := getClient(c)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), scimTimeout)
+	defer cancel()
+
+	user, ok := h.findWritableUser(ctx, c)
+	if !ok {
+		return
+	}
+
+	if err := h.deactivate(ctx, client, user); err != nil {
+		utils.LogErrorCtx(ctx, "SCIM", "DeleteUser", err, "user_uid", user.UID)
+		respondError(c, http.StatusInternalServerError, "", "Failed to deactivate user")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// findWritableUser 查询写操作的目标用户；不存在或拥有任何后台权限（管理员角色或用户组授予）时输出错误并返回 false。
+// 仅看角色不够：普通用户可经用户组获得后台权限，SCIM 改其邮箱后即可通过重置密码接管该账户
+func (h *SCIMHandler) findWritableUser(ctx context.Context, c *gin.Context) (*models.User, bool) {
+	user, err := h.findUser(ctx, c.Param("id"))
+	if err != nil {
+		utils.LogErrorCtx(ctx, "SCIM", "findWritableUser", err, "uid", c.Param("id"))
+		respondError(c, http.StatusInternalServerError, "", "Failed to query user")
+		return nil, false
+	}
+	if user == nil {
+		respondError(c, http.StatusNotFound, "", "User not found")
+		return nil, false
+	}
+	perms, err := models.ResolvePermissions(ctx, user, h.permissionReader)
+	if err != nil {
+		utils.LogErrorCtx(ctx, "SCIM", "findWritableUser", err, "Failed to resolve permissions", "uid", user.UID)
+		respondError(c, http.StatusInternalServerError, "", "Failed to query user")
+		return nil, false
+	}
+	if len(perms) > 0 {
+		utils.LogWarnCtx(ctx, "SCIM", "Attempted to modify admin via SCIM", "client_id", getClient(c).ClientID, "user_uid", user.UID)
+		respondError(c, http.StatusForbidden, "", "Administrator accounts cannot be managed via SCIM")
+		return nil, false
+	}
+	return user, true
+}
+
+// deactivate 以 deprovisioned 原因永久封禁用户；已永久封禁时不做变更，限时封禁会被覆盖为永久
+func (h *SCIMHandler) deactivate(ctx context.Context, client *models.OAuthClient, user *models.User) error {
+	if user.IsPermanentBan() {
+		return nil
+	}
+
+	if err := h.userRepo.Ban(ctx, user.UID, models.SCIMActorUID, BanReasonDeprovisioned, nil); err != nil {
+		return err
+	}
+	h.userCache.Invalidate(user.UID)
+
+	if err := h.logRepo.LogSCIMUserDeactivate(ctx, client, user.UID, user.Username); err != nil {
+		utils.LogWarnCtx(ctx, "SCIM", "Failed to log scim_user_deactivate", "error", err)
+	}
+	if h.userLogRepo != nil {
+		if err := h.userLogRepo.LogBanned(ctx, user.UID, BanReasonDeprovisioned, nil); err != nil {
+			utils.LogWarnCtx(ctx, "SCIM", "Failed to log user banned", "user_uid", user.UID, "error", err)
+		}
+	}
+
+	utils.LogInfoCtx(ctx, "SCIM", "User deactivated", "client_id", client.ClientID, "user_uid", user.UID)
+	return nil
+}
+
+// reactivate 解除 SCIM 停用；管理员因其他原因的封禁不受影响
+func (h *SCIMHandler) reactivate(ctx context.Context, client *models.OAuthClient, user *models.User) error {
+	if !user.IsBanned || user.BanReason.String != BanReasonDeprovisioned {
+		return nil
+	}
+
+	if err := h.userRepo.Unban(ctx, user.UID); err != nil {
+		return err
+	}
+	h.userCache.Invalidate(user.UID)
+
+	if err := h.logRepo.LogSCIMUserReactivate(ctx, client, user.UID, user.Username); err != nil {
+		utils.LogWarnCtx(ctx, "SCIM", "Failed to log scim_user_reactivate", "error", err)
+	}
+	if h.userLogRepo != nil {
+		if err := h.userLogRepo.LogUnbanned(ctx, user.UID); err != nil {
+			utils.LogWarnCtx(ctx, "SCIM", "Failed to log user unbanned", "user_uid", user.UID, "error", err)
+		}
+	}
+
+	utils.LogInfoCtx(ctx, "SCIM", "User reactivated", "client_id", client.ClientID, "user_uid", user.UID)
+	return nil
+}
+
+// parsePatch 解析并校验全部操作；任一操作无效时整个请求不生效
+func parsePatch(operations []patchOperation) (*userChanges, error) {
+	changes := &userChanges{}
+	for _, op := range operations {
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+		case "remove":
+			return nil, &requestError{status: http.StatusBadRequest, scimType: scimTypeMutability, detail: "Remove operations are not supported"}
+		default:
+			return nil, &requestError{status: http.StatusBadRequest, scimType: scimTypeInvalidSyntax, detail: "Unsupported operation: " + op.Op}
+		}
+
+		if op.Path != "" {
+			if err := changes.apply(op.Path, op.Value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// 无 path 时 value 为属性对象
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &attrs); err != nil {
+			return nil, invalidValue("Operation without path requires an object value")
+		}
+		for path, value := range attrs {
+			if err := changes.apply(path, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+// apply 应用单个属性；不支持修改的属性忽略
+func (u *userChanges) apply(path string, value json.RawMessage) error {
+	path = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(path)), userAttributePrefix)
+
+	switch {
+	case path == "username":
+		var username string
+		if err := json.Unmarshal(value, &username); err != nil {
+			return invalidValue("userName must be a string")
+		}
+		result := utils.ValidateUsername(username)
+		if !result.Valid {
+			return invalidValue("Invalid userName: " + result.ErrorCode)
+		}
+		u.username = &result.Value
+
+	case path == "emails":
+		var emails []scimEmail
+		if err := json.Unmarshal(value, &emails); err != nil {
+			return invalidValue("emails must be an array")
+		}
+		email, err := primaryEmail(emails)
+		if err != nil {
+			return err
+		}
+		u.email = &email
+
+	case strings.HasPrefix(path, "emails[") && strings.HasSuffix(path, "].value"):
+		var email string
+		if err := json.Unmarshal(value, &email); err != nil {
+			return invalidValue("emails.value must be a string")
+		}
+		email, err := primaryEmail([]scimEmail{{Value: email}})
+		if err != nil {
+			return err
+		}
+		u.email = &email
+
+	case path == "active":
+		active, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		u.active = &active
+
+	case path == "password":
+		return &requestError{status: http.StatusBadRequest, scimType: scimTypeMutability, detail: "Password cannot be changed via SCIM"}
+	}
+	return nil
+}
+
+// primaryEmail 取 primary 邮箱（没有时取第一个）并校验
+func primaryEmail(emails []scimEmail) (string, error) {
+	if len(emails) == 0 {
+		return "", invalidValue("At least one email is required")
+	}
+	email := emails[0].Value
+	for _, e := range emails {
+		if e.Primary {
+			email = e.Value
+			break
+		}
+	}
+
+	result := utils.ValidateEmail(email)
+	if !result.Valid {
+		return "", invalidValue("Invalid email: " + result.ErrorCode)
+	}
+	return result.Value, nil
+}
+
+// parseBool 解析布尔值；兼容部分身份源以字符串 "True" / "False" 发送的写法
+func parseBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+	}
+	return false, invalidValue("active must be a boolean")
+}
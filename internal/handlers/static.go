@@ -226,6 +226,24 @@ func ServeOAuthPage(c *gin.Context) {
 	serveHTML(c, DistAccountPages, "oauth.html")
 }
 
+// ServeDevicePage 服务设备授权页面（输入 user_code）
+// GET /account/device
+func ServeDevicePage(c *gin.Context) {
+	serveHTML(c, DistAccountPages, "device.html")
+}
+
+// ServeSecurePage 服务新设备登录提醒的 "不是我本人" 页面
+// GET /account/secure
+func ServeSecurePage(c *gin.Context) {
+	serveHTML(c, DistAccountPages, "secure.html")
+}
+
+// ServeUnlockPage 服务账户锁定通知邮件中的解锁页面
+// GET /account/unlock
+func ServeUnlockPage(c *gin.Context) {
+	serveHTML(c, DistAccountPages, "unlock.html")
+}
+
 // ServePolicyPage 服务政策中心 SPA 页面
 // GET /policy
 // 支持 hash 路由：/policy#privacy, /policy#terms, /policy#cookies
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 
 	"auth-system/internal/handlers"
@@ -306,6 +307,7 @@ func getDataExportFooter(lang string, utcTime string) string {
 }
 
 // RequestDataExport 请求数据导出（生成一次性下载 Token）
+// 浏览器拿到的 Token 会被立即下载消费，导出就绪邮件另外附带一个独立的一次性下载链接
 // POST /api/user/export/request
 func (h *UserHandler) RequestDataExport(c *gin.Context) {
 	userUID, ok := middleware.GetUID(c)
@@ -332,6 +334,8 @@ func (h *UserHandler) RequestDataExport(c *gin.Context) {
 		return
 	}
 
+	h.sendExportReadyNotice(c, userUID)
+
 	utils.LogInfoCtx(c.Request.Context(), "USER", "Data export token generated", "user_uid", userUID)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -339,6 +343,26 @@ func (h *UserHandler) RequestDataExport(c *gin.Context) {
 	})
 }
 
+// sendExportReadyNotice 向用户邮箱发送导出就绪通知，失败只记录日志，不影响浏览器下载
+func (h *UserHandler) sendExportReadyNotice(c *gin.Context, userUID string) {
+	user, err := h.userRepo.FindByUID(c.Request.Context(), userUID)
+	if err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "USER", "Failed to load user for export notice", "user_uid", userUID, "error", err)
+		return
+	}
+
+	token, err := h.exportTokenService.Generate(userUID)
+	if err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "USER", "Failed to generate export notice token", "user_uid", userUID, "error", err)
+		return
+	}
+
+	h.emailService.SendExportReadyNotice(user.Email, "", &services.ExportReadyEmailData{
+		DownloadURL: h.baseURL + "/api/user/export/" + url.PathEscape(token),
+		ExpiresAt:   time.Now().Add(services.ExportTokenTTL),
+	})
+}
+
 // DownloadUserData 下载用户数据
 // GET /api/user/export/:token
 func (h *UserHandler) DownloadUserData(c *gin.Context) {
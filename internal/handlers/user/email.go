@@ -0,0 +1,279 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"auth-system/internal/handlers"
+	"auth-system/internal/middleware"
+	"auth-system/internal/models"
+	"auth-system/internal/paths"
+	"auth-system/internal/services"
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 修改邮箱分三步：
+//  1. send-code：向当前邮箱发送验证链接（证明账户持有者）
+//  2. send-new-code：凭当前邮箱验证码提交新邮箱，校验白名单后向新邮箱发送验证链接（证明新地址可收信）
+//  3. PATCH /email：两个验证码同时有效时完成修改，并登出其他设备
+// 服务端不保存待修改状态，新邮箱验证码本身绑定新地址，第 3 步重新校验全部条件
+
+type sendChangeEmailCodeRequest struct {
+	CaptchaToken string `json:"captchaToken"`
+	Language     string `json:"language"`
+}
+
+type sendNewEmailCodeRequest struct {
+	CurrentCode string `json:"currentCode"`
+	NewEmail    string `json:"newEmail"`
+	Language    string `json:"language"`
+}
+
+// changeEmailRequest 修改邮箱请求
+type changeEmailRequest struct {
+	CurrentCode string `json:"currentCode"`
+	NewEmail    string `json:"newEmail"`
+	NewCode     string `json:"newCode"`
+}
+
+// SendChangeEmailCode 向当前邮箱发送修改邮箱验证码
+// POST /api/user/email/send-code
+func (h *UserHandler) SendChangeEmailCode(c *gin.Context) {
+	userUID, ok := middleware.GetUID(c)
+	if !ok {
+		utils.HTTPErrorResponse(c, "USER", http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized access to SendChangeEmailCode")
+		return
+	}
+
+	var req sendChangeEmailCodeRequest
+	if !utils.BindJSONOrError(c, "USER", &req, "INVALID_REQUEST") {
+		return
+	}
+	ctx := c.Request.Context()
+
+	user, err := h.userRepo.FindByUID(ctx, userUID)
+	if err != nil {
+		utils.HTTPDatabaseError(c, "USER", err, "USER_NOT_FOUND")
+		return
+	}
+
+	if err := h.verifyCaptcha(req.CaptchaToken, utils.GetClientIP(c)); err != nil {
+		utils.HTTPErrorResponse(c, "USER", http.StatusBadRequest, "CAPTCHA_FAILED", fmt.Sprintf("Captcha verification failed for change email code: userUID=%s", userUID))
+		return
+	}
+
+	if !h.limiterMgr.EmailAllow(user.Email) {
+		utils.HTTPErrorResponse(c, "USER", http.StatusTooManyRequests, "RATE_LIMIT", fmt.Sprintf("Email rate limit exceeded for change email: email=%s", user.Email))
+		return
+	}
+
+	if !h.sendChangeEmailLink(c, user.Email, services.TokenTypeChangeEmail, req.Language) {
+		return
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "USER", "Change email code sent (async)", "user_uid", userUID, "email", user.Email)
+	utils.RespondSuccess(c, gin.H{})
+}
+
+// SendNewEmailCode 校验当前邮箱验证码后向新邮箱发送验证码
+// POST /api/user/email/send-new-code
+func (h *UserHandler) SendNewEmailCode(c *gin.Context) {
+	userUID, ok := middleware.GetUID(c)
+	if !ok {
+		utils.HTTPErrorResponse(c, "USER", http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized access to SendNewEmailCode")
+		return
+	}
+
+	var req sendNewEmailCodeRequest
+	if !utils.BindJSONOrError(c, "USER", &req, "INVALID_REQUEST") {
+		return
+	}
+
+	currentCode := strings.TrimSpace(req.CurrentCode)
+	if currentCode == "" {
+		utils.HTTPErrorResponse(c, "USER", http.StatusBadRequest, "MISSING_PARAMETERS", fmt.Sprintf("Missing current code for new email code: userUID=%s", userUID))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, err := h.userRepo.FindByUID(ctx, userUID)
+	if err != nil {
+		utils.HTTPDatabaseError(c, "USER", err, "USER_NOT_FOUND")
+		return
+	}
+
+	if _, err := h.tokenService.VerifyCode(ctx, currentCode, user.Email, services.TokenTypeChangeEmail); err != nil {
+		handlers.RespondTokenError(c, "USER", err, fmt.Sprintf("Change email - current code verification failed: userUID=%s", userUID))
+		return
+	}
+
+	newEmail, ok := h.validateNewEmail(c, user, req.NewEmail)
+	if !ok {
+		return
+	}
+
+	if !h.limiterMgr.EmailAllow(newEmail) {
+		utils.HTTPErrorResponse(c, "USER", http.StatusTooManyRequests, "RATE_LIMIT", fmt.Sprintf("Email rate limit exceeded for new email: email=%s", newEmail))
+		return
+	}
+
+	if !h.sendChangeEmailLink(c, newEmail, services.TokenTypeChangeEmailNew, req.Language) {
+		return
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "USER", "New email code sent (async)", "user_uid", userUID, "new_email", newEmail)
+	utils.RespondSuccess(c, gin.H{})
+}
+
+// ChangeEmail 凭当前邮箱与新邮箱的验证码修改邮箱，成功后登出其他设备
+// PATCH /api/user/email
+func (h *UserHandler) ChangeEmail(c *gin.Context) {
+	userUID, ok := middleware.GetUID(c)
+	if !ok {
+		utils.HTTPErrorResponse(c, "USER", http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized access to ChangeEmail")
+		return
+	}
+
+	var req changeEmailRequest
+	if !utils.BindJSONOrError(c, "USER", &req, "INVALID_REQUEST") {
+		return
+	}
+
+	currentCode := strings.TrimSpace(req.CurrentCode)
+	newCode := strings.TrimSpace(req.NewCode)
+	if currentCode == "" || newCode == "" {
+		utils.HTTPErrorResponse(c, "USER", http.StatusBadRequest, "MISSING_PARAMETERS", fmt.Sprintf("Missing codes for change email: userUID=%s", userUID))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, err := h.userRepo.FindByUID(ctx, userUID)
+	if err != nil {
+		utils.HTTPDatabaseError(c, "USER", err, "USER_NOT_FOUND")
+		return
+	}
+	oldEmail := user.Email
+
+	if _, err := h.tokenService.VerifyCode(ctx, currentCode, oldEmail, services.TokenTypeChangeEmail); err != nil {
+		handlers.RespondTokenError(c, "USER", err, fmt.Sprintf("Change email - current code verification failed: userUID=%s", userUID))
+		return
+	}
+
+	// 白名单与占用情况可能在发送新邮箱验证码后发生变化，此处重新校验
+	newEmail, ok := h.validateNewEmail(c, user, req.NewEmail)
+	if !ok {
+		return
+	}
+
+	if _, err := h.tokenService.VerifyCode(ctx, newCode, newEmail, services.TokenTypeChangeEmailNew); err != nil {
+		handlers.RespondTokenError(c, "USER", err, fmt.Sprintf("Change email - new code verification failed: userUID=%s, new_email=%s", userUID, newEmail))
+		return
+	}
+
+	// 原子消费两个验证码：并发重放时只有一个请求能走到修改邮箱
+	if err := h.tokenService.UseCode(ctx, currentCode, oldEmail); err != nil {
+		handlers.RespondTokenError(c, "USER", err, fmt.Sprintf("Change email - current code consume failed: userUID=%s", userUID))
+		return
+	}
+	if err := h.tokenService.UseCode(ctx, newCode, newEmail); err != nil {
+		handlers.RespondTokenError(c, "USER", err, fmt.Sprintf("Change email - new code consume failed: userUID=%s", userUID))
+		return
+	}
+
+	if err := h.userRepo.Update(ctx, userUID, map[string]any{"email": newEmail}); err != nil {
+		if errors.Is(err, models.ErrEmailExists) {
+			utils.HTTPErrorResponse(c, "USER", http.StatusConflict, "EMAIL_ALREADY_EXISTS", fmt.Sprintf("Email already exists: email=%s", newEmail))
+			return
+		}
+		utils.HTTPErrorResponse(c, "USER", http.StatusInternalServerError, "UPDATE_FAILED", fmt.Sprintf("Failed to update email: userUID=%s", userUID))
+		return
+	}
+
+	// 邮箱是登录与找回密码的凭据，修改后登出其他设备；无会话 ID 的旧 token 无法区分当前设备，全部登出
+	if _, err := h.sessionService.RevokeOtherSessions(ctx, userUID, middleware.GetSessionID(c)); err != nil {
+		utils.LogWarnCtx(c.Request.Context(), "USER", "Failed to revoke other sessions after email change", "user_uid", userUID)
+	}
+
+	h.invalidateUserCache(c.Request.Context(), userUID)
+
+	changeEmailType := services.TokenTypeChangeEmail
+	changeEmailNewType := services.TokenTypeChangeEmailNew
+	_ = h.tokenService.InvalidateCodeByEmail(ctx, oldEmail, &changeEmailType)
+	_ = h.tokenService.InvalidateCodeByEmail(ctx, newEmail, &changeEmailNewType)
+
+	if h.userLogRepo != nil {
+		if err := h.userLogRepo.LogChangeEmail(ctx, userUID, oldEmail, newEmail); err != nil {
+			utils.LogWarnCtx(c.Request.Context(), "USER", "Failed to log email change", "user_uid", userUID)
+		}
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "USER", "Email updated", "user_uid", userUID, "old_email", oldEmail, "new_email", newEmail)
+	utils.RespondSuccess(c, gin.H{"email": newEmail})
+}
+
+// validateNewEmail 校验新邮箱格式、与当前邮箱不同、域名在白名单内且未被占用；失败时已写入响应
+func (h *UserHandler) validateNewEmail(c *gin.Context, user *models.User, email string) (string, bool) {
+	emailResult := utils.ValidateEmail(email)
+	if !emailResult.Valid {
+		utils.HTTPErrorResponse(c, "USER", http.StatusBadRequest, emailResult.ErrorCode, fmt.Sprintf("Email validation failed: email=%s", email))
+		return "", false
+	}
+	newEmail := emailResult.Value
+
+	if strings.EqualFold(newEmail, user.Email) {
+		utils.HTTPErrorResponse(c, "USER", http.StatusBadRequest, "SAME_EMAIL", fmt.Sprintf("New email same as current: userUID=%s", user.UID))
+		return "", false
+	}
+
+	ctx := c.Request.Context()
+
+	if h.emailWhitelistRepo != nil {
+		domain := strings.Split(newEmail, "@")[1]
+		isAllowed, _, err := h.emailWhitelistRepo.IsDomainAllowed(ctx, domain)
+		if err != nil {
+			utils.HTTPErrorResponse(c, "USER", http.StatusInternalServerError, "WHITELIST_CHECK_FAILED", fmt.Sprintf("Failed to check email whitelist: %v", err))
+			return "", false
+		}
+		if !isAllowed {
+			utils.HTTPErrorResponse(c, "USER", http.StatusForbidden, "EMAIL_DOMAIN_NOT_ALLOWED", fmt.Sprintf("Email domain %s is not in whitelist", domain))
+			return "", false
+		}
+	}
+
+	existingUser, err := h.userRepo.FindByEmail(ctx, newEmail)
+	if err != nil && !utils.IsDatabaseNotFound(err) {
+		utils.HTTPDatabaseError(c, "USER", err)
+		return "", false
+	}
+	if existingUser != nil {
+		utils.HTTPErrorResponse(c, "USER", http.StatusConflict, "EMAIL_ALREADY_EXISTS", fmt.Sprintf("Email already exists: email=%s", newEmail))
+		return "", false
+	}
+
+	return newEmail, true
+}
+
+// sendChangeEmailLink 创建验证码并异步发送验证链接；失败时已写入响应
+func (h *UserHandler) sendChangeEmailLink(c *gin.Context, email, tokenType, language string) bool {
+	token, _, err := h.tokenService.CreateToken(c.Request.Context(), email, tokenType)
+	if err != nil {
+		utils.HTTPErrorResponse(c, "USER", http.StatusInternalServerError, "TOKEN_CREATE_FAILED", fmt.Sprintf("Token creation failed: email=%s, type=%s", email, tokenType))
+		return false
+	}
+
+	verifyURL := h.baseURL + paths.PathAccountVerify + "#token=" + token
+
+	if language == "" {
+		language = "zh-CN"
+	}
+
+	// 邮件类型与验证码类型同名，文案见 data/email-texts.json
+	h.emailService.SendVerificationEmailAsync(email, tokenType, language, verifyURL, "USER")
+	return true
+}
@@ -14,6 +14,7 @@ import (
 var (
 	ErrUserHandlerNilUserRepo       = errors.New("user repository is nil")
 	ErrUserHandlerNilTokenService   = errors.New("token service is nil")
+	ErrUserHandlerNilSessionService = errors.New("session service is nil")
 	ErrUserHandlerNilEmailService   = errors.New("email service is nil")
 	ErrUserHandlerNilCaptchaService = errors.New("captcha service is nil")
 	ErrUserHandlerNilUserCache      = errors.New("user cache is nil")
@@ -25,11 +26,13 @@ type UserHandler struct {
 	userRepo           models.UserReadWriter
 	userLogRepo        models.UserLogStore
 	tokenService       services.TokenManager
+	sessionService     services.SessionManager
 	emailService       services.EmailSender
 	captchaService     services.CaptchaVerifier
 	userCache          services.UserCacheStore
 	storageService     services.StorageService
 	oauthService       services.OAuthGrantManager
+	emailWhitelistRepo models.EmailWhitelistStore
 	limiterMgr         middleware.RateLimiterManager
 	exportTokenService services.ExportTokenManager
 	baseURL            string
@@ -37,16 +40,18 @@ type UserHandler struct {
 }
 
 // NewUserHandler 创建用户管理 Handler，验证所有必需依赖后初始化。
-// storageService 和 oauthService 为可选参数。
+// storageService、oauthService 和 emailWhitelistRepo 为可选参数。
 func NewUserHandler(
 	userRepo models.UserReadWriter,
 	userLogRepo models.UserLogStore,
 	tokenService services.TokenManager,
+	sessionService services.SessionManager,
 	emailService services.EmailSender,
 	captchaService services.CaptchaVerifier,
 	userCache services.UserCacheStore,
 	storageService services.StorageService,
 	oauthService services.OAuthGrantManager,
+	emailWhitelistRepo models.EmailWhitelistStore,
 	limiterMgr middleware.RateLimiterManager,
 	exportTokenService services.ExportTokenManager,
 	baseURL string,
@@ -58,6 +63,9 @@ func NewUserHandler(
 	if tokenService == nil {
 		return nil, ErrUserHandlerNilTokenService
 	}
+	if sessionService == nil {
+		return nil, ErrUserHandlerNilSessionService
+	}
 	if emailService == nil {
 		return nil, ErrUserHandlerNilEmailService
 	}
@@ -77,11 +85,13 @@ func NewUserHandler(
 		userRepo:           userRepo,
 		userLogRepo:        userLogRepo,
 		tokenService:       tokenService,
+		sessionService:     sessionService,
 		emailService:       emailService,
 		captchaService:     captchaService,
 		userCache:          userCache,
 		storageService:     storageService,
 		oauthService:       oauthService,
+		emailWhitelistRepo: emailWhitelistRepo,
 		limiterMgr:         limiterMgr,
 		exportTokenService: exportTokenService,
 		baseURL:            baseURL,
@@ -0,0 +1,126 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"auth-system/internal/middleware"
+	"auth-system/internal/services"
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionView 设备会话展示信息（不暴露 token hash 与原始 User-Agent）
+type sessionView struct {
+	ID         string    `json:"id"`
+	IP         string    `json:"ip"`
+	Browser    string    `json:"browser"`
+	OS         string    `json:"os"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// GetSessions 获取当前用户已登录的设备会话列表
+// GET /api/user/sessions
+func (h *UserHandler) GetSessions(c *gin.Context) {
+	userUID, ok := middleware.GetUID(c)
+	if !ok {
+		utils.RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED")
+		return
+	}
+
+	tokens, err := h.sessionService.ListSessions(c.Request.Context(), userUID)
+	if err != nil {
+		utils.LogErrorCtx(c.Request.Context(), "USER", "GetSessions", err, "user_uid", userUID)
+		utils.RespondError(c, http.StatusInternalServerError, "DATABASE_ERROR")
+		return
+	}
+
+	currentID := middleware.GetSessionID(c)
+	sessions := make([]sessionView, 0, len(tokens))
+	for _, t := range tokens {
+		browser, os := utils.ParseUserAgent(t.UserAgent)
+		sessions = append(sessions, sessionView{
+			ID:         t.FamilyID,
+			IP:         t.IP,
+			Browser:    browser,
+			OS:         os,
+			CreatedAt:  t.CreatedAt,
+			LastUsedAt: t.LastUsedAt,
+			ExpiresAt:  t.ExpiresAt,
+			Current:    currentID != "" && t.FamilyID == currentID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"sessions": sessions,
+	})
+}
+
+// RevokeSession 登出指定设备会话（撤销其 refresh_token 家族）
+// 已签发的 access_token 不可撤销，会在过期后自然失效
+// DELETE /api/user/sessions/:family
+func (h *UserHandler) RevokeSession(c *gin.Context) {
+	userUID, ok := middleware.GetUID(c)
+	if !ok {
+		utils.RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED")
+		return
+	}
+
+	familyID := c.Param("family")
+	if familyID == "" {
+		utils.RespondError(c, http.StatusBadRequest, "MISSING_SESSION_ID")
+		return
+	}
+
+	if err := h.sessionService.RevokeTokenFamily(c.Request.Context(), userUID, familyID); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			utils.RespondError(c, http.StatusNotFound, "SESSION_NOT_FOUND")
+			return
+		}
+		utils.LogErrorCtx(c.Request.Context(), "USER", "RevokeSession", err, "user_uid", userUID, "family_id", familyID)
+		utils.RespondError(c, http.StatusInternalServerError, "REVOKE_FAILED")
+		return
+	}
+
+	// 登出的是当前设备时同时清除 Cookie
+	if familyID == middleware.GetSessionID(c) {
+		utils.ClearTokenCookieGin(c)
+		utils.ClearRefreshTokenCookieGin(c)
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "USER", "Session revoked", "user_uid", userUID, "family_id", familyID)
+	utils.RespondSuccess(c, gin.H{})
+}
+
+// RevokeOtherSessions 登出除当前设备外的所有会话
+// POST /api/user/sessions/revoke-others
+func (h *UserHandler) RevokeOtherSessions(c *gin.Context) {
+	userUID, ok := middleware.GetUID(c)
+	if !ok {
+		utils.RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED")
+		return
+	}
+
+	// 无会话 ID 的 token（升级前签发）无法区分当前设备，要求重新登录后再操作
+	currentID := middleware.GetSessionID(c)
+	if currentID == "" {
+		utils.RespondError(c, http.StatusBadRequest, "CURRENT_SESSION_UNKNOWN")
+		return
+	}
+
+	count, err := h.sessionService.RevokeOtherSessions(c.Request.Context(), userUID, currentID)
+	if err != nil {
+		utils.LogErrorCtx(c.Request.Context(), "USER", "RevokeOtherSessions", err, "user_uid", userUID)
+		utils.RespondError(c, http.StatusInternalServerError, "REVOKE_FAILED")
+		return
+	}
+
+	utils.LogInfoCtx(c.Request.Context(), "USER", "Other sessions revoked", "user_uid", userUID, "count", count)
+	utils.RespondSuccess(c, gin.H{})
+}
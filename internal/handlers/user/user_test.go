@@ -10,6 +10,7 @@ import (
 
 	"auth-system/internal/middleware"
 	"auth-system/internal/models"
+	"auth-system/internal/services"
 	"auth-system/internal/testutil"
 	"auth-system/internal/utils"
 
@@ -20,10 +21,12 @@ import (
 type userTestDeps struct {
 	userRepo    *testutil.FakeUserRepo
 	tokenMgr    *testutil.FakeTokenManager
+	sessions    *testutil.FakeSessionManager
 	captcha     *testutil.FakeCaptcha
 	emailSender *testutil.FakeEmailSender
 	storage     *testutil.FakeStorageService
 	oauthGrants *testutil.FakeOAuthGrants
+	whitelist   *testutil.FakeEmailWhitelist
 }
 
 func newTestUserHandler(t *testing.T) (*UserHandler, *userTestDeps) {
@@ -33,21 +36,25 @@ func newTestUserHandler(t *testing.T) (*UserHandler, *userTestDeps) {
 	deps := &userTestDeps{
 		userRepo:    testutil.NewFakeUserRepo(),
 		tokenMgr:    &testutil.FakeTokenManager{},
+		sessions:    &testutil.FakeSessionManager{},
 		captcha:     &testutil.FakeCaptcha{},
 		emailSender: &testutil.FakeEmailSender{},
 		storage:     &testutil.FakeStorageService{Configured: true},
 		oauthGrants: &testutil.FakeOAuthGrants{},
+		whitelist:   &testutil.FakeEmailWhitelist{Allowed: true},
 	}
 
 	h, err := NewUserHandler(
 		deps.userRepo,
 		&testutil.FakeUserLogStore{},
 		deps.tokenMgr,
+		deps.sessions,
 		deps.emailSender,
 		deps.captcha,
 		&testutil.FakeUserCache{},
 		deps.storage,
 		deps.oauthGrants,
+		deps.whitelist,
 		&testutil.FakeLimiter{EmailAllowed: true},
 		&testutil.FakeExportToken{},
 		"https://test.local",
@@ -210,6 +217,19 @@ func TestSendDeleteCodeSuccess(t *testing.T) {
 	}
 }
 
+func TestRequestDataExportSendsNotice(t *testing.T) {
+	h, deps := newTestUserHandler(t)
+	seedUser(deps, t, "Abcdef1!@#ghijklmn")
+
+	w := postUserJSON(h.RequestDataExport, `{}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"token":"export-token"`) {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if len(deps.emailSender.Notices) != 1 || deps.emailSender.Notices[0] != services.EmailTypeExportReady+":alice@example.com" {
+		t.Errorf("notices = %v, want export_ready notice to alice@example.com", deps.emailSender.Notices)
+	}
+}
+
 func TestSendDeleteCodeCaptchaFailed(t *testing.T) {
 	h, deps := newTestUserHandler(t)
 	seedUser(deps, t, "Abcdef1!@#ghijklmn")
@@ -265,3 +285,180 @@ func TestDeleteAccountMissingParams(t *testing.T) {
 		t.Errorf("status = %d body = %s", w.Code, w.Body.String())
 	}
 }
+
+func TestSendChangeEmailCodeSuccess(t *testing.T) {
+	h, deps := newTestUserHandler(t)
+	seedUser(deps, t, "Abcdef1!@#ghijklmn")
+
+	w := postUserJSON(h.SendChangeEmailCode, `{"captchaToken":"captcha-ok","language":"en"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if len(deps.emailSender.SentEmails) != 1 || deps.emailSender.SentEmails[0] != "alice@example.com" {
+		t.Errorf("change email code should be sent to current email, got %v", deps.emailSender.SentEmails)
+	}
+}
+
+func TestSendNewEmailCodeSuccess(t *testing.T) {
+	h, deps := newTestUserHandler(t)
+	seedUser(deps, t, "Abcdef1!@#ghijklmn")
+
+	w := postUserJSON(h.SendNewEmailCode, `{"currentCode":"A1b2C3","newEmail":"Alice.New@example.com"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if len(deps.emailSender.SentEmails) != 1 || deps.emailSender.SentEmails[0] != "alice.new@example.com" {
+		t.Errorf("verification link should be sent to the new email, got %v", deps.emailSender.SentEmails)
+	}
+}
+
+func TestSendNewEmailCodeRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		newEmail string
+		setup    func(*userTestDeps)
+		status   int
+		code     string
+	}{
+		{"same email", "ALICE@example.com", nil, http.StatusBadRequest, "SAME_EMAIL"},
+		{"domain not allowed", "alice@blocked.test", func(d *userTestDeps) { d.whitelist.Allowed = false }, http.StatusForbidden, "EMAIL_DOMAIN_NOT_ALLOWED"},
+		{"email taken", "bob@example.com", func(d *userTestDeps) {
+			d.userRepo.Seed(&models.User{UID: "uid-2", Username: "bob", Email: "bob@example.com"})
+		}, http.StatusConflict, "EMAIL_ALREADY_EXISTS"},
+		{"current code invalid", "alice.new@example.com", func(d *userTestDeps) { d.tokenMgr.VerifyCodeErr = models.ErrInvalidCode }, http.StatusBadRequest, "INVALID_CODE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, deps := newTestUserHandler(t)
+			seedUser(deps, t, "Abcdef1!@#ghijklmn")
+			if tt.setup != nil {
+				tt.setup(deps)
+			}
+
+			w := postUserJSON(h.SendNewEmailCode, `{"currentCode":"A1b2C3","newEmail":"`+tt.newEmail+`"}`)
+			if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.code) {
+				t.Errorf("status = %d body = %s", w.Code, w.Body.String())
+			}
+			if len(deps.emailSender.SentEmails) != 0 {
+				t.Errorf("no email should be sent, got %v", deps.emailSender.SentEmails)
+			}
+		})
+	}
+}
+
+func TestChangeEmailSuccess(t *testing.T) {
+	h, deps := newTestUserHandler(t)
+	seedUser(deps, t, "Abcdef1!@#ghijklmn")
+	seedSessions(deps)
+
+	w := postUserJSON(h.ChangeEmail, `{"currentCode":"A1b2C3","newEmail":"alice.new@example.com","newCode":"D4e5F6"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"email":"alice.new@example.com"`) {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if user, _ := deps.userRepo.FindByUID(t.Context(), "uid-1"); user.Email != "alice.new@example.com" {
+		t.Errorf("email = %q, want alice.new@example.com", user.Email)
+	}
+	// 无会话 ID 时 uid-1 的会话全部登出，其他用户不受影响
+	if len(deps.sessions.Sessions) != 1 || deps.sessions.Sessions[0].UserUID != "uid-2" {
+		t.Errorf("remaining sessions = %+v", deps.sessions.Sessions)
+	}
+}
+
+func TestChangeEmailMissingCodes(t *testing.T) {
+	h, deps := newTestUserHandler(t)
+	seedUser(deps, t, "Abcdef1!@#ghijklmn")
+
+	w := postUserJSON(h.ChangeEmail, `{"currentCode":"A1b2C3","newEmail":"alice.new@example.com"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "MISSING_PARAMETERS") {
+		t.Errorf("status = %d body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestChangeEmailDomainNoLongerAllowed(t *testing.T) {
+	h, deps := newTestUserHandler(t)
+	seedUser(deps, t, "Abcdef1!@#ghijklmn")
+	deps.whitelist.Allowed = false
+
+	w := postUserJSON(h.ChangeEmail, `{"currentCode":"A1b2C3","newEmail":"alice.new@example.com","newCode":"D4e5F6"}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "EMAIL_DOMAIN_NOT_ALLOWED") {
+		t.Errorf("status = %d body = %s", w.Code, w.Body.String())
+	}
+}
+
+// sessionRequest 以登录用户（uid-1，当前会话 sid）身份请求会话管理接口
+func sessionRequest(h gin.HandlerFunc, method, route, path, sid string) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.Handle(method, route, func(c *gin.Context) {
+		c.Set(middleware.ContextKeyUID, "uid-1")
+		if sid != "" {
+			c.Set(middleware.ContextKeySessionID, sid)
+		}
+		h(c)
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func seedSessions(deps *userTestDeps) {
+	deps.sessions.Sessions = []*models.SessionToken{
+		{UserUID: "uid-1", FamilyID: "fam-a", IP: "203.0.113.1", UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"},
+		{UserUID: "uid-1", FamilyID: "fam-b", IP: "203.0.113.2"},
+		{UserUID: "uid-2", FamilyID: "fam-c"},
+	}
+}
+
+func TestGetSessions(t *testing.T) {
+	h, deps := newTestUserHandler(t)
+	seedSessions(deps)
+
+	w := sessionRequest(h.GetSessions, http.MethodGet, "/sessions", "/sessions", "fam-a")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `"id":"fam-a"`) || !strings.Contains(body, `"browser":"Chrome"`) || strings.Contains(body, "fam-c") {
+		t.Errorf("unexpected sessions: %s", body)
+	}
+	if strings.Count(body, `"current":true`) != 1 {
+		t.Errorf("exactly one session should be current: %s", body)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	h, deps := newTestUserHandler(t)
+	seedSessions(deps)
+
+	// 不能登出他人的会话
+	w := sessionRequest(h.RevokeSession, http.MethodDelete, "/sessions/:family", "/sessions/fam-c", "fam-a")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("foreign session status = %d, want 404", w.Code)
+	}
+
+	w = sessionRequest(h.RevokeSession, http.MethodDelete, "/sessions/:family", "/sessions/fam-b", "fam-a")
+	if w.Code != http.StatusOK || len(deps.sessions.Sessions) != 2 {
+		t.Errorf("status = %d, remaining = %d", w.Code, len(deps.sessions.Sessions))
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("revoking another device should not clear current cookies")
+	}
+}
+
+func TestRevokeOtherSessions(t *testing.T) {
+	h, deps := newTestUserHandler(t)
+	seedSessions(deps)
+
+	w := sessionRequest(h.RevokeOtherSessions, http.MethodPost, "/sessions/revoke-others", "/sessions/revoke-others", "")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "CURRENT_SESSION_UNKNOWN") {
+		t.Errorf("without sid: status = %d body = %s", w.Code, w.Body.String())
+	}
+
+	w = sessionRequest(h.RevokeOtherSessions, http.MethodPost, "/sessions/revoke-others", "/sessions/revoke-others", "fam-a")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	// 当前会话与其他用户的会话保留
+	if len(deps.sessions.Sessions) != 2 || deps.sessions.Sessions[0].FamilyID != "fam-a" || deps.sessions.Sessions[1].FamilyID != "fam-c" {
+		t.Errorf("remaining sessions = %+v", deps.sessions.Sessions)
+	}
+}
@@ -1,10 +1,12 @@
-// Package admin 提供管理员和超级管理员权限中间件，直接查询数据库确保权限实时生效。
+// Package admin 提供后台权限中间件，直接查询数据库确保权限实时生效。
+// 有效权限为 users.role 的内置权限与所属用户组权限的并集（models.ResolvePermissions）。
 package admin
 
 import (
 	"context"
 	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	"auth-system/internal/handlers"
@@ -17,12 +19,14 @@ import (
 )
 
 const (
-	ContextKeyUserRole = "auth-system:userRole"
-	adminCheckTimeout  = 5 * time.Second
+	ContextKeyUserRole    = "auth-system:userRole"
+	ContextKeyPermissions = "auth-system:permissions"
+	adminCheckTimeout     = 5 * time.Second
 )
 
-// AdminMiddleware 管理员权限中间件（role >= 1），直接查数据库确保权限实时生效，必须在 AuthMiddleware 之后使用
-func AdminMiddleware(userRepo models.UserReader) gin.HandlerFunc {
+// AdminMiddleware 后台入口中间件：拥有任一后台权限即可进入，具体接口再由 RequirePermission 把关。
+// 直接查数据库确保权限实时生效，必须在 AuthMiddleware 之后使用；permRepo 为 nil 时只按 role 计算权限
+func AdminMiddleware(userRepo models.UserReader, permRepo models.UserPermissionReader) gin.HandlerFunc {
 	if userRepo == nil {
 		utils.LogError("ADMIN-MW", "AdminMiddleware", fmt.Errorf("UserRepository is nil"))
 		return func(c *gin.Context) {
@@ -44,6 +48,13 @@ func AdminMiddleware(userRepo models.UserReader) gin.HandlerFunc {
 			return
 		}
 
+		// 模拟登录会话不能进入后台，避免借目标用户身份操作
+		if middleware.IsImpersonating(c) {
+			utils.LogWarnCtx(c.Request.Context(), "ADMIN-MW", "Admin access with impersonation session", "user_uid", userUID, "impersonator", middleware.GetImpersonator(c), "ip", clientIP)
+			respondForbidden(c, middleware.ErrCodeImpersonationForbidden)
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(c.Request.Context(), adminCheckTimeout)
 		defer cancel()
 
@@ -60,17 +71,43 @@ func AdminMiddleware(userRepo models.UserReader) gin.HandlerFunc {
 			return
 		}
 
-		if !user.IsAdmin() {
+		perms := resolvePermissions(ctx, user, permRepo)
+		if len(perms) == 0 {
 			utils.LogWarnCtx(c.Request.Context(), "ADMIN-MW", "Unauthorized access attempt", "ip", clientIP)
 			respondForbidden(c, "ACCESS_DENIED")
 			return
 		}
 
 		c.Set(ContextKeyUserRole, user.Role)
+		c.Set(ContextKeyPermissions, perms)
 		c.Next()
 	}
 }
 
+// RequirePermission 接口级权限中间件，要求拥有全部所列权限，必须在 AdminMiddleware 之后使用
+func RequirePermission(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, perm := range perms {
+			if !HasPermission(c, perm) {
+				uid, _ := middleware.GetUID(c)
+				utils.LogWarnCtx(c.Request.Context(), "ADMIN-MW", "Permission denied", "user_uid", uid, "permission", perm, "ip", utils.GetClientIP(c))
+				respondForbidden(c, "PERMISSION_DENIED")
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// resolvePermissions 计算有效权限；用户组查询失败时降级为仅角色权限（只会少给权限，不会多给）
+func resolvePermissions(ctx context.Context, user *models.User, permRepo models.UserPermissionReader) []string {
+	perms, err := models.ResolvePermissions(ctx, user, permRepo)
+	if err != nil {
+		utils.LogWarnCtx(ctx, "ADMIN-MW", "Failed to load group permissions, using role permissions only", "user_uid", user.UID, "error", err)
+	}
+	return perms
+}
+
 // SuperAdminMiddleware 超级管理员权限中间件（role >= 2），直接查数据库确保权限实时生效，必须在 AuthMiddleware 之后使用
 func SuperAdminMiddleware(userRepo models.UserReader) gin.HandlerFunc {
 	if userRepo == nil {
@@ -94,6 +131,13 @@ func SuperAdminMiddleware(userRepo models.UserReader) gin.HandlerFunc {
 			return
 		}
 
+		// 模拟登录会话不能进入后台，避免借目标用户身份操作
+		if middleware.IsImpersonating(c) {
+			utils.LogWarnCtx(c.Request.Context(), "ADMIN-MW", "Admin access with impersonation session", "user_uid", userUID, "impersonator", middleware.GetImpersonator(c), "ip", clientIP)
+			respondForbidden(c, middleware.ErrCodeImpersonationForbidden)
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(c.Request.Context(), adminCheckTimeout)
 		defer cancel()
 
@@ -140,6 +184,26 @@ func GetUserRole(c *gin.Context) (int, bool) {
 	return r, true
 }
 
+// GetPermissions 从 Context 获取当前用户的有效权限
+func GetPermissions(c *gin.Context) []string {
+	if c == nil {
+		return nil
+	}
+
+	perms, exists := c.Get(ContextKeyPermissions)
+	if !exists {
+		return nil
+	}
+
+	p, _ := perms.([]string)
+	return p
+}
+
+// HasPermission 检查当前用户是否拥有指定权限
+func HasPermission(c *gin.Context, perm string) bool {
+	return slices.Contains(GetPermissions(c), perm)
+}
+
 // IsSuperAdmin 检查当前用户是否为超级管理员
 func IsSuperAdmin(c *gin.Context) bool {
 	role, ok := GetUserRole(c)
@@ -159,8 +223,8 @@ func respondForbidden(c *gin.Context, errorCode string) {
 }
 
 // AdminPageMiddleware 管理员页面权限中间件，用于保护后台页面，失败时伪装成 404（隐藏后台入口）。
-// cdnURL 透传给 404 页处理，保证伪装响应也带完整 CSP
-func AdminPageMiddleware(userRepo models.UserReader, sessionService services.SessionManager, cdnURL string) gin.HandlerFunc {
+// 与 AdminMiddleware 一致，拥有任一后台权限即可访问；cdnURL 透传给 404 页处理，保证伪装响应也带完整 CSP
+func AdminPageMiddleware(userRepo models.UserReader, permRepo models.UserPermissionReader, sessionService services.SessionManager, cdnURL string) gin.HandlerFunc {
 	notFound := handlers.NotFoundHandler(cdnURL)
 	if userRepo == nil || sessionService == nil {
 		utils.LogError("ADMIN-MW", "AdminPageMiddleware", fmt.Errorf("UserRepository or SessionService is nil"))
@@ -183,8 +247,11 @@ func AdminPageMiddleware(userRepo models.UserReader, sessionService services.Ses
 
 		// 验证 Token
 		claims, err := sessionService.VerifyToken(token)
-		if err != nil || claims == nil || claims.UID == "" {
-			// Token 无效，伪装成 404
+		if err == nil {
+			err = sessionService.CheckSession(c.Request.Context(), claims)
+		}
+		if err != nil || claims == nil || claims.UID == "" || claims.Impersonator() != "" {
+			// Token 无效、会话已撤销或为模拟登录会话，伪装成 404
 			utils.LogDebugCtx(c.Request.Context(), "ADMIN-MW", "Admin page access with invalid token, showing 404")
 			notFound(c)
 			c.Abort()
@@ -205,8 +272,9 @@ func AdminPageMiddleware(userRepo models.UserReader, sessionService services.Ses
 			return
 		}
 
-		// 检查管理员权限
-		if !user.IsAdmin() {
+		// 检查后台权限
+		perms := resolvePermissions(ctx, user, permRepo)
+		if len(perms) == 0 {
 			// 非管理员，伪装成 404（不暴露后台存在）
 			utils.LogWarnCtx(c.Request.Context(), "ADMIN-MW", "Unauthorized access attempt", "ip", clientIP)
 			notFound(c)
@@ -217,6 +285,7 @@ func AdminPageMiddleware(userRepo models.UserReader, sessionService services.Ses
 		// 将用户 UID 和角色挂载到 Context
 		c.Set(middleware.ContextKeyUID, userUID)
 		c.Set(ContextKeyUserRole, user.Role)
+		c.Set(ContextKeyPermissions, perms)
 		c.Next()
 	}
 }
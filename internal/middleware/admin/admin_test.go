@@ -50,7 +50,7 @@ func seedAdminUser(repo *testutil.FakeUserRepo, uid string, role int) {
 
 func TestAdminMiddlewareUnauthorized(t *testing.T) {
 	repo := testutil.NewFakeUserRepo()
-	w := runAdmin(AdminMiddleware(repo), "", "")
+	w := runAdmin(AdminMiddleware(repo, nil), "", "")
 	if w.Code != http.StatusForbidden {
 		t.Fatalf("status = %d, want 403", w.Code)
 	}
@@ -61,7 +61,7 @@ func TestAdminMiddlewareUnauthorized(t *testing.T) {
 
 func TestAdminMiddlewareUserNotFound(t *testing.T) {
 	repo := testutil.NewFakeUserRepo() // 无用户
-	w := runAdmin(AdminMiddleware(repo), "ghost", "")
+	w := runAdmin(AdminMiddleware(repo, nil), "ghost", "")
 	if w.Code != http.StatusForbidden {
 		t.Fatalf("status = %d, want 403", w.Code)
 	}
@@ -73,7 +73,7 @@ func TestAdminMiddlewareUserNotFound(t *testing.T) {
 func TestAdminMiddlewareAccessDenied(t *testing.T) {
 	repo := testutil.NewFakeUserRepo()
 	seedAdminUser(repo, "u1", models.RoleUser)
-	w := runAdmin(AdminMiddleware(repo), "u1", "")
+	w := runAdmin(AdminMiddleware(repo, nil), "u1", "")
 	if w.Code != http.StatusForbidden {
 		t.Fatalf("status = %d, want 403", w.Code)
 	}
@@ -85,7 +85,7 @@ func TestAdminMiddlewareAccessDenied(t *testing.T) {
 func TestAdminMiddlewareAdminAllowed(t *testing.T) {
 	repo := testutil.NewFakeUserRepo()
 	seedAdminUser(repo, "u1", models.RoleAdmin)
-	w := runAdmin(AdminMiddleware(repo), "u1", "")
+	w := runAdmin(AdminMiddleware(repo, nil), "u1", "")
 	if w.Code != http.StatusOK {
 		t.Fatalf("status = %d, want 200", w.Code)
 	}
@@ -94,6 +94,94 @@ func TestAdminMiddlewareAdminAllowed(t *testing.T) {
 	}
 }
 
+func TestAdminMiddlewareImpersonationDenied(t *testing.T) {
+	repo := testutil.NewFakeUserRepo()
+	seedAdminUser(repo, "u1", models.RoleAdmin)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(middleware.ContextKeyUID, "u1")
+		c.Set(middleware.ContextKeyImpersonator, "root")
+		c.Next()
+	})
+	r.Use(AdminMiddleware(repo, nil))
+	r.GET("/test", func(c *gin.Context) { c.Status(http.StatusOK) })
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), middleware.ErrCodeImpersonationForbidden) {
+		t.Errorf("status = %d body = %s, want 403 (模拟会话不能进入后台)", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminMiddlewareGroupMemberAllowed(t *testing.T) {
+	repo := testutil.NewFakeUserRepo()
+	seedAdminUser(repo, "u1", models.RoleUser)
+	groups := testutil.NewFakeUserGroupRepo()
+	groups.Seed(&models.UserGroup{Name: "support", Permissions: []string{models.PermUsersRead}}, "u1")
+
+	w := runPermission(AdminMiddleware(repo, groups), "u1", models.PermUsersRead)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (组成员拥有 users.read), body = %s", w.Code, w.Body.String())
+	}
+
+	w = runPermission(AdminMiddleware(repo, groups), "u1", models.PermUsersBan)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "PERMISSION_DENIED") {
+		t.Errorf("status = %d body = %s, want 403 PERMISSION_DENIED", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminMiddlewareGroupLookupFailureFallsBackToRole(t *testing.T) {
+	repo := testutil.NewFakeUserRepo()
+	seedAdminUser(repo, "u1", models.RoleAdmin)
+	groups := testutil.NewFakeUserGroupRepo()
+	groups.PermErr = errors.New("db down")
+
+	if w := runPermission(AdminMiddleware(repo, groups), "u1", models.PermUsersBan); w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200 (角色内置权限仍然生效)", w.Code)
+	}
+	if w := runPermission(AdminMiddleware(repo, groups), "u1", models.PermDataExport); w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", w.Code)
+	}
+}
+
+func TestRequirePermissionRoleDefaults(t *testing.T) {
+	repo := testutil.NewFakeUserRepo()
+	seedAdminUser(repo, "admin", models.RoleAdmin)
+	seedAdminUser(repo, "super", models.RoleSuperAdmin)
+
+	tests := []struct {
+		uid  string
+		perm string
+		want int
+	}{
+		{"admin", models.PermUsersBan, http.StatusOK},
+		{"admin", models.PermUsersRole, http.StatusForbidden},
+		{"admin", models.PermOAuthClientsWrite, http.StatusForbidden},
+		{"super", models.PermDataImport, http.StatusOK},
+		{"super", models.PermGroupsWrite, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := runPermission(AdminMiddleware(repo, nil), tt.uid, tt.perm); w.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.uid, tt.perm, w.Code, tt.want)
+		}
+	}
+}
+
+// runPermission 依次挂载 mw 与 RequirePermission(perm) 跑一个请求
+func runPermission(mw gin.HandlerFunc, uid, perm string) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(middleware.ContextKeyUID, uid)
+		c.Next()
+	})
+	r.GET("/test", mw, RequirePermission(perm), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+	return w
+}
+
 // ---------- SuperAdminMiddleware ----------
 
 func TestSuperAdminMiddlewareAdminDenied(t *testing.T) {
@@ -125,7 +213,7 @@ func TestSuperAdminMiddlewareSuperAdminAllowed(t *testing.T) {
 func TestAdminPageNoToken404(t *testing.T) {
 	repo := testutil.NewFakeUserRepo()
 	sess := &testutil.FakeSessionManager{}
-	w := runAdmin(AdminPageMiddleware(repo, sess, ""), "", "")
+	w := runAdmin(AdminPageMiddleware(repo, nil, sess, ""), "", "")
 	if w.Code != http.StatusNotFound {
 		t.Fatalf("status = %d, want 404 (隐藏后台入口)", w.Code)
 	}
@@ -134,7 +222,7 @@ func TestAdminPageNoToken404(t *testing.T) {
 func TestAdminPageInvalidToken404(t *testing.T) {
 	repo := testutil.NewFakeUserRepo()
 	sess := &testutil.FakeSessionManager{VerifyErr: errTestVerify}
-	w := runAdmin(AdminPageMiddleware(repo, sess, ""), "", "token=bad")
+	w := runAdmin(AdminPageMiddleware(repo, nil, sess, ""), "", "token=bad")
 	if w.Code != http.StatusNotFound {
 		t.Fatalf("status = %d, want 404", w.Code)
 	}
@@ -144,17 +232,39 @@ func TestAdminPageNonAdmin404(t *testing.T) {
 	repo := testutil.NewFakeUserRepo()
 	seedAdminUser(repo, "u1", models.RoleUser)
 	sess := &testutil.FakeSessionManager{VerifyResult: &services.Claims{UID: "u1"}}
-	w := runAdmin(AdminPageMiddleware(repo, sess, ""), "", "token=valid")
+	w := runAdmin(AdminPageMiddleware(repo, nil, sess, ""), "", "token=valid")
 	if w.Code != http.StatusNotFound {
 		t.Fatalf("status = %d, want 404 (非管理员伪装)", w.Code)
 	}
 }
 
+func TestAdminPageImpersonation404(t *testing.T) {
+	repo := testutil.NewFakeUserRepo()
+	seedAdminUser(repo, "u1", models.RoleAdmin)
+	sess := &testutil.FakeSessionManager{VerifyResult: &services.Claims{UID: "u1", Act: &services.ActorClaim{Sub: "root"}}}
+	w := runAdmin(AdminPageMiddleware(repo, nil, sess, ""), "", "token=valid")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404 (模拟会话伪装)", w.Code)
+	}
+}
+
+func TestAdminPageGroupMemberAllowed(t *testing.T) {
+	repo := testutil.NewFakeUserRepo()
+	seedAdminUser(repo, "u1", models.RoleUser)
+	groups := testutil.NewFakeUserGroupRepo()
+	groups.Seed(&models.UserGroup{Name: "auditors", Permissions: []string{models.PermLogsRead}}, "u1")
+	sess := &testutil.FakeSessionManager{VerifyResult: &services.Claims{UID: "u1"}}
+	w := runAdmin(AdminPageMiddleware(repo, groups, sess, ""), "", "token=valid")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+}
+
 func TestAdminPageAdminAllowed(t *testing.T) {
 	repo := testutil.NewFakeUserRepo()
 	seedAdminUser(repo, "u1", models.RoleAdmin)
 	sess := &testutil.FakeSessionManager{VerifyResult: &services.Claims{UID: "u1"}}
-	w := runAdmin(AdminPageMiddleware(repo, sess, ""), "", "token=valid")
+	w := runAdmin(AdminPageMiddleware(repo, nil, sess, ""), "", "token=valid")
 	if w.Code != http.StatusOK {
 		t.Fatalf("status = %d, want 200", w.Code)
 	}
@@ -22,10 +22,13 @@ var (
 )
 
 const (
-	ContextKeyUID         = "auth-system:uid"
-	authHeaderPrefix      = "Bearer "
-	tokenCookieName       = utils.TokenCookieName
-	guestOnlyCheckTimeout = 3 * time.Second
+	ContextKeyUID          = "auth-system:uid"
+	ContextKeySessionID    = "auth-system:sid"
+	ContextKeyImpersonator = "auth-system:impersonator"
+	ContextKeyAuthTime     = "auth-system:auth-time"
+	authHeaderPrefix       = "Bearer "
+	tokenCookieName        = utils.TokenCookieName
+	guestOnlyCheckTimeout  = 3 * time.Second
 )
 
 // AuthMiddleware JWT 认证中间件（强制认证），从 Cookie 或 Authorization Header 提取 JWT 并验证，验证失败返回 401
@@ -62,7 +65,21 @@ func AuthMiddleware(sessionService services.SessionManager) gin.HandlerFunc {
 			return
 		}
 
+		// 会话已被撤销（登出设备、"不是我本人"等）时，未过期的 access_token 同样拒绝
+		if err := sessionService.CheckSession(c.Request.Context(), claims); err != nil {
+			utils.LogInfoCtx(c.Request.Context(), "AUTH-MW", "Access token belongs to a revoked session", "user_uid", claims.UID, "ip", utils.GetClientIP(c))
+			respondUnauthorized(c, err.Error())
+			return
+		}
+
 		c.Set(ContextKeyUID, claims.UID)
+		c.Set(ContextKeyAuthTime, claims.LoginTime())
+		if claims.SID != "" {
+			c.Set(ContextKeySessionID, claims.SID)
+		}
+		if impersonator := claims.Impersonator(); impersonator != "" {
+			c.Set(ContextKeyImpersonator, impersonator)
+		}
 		c.Next()
 	}
 }
@@ -96,7 +113,19 @@ func OptionalAuthMiddleware(sessionService services.SessionManager) gin.HandlerF
 			return
 		}
 
+		if err := sessionService.CheckSession(c.Request.Context(), claims); err != nil {
+			utils.LogDebugCtx(c.Request.Context(), "AUTH-MW", "Optional auth session revoked", "path", c.Request.URL.Path)
+			c.Next()
+			return
+		}
+
 		c.Set(ContextKeyUID, claims.UID)
+		if claims.SID != "" {
+			c.Set(ContextKeySessionID, claims.SID)
+		}
+		if impersonator := claims.Impersonator(); impersonator != "" {
+			c.Set(ContextKeyImpersonator, impersonator)
+		}
 		c.Next()
 	}
 }
@@ -127,6 +156,28 @@ func GetUID(c *gin.Context) (string, bool) {
 	return uidStr, true
 }
 
+// GetSessionID 从 Context 获取当前请求所属的会话（token 家族）ID，
+// 未经 AuthMiddleware 或 token 不属于任何会话（如封禁用户的短期 token）时返回空串
+func GetSessionID(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+	sid, _ := c.Get(ContextKeySessionID)
+	sidStr, _ := sid.(string)
+	return sidStr
+}
+
+// GetAuthTime 从 Context 获取当前会话的登录时间（access_token 的 auth_time），
+// 未经 AuthMiddleware 时返回零值
+func GetAuthTime(c *gin.Context) time.Time {
+	if c == nil {
+		return time.Time{}
+	}
+	v, _ := c.Get(ContextKeyAuthTime)
+	t, _ := v.(time.Time)
+	return t
+}
+
 // IsAuthenticated 检查用户是否已认证
 func IsAuthenticated(c *gin.Context) bool {
 	_, ok := GetUID(c)
@@ -174,7 +225,7 @@ func errorMiddleware(err error) gin.HandlerFunc {
 }
 
 // GuestOnlyMiddleware 仅限未登录用户访问（登录/注册页面），已登录用户重定向到 dashboard
-// 当 JWT 有效但用户在数据库中不存在时（如数据库重置），清除 Cookie 并放行，防止重定向循环
+// 当 JWT 有效但所属会话已被撤销、或用户在数据库中不存在时（如数据库重置），清除 Cookie 并放行，防止重定向循环
 func GuestOnlyMiddleware(sessionService services.SessionManager, userCache services.UserCacheStore, userRepo models.UserReader) gin.HandlerFunc {
 	if sessionService == nil {
 		utils.LogWarn("AUTH-MW", "SessionService is nil for guest-only, skipping check")
@@ -206,6 +257,11 @@ func GuestOnlyMiddleware(sessionService services.SessionManager, userCache servi
 			return
 		}
 
+		if guestSessionRevoked(c, sessionService, claims) {
+			c.Next()
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(c.Request.Context(), guestOnlyCheckTimeout)
 		defer cancel()
 
@@ -237,7 +293,7 @@ func guestOnlyTokenCheck(sessionService services.SessionManager) gin.HandlerFunc
 			return
 		}
 
-		if claims != nil && claims.UID != "" {
+		if claims != nil && claims.UID != "" && !guestSessionRevoked(c, sessionService, claims) {
 			c.Redirect(http.StatusFound, paths.PathAccountDashboard)
 			c.Abort()
 			return
@@ -246,3 +302,14 @@ func guestOnlyTokenCheck(sessionService services.SessionManager) gin.HandlerFunc
 		c.Next()
 	}
 }
+
+// guestSessionRevoked token 所属会话已被撤销时清除 token Cookie 并返回 true：
+// 否则登录页重定向到 dashboard、dashboard 的 API 返回 401 又跳回登录页，循环直到 token 过期
+func guestSessionRevoked(c *gin.Context, sessionService services.SessionManager, claims *services.Claims) bool {
+	if err := sessionService.CheckSession(c.Request.Context(), claims); err != nil {
+		utils.LogInfoCtx(c.Request.Context(), "AUTH-MW", "Access token belongs to a revoked session, clearing cookie and treating as guest", "user_uid", claims.UID)
+		utils.ClearTokenCookieGin(c)
+		return true
+	}
+	return false
+}
@@ -24,7 +24,7 @@ func runAuth(mw gin.HandlerFunc, method, path, cookie, authHeader string) *httpt
 	r.Use(mw)
 	r.Any("/test", func(c *gin.Context) {
 		uid, _ := c.Get(ContextKeyUID)
-		c.JSON(http.StatusOK, gin.H{"uid": uid, "ok": true})
+		c.JSON(http.StatusOK, gin.H{"uid": uid, "sid": GetSessionID(c), "ok": true})
 	})
 	req := httptest.NewRequest(method, path, nil)
 	if cookie != "" {
@@ -160,6 +160,20 @@ func TestAuthMiddlewareSuccess(t *testing.T) {
 	}
 }
 
+func TestAuthMiddlewareRevokedSession(t *testing.T) {
+	sess := &testutil.FakeSessionManager{
+		VerifyResult: &services.Claims{UID: "u1", SID: "fam-1"},
+		RevokedSIDs:  []string{"fam-1"},
+	}
+	w := runAuth(AuthMiddleware(sess), http.MethodGet, "/test", "token=valid", "")
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "SESSION_REVOKED") {
+		t.Errorf("want SESSION_REVOKED, got %s", w.Body.String())
+	}
+}
+
 func TestAuthMiddlewareBearerToken(t *testing.T) {
 	sess := &testutil.FakeSessionManager{VerifyResult: &services.Claims{UID: "u1"}}
 	w := runAuth(AuthMiddleware(sess), http.MethodGet, "/test", "", "Bearer bearer-token")
@@ -178,6 +192,38 @@ func TestAuthMiddlewareNilService(t *testing.T) {
 	}
 }
 
+// ---------- BlockImpersonation ----------
+
+func TestBlockImpersonation(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims *services.Claims
+		want   int
+	}{
+		{"regular session", &services.Claims{UID: "u1"}, http.StatusOK},
+		{"impersonation session", &services.Claims{UID: "u1", Act: &services.ActorClaim{Sub: "admin"}}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		sess := &testutil.FakeSessionManager{VerifyResult: tt.claims}
+		r := gin.New()
+		r.Use(AuthMiddleware(sess), BlockImpersonation())
+		r.POST("/test", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"impersonator": GetImpersonator(c)})
+		})
+		req := httptest.NewRequest(http.MethodPost, "/test", nil)
+		req.Header.Set("Cookie", "token=valid")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if tt.want == http.StatusForbidden && !strings.Contains(w.Body.String(), ErrCodeImpersonationForbidden) {
+			t.Errorf("%s: want %s, got %s", tt.name, ErrCodeImpersonationForbidden, w.Body.String())
+		}
+	}
+}
+
 // ---------- OptionalAuthMiddleware ----------
 
 func TestOptionalAuthNoToken(t *testing.T) {
@@ -200,13 +246,27 @@ func TestOptionalAuthInvalidToken(t *testing.T) {
 }
 
 func TestOptionalAuthSuccess(t *testing.T) {
-	sess := &testutil.FakeSessionManager{VerifyResult: &services.Claims{UID: "u1"}}
+	sess := &testutil.FakeSessionManager{VerifyResult: &services.Claims{UID: "u1", SID: "fam-1"}}
 	w := runAuth(OptionalAuthMiddleware(sess), http.MethodGet, "/test", "token=valid", "")
 	if w.Code != http.StatusOK {
 		t.Fatalf("status = %d, want 200", w.Code)
 	}
-	if !strings.Contains(w.Body.String(), `"uid":"u1"`) {
-		t.Errorf("want uid mounted, got %s", w.Body.String())
+	if !strings.Contains(w.Body.String(), `"uid":"u1"`) || !strings.Contains(w.Body.String(), `"sid":"fam-1"`) {
+		t.Errorf("want uid and sid mounted, got %s", w.Body.String())
+	}
+}
+
+func TestOptionalAuthRevokedSession(t *testing.T) {
+	sess := &testutil.FakeSessionManager{
+		VerifyResult: &services.Claims{UID: "u1", SID: "fam-1"},
+		RevokedSIDs:  []string{"fam-1"},
+	}
+	w := runAuth(OptionalAuthMiddleware(sess), http.MethodGet, "/test", "token=valid", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if strings.Contains(w.Body.String(), `"uid":"u1"`) {
+		t.Errorf("revoked session should be treated as guest, got %s", w.Body.String())
 	}
 }
 
@@ -237,6 +297,30 @@ func TestGuestOnlyLoggedInRedirects(t *testing.T) {
 	}
 }
 
+func TestGuestOnlyRevokedSessionClearsCookie(t *testing.T) {
+	sess := &testutil.FakeSessionManager{
+		VerifyResult: &services.Claims{UID: "u1", SID: "fam-1"},
+		RevokedSIDs:  []string{"fam-1"},
+	}
+	cache := &testutil.FakeUserCache{}
+	repo := testutil.NewFakeUserRepo()
+	repo.Seed(&models.User{UID: "u1", Username: "alice", Email: "a@b.c"})
+
+	// 完整模式与降级模式（仅 Token 检查）都应当作访客放行并清除 Cookie，避免登录页与 dashboard 间循环重定向
+	for name, mw := range map[string]gin.HandlerFunc{
+		"full":       GuestOnlyMiddleware(sess, cache, repo),
+		"token-only": GuestOnlyMiddleware(sess, nil, nil),
+	} {
+		w := runAuth(mw, http.MethodGet, "/test", "token=valid", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200 (当访客放行)", name, w.Code)
+		}
+		if setCookie := w.Header().Get("Set-Cookie"); !strings.Contains(setCookie, "token=;") || !strings.Contains(setCookie, "Max-Age=0") {
+			t.Errorf("%s: want token cookie cleared, got Set-Cookie: %s", name, setCookie)
+		}
+	}
+}
+
 func TestGuestOnlyUserNotFoundClearsCookie(t *testing.T) {
 	sess := &testutil.FakeSessionManager{VerifyResult: &services.Claims{UID: "ghost"}}
 	cache := &testutil.FakeUserCache{}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"auth-system/internal/utils"
+)
+
+// ClientInfo 将客户端 IP、User-Agent 与大致位置注入 request.Context()，
+// 供 SessionService 签发/轮转 refresh_token 时记录会话设备信息、登录风险检查识别新设备，
+// 避免在每个登录入口显式透传设备参数。
+func ClientInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(utils.WithClientInfo(c.Request.Context(), utils.ClientInfo{
+			IP:        utils.GetClientIP(c),
+			UserAgent: c.Request.UserAgent(),
+			Location:  utils.GetClientLocation(c),
+		}))
+		c.Next()
+	}
+}
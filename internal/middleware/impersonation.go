@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+
+	"auth-system/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ErrCodeImpersonationForbidden = "IMPERSONATION_FORBIDDEN"
+
+// GetImpersonator 返回模拟登录的超级管理员 UID，非模拟会话返回空串
+func GetImpersonator(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+	uid, _ := c.Get(ContextKeyImpersonator)
+	uidStr, _ := uid.(string)
+	return uidStr
+}
+
+// IsImpersonating 当前请求是否来自模拟登录会话
+func IsImpersonating(c *gin.Context) bool {
+	return GetImpersonator(c) != ""
+}
+
+// BlockImpersonation 拒绝模拟登录会话执行敏感操作（改密码、删号、授权第三方等），必须在 AuthMiddleware 之后使用
+func BlockImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if impersonator := GetImpersonator(c); impersonator != "" {
+			uid, _ := GetUID(c)
+			utils.LogWarnCtx(c.Request.Context(), "AUTH-MW", "Sensitive action blocked during impersonation", "user_uid", uid, "impersonator", impersonator, "path", c.Request.URL.Path)
+			c.JSON(http.StatusForbidden, gin.H{
+				"success":   false,
+				"errorCode": ErrCodeImpersonationForbidden,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -1,6 +1,12 @@
 package middleware
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+
+	"auth-system/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
 
 // RateLimiterManager 限流器管理器接口
 type RateLimiterManager interface {
@@ -10,9 +16,29 @@ type RateLimiterManager interface {
 	OAuthTokenRateLimit() gin.HandlerFunc
 	VerifyCodeRateLimit() gin.HandlerFunc
 	QRLoginRateLimit() gin.HandlerFunc
+	PasswordStrengthRateLimit() gin.HandlerFunc
 	EmailAllow(email string) bool
 	EmailWaitTime(email string) int
 	DataExportAllow(userUID string) bool
 	DataExportWaitTime(userUID string) int
+	// Backend 当前限流状态后端（memory / postgres）
+	Backend() string
+	// LimitedKeys 按限流器名返回当前处于限流中的 key（管理后台查看）
+	LimitedKeys(ctx context.Context) (map[string][]models.LimitedKey, error)
 	StopAll()
 }
+
+// KeyRateLimiter 按 key 的令牌桶限流器（内存分片或共享存储实现）
+type KeyRateLimiter interface {
+	Allow(key string) bool
+	Limited(ctx context.Context) ([]models.LimitedKey, error)
+	Stop()
+}
+
+// IntervalRateLimiter 按 key 限制两次成功请求最小间隔的限流器
+type IntervalRateLimiter interface {
+	Allow(key string) bool
+	GetWaitTime(key string) int
+	Limited(ctx context.Context) ([]models.LimitedKey, error)
+	Stop()
+}
@@ -1,13 +1,18 @@
 package middleware
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"hash/maphash"
+	"math"
 	"net/http"
+	"slices"
 	"sync"
 	"time"
 
+	"auth-system/internal/config"
+	"auth-system/internal/models"
 	"auth-system/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -38,7 +43,10 @@ const (
 	defaultVerifyCodeBurst    = 5
 	defaultQRLoginRate        = 10 * time.Second
 	defaultQRLoginBurst       = 3
-	defaultEmailInterval      = 60 * time.Second
+	// 密码强度检测：前端输入防抖后调用，允许连续输入时的短时突发
+	defaultPasswordStrengthRate  = 2 * time.Second
+	defaultPasswordStrengthBurst = 10
+	defaultEmailInterval         = 60 * time.Second
 
 	rateLimiterCleanupInterval       = 5 * time.Minute
 	rateLimiterEntryTTL              = 1 * time.Hour
@@ -46,8 +54,40 @@ const (
 	emailLimiterEntryTTL             = 24 * time.Hour
 	dataExportLimiterCleanupInterval = 10 * time.Minute
 	dataExportLimiterEntryTTL        = 24 * time.Hour
+
+	defaultDataExportInterval = 24 * time.Hour
+
+	// maxLimitedKeys 每个限流器最多返回的受限 key 数（管理后台展示用）
+	maxLimitedKeys = 200
 )
 
+// 限流器名：管理后台展示分组，共享后端中作为 bucket 区分各限流器的状态
+const (
+	limiterLogin            = "login"
+	limiterRegister         = "register"
+	limiterResetPassword    = "reset_password"
+	limiterOAuthToken       = "oauth_token"
+	limiterVerifyCode       = "verify_code"
+	limiterQRLogin          = "qr_login"
+	limiterPasswordStrength = "password_strength"
+	limiterEmail            = "email"
+	limiterDataExport       = "data_export"
+)
+
+// retryAfterSeconds 剩余等待时间转为秒（向上取整，避免受限 key 显示 0 秒）
+func retryAfterSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// sortLimitedKeys 按剩余等待时间降序排列并截断到 maxLimitedKeys
+func sortLimitedKeys(keys []models.LimitedKey) []models.LimitedKey {
+	slices.SortFunc(keys, func(a, b models.LimitedKey) int { return b.RetryAfter - a.RetryAfter })
+	if len(keys) > maxLimitedKeys {
+		keys = keys[:maxLimitedKeys]
+	}
+	return keys
+}
+
 // cacheShard 泛型缓存分片
 type cacheShard[V any] struct {
 	cache *lru.Cache[string, V]
@@ -153,6 +193,24 @@ func (sc *ShardedCache[V]) shard(key string) *cacheShard[V] {
 	return sc.shards[h%shardCount]
 }
 
+// each 遍历所有分片中的条目（不更新 LRU 顺序）
+func (sc *ShardedCache[V]) each(fn func(key string, value V)) {
+	for i := range shardCount {
+		shard := sc.shards[i]
+		if shard == nil || shard.cache == nil {
+			continue
+		}
+
+		shard.mu.Lock()
+		for _, key := range shard.cache.Keys() {
+			if value, ok := shard.cache.Peek(key); ok {
+				fn(key, value)
+			}
+		}
+		shard.mu.Unlock()
+	}
+}
+
 func (sc *ShardedCache[V]) stats() int {
 	total := 0
 	for i := range shardCount {
@@ -256,6 +314,22 @@ func (srl *ShardedRateLimiter) Stats() int {
 	return srl.cache.stats()
 }
 
+// Limited 列出令牌已耗尽（下一次请求会被拒绝）的 key
+func (srl *ShardedRateLimiter) Limited(context.Context) ([]models.LimitedKey, error) {
+	now := time.Now()
+	var keys []models.LimitedKey
+	srl.cache.each(func(key string, entry *rateLimiterEntry) {
+		if entry.limiter == nil {
+			return
+		}
+		if tokens := entry.limiter.TokensAt(now); tokens < 1 {
+			wait := time.Duration((1 - tokens) / float64(srl.rate) * float64(time.Second))
+			keys = append(keys, models.LimitedKey{Key: key, RetryAfter: retryAfterSeconds(wait)})
+		}
+	})
+	return sortLimitedKeys(keys), nil
+}
+
 // NewShardedEmailRateLimiter 创建分片邮件限流器
 func NewShardedEmailRateLimiter(interval time.Duration) *ShardedEmailRateLimiter {
 	if interval <= 0 {
@@ -331,10 +405,15 @@ func (serl *ShardedEmailRateLimiter) Stats() int {
 	return serl.cache.stats()
 }
 
+// Limited 列出仍处于发送间隔内的邮箱
+func (serl *ShardedEmailRateLimiter) Limited(context.Context) ([]models.LimitedKey, error) {
+	return limitedByInterval(serl.cache, serl.interval), nil
+}
+
 // NewShardedDataExportLimiter 创建分片数据导出限流器
 func NewShardedDataExportLimiter(interval time.Duration) *ShardedDataExportLimiter {
 	if interval <= 0 {
-		interval = 24 * time.Hour
+		interval = defaultDataExportInterval
 	}
 
 	return &ShardedDataExportLimiter{
@@ -399,28 +478,53 @@ func (sdel *ShardedDataExportLimiter) GetWaitTime(userUID string) int {
 	return int((sdel.interval - elapsed).Seconds())
 }
 
-// rateLimiterManager 限流器管理器，实现 RateLimiterManager 接口
-type rateLimiterManager struct {
-	LoginLimiter         *ShardedRateLimiter
-	RegisterLimiter      *ShardedRateLimiter
-	ResetPasswordLimiter *ShardedRateLimiter
-	OAuthTokenLimiter    *ShardedRateLimiter
-	VerifyCodeLimiter    *ShardedRateLimiter
-	QRLoginLimiter       *ShardedRateLimiter
-	EmailLimiter         *ShardedEmailRateLimiter
-	DataExportLimiter    *ShardedDataExportLimiter
+// Limited 列出仍处于导出间隔内的用户
+func (sdel *ShardedDataExportLimiter) Limited(context.Context) ([]models.LimitedKey, error) {
+	return limitedByInterval(sdel.cache, sdel.interval), nil
 }
 
+// limitedByInterval 列出距上次成功请求不足 interval 的 key
+func limitedByInterval(cache *ShardedCache[time.Time], interval time.Duration) []models.LimitedKey {
+	now := time.Now()
+	var keys []models.LimitedKey
+	cache.each(func(key string, last time.Time) {
+		if elapsed := now.Sub(last); elapsed < interval {
+			keys = append(keys, models.LimitedKey{Key: key, RetryAfter: retryAfterSeconds(interval - elapsed)})
+		}
+	})
+	return sortLimitedKeys(keys)
+}
+
+// rateLimiterManager 限流器管理器，实现 RateLimiterManager 接口
+type rateLimiterManager struct {
+	backend                 string
+	LoginLimiter            KeyRateLimiter
+	RegisterLimiter         KeyRateLimiter
+	ResetPasswordLimiter    KeyRateLimiter
+	OAuthTokenLimiter       KeyRateLimiter
+	VerifyCodeLimiter       KeyRateLimiter
+	QRLoginLimiter          KeyRateLimiter
+	PasswordStrengthLimiter KeyRateLimiter
+	EmailLimiter            IntervalRateLimiter
+	DataExportLimiter       IntervalRateLimiter
+
+	// purger 共享后端的过期状态清理（内存后端由各分片缓存自行清理，为 nil）
+	purger *storePurger
+}
+
+// NewRateLimiterManager 创建进程内存限流器管理器（单实例部署；重启后限流状态清零）
 func NewRateLimiterManager() RateLimiterManager {
 	return &rateLimiterManager{
-		LoginLimiter:         NewShardedRateLimiter(rate.Every(defaultLoginRate), defaultLoginBurst),
-		RegisterLimiter:      NewShardedRateLimiter(rate.Every(defaultRegisterRate), defaultRegisterBurst),
-		ResetPasswordLimiter: NewShardedRateLimiter(rate.Every(defaultResetPasswordRate), defaultResetPasswordBurst),
-		OAuthTokenLimiter:    NewShardedRateLimiter(rate.Every(defaultOAuthTokenRate), defaultOAuthTokenBurst),
-		VerifyCodeLimiter:    NewShardedRateLimiter(rate.Every(defaultVerifyCodeRate), defaultVerifyCodeBurst),
-		QRLoginLimiter:       NewShardedRateLimiter(rate.Every(defaultQRLoginRate), defaultQRLoginBurst),
-		EmailLimiter:         NewShardedEmailRateLimiter(defaultEmailInterval),
-		DataExportLimiter:    NewShardedDataExportLimiter(24 * time.Hour),
+		backend:                 config.BackendMemory,
+		LoginLimiter:            NewShardedRateLimiter(rate.Every(defaultLoginRate), defaultLoginBurst),
+		RegisterLimiter:         NewShardedRateLimiter(rate.Every(defaultRegisterRate), defaultRegisterBurst),
+		ResetPasswordLimiter:    NewShardedRateLimiter(rate.Every(defaultResetPasswordRate), defaultResetPasswordBurst),
+		OAuthTokenLimiter:       NewShardedRateLimiter(rate.Every(defaultOAuthTokenRate), defaultOAuthTokenBurst),
+		VerifyCodeLimiter:       NewShardedRateLimiter(rate.Every(defaultVerifyCodeRate), defaultVerifyCodeBurst),
+		QRLoginLimiter:          NewShardedRateLimiter(rate.Every(defaultQRLoginRate), defaultQRLoginBurst),
+		PasswordStrengthLimiter: NewShardedRateLimiter(rate.Every(defaultPasswordStrengthRate), defaultPasswordStrengthBurst),
+		EmailLimiter:            NewShardedEmailRateLimiter(defaultEmailInterval),
+		DataExportLimiter:       NewShardedDataExportLimiter(defaultDataExportInterval),
 	}
 }
 
@@ -434,9 +538,48 @@ func (m *rateLimiterManager) StopAll() {
 	m.OAuthTokenLimiter.Stop()
 	m.VerifyCodeLimiter.Stop()
 	m.QRLoginLimiter.Stop()
+	m.PasswordStrengthLimiter.Stop()
 	m.EmailLimiter.Stop()
 	m.DataExportLimiter.Stop()
-	utils.LogInfo("RATELIMIT", "All rate limiters stopped")
+	if m.purger != nil {
+		m.purger.stop()
+	}
+	utils.LogInfo("RATELIMIT", "All rate limiters stopped", "backend", m.backend)
+}
+
+func (m *rateLimiterManager) Backend() string {
+	return m.backend
+}
+
+// LimitedKeys 汇总各限流器当前受限的 key；任一限流器查询失败即返回错误
+func (m *rateLimiterManager) LimitedKeys(ctx context.Context) (map[string][]models.LimitedKey, error) {
+	limiters := []struct {
+		name    string
+		limited func(context.Context) ([]models.LimitedKey, error)
+	}{
+		{limiterLogin, m.LoginLimiter.Limited},
+		{limiterRegister, m.RegisterLimiter.Limited},
+		{limiterResetPassword, m.ResetPasswordLimiter.Limited},
+		{limiterOAuthToken, m.OAuthTokenLimiter.Limited},
+		{limiterVerifyCode, m.VerifyCodeLimiter.Limited},
+		{limiterQRLogin, m.QRLoginLimiter.Limited},
+		{limiterPasswordStrength, m.PasswordStrengthLimiter.Limited},
+		{limiterEmail, m.EmailLimiter.Limited},
+		{limiterDataExport, m.DataExportLimiter.Limited},
+	}
+
+	result := make(map[string][]models.LimitedKey, len(limiters))
+	for _, l := range limiters {
+		keys, err := l.limited(ctx)
+		if err != nil {
+			return nil, utils.LogErrorCtx(ctx, "RATELIMIT", "LimitedKeys", err, "limiter", l.name)
+		}
+		if keys == nil {
+			keys = []models.LimitedKey{}
+		}
+		result[l.name] = keys
+	}
+	return result, nil
 }
 
 func (m *rateLimiterManager) LoginRateLimit() gin.HandlerFunc {
@@ -463,6 +606,10 @@ func (m *rateLimiterManager) QRLoginRateLimit() gin.HandlerFunc {
 	return RateLimitMiddleware(m.QRLoginLimiter)
 }
 
+func (m *rateLimiterManager) PasswordStrengthRateLimit() gin.HandlerFunc {
+	return RateLimitMiddleware(m.PasswordStrengthLimiter)
+}
+
 func (m *rateLimiterManager) EmailAllow(email string) bool {
 	return m.EmailLimiter.Allow(email)
 }
@@ -480,7 +627,7 @@ func (m *rateLimiterManager) DataExportWaitTime(userUID string) int {
 }
 
 // RateLimitMiddleware 基于 IP 的限流中间件，返回 429 Too Many Requests
-func RateLimitMiddleware(limiter *ShardedRateLimiter) gin.HandlerFunc {
+func RateLimitMiddleware(limiter KeyRateLimiter) gin.HandlerFunc {
 	if limiter == nil {
 		utils.LogError("RATELIMIT", "RateLimitMiddleware", fmt.Errorf("limiter is nil"), "Returning pass-through middleware")
 		return func(c *gin.Context) {
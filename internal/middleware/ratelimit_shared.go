@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"auth-system/internal/config"
+	"auth-system/internal/models"
+	"auth-system/internal/utils"
+)
+
+const (
+	// sharedStoreTimeout 单次共享存储访问超时；超时按存储故障处理（放行）
+	sharedStoreTimeout = 2 * time.Second
+	// sharedPurgeInterval 共享存储中已恢复状态的清理周期
+	sharedPurgeInterval = 10 * time.Minute
+)
+
+// StoreRateLimiter 基于共享存储的令牌桶限流器，多实例共用同一份配额。
+// 速率 1/every、容量 burst 的令牌桶在存储中以 GCRA 表示：emission = every，window = burst * every。
+// 存储故障时放行请求并记录错误，避免数据库抖动导致登录等入口整体不可用
+type StoreRateLimiter struct {
+	store    models.RateLimitStore
+	bucket   string
+	emission time.Duration
+	window   time.Duration
+}
+
+// NewStoreRateLimiter 创建共享存储令牌桶限流器，bucket 区分不同限流器的状态
+func NewStoreRateLimiter(store models.RateLimitStore, bucket string, every time.Duration, burst int) *StoreRateLimiter {
+	if every <= 0 {
+		utils.LogWarn("RATELIMIT", "Invalid rate, using default", "bucket", bucket, "every", every)
+		every = defaultLoginRate
+	}
+	if burst <= 0 {
+		utils.LogWarn("RATELIMIT", "Invalid burst, using default", "bucket", bucket, "burst", burst)
+		burst = defaultLoginBurst
+	}
+
+	return &StoreRateLimiter{
+		store:    store,
+		bucket:   bucket,
+		emission: every,
+		window:   time.Duration(burst) * every,
+	}
+}
+
+func (l *StoreRateLimiter) Stop() {}
+
+func (l *StoreRateLimiter) Allow(key string) bool {
+	if key == "" {
+		utils.LogWarn("RATELIMIT", "Empty key, allowing request")
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sharedStoreTimeout)
+	defer cancel()
+
+	allowed, _, err := l.store.Take(ctx, l.bucket, key, l.emission, l.window)
+	if err != nil {
+		utils.LogError("RATELIMIT", "Allow", err, "bucket", l.bucket, "fallback", "allow")
+		return true
+	}
+	return allowed
+}
+
+// Limited 列出下一次请求会被拒绝的 key
+func (l *StoreRateLimiter) Limited(ctx context.Context) ([]models.LimitedKey, error) {
+	return limitedFromStore(ctx, l.store, l.bucket, l.emission, l.window)
+}
+
+// StoreIntervalLimiter 基于共享存储的最小间隔限流器（GCRA：emission = window = interval）
+type StoreIntervalLimiter struct {
+	store    models.RateLimitStore
+	bucket   string
+	interval time.Duration
+}
+
+// NewStoreIntervalLimiter 创建共享存储间隔限流器
+func NewStoreIntervalLimiter(store models.RateLimitStore, bucket string, interval time.Duration) *StoreIntervalLimiter {
+	if interval <= 0 {
+		utils.LogWarn("RATELIMIT", "Invalid interval, using default", "bucket", bucket, "interval", interval)
+		interval = defaultEmailInterval
+	}
+
+	return &StoreIntervalLimiter{
+		store:    store,
+		bucket:   bucket,
+		interval: interval,
+	}
+}
+
+func (l *StoreIntervalLimiter) Stop() {}
+
+// Allow 空 key 拒绝（与内存实现一致）；存储故障时放行
+func (l *StoreIntervalLimiter) Allow(key string) bool {
+	if key == "" {
+		utils.LogWarn("RATELIMIT", "Empty key, denying request", "bucket", l.bucket)
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sharedStoreTimeout)
+	defer cancel()
+
+	allowed, _, err := l.store.Take(ctx, l.bucket, key, l.interval, l.interval)
+	if err != nil {
+		utils.LogError("RATELIMIT", "AllowInterval", err, "bucket", l.bucket, "fallback", "allow")
+		return true
+	}
+	return allowed
+}
+
+func (l *StoreIntervalLimiter) GetWaitTime(key string) int {
+	if key == "" {
+		return 0
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sharedStoreTimeout)
+	defer cancel()
+
+	wait, err := l.store.Peek(ctx, l.bucket, key, l.interval, l.interval)
+	if err != nil {
+		utils.LogError("RATELIMIT", "GetWaitTime", err, "bucket", l.bucket)
+		return 0
+	}
+	return int(wait.Seconds())
+}
+
+// Limited 列出仍处于间隔内的 key
+func (l *StoreIntervalLimiter) Limited(ctx context.Context) ([]models.LimitedKey, error) {
+	return limitedFromStore(ctx, l.store, l.bucket, l.interval, l.interval)
+}
+
+func limitedFromStore(ctx context.Context, store models.RateLimitStore, bucket string, emission, window time.Duration) ([]models.LimitedKey, error) {
+	keys, err := store.Limited(ctx, bucket, emission, window)
+	if err != nil {
+		return nil, err
+	}
+	return sortLimitedKeys(keys), nil
+}
+
+// storePurger 周期性删除共享存储中已完全恢复的限流状态
+type storePurger struct {
+	store    models.RateLimitStore
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newStorePurger(store models.RateLimitStore, interval time.Duration) *storePurger {
+	p := &storePurger{
+		store:    store,
+		stopChan: make(chan struct{}),
+	}
+
+	p.wg.Add(1)
+	go p.loop(interval)
+
+	return p
+}
+
+func (p *storePurger) loop(interval time.Duration) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), sharedStoreTimeout)
+			n, err := p.store.PurgeExpired(ctx)
+			cancel()
+			if err != nil {
+				utils.LogError("RATELIMIT", "PurgeExpired", err)
+			} else if n > 0 {
+				utils.LogDebug("RATELIMIT", "Purged expired shared rate limit entries", "count", n)
+			}
+		case <-p.stopChan:
+			return
+		}
+	}
+}
+
+func (p *storePurger) stop() {
+	close(p.stopChan)
+	p.wg.Wait()
+}
+
+// NewSharedRateLimiterManager 创建基于共享存储的限流器管理器：
+// 多实例共用同一份配额，重启不清零；各限流器默认速率与内存实现一致
+func NewSharedRateLimiterManager(store models.RateLimitStore) RateLimiterManager {
+	utils.LogInfo("RATELIMIT", "Using shared rate limit store", "purge_interval", sharedPurgeInterval)
+
+	return &rateLimiterManager{
+		backend:                 config.BackendPostgres,
+		LoginLimiter:            NewStoreRateLimiter(store, limiterLogin, defaultLoginRate, defaultLoginBurst),
+		RegisterLimiter:         NewStoreRateLimiter(store, limiterRegister, defaultRegisterRate, defaultRegisterBurst),
+		ResetPasswordLimiter:    NewStoreRateLimiter(store, limiterResetPassword, defaultResetPasswordRate, defaultResetPasswordBurst),
+		OAuthTokenLimiter:       NewStoreRateLimiter(store, limiterOAuthToken, defaultOAuthTokenRate, defaultOAuthTokenBurst),
+		VerifyCodeLimiter:       NewStoreRateLimiter(store, limiterVerifyCode, defaultVerifyCodeRate, defaultVerifyCodeBurst),
+		QRLoginLimiter:          NewStoreRateLimiter(store, limiterQRLogin, defaultQRLoginRate, defaultQRLoginBurst),
+		PasswordStrengthLimiter: NewStoreRateLimiter(store, limiterPasswordStrength, defaultPasswordStrengthRate, defaultPasswordStrengthBurst),
+		EmailLimiter:            NewStoreIntervalLimiter(store, limiterEmail, defaultEmailInterval),
+		DataExportLimiter:       NewStoreIntervalLimiter(store, limiterDataExport, defaultDataExportInterval),
+		purger:                  newStorePurger(store, sharedPurgeInterval),
+	}
+}
@@ -1,11 +1,16 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"auth-system/internal/models"
+
 	"golang.org/x/time/rate"
 )
 
@@ -136,6 +141,195 @@ func TestEmailLimiterKeyIsolation(t *testing.T) {
 	}
 }
 
+func TestLimitedKeysMemory(t *testing.T) {
+	m := NewRateLimiterManager()
+	t.Cleanup(m.StopAll)
+
+	mgr := m.(*rateLimiterManager)
+	for range defaultLoginBurst {
+		mgr.LoginLimiter.Allow("198.51.100.1")
+	}
+	mgr.LoginLimiter.Allow("198.51.100.2")
+	m.EmailAllow("a@b.c")
+
+	limited, err := m.LimitedKeys(context.Background())
+	if err != nil {
+		t.Fatalf("LimitedKeys: %v", err)
+	}
+	if got := limited[limiterLogin]; len(got) != 1 || got[0].Key != "198.51.100.1" || got[0].RetryAfter < 1 {
+		t.Errorf("login limited = %+v, want only exhausted key", got)
+	}
+	if got := limited[limiterEmail]; len(got) != 1 || got[0].RetryAfter > 60 {
+		t.Errorf("email limited = %+v", got)
+	}
+	if got, ok := limited[limiterRegister]; !ok || len(got) != 0 {
+		t.Errorf("register limited = %v, want empty slice", got)
+	}
+}
+
+// ---------- 共享存储限流器 ----------
+
+// fakeRateLimitStore 按 RateLimitRepository 的 GCRA 语义实现的内存存储，时钟可控
+type fakeRateLimitStore struct {
+	mu  sync.Mutex
+	now time.Time
+	tat map[string]time.Time
+	err error
+}
+
+func newFakeRateLimitStore() *fakeRateLimitStore {
+	return &fakeRateLimitStore{now: time.Unix(1_700_000_000, 0), tat: map[string]time.Time{}}
+}
+
+func (s *fakeRateLimitStore) advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.now.Add(d)
+}
+
+func (s *fakeRateLimitStore) Take(_ context.Context, bucket, key string, emission, window time.Duration) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return false, 0, s.err
+	}
+	base := s.now
+	if tat, ok := s.tat[bucket+"/"+key]; ok && tat.After(base) {
+		base = tat
+	}
+	newTAT := base.Add(emission)
+	if over := newTAT.Sub(s.now) - window; over > 0 {
+		return false, over, nil
+	}
+	s.tat[bucket+"/"+key] = newTAT
+	return true, 0, nil
+}
+
+func (s *fakeRateLimitStore) Peek(_ context.Context, bucket, key string, emission, window time.Duration) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return 0, s.err
+	}
+	tat, ok := s.tat[bucket+"/"+key]
+	if !ok {
+		return 0, nil
+	}
+	return max(tat.Add(emission-window).Sub(s.now), 0), nil
+}
+
+func (s *fakeRateLimitStore) Limited(_ context.Context, bucket string, emission, window time.Duration) ([]models.LimitedKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	var keys []models.LimitedKey
+	for k, tat := range s.tat {
+		key, ok := strings.CutPrefix(k, bucket+"/")
+		if !ok {
+			continue
+		}
+		if wait := tat.Add(emission - window).Sub(s.now); wait > 0 {
+			keys = append(keys, models.LimitedKey{Key: key, RetryAfter: retryAfterSeconds(wait)})
+		}
+	}
+	return keys, nil
+}
+
+func (s *fakeRateLimitStore) PurgeExpired(context.Context) (int64, error) { return 0, nil }
+
+func TestStoreRateLimiterBurstAndRecovery(t *testing.T) {
+	store := newFakeRateLimitStore()
+	l := NewStoreRateLimiter(store, limiterLogin, 10*time.Second, 3)
+
+	for i := range 3 {
+		if !l.Allow("ip") {
+			t.Fatalf("Allow #%d = false, want true (burst 内)", i+1)
+		}
+	}
+	if l.Allow("ip") {
+		t.Fatal("Allow after burst exhausted = true, want false")
+	}
+	if !l.Allow("other-ip") {
+		t.Error("other key should not be affected")
+	}
+
+	// 每 10 秒恢复一个令牌
+	store.advance(10 * time.Second)
+	if !l.Allow("ip") {
+		t.Error("Allow after one emission interval = false, want true")
+	}
+	if l.Allow("ip") {
+		t.Error("only one token should have been restored")
+	}
+}
+
+func TestStoreRateLimiterFailOpen(t *testing.T) {
+	store := newFakeRateLimitStore()
+	store.err = errors.New("connection refused")
+	l := NewStoreRateLimiter(store, limiterLogin, time.Minute, 1)
+
+	if !l.Allow("ip") || !l.Allow("ip") {
+		t.Error("store error should allow request")
+	}
+}
+
+func TestStoreIntervalLimiter(t *testing.T) {
+	store := newFakeRateLimitStore()
+	l := NewStoreIntervalLimiter(store, limiterEmail, time.Minute)
+
+	if l.Allow("") {
+		t.Error("Allow(empty) = true, want false (空 key 拒绝)")
+	}
+	if !l.Allow("a@b.c") {
+		t.Fatal("first Allow = false, want true")
+	}
+	if l.Allow("a@b.c") {
+		t.Error("second Allow within interval = true, want false")
+	}
+
+	store.advance(20 * time.Second)
+	if wait := l.GetWaitTime("a@b.c"); wait != 40 {
+		t.Errorf("GetWaitTime() = %d, want 40", wait)
+	}
+
+	store.advance(40 * time.Second)
+	if wait := l.GetWaitTime("a@b.c"); wait != 0 {
+		t.Errorf("GetWaitTime() after interval = %d, want 0", wait)
+	}
+	if !l.Allow("a@b.c") {
+		t.Error("Allow after interval = false, want true")
+	}
+}
+
+func TestSharedRateLimiterManagerLimitedKeys(t *testing.T) {
+	store := newFakeRateLimitStore()
+	m := NewSharedRateLimiterManager(store)
+	t.Cleanup(m.StopAll)
+
+	if m.Backend() != "postgres" {
+		t.Errorf("Backend() = %q, want postgres", m.Backend())
+	}
+	if !m.DataExportAllow("uid-1") || m.DataExportAllow("uid-1") {
+		t.Fatal("data export interval not enforced")
+	}
+
+	limited, err := m.LimitedKeys(context.Background())
+	if err != nil {
+		t.Fatalf("LimitedKeys: %v", err)
+	}
+	got := limited[limiterDataExport]
+	if len(got) != 1 || got[0].Key != "uid-1" || got[0].RetryAfter != int(defaultDataExportInterval.Seconds()) {
+		t.Errorf("data_export limited = %+v", got)
+	}
+
+	store.err = errors.New("connection refused")
+	if _, err := m.LimitedKeys(context.Background()); err == nil {
+		t.Error("LimitedKeys should surface store errors")
+	}
+}
+
 // ---------- RateLimitMiddleware ----------
 
 func TestRateLimitMiddleware429(t *testing.T) {
@@ -71,6 +71,9 @@ var htmlPages = map[string]bool{
 	paths.PathAccountDashboard: true,
 	paths.PathAccountLink:      true,
 	paths.PathAccountOAuth:     true,
+	paths.PathAccountDevice:    true,
+	paths.PathAccountSecure:    true,
+	paths.PathAccountUnlock:    true,
 }
 
 // SecurityHeaders 安全头中间件（使用默认配置：启用 CSP、ReferrerPolicy、PermissionsPolicy）
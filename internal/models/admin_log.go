@@ -21,11 +21,15 @@ const (
 	ActionDeleteUser                  = "delete_user"
 	ActionBanUser                     = "ban_user"
 	ActionUnbanUser                   = "unban_user"
+	ActionReset2FA                    = "reset_2fa"
 	ActionOAuthClientCreate           = "oauth_client_create"
 	ActionOAuthClientUpdate           = "oauth_client_update"
 	ActionOAuthClientDelete           = "oauth_client_delete"
 	ActionOAuthClientRegenerateSecret = "oauth_client_regenerate_secret"
 	ActionOAuthClientToggle           = "oauth_client_toggle"
+	ActionOAuthScopeCreate            = "oauth_scope_create"
+	ActionOAuthScopeUpdate            = "oauth_scope_update"
+	ActionOAuthScopeDelete            = "oauth_scope_delete"
 
 	ActionEmailWhitelistCreate = "email_whitelist_create"
 	ActionEmailWhitelistUpdate = "email_whitelist_update"
@@ -33,8 +37,32 @@ const (
 
 	ActionDataExport = "data_export"
 	ActionDataImport = "data_import"
+
+	ActionUserGroupCreate       = "user_group_create"
+	ActionUserGroupUpdate       = "user_group_update"
+	ActionUserGroupDelete       = "user_group_delete"
+	ActionUserGroupAddMember    = "user_group_add_member"
+	ActionUserGroupRemoveMember = "user_group_remove_member"
+
+	ActionImpersonationStart = "impersonation_start"
+	ActionImpersonationStop  = "impersonation_stop"
+
+	ActionWebhookCreate           = "webhook_create"
+	ActionWebhookUpdate           = "webhook_update"
+	ActionWebhookDelete           = "webhook_delete"
+	ActionWebhookRegenerateSecret = "webhook_regenerate_secret"
+	ActionWebhookRedeliver        = "webhook_redeliver"
+
+	ActionSCIMUserCreate     = "scim_user_create"
+	ActionSCIMUserUpdate     = "scim_user_update"
+	ActionSCIMUserDeactivate = "scim_user_deactivate"
+	ActionSCIMUserReactivate = "scim_user_reactivate"
 )
 
+// SCIMActorUID SCIM 供应写操作的操作者标识，写入 admin_logs.admin_uid 与 users.banned_by；
+// 发起请求的 OAuth 客户端记入 details
+const SCIMActorUID = "scim"
+
 // AdminLog 管理员操作日志
 type AdminLog struct {
 	ID        int64           `json:"id"`
@@ -81,6 +109,11 @@ type UnbanUserDetails struct {
 	TargetUsername string `json:"target_username"`
 }
 
+// Reset2FADetails 重置两步验证操作详情
+type Reset2FADetails struct {
+	TargetUsername string `json:"target_username"`
+}
+
 // OAuthClientDetails OAuth 客户端操作详情
 type OAuthClientDetails struct {
 	ClientDBID int64  `json:"client_db_id"`
@@ -96,6 +129,12 @@ type OAuthClientToggleDetails struct {
 	Enabled    bool   `json:"enabled"`
 }
 
+// OAuthScopeDetails 自定义 scope 操作详情
+type OAuthScopeDetails struct {
+	ScopeID   int64  `json:"scope_id"`
+	ScopeName string `json:"scope_name"`
+}
+
 // DataExportDetails 导出数据操作详情
 type DataExportDetails struct {
 	UsersCount int `json:"users_count"`
@@ -108,6 +147,43 @@ type DataImportDetails struct {
 	LogsImported  int `json:"logs_imported"`
 }
 
+// UserGroupDetails 用户组操作详情
+type UserGroupDetails struct {
+	GroupID     int64    `json:"group_id"`
+	GroupName   string   `json:"group_name"`
+	Permissions []string `json:"permissions"`
+}
+
+// UserGroupMemberDetails 用户组成员变更详情
+type UserGroupMemberDetails struct {
+	TargetUsername string `json:"target_username"`
+	GroupID        int64  `json:"group_id"`
+	GroupName      string `json:"group_name"`
+}
+
+// ImpersonationDetails 模拟登录操作详情
+type ImpersonationDetails struct {
+	TargetUsername string     `json:"target_username"`
+	Reason         string     `json:"reason,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"` // 仅开始记录：模拟会话的过期时间
+}
+
+// WebhookDetails Webhook 端点操作详情
+type WebhookDetails struct {
+	WebhookID  int64    `json:"webhook_id"`
+	URL        string   `json:"url"`
+	Events     []string `json:"events,omitempty"`
+	DeliveryID int64    `json:"delivery_id,omitempty"` // 仅重新投递：新建的投递记录 ID
+}
+
+// SCIMUserDetails SCIM 供应操作详情
+type SCIMUserDetails struct {
+	ClientID       string   `json:"client_id"`
+	ClientName     string   `json:"client_name"`
+	TargetUsername string   `json:"target_username"`
+	Attributes     []string `json:"attributes,omitempty"` // 仅更新：被修改的属性
+}
+
 // AdminLogRepository 管理员日志仓库
 type AdminLogRepository struct {
 	pool *pgxpool.Pool
@@ -243,6 +319,27 @@ func (r *AdminLogRepository) LogUnbanUser(ctx context.Context, adminUID, targetU
 	return r.Create(ctx, log)
 }
 
+// LogReset2FA 记录重置用户两步验证操作
+func (r *AdminLogRepository) LogReset2FA(ctx context.Context, adminUID, targetUID string, targetUsername string) error {
+	details := Reset2FADetails{
+		TargetUsername: targetUsername,
+	}
+
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("marshal details failed: %w", err)
+	}
+
+	log := &AdminLog{
+		AdminUID:  adminUID,
+		Action:    ActionReset2FA,
+		TargetUID: &targetUID,
+		Details:   detailsJSON,
+	}
+
+	return r.Create(ctx, log)
+}
+
 // LogOAuthClientCreate 记录创建 OAuth 客户端操作
 func (r *AdminLogRepository) LogOAuthClientCreate(ctx context.Context, adminUID string, clientDBID int64, clientID, clientName string) error {
 	details := OAuthClientDetails{
@@ -354,6 +451,36 @@ func (r *AdminLogRepository) LogOAuthClientToggle(ctx context.Context, adminUID
 	return r.Create(ctx, log)
 }
 
+// LogOAuthScopeCreate 记录创建自定义 OAuth scope 操作
+func (r *AdminLogRepository) LogOAuthScopeCreate(ctx context.Context, adminUID string, scope *OAuthScope) error {
+	return r.logOAuthScope(ctx, adminUID, ActionOAuthScopeCreate, scope)
+}
+
+// LogOAuthScopeUpdate 记录更新自定义 OAuth scope 操作
+func (r *AdminLogRepository) LogOAuthScopeUpdate(ctx context.Context, adminUID string, scope *OAuthScope) error {
+	return r.logOAuthScope(ctx, adminUID, ActionOAuthScopeUpdate, scope)
+}
+
+// LogOAuthScopeDelete 记录删除自定义 OAuth scope 操作
+func (r *AdminLogRepository) LogOAuthScopeDelete(ctx context.Context, adminUID string, scope *OAuthScope) error {
+	return r.logOAuthScope(ctx, adminUID, ActionOAuthScopeDelete, scope)
+}
+
+func (r *AdminLogRepository) logOAuthScope(ctx context.Context, adminUID, action string, scope *OAuthScope) error {
+	detailsJSON, err := json.Marshal(OAuthScopeDetails{ScopeID: scope.ID, ScopeName: scope.Name})
+	if err != nil {
+		return fmt.Errorf("marshal details failed: %w", err)
+	}
+
+	log := &AdminLog{
+		AdminUID: adminUID,
+		Action:   action,
+		Details:  detailsJSON,
+	}
+
+	return r.Create(ctx, log)
+}
+
 // LogEmailWhitelistCreate 记录创建邮箱白名单
 func (r *AdminLogRepository) LogEmailWhitelistCreate(ctx context.Context, adminUID string, entry *EmailWhitelist) error {
 	details := map[string]any{
@@ -463,6 +590,170 @@ func (r *AdminLogRepository) LogDataImport(ctx context.Context, adminUID string,
 	return r.Create(ctx, log)
 }
 
+// LogUserGroupCreate 记录创建用户组操作
+func (r *AdminLogRepository) LogUserGroupCreate(ctx context.Context, adminUID string, group *UserGroup) error {
+	return r.logUserGroup(ctx, adminUID, ActionUserGroupCreate, group)
+}
+
+// LogUserGroupUpdate 记录更新用户组操作
+func (r *AdminLogRepository) LogUserGroupUpdate(ctx context.Context, adminUID string, group *UserGroup) error {
+	return r.logUserGroup(ctx, adminUID, ActionUserGroupUpdate, group)
+}
+
+// LogUserGroupDelete 记录删除用户组操作
+func (r *AdminLogRepository) LogUserGroupDelete(ctx context.Context, adminUID string, group *UserGroup) error {
+	return r.logUserGroup(ctx, adminUID, ActionUserGroupDelete, group)
+}
+
+func (r *AdminLogRepository) logUserGroup(ctx context.Context, adminUID, action string, group *UserGroup) error {
+	detailsJSON, err := json.Marshal(UserGroupDetails{GroupID: group.ID, GroupName: group.Name, Permissions: group.Permissions})
+	if err != nil {
+		return fmt.Errorf("marshal details failed: %w", err)
+	}
+
+	log := &AdminLog{
+		AdminUID: adminUID,
+		Action:   action,
+		Details:  detailsJSON,
+	}
+
+	return r.Create(ctx, log)
+}
+
+// LogUserGroupAddMember 记录将用户加入用户组操作
+func (r *AdminLogRepository) LogUserGroupAddMember(ctx context.Context, adminUID, targetUID string, targetUsername string, group *UserGroup) error {
+	return r.logUserGroupMember(ctx, adminUID, ActionUserGroupAddMember, targetUID, targetUsername, group)
+}
+
+// LogUserGroupRemoveMember 记录将用户移出用户组操作
+func (r *AdminLogRepository) LogUserGroupRemoveMember(ctx context.Context, adminUID, targetUID string, targetUsername string, group *UserGroup) error {
+	return r.logUserGroupMember(ctx, adminUID, ActionUserGroupRemoveMember, targetUID, targetUsername, group)
+}
+
+func (r *AdminLogRepository) logUserGroupMember(ctx context.Context, adminUID, action, targetUID, targetUsername string, group *UserGroup) error {
+	detailsJSON, err := json.Marshal(UserGroupMemberDetails{TargetUsername: targetUsername, GroupID: group.ID, GroupName: group.Name})
+	if err != nil {
+		return fmt.Errorf("marshal details failed: %w", err)
+	}
+
+	log := &AdminLog{
+		AdminUID:  adminUID,
+		Action:    action,
+		TargetUID: &targetUID,
+		Details:   detailsJSON,
+	}
+
+	return r.Create(ctx, log)
+}
+
+// LogImpersonationStart 记录开始以用户身份登录（模拟登录）
+func (r *AdminLogRepository) LogImpersonationStart(ctx context.Context, adminUID, targetUID string, targetUsername, reason string, expiresAt time.Time) error {
+	return r.logImpersonation(ctx, adminUID, ActionImpersonationStart, targetUID, ImpersonationDetails{
+		TargetUsername: targetUsername,
+		Reason:         reason,
+		ExpiresAt:      &expiresAt,
+	})
+}
+
+// LogImpersonationStop 记录主动结束模拟登录
+func (r *AdminLogRepository) LogImpersonationStop(ctx context.Context, adminUID, targetUID string, targetUsername string) error {
+	return r.logImpersonation(ctx, adminUID, ActionImpersonationStop, targetUID, ImpersonationDetails{
+		TargetUsername: targetUsername,
+	})
+}
+
+func (r *AdminLogRepository) logImpersonation(ctx context.Context, adminUID, action, targetUID string, details ImpersonationDetails) error {
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("marshal details failed: %w", err)
+	}
+
+	log := &AdminLog{
+		AdminUID:  adminUID,
+		Action:    action,
+		TargetUID: &targetUID,
+		Details:   detailsJSON,
+	}
+
+	return r.Create(ctx, log)
+}
+
+// LogWebhookCreate 记录创建 Webhook 端点操作
+func (r *AdminLogRepository) LogWebhookCreate(ctx context.Context, adminUID string, endpoint *WebhookEndpoint) error {
+	return r.logWebhook(ctx, adminUID, ActionWebhookCreate, WebhookDetails{WebhookID: endpoint.ID, URL: endpoint.URL, Events: endpoint.Events})
+}
+
+// LogWebhookUpdate 记录更新 Webhook 端点操作
+func (r *AdminLogRepository) LogWebhookUpdate(ctx context.Context, adminUID string, endpoint *WebhookEndpoint) error {
+	return r.logWebhook(ctx, adminUID, ActionWebhookUpdate, WebhookDetails{WebhookID: endpoint.ID, URL: endpoint.URL, Events: endpoint.Events})
+}
+
+// LogWebhookDelete 记录删除 Webhook 端点操作
+func (r *AdminLogRepository) LogWebhookDelete(ctx context.Context, adminUID string, endpoint *WebhookEndpoint) error {
+	return r.logWebhook(ctx, adminUID, ActionWebhookDelete, WebhookDetails{WebhookID: endpoint.ID, URL: endpoint.URL, Events: endpoint.Events})
+}
+
+// LogWebhookRegenerateSecret 记录重新生成 Webhook 签名密钥操作
+func (r *AdminLogRepository) LogWebhookRegenerateSecret(ctx context.Context, adminUID string, endpoint *WebhookEndpoint) error {
+	return r.logWebhook(ctx, adminUID, ActionWebhookRegenerateSecret, WebhookDetails{WebhookID: endpoint.ID, URL: endpoint.URL})
+}
+
+// LogWebhookRedeliver 记录手动重新投递操作
+func (r *AdminLogRepository) LogWebhookRedeliver(ctx context.Context, adminUID string, endpoint *WebhookEndpoint, deliveryID int64) error {
+	return r.logWebhook(ctx, adminUID, ActionWebhookRedeliver, WebhookDetails{WebhookID: endpoint.ID, URL: endpoint.URL, DeliveryID: deliveryID})
+}
+
+func (r *AdminLogRepository) logWebhook(ctx context.Context, adminUID, action string, details WebhookDetails) error {
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("marshal details failed: %w", err)
+	}
+
+	log := &AdminLog{
+		AdminUID: adminUID,
+		Action:   action,
+		Details:  detailsJSON,
+	}
+
+	return r.Create(ctx, log)
+}
+
+// LogSCIMUserCreate 记录 SCIM 创建用户
+func (r *AdminLogRepository) LogSCIMUserCreate(ctx context.Context, client *OAuthClient, targetUID, targetUsername string) error {
+	return r.logSCIMUser(ctx, ActionSCIMUserCreate, targetUID, SCIMUserDetails{ClientID: client.ClientID, ClientName: client.Name, TargetUsername: targetUsername})
+}
+
+// LogSCIMUserUpdate 记录 SCIM 修改用户属性
+func (r *AdminLogRepository) LogSCIMUserUpdate(ctx context.Context, client *OAuthClient, targetUID, targetUsername string, attributes []string) error {
+	return r.logSCIMUser(ctx, ActionSCIMUserUpdate, targetUID, SCIMUserDetails{ClientID: client.ClientID, ClientName: client.Name, TargetUsername: targetUsername, Attributes: attributes})
+}
+
+// LogSCIMUserDeactivate 记录 SCIM 停用用户（封禁）
+func (r *AdminLogRepository) LogSCIMUserDeactivate(ctx context.Context, client *OAuthClient, targetUID, targetUsername string) error {
+	return r.logSCIMUser(ctx, ActionSCIMUserDeactivate, targetUID, SCIMUserDetails{ClientID: client.ClientID, ClientName: client.Name, TargetUsername: targetUsername})
+}
+
+// LogSCIMUserReactivate 记录 SCIM 重新启用用户（解封）
+func (r *AdminLogRepository) LogSCIMUserReactivate(ctx context.Context, client *OAuthClient, targetUID, targetUsername string) error {
+	return r.logSCIMUser(ctx, ActionSCIMUserReactivate, targetUID, SCIMUserDetails{ClientID: client.ClientID, ClientName: client.Name, TargetUsername: targetUsername})
+}
+
+func (r *AdminLogRepository) logSCIMUser(ctx context.Context, action, targetUID string, details SCIMUserDetails) error {
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("marshal details failed: %w", err)
+	}
+
+	log := &AdminLog{
+		AdminUID:  SCIMActorUID,
+		Action:    action,
+		TargetUID: &targetUID,
+		Details:   detailsJSON,
+	}
+
+	return r.Create(ctx, log)
+}
+
 // FindAll 查询日志列表（分页）
 func (r *AdminLogRepository) FindAll(ctx context.Context, page, pageSize int) ([]*AdminLogPublic, int64, error) {
 	if err := r.checkDB(); err != nil {
@@ -503,6 +794,8 @@ func (r *AdminLogRepository) FindAll(ctx context.Context, page, pageSize int) ([
 		}
 		if adminUsername != nil {
 			log.AdminUsername = *adminUsername
+		} else if log.AdminUID == SCIMActorUID {
+			log.AdminUsername = "SCIM"
 		} else {
 			log.AdminUsername = "已删除"
 		}
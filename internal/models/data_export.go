@@ -36,9 +36,9 @@ type pgxConn interface {
 // 导入侧 (ImportUsers) 会校验 password 必须为 $argon2 前缀格式以防篡改。
 func (r *DataExportImportRepository) QueryAllUsers(ctx context.Context) ([]map[string]any, error) {
 	rows, err := r.pool.Query(ctx, `
-		SELECT uid, username, email, password, avatar_url, microsoft_id,
-		       microsoft_name, microsoft_avatar_url, microsoft_avatar_hash,
-		       google_id, google_name, google_avatar_url,
+		SELECT uid, username, email, password, avatar_url,
+		       `+microsoftIdentityColumns+`, microsoft_avatar_url, microsoft_avatar_hash,
+		       `+googleIdentityColumns+`,
 		       is_banned, ban_reason, banned_at, banned_by, unban_at, role,
 		       created_at, updated_at
 		FROM users
@@ -147,22 +147,16 @@ func (r *DataExportImportRepository) QueryAllUserLogs(ctx context.Context) ([]ma
 
 const importUsersSQL = `
 	INSERT INTO users (uid, username, email, password, avatar_url,
-	                   microsoft_id, microsoft_name, microsoft_avatar_url, microsoft_avatar_hash,
-	                   google_id, google_name, google_avatar_url,
+	                   microsoft_avatar_url, microsoft_avatar_hash,
 	                   is_banned, ban_reason, banned_at, banned_by, unban_at, role, created_at, updated_at)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 	ON CONFLICT (uid) DO UPDATE SET
 		username = EXCLUDED.username,
 		email = EXCLUDED.email,
 		password = EXCLUDED.password,
 		avatar_url = EXCLUDED.avatar_url,
-		microsoft_id = EXCLUDED.microsoft_id,
-		microsoft_name = EXCLUDED.microsoft_name,
 		microsoft_avatar_url = EXCLUDED.microsoft_avatar_url,
 		microsoft_avatar_hash = EXCLUDED.microsoft_avatar_hash,
-		google_id = EXCLUDED.google_id,
-		google_name = EXCLUDED.google_name,
-		google_avatar_url = EXCLUDED.google_avatar_url,
 		is_banned = EXCLUDED.is_banned,
 		ban_reason = EXCLUDED.ban_reason,
 		banned_at = EXCLUDED.banned_at,
@@ -172,6 +166,20 @@ const importUsersSQL = `
 		updated_at = EXCLUDED.updated_at
 `
 
+// importBuiltinIdentitySQL 导入 Microsoft / Google 绑定（备份中沿用 users 上的原字段名）
+const importBuiltinIdentitySQL = `
+	INSERT INTO user_identities (user_uid, provider, subject, display_name, avatar_url)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (user_uid, provider) DO UPDATE SET
+		subject = EXCLUDED.subject,
+		display_name = EXCLUDED.display_name,
+		avatar_url = EXCLUDED.avatar_url,
+		updated_at = NOW()
+`
+
+// deleteBuiltinIdentitySQL 备份中该用户未绑定对应 Provider 时清除现有绑定
+const deleteBuiltinIdentitySQL = `DELETE FROM user_identities WHERE user_uid = $1 AND provider = $2`
+
 const importUserLogsSQL = `
 	INSERT INTO user_logs (id, user_uid, action, details, created_at)
 	VALUES ($1, $2, $3, $4, $5)
@@ -233,6 +241,7 @@ func (r *DataExportImportRepository) ImportAllInTransaction(ctx context.Context,
 func importUsersBatch(ctx context.Context, conn pgxConn, users []map[string]any) (ImportUsersResult, error) {
 	batch := &pgx.Batch{}
 	uids := make([]string, 0, len(users))
+	queued := make([]map[string]any, 0, len(users))
 	result := ImportUsersResult{}
 
 	for _, user := range users {
@@ -263,13 +272,8 @@ func importUsersBatch(ctx context.Context, conn pgxConn, users []map[string]any)
 			toString(user["email"]),
 			password,
 			toString(user["avatar_url"]),
-			toNullableString(user["microsoft_id"]),
-			toNullableString(user["microsoft_name"]),
 			toNullableString(user["microsoft_avatar_url"]),
 			toNullableString(user["microsoft_avatar_hash"]),
-			toNullableString(user["google_id"]),
-			toNullableString(user["google_name"]),
-			toNullableString(user["google_avatar_url"]),
 			toBool(user["is_banned"]),
 			toNullableString(user["ban_reason"]),
 			toNullableTime(user["banned_at"]),
@@ -280,6 +284,7 @@ func importUsersBatch(ctx context.Context, conn pgxConn, users []map[string]any)
 			toTime(user["updated_at"]),
 		)
 		uids = append(uids, uid)
+		queued = append(queued, user)
 	}
 
 	if len(uids) == 0 {
@@ -290,13 +295,15 @@ func importUsersBatch(ctx context.Context, conn pgxConn, users []map[string]any)
 	defer br.Close()
 
 	var hasError bool
-	for _, uid := range uids {
+	imported := make([]map[string]any, 0, len(queued))
+	for i, uid := range uids {
 		if _, err := br.Exec(); err != nil {
 			utils.LogWarn("DATA-IMPORT", "Failed to import user", "uid", uid, "error", err)
 			hasError = true
 			result.Failed++
 		} else {
 			result.Imported++
+			imported = append(imported, queued[i])
 		}
 	}
 
@@ -304,6 +311,10 @@ func importUsersBatch(ctx context.Context, conn pgxConn, users []map[string]any)
 		return result, fmt.Errorf("failed to close batch result: %w", err)
 	}
 
+	if err := importBuiltinIdentitiesBatch(ctx, conn, imported); err != nil {
+		return result, err
+	}
+
 	if hasError && result.Imported == 0 {
 		return result, fmt.Errorf("all %d user imports failed", len(uids))
 	}
@@ -311,6 +322,45 @@ func importUsersBatch(ctx context.Context, conn pgxConn, users []map[string]any)
 	return result, nil
 }
 
+// importBuiltinIdentitiesBatch 导入已成功写入用户的 Microsoft / Google 绑定，conn 可以是 pool 或 tx
+// 单条失败（如外部身份已被其他用户绑定）仅记录警告，不影响用户本身的导入结果
+func importBuiltinIdentitiesBatch(ctx context.Context, conn pgxConn, users []map[string]any) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, user := range users {
+		uid := toString(user["uid"])
+		if id := toString(user["microsoft_id"]); id != "" {
+			batch.Queue(importBuiltinIdentitySQL, uid, ProviderMicrosoft, id, toString(user["microsoft_name"]), "")
+		} else {
+			batch.Queue(deleteBuiltinIdentitySQL, uid, ProviderMicrosoft)
+		}
+		if id := toString(user["google_id"]); id != "" {
+			batch.Queue(importBuiltinIdentitySQL, uid, ProviderGoogle, id, toString(user["google_name"]), toString(user["google_avatar_url"]))
+		} else {
+			batch.Queue(deleteBuiltinIdentitySQL, uid, ProviderGoogle)
+		}
+	}
+
+	br := conn.SendBatch(ctx, batch)
+	defer br.Close()
+
+	for _, user := range users {
+		for _, provider := range []string{ProviderMicrosoft, ProviderGoogle} {
+			if _, err := br.Exec(); err != nil {
+				utils.LogWarn("DATA-IMPORT", "Failed to import user identity", "uid", toString(user["uid"]), "provider", provider, "error", err)
+			}
+		}
+	}
+
+	if err := br.Close(); err != nil {
+		return fmt.Errorf("failed to close identity batch result: %w", err)
+	}
+	return nil
+}
+
 // importUserLogsBatch 批量导入用户日志，conn 可以是 pool 或 tx
 func importUserLogsBatch(ctx context.Context, conn pgxConn, logs []map[string]any) (int, int, error) {
 	batch := &pgx.Batch{}
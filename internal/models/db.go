@@ -17,7 +17,7 @@ var (
 	ErrDBEmptyURL         = fmt.Errorf("database URL is empty")
 	ErrDBConnectionFailed = fmt.Errorf("database connection failed")
 	ErrDBPingFailed       = fmt.Errorf("database ping failed")
-	ErrDBTableInitFailed  = fmt.Errorf("table initialization failed")
+	ErrDBSchemaCheck      = fmt.Errorf("database schema check failed")
 )
 
 const (
@@ -28,15 +28,32 @@ const (
 	pingTimeout              = 5 * time.Second
 )
 
-// InitDB 初始化数据库连接池并执行迁移
+// InitDB 初始化数据库连接池并校验 schema 版本
+// 数据库未迁移到最新版本时拒绝启动，迁移通过 migrate 子命令显式执行
 func InitDB(cfg *config.Config) (*pgxpool.Pool, error) {
+	pool, err := OpenDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckSchemaVersion(pool); err != nil {
+		pool.Close()
+		utils.LogError("DATABASE", "InitDB", err, "Schema version check failed")
+		return nil, fmt.Errorf("%w: %w", ErrDBSchemaCheck, err)
+	}
+
+	return pool, nil
+}
+
+// OpenDB 创建数据库连接池并测试连通性，不校验 schema（migrate 子命令使用）
+func OpenDB(cfg *config.Config) (*pgxpool.Pool, error) {
 	if cfg == nil {
-		utils.LogError("DATABASE", "InitDB", fmt.Errorf("config is nil"))
+		utils.LogError("DATABASE", "OpenDB", fmt.Errorf("config is nil"))
 		return nil, ErrDBNilConfig
 	}
 
 	if cfg.DatabaseURL == "" {
-		utils.LogError("DATABASE", "InitDB", fmt.Errorf("database URL is empty"))
+		utils.LogError("DATABASE", "OpenDB", fmt.Errorf("database URL is empty"))
 		return nil, ErrDBEmptyURL
 	}
 
@@ -44,7 +61,7 @@ func InitDB(cfg *config.Config) (*pgxpool.Pool, error) {
 
 	poolConfig, err := pgxpool.ParseConfig(cfg.DatabaseURL)
 	if err != nil {
-		utils.LogError("DATABASE", "InitDB", err, "Failed to parse database URL")
+		utils.LogError("DATABASE", "OpenDB", err, "Failed to parse database URL")
 		return nil, fmt.Errorf("parse database URL: %w", err)
 	}
 
@@ -52,7 +69,7 @@ func InitDB(cfg *config.Config) (*pgxpool.Pool, error) {
 
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
-		utils.LogError("DATABASE", "InitDB", err, "Failed to create connection pool")
+		utils.LogError("DATABASE", "OpenDB", err, "Failed to create connection pool")
 		return nil, fmt.Errorf("%w: %v", ErrDBConnectionFailed, err)
 	}
 
@@ -61,19 +78,13 @@ func InitDB(cfg *config.Config) (*pgxpool.Pool, error) {
 
 	if err := pool.Ping(pingCtx); err != nil {
 		pool.Close()
-		utils.LogError("DATABASE", "InitDB", err, "Failed to ping database")
+		utils.LogError("DATABASE", "OpenDB", err, "Failed to ping database")
 		return nil, fmt.Errorf("%w: %v", ErrDBPingFailed, err)
 	}
 
 	utils.LogInfo("DATABASE", "PostgreSQL connected successfully", "max_conns",
 		poolConfig.MaxConns, "min_conns", poolConfig.MinConns)
 
-	if err := initTables(ctx, pool); err != nil {
-		pool.Close()
-		utils.LogError("DATABASE", "InitDB", err, "Failed to initialize tables")
-		return nil, fmt.Errorf("%w: %v", ErrDBTableInitFailed, err)
-	}
-
 	return pool, nil
 }
 
@@ -114,13 +125,3 @@ func configurePool(poolConfig *pgxpool.Config, cfg *config.Config) {
 	poolConfig.MaxConnIdleTime = defaultMaxConnIdleTime
 	poolConfig.HealthCheckPeriod = defaultHealthCheckPeriod
 }
-
-func initTables(ctx context.Context, pool *pgxpool.Pool) error {
-	if err := RunMigrations(pool); err != nil {
-		utils.LogError("DATABASE", "initTables", err, "Migration failed")
-		return fmt.Errorf("migration failed: %w", err)
-	}
-
-	utils.LogInfo("DATABASE", "Tables initialized successfully")
-	return nil
-}
@@ -0,0 +1,194 @@
+package models
+
+import (
+	"auth-system/internal/utils"
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// 邮件状态：pending 等待（重试）发送，sent / bounced / failed 为终态
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSent    = "sent"
+	EmailStatusBounced = "bounced" // 后端永久拒收（收件地址不存在、被拒信等），不再重试
+	EmailStatusFailed  = "failed"  // 重试次数耗尽或在过期前未能发出
+)
+
+// EmailMessage 出站邮件（队列项兼发送日志），内容在入队时渲染完成
+type EmailMessage struct {
+	ID            int64      `json:"id"`
+	Recipient     string     `json:"recipient"`
+	EmailType     string     `json:"email_type"`
+	Subject       string     `json:"subject"`
+	HTMLBody      string     `json:"-"`
+	TextBody      string     `json:"-"`
+	BodyEncrypted bool       `json:"-"` // 正文含一次性链接，已加密存储
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	Transport     string     `json:"transport"` // 最近一次尝试使用的发送后端
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"` // 过期后不再发送（如验证链接已失效），nil 表示不过期
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// EmailAttempt 一次发送尝试的结果
+type EmailAttempt struct {
+	Status        string    // 尝试后的状态
+	NextAttemptAt time.Time // 仍为 pending 时的下次尝试时间
+	Transport     string
+	Error         string
+}
+
+// EmailQueueStats 邮件队列统计
+type EmailQueueStats struct {
+	Pending         int64      `json:"pending"`                   // 队列深度
+	OldestPendingAt *time.Time `json:"oldestPendingAt,omitempty"` // 最早一封待发邮件的入队时间
+	Sent24h         int64      `json:"sent24h"`
+	Bounced24h      int64      `json:"bounced24h"`
+	Failed24h       int64      `json:"failed24h"`
+}
+
+// EmailQueueRepository 出站邮件队列仓库
+type EmailQueueRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewEmailQueueRepository 创建邮件队列仓库
+func NewEmailQueueRepository(pool *pgxpool.Pool) *EmailQueueRepository {
+	return &EmailQueueRepository{pool: pool}
+}
+
+const emailMessageColumns = `id, recipient, email_type, subject, html_body, text_body, body_encrypted, status, attempts, transport,
+	next_attempt_at, last_attempt_at, last_error, expires_at, sent_at, created_at`
+
+func emailMessageDest(m *EmailMessage) []any {
+	return []any{&m.ID, &m.Recipient, &m.EmailType, &m.Subject, &m.HTMLBody, &m.TextBody, &m.BodyEncrypted, &m.Status, &m.Attempts, &m.Transport,
+		&m.NextAttemptAt, &m.LastAttemptAt, &m.LastError, &m.ExpiresAt, &m.SentAt, &m.CreatedAt}
+}
+
+func (r *EmailQueueRepository) checkDB() error {
+	if r.pool == nil {
+		return ErrDBNotInitialized
+	}
+	return nil
+}
+
+// Enqueue 写入一封待发送邮件，回填 ID 与时间字段
+func (r *EmailQueueRepository) Enqueue(ctx context.Context, msg *EmailMessage) error {
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO email_messages (recipient, email_type, subject, html_body, text_body, body_encrypted, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, status, next_attempt_at, created_at
+	`, msg.Recipient, msg.EmailType, msg.Subject, msg.HTMLBody, msg.TextBody, msg.BodyEncrypted, msg.ExpiresAt).Scan(
+		&msg.ID, &msg.Status, &msg.NextAttemptAt, &msg.CreatedAt)
+	if err != nil {
+		return utils.LogError("EMAIL_QUEUE", "Enqueue", err, "to", msg.Recipient, "type", msg.EmailType)
+	}
+	return nil
+}
+
+// ClaimDue 领取最多 limit 封到期的待发送邮件，并将其 next_attempt_at 推后 lease（租约），
+// 防止发送期间被其他实例重复领取；发送结束后由 RecordAttempt 写入真实结果
+func (r *EmailQueueRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*EmailMessage, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		WITH due AS (
+			SELECT id FROM email_messages
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at ASC
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE email_messages m SET next_attempt_at = NOW() + $2::float8 * INTERVAL '1 second'
+		FROM due
+		WHERE m.id = due.id
+		RETURNING `+emailMessageColumns, limit, lease.Seconds())
+	if err != nil {
+		return nil, utils.LogError("EMAIL_QUEUE", "ClaimDue", err)
+	}
+	defer rows.Close()
+
+	messages := make([]*EmailMessage, 0)
+	for rows.Next() {
+		msg := &EmailMessage{}
+		if err := rows.Scan(emailMessageDest(msg)...); err != nil {
+			return nil, utils.LogError("EMAIL_QUEUE", "ClaimDue", err)
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.LogError("EMAIL_QUEUE", "ClaimDue", err)
+	}
+	return messages, nil
+}
+
+// RecordAttempt 记录一次发送尝试：累加尝试次数并写入结果，成功时记录发送时间；
+// 进入终态后清空正文，只保留发送记录
+func (r *EmailQueueRepository) RecordAttempt(ctx context.Context, id int64, attempt *EmailAttempt) error {
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+
+	_, err := r.pool.Exec(ctx, `
+		UPDATE email_messages
+		SET status = $1, attempts = attempts + 1, next_attempt_at = $2, last_attempt_at = NOW(),
+			transport = $3, last_error = $4,
+			sent_at = CASE WHEN $1 = 'sent' THEN NOW() ELSE sent_at END,
+			html_body = CASE WHEN $1 = 'pending' THEN html_body ELSE '' END,
+			text_body = CASE WHEN $1 = 'pending' THEN text_body ELSE '' END
+		WHERE id = $5
+	`, attempt.Status, attempt.NextAttemptAt, attempt.Transport, attempt.Error, id)
+	if err != nil {
+		return utils.LogError("EMAIL_QUEUE", "RecordAttempt", err, "id", id)
+	}
+	return nil
+}
+
+// GetStats 统计队列深度与最近 24 小时的发送结果
+func (r *EmailQueueRepository) GetStats(ctx context.Context) (*EmailQueueStats, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	stats := &EmailQueueStats{}
+	err := r.pool.QueryRow(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE status = 'pending'),
+			MIN(created_at) FILTER (WHERE status = 'pending'),
+			COUNT(*) FILTER (WHERE status = 'sent' AND created_at >= NOW() - INTERVAL '24 hours'),
+			COUNT(*) FILTER (WHERE status = 'bounced' AND created_at >= NOW() - INTERVAL '24 hours'),
+			COUNT(*) FILTER (WHERE status = 'failed' AND created_at >= NOW() - INTERVAL '24 hours')
+		FROM email_messages
+	`).Scan(&stats.Pending, &stats.OldestPendingAt, &stats.Sent24h, &stats.Bounced24h, &stats.Failed24h)
+	if err != nil {
+		return nil, utils.LogError("EMAIL_QUEUE", "GetStats", err)
+	}
+	return stats, nil
+}
+
+// DeleteFinishedBefore 删除 before 之前创建且已结束的邮件记录
+func (r *EmailQueueRepository) DeleteFinishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	if err := r.checkDB(); err != nil {
+		return 0, err
+	}
+
+	tag, err := r.pool.Exec(ctx, `
+		DELETE FROM email_messages WHERE status <> 'pending' AND created_at < $1
+	`, before)
+	if err != nil {
+		return 0, utils.LogError("EMAIL_QUEUE", "DeleteFinishedBefore", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 )
 
@@ -11,8 +12,6 @@ type UserReader interface {
 	FindByEmail(ctx context.Context, email string) (*User, error)
 	FindByEmailOrUsername(ctx context.Context, identifier string) (*User, error)
 	FindByUsername(ctx context.Context, username string) (*User, error)
-	FindByMicrosoftID(ctx context.Context, msID string) (*User, error)
-	FindByGoogleID(ctx context.Context, googleID string) (*User, error)
 }
 
 // UserWriter 用户写接口
@@ -26,11 +25,29 @@ type UserWriter interface {
 // UserAdminStore 用户管理接口（管理后台专用）
 type UserAdminStore interface {
 	FindAll(ctx context.Context, page, pageSize int, search string) ([]*User, int64, error)
+	FindRange(ctx context.Context, offset, limit int) ([]*User, int64, error)
 	GetStats(ctx context.Context) (*UserStats, error)
 	Ban(ctx context.Context, userUID, adminUID string, reason string, unbanAt *time.Time) error
 	Unban(ctx context.Context, userUID string) error
 }
 
+// UserTwoFactorStore 两步验证（TOTP）数据访问接口
+type UserTwoFactorStore interface {
+	SetPendingTOTPSecret(ctx context.Context, uid, encryptedSecret string) error
+	EnableTOTP(ctx context.Context, uid string, recoveryCodeHashes []string, step int64) error
+	DisableTOTP(ctx context.Context, uid string) error
+	AdvanceTOTPStep(ctx context.Context, uid string, step int64) (bool, error)
+	ConsumeRecoveryCode(ctx context.Context, uid, codeHash string) (int, bool, error)
+}
+
+// UserLoginLockStore 密码登录失败计数与锁定数据访问接口
+type UserLoginLockStore interface {
+	RecordLoginFailure(ctx context.Context, uid string, windowStart time.Time) (int, error)
+	LockLogin(ctx context.Context, uid string, until time.Time, unlockTokenHash string) error
+	ResetLoginFailures(ctx context.Context, uid string) error
+	UnlockLogin(ctx context.Context, unlockTokenHash string) (string, error)
+}
+
 // UserReadWriter 用户读写接口（业务侧常用组合：Auth / User / OAuth Handler）
 type UserReadWriter interface {
 	UserReader
@@ -42,6 +59,8 @@ type UserStore interface {
 	UserReader
 	UserWriter
 	UserAdminStore
+	UserTwoFactorStore
+	UserLoginLockStore
 }
 
 // UserLogStore 用户日志数据访问接口
@@ -50,6 +69,7 @@ type UserLogStore interface {
 	LogChangePassword(ctx context.Context, userUID string) error
 	LogRegister(ctx context.Context, userUID string) error
 	LogChangeUsername(ctx context.Context, userUID string, oldUsername, newUsername string) error
+	LogChangeEmail(ctx context.Context, userUID string, oldEmail, newEmail string) error
 	LogChangeAvatar(ctx context.Context, userUID string, oldURL, newURL string) error
 	LogEnableAvatarSync(ctx context.Context, userUID, provider string) error
 	LogDisableAvatarSync(ctx context.Context, userUID, provider string) error
@@ -57,16 +77,63 @@ type UserLogStore interface {
 	LogUnlinkMicrosoft(ctx context.Context, userUID string, microsoftID, microsoftName string) error
 	LogLinkGoogle(ctx context.Context, userUID string, googleID, googleName string) error
 	LogUnlinkGoogle(ctx context.Context, userUID string, googleID, googleName string) error
+	LogLinkIdentity(ctx context.Context, userUID string, provider, subject, displayName string) error
+	LogUnlinkIdentity(ctx context.Context, userUID string, provider, subject, displayName string) error
 	LogDeleteAccount(ctx context.Context, userUID string) error
 	LogBanned(ctx context.Context, userUID string, reason string, unbanAt *time.Time) error
 	LogUnbanned(ctx context.Context, userUID string) error
 	LogOAuthAuthorize(ctx context.Context, userUID string, clientID, clientName, scope string) error
 	LogOAuthRevoke(ctx context.Context, userUID string, clientID, clientName string) error
+	LogEnable2FA(ctx context.Context, userUID string) error
+	LogDisable2FA(ctx context.Context, userUID, resetBy string) error
+	LogUse2FARecoveryCode(ctx context.Context, userUID string, remaining int) error
+	LogRegisterPasskey(ctx context.Context, userUID string, credentialID int64, name string) error
+	LogDeletePasskey(ctx context.Context, userUID string, credentialID int64, name string) error
+	LogSecureAccount(ctx context.Context, userUID, ip, userAgent, familyID string) error
+	LogLoginFailed(ctx context.Context, userUID, ip, userAgent string, locked bool) error
 	FindByUserUID(ctx context.Context, userUID string, page, pageSize int) ([]*UserLog, int64, error)
 	DeleteByUserUID(ctx context.Context, userUID string) error
 	DeleteExpiredLogs(ctx context.Context) (int64, error)
 }
 
+// WebAuthnCredentialStore Passkey（WebAuthn 凭据）数据访问接口
+type WebAuthnCredentialStore interface {
+	Create(ctx context.Context, cred *WebAuthnCredential) error
+	FindByCredentialID(ctx context.Context, credentialID []byte) (*WebAuthnCredential, error)
+	FindByID(ctx context.Context, userUID string, id int64) (*WebAuthnCredential, error)
+	ListByUser(ctx context.Context, userUID string) ([]*WebAuthnCredential, error)
+	Rename(ctx context.Context, userUID string, id int64, name string) error
+	Delete(ctx context.Context, userUID string, id int64) error
+	UpdateUsage(ctx context.Context, id int64, signCount int64) error
+}
+
+// UserIdentityStore 外部身份（通用 OIDC 上游 IdP）数据访问接口
+type UserIdentityStore interface {
+	FindBySubject(ctx context.Context, provider, subject string) (*UserIdentity, error)
+	FindByUser(ctx context.Context, userUID, provider string) (*UserIdentity, error)
+	ListByUser(ctx context.Context, userUID string) ([]*UserIdentity, error)
+	Link(ctx context.Context, identity *UserIdentity) error
+	UpdateProfile(ctx context.Context, identity *UserIdentity) error
+	Delete(ctx context.Context, userUID, provider string) error
+}
+
+// OAuthFlowStateStore 短期流程状态数据访问接口（外部登录 state、两步验证与 Passkey 挑战）
+type OAuthFlowStateStore interface {
+	Save(ctx context.Context, kind, key string, payload []byte, ttl time.Duration) error
+	Get(ctx context.Context, kind, key string) ([]byte, error)
+	GetAndDelete(ctx context.Context, kind, key string) ([]byte, error)
+	Delete(ctx context.Context, kind, key string) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// RateLimitStore 跨实例共享的限流状态数据访问接口
+type RateLimitStore interface {
+	Take(ctx context.Context, bucket, key string, emission, window time.Duration) (bool, time.Duration, error)
+	Peek(ctx context.Context, bucket, key string, emission, window time.Duration) (time.Duration, error)
+	Limited(ctx context.Context, bucket string, emission, window time.Duration) ([]LimitedKey, error)
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
 // UserConsentStore 用户政策同意记录数据访问接口
 type UserConsentStore interface {
 	Create(ctx context.Context, consent *UserConsent) error
@@ -101,6 +168,66 @@ type EmailWhitelistStore interface {
 	InitDefaultWhitelist(ctx context.Context, domains string) error
 }
 
+// UserPermissionReader 查询用户经由用户组获得的后台权限
+type UserPermissionReader interface {
+	PermissionsForUser(ctx context.Context, userUID string) ([]string, error)
+}
+
+// UserGroupReader 查询用户所属的用户组（OAuth groups claim 使用）
+type UserGroupReader interface {
+	FindByUser(ctx context.Context, userUID string) ([]*UserGroup, error)
+}
+
+// UserGroupStore 用户组数据访问接口
+type UserGroupStore interface {
+	UserPermissionReader
+	UserGroupReader
+	FindAll(ctx context.Context) ([]*UserGroup, error)
+	FindByID(ctx context.Context, id int64) (*UserGroup, error)
+	Create(ctx context.Context, group *UserGroup) error
+	Update(ctx context.Context, group *UserGroup) error
+	Delete(ctx context.Context, id int64) (*UserGroup, error)
+	ListMembers(ctx context.Context, groupID int64) ([]*UserGroupMember, error)
+	AddMember(ctx context.Context, groupID int64, userUID string) error
+	RemoveMember(ctx context.Context, groupID int64, userUID string) error
+}
+
+// WebhookStore Webhook 端点与投递数据访问接口
+type WebhookStore interface {
+	FindAll(ctx context.Context) ([]*WebhookEndpoint, error)
+	FindByID(ctx context.Context, id int64) (*WebhookEndpoint, error)
+	Create(ctx context.Context, endpoint *WebhookEndpoint) error
+	Update(ctx context.Context, endpoint *WebhookEndpoint) error
+	UpdateSecret(ctx context.Context, id int64, secret string) error
+	Delete(ctx context.Context, id int64) (*WebhookEndpoint, error)
+	Enqueue(ctx context.Context, eventID, eventType string, payload json.RawMessage) (int64, error)
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, id int64, attempt *WebhookAttempt) error
+	FindDeliveryByID(ctx context.Context, id int64) (*WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, endpointID int64, page, pageSize int) ([]*WebhookDelivery, int64, error)
+	Redeliver(ctx context.Context, id int64) (*WebhookDelivery, error)
+	DeleteFinishedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// EmailQueueStore 出站邮件队列接口
+type EmailQueueStore interface {
+	Enqueue(ctx context.Context, msg *EmailMessage) error
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*EmailMessage, error)
+	RecordAttempt(ctx context.Context, id int64, attempt *EmailAttempt) error
+	GetStats(ctx context.Context) (*EmailQueueStats, error)
+	DeleteFinishedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// KnownDeviceStore 已知登录设备与新设备登录提醒数据访问接口
+type KnownDeviceStore interface {
+	ListByUser(ctx context.Context, userUID string) ([]*KnownDevice, error)
+	Upsert(ctx context.Context, device *KnownDevice) error
+	Delete(ctx context.Context, userUID string, id int64) error
+	CreateAlert(ctx context.Context, alert *LoginAlert) error
+	ConsumeAlert(ctx context.Context, tokenHash string) (*LoginAlert, error)
+	DeleteExpired(ctx context.Context, staleBefore time.Time) (int64, error)
+}
+
 // AdminLogStore 管理员操作日志接口
 type AdminLogStore interface {
 	Create(ctx context.Context, log *AdminLog) error
@@ -108,16 +235,36 @@ type AdminLogStore interface {
 	LogDeleteUser(ctx context.Context, adminUID, targetUID string, targetUsername, targetEmail string) error
 	LogBanUser(ctx context.Context, adminUID, targetUID string, targetUsername, reason string, unbanAt *time.Time) error
 	LogUnbanUser(ctx context.Context, adminUID, targetUID string, targetUsername string) error
+	LogReset2FA(ctx context.Context, adminUID, targetUID string, targetUsername string) error
 	LogOAuthClientCreate(ctx context.Context, adminUID string, clientDBID int64, clientID, clientName string) error
 	LogOAuthClientUpdate(ctx context.Context, adminUID string, clientDBID int64, clientID, clientName string) error
 	LogOAuthClientDelete(ctx context.Context, adminUID string, clientDBID int64, clientID, clientName string) error
 	LogOAuthClientRegenerateSecret(ctx context.Context, adminUID string, clientDBID int64, clientID, clientName string) error
 	LogOAuthClientToggle(ctx context.Context, adminUID string, clientDBID int64, clientID, clientName string, enabled bool) error
+	LogOAuthScopeCreate(ctx context.Context, adminUID string, scope *OAuthScope) error
+	LogOAuthScopeUpdate(ctx context.Context, adminUID string, scope *OAuthScope) error
+	LogOAuthScopeDelete(ctx context.Context, adminUID string, scope *OAuthScope) error
 	LogEmailWhitelistCreate(ctx context.Context, adminUID string, entry *EmailWhitelist) error
 	LogEmailWhitelistUpdate(ctx context.Context, adminUID string, entry *EmailWhitelist) error
 	LogEmailWhitelistDelete(ctx context.Context, adminUID string, id int64) error
 	LogDataExport(ctx context.Context, adminUID string, usersCount, logsCount int) error
 	LogDataImport(ctx context.Context, adminUID string, usersImported, logsImported int) error
+	LogUserGroupCreate(ctx context.Context, adminUID string, group *UserGroup) error
+	LogUserGroupUpdate(ctx context.Context, adminUID string, group *UserGroup) error
+	LogUserGroupDelete(ctx context.Context, adminUID string, group *UserGroup) error
+	LogUserGroupAddMember(ctx context.Context, adminUID, targetUID string, targetUsername string, group *UserGroup) error
+	LogUserGroupRemoveMember(ctx context.Context, adminUID, targetUID string, targetUsername string, group *UserGroup) error
+	LogImpersonationStart(ctx context.Context, adminUID, targetUID string, targetUsername, reason string, expiresAt time.Time) error
+	LogImpersonationStop(ctx context.Context, adminUID, targetUID string, targetUsername string) error
+	LogWebhookCreate(ctx context.Context, adminUID string, endpoint *WebhookEndpoint) error
+	LogWebhookUpdate(ctx context.Context, adminUID string, endpoint *WebhookEndpoint) error
+	LogWebhookDelete(ctx context.Context, adminUID string, endpoint *WebhookEndpoint) error
+	LogWebhookRegenerateSecret(ctx context.Context, adminUID string, endpoint *WebhookEndpoint) error
+	LogWebhookRedeliver(ctx context.Context, adminUID string, endpoint *WebhookEndpoint, deliveryID int64) error
+	LogSCIMUserCreate(ctx context.Context, client *OAuthClient, targetUID, targetUsername string) error
+	LogSCIMUserUpdate(ctx context.Context, client *OAuthClient, targetUID, targetUsername string, attributes []string) error
+	LogSCIMUserDeactivate(ctx context.Context, client *OAuthClient, targetUID, targetUsername string) error
+	LogSCIMUserReactivate(ctx context.Context, client *OAuthClient, targetUID, targetUsername string) error
 	FindAll(ctx context.Context, page, pageSize int) ([]*AdminLogPublic, int64, error)
 }
 
@@ -137,7 +284,11 @@ type SessionTokenStore interface {
 	Create(ctx context.Context, token *SessionToken) error
 	FindByHash(ctx context.Context, tokenHash string) (*SessionToken, error)
 	MarkUsed(ctx context.Context, id int64) error
+	ListActiveByUser(ctx context.Context, userUID string) ([]*SessionToken, error)
+	FamilyActive(ctx context.Context, familyID string) (bool, error)
 	RevokeFamily(ctx context.Context, familyID string) (int64, error)
+	RevokeUserFamily(ctx context.Context, userUID, familyID string) (int64, error)
+	RevokeUserExcept(ctx context.Context, userUID, keepFamilyID string) (int64, error)
 	RevokeUser(ctx context.Context, userUID string) (int64, error)
 	DeleteExpired(ctx context.Context) (int64, error)
 }
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"auth-system/internal/utils"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrLoginAlertNotFound = errors.New("LOGIN_ALERT_NOT_FOUND")
+
+// 登录风险原因
+const (
+	LoginRiskNewDevice        = "new_device"
+	LoginRiskNewCountry       = "new_country"
+	LoginRiskNewBrowser       = "new_browser"
+	LoginRiskImpossibleTravel = "impossible_travel"
+)
+
+// KnownDevice 用户登录过的设备，以 (IP, User-Agent) 识别
+type KnownDevice struct {
+	ID          int64     `json:"id"`
+	UserUID     string    `json:"-"`
+	IP          string    `json:"ip"`
+	UserAgent   string    `json:"user_agent"`
+	Browser     string    `json:"browser"`
+	OS          string    `json:"os"`
+	Country     string    `json:"country"`
+	City        string    `json:"city"`
+	Latitude    *float64  `json:"latitude,omitempty"`
+	Longitude   *float64  `json:"longitude,omitempty"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// LoginAlert 新设备登录提醒，Token 明文仅出现在邮件链接中，库中只存 SHA-256
+type LoginAlert struct {
+	TokenHash string
+	UserUID   string
+	FamilyID  string // 触发提醒的会话族，为空表示无法定位（撤销用户全部会话）
+	DeviceID  int64
+	IP        string
+	UserAgent string
+	Reasons   []string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// KnownDeviceRepository 已知设备与登录提醒仓库
+type KnownDeviceRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewKnownDeviceRepository 创建已知设备仓库
+func NewKnownDeviceRepository(pool *pgxpool.Pool) *KnownDeviceRepository {
+	return &KnownDeviceRepository{pool: pool}
+}
+
+const knownDeviceColumns = `id, user_uid, ip, user_agent, browser, os, country, city, latitude, longitude, first_seen_at, last_seen_at`
+
+func scanKnownDevice(row pgx.Row, d *KnownDevice) error {
+	return row.Scan(&d.ID, &d.UserUID, &d.IP, &d.UserAgent, &d.Browser, &d.OS, &d.Country, &d.City,
+		&d.Latitude, &d.Longitude, &d.FirstSeenAt, &d.LastSeenAt)
+}
+
+func (r *KnownDeviceRepository) checkDB() error {
+	if r.pool == nil {
+		return ErrDBNotInitialized
+	}
+	return nil
+}
+
+// ListByUser 获取用户的全部已知设备（最近使用的在前）
+func (r *KnownDeviceRepository) ListByUser(ctx context.Context, userUID string) ([]*KnownDevice, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.pool.Query(ctx, `SELECT `+knownDeviceColumns+` FROM user_known_devices
+		WHERE user_uid = $1 ORDER BY last_seen_at DESC`, userUID)
+	if err != nil {
+		return nil, utils.LogError("KNOWN_DEVICE", "ListByUser", err, "user_uid", userUID)
+	}
+	defer rows.Close()
+
+	devices := make([]*KnownDevice, 0)
+	for rows.Next() {
+		device := &KnownDevice{}
+		if err := scanKnownDevice(rows, device); err != nil {
+			return nil, utils.LogError("KNOWN_DEVICE", "ListByUser", err, "user_uid", userUID)
+		}
+		devices = append(devices, device)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.LogError("KNOWN_DEVICE", "ListByUser", err, "user_uid", userUID)
+	}
+	return devices, nil
+}
+
+// Upsert 记录一次登录：设备不存在时创建，已存在时刷新 last_seen_at 与位置，回填 ID 与时间
+func (r *KnownDeviceRepository) Upsert(ctx context.Context, device *KnownDevice) error {
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO user_known_devices (user_uid, ip, user_agent, browser, os, country, city, latitude, longitude)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (user_uid, ip, user_agent) DO UPDATE SET
+			country = EXCLUDED.country, city = EXCLUDED.city,
+			latitude = EXCLUDED.latitude, longitude = EXCLUDED.longitude,
+			last_seen_at = NOW()
+		RETURNING id, first_seen_at, last_seen_at
+	`, device.UserUID, device.IP, device.UserAgent, device.Browser, device.OS, device.Country, device.City,
+		device.Latitude, device.Longitude).Scan(&device.ID, &device.FirstSeenAt, &device.LastSeenAt)
+	if err != nil {
+		return utils.LogError("KNOWN_DEVICE", "Upsert", err, "user_uid", device.UserUID)
+	}
+	return nil
+}
+
+// Delete 删除用户的某台已知设备（不存在时不报错）
+func (r *KnownDeviceRepository) Delete(ctx context.Context, userUID string, id int64) error {
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+
+	if _, err := r.pool.Exec(ctx, `DELETE FROM user_known_devices WHERE user_uid = $1 AND id = $2`, userUID, id); err != nil {
+		return utils.LogError("KNOWN_DEVICE", "Delete", err, "user_uid", userUID, "id", id)
+	}
+	return nil
+}
+
+// CreateAlert 保存登录提醒
+func (r *KnownDeviceRepository) CreateAlert(ctx context.Context, alert *LoginAlert) error {
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO login_alerts (token_hash, user_uid, family_id, device_id, ip, user_agent, reasons, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING created_at
+	`, alert.TokenHash, alert.UserUID, alert.FamilyID, alert.DeviceID, alert.IP, alert.UserAgent, alert.Reasons,
+		alert.ExpiresAt).Scan(&alert.CreatedAt)
+	if err != nil {
+		return utils.LogError("KNOWN_DEVICE", "CreateAlert", err, "user_uid", alert.UserUID)
+	}
+	return nil
+}
+
+// ConsumeAlert 原子地将未使用且未过期的提醒标记为已使用并返回；
+// 不存在、已使用或已过期时返回 ErrLoginAlertNotFound
+func (r *KnownDeviceRepository) ConsumeAlert(ctx context.Context, tokenHash string) (*LoginAlert, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	alert := &LoginAlert{}
+	err := r.pool.QueryRow(ctx, `
+		UPDATE login_alerts SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING token_hash, user_uid, family_id, device_id, ip, user_agent, reasons, created_at, expires_at, used_at
+	`, tokenHash).Scan(&alert.TokenHash, &alert.UserUID, &alert.FamilyID, &alert.DeviceID, &alert.IP, &alert.UserAgent,
+		&alert.Reasons, &alert.CreatedAt, &alert.ExpiresAt, &alert.UsedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrLoginAlertNotFound
+		}
+		return nil, utils.LogError("KNOWN_DEVICE", "ConsumeAlert", err, "token_hash", utils.TruncateIdentifier(tokenHash))
+	}
+	return alert, nil
+}
+
+// DeleteExpired 清理已过期的登录提醒与 staleBefore 之前未再出现的设备，返回删除总数
+func (r *KnownDeviceRepository) DeleteExpired(ctx context.Context, staleBefore time.Time) (int64, error) {
+	if err := r.checkDB(); err != nil {
+		return 0, err
+	}
+
+	alerts, err := r.pool.Exec(ctx, `DELETE FROM login_alerts WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, utils.LogError("KNOWN_DEVICE", "DeleteExpired", err)
+	}
+	devices, err := r.pool.Exec(ctx, `DELETE FROM user_known_devices WHERE last_seen_at < $1`, staleBefore)
+	if err != nil {
+		return alerts.RowsAffected(), utils.LogError("KNOWN_DEVICE", "DeleteExpired", err)
+	}
+
+	count := alerts.RowsAffected() + devices.RowsAffected()
+	if count > 0 {
+		utils.LogInfo("KNOWN_DEVICE", "Deleted expired login alerts and stale devices",
+			"alerts", alerts.RowsAffected(), "devices", devices.RowsAffected())
+	}
+	return count, nil
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"auth-system/internal/utils"
+	"cmp"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// migrationFiles 版本化迁移文件，命名为 {版本号}_{说明}.up.sql / .down.sql
+//
+// 已发布的迁移文件不可修改；schema 变更一律追加新版本，并同时提供 down 文件。
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var (
+	ErrSchemaOutdated = errors.New("database schema is behind, run `migrate up`")
+	ErrSchemaDirty    = errors.New("database schema is dirty, fix manually and run `migrate force`")
+)
+
+var migrationNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.up\.sql$`)
+
+// Migration 一个可用的迁移版本
+type Migration struct {
+	Version uint
+	Name    string
+}
+
+// MigrationStatus 数据库迁移状态
+type MigrationStatus struct {
+	Version uint // 当前已应用的版本，0 表示尚未迁移
+	Dirty   bool // 上次迁移中途失败，需人工修复后 force
+	Latest  uint // 二进制内嵌的最新版本
+	Pending []Migration
+}
+
+// AvailableMigrations 返回内嵌的全部迁移，按版本升序
+func AvailableMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read embedded migrations: %w", err)
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		match := migrationNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, Migration{Version: uint(version), Name: match[2]})
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	return migrations, nil
+}
+
+// newMigrator 基于连接池创建 golang-migrate 实例，调用方负责 Close
+// golang-migrate 在执行期间持有 PostgreSQL advisory lock，多实例并发执行时串行化
+func newMigrator(pool *pgxpool.Pool) (*migrate.Migrate, error) {
+	if pool == nil {
+		return nil, ErrDBNotInitialized
+	}
+
+	driver, err := postgres.WithInstance(stdlib.OpenDBFromPool(pool), &postgres.Config{})
+	if err != nil {
+		return nil, utils.LogError("DATABASE", "newMigrator", fmt.Errorf("create postgres driver: %w", err))
+	}
+
+	source, err := iofs.New(migrationFiles, "migrations")
+	if err != nil {
+		return nil, utils.LogError("DATABASE", "newMigrator", fmt.Errorf("create migration source: %w", err))
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		return nil, utils.LogError("DATABASE", "newMigrator", fmt.Errorf("create migrator: %w", err))
+	}
+	return m, nil
+}
+
+// closeMigrator 关闭迁移实例（会一并关闭其持有的 *sql.DB，不影响连接池）
+func closeMigrator(m *migrate.Migrate) {
+	if srcErr, dbErr := m.Close(); srcErr != nil || dbErr != nil {
+		utils.LogWarn("DATABASE", "Failed to close migrator", "source_error", srcErr, "db_error", dbErr)
+	}
+}
+
+// MigrateUp 应用全部未执行的迁移
+func MigrateUp(pool *pgxpool.Pool) error {
+	m, err := newMigrator(pool)
+	if err != nil {
+		return err
+	}
+	defer closeMigrator(m)
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return utils.LogError("DATABASE", "MigrateUp", err)
+	}
+
+	utils.LogInfo("DATABASE", "Migrations applied")
+	return nil
+}
+
+// MigrateDown 回退 steps 个版本
+func MigrateDown(pool *pgxpool.Pool, steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("steps must be positive, got %d", steps)
+	}
+
+	m, err := newMigrator(pool)
+	if err != nil {
+		return err
+	}
+	defer closeMigrator(m)
+
+	if err := m.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return utils.LogError("DATABASE", "MigrateDown", err, "steps", steps)
+	}
+
+	utils.LogInfo("DATABASE", "Migrations rolled back", "steps", steps)
+	return nil
+}
+
+// MigrateForce 将记录的版本强制设为 version 并清除 dirty 标记，不执行任何迁移 SQL。
+// 用于迁移中途失败、人工修复 schema 之后；version 为 -1 表示清空版本记录
+func MigrateForce(pool *pgxpool.Pool, version int) error {
+	m, err := newMigrator(pool)
+	if err != nil {
+		return err
+	}
+	defer closeMigrator(m)
+
+	if err := m.Force(version); err != nil {
+		return utils.LogError("DATABASE", "MigrateForce", err, "version", version)
+	}
+
+	utils.LogInfo("DATABASE", "Migration version forced", "version", version)
+	return nil
+}
+
+// GetMigrationStatus 查询当前版本与待执行的迁移
+func GetMigrationStatus(pool *pgxpool.Pool) (*MigrationStatus, error) {
+	available, err := AvailableMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := newMigrator(pool)
+	if err != nil {
+		return nil, err
+	}
+	defer closeMigrator(m)
+
+	version, dirty, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, utils.LogError("DATABASE", "GetMigrationStatus", err)
+	}
+
+	status := &MigrationStatus{Version: version, Dirty: dirty}
+	for _, mig := range available {
+		status.Latest = max(status.Latest, mig.Version)
+		if mig.Version > version {
+			status.Pending = append(status.Pending, mig)
+		}
+	}
+	return status, nil
+}
+
+// CheckSchemaVersion 启动检查：数据库未迁移到内嵌的最新版本或处于 dirty 状态时返回错误。
+// 数据库版本高于二进制（回滚部署）时只记录警告
+func CheckSchemaVersion(pool *pgxpool.Pool) error {
+	status, err := GetMigrationStatus(pool)
+	if err != nil {
+		return err
+	}
+
+	if status.Dirty {
+		return fmt.Errorf("%w (version %d)", ErrSchemaDirty, status.Version)
+	}
+	if len(status.Pending) > 0 {
+		return fmt.Errorf("%w (current %d, latest %d, %d pending)", ErrSchemaOutdated, status.Version, status.Latest, len(status.Pending))
+	}
+	if status.Version > status.Latest {
+		utils.LogWarn("DATABASE", "Database schema is newer than this binary", "version", status.Version, "latest", status.Latest)
+	}
+
+	utils.LogInfo("DATABASE", "Database schema is up to date", "version", status.Version)
+	return nil
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedMigrationsAreContiguousAndReversible(t *testing.T) {
+	migrations, err := AvailableMigrations()
+	if err != nil {
+		t.Fatalf("AvailableMigrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+
+	for i, m := range migrations {
+		if m.Version != uint(i+1) {
+			t.Fatalf("migration %d has version %d, want %d (versions must be contiguous from 1)", i, m.Version, i+1)
+		}
+		for _, dir := range []string{"up", "down"} {
+			name := migrationFileName(m, dir)
+			data, err := fs.ReadFile(migrationFiles, "migrations/"+name)
+			if err != nil {
+				t.Errorf("missing %s: %v", name, err)
+				continue
+			}
+			if strings.TrimSpace(string(data)) == "" {
+				t.Errorf("%s is empty", name)
+			}
+		}
+	}
+}
+
+// 早期版本 1 由程序按当时的表定义生成，已有数据库可能已包含后续版本新增的列或表，升级迁移必须幂等
+func TestUpgradeMigrationsAreIdempotent(t *testing.T) {
+	migrations, err := AvailableMigrations()
+	if err != nil {
+		t.Fatalf("AvailableMigrations: %v", err)
+	}
+
+	unguarded := regexp.MustCompile(`(?i)\b(ADD COLUMN|CREATE TABLE|CREATE INDEX)\s+(?:IF NOT EXISTS\b)?`)
+	for _, m := range migrations {
+		if m.Version == 1 {
+			continue
+		}
+		name := migrationFileName(m, "up")
+		data, err := fs.ReadFile(migrationFiles, "migrations/"+name)
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		for _, match := range unguarded.FindAllString(string(data), -1) {
+			if !strings.Contains(strings.ToUpper(match), "IF NOT EXISTS") {
+				t.Errorf("%s: %q without IF NOT EXISTS", name, strings.TrimSpace(match))
+			}
+		}
+	}
+}
+
+func migrationFileName(m Migration, direction string) string {
+	return fmt.Sprintf("%06d_%s.%s.sql", m.Version, m.Name, direction)
+}
@@ -0,0 +1,15 @@
+-- 删除全部基础表（按外键依赖逆序）
+DROP TABLE IF EXISTS "email_whitelist";
+DROP TABLE IF EXISTS "session_tokens";
+DROP TABLE IF EXISTS "oauth_grants";
+DROP TABLE IF EXISTS "oauth_refresh_tokens";
+DROP TABLE IF EXISTS "oauth_access_tokens";
+DROP TABLE IF EXISTS "oauth_auth_codes";
+DROP TABLE IF EXISTS "oauth_clients";
+DROP TABLE IF EXISTS "user_consents";
+DROP TABLE IF EXISTS "user_logs";
+DROP TABLE IF EXISTS "admin_logs";
+DROP TABLE IF EXISTS "qr_login_tokens";
+DROP TABLE IF EXISTS "codes";
+DROP TABLE IF EXISTS "tokens";
+DROP TABLE IF EXISTS "users";
@@ -0,0 +1,194 @@
+-- 初始 schema：全部基础表与索引
+-- 早期版本由程序生成同名的版本 1，已有数据库直接从版本 2 开始升级，因此本文件保持与其一致
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" SERIAL NOT NULL PRIMARY KEY,
+    "uid" VARCHAR(16) NOT NULL UNIQUE,
+    "username" VARCHAR(50) NOT NULL UNIQUE,
+    "email" VARCHAR(255) NOT NULL UNIQUE,
+    "password" VARCHAR(255) NOT NULL,
+    "avatar_url" TEXT NOT NULL,
+    "role" INTEGER NOT NULL DEFAULT 0,
+    "microsoft_id" VARCHAR(255) UNIQUE,
+    "microsoft_name" VARCHAR(255),
+    "microsoft_avatar_url" TEXT,
+    "microsoft_avatar_hash" VARCHAR(64),
+    "google_id" VARCHAR(255) UNIQUE,
+    "google_name" VARCHAR(255),
+    "google_avatar_url" TEXT,
+    "microsoft_avatar_sync" BOOLEAN NOT NULL DEFAULT TRUE,
+    "is_banned" BOOLEAN NOT NULL DEFAULT FALSE,
+    "ban_reason" TEXT,
+    "banned_at" TIMESTAMPTZ,
+    "banned_by" VARCHAR(16),
+    "unban_at" TIMESTAMPTZ,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS "tokens" (
+    "token_hash" VARCHAR(64) NOT NULL PRIMARY KEY,
+    "email" VARCHAR(255) NOT NULL,
+    "type" VARCHAR(50) DEFAULT 'register',
+    "code" VARCHAR(10),
+    "created_at" BIGINT NOT NULL,
+    "expire_time" BIGINT NOT NULL,
+    "used" INTEGER DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS "codes" (
+    "code" VARCHAR(10) NOT NULL PRIMARY KEY,
+    "email" VARCHAR(255) NOT NULL,
+    "type" VARCHAR(50) DEFAULT 'register',
+    "created_at" BIGINT NOT NULL,
+    "expire_time" BIGINT NOT NULL,
+    "attempts" INTEGER DEFAULT 0,
+    "verified" INTEGER DEFAULT 0,
+    "verified_at" BIGINT
+);
+
+CREATE TABLE IF NOT EXISTS "qr_login_tokens" (
+    "token_hash" VARCHAR(64) NOT NULL PRIMARY KEY,
+    "status" VARCHAR(20) DEFAULT 'pending',
+    "user_uid" VARCHAR(16),
+    "pc_ip" VARCHAR(45),
+    "pc_user_agent" TEXT,
+    "created_at" BIGINT NOT NULL,
+    "expire_time" BIGINT NOT NULL,
+    "scanned_at" BIGINT,
+    "confirmed_at" BIGINT,
+    "pc_session_token_hash" VARCHAR(64)
+);
+
+CREATE TABLE IF NOT EXISTS "admin_logs" (
+    "id" SERIAL NOT NULL PRIMARY KEY,
+    "admin_uid" VARCHAR(16) NOT NULL,
+    "action" VARCHAR(50) NOT NULL,
+    "target_uid" VARCHAR(16),
+    "details" JSONB,
+    "created_at" TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS "user_logs" (
+    "id" SERIAL NOT NULL PRIMARY KEY,
+    "user_uid" VARCHAR(16) NOT NULL,
+    "action" VARCHAR(50) NOT NULL,
+    "details" JSONB,
+    "created_at" TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS "user_consents" (
+    "id" SERIAL NOT NULL PRIMARY KEY,
+    "user_uid" VARCHAR(16) NOT NULL,
+    "policy_type" VARCHAR(20) NOT NULL,
+    "policy_version" VARCHAR(20) NOT NULL,
+    "created_at" TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS "oauth_clients" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "client_id" VARCHAR(64) NOT NULL UNIQUE,
+    "client_secret_hash" VARCHAR(255) NOT NULL,
+    "name" VARCHAR(100) NOT NULL,
+    "description" TEXT,
+    "redirect_uri" TEXT NOT NULL,
+    "is_enabled" BOOLEAN DEFAULT true,
+    "created_at" TIMESTAMPTZ DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS "oauth_auth_codes" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "code_hash" VARCHAR(64) NOT NULL UNIQUE,
+    "client_id" VARCHAR(64) NOT NULL,
+    "user_uid" VARCHAR(16) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    "redirect_uri" TEXT NOT NULL,
+    "scope" VARCHAR(255) NOT NULL,
+    "code_challenge" VARCHAR(128),
+    "code_challenge_method" VARCHAR(10),
+    "expires_at" TIMESTAMPTZ NOT NULL,
+    "used" BOOLEAN DEFAULT false,
+    "created_at" TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS "oauth_access_tokens" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "token_hash" VARCHAR(64) NOT NULL UNIQUE,
+    "client_id" VARCHAR(64) NOT NULL,
+    "user_uid" VARCHAR(16) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    "scope" VARCHAR(255) NOT NULL,
+    "expires_at" TIMESTAMPTZ NOT NULL,
+    "created_at" TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS "oauth_refresh_tokens" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "token_hash" VARCHAR(64) NOT NULL UNIQUE,
+    "client_id" VARCHAR(64) NOT NULL,
+    "user_uid" VARCHAR(16) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    "scope" VARCHAR(255) NOT NULL,
+    "expires_at" TIMESTAMPTZ NOT NULL,
+    "access_token_id" BIGINT REFERENCES oauth_access_tokens(id) ON DELETE SET NULL,
+    "created_at" TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS "oauth_grants" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "user_uid" VARCHAR(16) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    "client_id" VARCHAR(64) NOT NULL,
+    "scope" VARCHAR(255) NOT NULL,
+    "created_at" TIMESTAMPTZ DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE("user_uid", "client_id")
+);
+
+CREATE TABLE IF NOT EXISTS "session_tokens" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "token_hash" VARCHAR(64) NOT NULL UNIQUE,
+    "user_uid" VARCHAR(16) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    "family_id" VARCHAR(64) NOT NULL,
+    "banned" BOOLEAN NOT NULL DEFAULT FALSE,
+    "expires_at" TIMESTAMPTZ NOT NULL,
+    "created_at" TIMESTAMPTZ DEFAULT NOW(),
+    "used" BOOLEAN NOT NULL DEFAULT FALSE,
+    "used_at" TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS "email_whitelist" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "domain" VARCHAR(255) NOT NULL UNIQUE,
+    "signup_url" TEXT NOT NULL,
+    "logo_url" TEXT NOT NULL DEFAULT '',
+    "is_enabled" BOOLEAN NOT NULL DEFAULT true,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+CREATE INDEX IF NOT EXISTS idx_users_username ON users(LOWER(username));
+CREATE INDEX IF NOT EXISTS idx_users_microsoft_id ON users(microsoft_id);
+CREATE INDEX IF NOT EXISTS idx_users_google_id ON users(google_id);
+CREATE INDEX IF NOT EXISTS idx_tokens_email_type ON tokens(email, type);
+CREATE INDEX IF NOT EXISTS idx_tokens_expire ON tokens(expire_time);
+CREATE INDEX IF NOT EXISTS idx_codes_email_type ON codes(email, type);
+CREATE INDEX IF NOT EXISTS idx_codes_expire ON codes(expire_time);
+CREATE INDEX IF NOT EXISTS idx_qr_tokens_expire ON qr_login_tokens(expire_time);
+CREATE INDEX IF NOT EXISTS idx_admin_logs_admin_uid ON admin_logs(admin_uid);
+CREATE INDEX IF NOT EXISTS idx_admin_logs_created_at ON admin_logs(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_user_logs_user_uid ON user_logs(user_uid);
+CREATE INDEX IF NOT EXISTS idx_user_logs_created_at ON user_logs(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_user_consents_user_uid ON user_consents(user_uid);
+CREATE INDEX IF NOT EXISTS idx_oauth_clients_client_id ON oauth_clients(client_id);
+CREATE INDEX IF NOT EXISTS idx_oauth_auth_codes_code ON oauth_auth_codes(code_hash);
+CREATE INDEX IF NOT EXISTS idx_oauth_auth_codes_expires ON oauth_auth_codes(expires_at);
+CREATE INDEX IF NOT EXISTS idx_oauth_access_tokens_hash ON oauth_access_tokens(token_hash);
+CREATE INDEX IF NOT EXISTS idx_oauth_access_tokens_user_uid ON oauth_access_tokens(user_uid);
+CREATE INDEX IF NOT EXISTS idx_oauth_access_tokens_expires ON oauth_access_tokens(expires_at);
+CREATE INDEX IF NOT EXISTS idx_oauth_refresh_tokens_hash ON oauth_refresh_tokens(token_hash);
+CREATE INDEX IF NOT EXISTS idx_oauth_refresh_tokens_user_uid ON oauth_refresh_tokens(user_uid);
+CREATE INDEX IF NOT EXISTS idx_oauth_refresh_tokens_expires ON oauth_refresh_tokens(expires_at);
+CREATE INDEX IF NOT EXISTS idx_oauth_grants_user_uid ON oauth_grants(user_uid);
+CREATE INDEX IF NOT EXISTS idx_session_tokens_user_uid ON session_tokens(user_uid);
+CREATE INDEX IF NOT EXISTS idx_session_tokens_token_hash ON session_tokens(token_hash);
+CREATE INDEX IF NOT EXISTS idx_session_tokens_family_id ON session_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_session_tokens_expires_at ON session_tokens(expires_at);
@@ -0,0 +1,3 @@
+-- OIDC：授权码携带 nonce，签发 id_token 时回填
+-- 以下升级均使用 IF NOT EXISTS：早期版本的版本 1 可能已包含部分列或表
+ALTER TABLE "oauth_auth_codes" ADD COLUMN IF NOT EXISTS "nonce" VARCHAR(255);
@@ -0,0 +1,6 @@
+ALTER TABLE "users"
+    DROP COLUMN IF EXISTS "totp_secret",
+    DROP COLUMN IF EXISTS "totp_enabled",
+    DROP COLUMN IF EXISTS "totp_recovery_codes",
+    DROP COLUMN IF EXISTS "totp_last_step",
+    DROP COLUMN IF EXISTS "totp_enabled_at";
@@ -0,0 +1,6 @@
+-- TOTP 两步验证
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_secret" TEXT;                         -- AES-256-GCM 加密的 TOTP 共享密钥
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_enabled" BOOLEAN NOT NULL DEFAULT FALSE; -- 确认绑定后才生效
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_recovery_codes" TEXT[];               -- 恢复码 SHA-256 哈希，使用后移除
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_last_step" BIGINT NOT NULL DEFAULT 0;  -- 最近一次通过验证的时间步（防重放）
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_enabled_at" TIMESTAMPTZ;
@@ -0,0 +1,17 @@
+-- Passkey 凭证
+CREATE TABLE IF NOT EXISTS "webauthn_credentials" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "user_uid" VARCHAR(16) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    "credential_id" BYTEA NOT NULL UNIQUE,
+    "public_key" BYTEA NOT NULL, -- COSE_Key 原始字节
+    "sign_count" BIGINT NOT NULL DEFAULT 0,
+    "aaguid" BYTEA,
+    "transports" TEXT[] NOT NULL DEFAULT '{}',
+    "backup_eligible" BOOLEAN NOT NULL DEFAULT FALSE,
+    "backed_up" BOOLEAN NOT NULL DEFAULT FALSE,
+    "name" VARCHAR(64) NOT NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "last_used_at" TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_uid ON webauthn_credentials(user_uid);
@@ -0,0 +1,4 @@
+-- 会话设备信息（设备列表与单独撤销）
+ALTER TABLE "session_tokens" ADD COLUMN IF NOT EXISTS "ip" VARCHAR(45);
+ALTER TABLE "session_tokens" ADD COLUMN IF NOT EXISTS "user_agent" TEXT;
+ALTER TABLE "session_tokens" ADD COLUMN IF NOT EXISTS "last_used_at" TIMESTAMPTZ DEFAULT NOW();
@@ -0,0 +1,7 @@
+-- 回退为单个 redirect_uri，仅保留第一个回调地址
+ALTER TABLE "oauth_clients" ADD COLUMN IF NOT EXISTS "redirect_uri" TEXT NOT NULL DEFAULT '';
+UPDATE "oauth_clients" SET "redirect_uri" = COALESCE("redirect_uris"[1], '');
+ALTER TABLE "oauth_clients" ALTER COLUMN "redirect_uri" DROP DEFAULT;
+ALTER TABLE "oauth_clients"
+    DROP COLUMN IF EXISTS "redirect_uris",
+    DROP COLUMN IF EXISTS "post_logout_redirect_uris";
@@ -0,0 +1,13 @@
+-- OAuth 客户端支持多个回调地址与登出回调地址：单值 redirect_uri 迁移为数组 redirect_uris
+ALTER TABLE "oauth_clients" ADD COLUMN IF NOT EXISTS "redirect_uris" TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE "oauth_clients" ADD COLUMN IF NOT EXISTS "post_logout_redirect_uris" TEXT[] NOT NULL DEFAULT '{}';
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'oauth_clients' AND column_name = 'redirect_uri') THEN
+        UPDATE "oauth_clients" SET "redirect_uris" = ARRAY["redirect_uri"]
+        WHERE cardinality("redirect_uris") = 0 AND "redirect_uri" <> '';
+        ALTER TABLE "oauth_clients" DROP COLUMN "redirect_uri";
+    END IF;
+END $$;
@@ -0,0 +1,4 @@
+-- 不关联用户的 Token 无法满足 NOT NULL，回退前删除
+DELETE FROM "oauth_access_tokens" WHERE "user_uid" IS NULL;
+ALTER TABLE "oauth_access_tokens" ALTER COLUMN "user_uid" SET NOT NULL;
+ALTER TABLE "oauth_clients" DROP COLUMN IF EXISTS "client_scopes";
@@ -0,0 +1,3 @@
+-- client_credentials 授权：客户端自身可申请的 scope；签发的 Token 不关联用户
+ALTER TABLE "oauth_clients" ADD COLUMN IF NOT EXISTS "client_scopes" TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE "oauth_access_tokens" ALTER COLUMN "user_uid" DROP NOT NULL;
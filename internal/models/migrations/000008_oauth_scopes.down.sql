@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "oauth_scopes";
+ALTER TABLE "oauth_clients" DROP COLUMN IF EXISTS "allowed_scopes";
@@ -0,0 +1,10 @@
+-- 管理员定义的自定义 scope 与客户端可申请的 scope 列表
+ALTER TABLE "oauth_clients" ADD COLUMN IF NOT EXISTS "allowed_scopes" TEXT[] NOT NULL DEFAULT '{openid,profile,email}';
+
+CREATE TABLE IF NOT EXISTS "oauth_scopes" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "name" VARCHAR(64) NOT NULL UNIQUE,
+    "descriptions" JSONB NOT NULL DEFAULT '{}',
+    "created_at" TIMESTAMPTZ DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ DEFAULT NOW()
+);
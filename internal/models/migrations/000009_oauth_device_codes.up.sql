@@ -0,0 +1,17 @@
+-- 设备授权请求（RFC 8628）
+CREATE TABLE IF NOT EXISTS "oauth_device_codes" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "device_code_hash" VARCHAR(64) NOT NULL UNIQUE,
+    "user_code_hash" VARCHAR(64) NOT NULL UNIQUE,
+    "client_id" VARCHAR(64) NOT NULL,
+    "scope" VARCHAR(255) NOT NULL,
+    "status" VARCHAR(16) NOT NULL,
+    "user_uid" VARCHAR(16) REFERENCES users(uid) ON DELETE CASCADE,
+    "poll_interval" INTEGER NOT NULL,
+    "last_polled_at" TIMESTAMPTZ,
+    "expires_at" TIMESTAMPTZ NOT NULL,
+    "confirmed_at" TIMESTAMPTZ,
+    "created_at" TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_device_codes_expires ON oauth_device_codes(expires_at);
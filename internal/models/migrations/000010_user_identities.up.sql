@@ -0,0 +1,14 @@
+-- 通用 OIDC 上游 IdP 绑定的外部身份
+CREATE TABLE IF NOT EXISTS "user_identities" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "user_uid" VARCHAR(16) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    "provider" VARCHAR(32) NOT NULL,
+    "subject" VARCHAR(255) NOT NULL,
+    "display_name" VARCHAR(255) NOT NULL DEFAULT '',
+    "email" VARCHAR(255) NOT NULL DEFAULT '',
+    "avatar_url" TEXT NOT NULL DEFAULT '',
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE("provider", "subject"),
+    UNIQUE("user_uid", "provider")
+);
@@ -0,0 +1,10 @@
+-- 跨实例限流状态（RATE_LIMIT_BACKEND=postgres，GCRA 理论到达时间）
+CREATE TABLE IF NOT EXISTS "rate_limits" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "bucket" VARCHAR(32) NOT NULL,
+    "limit_key" VARCHAR(255) NOT NULL,
+    "tat" TIMESTAMPTZ NOT NULL,
+    UNIQUE("bucket", "limit_key")
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limits_tat ON rate_limits(tat);
@@ -0,0 +1,12 @@
+-- 外部登录 state / 待确认绑定（OAUTH_STATE_BACKEND=postgres）
+CREATE TABLE IF NOT EXISTS "oauth_flow_states" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "kind" VARCHAR(16) NOT NULL,
+    "key_hash" VARCHAR(64) NOT NULL,
+    "payload" JSONB NOT NULL,
+    "expires_at" TIMESTAMPTZ NOT NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE("kind", "key_hash")
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_flow_states_expires ON oauth_flow_states(expires_at);
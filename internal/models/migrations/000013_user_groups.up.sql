@@ -0,0 +1,19 @@
+-- 管理员维护的用户组，组内成员获得 permissions 中列出的后台权限（与 users.role 内置权限取并集）
+CREATE TABLE IF NOT EXISTS "user_groups" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "name" VARCHAR(64) NOT NULL UNIQUE,
+    "description" VARCHAR(255) NOT NULL DEFAULT '',
+    "permissions" TEXT[] NOT NULL DEFAULT '{}',
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS "user_group_members" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "group_id" BIGINT NOT NULL REFERENCES user_groups(id) ON DELETE CASCADE,
+    "user_uid" VARCHAR(16) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE("group_id", "user_uid")
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_group_members_user_uid ON user_group_members(user_uid);
@@ -0,0 +1,2 @@
+-- 客户端的 roles / groups claim 映射：{"roles": {"admin": "editor"}, "groups": {"support": "helpdesk"}}
+ALTER TABLE "oauth_clients" ADD COLUMN IF NOT EXISTS "claim_mapping" JSONB NOT NULL DEFAULT '{}';
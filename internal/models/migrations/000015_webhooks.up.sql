@@ -0,0 +1,30 @@
+-- 管理员配置的出站 Webhook 端点：订阅 events 中的账户生命周期事件，payload 以 secret 做 HMAC-SHA256 签名
+CREATE TABLE IF NOT EXISTS "webhook_endpoints" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "url" VARCHAR(2048) NOT NULL,
+    "description" VARCHAR(255) NOT NULL DEFAULT '',
+    "secret" VARCHAR(128) NOT NULL,
+    "events" TEXT[] NOT NULL DEFAULT '{}',
+    "is_enabled" BOOLEAN NOT NULL DEFAULT TRUE,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- 投递队列兼投递日志：每个 (事件, 端点) 一行，pending 行由后台任务按 next_attempt_at 领取并重试
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "endpoint_id" BIGINT NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    "event_id" VARCHAR(64) NOT NULL,
+    "event_type" VARCHAR(64) NOT NULL,
+    "payload" JSONB NOT NULL,
+    "status" VARCHAR(16) NOT NULL DEFAULT 'pending',
+    "attempts" INT NOT NULL DEFAULT 0,
+    "next_attempt_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "last_attempt_at" TIMESTAMPTZ,
+    "response_status" INT,
+    "last_error" TEXT NOT NULL DEFAULT '',
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint_id ON webhook_deliveries(endpoint_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,22 @@
+-- 出站邮件队列兼发送日志：每封邮件渲染后入队一行，pending 行由发送任务按 next_attempt_at 领取并重试。
+-- sent 为已被后端接收，bounced 为后端永久拒收（不再重试），failed 为重试耗尽或已过期
+CREATE TABLE IF NOT EXISTS "email_messages" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "recipient" VARCHAR(255) NOT NULL,
+    "email_type" VARCHAR(64) NOT NULL,
+    "subject" VARCHAR(512) NOT NULL,
+    "html_body" TEXT NOT NULL,
+    "text_body" TEXT NOT NULL,
+    "status" VARCHAR(16) NOT NULL DEFAULT 'pending',
+    "attempts" INT NOT NULL DEFAULT 0,
+    "transport" VARCHAR(32) NOT NULL DEFAULT '',
+    "next_attempt_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "last_attempt_at" TIMESTAMPTZ,
+    "last_error" TEXT NOT NULL DEFAULT '',
+    "expires_at" TIMESTAMPTZ,
+    "sent_at" TIMESTAMPTZ,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_messages_due ON email_messages(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_email_messages_status_created ON email_messages(status, created_at);
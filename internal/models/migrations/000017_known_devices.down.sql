@@ -0,0 +1,7 @@
+ALTER TABLE "qr_login_tokens" DROP COLUMN IF EXISTS "pc_longitude";
+ALTER TABLE "qr_login_tokens" DROP COLUMN IF EXISTS "pc_latitude";
+ALTER TABLE "qr_login_tokens" DROP COLUMN IF EXISTS "pc_city";
+ALTER TABLE "qr_login_tokens" DROP COLUMN IF EXISTS "pc_country";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "password_reset_required";
+DROP TABLE IF EXISTS "login_alerts";
+DROP TABLE IF EXISTS "user_known_devices";
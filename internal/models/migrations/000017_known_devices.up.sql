@@ -0,0 +1,43 @@
+-- 用户已知登录设备：以 (IP, User-Agent) 识别一台设备，登录风险检查据此判断新设备 / 新国家 / 新浏览器 / 不可能旅行
+CREATE TABLE IF NOT EXISTS "user_known_devices" (
+    "id" BIGSERIAL NOT NULL PRIMARY KEY,
+    "user_uid" VARCHAR(16) NOT NULL REFERENCES "users"("uid") ON DELETE CASCADE,
+    "ip" VARCHAR(45) NOT NULL,
+    "user_agent" TEXT NOT NULL,
+    "browser" VARCHAR(64) NOT NULL DEFAULT '',
+    "os" VARCHAR(64) NOT NULL DEFAULT '',
+    "country" VARCHAR(2) NOT NULL DEFAULT '',
+    "city" VARCHAR(100) NOT NULL DEFAULT '',
+    "latitude" DOUBLE PRECISION,
+    "longitude" DOUBLE PRECISION,
+    "first_seen_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "last_seen_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE ("user_uid", "ip", "user_agent")
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_known_devices_user_seen ON user_known_devices(user_uid, last_seen_at DESC);
+
+-- 新设备登录提醒："不是我本人" 链接的 token（仅存 SHA-256），使用后撤销对应会话族并要求重置密码
+CREATE TABLE IF NOT EXISTS "login_alerts" (
+    "token_hash" VARCHAR(64) NOT NULL PRIMARY KEY,
+    "user_uid" VARCHAR(16) NOT NULL REFERENCES "users"("uid") ON DELETE CASCADE,
+    "family_id" VARCHAR(64) NOT NULL DEFAULT '',
+    "device_id" BIGINT NOT NULL,
+    "ip" VARCHAR(45) NOT NULL,
+    "user_agent" TEXT NOT NULL,
+    "reasons" TEXT[] NOT NULL DEFAULT '{}',
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "expires_at" TIMESTAMPTZ NOT NULL,
+    "used_at" TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_alerts_expires ON login_alerts(expires_at);
+
+-- 账户被标记为可疑登录后需先通过邮件重置密码才能再用密码登录
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "password_reset_required" BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- 扫码登录记录 PC 端的大致位置，确认时用于登录风险检查
+ALTER TABLE "qr_login_tokens" ADD COLUMN IF NOT EXISTS "pc_country" VARCHAR(2) NOT NULL DEFAULT '';
+ALTER TABLE "qr_login_tokens" ADD COLUMN IF NOT EXISTS "pc_city" VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE "qr_login_tokens" ADD COLUMN IF NOT EXISTS "pc_latitude" DOUBLE PRECISION;
+ALTER TABLE "qr_login_tokens" ADD COLUMN IF NOT EXISTS "pc_longitude" DOUBLE PRECISION;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "idx_users_unlock_token_hash";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "unlock_token_hash";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "locked_until";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "last_failed_login_at";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "failed_login_count";
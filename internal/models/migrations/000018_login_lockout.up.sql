@@ -0,0 +1,8 @@
+-- 按账户累计密码登录失败次数：失败次数增加时要求逐渐延长的等待，达到阈值后临时锁定
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "failed_login_count" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "last_failed_login_at" TIMESTAMPTZ;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "locked_until" TIMESTAMPTZ;
+-- 锁定时通过邮件发送的解锁链接 Token（SHA-256），解锁或登录成功后清除
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "unlock_token_hash" VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS "idx_users_unlock_token_hash" ON "users" ("unlock_token_hash") WHERE "unlock_token_hash" IS NOT NULL;
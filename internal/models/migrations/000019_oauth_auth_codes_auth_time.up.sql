@@ -0,0 +1,2 @@
+-- OIDC：授权码记录用户登录时间（而非授权确认时间），签发 id_token 时写入 auth_time
+ALTER TABLE "oauth_auth_codes" ADD COLUMN IF NOT EXISTS "auth_time" TIMESTAMPTZ;
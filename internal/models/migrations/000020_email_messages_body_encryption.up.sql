@@ -0,0 +1,4 @@
+-- 含一次性链接的邮件正文加密存储；已结束的邮件不再保留正文
+ALTER TABLE "email_messages" ADD COLUMN IF NOT EXISTS "body_encrypted" BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE "email_messages" SET "html_body" = '', "text_body" = '' WHERE "status" <> 'pending';
@@ -0,0 +1,20 @@
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS "microsoft_id" VARCHAR(255) UNIQUE,
+    ADD COLUMN IF NOT EXISTS "microsoft_name" VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS "google_id" VARCHAR(255) UNIQUE,
+    ADD COLUMN IF NOT EXISTS "google_name" VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS "google_avatar_url" TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_users_microsoft_id ON users(microsoft_id);
+CREATE INDEX IF NOT EXISTS idx_users_google_id ON users(google_id);
+
+UPDATE "users" u SET "microsoft_id" = i."subject", "microsoft_name" = NULLIF(i."display_name", '')
+FROM "user_identities" i
+WHERE i."user_uid" = u."uid" AND i."provider" = 'microsoft';
+
+UPDATE "users" u SET "google_id" = i."subject", "google_name" = NULLIF(i."display_name", ''),
+    "google_avatar_url" = NULLIF(i."avatar_url", '')
+FROM "user_identities" i
+WHERE i."user_uid" = u."uid" AND i."provider" = 'google';
+
+DELETE FROM "user_identities" WHERE "provider" IN ('microsoft', 'google');
@@ -0,0 +1,23 @@
+-- Microsoft / Google 绑定迁入 user_identities，移除 users 上的专用列
+-- 头像文件相关列（microsoft_avatar_url / microsoft_avatar_hash / microsoft_avatar_sync）属于本地头像存储，保留
+INSERT INTO "user_identities" ("user_uid", "provider", "subject", "display_name")
+SELECT "uid", 'microsoft', "microsoft_id", COALESCE("microsoft_name", '')
+FROM "users"
+WHERE "microsoft_id" IS NOT NULL AND "microsoft_id" <> ''
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "user_identities" ("user_uid", "provider", "subject", "display_name", "avatar_url")
+SELECT "uid", 'google', "google_id", COALESCE("google_name", ''), COALESCE("google_avatar_url", '')
+FROM "users"
+WHERE "google_id" IS NOT NULL AND "google_id" <> ''
+ON CONFLICT DO NOTHING;
+
+DROP INDEX IF EXISTS idx_users_microsoft_id;
+DROP INDEX IF EXISTS idx_users_google_id;
+
+ALTER TABLE "users"
+    DROP COLUMN IF EXISTS "microsoft_id",
+    DROP COLUMN IF EXISTS "microsoft_name",
+    DROP COLUMN IF EXISTS "google_id",
+    DROP COLUMN IF EXISTS "google_name",
+    DROP COLUMN IF EXISTS "google_avatar_url";
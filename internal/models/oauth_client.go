@@ -6,6 +6,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -18,6 +20,9 @@ var (
 	ErrOAuthClientIDExists            = errors.New("OAUTH_CLIENT_ID_EXISTS")
 	ErrOAuthClientDisabled            = errors.New("OAUTH_CLIENT_DISABLED")
 	ErrOAuthInvalidClientData         = errors.New("OAUTH_INVALID_CLIENT_DATA")
+	ErrOAuthInvalidRedirectURI        = errors.New("OAUTH_INVALID_REDIRECT_URI")
+	ErrOAuthInvalidClientScope        = errors.New("OAUTH_INVALID_CLIENT_SCOPE")
+	ErrOAuthInvalidClaimMapping       = errors.New("OAUTH_INVALID_CLAIM_MAPPING")
 	ErrOAuthClientRepoDBNotReady      = errors.New("database not ready")
 	ErrOAuthClientRepoNilClient       = errors.New("client object is nil")
 	ErrOAuthClientRepoInvalidID       = errors.New("invalid client ID")
@@ -25,41 +30,144 @@ var (
 )
 
 const (
-	oauthClientMaxUpdateFields = 5
+	oauthClientMaxUpdateFields = 7
+
+	// MaxOAuthClientRedirectURIs 每个客户端可登记的回调地址（及登出后回调地址）上限
+	MaxOAuthClientRedirectURIs = 10
+
+	// MaxOAuthClientScopes 客户端级 scope 数量上限；拼接后需能写入 oauth_access_tokens.scope（VARCHAR(255)）
+	MaxOAuthClientScopes      = 20
+	maxOAuthClientScopeLength = 64
+	maxOAuthScopeStringLength = 255
+
+	// MaxOAuthClaimMappings roles / groups 映射各自的条目上限
+	MaxOAuthClaimMappings    = 50
+	maxOAuthClaimValueLength = 64
 )
 
 // oauthClientAllowedUpdateFields 允许更新的字段白名单
 var oauthClientAllowedUpdateFields = map[string]bool{
-	"name":               true,
-	"description":        true,
-	"redirect_uri":       true,
-	"is_enabled":         true,
-	"client_secret_hash": true,
+	"name":                      true,
+	"description":               true,
+	"redirect_uris":             true,
+	"post_logout_redirect_uris": true,
+	"client_scopes":             true,
+	"allowed_scopes":            true,
+	"claim_mapping":             true,
+	"is_enabled":                true,
+	"client_secret_hash":        true,
 }
 
 // OAuthClient OAuth 客户端模型
+// RedirectURIs 为授权回调地址白名单（至少一个），PostLogoutRedirectURIs 为登出后回调地址白名单（可为空）
+// ClientScopes 为 client_credentials 授权可申请的 scope，为空表示该客户端不允许使用 client_credentials
+// AllowedScopes 为用户授权（authorization_code）时客户端可申请的 scope 白名单
+// ClaimMapping 为 roles / groups scope 下发 claim 时使用的映射
 type OAuthClient struct {
-	ID               int64     `json:"id"`
-	ClientID         string    `json:"client_id"`
-	ClientSecretHash string    `json:"-"` // 不序列化到 JSON
-	Name             string    `json:"name"`
-	Description      string    `json:"description"`
-	RedirectURI      string    `json:"redirect_uri"`
-	IsEnabled        bool      `json:"is_enabled"`
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
+	ID                     int64             `json:"id"`
+	ClientID               string            `json:"client_id"`
+	ClientSecretHash       string            `json:"-"` // 不序列化到 JSON
+	Name                   string            `json:"name"`
+	Description            string            `json:"description"`
+	RedirectURIs           []string          `json:"redirect_uris"`
+	PostLogoutRedirectURIs []string          `json:"post_logout_redirect_uris"`
+	ClientScopes           []string          `json:"client_scopes"`
+	AllowedScopes          []string          `json:"allowed_scopes"`
+	ClaimMapping           OAuthClaimMapping `json:"claim_mapping"`
+	IsEnabled              bool              `json:"is_enabled"`
+	CreatedAt              time.Time         `json:"created_at"`
+	UpdatedAt              time.Time         `json:"updated_at"`
+}
+
+// OAuthClaimMapping 客户端的 roles / groups claim 映射，key 为本系统的角色名（user/admin/super_admin）或用户组名，
+// value 为下发给该客户端的值。某类映射为空时原样下发本系统名称；非空时只下发映射中列出的项，
+// 未列出的角色与用户组不会暴露给该客户端
+type OAuthClaimMapping struct {
+	Roles  map[string]string `json:"roles,omitempty"`
+	Groups map[string]string `json:"groups,omitempty"`
+}
+
+// Validate 校验映射：条目数量上限、key 为已定义的角色名 / 合法的用户组名、value 非空且不超长
+func (m OAuthClaimMapping) Validate() error {
+	if len(m.Roles) > MaxOAuthClaimMappings || len(m.Groups) > MaxOAuthClaimMappings {
+		return fmt.Errorf("%w: too many entries", ErrOAuthInvalidClaimMapping)
+	}
+	for role, value := range m.Roles {
+		if role != RoleNameUser && role != RoleNameAdmin && role != RoleNameSuperAdmin {
+			return fmt.Errorf("%w: unknown role %q", ErrOAuthInvalidClaimMapping, role)
+		}
+		if !isOAuthClaimValue(value) {
+			return fmt.Errorf("%w: invalid value for role %q", ErrOAuthInvalidClaimMapping, role)
+		}
+	}
+	for group, value := range m.Groups {
+		if !userGroupNamePattern.MatchString(group) {
+			return fmt.Errorf("%w: invalid group name %q", ErrOAuthInvalidClaimMapping, group)
+		}
+		if !isOAuthClaimValue(value) {
+			return fmt.Errorf("%w: invalid value for group %q", ErrOAuthInvalidClaimMapping, group)
+		}
+	}
+	return nil
+}
+
+func isOAuthClaimValue(value string) bool {
+	return strings.TrimSpace(value) != "" && len([]rune(value)) <= maxOAuthClaimValueLength
+}
+
+// MapRoles 返回用户角色对应的 roles claim
+func (m OAuthClaimMapping) MapRoles(role int) []string {
+	return mapOAuthClaimValues(m.Roles, []string{RoleName(role)})
+}
+
+// MapGroups 返回用户组名对应的 groups claim（保持输入顺序，映射后的重复值只保留一个）
+func (m OAuthClaimMapping) MapGroups(groups []string) []string {
+	return mapOAuthClaimValues(m.Groups, groups)
+}
+
+func mapOAuthClaimValues(mapping map[string]string, names []string) []string {
+	values := make([]string, 0, len(names))
+	for _, name := range names {
+		value := name
+		if len(mapping) > 0 {
+			mapped, ok := mapping[name]
+			if !ok {
+				continue
+			}
+			value = mapped
+		}
+		if !slices.Contains(values, value) {
+			values = append(values, value)
+		}
+	}
+	return values
 }
 
 // OAuthClientPublic 公开的客户端信息（用于列表展示）
 type OAuthClientPublic struct {
-	ID          int64     `json:"id"`
-	ClientID    string    `json:"client_id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	RedirectURI string    `json:"redirect_uri"`
-	IsEnabled   bool      `json:"is_enabled"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID                     int64     `json:"id"`
+	ClientID               string    `json:"client_id"`
+	Name                   string    `json:"name"`
+	Description            string    `json:"description"`
+	RedirectURIs           []string  `json:"redirect_uris"`
+	PostLogoutRedirectURIs []string  `json:"post_logout_redirect_uris"`
+	ClientScopes           []string  `json:"client_scopes"`
+	AllowedScopes          []string  `json:"allowed_scopes"`
+	IsEnabled              bool      `json:"is_enabled"`
+	CreatedAt              time.Time `json:"created_at"`
+	UpdatedAt              time.Time `json:"updated_at"`
+}
+
+// oauthClientColumns 查询客户端时的统一列顺序（与 scanOAuthClient 对应）
+const oauthClientColumns = `id, client_id, client_secret_hash, name, description, redirect_uris,
+	post_logout_redirect_uris, client_scopes, allowed_scopes, claim_mapping, is_enabled, created_at, updated_at`
+
+func scanOAuthClient(row pgx.Row, client *OAuthClient) error {
+	return row.Scan(
+		&client.ID, &client.ClientID, &client.ClientSecretHash, &client.Name,
+		&client.Description, &client.RedirectURIs, &client.PostLogoutRedirectURIs,
+		&client.ClientScopes, &client.AllowedScopes, &client.ClaimMapping, &client.IsEnabled, &client.CreatedAt, &client.UpdatedAt,
+	)
 }
 
 // OAuthClientRepository OAuth 客户端仓库
@@ -74,14 +182,17 @@ func (c *OAuthClient) ToPublic() *OAuthClientPublic {
 	}
 
 	return &OAuthClientPublic{
-		ID:          c.ID,
-		ClientID:    c.ClientID,
-		Name:        c.Name,
-		Description: c.Description,
-		RedirectURI: c.RedirectURI,
-		IsEnabled:   c.IsEnabled,
-		CreatedAt:   c.CreatedAt,
-		UpdatedAt:   c.UpdatedAt,
+		ID:                     c.ID,
+		ClientID:               c.ClientID,
+		Name:                   c.Name,
+		Description:            c.Description,
+		RedirectURIs:           c.RedirectURIs,
+		PostLogoutRedirectURIs: c.PostLogoutRedirectURIs,
+		ClientScopes:           c.ClientScopes,
+		AllowedScopes:          c.AllowedScopes,
+		IsEnabled:              c.IsEnabled,
+		CreatedAt:              c.CreatedAt,
+		UpdatedAt:              c.UpdatedAt,
 	}
 }
 
@@ -96,12 +207,126 @@ func (c *OAuthClient) Validate() error {
 	if c.Name == "" {
 		return fmt.Errorf("%w: name is empty", ErrOAuthInvalidClientData)
 	}
-	if c.RedirectURI == "" {
-		return fmt.Errorf("%w: redirect_uri is empty", ErrOAuthInvalidClientData)
+	if len(c.RedirectURIs) == 0 {
+		return fmt.Errorf("%w: %w: redirect_uris is empty", ErrOAuthInvalidClientData, ErrOAuthInvalidRedirectURI)
+	}
+	if err := ValidateOAuthRedirectURIList("redirect_uris", c.RedirectURIs); err != nil {
+		return err
+	}
+	if err := ValidateOAuthRedirectURIList("post_logout_redirect_uris", c.PostLogoutRedirectURIs); err != nil {
+		return err
+	}
+	if err := ValidateOAuthClientScopes(c.ClientScopes); err != nil {
+		return err
+	}
+	if err := ValidateOAuthAllowedScopes(c.AllowedScopes); err != nil {
+		return err
+	}
+	return c.ClaimMapping.Validate()
+}
+
+// ValidateOAuthAllowedScopes 校验用户授权 scope 白名单（可包含内置 scope，不得包含仅限客户端的 scim/introspect）
+func ValidateOAuthAllowedScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if IsClientOnlyOAuthScope(scope) {
+			return fmt.Errorf("%w: %w: invalid allowed_scopes entry %q", ErrOAuthInvalidClientData, ErrOAuthInvalidClientScope, scope)
+		}
+	}
+	return validateOAuthScopeList("allowed_scopes", scopes, true)
+}
+
+// ValidateOAuthClientScopes 校验客户端级 scope 列表（client_credentials 无用户参与，不得包含内置用户 scope）
+func ValidateOAuthClientScopes(scopes []string) error {
+	return validateOAuthScopeList("client_scopes", scopes, false)
+}
+
+// validateOAuthScopeList 校验 scope 列表：数量与总长度上限、RFC 6749 3.3 的 scope-token 字符集、不得重复
+func validateOAuthScopeList(field string, scopes []string, allowBuiltin bool) error {
+	if len(scopes) > MaxOAuthClientScopes || len(strings.Join(scopes, " ")) > maxOAuthScopeStringLength {
+		return fmt.Errorf("%w: %w: too many %s", ErrOAuthInvalidClientData, ErrOAuthInvalidClientScope, field)
+	}
+
+	seen := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		if !isOAuthScopeToken(scope) || (!allowBuiltin && IsBuiltinOAuthScope(scope)) {
+			return fmt.Errorf("%w: %w: invalid %s entry %q", ErrOAuthInvalidClientData, ErrOAuthInvalidClientScope, field, scope)
+		}
+		if seen[scope] {
+			return fmt.Errorf("%w: %w: duplicate %s entry %q", ErrOAuthInvalidClientData, ErrOAuthInvalidClientScope, field, scope)
+		}
+		seen[scope] = true
+	}
+	return nil
+}
+
+// isOAuthScopeToken scope-token = 1*( %x21 / %x23-5B / %x5D-7E )
+func isOAuthScopeToken(scope string) bool {
+	if scope == "" || len(scope) > maxOAuthClientScopeLength {
+		return false
+	}
+	for i := 0; i < len(scope); i++ {
+		ch := scope[i]
+		if ch < 0x21 || ch > 0x7e || ch == '"' || ch == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateOAuthRedirectURIList 校验回调地址列表：数量上限、逐个校验 scheme、不允许重复
+func ValidateOAuthRedirectURIList(field string, uris []string) error {
+	if len(uris) > MaxOAuthClientRedirectURIs {
+		return fmt.Errorf("%w: %w: too many %s", ErrOAuthInvalidClientData, ErrOAuthInvalidRedirectURI, field)
+	}
+
+	seen := make(map[string]bool, len(uris))
+	for _, uri := range uris {
+		if err := ValidateOAuthRedirectURI(uri); err != nil {
+			return fmt.Errorf("%w: %w: invalid %s entry", ErrOAuthInvalidClientData, err, field)
+		}
+		if seen[uri] {
+			return fmt.Errorf("%w: %w: duplicate %s entry", ErrOAuthInvalidClientData, ErrOAuthInvalidRedirectURI, field)
+		}
+		seen[uri] = true
 	}
 	return nil
 }
 
+// ValidateOAuthRedirectURI 校验单个回调地址的安全性
+// 仅允许 http/https，http 仅允许 localhost/127.0.0.1/::1（开发环境与原生应用回环回调，RFC 8252）；
+// 防止 javascript:/data: 等危险 scheme 被注册为回调地址，且不得包含 # 锚点（RFC 6749 3.1.2）
+func ValidateOAuthRedirectURI(redirectURI string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil || u.Fragment != "" {
+		return ErrOAuthInvalidRedirectURI
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+
+	switch scheme {
+	case "https":
+		if host == "" {
+			return ErrOAuthInvalidRedirectURI
+		}
+	case "http":
+		if !IsLoopbackHost(host) {
+			return ErrOAuthInvalidRedirectURI
+		}
+	default:
+		// 拒绝 javascript:/data:/file:/blob: 等所有非 http(s) scheme
+		return ErrOAuthInvalidRedirectURI
+	}
+
+	return nil
+}
+
+// IsLoopbackHost 判断主机名是否为本地回环地址（localhost / 127.0.0.1 / ::1）
+func IsLoopbackHost(host string) bool {
+	host = strings.ToLower(host)
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
 // NewOAuthClientRepository 创建 OAuth 客户端仓库
 func NewOAuthClientRepository(pool *pgxpool.Pool) *OAuthClientRepository {
 	return &OAuthClientRepository{pool: pool}
@@ -120,15 +345,10 @@ func (r *OAuthClientRepository) FindByID(ctx context.Context, id int64) (*OAuthC
 	}
 
 	client := &OAuthClient{}
-	err := r.pool.QueryRow(ctx, `
-		SELECT id, client_id, client_secret_hash, name, description, redirect_uri,
-		       is_enabled, created_at, updated_at
+	err := scanOAuthClient(r.pool.QueryRow(ctx, `
+		SELECT `+oauthClientColumns+`
 		FROM oauth_clients WHERE id = $1
-	`, id).Scan(
-		&client.ID, &client.ClientID, &client.ClientSecretHash, &client.Name,
-		&client.Description, &client.RedirectURI, &client.IsEnabled,
-		&client.CreatedAt, &client.UpdatedAt,
-	)
+	`, id), client)
 
 	if err != nil {
 		return nil, r.handleQueryError(err, "FindByID", id)
@@ -150,15 +370,10 @@ func (r *OAuthClientRepository) FindByClientID(ctx context.Context, clientID str
 	}
 
 	client := &OAuthClient{}
-	err := r.pool.QueryRow(ctx, `
-		SELECT id, client_id, client_secret_hash, name, description, redirect_uri,
-		       is_enabled, created_at, updated_at
+	err := scanOAuthClient(r.pool.QueryRow(ctx, `
+		SELECT `+oauthClientColumns+`
 		FROM oauth_clients WHERE client_id = $1
-	`, clientID).Scan(
-		&client.ID, &client.ClientID, &client.ClientSecretHash, &client.Name,
-		&client.Description, &client.RedirectURI, &client.IsEnabled,
-		&client.CreatedAt, &client.UpdatedAt,
-	)
+	`, clientID), client)
 
 	if err != nil {
 		return nil, r.handleQueryError(err, "FindByClientID", clientID)
@@ -188,8 +403,7 @@ func (r *OAuthClientRepository) FindAll(ctx context.Context, page, pageSize int,
 		}
 
 		rows, err = r.pool.Query(ctx, `
-			SELECT id, client_id, client_secret_hash, name, description, redirect_uri,
-			       is_enabled, created_at, updated_at
+			SELECT `+oauthClientColumns+`
 			FROM oauth_clients
 			ORDER BY id DESC
 			LIMIT $1 OFFSET $2
@@ -208,8 +422,7 @@ func (r *OAuthClientRepository) FindAll(ctx context.Context, page, pageSize int,
 		}
 
 		rows, err = r.pool.Query(ctx, `
-			SELECT id, client_id, client_secret_hash, name, description, redirect_uri,
-			       is_enabled, created_at, updated_at
+			SELECT `+oauthClientColumns+`
 			FROM oauth_clients
 			WHERE name ILIKE $1 OR description ILIKE $1 OR client_id ILIKE $1
 			ORDER BY id DESC
@@ -226,12 +439,7 @@ func (r *OAuthClientRepository) FindAll(ctx context.Context, page, pageSize int,
 	clients := make([]*OAuthClient, 0)
 	for rows.Next() {
 		client := &OAuthClient{}
-		err := rows.Scan(
-			&client.ID, &client.ClientID, &client.ClientSecretHash, &client.Name,
-			&client.Description, &client.RedirectURI, &client.IsEnabled,
-			&client.CreatedAt, &client.UpdatedAt,
-		)
-		if err != nil {
+		if err := scanOAuthClient(rows, client); err != nil {
 			// 扫描失败属于编程错误（列序/类型不匹配），静默丢行会以部分数据伪装成功
 			return nil, 0, fmt.Errorf("failed to scan client: %w", err)
 		}
@@ -256,6 +464,15 @@ func (r *OAuthClientRepository) Create(ctx context.Context, client *OAuthClient)
 	if err := client.Validate(); err != nil {
 		return err
 	}
+	if client.PostLogoutRedirectURIs == nil {
+		client.PostLogoutRedirectURIs = []string{}
+	}
+	if client.ClientScopes == nil {
+		client.ClientScopes = []string{}
+	}
+	if client.AllowedScopes == nil {
+		client.AllowedScopes = []string{}
+	}
 
 	// 检查数据库连接
 	if err := r.checkDB(); err != nil {
@@ -264,11 +481,11 @@ func (r *OAuthClientRepository) Create(ctx context.Context, client *OAuthClient)
 
 	// 执行插入
 	err := r.pool.QueryRow(ctx, `
-		INSERT INTO oauth_clients (client_id, client_secret_hash, name, description, redirect_uri, is_enabled)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO oauth_clients (client_id, client_secret_hash, name, description, redirect_uris, post_logout_redirect_uris, client_scopes, allowed_scopes, claim_mapping, is_enabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		RETURNING id, created_at, updated_at
 	`, client.ClientID, client.ClientSecretHash, client.Name, client.Description,
-		client.RedirectURI, client.IsEnabled).Scan(
+		client.RedirectURIs, client.PostLogoutRedirectURIs, client.ClientScopes, client.AllowedScopes, client.ClaimMapping, client.IsEnabled).Scan(
 		&client.ID, &client.CreatedAt, &client.UpdatedAt,
 	)
 
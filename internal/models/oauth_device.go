@@ -0,0 +1,223 @@
+package models
+
+import (
+	"auth-system/internal/utils"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrOAuthDeviceCodeNotFound = errors.New("OAUTH_DEVICE_CODE_NOT_FOUND")
+	ErrOAuthUserCodeConflict   = errors.New("OAUTH_USER_CODE_CONFLICT")
+)
+
+// OAuthDeviceCode 设备授权请求（RFC 8628）
+// 状态沿用扫码登录的 pending → confirmed / cancelled 模型：
+// 设备端凭 device_code 轮询，用户在浏览器或手机上输入 user_code 后确认或拒绝
+type OAuthDeviceCode struct {
+	ID             int64      `json:"id"`
+	DeviceCode     string     `json:"-"` // 明文 device_code，仅业务层使用
+	DeviceCodeHash string     `json:"-"` // device_code 的 SHA-256 hash，写入 DB
+	UserCode       string     `json:"-"` // 明文 user_code，仅业务层使用
+	UserCodeHash   string     `json:"-"` // 规范化后 user_code 的 SHA-256 hash，写入 DB
+	ClientID       string     `json:"client_id"`
+	Scope          string     `json:"scope"`
+	Status         string     `json:"status"` // QRStatusPending / QRStatusConfirmed / QRStatusCancelled
+	UserUID        string     `json:"user_uid,omitempty"`
+	PollInterval   int        `json:"poll_interval"` // 当前最小轮询间隔（秒），slow_down 时递增
+	LastPolledAt   *time.Time `json:"last_polled_at,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	ConfirmedAt    *time.Time `json:"confirmed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// IsExpired 检查设备授权请求是否已过期
+func (d *OAuthDeviceCode) IsExpired() bool {
+	return d != nil && time.Now().After(d.ExpiresAt)
+}
+
+// OAuthDeviceCodeRepository 设备授权请求仓库
+type OAuthDeviceCodeRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewOAuthDeviceCodeRepository 创建设备授权请求仓库
+func NewOAuthDeviceCodeRepository(pool *pgxpool.Pool) *OAuthDeviceCodeRepository {
+	return &OAuthDeviceCodeRepository{pool: pool}
+}
+
+const oauthDeviceCodeColumns = `id, device_code_hash, user_code_hash, client_id, scope, status, COALESCE(user_uid, ''),
+	poll_interval, last_polled_at, expires_at, confirmed_at, created_at`
+
+func scanOAuthDeviceCode(row pgx.Row, d *OAuthDeviceCode) error {
+	return row.Scan(&d.ID, &d.DeviceCodeHash, &d.UserCodeHash, &d.ClientID, &d.Scope, &d.Status, &d.UserUID,
+		&d.PollInterval, &d.LastPolledAt, &d.ExpiresAt, &d.ConfirmedAt, &d.CreatedAt)
+}
+
+func (r *OAuthDeviceCodeRepository) checkDB() error {
+	if r.pool == nil {
+		return ErrDBNotInitialized
+	}
+	return nil
+}
+
+// Create 创建设备授权请求
+// user_code 熵较低，与未过期记录冲突时返回 ErrOAuthUserCodeConflict，由调用方重新生成
+func (r *OAuthDeviceCodeRepository) Create(ctx context.Context, d *OAuthDeviceCode) error {
+	if d == nil || d.DeviceCodeHash == "" || d.UserCodeHash == "" {
+		return errors.New("invalid device code")
+	}
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+	if d.Status == "" {
+		d.Status = QRStatusPending
+	}
+
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO oauth_device_codes (device_code_hash, user_code_hash, client_id, scope, status, poll_interval, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, d.DeviceCodeHash, d.UserCodeHash, d.ClientID, d.Scope, d.Status, d.PollInterval, d.ExpiresAt).Scan(&d.ID, &d.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrOAuthUserCodeConflict
+		}
+		return utils.LogError("OAUTH_DEVICE", "Create", err, "client_id", d.ClientID)
+	}
+
+	utils.LogInfo("OAUTH_DEVICE", "Device code created", "id", d.ID, "client_id", d.ClientID)
+	return nil
+}
+
+// FindByDeviceCode 根据 device_code hash 查找
+func (r *OAuthDeviceCodeRepository) FindByDeviceCode(ctx context.Context, deviceCodeHash string) (*OAuthDeviceCode, error) {
+	return r.findBy(ctx, "FindByDeviceCode", "device_code_hash", deviceCodeHash)
+}
+
+// FindByUserCode 根据规范化 user_code 的 hash 查找
+func (r *OAuthDeviceCodeRepository) FindByUserCode(ctx context.Context, userCodeHash string) (*OAuthDeviceCode, error) {
+	return r.findBy(ctx, "FindByUserCode", "user_code_hash", userCodeHash)
+}
+
+func (r *OAuthDeviceCodeRepository) findBy(ctx context.Context, op, column, hash string) (*OAuthDeviceCode, error) {
+	if hash == "" {
+		return nil, ErrOAuthDeviceCodeNotFound
+	}
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	d := &OAuthDeviceCode{}
+	err := scanOAuthDeviceCode(r.pool.QueryRow(ctx, `SELECT `+oauthDeviceCodeColumns+` FROM oauth_device_codes WHERE `+column+` = $1`, hash), d)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOAuthDeviceCodeNotFound
+		}
+		return nil, utils.LogError("OAUTH_DEVICE", op, err, column, utils.TruncateIdentifier(hash))
+	}
+	return d, nil
+}
+
+// ConfirmWithCondition 原子地将未过期的 pending 请求标记为 confirmed 并绑定用户
+// 返回 false 表示请求已被处理或已过期
+func (r *OAuthDeviceCodeRepository) ConfirmWithCondition(ctx context.Context, id int64, userUID string) (bool, error) {
+	if err := r.checkDB(); err != nil {
+		return false, err
+	}
+
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE oauth_device_codes
+		SET status = $1, user_uid = $2, confirmed_at = NOW()
+		WHERE id = $3 AND status = $4 AND expires_at > NOW()
+	`, QRStatusConfirmed, userUID, id, QRStatusPending)
+	if err != nil {
+		return false, utils.LogError("OAUTH_DEVICE", "ConfirmWithCondition", err, "id", id)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// CancelWithCondition 原子地将未过期的 pending 请求标记为 cancelled（用户拒绝授权）
+func (r *OAuthDeviceCodeRepository) CancelWithCondition(ctx context.Context, id int64) (bool, error) {
+	if err := r.checkDB(); err != nil {
+		return false, err
+	}
+
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE oauth_device_codes SET status = $1
+		WHERE id = $2 AND status = $3 AND expires_at > NOW()
+	`, QRStatusCancelled, id, QRStatusPending)
+	if err != nil {
+		return false, utils.LogError("OAUTH_DEVICE", "CancelWithCondition", err, "id", id)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// RecordPoll 记录一次轮询并返回当前轮询间隔（秒）
+// 距上次轮询不足 poll_interval 时在同一语句中将间隔增加 step（RFC 8628 3.5 slow_down）
+func (r *OAuthDeviceCodeRepository) RecordPoll(ctx context.Context, id int64, step int) (int, error) {
+	if err := r.checkDB(); err != nil {
+		return 0, err
+	}
+
+	var interval int
+	err := r.pool.QueryRow(ctx, `
+		UPDATE oauth_device_codes
+		SET poll_interval = CASE
+		        WHEN last_polled_at IS NOT NULL AND last_polled_at > NOW() - make_interval(secs => poll_interval)
+		        THEN poll_interval + $2
+		        ELSE poll_interval
+		    END,
+		    last_polled_at = NOW()
+		WHERE id = $1
+		RETURNING poll_interval
+	`, id, step).Scan(&interval)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrOAuthDeviceCodeNotFound
+		}
+		return 0, utils.LogError("OAUTH_DEVICE", "RecordPoll", err, "id", id)
+	}
+	return interval, nil
+}
+
+// Consume 删除已确认的请求（device_code 一次性使用，防止并发轮询重复换取 Token）
+// 已被消费时返回 ErrOAuthDeviceCodeNotFound
+func (r *OAuthDeviceCodeRepository) Consume(ctx context.Context, id int64) error {
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+
+	tag, err := r.pool.Exec(ctx, `DELETE FROM oauth_device_codes WHERE id = $1 AND status = $2`, id, QRStatusConfirmed)
+	if err != nil {
+		return utils.LogError("OAUTH_DEVICE", "Consume", err, "id", id)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrOAuthDeviceCodeNotFound
+	}
+	return nil
+}
+
+// DeleteExpired 删除过期的设备授权请求
+func (r *OAuthDeviceCodeRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	if err := r.checkDB(); err != nil {
+		return 0, err
+	}
+
+	tag, err := r.pool.Exec(ctx, `DELETE FROM oauth_device_codes WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, utils.LogError("OAUTH_DEVICE", "DeleteExpired", err)
+	}
+
+	count := tag.RowsAffected()
+	if count > 0 {
+		utils.LogInfo("OAUTH_DEVICE", "Deleted expired device codes", "count", count)
+	}
+	return count, nil
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"auth-system/internal/utils"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrOAuthFlowStateNotFound = errors.New("OAUTH_FLOW_STATE_NOT_FOUND")
+
+// OAuthFlowStateRepository 外部登录流程的短期状态（OAuth state、待确认绑定）数据访问层
+//
+// 多实例部署时回调可能落在与发起授权不同的实例上，状态需跨实例共享。
+// 每行以 (kind, key_hash) 唯一标识，key 只存 SHA-256 哈希；payload 为调用方序列化的 JSON。
+// 过期行在读取时即视为不存在，由后台任务定期删除。
+type OAuthFlowStateRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewOAuthFlowStateRepository 创建流程状态仓库
+func NewOAuthFlowStateRepository(pool *pgxpool.Pool) *OAuthFlowStateRepository {
+	return &OAuthFlowStateRepository{pool: pool}
+}
+
+func (r *OAuthFlowStateRepository) checkDB() error {
+	if r.pool == nil {
+		return ErrDBNotInitialized
+	}
+	return nil
+}
+
+// Save 保存状态，ttl 后过期；同一 key 重复保存时覆盖
+func (r *OAuthFlowStateRepository) Save(ctx context.Context, kind, key string, payload []byte, ttl time.Duration) error {
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO oauth_flow_states (kind, key_hash, payload, expires_at)
+		VALUES ($1, $2, $3, NOW() + $4::bigint * INTERVAL '1 millisecond')
+		ON CONFLICT (kind, key_hash) DO UPDATE SET payload = EXCLUDED.payload, expires_at = EXCLUDED.expires_at`,
+		kind, utils.HashToken(key), payload, ttl.Milliseconds(),
+	)
+	if err != nil {
+		return utils.LogError("DATABASE", "SaveOAuthFlowState", err, "kind", kind)
+	}
+	return nil
+}
+
+// Get 读取未过期的状态，不存在或已过期返回 ErrOAuthFlowStateNotFound
+func (r *OAuthFlowStateRepository) Get(ctx context.Context, kind, key string) ([]byte, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	var payload []byte
+	err := r.pool.QueryRow(ctx,
+		`SELECT payload FROM oauth_flow_states WHERE kind = $1 AND key_hash = $2 AND expires_at > NOW()`,
+		kind, utils.HashToken(key),
+	).Scan(&payload)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrOAuthFlowStateNotFound
+	}
+	if err != nil {
+		return nil, utils.LogError("DATABASE", "GetOAuthFlowState", err, "kind", kind)
+	}
+	return payload, nil
+}
+
+// GetAndDelete 原子地读取并删除状态（DELETE ... RETURNING），并发消费时只有一方成功
+func (r *OAuthFlowStateRepository) GetAndDelete(ctx context.Context, kind, key string) ([]byte, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	var payload []byte
+	var expired bool
+	err := r.pool.QueryRow(ctx,
+		`DELETE FROM oauth_flow_states WHERE kind = $1 AND key_hash = $2 RETURNING payload, expires_at <= NOW()`,
+		kind, utils.HashToken(key),
+	).Scan(&payload, &expired)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrOAuthFlowStateNotFound
+	}
+	if err != nil {
+		return nil, utils.LogError("DATABASE", "GetAndDeleteOAuthFlowState", err, "kind", kind)
+	}
+	// 已过期的行同样删除，但按不存在处理
+	if expired {
+		return nil, ErrOAuthFlowStateNotFound
+	}
+	return payload, nil
+}
+
+// Delete 删除状态，不存在时不报错
+func (r *OAuthFlowStateRepository) Delete(ctx context.Context, kind, key string) error {
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+
+	_, err := r.pool.Exec(ctx, `DELETE FROM oauth_flow_states WHERE kind = $1 AND key_hash = $2`, kind, utils.HashToken(key))
+	if err != nil {
+		return utils.LogError("DATABASE", "DeleteOAuthFlowState", err, "kind", kind)
+	}
+	return nil
+}
+
+// DeleteExpired 删除全部过期状态，返回删除数量
+func (r *OAuthFlowStateRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	if err := r.checkDB(); err != nil {
+		return 0, err
+	}
+
+	tag, err := r.pool.Exec(ctx, `DELETE FROM oauth_flow_states WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, utils.LogError("DATABASE", "DeleteExpiredOAuthFlowStates", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,298 @@
+package models
+
+import (
+	"auth-system/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrOAuthScopeNotFound    = errors.New("OAUTH_SCOPE_NOT_FOUND")
+	ErrOAuthScopeExists      = errors.New("OAUTH_SCOPE_EXISTS")
+	ErrOAuthScopeInvalidName = errors.New("OAUTH_SCOPE_INVALID_NAME")
+	ErrOAuthScopeInvalidDesc = errors.New("OAUTH_SCOPE_INVALID_DESCRIPTION")
+)
+
+const (
+	// maxOAuthScopeDescriptionLength 单语言描述的最大长度（字符）
+	maxOAuthScopeDescriptionLength = 200
+)
+
+// 内置 scope（对应用户信息字段，描述由前端翻译文件提供，不存入数据库）
+const (
+	OAuthScopeOpenID  = "openid"
+	OAuthScopeProfile = "profile"
+	OAuthScopeEmail   = "email"
+	OAuthScopeRoles   = "roles"
+	OAuthScopeGroups  = "groups"
+)
+
+// OAuthScopeSCIM 系统定义的客户端级 scope：client_credentials Token 带有该 scope 时可调用 /scim/v2 供应用户。
+// 无需在 oauth_scopes 中定义，只能写入 client_scopes（需要 users.provision 权限），不能用于用户授权
+const OAuthScopeSCIM = "scim"
+
+// OAuthScopeIntrospect 系统定义的客户端级 scope：客户端的 client_scopes 包含该 scope 时视为资源服务器，
+// 可内省其他客户端的 Access Token（RFC 7662 4 节）；未包含时只能内省签发给自己的 Token。
+// 无需在 oauth_scopes 中定义，只能写入 client_scopes，不能用于用户授权
+const OAuthScopeIntrospect = "introspect"
+
+// BuiltinOAuthScopes 全部内置 scope
+var BuiltinOAuthScopes = []string{OAuthScopeOpenID, OAuthScopeProfile, OAuthScopeEmail, OAuthScopeRoles, OAuthScopeGroups}
+
+// DefaultOAuthAllowedScopes 新建客户端未指定 allowed_scopes 时的默认白名单
+// roles / groups 会向客户端暴露用户的后台角色与用户组，需管理员显式加入白名单
+var DefaultOAuthAllowedScopes = []string{OAuthScopeOpenID, OAuthScopeProfile, OAuthScopeEmail}
+
+// OAuthScopeLanguages 自定义 scope 描述支持的语言（与前端 i18n 一致）
+var OAuthScopeLanguages = []string{"zh-CN", "zh-TW", "en", "ja", "ko"}
+
+// OAuthScope 管理员定义的自定义 scope（如 files:read）
+// Descriptions 为 语言 → 描述，展示在授权同意页
+type OAuthScope struct {
+	ID           int64             `json:"id"`
+	Name         string            `json:"name"`
+	Descriptions map[string]string `json:"descriptions"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// IsBuiltinOAuthScope 判断是否为内置 scope（openid/profile/email/roles/groups）
+func IsBuiltinOAuthScope(name string) bool {
+	return slices.Contains(BuiltinOAuthScopes, name)
+}
+
+// ValidateOAuthScopeName 校验自定义 scope 名称：符合 scope-token 字符集且不与内置 scope、scim、introspect 冲突
+func ValidateOAuthScopeName(name string) error {
+	if !isOAuthScopeToken(name) || IsBuiltinOAuthScope(name) || IsClientOnlyOAuthScope(name) {
+		return ErrOAuthScopeInvalidName
+	}
+	return nil
+}
+
+// IsClientOnlyOAuthScope 判断是否为仅限 client_scopes 的系统 scope（scim/introspect）
+func IsClientOnlyOAuthScope(name string) bool {
+	return name == OAuthScopeSCIM || name == OAuthScopeIntrospect
+}
+
+// ValidateOAuthScopeDescriptions 校验多语言描述：语言须受支持，描述非空且不超长
+func ValidateOAuthScopeDescriptions(descriptions map[string]string) error {
+	for lang, desc := range descriptions {
+		if !slices.Contains(OAuthScopeLanguages, lang) {
+			return fmt.Errorf("%w: unsupported language %q", ErrOAuthScopeInvalidDesc, lang)
+		}
+		if desc == "" || len([]rune(desc)) > maxOAuthScopeDescriptionLength {
+			return fmt.Errorf("%w: invalid description for %q", ErrOAuthScopeInvalidDesc, lang)
+		}
+	}
+	return nil
+}
+
+// Description 返回指定语言的描述，缺失时依次回退到 en、zh-CN
+func (s *OAuthScope) Description(lang string) string {
+	for _, l := range []string{lang, "en", "zh-CN"} {
+		if desc := s.Descriptions[l]; desc != "" {
+			return desc
+		}
+	}
+	return ""
+}
+
+// OAuthScopeRepository 自定义 scope 仓库
+type OAuthScopeRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewOAuthScopeRepository 创建自定义 scope 仓库
+func NewOAuthScopeRepository(pool *pgxpool.Pool) *OAuthScopeRepository {
+	return &OAuthScopeRepository{pool: pool}
+}
+
+const oauthScopeColumns = `id, name, descriptions, created_at, updated_at`
+
+func scanOAuthScope(row pgx.Row, scope *OAuthScope) error {
+	return row.Scan(&scope.ID, &scope.Name, &scope.Descriptions, &scope.CreatedAt, &scope.UpdatedAt)
+}
+
+func (r *OAuthScopeRepository) checkDB() error {
+	if r.pool == nil {
+		return ErrDBNotInitialized
+	}
+	return nil
+}
+
+// FindAll 获取全部自定义 scope（按名称排序）
+func (r *OAuthScopeRepository) FindAll(ctx context.Context) ([]*OAuthScope, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.pool.Query(ctx, `SELECT `+oauthScopeColumns+` FROM oauth_scopes ORDER BY name ASC`)
+	if err != nil {
+		return nil, utils.LogError("OAUTH_SCOPE", "FindAll", err)
+	}
+	defer rows.Close()
+
+	scopes := make([]*OAuthScope, 0)
+	for rows.Next() {
+		scope := &OAuthScope{}
+		if err := scanOAuthScope(rows, scope); err != nil {
+			return nil, utils.LogError("OAUTH_SCOPE", "FindAll", err)
+		}
+		scopes = append(scopes, scope)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.LogError("OAUTH_SCOPE", "FindAll", err)
+	}
+	return scopes, nil
+}
+
+// FindByNames 批量查询自定义 scope，不存在的名称直接忽略
+func (r *OAuthScopeRepository) FindByNames(ctx context.Context, names []string) ([]*OAuthScope, error) {
+	if len(names) == 0 {
+		return []*OAuthScope{}, nil
+	}
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.pool.Query(ctx, `SELECT `+oauthScopeColumns+` FROM oauth_scopes WHERE name = ANY($1)`, names)
+	if err != nil {
+		return nil, utils.LogError("OAUTH_SCOPE", "FindByNames", err)
+	}
+	defer rows.Close()
+
+	scopes := make([]*OAuthScope, 0, len(names))
+	for rows.Next() {
+		scope := &OAuthScope{}
+		if err := scanOAuthScope(rows, scope); err != nil {
+			return nil, utils.LogError("OAUTH_SCOPE", "FindByNames", err)
+		}
+		scopes = append(scopes, scope)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.LogError("OAUTH_SCOPE", "FindByNames", err)
+	}
+	return scopes, nil
+}
+
+// FindByID 按 ID 查询
+func (r *OAuthScopeRepository) FindByID(ctx context.Context, id int64) (*OAuthScope, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	scope := &OAuthScope{}
+	err := scanOAuthScope(r.pool.QueryRow(ctx, `SELECT `+oauthScopeColumns+` FROM oauth_scopes WHERE id = $1`, id), scope)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOAuthScopeNotFound
+		}
+		return nil, utils.LogError("OAUTH_SCOPE", "FindByID", err, "id", id)
+	}
+	return scope, nil
+}
+
+// Create 创建自定义 scope
+func (r *OAuthScopeRepository) Create(ctx context.Context, scope *OAuthScope) error {
+	if err := ValidateOAuthScopeName(scope.Name); err != nil {
+		return err
+	}
+	if err := ValidateOAuthScopeDescriptions(scope.Descriptions); err != nil {
+		return err
+	}
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+	if scope.Descriptions == nil {
+		scope.Descriptions = map[string]string{}
+	}
+
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO oauth_scopes (name, descriptions)
+		VALUES ($1, $2)
+		RETURNING id, created_at, updated_at
+	`, scope.Name, scope.Descriptions).Scan(&scope.ID, &scope.CreatedAt, &scope.UpdatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrOAuthScopeExists
+		}
+		return utils.LogError("OAUTH_SCOPE", "Create", err, "name", scope.Name)
+	}
+
+	utils.LogInfo("OAUTH_SCOPE", "Scope created", "id", scope.ID, "name", scope.Name)
+	return nil
+}
+
+// UpdateDescriptions 更新描述（scope 名称创建后不可修改，避免已签发 Token 的 scope 含义变化）
+func (r *OAuthScopeRepository) UpdateDescriptions(ctx context.Context, id int64, descriptions map[string]string) (*OAuthScope, error) {
+	if err := ValidateOAuthScopeDescriptions(descriptions); err != nil {
+		return nil, err
+	}
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+	if descriptions == nil {
+		descriptions = map[string]string{}
+	}
+
+	scope := &OAuthScope{}
+	err := scanOAuthScope(r.pool.QueryRow(ctx, `
+		UPDATE oauth_scopes SET descriptions = $1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING `+oauthScopeColumns, descriptions, id), scope)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOAuthScopeNotFound
+		}
+		return nil, utils.LogError("OAUTH_SCOPE", "UpdateDescriptions", err, "id", id)
+	}
+	return scope, nil
+}
+
+// Delete 删除自定义 scope，并在同一事务中从所有客户端的 allowed_scopes / client_scopes 中移除
+// 已签发 Token 的 scope 字符串不受影响，随 Token 过期自然失效
+func (r *OAuthScopeRepository) Delete(ctx context.Context, id int64) (*OAuthScope, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, utils.LogError("OAUTH_SCOPE", "Delete", err, "id", id)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	scope := &OAuthScope{}
+	err = scanOAuthScope(tx.QueryRow(ctx, `DELETE FROM oauth_scopes WHERE id = $1 RETURNING `+oauthScopeColumns, id), scope)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOAuthScopeNotFound
+		}
+		return nil, utils.LogError("OAUTH_SCOPE", "Delete", err, "id", id)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE oauth_clients
+		SET allowed_scopes = array_remove(allowed_scopes, $1),
+		    client_scopes = array_remove(client_scopes, $1),
+		    updated_at = NOW()
+		WHERE $1 = ANY(allowed_scopes) OR $1 = ANY(client_scopes)
+	`, scope.Name); err != nil {
+		return nil, utils.LogError("OAUTH_SCOPE", "Delete", err, "name", scope.Name)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, utils.LogError("OAUTH_SCOPE", "Delete", err, "id", id)
+	}
+
+	utils.LogInfo("OAUTH_SCOPE", "Scope deleted", "id", id, "name", scope.Name)
+	return scope, nil
+}
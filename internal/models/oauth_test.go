@@ -2,6 +2,9 @@ package models
 
 import (
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -13,10 +16,19 @@ func TestOAuthClientValidate(t *testing.T) {
 		wantErr error
 	}{
 		{"nil client", nil, ErrOAuthClientRepoNilClient},
-		{"empty client id", &OAuthClient{Name: "app", RedirectURI: "https://a.com/cb"}, ErrOAuthInvalidClientData},
-		{"empty name", &OAuthClient{ClientID: "abc", RedirectURI: "https://a.com/cb"}, ErrOAuthInvalidClientData},
+		{"empty client id", &OAuthClient{Name: "app", RedirectURIs: []string{"https://a.com/cb"}}, ErrOAuthInvalidClientData},
+		{"empty name", &OAuthClient{ClientID: "abc", RedirectURIs: []string{"https://a.com/cb"}}, ErrOAuthInvalidClientData},
 		{"empty redirect uri", &OAuthClient{ClientID: "abc", Name: "app"}, ErrOAuthInvalidClientData},
-		{"valid", &OAuthClient{ClientID: "abc", Name: "app", RedirectURI: "https://a.com/cb"}, nil},
+		{"insecure redirect uri", &OAuthClient{ClientID: "abc", Name: "app", RedirectURIs: []string{"http://a.com/cb"}}, ErrOAuthInvalidRedirectURI},
+		{"duplicate redirect uri", &OAuthClient{ClientID: "abc", Name: "app", RedirectURIs: []string{"https://a.com/cb", "https://a.com/cb"}}, ErrOAuthInvalidRedirectURI},
+		{"too many redirect uris", &OAuthClient{ClientID: "abc", Name: "app", RedirectURIs: manyRedirectURIs(MaxOAuthClientRedirectURIs + 1)}, ErrOAuthInvalidRedirectURI},
+		{"invalid post logout uri", &OAuthClient{ClientID: "abc", Name: "app", RedirectURIs: []string{"https://a.com/cb"}, PostLogoutRedirectURIs: []string{"javascript:alert(1)"}}, ErrOAuthInvalidRedirectURI},
+		{"user scope as client scope", &OAuthClient{ClientID: "abc", Name: "app", RedirectURIs: []string{"https://a.com/cb"}, ClientScopes: []string{"openid"}}, ErrOAuthInvalidClientScope},
+		{"malformed client scope", &OAuthClient{ClientID: "abc", Name: "app", RedirectURIs: []string{"https://a.com/cb"}, ClientScopes: []string{`bad"scope`}}, ErrOAuthInvalidClientScope},
+		{"duplicate client scope", &OAuthClient{ClientID: "abc", Name: "app", RedirectURIs: []string{"https://a.com/cb"}, ClientScopes: []string{"api:read", "api:read"}}, ErrOAuthInvalidClientScope},
+		{"duplicate allowed scope", &OAuthClient{ClientID: "abc", Name: "app", RedirectURIs: []string{"https://a.com/cb"}, AllowedScopes: []string{"openid", "openid"}}, ErrOAuthInvalidClientScope},
+		{"malformed allowed scope", &OAuthClient{ClientID: "abc", Name: "app", RedirectURIs: []string{"https://a.com/cb"}, AllowedScopes: []string{"a b"}}, ErrOAuthInvalidClientScope},
+		{"valid", &OAuthClient{ClientID: "abc", Name: "app", RedirectURIs: []string{"https://a.com/cb", "http://127.0.0.1/cb"}, PostLogoutRedirectURIs: []string{"https://a.com/bye"}, ClientScopes: []string{"api:read"}, AllowedScopes: []string{"openid", "files:read"}}, nil},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -34,6 +46,92 @@ func TestOAuthClientValidate(t *testing.T) {
 	}
 }
 
+func manyRedirectURIs(n int) []string {
+	uris := make([]string, n)
+	for i := range uris {
+		uris[i] = fmt.Sprintf("https://a.com/cb/%d", i)
+	}
+	return uris
+}
+
+func TestOAuthClaimMapping(t *testing.T) {
+	invalid := []OAuthClaimMapping{
+		{Roles: map[string]string{"owner": "Owner"}},
+		{Roles: map[string]string{"admin": " "}},
+		{Groups: map[string]string{"Support": "helpdesk"}},
+		{Groups: map[string]string{"support": strings.Repeat("x", maxOAuthClaimValueLength+1)}},
+	}
+	for _, m := range invalid {
+		if err := m.Validate(); !errors.Is(err, ErrOAuthInvalidClaimMapping) {
+			t.Errorf("Validate(%+v) = %v, want ErrOAuthInvalidClaimMapping", m, err)
+		}
+	}
+
+	var empty OAuthClaimMapping
+	if got := empty.MapRoles(RoleAdmin); !slices.Equal(got, []string{"admin"}) {
+		t.Errorf("MapRoles without mapping = %v", got)
+	}
+	if got := empty.MapGroups(nil); got == nil || len(got) != 0 {
+		t.Errorf("MapGroups(nil) = %#v, want empty non-nil slice", got)
+	}
+
+	m := OAuthClaimMapping{
+		Roles:  map[string]string{"super_admin": "Admin"},
+		Groups: map[string]string{"support": "staff", "ops": "staff"},
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if got := m.MapRoles(RoleAdmin); len(got) != 0 {
+		t.Errorf("unmapped role leaked: %v", got)
+	}
+	if got := m.MapGroups([]string{"ops", "billing", "support"}); !slices.Equal(got, []string{"staff"}) {
+		t.Errorf("MapGroups = %v, want [staff]", got)
+	}
+}
+
+func TestOAuthScopeValidation(t *testing.T) {
+	for _, name := range []string{"openid", "email", "scim", "introspect", "", "a b", `x"y`} {
+		if err := ValidateOAuthScopeName(name); !errors.Is(err, ErrOAuthScopeInvalidName) {
+			t.Errorf("ValidateOAuthScopeName(%q) error = %v, want ErrOAuthScopeInvalidName", name, err)
+		}
+	}
+	if err := ValidateOAuthScopeName("files:read"); err != nil {
+		t.Errorf("ValidateOAuthScopeName(files:read) error = %v", err)
+	}
+	if err := ValidateOAuthAllowedScopes([]string{"openid", "introspect"}); !errors.Is(err, ErrOAuthInvalidClientScope) {
+		t.Errorf("ValidateOAuthAllowedScopes(introspect) error = %v, want ErrOAuthInvalidClientScope", err)
+	}
+
+	invalid := []map[string]string{
+		{"fr": "Lire"},
+		{"en": ""},
+		{"en": strings.Repeat("字", maxOAuthScopeDescriptionLength+1)},
+	}
+	for _, desc := range invalid {
+		if err := ValidateOAuthScopeDescriptions(desc); !errors.Is(err, ErrOAuthScopeInvalidDesc) {
+			t.Errorf("ValidateOAuthScopeDescriptions(%v) error = %v, want ErrOAuthScopeInvalidDesc", desc, err)
+		}
+	}
+	if err := ValidateOAuthScopeDescriptions(map[string]string{"zh-CN": strings.Repeat("字", maxOAuthScopeDescriptionLength)}); err != nil {
+		t.Errorf("ValidateOAuthScopeDescriptions() error = %v", err)
+	}
+}
+
+func TestOAuthScopeDescriptionFallback(t *testing.T) {
+	scope := &OAuthScope{Name: "files:read", Descriptions: map[string]string{"zh-CN": "读取文件", "ja": "ファイルの読み取り"}}
+	cases := map[string]string{"ja": "ファイルの読み取り", "ko": "读取文件", "zh-CN": "读取文件"}
+	for lang, want := range cases {
+		if got := scope.Description(lang); got != want {
+			t.Errorf("Description(%q) = %q, want %q", lang, got, want)
+		}
+	}
+	scope.Descriptions["en"] = "Read files"
+	if got := scope.Description("ko"); got != "Read files" {
+		t.Errorf("Description(ko) = %q, want en fallback", got)
+	}
+}
+
 func TestOAuthAuthCodeLifecycle(t *testing.T) {
 	now := time.Now()
 	expired := &OAuthAuthCode{ExpiresAt: now.Add(-time.Minute), Used: false}
@@ -33,17 +33,20 @@ type OAuthAuthCode struct {
 	Scope               string    `json:"scope"`
 	CodeChallenge       string    `json:"-"` // 不序列化
 	CodeChallengeMethod string    `json:"-"` // 不序列化
+	Nonce               string    `json:"-"` // OIDC nonce，原样写入 ID Token
+	AuthTime            time.Time `json:"-"` // 用户完成认证（登录）的时刻，写入 ID Token 的 auth_time
 	ExpiresAt           time.Time `json:"expires_at"`
 	Used                bool      `json:"used"`
 	CreatedAt           time.Time `json:"created_at"`
 }
 
 // OAuthAccessToken 访问令牌
+// client_credentials 签发的 Token 不关联用户，UserUID 为空
 type OAuthAccessToken struct {
 	ID        int64     `json:"id"`
 	TokenHash string    `json:"-"` // 不序列化
 	ClientID  string    `json:"client_id"`
-	UserUID   string    `json:"user_uid"`
+	UserUID   string    `json:"user_uid,omitempty"`
 	Scope     string    `json:"scope"`
 	ExpiresAt time.Time `json:"expires_at"`
 	CreatedAt time.Time `json:"created_at"`
@@ -148,11 +151,17 @@ func (r *OAuthAuthCodeRepository) Create(ctx context.Context, code *OAuthAuthCod
 		return err
 	}
 
+	// 旧调用方未提供登录时间时写入 NULL，换取 Token 时回退为授权码签发时间
+	var authTime *time.Time
+	if !code.AuthTime.IsZero() {
+		authTime = &code.AuthTime
+	}
+
 	err := r.pool.QueryRow(ctx, `
-		INSERT INTO oauth_auth_codes (code_hash, client_id, user_uid, redirect_uri, scope, code_challenge, code_challenge_method, expires_at, used)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO oauth_auth_codes (code_hash, client_id, user_uid, redirect_uri, scope, code_challenge, code_challenge_method, nonce, auth_time, expires_at, used)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING id, created_at
-	`, code.CodeHash, code.ClientID, code.UserUID, code.RedirectURI, code.Scope, code.CodeChallenge, code.CodeChallengeMethod, code.ExpiresAt, code.Used).Scan(
+	`, code.CodeHash, code.ClientID, code.UserUID, code.RedirectURI, code.Scope, code.CodeChallenge, code.CodeChallengeMethod, code.Nonce, authTime, code.ExpiresAt, code.Used).Scan(
 		&code.ID, &code.CreatedAt,
 	)
 
@@ -175,13 +184,14 @@ func (r *OAuthAuthCodeRepository) FindByCode(ctx context.Context, codeHash strin
 	}
 
 	authCode := &OAuthAuthCode{CodeHash: codeHash}
+	var authTime *time.Time
 	err := r.pool.QueryRow(ctx, `
-		SELECT id, client_id, user_uid, redirect_uri, scope, code_challenge, code_challenge_method, expires_at, used, created_at
+		SELECT id, client_id, user_uid, redirect_uri, scope, code_challenge, code_challenge_method, COALESCE(nonce, ''), auth_time, expires_at, used, created_at
 		FROM oauth_auth_codes WHERE code_hash = $1
 	`, codeHash).Scan(
 		&authCode.ID, &authCode.ClientID, &authCode.UserUID,
-		&authCode.RedirectURI, &authCode.Scope, &authCode.CodeChallenge, &authCode.CodeChallengeMethod,
-		&authCode.ExpiresAt, &authCode.Used, &authCode.CreatedAt,
+		&authCode.RedirectURI, &authCode.Scope, &authCode.CodeChallenge, &authCode.CodeChallengeMethod, &authCode.Nonce,
+		&authTime, &authCode.ExpiresAt, &authCode.Used, &authCode.CreatedAt,
 	)
 
 	if err != nil {
@@ -190,6 +200,9 @@ func (r *OAuthAuthCodeRepository) FindByCode(ctx context.Context, codeHash strin
 		}
 		return nil, utils.LogError("OAUTH_CODE", "FindByCode", err, "code_hash", utils.TruncateIdentifier(codeHash))
 	}
+	if authTime != nil {
+		authCode.AuthTime = *authTime
+	}
 
 	return authCode, nil
 }
@@ -258,7 +271,7 @@ func (r *OAuthAccessTokenRepository) Create(ctx context.Context, token *OAuthAcc
 
 	err := r.pool.QueryRow(ctx, `
 		INSERT INTO oauth_access_tokens (token_hash, client_id, user_uid, scope, expires_at)
-		VALUES ($1, $2, $3, $4, $5)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
 		RETURNING id, created_at
 	`, token.TokenHash, token.ClientID, token.UserUID, token.Scope, token.ExpiresAt).Scan(
 		&token.ID, &token.CreatedAt,
@@ -284,7 +297,7 @@ func (r *OAuthAccessTokenRepository) FindByTokenHash(ctx context.Context, tokenH
 
 	token := &OAuthAccessToken{}
 	err := r.pool.QueryRow(ctx, `
-		SELECT id, token_hash, client_id, user_uid, scope, expires_at, created_at
+		SELECT id, token_hash, client_id, COALESCE(user_uid, ''), scope, expires_at, created_at
 		FROM oauth_access_tokens WHERE token_hash = $1
 	`, tokenHash).Scan(
 		&token.ID, &token.TokenHash, &token.ClientID, &token.UserUID,
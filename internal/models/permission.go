@@ -0,0 +1,147 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrInvalidPermission = errors.New("INVALID_PERMISSION")
+
+// 后台权限，命名为 {资源}.{操作}
+const (
+	PermStatsRead           = "stats.read"
+	PermUsersRead           = "users.read"
+	PermUsersBan            = "users.ban"
+	PermUsersRole           = "users.role"
+	PermUsersDelete         = "users.delete"
+	PermUsers2FAReset       = "users.2fa.reset"
+	PermUsersProvision      = "users.provision"
+	PermRateLimitsRead      = "ratelimits.read"
+	PermLogsRead            = "logs.read"
+	PermOAuthClientsRead    = "oauth.clients.read"
+	PermOAuthClientsWrite   = "oauth.clients.write"
+	PermOAuthScopesWrite    = "oauth.scopes.write"
+	PermEmailWhitelistRead  = "email_whitelist.read"
+	PermEmailWhitelistWrite = "email_whitelist.write"
+	PermDataExport          = "data.export"
+	PermDataImport          = "data.import"
+	PermGroupsRead          = "groups.read"
+	PermGroupsWrite         = "groups.write"
+	PermWebhooksRead        = "webhooks.read"
+	PermWebhooksWrite       = "webhooks.write"
+	PermEmailTemplatesRead  = "email_templates.read"
+)
+
+// Permissions 全部可分配的后台权限（展示顺序）
+var Permissions = []string{
+	PermStatsRead,
+	PermUsersRead,
+	PermUsersBan,
+	PermUsersRole,
+	PermUsersDelete,
+	PermUsers2FAReset,
+	PermUsersProvision,
+	PermRateLimitsRead,
+	PermLogsRead,
+	PermOAuthClientsRead,
+	PermOAuthClientsWrite,
+	PermOAuthScopesWrite,
+	PermEmailWhitelistRead,
+	PermEmailWhitelistWrite,
+	PermDataExport,
+	PermDataImport,
+	PermGroupsRead,
+	PermGroupsWrite,
+	PermWebhooksRead,
+	PermWebhooksWrite,
+	PermEmailTemplatesRead,
+}
+
+// adminRolePermissions RoleAdmin 的内置权限，与拆分权限前普通管理员可访问的接口一致
+var adminRolePermissions = []string{
+	PermStatsRead,
+	PermUsersRead,
+	PermUsersBan,
+	PermRateLimitsRead,
+}
+
+// 角色对外名称（OAuth roles claim 与客户端 claim 映射使用）
+const (
+	RoleNameUser       = "user"
+	RoleNameAdmin      = "admin"
+	RoleNameSuperAdmin = "super_admin"
+)
+
+// RoleName 返回 users.role 的对外名称
+func RoleName(role int) string {
+	switch {
+	case role >= RoleSuperAdmin:
+		return RoleNameSuperAdmin
+	case role >= RoleAdmin:
+		return RoleNameAdmin
+	default:
+		return RoleNameUser
+	}
+}
+
+// IsValidPermission 判断是否为已定义的权限
+func IsValidPermission(perm string) bool {
+	return slices.Contains(Permissions, perm)
+}
+
+// ValidatePermissions 校验权限列表，全部已定义才通过
+func ValidatePermissions(perms []string) error {
+	for _, perm := range perms {
+		if !IsValidPermission(perm) {
+			return fmt.Errorf("%w: %q", ErrInvalidPermission, perm)
+		}
+	}
+	return nil
+}
+
+// RolePermissions 返回 users.role 对应的内置权限；超级管理员拥有全部权限
+func RolePermissions(role int) []string {
+	switch {
+	case role >= RoleSuperAdmin:
+		return slices.Clone(Permissions)
+	case role >= RoleAdmin:
+		return slices.Clone(adminRolePermissions)
+	default:
+		return []string{}
+	}
+}
+
+// MergePermissions 合并多组权限：去重、丢弃未定义的权限，按 Permissions 顺序返回
+func MergePermissions(sets ...[]string) []string {
+	merged := make([]string, 0, len(Permissions))
+	for _, perm := range Permissions {
+		for _, set := range sets {
+			if slices.Contains(set, perm) {
+				merged = append(merged, perm)
+				break
+			}
+		}
+	}
+	return merged
+}
+
+// ResolvePermissions 计算用户的有效权限：内置角色权限 ∪ 所属用户组权限。
+// store 为 nil 时只返回角色权限；查询用户组失败时返回角色权限和错误，调用方可按需降级
+func ResolvePermissions(ctx context.Context, user *User, store UserPermissionReader) ([]string, error) {
+	if user == nil {
+		return []string{}, nil
+	}
+
+	rolePerms := RolePermissions(user.Role)
+	if store == nil {
+		return rolePerms, nil
+	}
+
+	groupPerms, err := store.PermissionsForUser(ctx, user.UID)
+	if err != nil {
+		return rolePerms, err
+	}
+	return MergePermissions(rolePerms, groupPerms), nil
+}
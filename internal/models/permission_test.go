@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+type stubPermissionReader struct {
+	perms []string
+	err   error
+}
+
+func (s stubPermissionReader) PermissionsForUser(context.Context, string) ([]string, error) {
+	return s.perms, s.err
+}
+
+func TestRolePermissions(t *testing.T) {
+	if perms := RolePermissions(RoleUser); len(perms) != 0 {
+		t.Errorf("RoleUser permissions = %v, want none", perms)
+	}
+	admin := RolePermissions(RoleAdmin)
+	if !slices.Contains(admin, PermUsersBan) || slices.Contains(admin, PermUsersRole) {
+		t.Errorf("RoleAdmin permissions = %v", admin)
+	}
+	if super := RolePermissions(RoleSuperAdmin); !slices.Equal(super, Permissions) {
+		t.Errorf("RoleSuperAdmin permissions = %v, want all", super)
+	}
+
+	// 返回副本，调用方修改不影响内置表
+	admin[0] = "tampered"
+	if RolePermissions(RoleAdmin)[0] == "tampered" {
+		t.Error("RolePermissions returned shared slice")
+	}
+}
+
+func TestResolvePermissions(t *testing.T) {
+	user := &User{UID: "u1", Role: RoleAdmin}
+
+	perms, err := ResolvePermissions(context.Background(), user, stubPermissionReader{
+		perms: []string{PermDataExport, PermUsersBan, "unknown.perm", PermDataExport},
+	})
+	if err != nil {
+		t.Fatalf("ResolvePermissions: %v", err)
+	}
+	want := []string{PermStatsRead, PermUsersRead, PermUsersBan, PermRateLimitsRead, PermDataExport}
+	if !slices.Equal(perms, want) {
+		t.Errorf("perms = %v, want %v (并集、去重、按目录排序、丢弃未定义权限)", perms, want)
+	}
+
+	// 查询失败时仍返回角色权限
+	perms, err = ResolvePermissions(context.Background(), user, stubPermissionReader{err: errors.New("db down")})
+	if err == nil || !slices.Equal(perms, RolePermissions(RoleAdmin)) {
+		t.Errorf("on error: perms = %v err = %v", perms, err)
+	}
+}
+
+func TestValidateUserGroup(t *testing.T) {
+	tests := []struct {
+		group UserGroup
+		want  error
+	}{
+		{UserGroup{Name: "support", Permissions: []string{PermUsersRead}}, nil},
+		{UserGroup{Name: "Support"}, ErrUserGroupInvalidName},
+		{UserGroup{Name: "-support"}, ErrUserGroupInvalidName},
+		{UserGroup{Name: "support", Permissions: []string{"users.*"}}, ErrInvalidPermission},
+	}
+	for _, tt := range tests {
+		if err := ValidateUserGroup(&tt.group); !errors.Is(err, tt.want) {
+			t.Errorf("ValidateUserGroup(%+v) = %v, want %v", tt.group, err, tt.want)
+		}
+	}
+}
@@ -21,17 +21,18 @@ const (
 
 // QRLoginToken 扫码登录 Token 模型
 type QRLoginToken struct {
-	Token              string         `json:"token"` // 明文 token，仅业务层使用
-	TokenHash          string         `json:"-"`     // token 的 SHA-256 hash，写入 DB
-	Status             string         `json:"status"`
-	UserUID            sql.NullString `json:"user_uid"`
-	PcIP               string         `json:"pc_ip"`
-	PcUserAgent        string         `json:"pc_user_agent"`
-	PcSessionTokenHash sql.NullString `json:"pc_session_token_hash"`
-	CreatedAt          int64          `json:"created_at"`
-	ScannedAt          sql.NullInt64  `json:"scanned_at"`
-	ConfirmedAt        sql.NullInt64  `json:"confirmed_at"`
-	ExpireTime         int64          `json:"expire_time"`
+	Token              string               `json:"token"` // 明文 token，仅业务层使用
+	TokenHash          string               `json:"-"`     // token 的 SHA-256 hash，写入 DB
+	Status             string               `json:"status"`
+	UserUID            sql.NullString       `json:"user_uid"`
+	PcIP               string               `json:"pc_ip"`
+	PcUserAgent        string               `json:"pc_user_agent"`
+	PcLocation         utils.ClientLocation `json:"-"` // PC 端大致位置，确认登录时用于风险检查
+	PcSessionTokenHash sql.NullString       `json:"pc_session_token_hash"`
+	CreatedAt          int64                `json:"created_at"`
+	ScannedAt          sql.NullInt64        `json:"scanned_at"`
+	ConfirmedAt        sql.NullInt64        `json:"confirmed_at"`
+	ExpireTime         int64                `json:"expire_time"`
 }
 
 // QRLoginRepository 扫码登录仓库
@@ -56,11 +57,12 @@ func (r *QRLoginRepository) FindByToken(ctx context.Context, tokenHash string) (
 
 	qrToken := &QRLoginToken{TokenHash: tokenHash}
 	err := r.pool.QueryRow(ctx, `
-		SELECT status, user_uid, pc_ip, pc_user_agent, pc_session_token_hash,
-		       created_at, scanned_at, confirmed_at, expire_time
+		SELECT status, user_uid, pc_ip, pc_user_agent, pc_country, pc_city, pc_latitude, pc_longitude,
+		       pc_session_token_hash, created_at, scanned_at, confirmed_at, expire_time
 		FROM qr_login_tokens WHERE token_hash = $1
 	`, tokenHash).Scan(
 		&qrToken.Status, &qrToken.UserUID, &qrToken.PcIP, &qrToken.PcUserAgent,
+		&qrToken.PcLocation.Country, &qrToken.PcLocation.City, &qrToken.PcLocation.Latitude, &qrToken.PcLocation.Longitude,
 		&qrToken.PcSessionTokenHash, &qrToken.CreatedAt, &qrToken.ScannedAt, &qrToken.ConfirmedAt, &qrToken.ExpireTime,
 	)
 
@@ -85,9 +87,12 @@ func (r *QRLoginRepository) Create(ctx context.Context, qrToken *QRLoginToken) e
 	}
 
 	_, err := r.pool.Exec(ctx, `
-		INSERT INTO qr_login_tokens (token_hash, status, pc_ip, pc_user_agent, created_at, expire_time)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`, qrToken.TokenHash, qrToken.Status, qrToken.PcIP, qrToken.PcUserAgent, qrToken.CreatedAt, qrToken.ExpireTime)
+		INSERT INTO qr_login_tokens (token_hash, status, pc_ip, pc_user_agent,
+		                             pc_country, pc_city, pc_latitude, pc_longitude, created_at, expire_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, qrToken.TokenHash, qrToken.Status, qrToken.PcIP, qrToken.PcUserAgent,
+		qrToken.PcLocation.Country, qrToken.PcLocation.City, qrToken.PcLocation.Latitude, qrToken.PcLocation.Longitude,
+		qrToken.CreatedAt, qrToken.ExpireTime)
 
 	if err != nil {
 		return utils.LogError("QRLOGIN", "Create", err, "token_hash", utils.TruncateIdentifier(qrToken.TokenHash))
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"auth-system/internal/utils"
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LimitedKey 当前处于限流中的 key（管理后台展示）
+type LimitedKey struct {
+	Key        string `json:"key"`
+	RetryAfter int    `json:"retryAfter"` // 距下一次放行的秒数（向上取整）
+}
+
+// maxLimitedEntries 单个桶最多返回的受限 key 数（管理后台展示用）
+const maxLimitedEntries = 200
+
+// RateLimitRepository 跨实例共享的限流状态（GCRA 算法）
+//
+// 每个 (bucket, limit_key) 只存一个理论到达时间 tat：
+// 请求到达时 base = max(tat, now)，new_tat = base + emission；
+// new_tat - now <= window 则放行并写回 new_tat，否则拒绝且不修改。
+// 令牌桶（速率 1/T、容量 B）对应 emission = T、window = B*T；
+// 固定间隔（两次成功间隔至少 I）对应 emission = window = I。
+// 所有时间计算使用数据库 now()，避免实例间时钟偏差。tat <= now 的行与不存在等价，可随时清理。
+type RateLimitRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewRateLimitRepository 创建限流状态仓库
+func NewRateLimitRepository(pool *pgxpool.Pool) *RateLimitRepository {
+	return &RateLimitRepository{pool: pool}
+}
+
+func (r *RateLimitRepository) checkDB() error {
+	if r.pool == nil {
+		return ErrDBNotInitialized
+	}
+	return nil
+}
+
+// Take 尝试为 key 消耗一次配额，返回是否放行；拒绝时 retryAfter 为需要等待的时间。
+// 已有行通过 FOR UPDATE 串行化，并发请求按提交顺序依次判定
+func (r *RateLimitRepository) Take(ctx context.Context, bucket, key string, emission, window time.Duration) (bool, time.Duration, error) {
+	if err := r.checkDB(); err != nil {
+		return false, 0, err
+	}
+
+	var allowed bool
+	var retrySeconds float64
+	err := r.pool.QueryRow(ctx, `
+		WITH cur AS (
+			SELECT GREATEST(COALESCE(
+				(SELECT tat FROM rate_limits WHERE bucket = $1 AND limit_key = $2 FOR UPDATE),
+				NOW()), NOW()) AS base
+		), decision AS (
+			SELECT base + $3::bigint * INTERVAL '1 microsecond' AS new_tat,
+				base + $3::bigint * INTERVAL '1 microsecond' - NOW() <= $4::bigint * INTERVAL '1 microsecond' AS allowed
+			FROM cur
+		), upsert AS (
+			INSERT INTO rate_limits (bucket, limit_key, tat)
+			SELECT $1, $2, new_tat FROM decision WHERE allowed
+			ON CONFLICT (bucket, limit_key) DO UPDATE SET tat = EXCLUDED.tat
+		)
+		SELECT allowed, EXTRACT(EPOCH FROM (new_tat - NOW() - $4::bigint * INTERVAL '1 microsecond'))::float8
+		FROM decision`,
+		bucket, key, emission.Microseconds(), window.Microseconds(),
+	).Scan(&allowed, &retrySeconds)
+	if err != nil {
+		return false, 0, utils.LogError("DATABASE", "RateLimitTake", err, "bucket", bucket)
+	}
+
+	if allowed || retrySeconds < 0 {
+		return allowed, 0, nil
+	}
+	return false, time.Duration(retrySeconds * float64(time.Second)), nil
+}
+
+// Peek 查询 key 距下一次放行的剩余时间，不消耗配额
+func (r *RateLimitRepository) Peek(ctx context.Context, bucket, key string, emission, window time.Duration) (time.Duration, error) {
+	if err := r.checkDB(); err != nil {
+		return 0, err
+	}
+
+	var retrySeconds float64
+	err := r.pool.QueryRow(ctx, `
+		SELECT COALESCE(MAX(EXTRACT(EPOCH FROM (tat + $3::bigint * INTERVAL '1 microsecond' - $4::bigint * INTERVAL '1 microsecond' - NOW()))), 0)::float8
+		FROM rate_limits WHERE bucket = $1 AND limit_key = $2`,
+		bucket, key, emission.Microseconds(), window.Microseconds(),
+	).Scan(&retrySeconds)
+	if err != nil {
+		return 0, utils.LogError("DATABASE", "RateLimitPeek", err, "bucket", bucket)
+	}
+
+	if retrySeconds <= 0 {
+		return 0, nil
+	}
+	return time.Duration(retrySeconds * float64(time.Second)), nil
+}
+
+// Limited 列出桶内下一次请求会被拒绝的 key，按剩余等待时间降序
+func (r *RateLimitRepository) Limited(ctx context.Context, bucket string, emission, window time.Duration) ([]LimitedKey, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT limit_key, CEIL(EXTRACT(EPOCH FROM (tat + $2::bigint * INTERVAL '1 microsecond' - $3::bigint * INTERVAL '1 microsecond' - NOW())))::int AS retry
+		FROM rate_limits
+		WHERE bucket = $1 AND tat + $2::bigint * INTERVAL '1 microsecond' - $3::bigint * INTERVAL '1 microsecond' > NOW()
+		ORDER BY retry DESC
+		LIMIT $4`,
+		bucket, emission.Microseconds(), window.Microseconds(), maxLimitedEntries,
+	)
+	if err != nil {
+		return nil, utils.LogError("DATABASE", "RateLimitLimited", err, "bucket", bucket)
+	}
+	defer rows.Close()
+
+	var entries []LimitedKey
+	for rows.Next() {
+		var entry LimitedKey
+		if err := rows.Scan(&entry.Key, &entry.RetryAfter); err != nil {
+			return nil, utils.LogError("DATABASE", "RateLimitLimited", err, "bucket", bucket)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.LogError("DATABASE", "RateLimitLimited", err, "bucket", bucket)
+	}
+
+	return entries, nil
+}
+
+// PurgeExpired 删除已完全恢复（tat 已过去）的行，返回删除数量
+func (r *RateLimitRepository) PurgeExpired(ctx context.Context) (int64, error) {
+	if err := r.checkDB(); err != nil {
+		return 0, err
+	}
+
+	tag, err := r.pool.Exec(ctx, `DELETE FROM rate_limits WHERE tat <= NOW()`)
+	if err != nil {
+		return 0, utils.LogError("DATABASE", "RateLimitPurgeExpired", err)
+	}
+	return tag.RowsAffected(), nil
+}
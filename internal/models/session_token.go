@@ -20,16 +20,35 @@ var (
 )
 
 // SessionToken 刷新令牌
+//
+// 同一次登录轮转出的 refresh_token 共享 FamilyID，一个家族即一个设备会话：
+// CreatedAt 沿用家族首次登录时间，LastUsedAt 为最近一次签发/轮转时间，
+// IP/UserAgent 记录最近一次使用该会话的客户端。
 type SessionToken struct {
-	ID        int64      `json:"id"`
-	TokenHash string     `json:"-"`
-	UserUID   string     `json:"user_uid"`
-	FamilyID  string     `json:"family_id"`
-	Banned    bool       `json:"banned"`
-	ExpiresAt time.Time  `json:"expires_at"`
-	CreatedAt time.Time  `json:"created_at"`
-	Used      bool       `json:"used"`
-	UsedAt    *time.Time `json:"used_at,omitempty"`
+	ID         int64      `json:"id"`
+	TokenHash  string     `json:"-"`
+	UserUID    string     `json:"user_uid"`
+	FamilyID   string     `json:"family_id"`
+	Banned     bool       `json:"banned"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Used       bool       `json:"used"`
+	UsedAt     *time.Time `json:"used_at,omitempty"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+}
+
+// sessionTokenColumns 查询刷新令牌时的统一列顺序（与 scanSessionToken 对应）
+const sessionTokenColumns = `id, token_hash, user_uid, family_id, banned, expires_at, created_at, used, used_at,
+	COALESCE(ip, ''), COALESCE(user_agent, ''), COALESCE(last_used_at, created_at)`
+
+func scanSessionToken(row pgx.Row, token *SessionToken) error {
+	return row.Scan(
+		&token.ID, &token.TokenHash, &token.UserUID, &token.FamilyID, &token.Banned,
+		&token.ExpiresAt, &token.CreatedAt, &token.Used, &token.UsedAt,
+		&token.IP, &token.UserAgent, &token.LastUsedAt,
+	)
 }
 
 // IsExpired 检查是否已过期
@@ -48,6 +67,7 @@ func NewSessionTokenRepository(pool *pgxpool.Pool) *SessionTokenRepository {
 }
 
 // Create 创建刷新令牌
+// token.CreatedAt 非零时沿用（轮转时保留家族的登录时间），否则取当前时间
 func (r *SessionTokenRepository) Create(ctx context.Context, token *SessionToken) error {
 	if token == nil {
 		return fmt.Errorf("token object is nil")
@@ -57,12 +77,18 @@ func (r *SessionTokenRepository) Create(ctx context.Context, token *SessionToken
 		return err
 	}
 
+	var createdAt *time.Time
+	if !token.CreatedAt.IsZero() {
+		createdAt = &token.CreatedAt
+	}
+
 	err := r.pool.QueryRow(ctx, `
-		INSERT INTO session_tokens (token_hash, user_uid, family_id, banned, expires_at)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, created_at
-	`, token.TokenHash, token.UserUID, token.FamilyID, token.Banned, token.ExpiresAt).Scan(
-		&token.ID, &token.CreatedAt,
+		INSERT INTO session_tokens (token_hash, user_uid, family_id, banned, expires_at, ip, user_agent, created_at, last_used_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), COALESCE($8, NOW()), NOW())
+		RETURNING id, created_at, last_used_at
+	`, token.TokenHash, token.UserUID, token.FamilyID, token.Banned, token.ExpiresAt,
+		token.IP, token.UserAgent, createdAt).Scan(
+		&token.ID, &token.CreatedAt, &token.LastUsedAt,
 	)
 
 	if err != nil {
@@ -84,13 +110,10 @@ func (r *SessionTokenRepository) FindByHash(ctx context.Context, tokenHash strin
 	}
 
 	token := &SessionToken{}
-	err := r.pool.QueryRow(ctx, `
-		SELECT id, token_hash, user_uid, family_id, banned, expires_at, created_at, used, used_at
+	err := scanSessionToken(r.pool.QueryRow(ctx, `
+		SELECT `+sessionTokenColumns+`
 		FROM session_tokens WHERE token_hash = $1
-	`, tokenHash).Scan(
-		&token.ID, &token.TokenHash, &token.UserUID, &token.FamilyID, &token.Banned,
-		&token.ExpiresAt, &token.CreatedAt, &token.Used, &token.UsedAt,
-	)
+	`, tokenHash), token)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -143,6 +166,95 @@ func (r *SessionTokenRepository) RevokeFamily(ctx context.Context, familyID stri
 	return count, nil
 }
 
+// FamilyActive 判断 token 家族（会话）是否仍存在未过期的刷新令牌；撤销会话即删除其全部令牌
+func (r *SessionTokenRepository) FamilyActive(ctx context.Context, familyID string) (bool, error) {
+	if err := r.checkDB(); err != nil {
+		return false, err
+	}
+
+	var active bool
+	err := r.pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM session_tokens WHERE family_id = $1 AND expires_at > NOW())
+	`, familyID).Scan(&active)
+	if err != nil {
+		return false, utils.LogError("SESSION_TOKEN", "FamilyActive", err, "family_id", familyID)
+	}
+	return active, nil
+}
+
+// ListActiveByUser 列出用户当前有效的会话
+// 每个家族仅有一枚未使用的 refresh_token（轮转时旧 token 先标记已使用），因此每行对应一个设备会话
+func (r *SessionTokenRepository) ListActiveByUser(ctx context.Context, userUID string) ([]*SessionToken, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+sessionTokenColumns+`
+		FROM session_tokens
+		WHERE user_uid = $1 AND used = FALSE AND expires_at > NOW()
+		ORDER BY last_used_at DESC NULLS LAST, id DESC
+	`, userUID)
+	if err != nil {
+		return nil, utils.LogError("SESSION_TOKEN", "ListActiveByUser", err, "user_uid", userUID)
+	}
+	defer rows.Close()
+
+	tokens := make([]*SessionToken, 0)
+	for rows.Next() {
+		token := &SessionToken{}
+		if err := scanSessionToken(rows, token); err != nil {
+			return nil, utils.LogError("SESSION_TOKEN", "ListActiveByUser", err, "user_uid", userUID)
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, utils.LogError("SESSION_TOKEN", "ListActiveByUser", err, "user_uid", userUID)
+	}
+
+	return tokens, nil
+}
+
+// RevokeUserFamily 撤销属于指定用户的 token 家族（用户主动登出某设备）
+// 按 user_uid 限定范围，防止撤销他人的会话；返回删除行数，0 表示家族不存在或不属于该用户
+func (r *SessionTokenRepository) RevokeUserFamily(ctx context.Context, userUID, familyID string) (int64, error) {
+	if err := r.checkDB(); err != nil {
+		return 0, err
+	}
+
+	result, err := r.pool.Exec(ctx, `
+		DELETE FROM session_tokens WHERE user_uid = $1 AND family_id = $2
+	`, userUID, familyID)
+
+	if err != nil {
+		return 0, utils.LogError("SESSION_TOKEN", "RevokeUserFamily", err, "user_uid", userUID, "family_id", familyID)
+	}
+
+	count := result.RowsAffected()
+	utils.LogInfo("SESSION_TOKEN", "User token family revoked", "user_uid", userUID, "family_id", familyID, "count", count)
+	return count, nil
+}
+
+// RevokeUserExcept 撤销用户除指定家族外的所有刷新令牌（"登出其他设备"）
+func (r *SessionTokenRepository) RevokeUserExcept(ctx context.Context, userUID, keepFamilyID string) (int64, error) {
+	if err := r.checkDB(); err != nil {
+		return 0, err
+	}
+
+	result, err := r.pool.Exec(ctx, `
+		DELETE FROM session_tokens WHERE user_uid = $1 AND family_id <> $2
+	`, userUID, keepFamilyID)
+
+	if err != nil {
+		return 0, utils.LogError("SESSION_TOKEN", "RevokeUserExcept", err, "user_uid", userUID, "family_id", keepFamilyID)
+	}
+
+	count := result.RowsAffected()
+	utils.LogInfo("SESSION_TOKEN", "Other user tokens revoked", "user_uid", userUID, "kept_family_id", keepFamilyID, "count", count)
+	return count, nil
+}
+
 // RevokeUser 撤销用户的所有刷新令牌
 func (r *SessionTokenRepository) RevokeUser(ctx context.Context, userUID string) (int64, error) {
 	if err := r.checkDB(); err != nil {
@@ -14,10 +14,8 @@ import (
 )
 
 var (
-	ErrEmailExists       = errors.New("EMAIL_EXISTS")
-	ErrUsernameExists    = errors.New("USERNAME_EXISTS")
-	ErrMicrosoftIDExists = errors.New("MICROSOFT_ID_EXISTS")
-	ErrGoogleIDExists    = errors.New("GOOGLE_ID_EXISTS")
+	ErrEmailExists    = errors.New("EMAIL_EXISTS")
+	ErrUsernameExists = errors.New("USERNAME_EXISTS")
 )
 
 // IsUniqueViolation 检查错误是否为指定列的唯一约束冲突
@@ -39,44 +37,50 @@ const (
 
 // allowedUpdateFields 允许更新的字段白名单
 var allowedUpdateFields = map[string]bool{
-	"username":              true,
-	"email":                 true,
-	"avatar_url":            true,
-	"microsoft_id":          true,
-	"microsoft_name":        true,
-	"microsoft_avatar_url":  true,
-	"microsoft_avatar_hash": true,
-	"google_id":             true,
-	"google_name":           true,
-	"google_avatar_url":     true,
-	"microsoft_avatar_sync": true,
-	"role":                  true,
+	"username":                true,
+	"email":                   true,
+	"avatar_url":              true,
+	"microsoft_avatar_url":    true,
+	"microsoft_avatar_hash":   true,
+	"microsoft_avatar_sync":   true,
+	"role":                    true,
+	"password_reset_required": true,
 }
 
 // User 用户模型
+// MicrosoftID / MicrosoftName / GoogleID / GoogleName / GoogleAvatarURL 为只读投影，查询时取自 user_identities
 type User struct {
-	ID                  int64          `json:"id"`
-	UID                 string         `json:"uid"`
-	Username            string         `json:"username"`
-	Email               string         `json:"email"`
-	Password            string         `json:"-"` // 不序列化到 JSON
-	AvatarURL           string         `json:"avatar_url"`
-	Role                int            `json:"role"` // 0: user, 1: admin, 2: super_admin
-	MicrosoftID         sql.NullString `json:"microsoft_id"`
-	MicrosoftName       sql.NullString `json:"microsoft_name"`
-	MicrosoftAvatarURL  sql.NullString `json:"microsoft_avatar_url"`
-	MicrosoftAvatarHash sql.NullString `json:"-"` // 头像哈希，用于判断是否需要更新
-	GoogleID            sql.NullString `json:"google_id"`
-	GoogleName          sql.NullString `json:"google_name"`
-	GoogleAvatarURL     sql.NullString `json:"google_avatar_url"`
-	MicrosoftAvatarSync bool           `json:"microsoft_avatar_sync"`
-	IsBanned            bool           `json:"is_banned"`  // 是否被封禁
-	BanReason           sql.NullString `json:"ban_reason"` // 封禁原因
-	BannedAt            sql.NullTime   `json:"banned_at"`  // 封禁时间
-	BannedBy            sql.NullString `json:"banned_by"`  // 封禁操作者 UID
-	UnbanAt             sql.NullTime   `json:"unban_at"`   // 解封时间（NULL 表示永封）
-	CreatedAt           time.Time      `json:"created_at"`
-	UpdatedAt           time.Time      `json:"updated_at"`
+	ID                    int64          `json:"id"`
+	UID                   string         `json:"uid"`
+	Username              string         `json:"username"`
+	Email                 string         `json:"email"`
+	Password              string         `json:"-"` // 不序列化到 JSON
+	AvatarURL             string         `json:"avatar_url"`
+	Role                  int            `json:"role"` // 0: user, 1: admin, 2: super_admin
+	MicrosoftID           sql.NullString `json:"microsoft_id"`
+	MicrosoftName         sql.NullString `json:"microsoft_name"`
+	MicrosoftAvatarURL    sql.NullString `json:"microsoft_avatar_url"`
+	MicrosoftAvatarHash   sql.NullString `json:"-"` // 头像哈希，用于判断是否需要更新
+	GoogleID              sql.NullString `json:"google_id"`
+	GoogleName            sql.NullString `json:"google_name"`
+	GoogleAvatarURL       sql.NullString `json:"google_avatar_url"`
+	MicrosoftAvatarSync   bool           `json:"microsoft_avatar_sync"`
+	IsBanned              bool           `json:"is_banned"`    // 是否被封禁
+	BanReason             sql.NullString `json:"ban_reason"`   // 封禁原因
+	BannedAt              sql.NullTime   `json:"banned_at"`    // 封禁时间
+	BannedBy              sql.NullString `json:"banned_by"`    // 封禁操作者 UID
+	UnbanAt               sql.NullTime   `json:"unban_at"`     // 解封时间（NULL 表示永封）
+	TOTPSecret            sql.NullString `json:"-"`            // 加密后的 TOTP 密钥（绑定中或已启用）
+	TOTPEnabled           bool           `json:"totp_enabled"` // 是否已启用两步验证
+	TOTPRecoveryCodes     []string       `json:"-"`            // 剩余恢复码的哈希
+	TOTPLastStep          int64          `json:"-"`            // 最近一次使用的 TOTP 时间步
+	TOTPEnabledAt         sql.NullTime   `json:"totp_enabled_at"`
+	PasswordResetRequired bool           `json:"password_reset_required"` // 可疑登录后需通过邮件重置密码才能再用密码登录
+	FailedLoginCount      int            `json:"failed_login_count"`      // 连续密码登录失败次数（登录成功后清零）
+	LastFailedLoginAt     sql.NullTime   `json:"last_failed_login_at"`
+	LockedUntil           sql.NullTime   `json:"locked_until"` // 登录失败过多被临时锁定的截止时间
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
 }
 
 // UserPublic 公开的用户信息（不含敏感数据）
@@ -98,21 +102,37 @@ type UserPublic struct {
 	BanReason           *string    `json:"ban_reason,omitempty"`
 	BannedAt            *time.Time `json:"banned_at,omitempty"`
 	UnbanAt             *time.Time `json:"unban_at,omitempty"` // NULL 表示永封
+	TOTPEnabled         bool       `json:"totp_enabled"`
+	FailedLoginCount    int        `json:"failed_login_count"`
+	LockedUntil         *time.Time `json:"locked_until,omitempty"` // 仅处于登录锁定期时返回
 	CreatedAt           time.Time  `json:"created_at"`
 }
 
+// microsoftIdentityColumns / googleIdentityColumns 内置 Provider 的绑定信息存放于 user_identities，
+// 按原列名投影到用户查询中（FROM 须为未加别名的 users）
+const (
+	microsoftIdentityColumns = `(SELECT subject FROM user_identities WHERE user_uid = users.uid AND provider = 'microsoft') AS microsoft_id,
+       (SELECT display_name FROM user_identities WHERE user_uid = users.uid AND provider = 'microsoft') AS microsoft_name`
+	googleIdentityColumns = `(SELECT subject FROM user_identities WHERE user_uid = users.uid AND provider = 'google') AS google_id,
+       (SELECT display_name FROM user_identities WHERE user_uid = users.uid AND provider = 'google') AS google_name,
+       (SELECT NULLIF(avatar_url, '') FROM user_identities WHERE user_uid = users.uid AND provider = 'google') AS google_avatar_url`
+)
+
 // userColumns 用户表全列 SELECT 字符串，所有查询方法统一引用
 const userColumns = `id, uid, username, email, password, avatar_url, role,
-       microsoft_id, microsoft_name, microsoft_avatar_url, microsoft_avatar_hash,
-       google_id, google_name, google_avatar_url, microsoft_avatar_sync,
+       ` + microsoftIdentityColumns + `, microsoft_avatar_url, microsoft_avatar_hash,
+       ` + googleIdentityColumns + `, microsoft_avatar_sync,
        is_banned, ban_reason, banned_at, banned_by, unban_at,
+       totp_secret, totp_enabled, totp_recovery_codes, totp_last_step, totp_enabled_at,
+       password_reset_required, failed_login_count, last_failed_login_at, locked_until,
        created_at, updated_at`
 
 // userColumnsPublic 不包含 password，用于管理后台列表等不需要密码哈希的场景
 const userColumnsPublic = `id, uid, username, email, avatar_url, role,
-       microsoft_id, microsoft_name, microsoft_avatar_url, microsoft_avatar_hash,
-       google_id, google_name, google_avatar_url,
+       ` + microsoftIdentityColumns + `, microsoft_avatar_url, microsoft_avatar_hash,
+       ` + googleIdentityColumns + `,
        is_banned, ban_reason, banned_at, banned_by, unban_at,
+       totp_enabled,
        created_at, updated_at`
 
 // UserRepository 用户仓库
@@ -135,6 +155,8 @@ func (u *User) ToPublic() *UserPublic {
 		AvatarURL:           u.AvatarURL,
 		Role:                u.Role,
 		IsBanned:            u.IsBanned,
+		TOTPEnabled:         u.TOTPEnabled,
+		FailedLoginCount:    u.FailedLoginCount,
 		CreatedAt:           u.CreatedAt,
 		MicrosoftAvatarSync: u.MicrosoftAvatarSync,
 	}
@@ -166,6 +188,9 @@ func (u *User) ToPublic() *UserPublic {
 	if u.UnbanAt.Valid {
 		pub.UnbanAt = &u.UnbanAt.Time
 	}
+	if u.IsLoginLocked(time.Now()) {
+		pub.LockedUntil = &u.LockedUntil.Time
+	}
 
 	return pub
 }
@@ -237,6 +262,8 @@ func (r *UserRepository) FindByUID(ctx context.Context, uid string) (*User, erro
 		&user.MicrosoftID, &user.MicrosoftName, &user.MicrosoftAvatarURL, &user.MicrosoftAvatarHash,
 		&user.GoogleID, &user.GoogleName, &user.GoogleAvatarURL, &user.MicrosoftAvatarSync,
 		&user.IsBanned, &user.BanReason, &user.BannedAt, &user.BannedBy, &user.UnbanAt,
+		&user.TOTPSecret, &user.TOTPEnabled, &user.TOTPRecoveryCodes, &user.TOTPLastStep, &user.TOTPEnabledAt,
+		&user.PasswordResetRequired, &user.FailedLoginCount, &user.LastFailedLoginAt, &user.LockedUntil,
 		&user.CreatedAt, &user.UpdatedAt,
 	)
 
@@ -268,6 +295,8 @@ func (r *UserRepository) FindByEmailOrUsername(ctx context.Context, identifier s
 		&user.MicrosoftID, &user.MicrosoftName, &user.MicrosoftAvatarURL, &user.MicrosoftAvatarHash,
 		&user.GoogleID, &user.GoogleName, &user.GoogleAvatarURL, &user.MicrosoftAvatarSync,
 		&user.IsBanned, &user.BanReason, &user.BannedAt, &user.BannedBy, &user.UnbanAt,
+		&user.TOTPSecret, &user.TOTPEnabled, &user.TOTPRecoveryCodes, &user.TOTPLastStep, &user.TOTPEnabledAt,
+		&user.PasswordResetRequired, &user.FailedLoginCount, &user.LastFailedLoginAt, &user.LockedUntil,
 		&user.CreatedAt, &user.UpdatedAt,
 	)
 
@@ -296,6 +325,8 @@ func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*User,
 		&user.MicrosoftID, &user.MicrosoftName, &user.MicrosoftAvatarURL, &user.MicrosoftAvatarHash,
 		&user.GoogleID, &user.GoogleName, &user.GoogleAvatarURL, &user.MicrosoftAvatarSync,
 		&user.IsBanned, &user.BanReason, &user.BannedAt, &user.BannedBy, &user.UnbanAt,
+		&user.TOTPSecret, &user.TOTPEnabled, &user.TOTPRecoveryCodes, &user.TOTPLastStep, &user.TOTPEnabledAt,
+		&user.PasswordResetRequired, &user.FailedLoginCount, &user.LastFailedLoginAt, &user.LockedUntil,
 		&user.CreatedAt, &user.UpdatedAt,
 	)
 
@@ -324,6 +355,8 @@ func (r *UserRepository) FindByUsername(ctx context.Context, username string) (*
 		&user.MicrosoftID, &user.MicrosoftName, &user.MicrosoftAvatarURL, &user.MicrosoftAvatarHash,
 		&user.GoogleID, &user.GoogleName, &user.GoogleAvatarURL, &user.MicrosoftAvatarSync,
 		&user.IsBanned, &user.BanReason, &user.BannedAt, &user.BannedBy, &user.UnbanAt,
+		&user.TOTPSecret, &user.TOTPEnabled, &user.TOTPRecoveryCodes, &user.TOTPLastStep, &user.TOTPEnabledAt,
+		&user.PasswordResetRequired, &user.FailedLoginCount, &user.LastFailedLoginAt, &user.LockedUntil,
 		&user.CreatedAt, &user.UpdatedAt,
 	)
 
@@ -334,62 +367,6 @@ func (r *UserRepository) FindByUsername(ctx context.Context, username string) (*
 	return user, nil
 }
 
-// FindByMicrosoftID 根据 Microsoft ID 查找用户
-func (r *UserRepository) FindByMicrosoftID(ctx context.Context, msID string) (*User, error) {
-	if msID == "" {
-		return nil, errors.New("empty microsoft ID")
-	}
-
-	if r.pool == nil {
-		return nil, errors.New("database not ready")
-	}
-
-	user := &User{}
-	err := r.pool.QueryRow(ctx, `
-		SELECT `+userColumns+`
-		FROM users WHERE microsoft_id = $1`, msID).Scan(
-		&user.ID, &user.UID, &user.Username, &user.Email, &user.Password, &user.AvatarURL, &user.Role,
-		&user.MicrosoftID, &user.MicrosoftName, &user.MicrosoftAvatarURL, &user.MicrosoftAvatarHash,
-		&user.GoogleID, &user.GoogleName, &user.GoogleAvatarURL, &user.MicrosoftAvatarSync,
-		&user.IsBanned, &user.BanReason, &user.BannedAt, &user.BannedBy, &user.UnbanAt,
-		&user.CreatedAt, &user.UpdatedAt,
-	)
-
-	if err != nil {
-		return nil, utils.HandleDatabaseError("USER", "FindByMicrosoftID", err, msID)
-	}
-
-	return user, nil
-}
-
-// FindByGoogleID 根据 Google ID 查找用户
-func (r *UserRepository) FindByGoogleID(ctx context.Context, googleID string) (*User, error) {
-	if googleID == "" {
-		return nil, errors.New("empty google ID")
-	}
-
-	if r.pool == nil {
-		return nil, errors.New("database not ready")
-	}
-
-	user := &User{}
-	err := r.pool.QueryRow(ctx, `
-		SELECT `+userColumns+`
-		FROM users WHERE google_id = $1`, googleID).Scan(
-		&user.ID, &user.UID, &user.Username, &user.Email, &user.Password, &user.AvatarURL, &user.Role,
-		&user.MicrosoftID, &user.MicrosoftName, &user.MicrosoftAvatarURL, &user.MicrosoftAvatarHash,
-		&user.GoogleID, &user.GoogleName, &user.GoogleAvatarURL, &user.MicrosoftAvatarSync,
-		&user.IsBanned, &user.BanReason, &user.BannedAt, &user.BannedBy, &user.UnbanAt,
-		&user.CreatedAt, &user.UpdatedAt,
-	)
-
-	if err != nil {
-		return nil, utils.HandleDatabaseError("USER", "FindByGoogleID", err, googleID)
-	}
-
-	return user, nil
-}
-
 // Create 创建用户
 // ID、CreatedAt、UpdatedAt 会被自动填充
 func (r *UserRepository) Create(ctx context.Context, user *User) error {
@@ -426,10 +403,10 @@ func (r *UserRepository) Create(ctx context.Context, user *User) error {
 			}
 
 			err = r.pool.QueryRow(ctx, `
-				INSERT INTO users (uid, username, email, password, avatar_url, microsoft_id, role)
-				VALUES ($1, $2, $3, $4, $5, $6, $7)
+				INSERT INTO users (uid, username, email, password, avatar_url, role)
+				VALUES ($1, $2, $3, $4, $5, $6)
 				RETURNING id, created_at, updated_at
-			`, user.UID, user.Username, user.Email, user.Password, user.AvatarURL, user.MicrosoftID, user.Role).Scan(
+			`, user.UID, user.Username, user.Email, user.Password, user.AvatarURL, user.Role).Scan(
 				&user.ID, &user.CreatedAt, &user.UpdatedAt,
 			)
 
@@ -451,10 +428,10 @@ func (r *UserRepository) Create(ctx context.Context, user *User) error {
 
 	// UID 已指定，直接插入
 	err := r.pool.QueryRow(ctx, `
-		INSERT INTO users (uid, username, email, password, avatar_url, microsoft_id, role)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO users (uid, username, email, password, avatar_url, role)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id, created_at, updated_at
-	`, user.UID, user.Username, user.Email, user.Password, user.AvatarURL, user.MicrosoftID, user.Role).Scan(
+	`, user.UID, user.Username, user.Email, user.Password, user.AvatarURL, user.Role).Scan(
 		&user.ID, &user.CreatedAt, &user.UpdatedAt,
 	)
 
@@ -505,7 +482,7 @@ func (r *UserRepository) Update(ctx context.Context, uid string, updates map[str
 	return nil
 }
 
-// UpdatePassword 更新用户密码（内部强制哈希，不接受明文）
+// UpdatePassword 更新用户密码（内部强制哈希，不接受明文），同时清除 password_reset_required
 func (r *UserRepository) UpdatePassword(ctx context.Context, uid, plainPassword string) error {
 	if uid == "" {
 		return errors.New("invalid user UID")
@@ -527,7 +504,7 @@ func (r *UserRepository) UpdatePassword(ctx context.Context, uid, plainPassword
 	}
 
 	result, err := r.pool.Exec(ctx,
-		"UPDATE users SET password = $1, updated_at = NOW() WHERE uid = $2",
+		"UPDATE users SET password = $1, password_reset_required = FALSE, updated_at = NOW() WHERE uid = $2",
 		hashedPassword, uid,
 	)
 	if err != nil {
@@ -573,12 +550,6 @@ func (r *UserRepository) handleWriteError(err error, operation string, identifie
 	if IsUniqueViolation(err, "username") {
 		return ErrUsernameExists
 	}
-	if IsUniqueViolation(err, "microsoft_id") {
-		return ErrMicrosoftIDExists
-	}
-	if IsUniqueViolation(err, "google_id") {
-		return ErrGoogleIDExists
-	}
 
 	return utils.LogError("USER", operation, err, "identifier", identifier)
 }
@@ -687,6 +658,7 @@ func (r *UserRepository) FindAll(ctx context.Context, page, pageSize int, search
 			&user.MicrosoftID, &user.MicrosoftName, &user.MicrosoftAvatarURL, &user.MicrosoftAvatarHash,
 			&user.GoogleID, &user.GoogleName, &user.GoogleAvatarURL,
 			&user.IsBanned, &user.BanReason, &user.BannedAt, &user.BannedBy, &user.UnbanAt,
+			&user.TOTPEnabled,
 			&user.CreatedAt, &user.UpdatedAt,
 		)
 		if err != nil {
@@ -699,6 +671,50 @@ func (r *UserRepository) FindAll(ctx context.Context, page, pageSize int, search
 	return users, total, nil
 }
 
+// FindRange 按注册顺序（id 升序）查询从 offset 起的 limit 个用户及总数（SCIM startIndex/count 分页）
+func (r *UserRepository) FindRange(ctx context.Context, offset, limit int) ([]*User, int64, error) {
+	if r.pool == nil {
+		return nil, 0, errors.New("database not ready")
+	}
+
+	var total int64
+	if err := r.pool.QueryRow(ctx, "SELECT COUNT(*) FROM users").Scan(&total); err != nil {
+		return nil, 0, utils.LogError("USER", "FindRange.Count", err)
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+userColumnsPublic+`
+		FROM users
+		ORDER BY id
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, utils.LogError("USER", "FindRange.Query", err)
+	}
+	defer rows.Close()
+
+	users := make([]*User, 0, limit)
+	for rows.Next() {
+		user := &User{}
+		if err := rows.Scan(
+			&user.ID, &user.UID, &user.Username, &user.Email, &user.AvatarURL, &user.Role,
+			&user.MicrosoftID, &user.MicrosoftName, &user.MicrosoftAvatarURL, &user.MicrosoftAvatarHash,
+			&user.GoogleID, &user.GoogleName, &user.GoogleAvatarURL,
+			&user.IsBanned, &user.BanReason, &user.BannedAt, &user.BannedBy, &user.UnbanAt,
+			&user.TOTPEnabled,
+			&user.CreatedAt, &user.UpdatedAt,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to iterate users: %w", err)
+	}
+
+	return users, total, nil
+}
+
 // GetStats 获取用户统计数据
 func (r *UserRepository) GetStats(ctx context.Context) (*UserStats, error) {
 	if r.pool == nil {
@@ -0,0 +1,312 @@
+package models
+
+import (
+	"auth-system/internal/utils"
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrUserGroupNotFound       = errors.New("USER_GROUP_NOT_FOUND")
+	ErrUserGroupExists         = errors.New("USER_GROUP_EXISTS")
+	ErrUserGroupInvalidName    = errors.New("USER_GROUP_INVALID_NAME")
+	ErrUserGroupInvalidDesc    = errors.New("USER_GROUP_INVALID_DESCRIPTION")
+	ErrUserGroupMemberNotFound = errors.New("USER_GROUP_MEMBER_NOT_FOUND")
+)
+
+const maxUserGroupDescriptionLength = 255
+
+// userGroupNamePattern 组名：小写字母开头，允许小写字母、数字、- 和 _，最长 64
+var userGroupNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+
+// UserGroup 管理员维护的用户组，成员获得 Permissions 中的后台权限
+type UserGroup struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	MemberCount int64     `json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// UserGroupMember 用户组成员
+type UserGroupMember struct {
+	UserUID   string    `json:"user_uid"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Role      int       `json:"role"`
+	CreatedAt time.Time `json:"created_at"` // 加入时间
+}
+
+// ValidateUserGroup 校验组名、描述长度与权限列表
+func ValidateUserGroup(group *UserGroup) error {
+	if !userGroupNamePattern.MatchString(group.Name) {
+		return ErrUserGroupInvalidName
+	}
+	if len([]rune(group.Description)) > maxUserGroupDescriptionLength {
+		return ErrUserGroupInvalidDesc
+	}
+	return ValidatePermissions(group.Permissions)
+}
+
+// UserGroupRepository 用户组仓库
+type UserGroupRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewUserGroupRepository 创建用户组仓库
+func NewUserGroupRepository(pool *pgxpool.Pool) *UserGroupRepository {
+	return &UserGroupRepository{pool: pool}
+}
+
+const userGroupColumns = `g.id, g.name, g.description, g.permissions,
+	(SELECT COUNT(*) FROM user_group_members m WHERE m.group_id = g.id), g.created_at, g.updated_at`
+
+func scanUserGroup(row pgx.Row, group *UserGroup) error {
+	return row.Scan(&group.ID, &group.Name, &group.Description, &group.Permissions, &group.MemberCount, &group.CreatedAt, &group.UpdatedAt)
+}
+
+func (r *UserGroupRepository) checkDB() error {
+	if r.pool == nil {
+		return ErrDBNotInitialized
+	}
+	return nil
+}
+
+// FindAll 获取全部用户组（按名称排序）
+func (r *UserGroupRepository) FindAll(ctx context.Context) ([]*UserGroup, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	return r.queryGroups(ctx, "FindAll", `SELECT `+userGroupColumns+` FROM user_groups g ORDER BY g.name ASC`)
+}
+
+// FindByUser 获取用户所属的全部用户组
+func (r *UserGroupRepository) FindByUser(ctx context.Context, userUID string) ([]*UserGroup, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	return r.queryGroups(ctx, "FindByUser", `
+		SELECT `+userGroupColumns+`
+		FROM user_groups g
+		JOIN user_group_members um ON um.group_id = g.id
+		WHERE um.user_uid = $1
+		ORDER BY g.name ASC
+	`, userUID)
+}
+
+func (r *UserGroupRepository) queryGroups(ctx context.Context, op, query string, args ...any) ([]*UserGroup, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, utils.LogError("USER_GROUP", op, err)
+	}
+	defer rows.Close()
+
+	groups := make([]*UserGroup, 0)
+	for rows.Next() {
+		group := &UserGroup{}
+		if err := scanUserGroup(rows, group); err != nil {
+			return nil, utils.LogError("USER_GROUP", op, err)
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.LogError("USER_GROUP", op, err)
+	}
+	return groups, nil
+}
+
+// FindByID 按 ID 查询
+func (r *UserGroupRepository) FindByID(ctx context.Context, id int64) (*UserGroup, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	group := &UserGroup{}
+	err := scanUserGroup(r.pool.QueryRow(ctx, `SELECT `+userGroupColumns+` FROM user_groups g WHERE g.id = $1`, id), group)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserGroupNotFound
+		}
+		return nil, utils.LogError("USER_GROUP", "FindByID", err, "id", id)
+	}
+	return group, nil
+}
+
+// Create 创建用户组
+func (r *UserGroupRepository) Create(ctx context.Context, group *UserGroup) error {
+	if err := ValidateUserGroup(group); err != nil {
+		return err
+	}
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+	if group.Permissions == nil {
+		group.Permissions = []string{}
+	}
+
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO user_groups (name, description, permissions)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at
+	`, group.Name, group.Description, group.Permissions).Scan(&group.ID, &group.CreatedAt, &group.UpdatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrUserGroupExists
+		}
+		return utils.LogError("USER_GROUP", "Create", err, "name", group.Name)
+	}
+
+	utils.LogInfo("USER_GROUP", "Group created", "id", group.ID, "name", group.Name)
+	return nil
+}
+
+// Update 更新组名、描述与权限
+func (r *UserGroupRepository) Update(ctx context.Context, group *UserGroup) error {
+	if err := ValidateUserGroup(group); err != nil {
+		return err
+	}
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+	if group.Permissions == nil {
+		group.Permissions = []string{}
+	}
+
+	err := scanUserGroup(r.pool.QueryRow(ctx, `
+		UPDATE user_groups g SET name = $1, description = $2, permissions = $3, updated_at = NOW()
+		WHERE g.id = $4
+		RETURNING `+userGroupColumns, group.Name, group.Description, group.Permissions, group.ID), group)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserGroupNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrUserGroupExists
+		}
+		return utils.LogError("USER_GROUP", "Update", err, "id", group.ID)
+	}
+	return nil
+}
+
+// Delete 删除用户组（成员关系随外键级联删除）
+func (r *UserGroupRepository) Delete(ctx context.Context, id int64) (*UserGroup, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	group := &UserGroup{}
+	err := r.pool.QueryRow(ctx, `
+		DELETE FROM user_groups WHERE id = $1
+		RETURNING id, name, description, permissions, created_at, updated_at
+	`, id).Scan(&group.ID, &group.Name, &group.Description, &group.Permissions, &group.CreatedAt, &group.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserGroupNotFound
+		}
+		return nil, utils.LogError("USER_GROUP", "Delete", err, "id", id)
+	}
+
+	utils.LogInfo("USER_GROUP", "Group deleted", "id", id, "name", group.Name)
+	return group, nil
+}
+
+// ListMembers 获取用户组成员（按加入时间排序）
+func (r *UserGroupRepository) ListMembers(ctx context.Context, groupID int64) ([]*UserGroupMember, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT u.uid, u.username, u.email, u.role, m.created_at
+		FROM user_group_members m
+		JOIN users u ON u.uid = m.user_uid
+		WHERE m.group_id = $1
+		ORDER BY m.created_at ASC
+	`, groupID)
+	if err != nil {
+		return nil, utils.LogError("USER_GROUP", "ListMembers", err, "group_id", groupID)
+	}
+	defer rows.Close()
+
+	members := make([]*UserGroupMember, 0)
+	for rows.Next() {
+		member := &UserGroupMember{}
+		if err := rows.Scan(&member.UserUID, &member.Username, &member.Email, &member.Role, &member.CreatedAt); err != nil {
+			return nil, utils.LogError("USER_GROUP", "ListMembers", err, "group_id", groupID)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.LogError("USER_GROUP", "ListMembers", err, "group_id", groupID)
+	}
+	return members, nil
+}
+
+// AddMember 将用户加入用户组，已是成员时不做任何事
+func (r *UserGroupRepository) AddMember(ctx context.Context, groupID int64, userUID string) error {
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO user_group_members (group_id, user_uid)
+		VALUES ($1, $2)
+		ON CONFLICT (group_id, user_uid) DO NOTHING
+	`, groupID, userUID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrUserGroupNotFound
+		}
+		return utils.LogError("USER_GROUP", "AddMember", err, "group_id", groupID, "user_uid", userUID)
+	}
+	return nil
+}
+
+// RemoveMember 将用户移出用户组
+func (r *UserGroupRepository) RemoveMember(ctx context.Context, groupID int64, userUID string) error {
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+
+	tag, err := r.pool.Exec(ctx, `DELETE FROM user_group_members WHERE group_id = $1 AND user_uid = $2`, groupID, userUID)
+	if err != nil {
+		return utils.LogError("USER_GROUP", "RemoveMember", err, "group_id", groupID, "user_uid", userUID)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserGroupMemberNotFound
+	}
+	return nil
+}
+
+// PermissionsForUser 返回用户所属全部用户组的权限并集（未排序、可能含重复，由 MergePermissions 规整）
+func (r *UserGroupRepository) PermissionsForUser(ctx context.Context, userUID string) ([]string, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	var perms []string
+	err := r.pool.QueryRow(ctx, `
+		SELECT COALESCE(array_agg(DISTINCT p), '{}')
+		FROM user_group_members m
+		JOIN user_groups g ON g.id = m.group_id
+		CROSS JOIN LATERAL unnest(g.permissions) AS p
+		WHERE m.user_uid = $1
+	`, userUID).Scan(&perms)
+	if err != nil {
+		return nil, utils.LogError("USER_GROUP", "PermissionsForUser", err, "user_uid", userUID)
+	}
+	return perms, nil
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"auth-system/internal/utils"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrUserIdentityNotFound = errors.New("USER_IDENTITY_NOT_FOUND")
+	ErrUserIdentityExists   = errors.New("USER_IDENTITY_EXISTS")
+)
+
+// 内置 Provider 名称；通用 OIDC 配置中保留，不可用作自定义 Provider 名
+const (
+	ProviderMicrosoft = "microsoft"
+	ProviderGoogle    = "google"
+)
+
+// UserIdentity 用户绑定的外部身份
+// 内置的 Microsoft / Google 与通过配置新增的通用 OIDC Provider 统一存放于此表，
+// 新增 IdP 无需改代码或迁移。每个用户在同一 Provider 下最多绑定一个身份。
+type UserIdentity struct {
+	ID          int64     `json:"-"`
+	UserUID     string    `json:"-"`
+	Provider    string    `json:"provider"` // Provider 配置名（路由段），如 "keycloak"
+	Subject     string    `json:"-"`        // Provider 侧的用户标识（sub 或映射的 ID 声明）
+	DisplayName string    `json:"display_name"`
+	Email       string    `json:"email,omitempty"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+const userIdentityColumns = `id, user_uid, provider, subject, display_name, email, avatar_url, created_at, updated_at`
+
+// UserIdentityRepository 外部身份数据访问层
+type UserIdentityRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewUserIdentityRepository 创建外部身份仓库
+func NewUserIdentityRepository(pool *pgxpool.Pool) *UserIdentityRepository {
+	return &UserIdentityRepository{pool: pool}
+}
+
+func scanUserIdentity(row pgx.Row) (*UserIdentity, error) {
+	i := &UserIdentity{}
+	err := row.Scan(&i.ID, &i.UserUID, &i.Provider, &i.Subject, &i.DisplayName, &i.Email, &i.AvatarURL, &i.CreatedAt, &i.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func (r *UserIdentityRepository) checkDB() error {
+	if r.pool == nil {
+		return ErrDBNotInitialized
+	}
+	return nil
+}
+
+// FindBySubject 按 Provider 与 Provider 侧用户标识查找（登录时使用）
+func (r *UserIdentityRepository) FindBySubject(ctx context.Context, provider, subject string) (*UserIdentity, error) {
+	return r.findOne(ctx, "FindBySubject", `provider = $1 AND subject = $2`, provider, subject)
+}
+
+// FindByUser 查找用户在指定 Provider 下绑定的身份
+func (r *UserIdentityRepository) FindByUser(ctx context.Context, userUID, provider string) (*UserIdentity, error) {
+	return r.findOne(ctx, "FindByUser", `user_uid = $1 AND provider = $2`, userUID, provider)
+}
+
+func (r *UserIdentityRepository) findOne(ctx context.Context, op, where string, a, b string) (*UserIdentity, error) {
+	if a == "" || b == "" {
+		return nil, ErrUserIdentityNotFound
+	}
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	identity, err := scanUserIdentity(r.pool.QueryRow(ctx, `SELECT `+userIdentityColumns+` FROM user_identities WHERE `+where, a, b))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserIdentityNotFound
+		}
+		return nil, utils.LogError("USER_IDENTITY", op, err, "provider", a)
+	}
+	return identity, nil
+}
+
+// ListByUser 列出用户绑定的全部外部身份（按绑定时间升序）
+func (r *UserIdentityRepository) ListByUser(ctx context.Context, userUID string) ([]*UserIdentity, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+userIdentityColumns+` FROM user_identities WHERE user_uid = $1 ORDER BY created_at ASC, id ASC`, userUID)
+	if err != nil {
+		return nil, utils.LogError("USER_IDENTITY", "ListByUser", err, "user_uid", userUID)
+	}
+	defer rows.Close()
+
+	identities := []*UserIdentity{}
+	for rows.Next() {
+		identity, err := scanUserIdentity(rows)
+		if err != nil {
+			return nil, utils.LogError("USER_IDENTITY", "ListByUser", err, "user_uid", userUID)
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+// Link 绑定外部身份；用户已绑定该 Provider 时替换为新身份
+// 该外部身份已绑定到其他用户时返回 ErrUserIdentityExists
+func (r *UserIdentityRepository) Link(ctx context.Context, identity *UserIdentity) error {
+	if identity == nil || identity.UserUID == "" || identity.Provider == "" || identity.Subject == "" {
+		return errors.New("invalid user identity")
+	}
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO user_identities (user_uid, provider, subject, display_name, email, avatar_url)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_uid, provider) DO UPDATE
+		SET subject = EXCLUDED.subject, display_name = EXCLUDED.display_name,
+		    email = EXCLUDED.email, avatar_url = EXCLUDED.avatar_url, updated_at = NOW()
+		RETURNING id, created_at, updated_at
+	`, identity.UserUID, identity.Provider, identity.Subject, identity.DisplayName, identity.Email, identity.AvatarURL,
+	).Scan(&identity.ID, &identity.CreatedAt, &identity.UpdatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrUserIdentityExists
+		}
+		return utils.LogError("USER_IDENTITY", "Link", err, "user_uid", identity.UserUID, "provider", identity.Provider)
+	}
+
+	utils.LogInfo("USER_IDENTITY", "Identity linked", "user_uid", identity.UserUID, "provider", identity.Provider)
+	return nil
+}
+
+// UpdateProfile 登录时同步 Provider 侧的显示名称、邮箱与头像
+func (r *UserIdentityRepository) UpdateProfile(ctx context.Context, identity *UserIdentity) error {
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+
+	_, err := r.pool.Exec(ctx, `
+		UPDATE user_identities SET display_name = $1, email = $2, avatar_url = $3, updated_at = NOW()
+		WHERE provider = $4 AND subject = $5
+	`, identity.DisplayName, identity.Email, identity.AvatarURL, identity.Provider, identity.Subject)
+	if err != nil {
+		return utils.LogError("USER_IDENTITY", "UpdateProfile", err, "provider", identity.Provider)
+	}
+	return nil
+}
+
+// Delete 解绑用户在指定 Provider 下的身份，未绑定时返回 ErrUserIdentityNotFound
+func (r *UserIdentityRepository) Delete(ctx context.Context, userUID, provider string) error {
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+
+	tag, err := r.pool.Exec(ctx, `DELETE FROM user_identities WHERE user_uid = $1 AND provider = $2`, userUID, provider)
+	if err != nil {
+		return utils.LogError("USER_IDENTITY", "Delete", err, "user_uid", userUID, "provider", provider)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserIdentityNotFound
+	}
+
+	utils.LogInfo("USER_IDENTITY", "Identity unlinked", "user_uid", userUID, "provider", provider)
+	return nil
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"auth-system/internal/utils"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrUnlockTokenNotFound = errors.New("UNLOCK_TOKEN_NOT_FOUND")
+
+// IsLoginLocked 检查账户是否处于登录失败锁定期
+func (u *User) IsLoginLocked(now time.Time) bool {
+	return u != nil && u.LockedUntil.Valid && now.Before(u.LockedUntil.Time)
+}
+
+// RecordLoginFailure 原子地累计一次密码登录失败并返回累计次数；
+// 上次失败早于 windowStart 时重新从 1 开始计数
+func (r *UserRepository) RecordLoginFailure(ctx context.Context, uid string, windowStart time.Time) (int, error) {
+	if uid == "" {
+		return 0, errors.New("invalid user UID")
+	}
+
+	if r.pool == nil {
+		return 0, errors.New("database not ready")
+	}
+
+	var count int
+	err := r.pool.QueryRow(ctx, `
+		UPDATE users SET
+			failed_login_count = CASE
+				WHEN last_failed_login_at IS NULL OR last_failed_login_at < $2 THEN 1
+				ELSE failed_login_count + 1
+			END,
+			last_failed_login_at = NOW()
+		WHERE uid = $1
+		RETURNING failed_login_count
+	`, uid, windowStart).Scan(&count)
+	if err != nil {
+		return 0, utils.HandleDatabaseError("USER", "RecordLoginFailure", err, uid)
+	}
+	return count, nil
+}
+
+// LockLogin 锁定账户的密码登录至 until，并保存解锁链接 Token 的哈希（覆盖之前的链接）
+func (r *UserRepository) LockLogin(ctx context.Context, uid string, until time.Time, unlockTokenHash string) error {
+	if uid == "" {
+		return errors.New("invalid user UID")
+	}
+
+	if r.pool == nil {
+		return errors.New("database not ready")
+	}
+
+	result, err := r.pool.Exec(ctx, `
+		UPDATE users SET locked_until = $1, unlock_token_hash = $2
+		WHERE uid = $3
+	`, until, unlockTokenHash, uid)
+	if err != nil {
+		return utils.LogError("USER", "LockLogin", err, "uid", uid)
+	}
+
+	if result.RowsAffected() == 0 {
+		return utils.HandleDatabaseError("USER", "LockLogin", errors.New("no rows affected"), uid)
+	}
+
+	utils.LogInfo("USER", "User login locked", "uid", uid, "until", until)
+	return nil
+}
+
+// ResetLoginFailures 清除失败计数、锁定状态与解锁链接（登录成功时调用）
+func (r *UserRepository) ResetLoginFailures(ctx context.Context, uid string) error {
+	if uid == "" {
+		return errors.New("invalid user UID")
+	}
+
+	if r.pool == nil {
+		return errors.New("database not ready")
+	}
+
+	_, err := r.pool.Exec(ctx, `
+		UPDATE users SET failed_login_count = 0, last_failed_login_at = NULL, locked_until = NULL, unlock_token_hash = NULL
+		WHERE uid = $1 AND (failed_login_count > 0 OR locked_until IS NOT NULL)
+	`, uid)
+	if err != nil {
+		return utils.LogError("USER", "ResetLoginFailures", err, "uid", uid)
+	}
+	return nil
+}
+
+// UnlockLogin 凭解锁链接 Token 哈希解除锁定并清零失败计数，返回用户 UID；
+// Token 不存在或锁定已过期时返回 ErrUnlockTokenNotFound
+func (r *UserRepository) UnlockLogin(ctx context.Context, unlockTokenHash string) (string, error) {
+	if unlockTokenHash == "" {
+		return "", ErrUnlockTokenNotFound
+	}
+
+	if r.pool == nil {
+		return "", errors.New("database not ready")
+	}
+
+	var uid string
+	err := r.pool.QueryRow(ctx, `
+		UPDATE users SET failed_login_count = 0, last_failed_login_at = NULL, locked_until = NULL, unlock_token_hash = NULL
+		WHERE unlock_token_hash = $1 AND locked_until > NOW()
+		RETURNING uid
+	`, unlockTokenHash).Scan(&uid)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrUnlockTokenNotFound
+		}
+		return "", utils.LogError("USER", "UnlockLogin", err)
+	}
+
+	utils.LogInfo("USER", "User login unlocked", "uid", uid)
+	return uid, nil
+}
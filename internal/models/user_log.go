@@ -15,11 +15,14 @@ const (
 	UserActionRegister        = "register"
 	UserActionChangePassword  = "change_password"
 	UserActionChangeUsername  = "change_username"
+	UserActionChangeEmail     = "change_email"
 	UserActionChangeAvatar    = "change_avatar"
 	UserActionLinkMicrosoft   = "link_microsoft"
 	UserActionUnlinkMicrosoft = "unlink_microsoft"
 	UserActionLinkGoogle      = "link_google"
 	UserActionUnlinkGoogle    = "unlink_google"
+	UserActionLinkIdentity    = "link_identity"
+	UserActionUnlinkIdentity  = "unlink_identity"
 	UserActionDeleteAccount   = "delete_account"
 	UserActionBanned          = "banned"
 	UserActionUnbanned        = "unbanned"
@@ -28,6 +31,17 @@ const (
 	// 头像同步开关（隐私层面：服务器是否持续存储第三方头像）
 	UserActionEnableAvatarSync  = "enable_avatar_sync"
 	UserActionDisableAvatarSync = "disable_avatar_sync"
+	// 两步验证（TOTP）
+	UserActionEnable2FA          = "enable_2fa"
+	UserActionDisable2FA         = "disable_2fa"
+	UserActionUse2FARecoveryCode = "use_2fa_recovery_code"
+	// Passkey（WebAuthn）
+	UserActionRegisterPasskey = "register_passkey"
+	UserActionDeletePasskey   = "delete_passkey"
+	// 通过新设备登录提醒邮件确认 "不是我本人"
+	UserActionSecureAccount = "secure_account"
+	// 密码登录失败（密码错误；达到阈值时账户被临时锁定）
+	UserActionLoginFailed = "login_failed"
 )
 
 // UserLog 用户操作日志
@@ -45,6 +59,12 @@ type ChangeUsernameDetails struct {
 	NewUsername string `json:"new_username"`
 }
 
+// ChangeEmailDetails 修改邮箱详情
+type ChangeEmailDetails struct {
+	OldEmail string `json:"old_email"`
+	NewEmail string `json:"new_email"`
+}
+
 // ChangeAvatarDetails 修改头像详情（仅记录显示头像变化；同步开关是独立日志）
 type ChangeAvatarDetails struct {
 	OldAvatarURL string `json:"old_avatar_url,omitempty"`
@@ -80,6 +100,13 @@ type UnlinkGoogleDetails struct {
 	GoogleName string `json:"google_name"`
 }
 
+// IdentityDetails 绑定/解绑通用 OIDC Provider 账户详情
+type IdentityDetails struct {
+	Provider     string `json:"provider"`
+	ProviderID   string `json:"provider_id"`
+	ProviderName string `json:"provider_name"`
+}
+
 // BannedDetails 被封禁详情
 type BannedDetails struct {
 	Reason  string     `json:"reason"`
@@ -99,6 +126,36 @@ type OAuthRevokeDetails struct {
 	ClientName string `json:"client_name"`
 }
 
+// Disable2FADetails 关闭两步验证详情
+type Disable2FADetails struct {
+	ResetBy string `json:"reset_by,omitempty"` // 超级管理员重置时为其 UID，用户自行关闭时为空
+}
+
+// Use2FARecoveryCodeDetails 使用恢复码详情
+type Use2FARecoveryCodeDetails struct {
+	Remaining int `json:"remaining"`
+}
+
+// PasskeyDetails Passkey 注册/删除详情
+type PasskeyDetails struct {
+	CredentialID int64  `json:"credential_id"`
+	Name         string `json:"name"`
+}
+
+// SecureAccountDetails "不是我本人" 详情（被撤销的可疑登录）
+type SecureAccountDetails struct {
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	FamilyID  string `json:"family_id,omitempty"` // 为空表示已撤销全部会话
+}
+
+// LoginFailedDetails 密码登录失败详情
+type LoginFailedDetails struct {
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	Locked    bool   `json:"locked,omitempty"` // 本次失败触发了账户锁定
+}
+
 // UserLogRepository 用户日志仓库
 type UserLogRepository struct {
 	pool *pgxpool.Pool
@@ -176,6 +233,25 @@ func (r *UserLogRepository) LogChangeUsername(ctx context.Context, userUID strin
 	return r.Create(ctx, log)
 }
 
+// LogChangeEmail 记录修改邮箱操作
+func (r *UserLogRepository) LogChangeEmail(ctx context.Context, userUID string, oldEmail, newEmail string) error {
+	details := ChangeEmailDetails{
+		OldEmail: oldEmail,
+		NewEmail: newEmail,
+	}
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("marshal details failed: %w", err)
+	}
+
+	log := &UserLog{
+		UserUID: userUID,
+		Action:  UserActionChangeEmail,
+		Details: detailsJSON,
+	}
+	return r.Create(ctx, log)
+}
+
 // LogChangeAvatar 记录修改头像操作
 func (r *UserLogRepository) LogChangeAvatar(ctx context.Context, userUID string, oldURL, newURL string) error {
 	details := ChangeAvatarDetails{
@@ -299,6 +375,28 @@ func (r *UserLogRepository) LogUnlinkGoogle(ctx context.Context, userUID string,
 	return r.Create(ctx, log)
 }
 
+// LogLinkIdentity 记录绑定通用 OIDC Provider 账户操作
+func (r *UserLogRepository) LogLinkIdentity(ctx context.Context, userUID string, provider, subject, displayName string) error {
+	return r.logIdentity(ctx, userUID, UserActionLinkIdentity, provider, subject, displayName)
+}
+
+// LogUnlinkIdentity 记录解绑通用 OIDC Provider 账户操作
+func (r *UserLogRepository) LogUnlinkIdentity(ctx context.Context, userUID string, provider, subject, displayName string) error {
+	return r.logIdentity(ctx, userUID, UserActionUnlinkIdentity, provider, subject, displayName)
+}
+
+func (r *UserLogRepository) logIdentity(ctx context.Context, userUID, action, provider, subject, displayName string) error {
+	detailsJSON, err := json.Marshal(IdentityDetails{Provider: provider, ProviderID: subject, ProviderName: displayName})
+	if err != nil {
+		return fmt.Errorf("marshal details failed: %w", err)
+	}
+	return r.Create(ctx, &UserLog{
+		UserUID: userUID,
+		Action:  action,
+		Details: detailsJSON,
+	})
+}
+
 // LogDeleteAccount 记录删除账户操作
 func (r *UserLogRepository) LogDeleteAccount(ctx context.Context, userUID string) error {
 	log := &UserLog{
@@ -375,6 +473,99 @@ func (r *UserLogRepository) LogOAuthRevoke(ctx context.Context, userUID string,
 	return r.Create(ctx, log)
 }
 
+// LogEnable2FA 记录启用两步验证
+func (r *UserLogRepository) LogEnable2FA(ctx context.Context, userUID string) error {
+	log := &UserLog{
+		UserUID: userUID,
+		Action:  UserActionEnable2FA,
+	}
+	return r.Create(ctx, log)
+}
+
+// LogDisable2FA 记录关闭两步验证，resetBy 非空表示由超级管理员重置
+func (r *UserLogRepository) LogDisable2FA(ctx context.Context, userUID, resetBy string) error {
+	detailsJSON, err := json.Marshal(Disable2FADetails{ResetBy: resetBy})
+	if err != nil {
+		return fmt.Errorf("marshal details failed: %w", err)
+	}
+
+	log := &UserLog{
+		UserUID: userUID,
+		Action:  UserActionDisable2FA,
+		Details: detailsJSON,
+	}
+	return r.Create(ctx, log)
+}
+
+// LogUse2FARecoveryCode 记录使用恢复码完成两步验证
+func (r *UserLogRepository) LogUse2FARecoveryCode(ctx context.Context, userUID string, remaining int) error {
+	detailsJSON, err := json.Marshal(Use2FARecoveryCodeDetails{Remaining: remaining})
+	if err != nil {
+		return fmt.Errorf("marshal details failed: %w", err)
+	}
+
+	log := &UserLog{
+		UserUID: userUID,
+		Action:  UserActionUse2FARecoveryCode,
+		Details: detailsJSON,
+	}
+	return r.Create(ctx, log)
+}
+
+// LogRegisterPasskey 记录注册 Passkey
+func (r *UserLogRepository) LogRegisterPasskey(ctx context.Context, userUID string, credentialID int64, name string) error {
+	return r.logPasskey(ctx, userUID, UserActionRegisterPasskey, credentialID, name)
+}
+
+// LogDeletePasskey 记录删除 Passkey
+func (r *UserLogRepository) LogDeletePasskey(ctx context.Context, userUID string, credentialID int64, name string) error {
+	return r.logPasskey(ctx, userUID, UserActionDeletePasskey, credentialID, name)
+}
+
+func (r *UserLogRepository) logPasskey(ctx context.Context, userUID, action string, credentialID int64, name string) error {
+	detailsJSON, err := json.Marshal(PasskeyDetails{CredentialID: credentialID, Name: name})
+	if err != nil {
+		return fmt.Errorf("marshal details failed: %w", err)
+	}
+
+	log := &UserLog{
+		UserUID: userUID,
+		Action:  action,
+		Details: detailsJSON,
+	}
+	return r.Create(ctx, log)
+}
+
+// LogSecureAccount 记录通过登录提醒撤销可疑会话并要求重置密码
+func (r *UserLogRepository) LogSecureAccount(ctx context.Context, userUID, ip, userAgent, familyID string) error {
+	detailsJSON, err := json.Marshal(SecureAccountDetails{IP: ip, UserAgent: userAgent, FamilyID: familyID})
+	if err != nil {
+		return fmt.Errorf("marshal details failed: %w", err)
+	}
+
+	log := &UserLog{
+		UserUID: userUID,
+		Action:  UserActionSecureAccount,
+		Details: detailsJSON,
+	}
+	return r.Create(ctx, log)
+}
+
+// LogLoginFailed 记录密码登录失败
+func (r *UserLogRepository) LogLoginFailed(ctx context.Context, userUID, ip, userAgent string, locked bool) error {
+	detailsJSON, err := json.Marshal(LoginFailedDetails{IP: ip, UserAgent: userAgent, Locked: locked})
+	if err != nil {
+		return fmt.Errorf("marshal details failed: %w", err)
+	}
+
+	log := &UserLog{
+		UserUID: userUID,
+		Action:  UserActionLoginFailed,
+		Details: detailsJSON,
+	}
+	return r.Create(ctx, log)
+}
+
 // FindByUserUID 查询用户的操作日志（分页）
 func (r *UserLogRepository) FindByUserUID(ctx context.Context, userUID string, page, pageSize int) ([]*UserLog, int64, error) {
 	if r.pool == nil {
@@ -72,3 +72,28 @@ func TestUserValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestIsLoginLocked(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		user *User
+		want bool
+	}{
+		{"never locked", &User{}, false},
+		{"locked", &User{LockedUntil: sql.NullTime{Valid: true, Time: now.Add(time.Minute)}}, true},
+		{"lock expired", &User{LockedUntil: sql.NullTime{Valid: true, Time: now.Add(-time.Minute)}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.IsLoginLocked(now); got != tt.want {
+				t.Errorf("IsLoginLocked() = %v, want %v", got, tt.want)
+			}
+			// 管理后台只在锁定期内看到 locked_until
+			if got := tt.user.ToPublic().LockedUntil != nil; got != tt.want {
+				t.Errorf("ToPublic().LockedUntil set = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
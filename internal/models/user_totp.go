@@ -0,0 +1,162 @@
+package models
+
+import (
+	"auth-system/internal/utils"
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrTOTPAlreadyEnabled = errors.New("TOTP_ALREADY_ENABLED")
+	ErrTOTPNotPending     = errors.New("TOTP_NOT_PENDING")
+)
+
+// SetPendingTOTPSecret 写入待确认的 TOTP 密钥（仅未启用时允许，重复调用覆盖旧的待确认密钥）
+func (r *UserRepository) SetPendingTOTPSecret(ctx context.Context, uid, encryptedSecret string) error {
+	if uid == "" {
+		return errors.New("invalid user UID")
+	}
+	if encryptedSecret == "" {
+		return errors.New("totp secret is empty")
+	}
+
+	if r.pool == nil {
+		return errors.New("database not ready")
+	}
+
+	result, err := r.pool.Exec(ctx, `
+		UPDATE users SET totp_secret = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE uid = $2 AND totp_enabled = FALSE
+	`, encryptedSecret, uid)
+	if err != nil {
+		return utils.LogError("USER", "SetPendingTOTPSecret", err, "uid", uid)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrTOTPAlreadyEnabled
+	}
+
+	utils.LogInfo("USER", "Pending TOTP secret stored", "uid", uid)
+	return nil
+}
+
+// EnableTOTP 确认绑定：启用两步验证并写入恢复码哈希，step 为确认时使用的时间步（防止同一验证码随即用于登录）
+func (r *UserRepository) EnableTOTP(ctx context.Context, uid string, recoveryCodeHashes []string, step int64) error {
+	if uid == "" {
+		return errors.New("invalid user UID")
+	}
+	if len(recoveryCodeHashes) == 0 {
+		return errors.New("recovery codes are empty")
+	}
+
+	if r.pool == nil {
+		return errors.New("database not ready")
+	}
+
+	result, err := r.pool.Exec(ctx, `
+		UPDATE users SET
+			totp_enabled = TRUE,
+			totp_recovery_codes = $1,
+			totp_last_step = $2,
+			totp_enabled_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE uid = $3 AND totp_enabled = FALSE AND totp_secret IS NOT NULL
+	`, recoveryCodeHashes, step, uid)
+	if err != nil {
+		return utils.LogError("USER", "EnableTOTP", err, "uid", uid)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrTOTPNotPending
+	}
+
+	utils.LogInfo("USER", "TOTP enabled", "uid", uid)
+	return nil
+}
+
+// DisableTOTP 关闭两步验证并清除密钥、恢复码（用户自行关闭与超级管理员重置共用）
+func (r *UserRepository) DisableTOTP(ctx context.Context, uid string) error {
+	if uid == "" {
+		return errors.New("invalid user UID")
+	}
+
+	if r.pool == nil {
+		return errors.New("database not ready")
+	}
+
+	result, err := r.pool.Exec(ctx, `
+		UPDATE users SET
+			totp_secret = NULL,
+			totp_enabled = FALSE,
+			totp_recovery_codes = NULL,
+			totp_last_step = 0,
+			totp_enabled_at = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE uid = $1
+	`, uid)
+	if err != nil {
+		return utils.LogError("USER", "DisableTOTP", err, "uid", uid)
+	}
+
+	if result.RowsAffected() == 0 {
+		return utils.HandleDatabaseError("USER", "DisableTOTP", errors.New("no rows affected"), uid)
+	}
+
+	utils.LogInfo("USER", "TOTP disabled", "uid", uid)
+	return nil
+}
+
+// AdvanceTOTPStep 原子推进最近使用的时间步，返回 false 表示该时间步已被使用（并发重放）
+func (r *UserRepository) AdvanceTOTPStep(ctx context.Context, uid string, step int64) (bool, error) {
+	if uid == "" {
+		return false, errors.New("invalid user UID")
+	}
+
+	if r.pool == nil {
+		return false, errors.New("database not ready")
+	}
+
+	result, err := r.pool.Exec(ctx, `
+		UPDATE users SET totp_last_step = $1
+		WHERE uid = $2 AND totp_enabled = TRUE AND totp_last_step < $1
+	`, step, uid)
+	if err != nil {
+		return false, utils.LogError("USER", "AdvanceTOTPStep", err, "uid", uid)
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+// ConsumeRecoveryCode 原子消费一个恢复码，成功时返回剩余数量；哈希不存在返回 ok=false
+func (r *UserRepository) ConsumeRecoveryCode(ctx context.Context, uid, codeHash string) (int, bool, error) {
+	if uid == "" {
+		return 0, false, errors.New("invalid user UID")
+	}
+	if codeHash == "" {
+		return 0, false, nil
+	}
+
+	if r.pool == nil {
+		return 0, false, errors.New("database not ready")
+	}
+
+	var remaining int
+	err := r.pool.QueryRow(ctx, `
+		UPDATE users SET
+			totp_recovery_codes = array_remove(totp_recovery_codes, $1),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE uid = $2 AND totp_enabled = TRUE AND $1 = ANY(totp_recovery_codes)
+		RETURNING COALESCE(cardinality(totp_recovery_codes), 0)
+	`, codeHash, uid).Scan(&remaining)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, utils.LogError("USER", "ConsumeRecoveryCode", err, "uid", uid)
+	}
+
+	utils.LogInfo("USER", "Recovery code consumed", "uid", uid, "remaining", remaining)
+	return remaining, true, nil
+}
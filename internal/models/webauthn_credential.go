@@ -0,0 +1,184 @@
+package models
+
+import (
+	"auth-system/internal/utils"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrWebAuthnCredentialExists = errors.New("WEBAUTHN_CREDENTIAL_EXISTS")
+)
+
+// WebAuthnCredential 用户注册的 Passkey（WebAuthn 凭据）
+type WebAuthnCredential struct {
+	ID             int64      `json:"id"`
+	UserUID        string     `json:"-"`
+	CredentialID   []byte     `json:"-"`
+	PublicKey      []byte     `json:"-"` // COSE_Key
+	SignCount      int64      `json:"-"`
+	AAGUID         []byte     `json:"-"`
+	Transports     []string   `json:"transports"`
+	BackupEligible bool       `json:"backup_eligible"`
+	BackedUp       bool       `json:"backed_up"`
+	Name           string     `json:"name"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+}
+
+const webAuthnCredentialColumns = `id, user_uid, credential_id, public_key, sign_count, aaguid, transports,
+	backup_eligible, backed_up, name, created_at, last_used_at`
+
+// WebAuthnCredentialRepository Passkey 数据访问层
+type WebAuthnCredentialRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewWebAuthnCredentialRepository(pool *pgxpool.Pool) *WebAuthnCredentialRepository {
+	return &WebAuthnCredentialRepository{pool: pool}
+}
+
+func scanWebAuthnCredential(row pgx.Row) (*WebAuthnCredential, error) {
+	c := &WebAuthnCredential{}
+	err := row.Scan(&c.ID, &c.UserUID, &c.CredentialID, &c.PublicKey, &c.SignCount, &c.AAGUID, &c.Transports,
+		&c.BackupEligible, &c.BackedUp, &c.Name, &c.CreatedAt, &c.LastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Create 保存新凭据，credential_id 已存在时返回 ErrWebAuthnCredentialExists
+func (r *WebAuthnCredentialRepository) Create(ctx context.Context, cred *WebAuthnCredential) error {
+	if r.pool == nil {
+		return ErrDBNotInitialized
+	}
+	if cred.Transports == nil {
+		cred.Transports = []string{}
+	}
+
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO webauthn_credentials
+			(user_uid, credential_id, public_key, sign_count, aaguid, transports, backup_eligible, backed_up, name)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at
+	`, cred.UserUID, cred.CredentialID, cred.PublicKey, cred.SignCount, cred.AAGUID, cred.Transports,
+		cred.BackupEligible, cred.BackedUp, cred.Name).Scan(&cred.ID, &cred.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrWebAuthnCredentialExists
+		}
+		return utils.LogError("WEBAUTHN", "Create", err, "user_uid", cred.UserUID)
+	}
+
+	utils.LogInfo("WEBAUTHN", "Credential registered", "user_uid", cred.UserUID, "id", cred.ID)
+	return nil
+}
+
+// FindByCredentialID 按认证器凭据 ID 查询（登录时使用）
+func (r *WebAuthnCredentialRepository) FindByCredentialID(ctx context.Context, credentialID []byte) (*WebAuthnCredential, error) {
+	if r.pool == nil {
+		return nil, ErrDBNotInitialized
+	}
+
+	cred, err := scanWebAuthnCredential(r.pool.QueryRow(ctx,
+		`SELECT `+webAuthnCredentialColumns+` FROM webauthn_credentials WHERE credential_id = $1`, credentialID))
+	if err != nil {
+		return nil, utils.HandleDatabaseError("WEBAUTHN", "FindByCredentialID", err, "credential")
+	}
+	return cred, nil
+}
+
+// ListByUser 列出用户的全部凭据（按注册时间升序）
+func (r *WebAuthnCredentialRepository) ListByUser(ctx context.Context, userUID string) ([]*WebAuthnCredential, error) {
+	if r.pool == nil {
+		return nil, ErrDBNotInitialized
+	}
+
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+webAuthnCredentialColumns+` FROM webauthn_credentials WHERE user_uid = $1 ORDER BY created_at ASC, id ASC`, userUID)
+	if err != nil {
+		return nil, utils.LogError("WEBAUTHN", "ListByUser", err, "user_uid", userUID)
+	}
+	defer rows.Close()
+
+	creds := []*WebAuthnCredential{}
+	for rows.Next() {
+		cred, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, utils.LogError("WEBAUTHN", "ListByUser", err, "user_uid", userUID)
+		}
+		creds = append(creds, cred)
+	}
+	return creds, rows.Err()
+}
+
+// FindByID 查询用户名下的指定凭据（不属于该用户时视为不存在）
+func (r *WebAuthnCredentialRepository) FindByID(ctx context.Context, userUID string, id int64) (*WebAuthnCredential, error) {
+	if r.pool == nil {
+		return nil, ErrDBNotInitialized
+	}
+
+	cred, err := scanWebAuthnCredential(r.pool.QueryRow(ctx,
+		`SELECT `+webAuthnCredentialColumns+` FROM webauthn_credentials WHERE id = $1 AND user_uid = $2`, id, userUID))
+	if err != nil {
+		return nil, utils.HandleDatabaseError("WEBAUTHN", "FindByID", err, id)
+	}
+	return cred, nil
+}
+
+// Rename 修改凭据名称
+func (r *WebAuthnCredentialRepository) Rename(ctx context.Context, userUID string, id int64, name string) error {
+	if r.pool == nil {
+		return ErrDBNotInitialized
+	}
+
+	result, err := r.pool.Exec(ctx,
+		`UPDATE webauthn_credentials SET name = $1 WHERE id = $2 AND user_uid = $3`, name, id, userUID)
+	if err != nil {
+		return utils.LogError("WEBAUTHN", "Rename", err, "id", id)
+	}
+	if result.RowsAffected() == 0 {
+		return utils.HandleDatabaseError("WEBAUTHN", "Rename", pgx.ErrNoRows, id)
+	}
+	return nil
+}
+
+// Delete 删除用户名下的凭据
+func (r *WebAuthnCredentialRepository) Delete(ctx context.Context, userUID string, id int64) error {
+	if r.pool == nil {
+		return ErrDBNotInitialized
+	}
+
+	result, err := r.pool.Exec(ctx,
+		`DELETE FROM webauthn_credentials WHERE id = $1 AND user_uid = $2`, id, userUID)
+	if err != nil {
+		return utils.LogError("WEBAUTHN", "Delete", err, "id", id)
+	}
+	if result.RowsAffected() == 0 {
+		return utils.HandleDatabaseError("WEBAUTHN", "Delete", pgx.ErrNoRows, id)
+	}
+
+	utils.LogInfo("WEBAUTHN", "Credential deleted", "user_uid", userUID, "id", id)
+	return nil
+}
+
+// UpdateUsage 登录成功后更新签名计数与最近使用时间
+func (r *WebAuthnCredentialRepository) UpdateUsage(ctx context.Context, id int64, signCount int64) error {
+	if r.pool == nil {
+		return ErrDBNotInitialized
+	}
+
+	_, err := r.pool.Exec(ctx,
+		`UPDATE webauthn_credentials SET sign_count = $1, last_used_at = NOW() WHERE id = $2`, signCount, id)
+	if err != nil {
+		return utils.LogError("WEBAUTHN", "UpdateUsage", err, "id", id)
+	}
+	return nil
+}
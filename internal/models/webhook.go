@@ -0,0 +1,456 @@
+package models
+
+import (
+	"auth-system/internal/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("WEBHOOK_NOT_FOUND")
+	ErrWebhookDeliveryNotFound = errors.New("WEBHOOK_DELIVERY_NOT_FOUND")
+	ErrWebhookInvalidURL       = errors.New("WEBHOOK_INVALID_URL")
+	ErrWebhookInvalidDesc      = errors.New("WEBHOOK_INVALID_DESCRIPTION")
+	ErrWebhookInvalidEvent     = errors.New("WEBHOOK_INVALID_EVENT")
+	ErrWebhookDisabled         = errors.New("WEBHOOK_DISABLED")
+)
+
+const (
+	maxWebhookURLLength         = 2048
+	maxWebhookDescriptionLength = 255
+)
+
+// Webhook 事件类型，命名为 {资源}.{动作}
+const (
+	WebhookEventUserRegistered      = "user.registered"
+	WebhookEventUserEmailChanged    = "user.email_changed"
+	WebhookEventUserUsernameChanged = "user.username_changed"
+	WebhookEventUserBanned          = "user.banned"
+	WebhookEventUserUnbanned        = "user.unbanned"
+	WebhookEventUserDeleted         = "user.deleted"
+	WebhookEventOAuthGrantRevoked   = "oauth.grant_revoked"
+)
+
+// WebhookEvents 全部可订阅的事件（展示顺序）
+var WebhookEvents = []string{
+	WebhookEventUserRegistered,
+	WebhookEventUserEmailChanged,
+	WebhookEventUserUsernameChanged,
+	WebhookEventUserBanned,
+	WebhookEventUserUnbanned,
+	WebhookEventUserDeleted,
+	WebhookEventOAuthGrantRevoked,
+}
+
+// 投递状态：pending 等待（重试）投递，succeeded / failed 为终态
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookEndpoint 管理员配置的 Webhook 端点
+type WebhookEndpoint struct {
+	ID          int64     `json:"id"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	Secret      string    `json:"-"` // 签名密钥，仅创建与重新生成时返回一次
+	Events      []string  `json:"events"`
+	IsEnabled   bool      `json:"is_enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery 一次事件投递（队列项兼投递日志）
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	EndpointID     int64           `json:"endpoint_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+
+	// 以下字段仅 ClaimDue 填充，供投递使用
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// WebhookAttempt 一次投递尝试的结果
+type WebhookAttempt struct {
+	Status         string    // 尝试后的状态
+	NextAttemptAt  time.Time // 仍为 pending 时的下次尝试时间
+	ResponseStatus *int      // 未收到响应时为 nil
+	Error          string
+}
+
+// ValidateWebhookEndpoint 校验 URL（http/https 绝对地址，不含凭据与片段，不指向本机或内网 IP）、描述长度与订阅事件。
+// 域名在此不做解析，解析结果指向内网的情况由投递时的连接检查拒绝
+func ValidateWebhookEndpoint(endpoint *WebhookEndpoint) error {
+	if len(endpoint.URL) > maxWebhookURLLength {
+		return ErrWebhookInvalidURL
+	}
+	u, err := url.Parse(endpoint.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.User != nil || u.Fragment != "" {
+		return ErrWebhookInvalidURL
+	}
+	host := u.Hostname()
+	if IsLoopbackHost(host) {
+		return ErrWebhookInvalidURL
+	}
+	if ip := net.ParseIP(host); ip != nil && utils.IsBlockedIP(ip) {
+		return ErrWebhookInvalidURL
+	}
+	if len([]rune(endpoint.Description)) > maxWebhookDescriptionLength {
+		return ErrWebhookInvalidDesc
+	}
+	if len(endpoint.Events) == 0 {
+		return ErrWebhookInvalidEvent
+	}
+	for _, event := range endpoint.Events {
+		if !slices.Contains(WebhookEvents, event) {
+			return ErrWebhookInvalidEvent
+		}
+	}
+	return nil
+}
+
+// WebhookRepository Webhook 端点与投递仓库
+type WebhookRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewWebhookRepository 创建 Webhook 仓库
+func NewWebhookRepository(pool *pgxpool.Pool) *WebhookRepository {
+	return &WebhookRepository{pool: pool}
+}
+
+const webhookEndpointColumns = `id, url, description, secret, events, is_enabled, created_at, updated_at`
+
+func scanWebhookEndpoint(row pgx.Row, endpoint *WebhookEndpoint) error {
+	return row.Scan(&endpoint.ID, &endpoint.URL, &endpoint.Description, &endpoint.Secret,
+		&endpoint.Events, &endpoint.IsEnabled, &endpoint.CreatedAt, &endpoint.UpdatedAt)
+}
+
+const webhookDeliveryColumns = `d.id, d.endpoint_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+	d.next_attempt_at, d.last_attempt_at, d.response_status, d.last_error, d.created_at`
+
+func webhookDeliveryDest(d *WebhookDelivery) []any {
+	return []any{&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.LastAttemptAt, &d.ResponseStatus, &d.LastError, &d.CreatedAt}
+}
+
+func (r *WebhookRepository) checkDB() error {
+	if r.pool == nil {
+		return ErrDBNotInitialized
+	}
+	return nil
+}
+
+// FindAll 获取全部端点（按 ID 排序）
+func (r *WebhookRepository) FindAll(ctx context.Context) ([]*WebhookEndpoint, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.pool.Query(ctx, `SELECT `+webhookEndpointColumns+` FROM webhook_endpoints ORDER BY id ASC`)
+	if err != nil {
+		return nil, utils.LogError("WEBHOOK", "FindAll", err)
+	}
+	defer rows.Close()
+
+	endpoints := make([]*WebhookEndpoint, 0)
+	for rows.Next() {
+		endpoint := &WebhookEndpoint{}
+		if err := scanWebhookEndpoint(rows, endpoint); err != nil {
+			return nil, utils.LogError("WEBHOOK", "FindAll", err)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.LogError("WEBHOOK", "FindAll", err)
+	}
+	return endpoints, nil
+}
+
+// FindByID 按 ID 查询端点
+func (r *WebhookRepository) FindByID(ctx context.Context, id int64) (*WebhookEndpoint, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	endpoint := &WebhookEndpoint{}
+	err := scanWebhookEndpoint(r.pool.QueryRow(ctx, `SELECT `+webhookEndpointColumns+` FROM webhook_endpoints WHERE id = $1`, id), endpoint)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, utils.LogError("WEBHOOK", "FindByID", err, "id", id)
+	}
+	return endpoint, nil
+}
+
+// Create 创建端点，Secret 由调用方生成
+func (r *WebhookRepository) Create(ctx context.Context, endpoint *WebhookEndpoint) error {
+	if err := ValidateWebhookEndpoint(endpoint); err != nil {
+		return err
+	}
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO webhook_endpoints (url, description, secret, events, is_enabled)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`, endpoint.URL, endpoint.Description, endpoint.Secret, endpoint.Events, endpoint.IsEnabled).Scan(
+		&endpoint.ID, &endpoint.CreatedAt, &endpoint.UpdatedAt)
+	if err != nil {
+		return utils.LogError("WEBHOOK", "Create", err, "url", endpoint.URL)
+	}
+
+	utils.LogInfo("WEBHOOK", "Endpoint created", "id", endpoint.ID, "url", endpoint.URL)
+	return nil
+}
+
+// Update 更新 URL、描述、订阅事件与启用状态（不修改 Secret）
+func (r *WebhookRepository) Update(ctx context.Context, endpoint *WebhookEndpoint) error {
+	if err := ValidateWebhookEndpoint(endpoint); err != nil {
+		return err
+	}
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+
+	err := scanWebhookEndpoint(r.pool.QueryRow(ctx, `
+		UPDATE webhook_endpoints SET url = $1, description = $2, events = $3, is_enabled = $4, updated_at = NOW()
+		WHERE id = $5
+		RETURNING `+webhookEndpointColumns,
+		endpoint.URL, endpoint.Description, endpoint.Events, endpoint.IsEnabled, endpoint.ID), endpoint)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrWebhookNotFound
+		}
+		return utils.LogError("WEBHOOK", "Update", err, "id", endpoint.ID)
+	}
+	return nil
+}
+
+// UpdateSecret 替换签名密钥，之后的投递（含重试中的）均使用新密钥签名
+func (r *WebhookRepository) UpdateSecret(ctx context.Context, id int64, secret string) error {
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+
+	tag, err := r.pool.Exec(ctx, `UPDATE webhook_endpoints SET secret = $1, updated_at = NOW() WHERE id = $2`, secret, id)
+	if err != nil {
+		return utils.LogError("WEBHOOK", "UpdateSecret", err, "id", id)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// Delete 删除端点（投递记录随外键级联删除）
+func (r *WebhookRepository) Delete(ctx context.Context, id int64) (*WebhookEndpoint, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	endpoint := &WebhookEndpoint{}
+	err := scanWebhookEndpoint(r.pool.QueryRow(ctx, `DELETE FROM webhook_endpoints WHERE id = $1 RETURNING `+webhookEndpointColumns, id), endpoint)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, utils.LogError("WEBHOOK", "Delete", err, "id", id)
+	}
+
+	utils.LogInfo("WEBHOOK", "Endpoint deleted", "id", id, "url", endpoint.URL)
+	return endpoint, nil
+}
+
+// Enqueue 为每个已启用且订阅了 eventType 的端点写入一条待投递记录，返回写入条数
+func (r *WebhookRepository) Enqueue(ctx context.Context, eventID, eventType string, payload json.RawMessage) (int64, error) {
+	if err := r.checkDB(); err != nil {
+		return 0, err
+	}
+
+	tag, err := r.pool.Exec(ctx, `
+		INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload)
+		SELECT id, $1, $2::text, $3
+		FROM webhook_endpoints
+		WHERE is_enabled AND $2::text = ANY(events)
+	`, eventID, eventType, payload)
+	if err != nil {
+		return 0, utils.LogError("WEBHOOK", "Enqueue", err, "event_id", eventID, "event_type", eventType)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ClaimDue 领取最多 limit 条已到期的待投递记录，并将其 next_attempt_at 推迟 lease 作为租约：
+// 多实例并发领取互不重复（SKIP LOCKED），领取后进程崩溃的记录在租约到期后会被重新领取。
+// 已禁用端点的记录保持 pending，重新启用后继续投递
+func (r *WebhookRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		WITH due AS (
+			SELECT d.id
+			FROM webhook_deliveries d
+			JOIN webhook_endpoints e ON e.id = d.endpoint_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND e.is_enabled
+			ORDER BY d.next_attempt_at ASC
+			LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d SET next_attempt_at = NOW() + $2::float8 * INTERVAL '1 second'
+		FROM due, webhook_endpoints e
+		WHERE d.id = due.id AND e.id = d.endpoint_id
+		RETURNING `+webhookDeliveryColumns+`, e.url, e.secret
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, utils.LogError("WEBHOOK", "ClaimDue", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*WebhookDelivery, 0)
+	for rows.Next() {
+		delivery := &WebhookDelivery{}
+		if err := rows.Scan(append(webhookDeliveryDest(delivery), &delivery.URL, &delivery.Secret)...); err != nil {
+			return nil, utils.LogError("WEBHOOK", "ClaimDue", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.LogError("WEBHOOK", "ClaimDue", err)
+	}
+	return deliveries, nil
+}
+
+// RecordAttempt 记录一次投递尝试：累加尝试次数并写入结果
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, id int64, attempt *WebhookAttempt) error {
+	if err := r.checkDB(); err != nil {
+		return err
+	}
+
+	_, err := r.pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = attempts + 1, next_attempt_at = $2, last_attempt_at = NOW(),
+			response_status = $3, last_error = $4
+		WHERE id = $5
+	`, attempt.Status, attempt.NextAttemptAt, attempt.ResponseStatus, attempt.Error, id)
+	if err != nil {
+		return utils.LogError("WEBHOOK", "RecordAttempt", err, "id", id)
+	}
+	return nil
+}
+
+// FindDeliveryByID 按 ID 查询投递记录
+func (r *WebhookRepository) FindDeliveryByID(ctx context.Context, id int64) (*WebhookDelivery, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	delivery := &WebhookDelivery{}
+	err := r.pool.QueryRow(ctx, `SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries d WHERE d.id = $1`, id).
+		Scan(webhookDeliveryDest(delivery)...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrWebhookDeliveryNotFound
+		}
+		return nil, utils.LogError("WEBHOOK", "FindDeliveryByID", err, "id", id)
+	}
+	return delivery, nil
+}
+
+// ListDeliveries 查询端点的投递记录（分页，新的在前）
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, endpointID int64, page, pageSize int) ([]*WebhookDelivery, int64, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FROM webhook_deliveries WHERE endpoint_id = $1`, endpointID).Scan(&total)
+	if err != nil {
+		return nil, 0, utils.LogError("WEBHOOK", "ListDeliveries.Count", err, "endpoint_id", endpointID)
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries d
+		WHERE d.endpoint_id = $1
+		ORDER BY d.id DESC
+		LIMIT $2 OFFSET $3
+	`, endpointID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, utils.LogError("WEBHOOK", "ListDeliveries.Query", err, "endpoint_id", endpointID)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*WebhookDelivery, 0)
+	for rows.Next() {
+		delivery := &WebhookDelivery{}
+		if err := rows.Scan(webhookDeliveryDest(delivery)...); err != nil {
+			return nil, 0, utils.LogError("WEBHOOK", "ListDeliveries.Scan", err, "endpoint_id", endpointID)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, utils.LogError("WEBHOOK", "ListDeliveries.Rows", err, "endpoint_id", endpointID)
+	}
+	return deliveries, total, nil
+}
+
+// Redeliver 复制一条投递记录为新的待投递记录（沿用原 event_id 与 payload，便于接收方去重），原记录保留在日志中
+func (r *WebhookRepository) Redeliver(ctx context.Context, id int64) (*WebhookDelivery, error) {
+	if err := r.checkDB(); err != nil {
+		return nil, err
+	}
+
+	delivery := &WebhookDelivery{}
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO webhook_deliveries AS d (endpoint_id, event_id, event_type, payload)
+		SELECT endpoint_id, event_id, event_type, payload FROM webhook_deliveries WHERE id = $1
+		RETURNING `+webhookDeliveryColumns, id).Scan(webhookDeliveryDest(delivery)...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrWebhookDeliveryNotFound
+		}
+		return nil, utils.LogError("WEBHOOK", "Redeliver", err, "id", id)
+	}
+
+	utils.LogInfo("WEBHOOK", "Delivery requeued", "source_id", id, "id", delivery.ID, "event_id", delivery.EventID)
+	return delivery, nil
+}
+
+// DeleteFinishedBefore 删除 before 之前创建且已结束（成功或放弃）的投递记录
+func (r *WebhookRepository) DeleteFinishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	if err := r.checkDB(); err != nil {
+		return 0, err
+	}
+
+	tag, err := r.pool.Exec(ctx, `
+		DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < $1
+	`, before)
+	if err != nil {
+		return 0, utils.LogError("WEBHOOK", "DeleteFinishedBefore", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -24,6 +24,9 @@ const (
 	PathAccountDashboard = "/account/dashboard"
 	PathAccountLink      = "/account/link"
 	PathAccountOAuth     = "/account/oauth"
+	PathAccountDevice    = "/account/device"
+	PathAccountSecure    = "/account/secure"
+	PathAccountUnlock    = "/account/unlock"
 
 	AliasPathLogin     = "/login"
 	AliasPathRegister  = "/register"
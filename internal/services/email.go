@@ -2,26 +2,20 @@ package services
 
 import (
 	"auth-system/internal/utils"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"html"
 	"net/url"
-	"os"
 	"strings"
 	"sync"
 	"time"
 
-	"auth-system/internal/config"
-
-	"github.com/wneessen/go-mail"
+	"auth-system/internal/models"
 )
 
 var (
 	ErrEmailNilConfig          = errors.New("email config is nil")
-	ErrEmailTemplateNotFound   = errors.New("email template not found")
-	ErrEmailTextsNotFound      = errors.New("email texts not found")
-	ErrEmailInvalidTexts       = errors.New("invalid email texts format")
+	ErrEmailNilTransport       = errors.New("email transport is nil")
 	ErrEmailEmptyRecipient     = errors.New("email recipient is empty")
 	ErrEmailEmptySubject       = errors.New("email subject is empty")
 	ErrEmailSMTPConfigMissing  = errors.New("SMTP configuration is missing")
@@ -32,556 +26,506 @@ var (
 )
 
 const (
-	defaultLanguage   = "zh-CN"
-	defaultEmailType  = "register"
-	smtpTimeout       = 15 * time.Second
-	smtpPort465       = 465
-	smtpPort587       = 587
-	templatePath      = "dist/data/email-template.html"
-	textsPath         = "dist/data/email-texts.json"
-	connMaxIdleTime   = 5 * time.Minute
-	connCheckInterval = 30 * time.Second
+	defaultLanguage  = "zh-CN"
+	defaultEmailType = "register"
+
+	emailVerifyTimeout     = 15 * time.Second
+	emailSendTimeout       = 20 * time.Second
+	emailQueuePollInterval = 10 * time.Second
+	emailQueueBatchSize    = 10
+	emailQueueLease        = 5 * time.Minute // 须大于 emailQueueBatchSize * emailSendTimeout（逐封发送），避免发送中的邮件被重复领取
+	emailQueueRunTimeout   = 5 * time.Minute
+	emailMaxAttempts       = 10
+	emailRetryBaseDelay    = 15 * time.Second
+	emailRetryMaxDelay     = time.Hour
+	emailRetention         = 7 * 24 * time.Hour
+	emailMaxErrorLength    = 500
 )
 
-// templatePlaceholders 模板占位符列表
-var templatePlaceholders = []string{
-	"{{PAGE_TITLE}}",
-	"{{GREETING}}",
-	"{{DESCRIPTION}}",
-	"{{VERIFY_URL}}",
-	"{{BUTTON_TEXT}}",
-	"{{LINK_HINT}}",
-	"{{EXPIRE_NOTICE}}",
-	"{{SECURITY_TIP}}",
-	"{{FOOTER}}",
-}
+// EmailService 邮件服务：渲染邮件后写入持久化队列，由后台协程经发送后端（EmailTransport）发出，
+// 失败按指数退避重试，后端永久拒收记为 bounced，重试耗尽或验证链接过期记为 failed。
+// queue 为 nil 时退化为直接异步发送（不持久化）。
+// 含一次性链接的邮件正文以 bodyKey 加密入队；bodyKey 为 nil 时这类邮件不入队，直接异步发送
+type EmailService struct {
+	templates *EmailTemplateRegistry
 
-// EmailTexts 邮件文案
-// 结构：language -> section -> key -> value
-type EmailTexts map[string]map[string]map[string]string
+	transport EmailTransport
+	queue     models.EmailQueueStore
+	bodyKey   []byte
 
-// EmailService 邮件服务
-type EmailService struct {
-	cfg      *config.Config
-	template string
-	texts    EmailTexts
-	mu       sync.RWMutex
-
-	// 连接池相关
-	client     *mail.Client
-	clientMu   sync.Mutex
-	lastUsed   time.Time
-	stopKeeper chan struct{}
-
-	// 异步发送追踪
+	// 队列发送协程：wake 在入队后立即唤醒，stop 通知退出
+	wake      chan struct{}
+	stop      chan struct{}
+	worker    sync.WaitGroup
+	closeOnce sync.Once
+
+	// 直接异步发送追踪（无队列或入队失败时）
 	wg sync.WaitGroup
 }
 
-// NewEmailService 创建邮件服务
-func NewEmailService(cfg *config.Config) (*EmailService, error) {
-	if cfg == nil {
-		return nil, ErrEmailNilConfig
+// NewEmailService 创建邮件服务并启动队列发送协程，queue 与 encryptionKey 为可选参数
+func NewEmailService(transport EmailTransport, queue models.EmailQueueStore, encryptionKey string) (*EmailService, error) {
+	if transport == nil {
+		return nil, ErrEmailNilTransport
 	}
 
-	if err := validateSMTPConfig(cfg); err != nil {
-		return nil, err
-	}
-
-	template, err := loadTemplate(templatePath)
+	templates, err := LoadEmailTemplates(emailTemplatesDir, emailTextsPath)
 	if err != nil {
 		return nil, err
 	}
 
-	texts, err := loadTexts(textsPath)
-	if err != nil {
-		return nil, err
+	var bodyKey []byte
+	if encryptionKey != "" {
+		bodyKey, err = utils.DeriveKeyFromString(encryptionKey, "nebula-email-body")
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive email body key: %w", err)
+		}
 	}
 
-	if err := validateTemplateAndTexts(template, texts); err != nil {
-		utils.LogWarn("EMAIL", "Template validation warning", "error", err)
-		// 不返回错误，只记录警告
+	service := newEmailService(transport, queue, templates, bodyKey)
+	if queue != nil {
+		service.worker.Go(service.runQueue)
 	}
 
-	utils.LogInfo("EMAIL", "Email service initialized", "host", cfg.SMTPHost, "port", cfg.SMTPPort, "from", cfg.SMTPFrom)
+	utils.LogInfo("EMAIL", "Email service initialized", "transport", transport.Name(), "queue", queue != nil, "body_encryption", bodyKey != nil)
+	return service, nil
+}
 
-	service := &EmailService{
-		cfg:        cfg,
-		template:   template,
-		texts:      texts,
-		stopKeeper: make(chan struct{}),
+func newEmailService(transport EmailTransport, queue models.EmailQueueStore, templates *EmailTemplateRegistry, bodyKey []byte) *EmailService {
+	return &EmailService{
+		templates: templates,
+		transport: transport,
+		queue:     queue,
+		bodyKey:   bodyKey,
+		wake:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
 	}
-
-	go service.connectionKeeper()
-
-	return service, nil
 }
 
-// VerifyConnection 验证 SMTP 连接
+// VerifyConnection 验证发送后端连接
 func (s *EmailService) VerifyConnection() error {
 	if s == nil {
 		return errors.New("email service is nil")
 	}
 
-	client, err := s.createClient()
-	if err != nil {
-		utils.LogError("EMAIL", "VerifyConnection", err, "SMTP connection verification failed")
-		return fmt.Errorf("SMTP connection failed: %w", err)
+	ctx, cancel := context.WithTimeout(context.Background(), emailVerifyTimeout)
+	defer cancel()
+
+	if err := s.transport.Verify(ctx); err != nil {
+		utils.LogError("EMAIL", "VerifyConnection", err, "transport", s.transport.Name())
+		return err
 	}
-	defer func() {
-		if err := client.Close(); err != nil {
-			utils.LogWarn("EMAIL", "Failed to close SMTP client")
-		}
-	}()
 
-	utils.LogInfo("EMAIL", "SMTP connection verified successfully")
+	utils.LogInfo("EMAIL", "Email transport verified successfully", "transport", s.transport.Name())
 	return nil
 }
 
-// SendVerificationEmailAsync 异步发送验证邮件（不阻塞调用方）
+// SendVerificationEmailAsync 渲染验证邮件并写入发送队列（不等待发送）。
+// 验证链接与验证码同时过期，过期后仍未发出的邮件不再发送
 func (s *EmailService) SendVerificationEmailAsync(to, emailType, language, verifyURL, logContext string) {
-	s.wg.Add(1)
-	go func() {
-		defer s.wg.Done()
-		defer func() {
-			if r := recover(); r != nil {
-				utils.LogError(logContext, "SendVerificationEmailAsync",
-					fmt.Errorf("panic: %v", r), "to", to, "type", emailType)
-			}
-		}()
-		if err := s.SendVerificationEmail(to, emailType, language, verifyURL); err != nil {
-			utils.LogError(logContext, "SendVerificationEmailAsync", err, "to", to, "type", emailType)
-		}
-	}()
-}
-
-// SendVerificationEmail 发送验证邮件（同步）
-func (s *EmailService) SendVerificationEmail(to, emailType, language, verifyURL string) error {
-	if to == "" {
-		return ErrEmailEmptyRecipient
-	}
-	if verifyURL == "" {
-		return errors.New("verify URL is empty")
-	}
-	// 校验 verifyURL scheme 必须为 http/https，防止 javascript:/data: 等注入
-	parsedURL, err := url.Parse(verifyURL)
-	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
-		return ErrEmailInvalidVerifyURL
+	email, err := s.renderVerificationEmail(to, emailType, language, verifyURL)
+	if err != nil {
+		utils.LogError(logContext, "SendVerificationEmailAsync", err, "to", to, "type", emailType)
+		return
 	}
 
-	s.mu.RLock()
-	langTexts := s.getLanguageTexts(language)
-	s.mu.RUnlock()
-
-	common := langTexts["common"]
-	typeTexts := s.getTypeTexts(langTexts, emailType)
+	expiresAt := time.Now().Add(tokenExpiry)
+	s.sendAsync(email, emailType, &expiresAt, true, logContext)
+}
 
-	if err := validateTexts(common, typeTexts); err != nil {
-		utils.LogWarn("EMAIL", "Missing texts, using default language", "type", emailType, "language", language)
-		s.mu.RLock()
-		langTexts = s.texts[defaultLanguage]
-		s.mu.RUnlock()
-		if langTexts != nil {
-			common = langTexts["common"]
-			typeTexts = s.getTypeTexts(langTexts, emailType)
-		}
+// SendVerificationEmail 发送验证邮件（同步，不经过队列）
+func (s *EmailService) SendVerificationEmail(to, emailType, language, verifyURL string) error {
+	email, err := s.renderVerificationEmail(to, emailType, language, verifyURL)
+	if err != nil {
+		return err
 	}
 
-	html := s.renderTemplate(common, typeTexts, verifyURL)
-
-	textBody := s.renderTextBody(common, verifyURL)
+	ctx, cancel := context.WithTimeout(context.Background(), emailSendTimeout)
+	defer cancel()
 
-	subject := typeTexts["subject"]
-	if subject == "" {
-		subject = "Verification Email"
-		utils.LogWarn("EMAIL", "Missing subject, using default", "type", emailType)
-	}
-
-	if err := s.sendEmail(to, subject, html, textBody); err != nil {
+	if err := s.transport.Send(ctx, email); err != nil {
 		return fmt.Errorf("%w: %v", ErrEmailSendFailed, err)
 	}
 
+	utils.LogInfo("EMAIL", "Email sent successfully", "to", to, "subject", email.Subject)
 	return nil
 }
 
-// IsConfigured 检查邮件服务是否已配置
-func (s *EmailService) IsConfigured() bool {
-	if s == nil || s.cfg == nil {
-		return false
+// SendNewLoginAlert 发送新设备登录提醒，含"这不是我"链接时按含链接邮件处理
+func (s *EmailService) SendNewLoginAlert(to, language string, data *NewLoginEmailData) {
+	email, err := s.render(to, EmailTypeNewLogin, language, data)
+	if err != nil {
+		utils.LogError("EMAIL", "SendNewLoginAlert", err, "to", to)
+		return
 	}
-	return s.cfg.SMTPHost != "" && s.cfg.SMTPUser != "" && s.cfg.SMTPPassword != ""
+	s.sendAsync(email, EmailTypeNewLogin, nil, data.SecureURL != "", "EMAIL")
 }
 
-// getLanguageTexts 获取指定语言的文案
-func (s *EmailService) getLanguageTexts(language string) map[string]map[string]string {
-	if language == "" {
-		language = defaultLanguage
-	}
-
-	langTexts, ok := s.texts[language]
-	if !ok {
-		utils.LogWarn("EMAIL", "Language not found, using default", "language", language, "default", defaultLanguage)
-		langTexts = s.texts[defaultLanguage]
-	}
-
-	if langTexts == nil {
-		utils.LogError("EMAIL", "getTexts", fmt.Errorf("default language texts not found"))
-		return make(map[string]map[string]string)
-	}
-
-	return langTexts
+// SendPasswordChangedNotice 发送密码已修改通知
+func (s *EmailService) SendPasswordChangedNotice(to, language string, data *PasswordChangedEmailData) {
+	s.sendNotice(to, EmailTypePasswordChanged, language, data)
 }
 
-// getTypeTexts 获取指定类型的文案
-func (s *EmailService) getTypeTexts(langTexts map[string]map[string]string, emailType string) map[string]string {
-	if emailType == "" {
-		emailType = defaultEmailType
-	}
-
-	typeTexts, ok := langTexts[emailType]
-	if !ok {
-		utils.LogWarn("EMAIL", "Email type not found, using default", "type", emailType, "default", defaultEmailType)
-		typeTexts = langTexts[defaultEmailType]
-	}
-
-	if typeTexts == nil {
-		return make(map[string]string)
-	}
-
-	return typeTexts
+// SendAccountBannedNotice 发送账户封禁通知
+func (s *EmailService) SendAccountBannedNotice(to, language string, data *AccountBannedEmailData) {
+	s.sendNotice(to, EmailTypeAccountBanned, language, data)
 }
 
-// renderTemplate 渲染邮件模板
-func (s *EmailService) renderTemplate(common, typeTexts map[string]string, verifyURL string) string {
-	out := s.template
-	// HTML 上下文转义 verifyURL，防止 & 等字符破坏 HTML 结构（defense in depth）
-	escapedVerifyURL := html.EscapeString(verifyURL)
-
-	out = strings.ReplaceAll(out, "{{PAGE_TITLE}}", safeGet(typeTexts, "pageTitle", "Verification"))
-	out = strings.ReplaceAll(out, "{{DESCRIPTION}}", safeGet(typeTexts, "description", ""))
-
-	out = strings.ReplaceAll(out, "{{GREETING}}", safeGet(common, "greeting", "Hello"))
-	out = strings.ReplaceAll(out, "{{VERIFY_URL}}", escapedVerifyURL)
-	out = strings.ReplaceAll(out, "{{BUTTON_TEXT}}", safeGet(common, "buttonText", "Verify"))
-	out = strings.ReplaceAll(out, "{{LINK_HINT}}", safeGet(common, "linkHint", ""))
-	out = strings.ReplaceAll(out, "{{EXPIRE_NOTICE}}", safeGet(common, "expireNotice", ""))
-	out = strings.ReplaceAll(out, "{{SECURITY_TIP}}", safeGet(common, "securityTip", ""))
-	out = strings.ReplaceAll(out, "{{FOOTER}}", safeGet(common, "footer", ""))
-
-	return out
+// SendOAuthAuthorizedNotice 发送应用授权通知
+func (s *EmailService) SendOAuthAuthorizedNotice(to, language string, data *OAuthAuthorizedEmailData) {
+	s.sendNotice(to, EmailTypeOAuthAuthorized, language, data)
 }
 
-// renderTextBody 渲染纯文本邮件内容
-func (s *EmailService) renderTextBody(common map[string]string, verifyURL string) string {
-	textBody := safeGet(common, "textBody", "Please verify your email: {{VERIFY_URL}}")
-	return strings.ReplaceAll(textBody, "{{VERIFY_URL}}", verifyURL)
+// SendPasskeyAddedNotice 发送新增 Passkey 通知
+func (s *EmailService) SendPasskeyAddedNotice(to, language string, data *PasskeyAddedEmailData) {
+	s.sendNotice(to, EmailTypePasskeyAdded, language, data)
 }
 
-// sendEmail 发送邮件
-func (s *EmailService) sendEmail(to, subject, htmlBody, textBody string) error {
-	if to == "" {
-		return ErrEmailEmptyRecipient
-	}
-	if subject == "" {
-		return ErrEmailEmptySubject
-	}
-
-	// 校验 CRLF 防止邮件头部注入：to/subject/from 含换行符可注入 Bcc/Reply-To 等头
-	if containsNewline(to) || containsNewline(subject) || containsNewline(s.cfg.SMTPFrom) {
-		utils.LogError("EMAIL", "send", ErrEmailHeaderInjection, "reason", "CRLF detected in email headers", "to", to, "subject", subject)
-		return ErrEmailHeaderInjection
+// SendExportReadyNotice 发送数据导出就绪通知，下载链接过期后不再发送
+func (s *EmailService) SendExportReadyNotice(to, language string, data *ExportReadyEmailData) {
+	email, err := s.render(to, EmailTypeExportReady, language, data)
+	if err != nil {
+		utils.LogError("EMAIL", "SendExportReadyNotice", err, "to", to)
+		return
 	}
+	s.sendAsync(email, EmailTypeExportReady, &data.ExpiresAt, true, "EMAIL")
+}
 
-	client, err := s.getClient()
+// SendAccountLockedNotice 发送账户锁定通知（含解锁链接），锁定到期后不再发送
+func (s *EmailService) SendAccountLockedNotice(to, language string, data *AccountLockedEmailData) {
+	email, err := s.render(to, EmailTypeAccountLocked, language, data)
 	if err != nil {
-		return err
+		utils.LogError("EMAIL", "SendAccountLockedNotice", err, "to", to)
+		return
 	}
+	s.sendAsync(email, EmailTypeAccountLocked, &data.LockedUntil, true, "EMAIL")
+}
 
-	msg := mail.NewMsg()
-
-	if err := msg.From(s.cfg.SMTPFrom); err != nil {
-		utils.LogError("EMAIL", "send", err, "Failed to set from address")
-		return fmt.Errorf("failed to set from address: %w", err)
+// sendNotice 渲染通知类邮件并写入发送队列（不过期）
+func (s *EmailService) sendNotice(to, emailType, language string, data any) {
+	email, err := s.render(to, emailType, language, data)
+	if err != nil {
+		utils.LogError("EMAIL", "sendNotice", err, "to", to, "type", emailType)
+		return
 	}
+	s.sendAsync(email, emailType, nil, false, "EMAIL")
+}
 
-	if err := msg.To(to); err != nil {
-		utils.LogError("EMAIL", "send", err, "Failed to set to address")
-		return fmt.Errorf("failed to set to address: %w", err)
+// sendAsync 将已渲染的邮件写入发送队列并唤醒发送协程；未启用队列或入队失败时退化为直接异步发送。
+// hasLink 表示正文含一次性链接（重置密码、解锁等）：正文加密入队，未配置加密密钥时不入队
+func (s *EmailService) sendAsync(email *OutboundEmail, emailType string, expiresAt *time.Time, hasLink bool, logContext string) {
+	if s.queue != nil && (!hasLink || s.bodyKey != nil) {
+		err := s.enqueue(email, emailType, expiresAt, hasLink)
+		if err == nil {
+			s.notify()
+			return
+		}
+		utils.LogWarn(logContext, "Email enqueue failed, sending directly", "to", email.To, "type", emailType, "error", err)
 	}
 
-	msg.Subject(subject)
-	msg.SetBodyString(mail.TypeTextPlain, textBody)
-	msg.AddAlternativeString(mail.TypeTextHTML, htmlBody)
+	s.wg.Go(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				utils.LogError(logContext, "sendAsync",
+					fmt.Errorf("panic: %v", r), "to", email.To, "type", emailType)
+			}
+		}()
 
-	if err := client.DialAndSend(msg); err != nil {
-		utils.LogError("EMAIL", "send", err, "to", to, "subject", subject)
-		// 发送失败，重置连接（下次会重新建立）
-		s.resetClient()
-		return fmt.Errorf("failed to send email: %w", err)
-	}
+		ctx, cancel := context.WithTimeout(context.Background(), emailSendTimeout)
+		defer cancel()
 
-	utils.LogInfo("EMAIL", "Email sent successfully", "to", to, "subject", subject)
-	return nil
+		if err := s.transport.Send(ctx, email); err != nil {
+			utils.LogError(logContext, "sendAsync", err, "to", email.To, "type", emailType)
+			return
+		}
+		utils.LogInfo("EMAIL", "Email sent successfully", "to", email.To, "subject", email.Subject)
+	})
 }
 
-// createClient 创建 SMTP 客户端
-func (s *EmailService) createClient() (*mail.Client, error) {
-	if s.cfg == nil {
-		return nil, ErrEmailNilConfig
+// enqueue 写入发送队列，含链接的邮件正文先加密
+func (s *EmailService) enqueue(email *OutboundEmail, emailType string, expiresAt *time.Time, hasLink bool) error {
+	msg := &models.EmailMessage{
+		Recipient: email.To,
+		EmailType: emailType,
+		Subject:   email.Subject,
+		HTMLBody:  email.HTMLBody,
+		TextBody:  email.TextBody,
+		ExpiresAt: expiresAt,
+	}
+	if hasLink {
+		var err error
+		if msg.HTMLBody, err = s.sealBody(email.HTMLBody); err != nil {
+			return err
+		}
+		if msg.TextBody, err = s.sealBody(email.TextBody); err != nil {
+			return err
+		}
+		msg.BodyEncrypted = true
 	}
 
-	var tlsPolicy mail.TLSPolicy
-	var authType mail.SMTPAuthType
-	var useSSL bool
+	ctx, cancel := context.WithTimeout(context.Background(), emailVerifyTimeout)
+	defer cancel()
+	return s.queue.Enqueue(ctx, msg)
+}
 
-	switch s.cfg.SMTPPort {
-	case smtpPort465:
-		tlsPolicy = mail.TLSMandatory
-		authType = mail.SMTPAuthLogin // 网易邮箱需要 LOGIN 认证
-		useSSL = true
-	case smtpPort587:
-		tlsPolicy = mail.TLSOpportunistic
-		authType = mail.SMTPAuthPlain
-		useSSL = false
-	default:
-		// 其他端口默认使用 STARTTLS
-		tlsPolicy = mail.TLSOpportunistic
-		authType = mail.SMTPAuthPlain
-		useSSL = false
-		utils.LogWarn("EMAIL", "Non-standard SMTP port, using STARTTLS", "port", s.cfg.SMTPPort)
+// sealBody 加密邮件正文，空正文原样保留
+func (s *EmailService) sealBody(body string) (string, error) {
+	if body == "" {
+		return "", nil
 	}
+	return utils.EncryptAESGCM([]byte(body), s.bodyKey)
+}
 
-	options := []mail.Option{
-		mail.WithPort(s.cfg.SMTPPort),
-		mail.WithSMTPAuth(authType),
-		mail.WithUsername(s.cfg.SMTPUser),
-		mail.WithPassword(s.cfg.SMTPPassword),
-		mail.WithTLSPortPolicy(tlsPolicy),
-		mail.WithTimeout(smtpTimeout),
+// openBody 解密邮件正文，空正文原样返回
+func (s *EmailService) openBody(body string) (string, error) {
+	if body == "" {
+		return "", nil
+	}
+	if s.bodyKey == nil {
+		return "", errors.New("email body key not configured")
+	}
+	plaintext, err := utils.DecryptAESGCM(body, s.bodyKey)
+	if err != nil {
+		return "", err
 	}
+	return string(plaintext), nil
+}
 
-	if useSSL {
-		options = append(options, mail.WithSSL())
+// renderVerificationEmail 按语言与类型渲染验证邮件，未知类型按注册验证邮件渲染
+func (s *EmailService) renderVerificationEmail(to, emailType, language, verifyURL string) (*OutboundEmail, error) {
+	if verifyURL == "" {
+		return nil, errors.New("verify URL is empty")
+	}
+	// 校验 verifyURL scheme 必须为 http/https，防止 javascript:/data: 等注入
+	parsedURL, err := url.Parse(verifyURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		return nil, ErrEmailInvalidVerifyURL
 	}
 
-	client, err := mail.NewClient(s.cfg.SMTPHost, options...)
-	if err != nil {
-		utils.LogError("EMAIL", "createClient", err, "host", s.cfg.SMTPHost, "port", s.cfg.SMTPPort)
-		return nil, fmt.Errorf("%w: %v", ErrEmailClientCreateFailed, err)
+	if spec, ok := emailTemplateCatalog[emailType]; !ok || spec.template != emailVerifyTemplate {
+		utils.LogWarn("EMAIL", "Email type not found, using default", "type", emailType, "default", defaultEmailType)
+		emailType = defaultEmailType
 	}
 
-	return client, nil
+	return s.render(to, emailType, language, &VerifyEmailData{VerifyURL: verifyURL})
 }
 
-// getClient 获取或创建 SMTP 客户端（连接池）
-func (s *EmailService) getClient() (*mail.Client, error) {
-	s.clientMu.Lock()
-	defer s.clientMu.Unlock()
-
-	if s.client != nil {
-		s.lastUsed = time.Now()
-		return s.client, nil
+// render 渲染一封邮件
+func (s *EmailService) render(to, emailType, language string, data any) (*OutboundEmail, error) {
+	if to == "" {
+		return nil, ErrEmailEmptyRecipient
 	}
 
-	client, err := s.createClient()
+	rendered, err := s.templates.Render(emailType, language, data)
 	if err != nil {
 		return nil, err
 	}
 
-	s.client = client
-	s.lastUsed = time.Now()
-	utils.LogInfo("EMAIL", "SMTP connection established (pooled)")
-
-	return s.client, nil
+	return &OutboundEmail{
+		To:       to,
+		Subject:  rendered.Subject,
+		HTMLBody: rendered.HTMLBody,
+		TextBody: rendered.TextBody,
+	}, nil
 }
 
-// containsNewline 检查字符串是否含 CR/LF，用于防止邮件头部注入
-func containsNewline(s string) bool {
-	return strings.ContainsAny(s, "\r\n")
+// EmailTemplateTypes 全部可预览的邮件类型
+func (s *EmailService) EmailTemplateTypes() []string {
+	return s.templates.Types()
 }
 
-// closeClient 关闭当前连接
-func (s *EmailService) closeClient() {
-	s.clientMu.Lock()
-	defer s.clientMu.Unlock()
+// EmailLanguages 邮件文案支持的语言
+func (s *EmailService) EmailLanguages() []string {
+	return s.templates.Languages()
+}
 
-	if s.client != nil {
-		if err := s.client.Close(); err != nil {
-			utils.LogWarn("EMAIL", "Failed to close SMTP client")
-		}
-		s.client = nil
-		utils.LogInfo("EMAIL", "SMTP connection closed")
-	}
+// PreviewEmail 用示例数据渲染指定类型与语言的邮件
+func (s *EmailService) PreviewEmail(emailType, language string) (*RenderedEmail, error) {
+	return s.templates.Preview(emailType, language)
 }
 
-// resetClient 重置连接（发送失败时调用）
-func (s *EmailService) resetClient() {
-	s.clientMu.Lock()
-	defer s.clientMu.Unlock()
+// IsConfigured 检查邮件服务是否已配置发送后端
+func (s *EmailService) IsConfigured() bool {
+	return s != nil && s.transport != nil
+}
 
-	if s.client != nil {
-		_ = s.client.Close()
-		s.client = nil
+// notify 唤醒队列发送协程（非阻塞，已有待处理的唤醒时合并）
+func (s *EmailService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
 	}
 }
 
-// connectionKeeper 连接保活协程
-// 定期检查连接状态，关闭空闲过久的连接
-func (s *EmailService) connectionKeeper() {
-	ticker := time.NewTicker(connCheckInterval)
+// runQueue 队列发送协程：入队唤醒或定时轮询时领取到期邮件，直到队列中没有到期邮件。
+// 多实例同时运行时由数据库行锁保证每封邮件只被一个实例领取
+func (s *EmailService) runQueue() {
+	ticker := time.NewTicker(emailQueuePollInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-s.stop:
+			return
 		case <-ticker.C:
-			s.clientMu.Lock()
-			if s.client != nil && time.Since(s.lastUsed) > connMaxIdleTime {
-				_ = s.client.Close()
-				s.client = nil
-				utils.LogInfo("EMAIL", "SMTP connection closed due to idle timeout")
+		case <-s.wake:
+		}
+
+		for {
+			count, err := s.deliverDueSafe()
+			if err != nil {
+				utils.LogError("EMAIL", "DeliverDue", err)
+			}
+			if err != nil || count < emailQueueBatchSize {
+				break
 			}
-			s.clientMu.Unlock()
-		case <-s.stopKeeper:
-			s.closeClient()
-			return
 		}
 	}
 }
 
-// Close 关闭邮件服务
-func (s *EmailService) Close() {
-	if s.stopKeeper != nil {
-		close(s.stopKeeper)
-	}
-
-	done := make(chan struct{})
-	go func() {
-		s.wg.Wait()
-		close(done)
+// deliverDueSafe 执行一批发送，recover 防止单封邮件的 panic 终止发送协程
+func (s *EmailService) deliverDueSafe() (count int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
 	}()
 
-	select {
-	case <-done:
-		utils.LogInfo("EMAIL", "All inflight email sends completed")
-	case <-time.After(30 * time.Second):
-		utils.LogWarn("EMAIL", "Timed out waiting for inflight email sends")
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), emailQueueRunTimeout)
+	defer cancel()
+	return s.DeliverDue(ctx)
 }
 
-// validateSMTPConfig 验证 SMTP 配置
-func validateSMTPConfig(cfg *config.Config) error {
-	if cfg.SMTPHost == "" {
-		return fmt.Errorf("%w: SMTP host is empty", ErrEmailSMTPConfigMissing)
-	}
-	if cfg.SMTPPort == 0 {
-		return fmt.Errorf("%w: SMTP port is 0", ErrEmailSMTPConfigMissing)
-	}
-	if cfg.SMTPUser == "" {
-		return fmt.Errorf("%w: SMTP user is empty", ErrEmailSMTPConfigMissing)
-	}
-	if cfg.SMTPPassword == "" {
-		return fmt.Errorf("%w: SMTP password is empty", ErrEmailSMTPConfigMissing)
-	}
-	if cfg.SMTPFrom == "" {
-		return fmt.Errorf("%w: SMTP from address is empty", ErrEmailSMTPConfigMissing)
+// DeliverDue 领取一批到期的邮件并逐封发送，返回本批处理的条数
+func (s *EmailService) DeliverDue(ctx context.Context) (int, error) {
+	if s.queue == nil {
+		return 0, nil
 	}
-	return nil
-}
 
-// loadTemplate 加载邮件模板
-func loadTemplate(path string) (string, error) {
-	templateBytes, err := os.ReadFile(path)
+	messages, err := s.queue.ClaimDue(ctx, emailQueueBatchSize, emailQueueLease)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return "", fmt.Errorf("%w: %s", ErrEmailTemplateNotFound, path)
-		}
-		return "", fmt.Errorf("failed to read email template: %w", err)
+		return 0, err
 	}
 
-	template := string(templateBytes)
-	if template == "" {
-		return "", fmt.Errorf("%w: template is empty", ErrEmailTemplateNotFound)
+	for _, msg := range messages {
+		attempt := s.deliver(ctx, msg)
+		if err := s.queue.RecordAttempt(ctx, msg.ID, attempt); err != nil {
+			utils.LogWarn("EMAIL", "Failed to record email attempt", "id", msg.ID, "error", err)
+		}
 	}
-
-	utils.LogInfo("EMAIL", "Email template loaded", "path", path, "bytes", len(templateBytes))
-	return template, nil
+	return len(messages), nil
 }
 
-// loadTexts 加载多语言文案
-func loadTexts(path string) (EmailTexts, error) {
-	textsBytes, err := os.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("%w: %s", ErrEmailTextsNotFound, path)
+// deliver 发送一封队列中的邮件：成功记为 sent，永久拒收记为 bounced，
+// 其余失败按已尝试次数决定重试时间，重试耗尽或已过期记为 failed
+func (s *EmailService) deliver(ctx context.Context, msg *models.EmailMessage) *models.EmailAttempt {
+	attempts := msg.Attempts + 1
+	now := time.Now()
+	attempt := &models.EmailAttempt{NextAttemptAt: now, Transport: s.transport.Name()}
+
+	if msg.ExpiresAt != nil && now.After(*msg.ExpiresAt) {
+		attempt.Status = models.EmailStatusFailed
+		attempt.Error = "expired before delivery"
+		utils.LogWarn("EMAIL", "Email expired before delivery", "id", msg.ID, "to", msg.Recipient, "type", msg.EmailType)
+		return attempt
+	}
+
+	email := &OutboundEmail{
+		To:       msg.Recipient,
+		Subject:  msg.Subject,
+		HTMLBody: msg.HTMLBody,
+		TextBody: msg.TextBody,
+	}
+	if msg.BodyEncrypted {
+		html, htmlErr := s.openBody(msg.HTMLBody)
+		text, textErr := s.openBody(msg.TextBody)
+		if err := errors.Join(htmlErr, textErr); err != nil {
+			// 密钥缺失或已轮换，重试也无法解密
+			attempt.Status = models.EmailStatusFailed
+			attempt.Error = "cannot decrypt email body"
+			utils.LogWarn("EMAIL", "Email body decryption failed", "id", msg.ID, "to", msg.Recipient, "type", msg.EmailType, "error", err)
+			return attempt
 		}
-		return nil, fmt.Errorf("failed to read email texts: %w", err)
-	}
-
-	var texts EmailTexts
-	if err := json.Unmarshal(textsBytes, &texts); err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrEmailInvalidTexts, err)
-	}
-
-	if len(texts) == 0 {
-		return nil, fmt.Errorf("%w: texts is empty", ErrEmailInvalidTexts)
+		email.HTMLBody, email.TextBody = html, text
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, emailSendTimeout)
+	defer cancel()
+
+	err := s.transport.Send(sendCtx, email)
+	switch {
+	case err == nil:
+		attempt.Status = models.EmailStatusSent
+		utils.LogInfo("EMAIL", "Email sent successfully", "id", msg.ID, "to", msg.Recipient, "type", msg.EmailType, "transport", attempt.Transport)
+	case IsPermanentEmailError(err):
+		attempt.Status = models.EmailStatusBounced
+		attempt.Error = truncateEmailError(err.Error())
+		utils.LogWarn("EMAIL", "Email bounced", "id", msg.ID, "to", msg.Recipient, "type", msg.EmailType, "error", err)
+	case attempts >= emailMaxAttempts:
+		attempt.Status = models.EmailStatusFailed
+		attempt.Error = truncateEmailError(err.Error())
+		utils.LogWarn("EMAIL", "Email failed permanently", "id", msg.ID, "to", msg.Recipient, "attempts", attempts, "error", err)
+	default:
+		attempt.Status = models.EmailStatusPending
+		attempt.NextAttemptAt = now.Add(emailRetryDelay(attempts))
+		attempt.Error = truncateEmailError(err.Error())
+		utils.LogWarn("EMAIL", "Email send failed, will retry", "id", msg.ID, "to", msg.Recipient, "attempts", attempts, "next_attempt_at", attempt.NextAttemptAt, "error", err)
 	}
-
-	utils.LogInfo("EMAIL", "Email texts loaded", "path", path, "languages", len(texts))
-	return texts, nil
+	return attempt
 }
 
-// validateTemplateAndTexts 验证模板和文案
-func validateTemplateAndTexts(template string, texts EmailTexts) error {
-	var missingPlaceholders []string
-	for _, placeholder := range templatePlaceholders {
-		if !strings.Contains(template, placeholder) {
-			missingPlaceholders = append(missingPlaceholders, placeholder)
-		}
+// QueueStats 获取邮件队列统计；未启用队列时返回 nil
+func (s *EmailService) QueueStats(ctx context.Context) (*models.EmailQueueStats, error) {
+	if s.queue == nil {
+		return nil, nil
 	}
+	return s.queue.GetStats(ctx)
+}
 
-	if len(missingPlaceholders) > 0 {
-		return fmt.Errorf("template missing placeholders: %v", missingPlaceholders)
+// CleanupMessages 删除超过保留期且已结束的邮件
+func (s *EmailService) CleanupMessages(ctx context.Context) (int64, error) {
+	if s.queue == nil {
+		return 0, nil
 	}
+	return s.queue.DeleteFinishedBefore(ctx, time.Now().Add(-emailRetention))
+}
 
-	if _, ok := texts[defaultLanguage]; !ok {
-		return fmt.Errorf("default language %s not found in texts", defaultLanguage)
+// emailRetryDelay 第 attempts 次尝试失败后的等待时间：15s 起按 2 的幂递增，最长 1 小时
+func emailRetryDelay(attempts int) time.Duration {
+	delay := emailRetryBaseDelay
+	for i := 1; i < attempts && delay < emailRetryMaxDelay; i++ {
+		delay *= 2
 	}
-
-	return nil
+	return min(delay, emailRetryMaxDelay)
 }
 
-// validateTexts 验证文案是否完整
-func validateTexts(common, typeTexts map[string]string) error {
-	if common == nil {
-		return errors.New("common texts is nil")
-	}
-	if typeTexts == nil {
-		return errors.New("type texts is nil")
+func truncateEmailError(msg string) string {
+	if len(msg) <= emailMaxErrorLength {
+		return msg
 	}
+	return strings.ToValidUTF8(msg[:emailMaxErrorLength], "")
+}
 
-	requiredCommon := []string{"greeting", "buttonText"}
-	for _, key := range requiredCommon {
-		if common[key] == "" {
-			return fmt.Errorf("missing common text: %s", key)
-		}
-	}
+// Close 停止队列发送协程，等待进行中的发送完成后关闭发送后端。
+// 队列中尚未发出的邮件保留在数据库中，重启后继续发送
+func (s *EmailService) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+
+		done := make(chan struct{})
+		go func() {
+			s.worker.Wait()
+			s.wg.Wait()
+			close(done)
+		}()
 
-	requiredType := []string{"subject", "pageTitle"}
-	for _, key := range requiredType {
-		if typeTexts[key] == "" {
-			return fmt.Errorf("missing type text: %s", key)
+		select {
+		case <-done:
+			utils.LogInfo("EMAIL", "All inflight email sends completed")
+		case <-time.After(30 * time.Second):
+			utils.LogWarn("EMAIL", "Timed out waiting for inflight email sends")
 		}
-	}
 
-	return nil
-}
-
-// safeGet 安全获取 map 值
-func safeGet(m map[string]string, key, defaultValue string) string {
-	if m == nil {
-		return defaultValue
-	}
-	if v, ok := m[key]; ok && v != "" {
-		return v
-	}
-	return defaultValue
+		s.transport.Close()
+	})
 }
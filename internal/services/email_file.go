@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"auth-system/internal/config"
+	"auth-system/internal/utils"
+
+	"github.com/wneessen/go-mail"
+)
+
+// FileEmailTransport 将邮件以 RFC 5322 格式写入本地 maildir（tmp/ 写完后原子移动到 new/），
+// 不真正发信，供本地开发与测试查看邮件内容
+type FileEmailTransport struct {
+	dir  string
+	from string
+}
+
+// NewFileEmailTransport 创建 maildir 发送后端，目录不存在时自动创建
+func NewFileEmailTransport(dir, from string) (*FileEmailTransport, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("%w: maildir is empty", ErrEmailUnknownTransport)
+	}
+	if from == "" {
+		from = "noreply@localhost"
+	}
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o750); err != nil {
+			return nil, fmt.Errorf("create maildir: %w", err)
+		}
+	}
+
+	utils.LogWarn("EMAIL", "File transport enabled, emails will not be delivered", "dir", dir)
+	return &FileEmailTransport{dir: dir, from: from}, nil
+}
+
+// Name 后端名称
+func (t *FileEmailTransport) Name() string { return config.EmailTransportFile }
+
+// Verify 确认 maildir 可写
+func (t *FileEmailTransport) Verify(context.Context) error {
+	f, err := os.CreateTemp(filepath.Join(t.dir, "tmp"), "verify-*")
+	if err != nil {
+		return fmt.Errorf("maildir not writable: %w", err)
+	}
+	name := f.Name()
+	_ = f.Close()
+	return os.Remove(name)
+}
+
+// Close 无需释放资源
+func (t *FileEmailTransport) Close() {}
+
+// Send 写入一封 .eml 到 maildir 的 new/ 目录
+func (t *FileEmailTransport) Send(_ context.Context, email *OutboundEmail) error {
+	if err := validateOutboundEmail(email, t.from); err != nil {
+		return NewPermanentEmailError(err)
+	}
+
+	msg := mail.NewMsg()
+	if err := msg.From(t.from); err != nil {
+		return NewPermanentEmailError(fmt.Errorf("failed to set from address: %w", err))
+	}
+	if err := msg.To(email.To); err != nil {
+		return NewPermanentEmailError(fmt.Errorf("failed to set to address: %w", err))
+	}
+	msg.Subject(email.Subject)
+	msg.SetDate()
+	msg.SetMessageID()
+	msg.SetBodyString(mail.TypeTextPlain, email.TextBody)
+	msg.AddAlternativeString(mail.TypeTextHTML, email.HTMLBody)
+
+	name, err := maildirFilename()
+	if err != nil {
+		return err
+	}
+	tmpPath := filepath.Join(t.dir, "tmp", name)
+	if err := msg.WriteToFile(tmpPath); err != nil {
+		return fmt.Errorf("write maildir file: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(t.dir, "new", name)); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("move maildir file: %w", err)
+	}
+	return nil
+}
+
+// maildirFilename 生成 maildir 唯一文件名 "{unix 秒}.{纳秒}_{随机}.{主机名}"
+func maildirFilename() (string, error) {
+	random := make([]byte, 6)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	now := time.Now()
+	return strconv.FormatInt(now.Unix(), 10) + "." + strconv.Itoa(now.Nanosecond()) + "_" + hex.EncodeToString(random) + "." + host, nil
+}
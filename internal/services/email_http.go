@@ -0,0 +1,146 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"auth-system/internal/config"
+	"auth-system/internal/utils"
+)
+
+const (
+	emailAPITimeout         = 15 * time.Second
+	emailAPIMaxResponseRead = 1024
+)
+
+// 各服务商的默认 API 端点（可由 EMAIL_API_URL 覆盖）
+var emailAPIDefaultURLs = map[string]string{
+	config.EmailTransportResend:   "https://api.resend.com/emails",
+	config.EmailTransportPostmark: "https://api.postmarkapp.com/email",
+	config.EmailTransportSendGrid: "https://api.sendgrid.com/v3/mail/send",
+}
+
+// HTTPEmailTransport 通过服务商 HTTP API 发送邮件（Resend、Postmark、SendGrid）
+type HTTPEmailTransport struct {
+	provider string
+	apiKey   string
+	endpoint string
+	from     string
+	client   *http.Client
+}
+
+// NewHTTPEmailTransport 创建 HTTP API 发送后端，endpoint 为空时使用服务商默认端点
+func NewHTTPEmailTransport(provider, apiKey, endpoint, from string) (*HTTPEmailTransport, error) {
+	defaultURL, ok := emailAPIDefaultURLs[provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrEmailUnknownTransport, provider)
+	}
+	if apiKey == "" {
+		return nil, ErrEmailAPIKeyMissing
+	}
+	if from == "" {
+		return nil, ErrEmailFromMissing
+	}
+	if endpoint == "" {
+		endpoint = defaultURL
+	}
+
+	utils.LogInfo("EMAIL", "HTTP API transport initialized", "provider", provider, "endpoint", endpoint, "from", from)
+
+	return &HTTPEmailTransport{
+		provider: provider,
+		apiKey:   apiKey,
+		endpoint: endpoint,
+		from:     from,
+		client:   &http.Client{Timeout: emailAPITimeout},
+	}, nil
+}
+
+// Name 后端名称
+func (t *HTTPEmailTransport) Name() string { return t.provider }
+
+// Verify HTTP API 无连接可验证，仅确认配置完整
+func (t *HTTPEmailTransport) Verify(context.Context) error { return nil }
+
+// Close 无需释放资源
+func (t *HTTPEmailTransport) Close() {}
+
+// Send 调用服务商 API 发送；400 / 422（请求或收件人被拒绝）视为永久失败，其余错误可重试
+func (t *HTTPEmailTransport) Send(ctx context.Context, email *OutboundEmail) error {
+	if err := validateOutboundEmail(email, t.from); err != nil {
+		return NewPermanentEmailError(err)
+	}
+
+	body, err := json.Marshal(t.buildPayload(email))
+	if err != nil {
+		return NewPermanentEmailError(fmt.Errorf("marshal request: %w", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if t.provider == config.EmailTransportPostmark {
+		req.Header.Set("X-Postmark-Server-Token", t.apiKey)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, emailAPIMaxResponseRead))
+	sendErr := fmt.Errorf("%s API returned %d: %s", t.provider, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnprocessableEntity {
+		return NewPermanentEmailError(sendErr)
+	}
+	return sendErr
+}
+
+// buildPayload 按服务商格式构造请求体
+func (t *HTTPEmailTransport) buildPayload(email *OutboundEmail) any {
+	switch t.provider {
+	case config.EmailTransportPostmark:
+		return map[string]any{
+			"From":          t.from,
+			"To":            email.To,
+			"Subject":       email.Subject,
+			"HtmlBody":      email.HTMLBody,
+			"TextBody":      email.TextBody,
+			"MessageStream": "outbound",
+		}
+	case config.EmailTransportSendGrid:
+		return map[string]any{
+			"personalizations": []map[string]any{{"to": []map[string]string{{"email": email.To}}}},
+			"from":             map[string]string{"email": t.from},
+			"subject":          email.Subject,
+			"content": []map[string]string{
+				{"type": "text/plain", "value": email.TextBody},
+				{"type": "text/html", "value": email.HTMLBody},
+			},
+		}
+	default: // resend
+		return map[string]any{
+			"from":    t.from,
+			"to":      []string{email.To},
+			"subject": email.Subject,
+			"html":    email.HTMLBody,
+			"text":    email.TextBody,
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"auth-system/internal/models"
+	"auth-system/internal/paths"
+	"auth-system/internal/utils"
+)
+
+// EmailNoticeUserLogStore 在用户日志之上发送安全通知邮件（密码已修改、账户被封禁、授权第三方应用、新增 Passkey），
+// 与 WebhookUserLogStore 一样包装 UserLogStore，写日志的调用点无需逐个接入。
+// 用户未保存语言偏好，通知邮件统一使用默认语言；查询收件人失败只记录警告，不影响原调用
+type EmailNoticeUserLogStore struct {
+	models.UserLogStore
+	users   models.UserReader
+	sender  EmailSender
+	baseURL string
+}
+
+// NewEmailNoticeUserLogStore 包装用户日志仓库；sender 为 nil 时原样返回
+func NewEmailNoticeUserLogStore(store models.UserLogStore, users models.UserReader, sender EmailSender, baseURL string) models.UserLogStore {
+	if sender == nil || users == nil {
+		return store
+	}
+	return &EmailNoticeUserLogStore{UserLogStore: store, users: users, sender: sender, baseURL: baseURL}
+}
+
+// LogChangePassword 记录修改密码并发送 password_changed 通知
+func (s *EmailNoticeUserLogStore) LogChangePassword(ctx context.Context, userUID string) error {
+	err := s.UserLogStore.LogChangePassword(ctx, userUID)
+	if email := s.recipient(ctx, userUID); email != "" {
+		s.sender.SendPasswordChangedNotice(email, "", &PasswordChangedEmailData{
+			Time:     time.Now(),
+			ResetURL: s.baseURL + paths.PathAccountForgot,
+		})
+	}
+	return err
+}
+
+// LogBanned 记录被封禁并发送 account_banned 通知
+func (s *EmailNoticeUserLogStore) LogBanned(ctx context.Context, userUID string, reason string, unbanAt *time.Time) error {
+	err := s.UserLogStore.LogBanned(ctx, userUID, reason, unbanAt)
+	if email := s.recipient(ctx, userUID); email != "" {
+		s.sender.SendAccountBannedNotice(email, "", &AccountBannedEmailData{Reason: reason, UnbanAt: unbanAt})
+	}
+	return err
+}
+
+// LogOAuthAuthorize 记录 OAuth 授权并发送 oauth_authorized 通知
+func (s *EmailNoticeUserLogStore) LogOAuthAuthorize(ctx context.Context, userUID string, clientID, clientName, scope string) error {
+	err := s.UserLogStore.LogOAuthAuthorize(ctx, userUID, clientID, clientName, scope)
+	if email := s.recipient(ctx, userUID); email != "" {
+		s.sender.SendOAuthAuthorizedNotice(email, "", &OAuthAuthorizedEmailData{
+			ClientName: clientName,
+			Scopes:     strings.Fields(scope),
+			Time:       time.Now(),
+			ManageURL:  s.baseURL + paths.PathAccountDashboard,
+		})
+	}
+	return err
+}
+
+// LogRegisterPasskey 记录注册 Passkey 并发送 passkey_added 通知
+func (s *EmailNoticeUserLogStore) LogRegisterPasskey(ctx context.Context, userUID string, credentialID int64, name string) error {
+	err := s.UserLogStore.LogRegisterPasskey(ctx, userUID, credentialID, name)
+	if email := s.recipient(ctx, userUID); email != "" {
+		s.sender.SendPasskeyAddedNotice(email, "", &PasskeyAddedEmailData{
+			Name:      name,
+			Time:      time.Now(),
+			ManageURL: s.baseURL + paths.PathAccountDashboard,
+		})
+	}
+	return err
+}
+
+// recipient 查询用户当前邮箱，失败时返回空字符串
+func (s *EmailNoticeUserLogStore) recipient(ctx context.Context, userUID string) string {
+	user, err := s.users.FindByUID(ctx, userUID)
+	if err != nil || user == nil {
+		utils.LogWarnCtx(ctx, "EMAIL", "Failed to look up notice recipient", "user_uid", userUID, "error", err)
+		return ""
+	}
+	return user.Email
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"auth-system/internal/config"
+	"auth-system/internal/utils"
+
+	"github.com/wneessen/go-mail"
+)
+
+const (
+	smtpTimeout       = 15 * time.Second
+	smtpPort465       = 465
+	smtpPort587       = 587
+	connMaxIdleTime   = 5 * time.Minute
+	connCheckInterval = 30 * time.Second
+)
+
+// SMTPTransport SMTP 发送后端，复用一个 SMTP 客户端，空闲超时后关闭
+type SMTPTransport struct {
+	cfg  *config.Config
+	from string
+
+	// 连接池相关
+	client     *mail.Client
+	clientMu   sync.Mutex
+	lastUsed   time.Time
+	stopKeeper chan struct{}
+	closeOnce  sync.Once
+}
+
+// NewSMTPTransport 创建 SMTP 发送后端
+func NewSMTPTransport(cfg *config.Config) (*SMTPTransport, error) {
+	if cfg == nil {
+		return nil, ErrEmailNilConfig
+	}
+	if err := validateSMTPConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	from := cfg.EmailFrom
+	if from == "" {
+		from = cfg.SMTPFrom
+	}
+
+	utils.LogInfo("EMAIL", "SMTP transport initialized", "host", cfg.SMTPHost, "port", cfg.SMTPPort, "from", from)
+
+	t := &SMTPTransport{
+		cfg:        cfg,
+		from:       from,
+		stopKeeper: make(chan struct{}),
+	}
+	go t.connectionKeeper()
+	return t, nil
+}
+
+// Name 后端名称
+func (t *SMTPTransport) Name() string { return config.EmailTransportSMTP }
+
+// Verify 验证 SMTP 连接
+func (t *SMTPTransport) Verify(ctx context.Context) error {
+	client, err := t.createClient()
+	if err != nil {
+		return err
+	}
+	if err := client.DialWithContext(ctx); err != nil {
+		return fmt.Errorf("SMTP connection failed: %w", err)
+	}
+	if err := client.Close(); err != nil {
+		utils.LogWarn("EMAIL", "Failed to close SMTP client")
+	}
+	return nil
+}
+
+// Send 发送邮件；服务器对收件人或正文返回 5xx 时视为退信（永久失败）
+func (t *SMTPTransport) Send(ctx context.Context, email *OutboundEmail) error {
+	if err := validateOutboundEmail(email, t.from); err != nil {
+		return NewPermanentEmailError(err)
+	}
+
+	client, err := t.getClient()
+	if err != nil {
+		return err
+	}
+
+	msg := mail.NewMsg()
+	if err := msg.From(t.from); err != nil {
+		return fmt.Errorf("failed to set from address: %w", err)
+	}
+	if err := msg.To(email.To); err != nil {
+		return NewPermanentEmailError(fmt.Errorf("failed to set to address: %w", err))
+	}
+	msg.Subject(email.Subject)
+	msg.SetBodyString(mail.TypeTextPlain, email.TextBody)
+	msg.AddAlternativeString(mail.TypeTextHTML, email.HTMLBody)
+
+	if err := client.DialAndSendWithContext(ctx, msg); err != nil {
+		// 发送失败，重置连接（下次会重新建立）
+		t.resetClient()
+		if isSMTPBounce(err) {
+			return NewPermanentEmailError(err)
+		}
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// isSMTPBounce 服务器在 RCPT TO / DATA 阶段返回 5xx：收件地址不存在或邮件被拒收，重试无意义
+func isSMTPBounce(err error) bool {
+	var sendErr *mail.SendError
+	if !errors.As(err, &sendErr) || sendErr.IsTemp() {
+		return false
+	}
+	if sendErr.Reason != mail.ErrSMTPRcptTo && sendErr.Reason != mail.ErrSMTPData && sendErr.Reason != mail.ErrSMTPDataClose {
+		return false
+	}
+	code := sendErr.ErrorCode()
+	return code >= 500 && code < 600
+}
+
+// createClient 创建 SMTP 客户端
+func (t *SMTPTransport) createClient() (*mail.Client, error) {
+	var tlsPolicy mail.TLSPolicy
+	var authType mail.SMTPAuthType
+	var useSSL bool
+
+	switch t.cfg.SMTPPort {
+	case smtpPort465:
+		tlsPolicy = mail.TLSMandatory
+		authType = mail.SMTPAuthLogin // 网易邮箱需要 LOGIN 认证
+		useSSL = true
+	case smtpPort587:
+		tlsPolicy = mail.TLSOpportunistic
+		authType = mail.SMTPAuthPlain
+		useSSL = false
+	default:
+		// 其他端口默认使用 STARTTLS
+		tlsPolicy = mail.TLSOpportunistic
+		authType = mail.SMTPAuthPlain
+		useSSL = false
+		utils.LogWarn("EMAIL", "Non-standard SMTP port, using STARTTLS", "port", t.cfg.SMTPPort)
+	}
+
+	options := []mail.Option{
+		mail.WithPort(t.cfg.SMTPPort),
+		mail.WithSMTPAuth(authType),
+		mail.WithUsername(t.cfg.SMTPUser),
+		mail.WithPassword(t.cfg.SMTPPassword),
+		mail.WithTLSPortPolicy(tlsPolicy),
+		mail.WithTimeout(smtpTimeout),
+	}
+
+	if useSSL {
+		options = append(options, mail.WithSSL())
+	}
+
+	client, err := mail.NewClient(t.cfg.SMTPHost, options...)
+	if err != nil {
+		utils.LogError("EMAIL", "createClient", err, "host", t.cfg.SMTPHost, "port", t.cfg.SMTPPort)
+		return nil, fmt.Errorf("%w: %v", ErrEmailClientCreateFailed, err)
+	}
+
+	return client, nil
+}
+
+// getClient 获取或创建 SMTP 客户端（连接池）
+func (t *SMTPTransport) getClient() (*mail.Client, error) {
+	t.clientMu.Lock()
+	defer t.clientMu.Unlock()
+
+	if t.client != nil {
+		t.lastUsed = time.Now()
+		return t.client, nil
+	}
+
+	client, err := t.createClient()
+	if err != nil {
+		return nil, err
+	}
+
+	t.client = client
+	t.lastUsed = time.Now()
+	utils.LogInfo("EMAIL", "SMTP connection established (pooled)")
+
+	return t.client, nil
+}
+
+// closeClient 关闭当前连接
+func (t *SMTPTransport) closeClient() {
+	t.clientMu.Lock()
+	defer t.clientMu.Unlock()
+
+	if t.client != nil {
+		if err := t.client.Close(); err != nil {
+			utils.LogWarn("EMAIL", "Failed to close SMTP client")
+		}
+		t.client = nil
+		utils.LogInfo("EMAIL", "SMTP connection closed")
+	}
+}
+
+// resetClient 重置连接（发送失败时调用）
+func (t *SMTPTransport) resetClient() {
+	t.clientMu.Lock()
+	defer t.clientMu.Unlock()
+
+	if t.client != nil {
+		_ = t.client.Close()
+		t.client = nil
+	}
+}
+
+// connectionKeeper 连接保活协程
+// 定期检查连接状态，关闭空闲过久的连接
+func (t *SMTPTransport) connectionKeeper() {
+	ticker := time.NewTicker(connCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.clientMu.Lock()
+			if t.client != nil && time.Since(t.lastUsed) > connMaxIdleTime {
+				_ = t.client.Close()
+				t.client = nil
+				utils.LogInfo("EMAIL", "SMTP connection closed due to idle timeout")
+			}
+			t.clientMu.Unlock()
+		case <-t.stopKeeper:
+			t.closeClient()
+			return
+		}
+	}
+}
+
+// Close 停止保活协程并关闭连接
+func (t *SMTPTransport) Close() {
+	t.closeOnce.Do(func() { close(t.stopKeeper) })
+}
+
+// validateSMTPConfig 验证 SMTP 配置
+func validateSMTPConfig(cfg *config.Config) error {
+	if cfg.SMTPHost == "" {
+		return fmt.Errorf("%w: SMTP host is empty", ErrEmailSMTPConfigMissing)
+	}
+	if cfg.SMTPPort == 0 {
+		return fmt.Errorf("%w: SMTP port is 0", ErrEmailSMTPConfigMissing)
+	}
+	if cfg.SMTPUser == "" {
+		return fmt.Errorf("%w: SMTP user is empty", ErrEmailSMTPConfigMissing)
+	}
+	if cfg.SMTPPassword == "" {
+		return fmt.Errorf("%w: SMTP password is empty", ErrEmailSMTPConfigMissing)
+	}
+	if cfg.SMTPFrom == "" && cfg.EmailFrom == "" {
+		return fmt.Errorf("%w: SMTP from address is empty", ErrEmailSMTPConfigMissing)
+	}
+	return nil
+}
@@ -0,0 +1,504 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"auth-system/internal/utils"
+)
+
+var (
+	ErrEmailUnknownType      = errors.New("unknown email type")
+	ErrEmailTemplateNotFound = errors.New("email template not found")
+	ErrEmailTextsNotFound    = errors.New("email texts not found")
+	ErrEmailInvalidTexts     = errors.New("invalid email texts format")
+	ErrEmailTemplateInvalid  = errors.New("invalid email template")
+)
+
+const (
+	emailTemplatesDir = "dist/data/email-templates"
+	emailTextsPath    = "dist/data/email-texts.json"
+
+	emailLayoutTemplate = "layout"
+	emailVerifyTemplate = "verify"
+	emailCommonSection  = "common"
+	emailNoticeSection  = "notice"
+	emailTimeLayout     = "2006-01-02 15:04 UTC"
+)
+
+// 通知类邮件类型（验证类邮件类型与验证码 TokenType 同名）
+const (
+	EmailTypeNewLogin        = "new_login"
+	EmailTypePasswordChanged = "password_changed"
+	EmailTypeAccountBanned   = "account_banned"
+	EmailTypeOAuthAuthorized = "oauth_authorized"
+	EmailTypeExportReady     = "export_ready"
+	EmailTypeAccountLocked   = "account_locked"
+	EmailTypePasskeyAdded    = "passkey_added"
+)
+
+// EmailTexts 邮件文案
+// 结构：language -> section -> key -> value
+type EmailTexts map[string]map[string]map[string]string
+
+// VerifyEmailData 验证类邮件数据
+type VerifyEmailData struct {
+	VerifyURL string
+}
+
+// NewLoginEmailData 新设备登录提醒数据
+type NewLoginEmailData struct {
+	Time      time.Time
+	IP        string
+	Location  string
+	Device    string
+	SecureURL string // "这不是我" 链接，为空时不显示按钮
+}
+
+// PasswordChangedEmailData 密码已修改通知数据
+type PasswordChangedEmailData struct {
+	Time     time.Time
+	ResetURL string
+}
+
+// AccountBannedEmailData 账户封禁通知数据
+type AccountBannedEmailData struct {
+	Reason  string     // 封禁原因代码，文案中有 reason_{代码} 时显示翻译
+	UnbanAt *time.Time // nil 表示永久封禁
+}
+
+// OAuthAuthorizedEmailData 应用授权通知数据
+type OAuthAuthorizedEmailData struct {
+	ClientName string
+	Scopes     []string
+	Time       time.Time
+	ManageURL  string
+}
+
+// ExportReadyEmailData 数据导出就绪通知数据
+type ExportReadyEmailData struct {
+	DownloadURL string
+	ExpiresAt   time.Time
+}
+
+// AccountLockedEmailData 登录失败过多账户被临时锁定通知数据
+type AccountLockedEmailData struct {
+	IP          string // 触发锁定的最后一次失败登录的 IP
+	LockedUntil time.Time
+	UnlockURL   string
+}
+
+// PasskeyAddedEmailData 新增 Passkey 通知数据
+type PasskeyAddedEmailData struct {
+	Name      string
+	Time      time.Time
+	ManageURL string
+}
+
+// emailTemplateSpec 邮件类型对应的模板、共用文案段与预览用示例数据
+type emailTemplateSpec struct {
+	template string
+	section  string
+	sample   func() any
+}
+
+// emailTemplateCatalog 全部邮件类型：template 为 email-templates 目录下的模板名，
+// 文案按 common → section（验证类 verify、通知类 notice）→ 类型同名段 依次覆盖合并
+var emailTemplateCatalog = map[string]emailTemplateSpec{
+	TokenTypeRegister:        {template: emailVerifyTemplate, section: emailVerifyTemplate, sample: sampleVerifyEmailData},
+	TokenTypeResetPassword:   {template: emailVerifyTemplate, section: emailVerifyTemplate, sample: sampleVerifyEmailData},
+	TokenTypeChangePassword:  {template: emailVerifyTemplate, section: emailVerifyTemplate, sample: sampleVerifyEmailData},
+	TokenTypeDeleteAccount:   {template: emailVerifyTemplate, section: emailVerifyTemplate, sample: sampleVerifyEmailData},
+	TokenTypeChangeEmail:     {template: emailVerifyTemplate, section: emailVerifyTemplate, sample: sampleVerifyEmailData},
+	TokenTypeChangeEmailNew:  {template: emailVerifyTemplate, section: emailVerifyTemplate, sample: sampleVerifyEmailData},
+	EmailTypeNewLogin:        {template: EmailTypeNewLogin, section: emailNoticeSection, sample: sampleNewLoginEmailData},
+	EmailTypePasswordChanged: {template: EmailTypePasswordChanged, section: emailNoticeSection, sample: samplePasswordChangedEmailData},
+	EmailTypeAccountBanned:   {template: EmailTypeAccountBanned, section: emailNoticeSection, sample: sampleAccountBannedEmailData},
+	EmailTypeOAuthAuthorized: {template: EmailTypeOAuthAuthorized, section: emailNoticeSection, sample: sampleOAuthAuthorizedEmailData},
+	EmailTypeExportReady:     {template: EmailTypeExportReady, section: emailNoticeSection, sample: sampleExportReadyEmailData},
+	EmailTypeAccountLocked:   {template: EmailTypeAccountLocked, section: emailNoticeSection, sample: sampleAccountLockedEmailData},
+	EmailTypePasskeyAdded:    {template: EmailTypePasskeyAdded, section: emailNoticeSection, sample: samplePasskeyAddedEmailData},
+}
+
+// emailField 通知类邮件字段表的一行，Value 为空时不显示
+type emailField struct {
+	Label any
+	Value any
+}
+
+// emailAction 主操作按钮
+type emailAction struct {
+	URL   string
+	Label any
+	Hint  any
+}
+
+// emailTemplateData 模板数据：T 为合并后的文案，Data 为邮件类型对应的数据结构
+type emailTemplateData[T any] struct {
+	Lang string
+	T    map[string]T
+	Data any
+}
+
+// emailTemplateFuncs HTML 与纯文本模板共用的函数
+var emailTemplateFuncs = map[string]any{
+	"datetime": formatEmailTime,
+	"join":     strings.Join,
+	"fields": func(pairs ...any) []emailField {
+		fields := make([]emailField, 0, len(pairs)/2)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			fields = append(fields, emailField{Label: pairs[i], Value: pairs[i+1]})
+		}
+		return fields
+	},
+	"action": func(url string, label, hint any) emailAction {
+		return emailAction{URL: url, Label: label, Hint: hint}
+	},
+}
+
+// RenderedEmail 渲染结果
+type RenderedEmail struct {
+	Subject  string
+	HTMLBody string
+	TextBody string
+}
+
+// EmailTemplateRegistry 邮件模板注册表：每个模板由 {name}.html 与 {name}.txt 组成，
+// 套用 layout.html / layout.txt 并重定义其中的 content 块；
+// {name}.{language}.html / .txt 存在时覆盖该语言的模板
+type EmailTemplateRegistry struct {
+	texts EmailTexts
+	html  map[string]*htmltemplate.Template // key: name 或 name.language
+	text  map[string]*texttemplate.Template
+}
+
+// LoadEmailTemplates 加载模板目录与多语言文案，检查每个邮件类型都有模板与默认语言的主题
+func LoadEmailTemplates(dir, textsPath string) (*EmailTemplateRegistry, error) {
+	texts, err := loadTexts(textsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &EmailTemplateRegistry{
+		texts: texts,
+		html:  make(map[string]*htmltemplate.Template),
+		text:  make(map[string]*texttemplate.Template),
+	}
+	if err := r.parseDir(dir); err != nil {
+		return nil, err
+	}
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	utils.LogInfo("EMAIL", "Email templates loaded", "dir", dir, "templates", len(r.html), "languages", len(texts))
+	return r, nil
+}
+
+// parseDir 解析目录下的全部模板文件
+func (r *EmailTemplateRegistry) parseDir(dir string) error {
+	htmlLayout, err := readEmailTemplateFile(dir, emailLayoutTemplate+".html")
+	if err != nil {
+		return err
+	}
+	textLayout, err := readEmailTemplateFile(dir, emailLayoutTemplate+".txt")
+	if err != nil {
+		return err
+	}
+
+	htmlBase, err := htmltemplate.New(emailLayoutTemplate).Funcs(emailTemplateFuncs).Parse(htmlLayout)
+	if err != nil {
+		return fmt.Errorf("%w: layout.html: %v", ErrEmailTemplateInvalid, err)
+	}
+	textBase, err := texttemplate.New(emailLayoutTemplate).Funcs(emailTemplateFuncs).Parse(textLayout)
+	if err != nil {
+		return fmt.Errorf("%w: layout.txt: %v", ErrEmailTemplateInvalid, err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrEmailTemplateNotFound, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := filepath.Ext(entry.Name())
+		key := strings.TrimSuffix(entry.Name(), ext)
+		if key == emailLayoutTemplate || (ext != ".html" && ext != ".txt") {
+			continue
+		}
+
+		src, err := readEmailTemplateFile(dir, entry.Name())
+		if err != nil {
+			return err
+		}
+		if ext == ".html" {
+			t := htmltemplate.Must(htmlBase.Clone())
+			if _, err := t.Parse(src); err != nil {
+				return fmt.Errorf("%w: %s: %v", ErrEmailTemplateInvalid, entry.Name(), err)
+			}
+			r.html[key] = t
+		} else {
+			t := texttemplate.Must(textBase.Clone())
+			if _, err := t.Parse(src); err != nil {
+				return fmt.Errorf("%w: %s: %v", ErrEmailTemplateInvalid, entry.Name(), err)
+			}
+			r.text[key] = t
+		}
+	}
+	return nil
+}
+
+// validate 检查每个邮件类型的模板齐全，并用示例数据试渲染默认语言（提前暴露模板错误）
+func (r *EmailTemplateRegistry) validate() error {
+	if _, ok := r.texts[defaultLanguage]; !ok {
+		return fmt.Errorf("%w: default language %s not found", ErrEmailInvalidTexts, defaultLanguage)
+	}
+
+	for _, emailType := range r.Types() {
+		spec := emailTemplateCatalog[emailType]
+		name := spec.template
+		if r.html[name] == nil || r.text[name] == nil {
+			return fmt.Errorf("%w: %s.html / %s.txt (type %s)", ErrEmailTemplateNotFound, name, name, emailType)
+		}
+		if r.texts[defaultLanguage][emailType]["subject"] == "" {
+			utils.LogWarn("EMAIL", "Missing subject in default language", "type", emailType)
+		}
+		if _, err := r.Render(emailType, defaultLanguage, spec.sample()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Types 全部邮件类型（排序）
+func (r *EmailTemplateRegistry) Types() []string {
+	types := make([]string, 0, len(emailTemplateCatalog))
+	for emailType := range emailTemplateCatalog {
+		types = append(types, emailType)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// Languages 文案中的全部语言（排序）
+func (r *EmailTemplateRegistry) Languages() []string {
+	languages := make([]string, 0, len(r.texts))
+	for language := range r.texts {
+		languages = append(languages, language)
+	}
+	slices.Sort(languages)
+	return languages
+}
+
+// Render 按邮件类型与语言渲染主题、HTML 与纯文本正文；语言不存在时使用默认语言，
+// 缺失的文案逐项回退到默认语言
+func (r *EmailTemplateRegistry) Render(emailType, language string, data any) (*RenderedEmail, error) {
+	spec, ok := emailTemplateCatalog[emailType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrEmailUnknownType, emailType)
+	}
+	if _, ok := r.texts[language]; !ok {
+		language = defaultLanguage
+	}
+
+	texts := r.mergeTexts(spec, emailType, language)
+	name := spec.template
+
+	htmlTexts := make(map[string]htmltemplate.HTML, len(texts))
+	plainTexts := make(map[string]string, len(texts))
+	for key, value := range texts {
+		// 文案来自仓库内的 email-texts.json（非用户输入），允许内联 HTML
+		htmlTexts[key] = htmltemplate.HTML(value)
+		plainTexts[key] = stripEmailHTML(value)
+	}
+
+	var htmlBody, textBody bytes.Buffer
+	if err := r.htmlTemplate(name, language).Execute(&htmlBody, emailTemplateData[htmltemplate.HTML]{Lang: language, T: htmlTexts, Data: data}); err != nil {
+		return nil, fmt.Errorf("%w: render %s.html: %v", ErrEmailTemplateInvalid, name, err)
+	}
+	if err := r.textTemplate(name, language).Execute(&textBody, emailTemplateData[string]{Lang: language, T: plainTexts, Data: data}); err != nil {
+		return nil, fmt.Errorf("%w: render %s.txt: %v", ErrEmailTemplateInvalid, name, err)
+	}
+
+	subject := plainTexts["subject"]
+	if subject == "" {
+		subject = "Nebula Studios"
+	}
+
+	return &RenderedEmail{
+		Subject:  subject,
+		HTMLBody: htmlBody.String(),
+		TextBody: strings.TrimSpace(textBody.String()) + "\n",
+	}, nil
+}
+
+// Preview 用示例数据渲染邮件类型，供管理后台预览
+func (r *EmailTemplateRegistry) Preview(emailType, language string) (*RenderedEmail, error) {
+	spec, ok := emailTemplateCatalog[emailType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrEmailUnknownType, emailType)
+	}
+	return r.Render(emailType, language, spec.sample())
+}
+
+// mergeTexts 合并文案：默认语言在下、目标语言在上，每种语言内 common → 共用段 → 类型段
+func (r *EmailTemplateRegistry) mergeTexts(spec emailTemplateSpec, emailType, language string) map[string]string {
+	merged := make(map[string]string)
+	languages := []string{defaultLanguage}
+	if language != defaultLanguage {
+		languages = append(languages, language)
+	}
+	for _, lang := range languages {
+		for _, section := range []string{emailCommonSection, spec.section, emailType} {
+			for key, value := range r.texts[lang][section] {
+				merged[key] = value
+			}
+		}
+	}
+	return merged
+}
+
+func (r *EmailTemplateRegistry) htmlTemplate(name, language string) *htmltemplate.Template {
+	if t, ok := r.html[name+"."+language]; ok {
+		return t
+	}
+	return r.html[name]
+}
+
+func (r *EmailTemplateRegistry) textTemplate(name, language string) *texttemplate.Template {
+	if t, ok := r.text[name+"."+language]; ok {
+		return t
+	}
+	return r.text[name]
+}
+
+// readEmailTemplateFile 读取模板文件
+func readEmailTemplateFile(dir, name string) (string, error) {
+	path := filepath.Join(dir, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("%w: %s", ErrEmailTemplateNotFound, path)
+		}
+		return "", fmt.Errorf("failed to read email template: %w", err)
+	}
+	return string(data), nil
+}
+
+// loadTexts 加载多语言文案
+func loadTexts(path string) (EmailTexts, error) {
+	textsBytes, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrEmailTextsNotFound, path)
+		}
+		return nil, fmt.Errorf("failed to read email texts: %w", err)
+	}
+
+	var texts EmailTexts
+	if err := json.Unmarshal(textsBytes, &texts); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEmailInvalidTexts, err)
+	}
+
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("%w: texts is empty", ErrEmailInvalidTexts)
+	}
+
+	utils.LogInfo("EMAIL", "Email texts loaded", "path", path, "languages", len(texts))
+	return texts, nil
+}
+
+var emailHTMLTagRe = regexp.MustCompile(`<[^>]*>`)
+
+// stripEmailHTML 去掉文案中的 HTML 标签与实体，用于纯文本正文
+func stripEmailHTML(s string) string {
+	return html.UnescapeString(emailHTMLTagRe.ReplaceAllString(s, ""))
+}
+
+// formatEmailTime 邮件中的时间统一以 UTC 显示；nil 或零值返回空串
+func formatEmailTime(v any) string {
+	var t time.Time
+	switch value := v.(type) {
+	case time.Time:
+		t = value
+	case *time.Time:
+		if value == nil {
+			return ""
+		}
+		t = *value
+	default:
+		return ""
+	}
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(emailTimeLayout)
+}
+
+// 预览示例数据
+
+var emailSampleTime = time.Date(2025, 1, 15, 8, 30, 0, 0, time.UTC)
+
+func sampleVerifyEmailData() any {
+	return &VerifyEmailData{VerifyURL: "https://example.com/account/verify#token=sample-token"}
+}
+
+func sampleNewLoginEmailData() any {
+	return &NewLoginEmailData{
+		Time:      emailSampleTime,
+		IP:        "203.0.113.7",
+		Location:  "Tokyo, JP",
+		Device:    "Chrome on macOS",
+		SecureURL: "https://example.com/account/secure#token=sample-token",
+	}
+}
+
+func samplePasswordChangedEmailData() any {
+	return &PasswordChangedEmailData{Time: emailSampleTime, ResetURL: "https://example.com/account/forgot"}
+}
+
+func sampleAccountBannedEmailData() any {
+	unbanAt := emailSampleTime.AddDate(0, 0, 7)
+	return &AccountBannedEmailData{Reason: "violation", UnbanAt: &unbanAt}
+}
+
+func sampleOAuthAuthorizedEmailData() any {
+	return &OAuthAuthorizedEmailData{
+		ClientName: "Example App",
+		Scopes:     []string{"openid", "profile", "email"},
+		Time:       emailSampleTime,
+		ManageURL:  "https://example.com/account/dashboard",
+	}
+}
+
+func sampleExportReadyEmailData() any {
+	return &ExportReadyEmailData{
+		DownloadURL: "https://example.com/api/user/export/sample-token",
+		ExpiresAt:   emailSampleTime.Add(24 * time.Hour),
+	}
+}
+
+func sampleAccountLockedEmailData() any {
+	return &AccountLockedEmailData{
+		IP:          "203.0.113.7",
+		LockedUntil: emailSampleTime.Add(15 * time.Minute),
+		UnlockURL:   "https://example.com/account/unlock#token=sample-token",
+	}
+}
+
+func samplePasskeyAddedEmailData() any {
+	return &PasskeyAddedEmailData{Name: "MacBook", Time: emailSampleTime, ManageURL: "https://example.com/account/dashboard"}
+}
@@ -0,0 +1,452 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"auth-system/internal/config"
+	"auth-system/internal/models"
+)
+
+// fakeEmailQueueStore 本地邮件队列 fake
+type fakeEmailQueueStore struct {
+	models.EmailQueueStore
+	enqueued []*models.EmailMessage
+	due      []*models.EmailMessage
+	attempts map[int64]*models.EmailAttempt
+}
+
+func newFakeEmailQueueStore() *fakeEmailQueueStore {
+	return &fakeEmailQueueStore{attempts: map[int64]*models.EmailAttempt{}}
+}
+
+func (f *fakeEmailQueueStore) Enqueue(_ context.Context, msg *models.EmailMessage) error {
+	f.enqueued = append(f.enqueued, msg)
+	return nil
+}
+
+func (f *fakeEmailQueueStore) ClaimDue(context.Context, int, time.Duration) ([]*models.EmailMessage, error) {
+	due := f.due
+	f.due = nil
+	return due, nil
+}
+
+func (f *fakeEmailQueueStore) RecordAttempt(_ context.Context, id int64, attempt *models.EmailAttempt) error {
+	f.attempts[id] = attempt
+	return nil
+}
+
+// fakeEmailTransport 按收件人返回预设错误的发送后端
+type fakeEmailTransport struct {
+	errs map[string]error
+	sent []*OutboundEmail
+}
+
+func (f *fakeEmailTransport) Name() string                 { return "fake" }
+func (f *fakeEmailTransport) Verify(context.Context) error { return nil }
+func (f *fakeEmailTransport) Close()                       {}
+func (f *fakeEmailTransport) Send(_ context.Context, email *OutboundEmail) error {
+	if err := f.errs[email.To]; err != nil {
+		return err
+	}
+	f.sent = append(f.sent, email)
+	return nil
+}
+
+// loadTestEmailTemplates 加载仓库内的邮件模板源文件
+func loadTestEmailTemplates(t *testing.T) *EmailTemplateRegistry {
+	t.Helper()
+	registry, err := LoadEmailTemplates("../../data/email-templates", "../../data/email-texts.json")
+	if err != nil {
+		t.Fatalf("LoadEmailTemplates() error = %v", err)
+	}
+	return registry
+}
+
+// recordingEmailSender 只记录通知邮件的 EmailSender
+type recordingEmailSender struct {
+	EmailSender
+	sent []string
+	data []any
+}
+
+func (s *recordingEmailSender) SendPasswordChangedNotice(to, _ string, data *PasswordChangedEmailData) {
+	s.sent = append(s.sent, EmailTypePasswordChanged+":"+to)
+	s.data = append(s.data, data)
+}
+
+func (s *recordingEmailSender) SendAccountBannedNotice(to, _ string, data *AccountBannedEmailData) {
+	s.sent = append(s.sent, EmailTypeAccountBanned+":"+to)
+	s.data = append(s.data, data)
+}
+
+func (s *recordingEmailSender) SendOAuthAuthorizedNotice(to, _ string, data *OAuthAuthorizedEmailData) {
+	s.sent = append(s.sent, EmailTypeOAuthAuthorized+":"+to)
+	s.data = append(s.data, data)
+}
+
+func (s *recordingEmailSender) SendPasskeyAddedNotice(to, _ string, data *PasskeyAddedEmailData) {
+	s.sent = append(s.sent, EmailTypePasskeyAdded+":"+to)
+	s.data = append(s.data, data)
+}
+
+func (s *recordingEmailSender) SendAccountLockedNotice(to, _ string, data *AccountLockedEmailData) {
+	s.sent = append(s.sent, EmailTypeAccountLocked+":"+to)
+	s.data = append(s.data, data)
+}
+
+// fakeUserReader 按 UID 返回预置用户
+type fakeUserReader struct {
+	models.UserReader
+	users map[string]*models.User
+}
+
+func (f fakeUserReader) FindByUID(_ context.Context, uid string) (*models.User, error) {
+	if user, ok := f.users[uid]; ok {
+		return user, nil
+	}
+	return nil, nil
+}
+
+func TestEmailTemplateRegistryRender(t *testing.T) {
+	registry := loadTestEmailTemplates(t)
+
+	if len(registry.Types()) != len(emailTemplateCatalog) || len(registry.Languages()) != 3 {
+		t.Fatalf("types = %v languages = %v", registry.Types(), registry.Languages())
+	}
+	for _, emailType := range registry.Types() {
+		for _, lang := range registry.Languages() {
+			email, err := registry.Preview(emailType, lang)
+			if err != nil {
+				t.Fatalf("Preview(%s, %s) error = %v", emailType, lang, err)
+			}
+			if email.Subject == "" || !strings.Contains(email.HTMLBody, "<html lang=\""+lang+"\">") || email.TextBody == "" {
+				t.Errorf("Preview(%s, %s) = %+v", emailType, lang, email)
+			}
+			if strings.Contains(email.TextBody, "<strong>") || strings.Contains(email.TextBody, "&copy;") {
+				t.Errorf("Preview(%s, %s) text body contains HTML: %s", emailType, lang, email.TextBody)
+			}
+		}
+	}
+
+	email, err := registry.Render(EmailTypeNewLogin, "en", &NewLoginEmailData{
+		Time:      emailSampleTime,
+		IP:        "203.0.113.7",
+		Device:    "<script>alert(1)</script>",
+		SecureURL: "https://example.com/secure",
+	})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if strings.Contains(email.HTMLBody, "<script>") || !strings.Contains(email.HTMLBody, "&lt;script&gt;") {
+		t.Error("HTML body must escape template data")
+	}
+	if !strings.Contains(email.TextBody, "Device: <script>alert(1)</script>") || strings.Contains(email.TextBody, "Location:") {
+		t.Errorf("text body = %s (empty fields should be omitted)", email.TextBody)
+	}
+
+	banned, _ := registry.Render(EmailTypeAccountBanned, "zh-CN", &AccountBannedEmailData{Reason: "custom reason"})
+	if !strings.Contains(banned.TextBody, "custom reason") || !strings.Contains(banned.TextBody, "永久") {
+		t.Errorf("banned text body = %s", banned.TextBody)
+	}
+
+	if _, err := registry.Preview("nope", "en"); !errors.Is(err, ErrEmailUnknownType) {
+		t.Errorf("unknown type error = %v", err)
+	}
+}
+
+func TestEmailTemplateLanguageOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.CopyFS(dir, os.DirFS("../../data/email-templates")); err != nil {
+		t.Fatal(err)
+	}
+	override := `{{define "content"}}Custom English body{{end}}`
+	if err := os.WriteFile(filepath.Join(dir, "password_changed.en.txt"), []byte(override), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := LoadEmailTemplates(dir, "../../data/email-texts.json")
+	if err != nil {
+		t.Fatalf("LoadEmailTemplates() error = %v", err)
+	}
+	en, _ := registry.Preview(EmailTypePasswordChanged, "en")
+	zh, _ := registry.Preview(EmailTypePasswordChanged, "zh-CN")
+	if !strings.Contains(en.TextBody, "Custom English body") || strings.Contains(zh.TextBody, "Custom English body") {
+		t.Errorf("en = %q zh = %q, override should only apply to en", en.TextBody, zh.TextBody)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "verify.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadEmailTemplates(dir, "../../data/email-texts.json"); !errors.Is(err, ErrEmailTemplateNotFound) {
+		t.Errorf("missing template error = %v", err)
+	}
+}
+
+func TestEmailNoticeUserLogStore(t *testing.T) {
+	logErr := errors.New("db down")
+	sender := &recordingEmailSender{}
+	users := fakeUserReader{users: map[string]*models.User{"u1": {UID: "u1", Email: "a@example.com"}}}
+	store := NewEmailNoticeUserLogStore(noopUserLogStore{err: logErr}, users, sender, "https://example.com")
+	ctx := context.Background()
+
+	if err := store.LogChangePassword(ctx, "u1"); !errors.Is(err, logErr) {
+		t.Errorf("LogChangePassword err = %v, want log error passed through", err)
+	}
+	_ = store.LogBanned(ctx, "u1", "spam", nil)
+	_ = store.LogOAuthAuthorize(ctx, "u1", "client-1", "Example App", "openid profile")
+	_ = store.LogRegisterPasskey(ctx, "u1", 1, "MacBook")
+	_ = store.LogChangePassword(ctx, "missing")
+
+	want := []string{"password_changed:a@example.com", "account_banned:a@example.com", "oauth_authorized:a@example.com", "passkey_added:a@example.com"}
+	if strings.Join(sender.sent, ",") != strings.Join(want, ",") {
+		t.Fatalf("sent = %v, want %v", sender.sent, want)
+	}
+	if data := sender.data[0].(*PasswordChangedEmailData); data.ResetURL != "https://example.com/account/forgot" {
+		t.Errorf("password changed data = %+v", data)
+	}
+	if data := sender.data[2].(*OAuthAuthorizedEmailData); len(data.Scopes) != 2 || data.ManageURL != "https://example.com/account/dashboard" {
+		t.Errorf("oauth authorized data = %+v", data)
+	}
+	if data := sender.data[3].(*PasskeyAddedEmailData); data.Name != "MacBook" || data.ManageURL != "https://example.com/account/dashboard" {
+		t.Errorf("passkey added data = %+v", data)
+	}
+
+	if plain := NewEmailNoticeUserLogStore(noopUserLogStore{}, users, nil, ""); plain != (noopUserLogStore{}) {
+		t.Error("nil sender should return the store unwrapped")
+	}
+}
+
+func TestEmailRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 15 * time.Second},
+		{2, 30 * time.Second},
+		{5, 4 * time.Minute},
+		{20, emailRetryMaxDelay},
+	}
+	for _, tt := range tests {
+		if got := emailRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("emailRetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// testEmailBodyKey 测试用正文加密密钥
+var testEmailBodyKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestSendVerificationEmailAsyncEnqueues(t *testing.T) {
+	store := newFakeEmailQueueStore()
+	svc := newEmailService(&fakeEmailTransport{}, store, loadTestEmailTemplates(t), testEmailBodyKey)
+
+	svc.SendVerificationEmailAsync("a@example.com", "register", "fr", "https://example.com/verify?token=x", "TEST")
+	if len(store.enqueued) != 1 {
+		t.Fatalf("enqueued %d messages, want 1", len(store.enqueued))
+	}
+	msg := store.enqueued[0]
+	if msg.Recipient != "a@example.com" || msg.Subject != "【Nebula Studios】您的注册验证码" || !msg.BodyEncrypted {
+		t.Errorf("message = %+v", msg)
+	}
+	// 链接不得以明文入库
+	if strings.Contains(msg.HTMLBody, "token=x") || strings.Contains(msg.TextBody, "token=x") {
+		t.Error("verify link stored in plaintext")
+	}
+	if html, err := svc.openBody(msg.HTMLBody); err != nil || !strings.Contains(html, "https://example.com/verify?token=x") {
+		t.Errorf("openBody() = %q, %v", html, err)
+	}
+	if msg.ExpiresAt == nil || time.Until(*msg.ExpiresAt) > tokenExpiry {
+		t.Errorf("expires_at = %v, want within token expiry", msg.ExpiresAt)
+	}
+
+	svc.SendVerificationEmailAsync("a@example.com", "register", "", "javascript:alert(1)", "TEST")
+	if len(store.enqueued) != 1 {
+		t.Error("message with invalid verify URL should not be enqueued")
+	}
+}
+
+func TestSendVerificationEmailWithoutBodyKeySkipsQueue(t *testing.T) {
+	store := newFakeEmailQueueStore()
+	transport := &fakeEmailTransport{}
+	svc := newEmailService(transport, store, loadTestEmailTemplates(t), nil)
+
+	svc.SendVerificationEmailAsync("a@example.com", "register", "", "https://example.com/verify?token=x", "TEST")
+	svc.wg.Wait()
+	if len(store.enqueued) != 0 {
+		t.Errorf("enqueued %d messages, want 0 without body key", len(store.enqueued))
+	}
+	if len(transport.sent) != 1 {
+		t.Errorf("sent %d emails, want 1 (direct send)", len(transport.sent))
+	}
+
+	// 不含链接的通知照常入队
+	svc.SendPasswordChangedNotice("a@example.com", "", &PasswordChangedEmailData{ResetURL: "https://example.com/forgot"})
+	if len(store.enqueued) != 1 || store.enqueued[0].BodyEncrypted {
+		t.Errorf("enqueued = %+v, want one plaintext notice", store.enqueued)
+	}
+}
+
+func TestEmailDeliverDue(t *testing.T) {
+	expired := time.Now().Add(-time.Minute)
+	svc := newEmailService(nil, nil, nil, testEmailBodyKey)
+	sealed, err := svc.sealBody("<a href=\"https://example.com/reset?token=x\">reset</a>")
+	if err != nil {
+		t.Fatalf("sealBody() error = %v", err)
+	}
+	store := newFakeEmailQueueStore()
+	store.due = []*models.EmailMessage{
+		{ID: 1, Recipient: "ok@example.com", Subject: "s"},
+		{ID: 2, Recipient: "bounce@example.com", Subject: "s"},
+		{ID: 3, Recipient: "retry@example.com", Subject: "s", Attempts: 2},
+		{ID: 4, Recipient: "retry@example.com", Subject: "s", Attempts: emailMaxAttempts - 1},
+		{ID: 5, Recipient: "ok@example.com", Subject: "s", ExpiresAt: &expired},
+		{ID: 6, Recipient: "ok@example.com", Subject: "s", HTMLBody: sealed, BodyEncrypted: true},
+		{ID: 7, Recipient: "ok@example.com", Subject: "s", HTMLBody: "garbage", BodyEncrypted: true},
+	}
+	transport := &fakeEmailTransport{errs: map[string]error{
+		"bounce@example.com": NewPermanentEmailError(errors.New("550 mailbox unavailable")),
+		"retry@example.com":  errors.New("connection refused"),
+	}}
+
+	svc = newEmailService(transport, store, loadTestEmailTemplates(t), testEmailBodyKey)
+	start := time.Now()
+	n, err := svc.DeliverDue(context.Background())
+	if err != nil || n != 7 {
+		t.Fatalf("DeliverDue = %d, %v", n, err)
+	}
+
+	if got := store.attempts[1]; got.Status != models.EmailStatusSent || got.Transport != "fake" {
+		t.Errorf("message 1 = %+v", got)
+	}
+	if got := store.attempts[2]; got.Status != models.EmailStatusBounced || !strings.Contains(got.Error, "550") {
+		t.Errorf("message 2 = %+v", got)
+	}
+	retry := store.attempts[3]
+	if retry.Status != models.EmailStatusPending || retry.Error != "connection refused" {
+		t.Errorf("message 3 = %+v", retry)
+	}
+	if wait := retry.NextAttemptAt.Sub(start); wait < emailRetryDelay(3) || wait > emailRetryDelay(3)+time.Minute {
+		t.Errorf("message 3 retry in %v, want ~%v", wait, emailRetryDelay(3))
+	}
+	if got := store.attempts[4]; got.Status != models.EmailStatusFailed {
+		t.Errorf("message 4 status = %q, want failed after max attempts", got.Status)
+	}
+	if got := store.attempts[5]; got.Status != models.EmailStatusFailed || got.Error != "expired before delivery" {
+		t.Errorf("message 5 = %+v", got)
+	}
+	if got := store.attempts[6]; got.Status != models.EmailStatusSent {
+		t.Errorf("message 6 = %+v", got)
+	}
+	if got := store.attempts[7]; got.Status != models.EmailStatusFailed || got.Error != "cannot decrypt email body" {
+		t.Errorf("message 7 = %+v", got)
+	}
+	if len(transport.sent) != 2 {
+		t.Fatalf("sent %d emails, want 2 (expired message must not be sent)", len(transport.sent))
+	}
+	if body := transport.sent[1].HTMLBody; !strings.Contains(body, "reset?token=x") {
+		t.Errorf("encrypted message sent with body %q, want decrypted", body)
+	}
+}
+
+func TestHTTPEmailTransport(t *testing.T) {
+	var gotAuth, gotToken string
+	var gotBody map[string]any
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotToken = r.Header.Get("X-Postmark-Server-Token")
+		gotBody = nil
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	email := &OutboundEmail{To: "a@example.com", Subject: "Hello", HTMLBody: "<p>hi</p>", TextBody: "hi"}
+
+	resend, err := NewHTTPEmailTransport(config.EmailTransportResend, "re_key", server.URL, "noreply@example.com")
+	if err != nil {
+		t.Fatalf("NewHTTPEmailTransport() error = %v", err)
+	}
+	if err := resend.Send(context.Background(), email); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if gotAuth != "Bearer re_key" || gotBody["from"] != "noreply@example.com" || gotBody["html"] != "<p>hi</p>" {
+		t.Errorf("resend auth = %q body = %v", gotAuth, gotBody)
+	}
+
+	postmark, _ := NewHTTPEmailTransport(config.EmailTransportPostmark, "pm_key", server.URL, "noreply@example.com")
+	if err := postmark.Send(context.Background(), email); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if gotToken != "pm_key" || gotBody["To"] != "a@example.com" || gotBody["TextBody"] != "hi" {
+		t.Errorf("postmark token = %q body = %v", gotToken, gotBody)
+	}
+
+	status = http.StatusUnprocessableEntity
+	if err := resend.Send(context.Background(), email); !IsPermanentEmailError(err) {
+		t.Errorf("422 error = %v, want permanent", err)
+	}
+	status = http.StatusServiceUnavailable
+	if err := resend.Send(context.Background(), email); err == nil || IsPermanentEmailError(err) {
+		t.Errorf("503 error = %v, want retryable", err)
+	}
+
+	injected := &OutboundEmail{To: "a@example.com", Subject: "Hi\r\nBcc: x@example.com"}
+	if err := resend.Send(context.Background(), injected); !errors.Is(err, ErrEmailHeaderInjection) || !IsPermanentEmailError(err) {
+		t.Errorf("header injection error = %v", err)
+	}
+
+	if _, err := NewHTTPEmailTransport(config.EmailTransportSendGrid, "", "", "noreply@example.com"); !errors.Is(err, ErrEmailAPIKeyMissing) {
+		t.Errorf("missing API key error = %v", err)
+	}
+}
+
+func TestFileEmailTransport(t *testing.T) {
+	dir := t.TempDir()
+	transport, err := NewFileEmailTransport(dir, "noreply@example.com")
+	if err != nil {
+		t.Fatalf("NewFileEmailTransport() error = %v", err)
+	}
+	if err := transport.Verify(context.Background()); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	email := &OutboundEmail{To: "a@example.com", Subject: "Hello", HTMLBody: "<p>hi</p>", TextBody: "hi"}
+	if err := transport.Send(context.Background(), email); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	files, _ := os.ReadDir(filepath.Join(dir, "new"))
+	if len(files) != 1 {
+		t.Fatalf("maildir new/ has %d files, want 1", len(files))
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "new", files[0].Name()))
+	if !strings.Contains(string(data), "Subject: Hello") || !strings.Contains(string(data), "To: <a@example.com>") {
+		t.Errorf("message = %s", data)
+	}
+	if tmp, _ := os.ReadDir(filepath.Join(dir, "tmp")); len(tmp) != 0 {
+		t.Errorf("maildir tmp/ should be empty, has %d files", len(tmp))
+	}
+}
+
+func TestNewEmailTransport(t *testing.T) {
+	cfg := &config.Config{EmailTransport: "carrier-pigeon"}
+	if _, err := NewEmailTransport(cfg); !errors.Is(err, ErrEmailUnknownTransport) {
+		t.Errorf("unknown transport error = %v", err)
+	}
+
+	cfg = &config.Config{EmailTransport: config.EmailTransportFile, EmailFileDir: t.TempDir()}
+	transport, err := NewEmailTransport(cfg)
+	if err != nil || transport.Name() != config.EmailTransportFile {
+		t.Errorf("file transport = %v, %v", transport, err)
+	}
+}
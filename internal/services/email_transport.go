@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"auth-system/internal/config"
+)
+
+var (
+	ErrEmailUnknownTransport = errors.New("unknown email transport")
+	ErrEmailAPIKeyMissing    = errors.New("email API key is missing")
+	ErrEmailFromMissing      = errors.New("email from address is missing")
+)
+
+// OutboundEmail 一封已渲染、待交给发送后端的邮件
+type OutboundEmail struct {
+	To       string
+	Subject  string
+	HTMLBody string
+	TextBody string
+}
+
+// EmailTransport 邮件发送后端（SMTP、HTTP API 服务商或本地文件）。
+// Send 返回 permanent 错误（见 NewPermanentEmailError）表示后端已明确拒收，队列不再重试
+type EmailTransport interface {
+	Name() string
+	Send(ctx context.Context, email *OutboundEmail) error
+	Verify(ctx context.Context) error
+	Close()
+}
+
+// permanentEmailError 后端永久拒收（地址不存在、被拒信、请求非法等），重试不会成功
+type permanentEmailError struct {
+	err error
+}
+
+func (e *permanentEmailError) Error() string { return e.err.Error() }
+func (e *permanentEmailError) Unwrap() error { return e.err }
+
+// NewPermanentEmailError 将发送错误标记为永久失败（退信）
+func NewPermanentEmailError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentEmailError{err: err}
+}
+
+// IsPermanentEmailError 判断发送错误是否为永久失败
+func IsPermanentEmailError(err error) bool {
+	var permanent *permanentEmailError
+	return errors.As(err, &permanent)
+}
+
+// NewEmailTransport 按 EMAIL_TRANSPORT 创建发送后端
+func NewEmailTransport(cfg *config.Config) (EmailTransport, error) {
+	if cfg == nil {
+		return nil, ErrEmailNilConfig
+	}
+
+	switch cfg.EmailTransport {
+	case config.EmailTransportSMTP, "":
+		return NewSMTPTransport(cfg)
+	case config.EmailTransportResend, config.EmailTransportPostmark, config.EmailTransportSendGrid:
+		return NewHTTPEmailTransport(cfg.EmailTransport, cfg.EmailAPIKey, cfg.EmailAPIURL, cfg.EmailFrom)
+	case config.EmailTransportFile:
+		return NewFileEmailTransport(cfg.EmailFileDir, cfg.EmailFrom)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrEmailUnknownTransport, cfg.EmailTransport)
+	}
+}
+
+// validateOutboundEmail 校验收件人、主题与发件人，拒绝含 CR/LF 的头部字段（防止邮件头注入）
+func validateOutboundEmail(email *OutboundEmail, from string) error {
+	if email.To == "" {
+		return ErrEmailEmptyRecipient
+	}
+	if email.Subject == "" {
+		return ErrEmailEmptySubject
+	}
+	if containsNewline(email.To) || containsNewline(email.Subject) || containsNewline(from) {
+		return ErrEmailHeaderInjection
+	}
+	return nil
+}
+
+// containsNewline 检查字符串是否含 CR/LF，用于防止邮件头部注入
+func containsNewline(s string) bool {
+	return strings.ContainsAny(s, "\r\n")
+}
@@ -14,8 +14,10 @@ import (
 
 const (
 	defaultExportTokenCapacity = 1000
-	defaultExportTokenTTL      = 5 * time.Minute
 	exportTokenCleanupInterval = 5 * time.Minute
+
+	// ExportTokenTTL 导出 Token 有效期，同时作为导出就绪邮件中下载链接的过期时间
+	ExportTokenTTL = 5 * time.Minute
 )
 
 type exportTokenEntry struct {
@@ -58,7 +60,7 @@ func (s *ExportTokenService) Generate(userUID string) (string, error) {
 
 	s.cache.Add(token, &exportTokenEntry{
 		UserUID:   userUID,
-		ExpiresAt: time.Now().Add(defaultExportTokenTTL),
+		ExpiresAt: time.Now().Add(ExportTokenTTL),
 	})
 
 	utils.LogInfo("EXPORT_TOKEN", "Token generated", "user_uid", userUID)
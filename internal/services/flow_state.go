@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"auth-system/internal/models"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// 两步验证挑战、Passkey 挑战在流程状态存储中的 kind（oauth_flow_states.kind 最长 16 字符）
+const (
+	flowKindTwoFactorChallenge = "two_factor"
+	flowKindWebAuthnChallenge  = "webauthn"
+)
+
+const defaultFlowStateCapacity = 20000
+
+type flowStateEntry struct {
+	payload   []byte
+	expiresAt time.Time
+}
+
+// MemoryFlowStateStore models.OAuthFlowStateStore 的进程内存实现（OAUTH_STATE_BACKEND=memory）：
+// 单实例部署使用，重启丢失，多实例间不共享。过期条目在读取时视为不存在，容量满时按 LRU 淘汰。
+type MemoryFlowStateStore struct {
+	entries *lru.Cache[string, *flowStateEntry]
+	mu      sync.Mutex // 保证 GetAndDelete 的读-删原子性
+}
+
+// NewMemoryFlowStateStore 创建内存流程状态存储
+func NewMemoryFlowStateStore() (*MemoryFlowStateStore, error) {
+	cache, err := lru.New[string, *flowStateEntry](defaultFlowStateCapacity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create flow state cache: %w", err)
+	}
+	return &MemoryFlowStateStore{entries: cache}, nil
+}
+
+func flowStateKey(kind, key string) string {
+	return kind + ":" + key
+}
+
+// Save 保存状态，ttl 后过期；同一 key 重复保存时覆盖
+func (s *MemoryFlowStateStore) Save(_ context.Context, kind, key string, payload []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries.Add(flowStateKey(kind, key), &flowStateEntry{payload: payload, expiresAt: time.Now().Add(ttl)})
+	return nil
+}
+
+// Get 读取未过期的状态，不存在或已过期返回 ErrOAuthFlowStateNotFound
+func (s *MemoryFlowStateStore) Get(_ context.Context, kind, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getLocked(flowStateKey(kind, key), false)
+}
+
+// GetAndDelete 原子地读取并删除状态，并发消费时只有一方成功
+func (s *MemoryFlowStateStore) GetAndDelete(_ context.Context, kind, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getLocked(flowStateKey(kind, key), true)
+}
+
+func (s *MemoryFlowStateStore) getLocked(k string, remove bool) ([]byte, error) {
+	entry, ok := s.entries.Get(k)
+	if !ok {
+		return nil, models.ErrOAuthFlowStateNotFound
+	}
+	if remove || !time.Now().Before(entry.expiresAt) {
+		s.entries.Remove(k)
+	}
+	if !time.Now().Before(entry.expiresAt) {
+		return nil, models.ErrOAuthFlowStateNotFound
+	}
+	return entry.payload, nil
+}
+
+// Delete 删除状态（不存在时忽略）
+func (s *MemoryFlowStateStore) Delete(_ context.Context, kind, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries.Remove(flowStateKey(kind, key))
+	return nil
+}
+
+// DeleteExpired 删除全部过期状态，返回删除数量
+func (s *MemoryFlowStateStore) DeleteExpired(context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var count int64
+	for _, k := range s.entries.Keys() {
+		if entry, ok := s.entries.Peek(k); ok && !now.Before(entry.expiresAt) {
+			s.entries.Remove(k)
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"auth-system/internal/models"
+)
+
+func TestMemoryFlowStateStore(t *testing.T) {
+	store, err := NewMemoryFlowStateStore()
+	if err != nil {
+		t.Fatalf("NewMemoryFlowStateStore() error = %v", err)
+	}
+	ctx := context.Background()
+
+	if err := store.Save(ctx, "kind", "k1", []byte(`{"a":1}`), time.Minute); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := store.Get(ctx, "other", "k1"); !errors.Is(err, models.ErrOAuthFlowStateNotFound) {
+		t.Errorf("Get() with another kind error = %v, want not found", err)
+	}
+	if payload, err := store.Get(ctx, "kind", "k1"); err != nil || string(payload) != `{"a":1}` {
+		t.Fatalf("Get() = (%s, %v)", payload, err)
+	}
+
+	// GetAndDelete 一次性消费
+	if _, err := store.GetAndDelete(ctx, "kind", "k1"); err != nil {
+		t.Fatalf("GetAndDelete() error = %v", err)
+	}
+	if _, err := store.GetAndDelete(ctx, "kind", "k1"); !errors.Is(err, models.ErrOAuthFlowStateNotFound) {
+		t.Errorf("second GetAndDelete() error = %v, want not found", err)
+	}
+
+	// 过期条目读取时视为不存在
+	_ = store.Save(ctx, "kind", "k2", []byte(`{}`), -time.Second)
+	if _, err := store.Get(ctx, "kind", "k2"); !errors.Is(err, models.ErrOAuthFlowStateNotFound) {
+		t.Errorf("expired Get() error = %v, want not found", err)
+	}
+	_ = store.Save(ctx, "kind", "k3", []byte(`{}`), -time.Second)
+	if n, err := store.DeleteExpired(ctx); err != nil || n != 1 {
+		t.Errorf("DeleteExpired() = (%d, %v), want 1", n, err)
+	}
+}
@@ -6,17 +6,30 @@ import (
 
 	"auth-system/internal/cache"
 	"auth-system/internal/models"
+	"auth-system/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SessionManager Session 服务接口
 type SessionManager interface {
-	GenerateTokens(ctx context.Context, uid string, banned bool) (accessToken string, refreshToken string, err error)
+	GenerateTokens(ctx context.Context, uid string, banned bool) (accessToken string, refreshToken string, familyID string, err error)
+	GenerateImpersonationToken(ctx context.Context, uid, impersonatorUID string) (accessToken string, expiresAt time.Time, err error)
 	RefreshTokens(ctx context.Context, refreshToken string) (newAccessToken string, newRefreshToken string, err error)
 	RevokeUserTokens(ctx context.Context, uid string) error
 	RevokeTokenFamily(ctx context.Context, uid string, familyID string) error
+	ListSessions(ctx context.Context, uid string) ([]*models.SessionToken, error)
+	RevokeOtherSessions(ctx context.Context, uid string, keepFamilyID string) (int64, error)
 	VerifyToken(tokenString string) (*Claims, error)
+	// CheckSession 拒绝所属会话已被撤销的 access_token（VerifyToken 只校验签名与有效期）
+	CheckSession(ctx context.Context, claims *Claims) error
+}
+
+// IDTokenSigner OIDC ID Token 签名接口（复用 Session 的 ES256 密钥）
+type IDTokenSigner interface {
+	SignIDToken(claims *IDTokenClaims) (string, error)
+	VerifyIDToken(tokenString string) (*IDTokenClaims, error)
+	JWKS() *JWKSet
 }
 
 // TokenManager Token 服务接口
@@ -46,19 +59,46 @@ type EmailSender interface {
 	VerifyConnection() error
 	SendVerificationEmailAsync(to, emailType, language, verifyURL, logContext string)
 	SendVerificationEmail(to, emailType, language, verifyURL string) error
+	SendNewLoginAlert(to, language string, data *NewLoginEmailData)
+	SendPasswordChangedNotice(to, language string, data *PasswordChangedEmailData)
+	SendAccountBannedNotice(to, language string, data *AccountBannedEmailData)
+	SendOAuthAuthorizedNotice(to, language string, data *OAuthAuthorizedEmailData)
+	SendExportReadyNotice(to, language string, data *ExportReadyEmailData)
+	SendAccountLockedNotice(to, language string, data *AccountLockedEmailData)
+	SendPasskeyAddedNotice(to, language string, data *PasskeyAddedEmailData)
 	IsConfigured() bool
 	Close()
 }
 
+// EmailTemplatePreviewer 邮件模板预览接口（管理后台使用）
+type EmailTemplatePreviewer interface {
+	EmailTemplateTypes() []string
+	EmailLanguages() []string
+	PreviewEmail(emailType, language string) (*RenderedEmail, error)
+}
+
+// EmailQueueManager 出站邮件队列接口（管理统计与定时清理使用）
+type EmailQueueManager interface {
+	QueueStats(ctx context.Context) (*models.EmailQueueStats, error)
+	CleanupMessages(ctx context.Context) (int64, error)
+}
+
 // OAuthProviderStore OAuth 授权服务器接口（OAuth Provider Handler 使用）
 type OAuthProviderStore interface {
 	ValidateClientID(ctx context.Context, clientID string) (*models.OAuthClient, error)
 	ValidateRedirectURI(client *models.OAuthClient, redirectURI string) bool
-	CreateAuthorizationCode(ctx context.Context, clientID string, userUID string, redirectURI, scope, codeChallenge, codeChallengeMethod string) (string, error)
+	CreateAuthorizationCode(ctx context.Context, clientID string, userUID string, redirectURI, scope, codeChallenge, codeChallengeMethod, nonce string, authTime time.Time) (string, error)
 	ValidateClient(ctx context.Context, clientID, clientSecret string) (*models.OAuthClient, error)
 	ExchangeAuthorizationCode(ctx context.Context, code, clientID, redirectURI, codeVerifier string) (*OAuthTokenResponse, string, error)
 	RefreshAccessToken(ctx context.Context, refreshToken, clientID string) (*OAuthTokenResponse, string, error)
+	IssueClientCredentialsToken(ctx context.Context, client *models.OAuthClient, scope string) (*OAuthTokenResponse, error)
 	ValidateAccessToken(ctx context.Context, accessToken string) (*models.OAuthAccessToken, error)
+	IntrospectToken(ctx context.Context, token, tokenTypeHint string) (*OAuthTokenInfo, error)
+	GetScopes(ctx context.Context, names []string) ([]*models.OAuthScope, error)
+	CreateDeviceAuthorization(ctx context.Context, clientID, scope string) (*OAuthDeviceAuthorization, error)
+	GetDeviceAuthorization(ctx context.Context, userCode string) (*models.OAuthDeviceCode, error)
+	DecideDeviceAuthorization(ctx context.Context, userCode, userUID string, approve bool) (*models.OAuthDeviceCode, error)
+	ExchangeDeviceCode(ctx context.Context, deviceCode, clientID string) (*OAuthTokenResponse, string, error)
 	RevokeToken(ctx context.Context, token string) error
 }
 
@@ -66,11 +106,15 @@ type OAuthProviderStore interface {
 type OAuthAdminManager interface {
 	GetClients(ctx context.Context, page, pageSize int, search string) ([]*models.OAuthClient, int64, error)
 	GetClient(ctx context.Context, id int64) (*models.OAuthClient, error)
-	CreateClient(ctx context.Context, name, description, redirectURI string) (*models.OAuthClient, string, error)
-	UpdateClient(ctx context.Context, id int64, name string, description *string, redirectURI string) error
+	CreateClient(ctx context.Context, name, description string, redirectURIs, postLogoutRedirectURIs, clientScopes, allowedScopes []string, claimMapping models.OAuthClaimMapping) (*models.OAuthClient, string, error)
+	UpdateClient(ctx context.Context, id int64, name string, description *string, redirectURIs []string, postLogoutRedirectURIs, clientScopes, allowedScopes *[]string, claimMapping *models.OAuthClaimMapping) error
 	DeleteClient(ctx context.Context, id int64) error
 	RegenerateSecret(ctx context.Context, id int64) (string, error)
 	ToggleClient(ctx context.Context, id int64, enabled bool) error
+	ListScopes(ctx context.Context) ([]*models.OAuthScope, error)
+	CreateScope(ctx context.Context, name string, descriptions map[string]string) (*models.OAuthScope, error)
+	UpdateScope(ctx context.Context, id int64, descriptions map[string]string) (*models.OAuthScope, error)
+	DeleteScope(ctx context.Context, id int64) (*models.OAuthScope, error)
 }
 
 // OAuthGrantManager OAuth 授权管理接口（用户中心使用）
@@ -89,6 +133,57 @@ type OAuthClientManager interface {
 	OAuthGrantManager
 }
 
+// WebhookPublisher 发布账户生命周期事件（写入投递队列，由后台任务异步投递）
+type WebhookPublisher interface {
+	Publish(ctx context.Context, eventType string, data any) error
+}
+
+// WebhookAdminManager Webhook 端点管理接口（管理后台使用）
+type WebhookAdminManager interface {
+	ListEndpoints(ctx context.Context) ([]*models.WebhookEndpoint, error)
+	GetEndpoint(ctx context.Context, id int64) (*models.WebhookEndpoint, error)
+	CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) (secret string, err error)
+	UpdateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	DeleteEndpoint(ctx context.Context, id int64) (*models.WebhookEndpoint, error)
+	RegenerateSecret(ctx context.Context, id int64) (string, error)
+	ListDeliveries(ctx context.Context, endpointID int64, page, pageSize int) ([]*models.WebhookDelivery, int64, error)
+	Redeliver(ctx context.Context, endpointID, deliveryID int64) (*models.WebhookDelivery, error)
+}
+
+// WebhookDeliverer Webhook 投递接口（后台任务使用）
+type WebhookDeliverer interface {
+	DeliverDue(ctx context.Context) (int, error)
+	CleanupDeliveries(ctx context.Context) (int64, error)
+}
+
+// WebhookManager Webhook 服务接口（全量组合：依赖装配使用）
+type WebhookManager interface {
+	WebhookPublisher
+	WebhookAdminManager
+	WebhookDeliverer
+}
+
+// LoginRiskChecker 新设备登录检查接口（登录入口、"不是我本人" 与定时清理使用）
+type LoginRiskChecker interface {
+	CheckLogin(ctx context.Context, userUID, familyID string, client utils.ClientInfo)
+	ConsumeLoginAlert(ctx context.Context, token string) (*models.LoginAlert, error)
+	ForgetDevice(ctx context.Context, userUID string, deviceID int64) error
+	CleanupExpired(ctx context.Context) (int64, error)
+}
+
+// LoginLockoutManager 按账户的密码登录失败限制接口（密码登录与解锁链接使用）
+type LoginLockoutManager interface {
+	RetryAfter(user *models.User, now time.Time) (time.Duration, bool)
+	RecordFailure(ctx context.Context, user *models.User, ip string) (time.Time, error)
+	ResetFailures(ctx context.Context, userUID string) error
+	Unlock(ctx context.Context, token string) (string, error)
+}
+
+// PasswordScreener 新密码筛查接口（注册、重置与修改密码使用）
+type PasswordScreener interface {
+	Screen(password string, userInputs ...string) *PasswordScreenResult
+}
+
 // WebSocketManager WebSocket 服务接口
 type WebSocketManager interface {
 	HandleQRLogin(c *gin.Context)
@@ -131,6 +226,27 @@ type ExportTokenManager interface {
 	Stop()
 }
 
+// TwoFactorManager 两步验证服务接口
+type TwoFactorManager interface {
+	IsConfigured() bool
+	NewEnrollment(accountName string) (*TOTPEnrollment, error)
+	VerifyCode(encryptedSecret, code string, afterStep int64) (int64, bool, error)
+	GenerateRecoveryCodes() ([]string, []string, error)
+	HashRecoveryCode(code string) string
+	CreateChallenge(ctx context.Context, userUID string) (string, error)
+	// AttemptChallenge 校验验证码前计入一次尝试并返回挑战对应的用户
+	AttemptChallenge(ctx context.Context, token string) (string, bool)
+	ConsumeChallenge(ctx context.Context, token string)
+}
+
+// WebAuthnManager Passkey 服务接口
+type WebAuthnManager interface {
+	BeginRegistration(ctx context.Context, userUID, userName, displayName string, exclude []*models.WebAuthnCredential) (*WebAuthnRegistrationOptions, error)
+	FinishRegistration(ctx context.Context, userUID string, resp *WebAuthnRegistrationResponse) (*WebAuthnNewCredential, error)
+	BeginLogin(ctx context.Context) (*WebAuthnLoginOptions, error)
+	FinishLogin(ctx context.Context, resp *WebAuthnAssertionResponse, cred *models.WebAuthnCredential) (uint32, error)
+}
+
 // UserCacheStore 用户缓存接口
 type UserCacheStore interface {
 	Get(uid string) (*models.User, bool)
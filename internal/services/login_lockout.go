@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"auth-system/internal/models"
+	"auth-system/internal/paths"
+	"auth-system/internal/utils"
+)
+
+const (
+	// loginFailureWindow 上次失败超过该时长后重新计数
+	loginFailureWindow = 24 * time.Hour
+
+	// 失败达到 loginDelayThreshold 次起，下一次尝试前需等待 1s、2s、4s……，最长 loginMaxDelay
+	loginDelayThreshold = 3
+	loginMaxDelay       = time.Minute
+
+	// 失败达到 loginLockThreshold 次时锁定 loginLockBase，锁定到期后再次失败则锁定时长翻倍，最长 loginLockMax
+	loginLockThreshold = 10
+	loginLockBase      = 15 * time.Minute
+	loginLockMax       = 24 * time.Hour
+)
+
+// LoginLockoutService 按账户限制密码登录失败：失败次数增加时要求逐渐延长的等待间隔，
+// 达到阈值后临时锁定并向用户发送解锁链接。与按 IP 的 LoginRateLimit 互补，防止分布式的针对单一账户的猜测
+type LoginLockoutService struct {
+	store   models.UserLoginLockStore
+	email   EmailSender
+	baseURL string
+}
+
+// NewLoginLockoutService 创建登录锁定服务；email 为 nil 时仍锁定但不发送解锁邮件（只能等待锁定到期）
+func NewLoginLockoutService(store models.UserLoginLockStore, email EmailSender, baseURL string) *LoginLockoutService {
+	return &LoginLockoutService{store: store, email: email, baseURL: baseURL}
+}
+
+// RetryAfter 返回该账户下一次允许尝试密码登录前还需等待的时长；locked 表示处于锁定期（否则为递增延迟）
+func (s *LoginLockoutService) RetryAfter(user *models.User, now time.Time) (time.Duration, bool) {
+	if user.IsLoginLocked(now) {
+		return user.LockedUntil.Time.Sub(now), true
+	}
+	if !user.LastFailedLoginAt.Valid || now.Sub(user.LastFailedLoginAt.Time) > loginFailureWindow {
+		return 0, false
+	}
+	next := user.LastFailedLoginAt.Time.Add(loginRetryDelay(user.FailedLoginCount))
+	if now.Before(next) {
+		return next.Sub(now), false
+	}
+	return 0, false
+}
+
+// RecordFailure 累计一次密码错误，达到锁定阈值时锁定账户并发送解锁邮件；
+// 返回锁定截止时间，未锁定时为零值
+func (s *LoginLockoutService) RecordFailure(ctx context.Context, user *models.User, ip string) (time.Time, error) {
+	now := time.Now()
+	count, err := s.store.RecordLoginFailure(ctx, user.UID, now.Add(-loginFailureWindow))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	lockFor := loginLockDuration(count)
+	if lockFor == 0 {
+		return time.Time{}, nil
+	}
+
+	token, err := utils.GenerateSecureToken()
+	if err != nil {
+		return time.Time{}, utils.LogError("LOGIN-LOCKOUT", "RecordFailure", err, "user_uid", user.UID)
+	}
+	until := now.Add(lockFor)
+	if err := s.store.LockLogin(ctx, user.UID, until, utils.HashToken(token)); err != nil {
+		return time.Time{}, err
+	}
+
+	utils.LogWarnCtx(ctx, "LOGIN-LOCKOUT", "Account locked after repeated login failures",
+		"user_uid", user.UID, "failures", count, "ip", ip, "locked_until", until)
+
+	if s.email != nil && user.Email != "" {
+		s.email.SendAccountLockedNotice(user.Email, "", &AccountLockedEmailData{
+			IP:          ip,
+			LockedUntil: until,
+			UnlockURL:   s.baseURL + paths.PathAccountUnlock + "#token=" + url.QueryEscape(token),
+		})
+	}
+	return until, nil
+}
+
+// ResetFailures 登录成功后清零失败计数并解除锁定
+func (s *LoginLockoutService) ResetFailures(ctx context.Context, userUID string) error {
+	return s.store.ResetLoginFailures(ctx, userUID)
+}
+
+// Unlock 凭邮件中的解锁链接 Token 解除锁定，返回用户 UID；
+// Token 无效或锁定已到期时返回 models.ErrUnlockTokenNotFound
+func (s *LoginLockoutService) Unlock(ctx context.Context, token string) (string, error) {
+	if token == "" {
+		return "", models.ErrUnlockTokenNotFound
+	}
+	return s.store.UnlockLogin(ctx, utils.HashToken(token))
+}
+
+// loginRetryDelay 累计失败 failures 次后，下一次尝试前需等待的时长
+func loginRetryDelay(failures int) time.Duration {
+	if failures < loginDelayThreshold {
+		return 0
+	}
+	shift := failures - loginDelayThreshold
+	if shift >= 6 {
+		return loginMaxDelay
+	}
+	return min(time.Second<<shift, loginMaxDelay)
+}
+
+// loginLockDuration 累计失败 failures 次时的锁定时长，未达到阈值返回 0
+func loginLockDuration(failures int) time.Duration {
+	if failures < loginLockThreshold {
+		return 0
+	}
+	shift := failures - loginLockThreshold
+	if shift >= 7 {
+		return loginLockMax
+	}
+	return min(loginLockBase<<shift, loginLockMax)
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"auth-system/internal/models"
+	"auth-system/internal/utils"
+)
+
+// fakeLoginLockStore 内存版失败计数，只记录一个用户
+type fakeLoginLockStore struct {
+	count      int
+	lockedTill time.Time
+	tokenHash  string
+}
+
+func (f *fakeLoginLockStore) RecordLoginFailure(context.Context, string, time.Time) (int, error) {
+	f.count++
+	return f.count, nil
+}
+func (f *fakeLoginLockStore) LockLogin(_ context.Context, _ string, until time.Time, unlockTokenHash string) error {
+	f.lockedTill, f.tokenHash = until, unlockTokenHash
+	return nil
+}
+func (f *fakeLoginLockStore) ResetLoginFailures(context.Context, string) error {
+	*f = fakeLoginLockStore{}
+	return nil
+}
+func (f *fakeLoginLockStore) UnlockLogin(ctx context.Context, unlockTokenHash string) (string, error) {
+	if f.tokenHash == "" || f.tokenHash != unlockTokenHash {
+		return "", models.ErrUnlockTokenNotFound
+	}
+	return "uid-1", f.ResetLoginFailures(ctx, "uid-1")
+}
+
+func TestLoginRetryDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{8, 32 * time.Second},
+		{9, time.Minute},
+		{100, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := loginRetryDelay(tt.failures); got != tt.want {
+			t.Errorf("loginRetryDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginLockDuration(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{9, 0},
+		{10, 15 * time.Minute},
+		{11, 30 * time.Minute},
+		{15, 8 * time.Hour},
+		{16, 16 * time.Hour},
+		{17, 24 * time.Hour},
+		{100, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := loginLockDuration(tt.failures); got != tt.want {
+			t.Errorf("loginLockDuration(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginLockoutRetryAfter(t *testing.T) {
+	s := NewLoginLockoutService(&fakeLoginLockStore{}, nil, "")
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		wantWait   time.Duration
+		wantLocked bool
+	}{
+		{
+			name: "no failures",
+			user: &models.User{},
+		},
+		{
+			name:     "within delay",
+			user:     &models.User{FailedLoginCount: 4, LastFailedLoginAt: sql.NullTime{Valid: true, Time: now.Add(-time.Second)}},
+			wantWait: time.Second,
+		},
+		{
+			name: "delay elapsed",
+			user: &models.User{FailedLoginCount: 4, LastFailedLoginAt: sql.NullTime{Valid: true, Time: now.Add(-3 * time.Second)}},
+		},
+		{
+			name: "failures outside window",
+			user: &models.User{FailedLoginCount: 9, LastFailedLoginAt: sql.NullTime{Valid: true, Time: now.Add(-25 * time.Hour)}},
+		},
+		{
+			name:       "locked",
+			user:       &models.User{FailedLoginCount: 10, LockedUntil: sql.NullTime{Valid: true, Time: now.Add(10 * time.Minute)}},
+			wantWait:   10 * time.Minute,
+			wantLocked: true,
+		},
+		{
+			name: "lock expired",
+			user: &models.User{FailedLoginCount: 10, LockedUntil: sql.NullTime{Valid: true, Time: now.Add(-time.Minute)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, locked := s.RetryAfter(tt.user, now)
+			if wait != tt.wantWait || locked != tt.wantLocked {
+				t.Errorf("RetryAfter() = (%v, %v), want (%v, %v)", wait, locked, tt.wantWait, tt.wantLocked)
+			}
+		})
+	}
+}
+
+func TestLoginLockoutRecordFailureLocksAndUnlocks(t *testing.T) {
+	store := &fakeLoginLockStore{count: loginLockThreshold - 2}
+	email := &recordingEmailSender{}
+	s := NewLoginLockoutService(store, email, "https://example.com")
+	user := &models.User{UID: "uid-1", Email: "alice@example.com"}
+	ctx := context.Background()
+
+	until, err := s.RecordFailure(ctx, user, "203.0.113.9")
+	if err != nil || !until.IsZero() {
+		t.Fatalf("below threshold: RecordFailure() = (%v, %v), want no lock", until, err)
+	}
+
+	until, err = s.RecordFailure(ctx, user, "203.0.113.9")
+	if err != nil {
+		t.Fatalf("RecordFailure() error = %v", err)
+	}
+	if until.IsZero() || !store.lockedTill.Equal(until) {
+		t.Fatalf("until = %v, store locked till %v", until, store.lockedTill)
+	}
+	if len(email.sent) != 1 || email.sent[0] != EmailTypeAccountLocked+":alice@example.com" {
+		t.Fatalf("sent = %v, want one account_locked notice", email.sent)
+	}
+
+	data := email.data[0].(*AccountLockedEmailData)
+	prefix := "https://example.com/account/unlock#token="
+	if data.IP != "203.0.113.9" || !strings.HasPrefix(data.UnlockURL, prefix) {
+		t.Fatalf("email data = %+v", data)
+	}
+	token := strings.TrimPrefix(data.UnlockURL, prefix)
+	if store.tokenHash != utils.HashToken(token) {
+		t.Error("only the token hash should be stored")
+	}
+
+	if _, err := s.Unlock(ctx, "wrong"); err != models.ErrUnlockTokenNotFound {
+		t.Errorf("Unlock(wrong) error = %v, want ErrUnlockTokenNotFound", err)
+	}
+	if uid, err := s.Unlock(ctx, token); err != nil || uid != "uid-1" {
+		t.Errorf("Unlock() = (%q, %v), want uid-1", uid, err)
+	}
+	if store.count != 0 {
+		t.Errorf("count after unlock = %d, want 0", store.count)
+	}
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"math"
+	"net/url"
+	"slices"
+	"time"
+
+	"auth-system/internal/models"
+	"auth-system/internal/paths"
+	"auth-system/internal/utils"
+)
+
+const (
+	// loginAlertTTL "不是我本人" 链接有效期
+	loginAlertTTL = 7 * 24 * time.Hour
+	// knownDeviceRetention 超过该时长未再登录的设备被清理，之后再登录视为新设备
+	knownDeviceRetention = 180 * 24 * time.Hour
+
+	// 不可能旅行：与上一次登录地点相距超过 impossibleTravelMinKm，且所需速度超过 impossibleTravelMaxKmh
+	// （约为民航客机巡航速度）。距离下限避免同城 IP 定位误差导致误报
+	impossibleTravelMinKm  = 500.0
+	impossibleTravelMaxKmh = 1000.0
+	earthRadiusKm          = 6371.0
+)
+
+// LoginAlertService 新设备登录检查：维护用户的已知设备表，对新设备评估风险（新国家、新浏览器、不可能旅行），
+// 向用户发送带 "不是我本人" 链接的提醒邮件。检查失败只记录日志，不影响登录
+type LoginAlertService struct {
+	store   models.KnownDeviceStore
+	users   models.UserReader
+	email   EmailSender
+	baseURL string
+}
+
+// NewLoginAlertService 创建新设备登录检查服务；email 为 nil 时仍记录设备但不发送提醒
+func NewLoginAlertService(store models.KnownDeviceStore, users models.UserReader, email EmailSender, baseURL string) *LoginAlertService {
+	return &LoginAlertService{store: store, users: users, email: email, baseURL: baseURL}
+}
+
+// CheckLogin 在会话创建后调用：已知设备只刷新最近登录时间；新设备记录下来，
+// 若用户此前已有登录设备则发送提醒邮件。familyID 为新会话的族 ID，用于 "不是我本人" 时精确撤销
+func (s *LoginAlertService) CheckLogin(ctx context.Context, userUID, familyID string, client utils.ClientInfo) {
+	if userUID == "" || client.IP == "" {
+		return
+	}
+
+	known, err := s.store.ListByUser(ctx, userUID)
+	if err != nil {
+		utils.LogWarnCtx(ctx, "LOGIN-ALERT", "Failed to list known devices", "user_uid", userUID, "error", err)
+		return
+	}
+
+	browser, os := utils.ParseUserAgent(client.UserAgent)
+	device := &models.KnownDevice{
+		UserUID:   userUID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Browser:   browser,
+		OS:        os,
+		Country:   client.Location.Country,
+		City:      client.Location.City,
+		Latitude:  client.Location.Latitude,
+		Longitude: client.Location.Longitude,
+	}
+	reasons := assessLoginRisk(known, device, time.Now())
+
+	if err := s.store.Upsert(ctx, device); err != nil {
+		utils.LogWarnCtx(ctx, "LOGIN-ALERT", "Failed to record known device", "user_uid", userUID, "error", err)
+		return
+	}
+
+	// 首个设备（注册后首次登录、设备记录已全部过期）没有可比较的基线，不提醒
+	if len(reasons) == 0 || len(known) == 0 {
+		return
+	}
+
+	utils.LogInfoCtx(ctx, "LOGIN-ALERT", "Login from new device", "user_uid", userUID, "ip", client.IP, "reasons", reasons)
+	s.sendAlert(ctx, userUID, familyID, device, reasons)
+}
+
+// sendAlert 保存提醒 Token 并发送新设备登录邮件
+func (s *LoginAlertService) sendAlert(ctx context.Context, userUID, familyID string, device *models.KnownDevice, reasons []string) {
+	if s.email == nil || s.users == nil {
+		return
+	}
+	user, err := s.users.FindByUID(ctx, userUID)
+	if err != nil || user == nil || user.Email == "" {
+		utils.LogWarnCtx(ctx, "LOGIN-ALERT", "Failed to look up alert recipient", "user_uid", userUID, "error", err)
+		return
+	}
+
+	token, err := utils.GenerateSecureToken()
+	if err != nil {
+		utils.LogErrorCtx(ctx, "LOGIN-ALERT", "sendAlert", err, "user_uid", user.UID)
+		return
+	}
+
+	alert := &models.LoginAlert{
+		TokenHash: utils.HashToken(token),
+		UserUID:   userUID,
+		FamilyID:  familyID,
+		DeviceID:  device.ID,
+		IP:        device.IP,
+		UserAgent: device.UserAgent,
+		Reasons:   reasons,
+		ExpiresAt: time.Now().Add(loginAlertTTL),
+	}
+	if err := s.store.CreateAlert(ctx, alert); err != nil {
+		utils.LogWarnCtx(ctx, "LOGIN-ALERT", "Failed to save login alert", "user_uid", userUID, "error", err)
+		return
+	}
+
+	s.email.SendNewLoginAlert(user.Email, "", &NewLoginEmailData{
+		Time:      device.LastSeenAt,
+		IP:        device.IP,
+		Location:  formatDeviceLocation(device),
+		Device:    device.Browser + " on " + device.OS,
+		SecureURL: s.baseURL + paths.PathAccountSecure + "#token=" + url.QueryEscape(token),
+	})
+}
+
+// ConsumeLoginAlert 校验并一次性消费 "不是我本人" 链接的 Token，
+// 不存在、已使用或已过期时返回 models.ErrLoginAlertNotFound
+func (s *LoginAlertService) ConsumeLoginAlert(ctx context.Context, token string) (*models.LoginAlert, error) {
+	if token == "" {
+		return nil, models.ErrLoginAlertNotFound
+	}
+	return s.store.ConsumeAlert(ctx, utils.HashToken(token))
+}
+
+// ForgetDevice 删除已知设备，使该设备再次登录时重新触发提醒
+func (s *LoginAlertService) ForgetDevice(ctx context.Context, userUID string, deviceID int64) error {
+	return s.store.Delete(ctx, userUID, deviceID)
+}
+
+// CleanupExpired 清理过期提醒与长期未使用的设备
+func (s *LoginAlertService) CleanupExpired(ctx context.Context) (int64, error) {
+	return s.store.DeleteExpired(ctx, time.Now().Add(-knownDeviceRetention))
+}
+
+// assessLoginRisk 评估本次登录相对已知设备的风险原因；(IP, User-Agent) 已知时返回 nil。
+// 新设备总是包含 new_device，并视情况追加：
+//   - new_country：国家已知且从未在该国家登录过
+//   - new_browser：浏览器可识别且从未使用过
+//   - impossible_travel：与最近一次登录的位置相距过远，按间隔时间换算的速度不可能达到
+func assessLoginRisk(known []*models.KnownDevice, current *models.KnownDevice, now time.Time) []string {
+	var (
+		countries []string
+		browsers  []string
+		last      *models.KnownDevice
+	)
+	for _, d := range known {
+		if d.IP == current.IP && d.UserAgent == current.UserAgent {
+			return nil
+		}
+		if d.Country != "" {
+			countries = append(countries, d.Country)
+		}
+		browsers = append(browsers, d.Browser)
+		if last == nil || d.LastSeenAt.After(last.LastSeenAt) {
+			last = d
+		}
+	}
+
+	reasons := []string{models.LoginRiskNewDevice}
+	if current.Country != "" && len(countries) > 0 && !slices.Contains(countries, current.Country) {
+		reasons = append(reasons, models.LoginRiskNewCountry)
+	}
+	if current.Browser != "Unknown" && len(browsers) > 0 && !slices.Contains(browsers, current.Browser) {
+		reasons = append(reasons, models.LoginRiskNewBrowser)
+	}
+	if last != nil && isImpossibleTravel(last, current, now) {
+		reasons = append(reasons, models.LoginRiskImpossibleTravel)
+	}
+	return reasons
+}
+
+// isImpossibleTravel 判断从上一次登录地点到当前位置所需速度是否超出合理范围，任一方缺少坐标时返回 false
+func isImpossibleTravel(last, current *models.KnownDevice, now time.Time) bool {
+	if last.Latitude == nil || last.Longitude == nil || current.Latitude == nil || current.Longitude == nil {
+		return false
+	}
+	distance := haversineKm(*last.Latitude, *last.Longitude, *current.Latitude, *current.Longitude)
+	if distance <= impossibleTravelMinKm {
+		return false
+	}
+	hours := now.Sub(last.LastSeenAt).Hours()
+	return hours <= 0 || distance/hours > impossibleTravelMaxKmh
+}
+
+// haversineKm 两点间的大圆距离（公里）
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// formatDeviceLocation 格式化为 "城市, 国家"，均未知时返回空（邮件中不显示该字段）
+func formatDeviceLocation(device *models.KnownDevice) string {
+	switch {
+	case device.City != "" && device.Country != "":
+		return device.City + ", " + device.Country
+	case device.Country != "":
+		return device.Country
+	default:
+		return device.City
+	}
+}
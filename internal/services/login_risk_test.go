@@ -0,0 +1,96 @@
+package services
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"auth-system/internal/models"
+)
+
+func coord(v float64) *float64 { return &v }
+
+func TestAssessLoginRisk(t *testing.T) {
+	now := time.Now()
+	// 上海，1 小时前登录
+	home := &models.KnownDevice{
+		IP: "198.51.100.1", UserAgent: "ua-chrome", Browser: "Chrome", Country: "CN",
+		Latitude: coord(31.23), Longitude: coord(121.47), LastSeenAt: now.Add(-time.Hour),
+	}
+	known := []*models.KnownDevice{home}
+
+	tests := []struct {
+		name    string
+		current *models.KnownDevice
+		want    []string
+	}{
+		{
+			name:    "known device",
+			current: &models.KnownDevice{IP: "198.51.100.1", UserAgent: "ua-chrome", Browser: "Chrome", Country: "CN"},
+			want:    nil,
+		},
+		{
+			name:    "new ip same browser and country",
+			current: &models.KnownDevice{IP: "198.51.100.2", UserAgent: "ua-chrome", Browser: "Chrome", Country: "CN"},
+			want:    []string{models.LoginRiskNewDevice},
+		},
+		{
+			name:    "new country",
+			current: &models.KnownDevice{IP: "203.0.113.5", UserAgent: "ua-chrome", Browser: "Chrome", Country: "JP"},
+			want:    []string{models.LoginRiskNewDevice, models.LoginRiskNewCountry},
+		},
+		{
+			name:    "new browser",
+			current: &models.KnownDevice{IP: "198.51.100.1", UserAgent: "ua-firefox", Browser: "Firefox", Country: "CN"},
+			want:    []string{models.LoginRiskNewDevice, models.LoginRiskNewBrowser},
+		},
+		{
+			name:    "unknown browser is not new",
+			current: &models.KnownDevice{IP: "198.51.100.1", UserAgent: "curl", Browser: "Unknown", Country: "CN"},
+			want:    []string{models.LoginRiskNewDevice},
+		},
+		{
+			// 上海 → 伦敦约 9200 km，1 小时内不可能到达
+			name: "impossible travel",
+			current: &models.KnownDevice{IP: "203.0.113.7", UserAgent: "ua-chrome", Browser: "Chrome", Country: "GB",
+				Latitude: coord(51.51), Longitude: coord(-0.13)},
+			want: []string{models.LoginRiskNewDevice, models.LoginRiskNewCountry, models.LoginRiskImpossibleTravel},
+		},
+		{
+			// 上海 → 杭州约 170 km，低于距离下限
+			name: "nearby city",
+			current: &models.KnownDevice{IP: "203.0.113.8", UserAgent: "ua-chrome", Browser: "Chrome", Country: "CN",
+				Latitude: coord(30.27), Longitude: coord(120.16)},
+			want: []string{models.LoginRiskNewDevice},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assessLoginRisk(known, tt.current, now)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("assessLoginRisk() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsImpossibleTravelPlausibleFlight(t *testing.T) {
+	now := time.Now()
+	// 上海 → 伦敦，间隔 14 小时（约 660 km/h），视为合理
+	last := &models.KnownDevice{Latitude: coord(31.23), Longitude: coord(121.47), LastSeenAt: now.Add(-14 * time.Hour)}
+	current := &models.KnownDevice{Latitude: coord(51.51), Longitude: coord(-0.13)}
+	if isImpossibleTravel(last, current, now) {
+		t.Error("14h Shanghai→London flight should be plausible")
+	}
+}
+
+func TestHaversineKm(t *testing.T) {
+	// 上海 → 伦敦约 9200 km
+	if d := haversineKm(31.23, 121.47, 51.51, -0.13); d < 9000 || d > 9400 {
+		t.Errorf("haversineKm() = %.0f, want ~9200", d)
+	}
+	if d := haversineKm(10, 20, 10, 20); d != 0 {
+		t.Errorf("same point distance = %f, want 0", d)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -18,7 +19,7 @@ var (
 	ErrOAuthInvalidClient   = errors.New("OAUTH_INVALID_CLIENT")
 	ErrOAuthInvalidSecret   = errors.New("OAUTH_INVALID_SECRET")
 	ErrOAuthClientDisabled  = errors.New("OAUTH_CLIENT_DISABLED")
-	ErrOAuthInvalidRedirect = errors.New("OAUTH_INVALID_REDIRECT_URI")
+	ErrOAuthInvalidRedirect = models.ErrOAuthInvalidRedirectURI
 
 	ErrOAuthCodeNotFound     = errors.New("OAUTH_CODE_NOT_FOUND")
 	ErrOAuthCodeExpired      = errors.New("OAUTH_CODE_EXPIRED")
@@ -27,6 +28,17 @@ var (
 	ErrOAuthTokenExpired     = errors.New("OAUTH_TOKEN_EXPIRED")
 	ErrOAuthInvalidGrant     = errors.New("OAUTH_INVALID_GRANT")
 	ErrOAuthRedirectMismatch = errors.New("OAUTH_REDIRECT_MISMATCH")
+
+	ErrOAuthInvalidClientScope  = models.ErrOAuthInvalidClientScope
+	ErrOAuthInvalidClaimMapping = models.ErrOAuthInvalidClaimMapping
+	ErrOAuthUnauthorizedClient  = errors.New("OAUTH_UNAUTHORIZED_CLIENT")
+	ErrOAuthInvalidScope        = errors.New("OAUTH_INVALID_SCOPE")
+	ErrOAuthUnknownScope        = errors.New("OAUTH_UNKNOWN_SCOPE")
+
+	ErrOAuthScopeNotFound    = models.ErrOAuthScopeNotFound
+	ErrOAuthScopeExists      = models.ErrOAuthScopeExists
+	ErrOAuthScopeInvalidName = models.ErrOAuthScopeInvalidName
+	ErrOAuthScopeInvalidDesc = models.ErrOAuthScopeInvalidDesc
 )
 
 const (
@@ -48,6 +60,8 @@ type OAuthService struct {
 	accessTokenRepo  *models.OAuthAccessTokenRepository
 	refreshTokenRepo *models.OAuthRefreshTokenRepository
 	grantRepo        *models.OAuthGrantRepository
+	scopeRepo        *models.OAuthScopeRepository
+	deviceCodeRepo   *models.OAuthDeviceCodeRepository
 }
 
 // OAuthTokenResponse Token 响应
@@ -57,6 +71,27 @@ type OAuthTokenResponse struct {
 	ExpiresIn    int    `json:"expires_in"`
 	RefreshToken string `json:"refresh_token,omitempty"`
 	Scope        string `json:"scope"`
+	IDToken      string `json:"id_token,omitempty"`
+
+	// 以下字段来自授权码，仅供 Handler 构造 ID Token，不序列化
+	Nonce    string    `json:"-"`
+	AuthTime time.Time `json:"-"`
+}
+
+// OAuth Token 类型（用于内省的 token_type_hint 与 OAuthTokenInfo.TokenType）
+const (
+	OAuthTokenTypeAccess  = "access_token"
+	OAuthTokenTypeRefresh = "refresh_token"
+)
+
+// OAuthTokenInfo Token 内省结果（RFC 7662）
+type OAuthTokenInfo struct {
+	TokenType string
+	ClientID  string
+	UserUID   string
+	Scope     string
+	ExpiresAt time.Time
+	IssuedAt  time.Time
 }
 
 // NewOAuthService 创建 OAuth 服务
@@ -67,13 +102,42 @@ func NewOAuthService(pool *pgxpool.Pool) *OAuthService {
 		accessTokenRepo:  models.NewOAuthAccessTokenRepository(pool),
 		refreshTokenRepo: models.NewOAuthRefreshTokenRepository(pool),
 		grantRepo:        models.NewOAuthGrantRepository(pool),
+		scopeRepo:        models.NewOAuthScopeRepository(pool),
+		deviceCodeRepo:   models.NewOAuthDeviceCodeRepository(pool),
 	}
 }
 
 // CreateClient 创建客户端
 // 返回：客户端对象、明文 client_secret（仅此次返回）、错误
-func (s *OAuthService) CreateClient(ctx context.Context, name, description, redirectURI string) (*models.OAuthClient, string, error) {
-	if err := validateRedirectURIScheme(redirectURI); err != nil {
+// redirectURIs 至少包含一个回调地址；postLogoutRedirectURIs、clientScopes 可为空；
+// allowedScopes 为 nil 时使用默认白名单（openid/profile/email）；claimMapping 为 roles / groups claim 映射，可为空
+func (s *OAuthService) CreateClient(ctx context.Context, name, description string, redirectURIs, postLogoutRedirectURIs, clientScopes, allowedScopes []string, claimMapping models.OAuthClaimMapping) (*models.OAuthClient, string, error) {
+	redirectURIs = normalizeRedirectURIs(redirectURIs)
+	if len(redirectURIs) == 0 {
+		return nil, "", ErrOAuthInvalidRedirect
+	}
+	postLogoutRedirectURIs = normalizeRedirectURIs(postLogoutRedirectURIs)
+	if err := models.ValidateOAuthRedirectURIList("redirect_uris", redirectURIs); err != nil {
+		return nil, "", err
+	}
+	if err := models.ValidateOAuthRedirectURIList("post_logout_redirect_uris", postLogoutRedirectURIs); err != nil {
+		return nil, "", err
+	}
+	clientScopes = normalizeClientScopes(clientScopes)
+	if err := models.ValidateOAuthClientScopes(clientScopes); err != nil {
+		return nil, "", err
+	}
+	if allowedScopes == nil {
+		allowedScopes = models.DefaultOAuthAllowedScopes
+	}
+	allowedScopes = normalizeClientScopes(allowedScopes)
+	if err := models.ValidateOAuthAllowedScopes(allowedScopes); err != nil {
+		return nil, "", err
+	}
+	if err := s.checkScopesDefined(ctx, clientScopes, allowedScopes); err != nil {
+		return nil, "", err
+	}
+	if err := claimMapping.Validate(); err != nil {
 		return nil, "", err
 	}
 
@@ -96,12 +160,16 @@ func (s *OAuthService) CreateClient(ctx context.Context, name, description, redi
 	}
 
 	client := &models.OAuthClient{
-		ClientID:         clientID,
-		ClientSecretHash: string(secretHash),
-		Name:             name,
-		Description:      description,
-		RedirectURI:      redirectURI,
-		IsEnabled:        true,
+		ClientID:               clientID,
+		ClientSecretHash:       string(secretHash),
+		Name:                   name,
+		Description:            description,
+		RedirectURIs:           redirectURIs,
+		PostLogoutRedirectURIs: postLogoutRedirectURIs,
+		ClientScopes:           clientScopes,
+		AllowedScopes:          allowedScopes,
+		ClaimMapping:           claimMapping,
+		IsEnabled:              true,
 	}
 
 	if err := s.clientRepo.Create(ctx, client); err != nil {
@@ -150,39 +218,60 @@ func (s *OAuthService) ValidateClientID(ctx context.Context, clientID string) (*
 	return client, nil
 }
 
-// ValidateRedirectURI 验证回调地址（精确匹配）
+// ValidateRedirectURI 验证回调地址是否为客户端登记的地址之一
+// 非回环地址精确匹配；登记的 http 回环地址允许任意端口（RFC 8252 7.3，原生应用临时监听端口）
 func (s *OAuthService) ValidateRedirectURI(client *models.OAuthClient, redirectURI string) bool {
-	return client != nil && redirectURI != "" && client.RedirectURI == redirectURI
+	if client == nil || redirectURI == "" {
+		return false
+	}
+	for _, registered := range client.RedirectURIs {
+		if registered == redirectURI || loopbackRedirectMatches(registered, redirectURI) {
+			return true
+		}
+	}
+	return false
 }
 
-// validateRedirectURIScheme 校验 redirect_uri 的 scheme 安全性
-// 仅允许 http/https，http 仅允许 localhost/127.0.0.1/::1（开发环境）
-// 防止 javascript:/data: 等危险 scheme 被注册为回调地址
-func validateRedirectURIScheme(redirectURI string) error {
-	u, err := url.Parse(redirectURI)
-	if err != nil {
-		return ErrOAuthInvalidRedirect
+// loopbackRedirectMatches 判断请求地址与登记的回环地址是否仅端口不同
+// 要求 scheme、主机、路径、查询串完全一致，且不含用户信息与锚点
+func loopbackRedirectMatches(registered, requested string) bool {
+	r, err := url.Parse(registered)
+	if err != nil || r.Scheme != "http" || !models.IsLoopbackHost(r.Hostname()) {
+		return false
+	}
+	q, err := url.Parse(requested)
+	if err != nil || q.User != nil || q.Fragment != "" {
+		return false
 	}
 
-	scheme := strings.ToLower(u.Scheme)
-	host := strings.ToLower(u.Hostname())
+	return q.Scheme == r.Scheme &&
+		strings.EqualFold(q.Hostname(), r.Hostname()) &&
+		q.EscapedPath() == r.EscapedPath() &&
+		q.RawQuery == r.RawQuery
+}
 
-	switch scheme {
-	case "https":
-		if host == "" {
-			return ErrOAuthInvalidRedirect
-		}
-	case "http":
-		// 开发环境仅允许本地回环地址
-		if host != "localhost" && host != "127.0.0.1" && host != "::1" {
-			return ErrOAuthInvalidRedirect
+// normalizeClientScopes 去除首尾空白、丢弃空项并去重
+func normalizeClientScopes(scopes []string) []string {
+	out := make([]string, 0, len(scopes))
+	seen := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		if scope = strings.TrimSpace(scope); scope != "" && !seen[scope] {
+			seen[scope] = true
+			out = append(out, scope)
 		}
-	default:
-		// 拒绝 javascript:/data:/file:/blob: 等所有非 http(s) scheme
-		return ErrOAuthInvalidRedirect
 	}
+	return out
+}
 
-	return nil
+// normalizeRedirectURIs 去除首尾空白并丢弃空项
+func normalizeRedirectURIs(uris []string) []string {
+	out := make([]string, 0, len(uris))
+	for _, uri := range uris {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			out = append(out, uri)
+		}
+	}
+	return out
 }
 
 // RegenerateSecret 重新生成客户端密钥
@@ -223,13 +312,9 @@ func (s *OAuthService) GetClients(ctx context.Context, page, pageSize int, searc
 }
 
 // UpdateClient 更新客户端
-func (s *OAuthService) UpdateClient(ctx context.Context, id int64, name string, description *string, redirectURI string) error {
-	if redirectURI != "" {
-		if err := validateRedirectURIScheme(redirectURI); err != nil {
-			return err
-		}
-	}
-
+// redirectURIs 为空表示不修改；postLogoutRedirectURIs、clientScopes、allowedScopes、claimMapping 为 nil 表示不修改，
+// 切片指向空切片表示清空
+func (s *OAuthService) UpdateClient(ctx context.Context, id int64, name string, description *string, redirectURIs []string, postLogoutRedirectURIs, clientScopes, allowedScopes *[]string, claimMapping *models.OAuthClaimMapping) error {
 	updates := map[string]any{}
 	if name != "" {
 		updates["name"] = name
@@ -237,8 +322,44 @@ func (s *OAuthService) UpdateClient(ctx context.Context, id int64, name string,
 	if description != nil {
 		updates["description"] = *description
 	}
-	if redirectURI != "" {
-		updates["redirect_uri"] = redirectURI
+	if redirectURIs = normalizeRedirectURIs(redirectURIs); len(redirectURIs) > 0 {
+		if err := models.ValidateOAuthRedirectURIList("redirect_uris", redirectURIs); err != nil {
+			return err
+		}
+		updates["redirect_uris"] = redirectURIs
+	}
+	if postLogoutRedirectURIs != nil {
+		uris := normalizeRedirectURIs(*postLogoutRedirectURIs)
+		if err := models.ValidateOAuthRedirectURIList("post_logout_redirect_uris", uris); err != nil {
+			return err
+		}
+		updates["post_logout_redirect_uris"] = uris
+	}
+	var scopesToCheck []string
+	if clientScopes != nil {
+		scopes := normalizeClientScopes(*clientScopes)
+		if err := models.ValidateOAuthClientScopes(scopes); err != nil {
+			return err
+		}
+		updates["client_scopes"] = scopes
+		scopesToCheck = append(scopesToCheck, scopes...)
+	}
+	if allowedScopes != nil {
+		scopes := normalizeClientScopes(*allowedScopes)
+		if err := models.ValidateOAuthAllowedScopes(scopes); err != nil {
+			return err
+		}
+		updates["allowed_scopes"] = scopes
+		scopesToCheck = append(scopesToCheck, scopes...)
+	}
+	if err := s.checkScopesDefined(ctx, scopesToCheck); err != nil {
+		return err
+	}
+	if claimMapping != nil {
+		if err := claimMapping.Validate(); err != nil {
+			return err
+		}
+		updates["claim_mapping"] = *claimMapping
 	}
 	if len(updates) == 0 {
 		return nil
@@ -246,6 +367,65 @@ func (s *OAuthService) UpdateClient(ctx context.Context, id int64, name string,
 	return s.clientRepo.Update(ctx, id, updates)
 }
 
+// checkScopesDefined 校验 scope 均为内置 scope、scim/introspect 或已定义的自定义 scope，否则返回 ErrOAuthUnknownScope
+func (s *OAuthService) checkScopesDefined(ctx context.Context, lists ...[]string) error {
+	custom := make([]string, 0)
+	for _, list := range lists {
+		for _, scope := range list {
+			if !models.IsBuiltinOAuthScope(scope) && !models.IsClientOnlyOAuthScope(scope) && !slices.Contains(custom, scope) {
+				custom = append(custom, scope)
+			}
+		}
+	}
+	if len(custom) == 0 {
+		return nil
+	}
+
+	defined, err := s.scopeRepo.FindByNames(ctx, custom)
+	if err != nil {
+		return err
+	}
+	if len(defined) != len(custom) {
+		return ErrOAuthUnknownScope
+	}
+	return nil
+}
+
+// ListScopes 获取全部自定义 scope
+func (s *OAuthService) ListScopes(ctx context.Context) ([]*models.OAuthScope, error) {
+	return s.scopeRepo.FindAll(ctx)
+}
+
+// GetScopes 按名称批量获取自定义 scope（内置 scope 与不存在的名称被忽略）
+func (s *OAuthService) GetScopes(ctx context.Context, names []string) ([]*models.OAuthScope, error) {
+	custom := make([]string, 0, len(names))
+	for _, name := range names {
+		if !models.IsBuiltinOAuthScope(name) {
+			custom = append(custom, name)
+		}
+	}
+	return s.scopeRepo.FindByNames(ctx, custom)
+}
+
+// CreateScope 创建自定义 scope
+func (s *OAuthService) CreateScope(ctx context.Context, name string, descriptions map[string]string) (*models.OAuthScope, error) {
+	scope := &models.OAuthScope{Name: strings.TrimSpace(name), Descriptions: descriptions}
+	if err := s.scopeRepo.Create(ctx, scope); err != nil {
+		return nil, err
+	}
+	return scope, nil
+}
+
+// UpdateScope 更新自定义 scope 的多语言描述
+func (s *OAuthService) UpdateScope(ctx context.Context, id int64, descriptions map[string]string) (*models.OAuthScope, error) {
+	return s.scopeRepo.UpdateDescriptions(ctx, id, descriptions)
+}
+
+// DeleteScope 删除自定义 scope，同时从所有客户端的 scope 白名单中移除
+func (s *OAuthService) DeleteScope(ctx context.Context, id int64) (*models.OAuthScope, error) {
+	return s.scopeRepo.Delete(ctx, id)
+}
+
 // ToggleClient 启用/禁用客户端
 func (s *OAuthService) ToggleClient(ctx context.Context, id int64, enabled bool) error {
 	// 如果是禁用操作，先获取 client_id 用于撤销 Token
@@ -285,7 +465,9 @@ func (s *OAuthService) DeleteClient(ctx context.Context, id int64) error {
 }
 
 // CreateAuthorizationCode 创建授权码
-func (s *OAuthService) CreateAuthorizationCode(ctx context.Context, clientID string, userUID string, redirectURI, scope, codeChallenge, codeChallengeMethod string) (string, error) {
+// nonce 为 OIDC 请求参数，可为空，换取 Token 时原样写入 ID Token；
+// authTime 为用户会话的登录时间，作为 ID Token 的 auth_time
+func (s *OAuthService) CreateAuthorizationCode(ctx context.Context, clientID string, userUID string, redirectURI, scope, codeChallenge, codeChallengeMethod, nonce string, authTime time.Time) (string, error) {
 	if codeChallenge == "" {
 		return "", ErrOAuthInvalidGrant
 	}
@@ -308,6 +490,8 @@ func (s *OAuthService) CreateAuthorizationCode(ctx context.Context, clientID str
 		Scope:               scope,
 		CodeChallenge:       codeChallenge,
 		CodeChallengeMethod: codeChallengeMethod,
+		Nonce:               nonce,
+		AuthTime:            authTime,
 		ExpiresAt:           time.Now().Add(oauthAuthCodeExpiry),
 		Used:                false,
 	}
@@ -370,6 +554,11 @@ func (s *OAuthService) ExchangeAuthorizationCode(ctx context.Context, code, clie
 	if err != nil {
 		return nil, "", err
 	}
+	tokenResp.Nonce = authCode.Nonce
+	tokenResp.AuthTime = authCode.AuthTime
+	if tokenResp.AuthTime.IsZero() {
+		tokenResp.AuthTime = authCode.CreatedAt
+	}
 
 	utils.LogInfo("OAUTH", "Auth code exchanged", "client_id", clientID, "user_uid", authCode.UserUID)
 	return tokenResp, authCode.UserUID, nil
@@ -419,6 +608,57 @@ func (s *OAuthService) RefreshAccessToken(ctx context.Context, refreshToken, cli
 	return tokenResp, token.UserUID, nil
 }
 
+// IssueClientCredentialsToken 为客户端自身签发 Access Token（client_credentials，RFC 6749 4.4）
+// scope 为空时授予客户端配置的全部 scope；请求了未配置的 scope 返回 ErrOAuthInvalidScope。
+// Token 不关联用户，且不签发 Refresh Token（客户端可随时用凭据重新申请）
+func (s *OAuthService) IssueClientCredentialsToken(ctx context.Context, client *models.OAuthClient, scope string) (*OAuthTokenResponse, error) {
+	if client == nil || len(client.ClientScopes) == 0 {
+		return nil, ErrOAuthUnauthorizedClient
+	}
+
+	granted := client.ClientScopes
+	if requested := strings.Fields(scope); len(requested) > 0 {
+		allowed := make(map[string]bool, len(client.ClientScopes))
+		for _, sc := range client.ClientScopes {
+			allowed[sc] = true
+		}
+		granted = make([]string, 0, len(requested))
+		seen := make(map[string]bool, len(requested))
+		for _, sc := range requested {
+			if !allowed[sc] {
+				return nil, ErrOAuthInvalidScope
+			}
+			if !seen[sc] {
+				seen[sc] = true
+				granted = append(granted, sc)
+			}
+		}
+	}
+
+	accessToken, err := s.generateRandomHex(oauthAccessTokenLength)
+	if err != nil {
+		return nil, err
+	}
+
+	grantedScope := strings.Join(granted, " ")
+	if err := s.accessTokenRepo.Create(ctx, &models.OAuthAccessToken{
+		TokenHash: utils.HashToken(accessToken),
+		ClientID:  client.ClientID,
+		Scope:     grantedScope,
+		ExpiresAt: time.Now().Add(oauthAccessTokenExpiry),
+	}); err != nil {
+		return nil, err
+	}
+
+	utils.LogInfo("OAUTH", "Client credentials token issued", "client_id", client.ClientID, "scope", grantedScope)
+	return &OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(oauthAccessTokenExpiry.Seconds()),
+		Scope:       grantedScope,
+	}, nil
+}
+
 // ValidateAccessToken 验证 Access Token
 func (s *OAuthService) ValidateAccessToken(ctx context.Context, accessToken string) (*models.OAuthAccessToken, error) {
 	tokenHash := utils.HashToken(accessToken)
@@ -438,6 +678,67 @@ func (s *OAuthService) ValidateAccessToken(ctx context.Context, accessToken stri
 	return token, nil
 }
 
+// IntrospectToken 查询 Access Token 或 Refresh Token 的元数据（RFC 7662）
+// tokenTypeHint 仅决定查询顺序，未命中时继续查询另一类 Token；
+// 不存在返回 ErrOAuthTokenNotFound，已过期返回 ErrOAuthTokenExpired
+func (s *OAuthService) IntrospectToken(ctx context.Context, token, tokenTypeHint string) (*OAuthTokenInfo, error) {
+	tokenHash := utils.HashToken(token)
+
+	lookups := []func() (*OAuthTokenInfo, error){
+		func() (*OAuthTokenInfo, error) { return s.introspectAccessToken(ctx, tokenHash) },
+		func() (*OAuthTokenInfo, error) { return s.introspectRefreshToken(ctx, tokenHash) },
+	}
+	if tokenTypeHint == OAuthTokenTypeRefresh {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+
+	for _, lookup := range lookups {
+		info, err := lookup()
+		if errors.Is(err, models.ErrOAuthTokenNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if time.Now().After(info.ExpiresAt) {
+			return nil, ErrOAuthTokenExpired
+		}
+		return info, nil
+	}
+
+	return nil, ErrOAuthTokenNotFound
+}
+
+func (s *OAuthService) introspectAccessToken(ctx context.Context, tokenHash string) (*OAuthTokenInfo, error) {
+	token, err := s.accessTokenRepo.FindByTokenHash(ctx, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	return &OAuthTokenInfo{
+		TokenType: OAuthTokenTypeAccess,
+		ClientID:  token.ClientID,
+		UserUID:   token.UserUID,
+		Scope:     token.Scope,
+		ExpiresAt: token.ExpiresAt,
+		IssuedAt:  token.CreatedAt,
+	}, nil
+}
+
+func (s *OAuthService) introspectRefreshToken(ctx context.Context, tokenHash string) (*OAuthTokenInfo, error) {
+	token, err := s.refreshTokenRepo.FindByTokenHash(ctx, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	return &OAuthTokenInfo{
+		TokenType: OAuthTokenTypeRefresh,
+		ClientID:  token.ClientID,
+		UserUID:   token.UserUID,
+		Scope:     token.Scope,
+		ExpiresAt: token.ExpiresAt,
+		IssuedAt:  token.CreatedAt,
+	}, nil
+}
+
 // RevokeToken 撤销 Token（始终返回成功，防止探测）
 func (s *OAuthService) RevokeToken(ctx context.Context, token string) error {
 	tokenHash := utils.HashToken(token)
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"auth-system/internal/models"
+	"auth-system/internal/utils"
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	ErrOAuthAuthorizationPending = errors.New("OAUTH_AUTHORIZATION_PENDING")
+	ErrOAuthSlowDown             = errors.New("OAUTH_SLOW_DOWN")
+	ErrOAuthAccessDenied         = errors.New("OAUTH_ACCESS_DENIED")
+	ErrOAuthExpiredToken         = errors.New("OAUTH_EXPIRED_TOKEN")
+	ErrOAuthUserCodeInvalid      = errors.New("OAUTH_USER_CODE_INVALID")
+)
+
+const (
+	oauthDeviceCodeLength = 32
+	oauthDeviceCodeExpiry = 10 * time.Minute
+
+	// oauthDevicePollInterval 默认最小轮询间隔（秒），oauthDeviceSlowDownStep 为每次 slow_down 的增量
+	oauthDevicePollInterval = 5
+	oauthDeviceSlowDownStep = 5
+
+	// user_code 仅用辅音大写字母（避免拼出单词、避免 0/O、1/I 混淆），展示为 XXXX-XXXX
+	oauthUserCodeChars      = "BCDFGHJKLMNPQRSTVWXZ"
+	oauthUserCodeLength     = 8
+	oauthUserCodeMaxRetries = 3
+)
+
+// OAuthDeviceAuthorization 设备授权响应（RFC 8628 3.2）
+type OAuthDeviceAuthorization struct {
+	DeviceCode string
+	UserCode   string
+	ExpiresIn  int
+	Interval   int
+}
+
+// CreateDeviceAuthorization 为客户端创建设备授权请求，scope 须已按客户端白名单规范化
+func (s *OAuthService) CreateDeviceAuthorization(ctx context.Context, clientID, scope string) (*OAuthDeviceAuthorization, error) {
+	deviceCode, err := s.generateRandomHex(oauthDeviceCodeLength)
+	if err != nil {
+		utils.LogError("OAUTH", "CreateDeviceAuthorization", err, "Failed to generate device_code")
+		return nil, err
+	}
+
+	for range oauthUserCodeMaxRetries {
+		userCode, err := generateUserCode()
+		if err != nil {
+			return nil, utils.LogError("OAUTH", "CreateDeviceAuthorization", err)
+		}
+
+		err = s.deviceCodeRepo.Create(ctx, &models.OAuthDeviceCode{
+			DeviceCodeHash: utils.HashToken(deviceCode),
+			UserCodeHash:   utils.HashToken(normalizeUserCode(userCode)),
+			ClientID:       clientID,
+			Scope:          scope,
+			Status:         models.QRStatusPending,
+			PollInterval:   oauthDevicePollInterval,
+			ExpiresAt:      time.Now().Add(oauthDeviceCodeExpiry),
+		})
+		if errors.Is(err, models.ErrOAuthUserCodeConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		utils.LogInfo("OAUTH", "Device authorization created", "client_id", clientID)
+		return &OAuthDeviceAuthorization{
+			DeviceCode: deviceCode,
+			UserCode:   userCode,
+			ExpiresIn:  int(oauthDeviceCodeExpiry.Seconds()),
+			Interval:   oauthDevicePollInterval,
+		}, nil
+	}
+
+	return nil, utils.LogError("OAUTH", "CreateDeviceAuthorization", models.ErrOAuthUserCodeConflict, "client_id", clientID)
+}
+
+// GetDeviceAuthorization 按 user_code 查询待确认的设备授权请求
+// 格式错误、不存在、已过期或已处理均返回 ErrOAuthUserCodeInvalid
+func (s *OAuthService) GetDeviceAuthorization(ctx context.Context, userCode string) (*models.OAuthDeviceCode, error) {
+	normalized := normalizeUserCode(userCode)
+	if !isValidUserCode(normalized) {
+		return nil, ErrOAuthUserCodeInvalid
+	}
+
+	dc, err := s.deviceCodeRepo.FindByUserCode(ctx, utils.HashToken(normalized))
+	if err != nil {
+		if errors.Is(err, models.ErrOAuthDeviceCodeNotFound) {
+			return nil, ErrOAuthUserCodeInvalid
+		}
+		return nil, err
+	}
+	if dc.Status != models.QRStatusPending || dc.IsExpired() {
+		return nil, ErrOAuthUserCodeInvalid
+	}
+	return dc, nil
+}
+
+// DecideDeviceAuthorization 用户确认或拒绝设备授权请求；确认时同时记录用户对客户端的授权
+func (s *OAuthService) DecideDeviceAuthorization(ctx context.Context, userCode, userUID string, approve bool) (*models.OAuthDeviceCode, error) {
+	dc, err := s.GetDeviceAuthorization(ctx, userCode)
+	if err != nil {
+		return nil, err
+	}
+
+	var ok bool
+	if approve {
+		ok, err = s.deviceCodeRepo.ConfirmWithCondition(ctx, dc.ID, userUID)
+	} else {
+		ok, err = s.deviceCodeRepo.CancelWithCondition(ctx, dc.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrOAuthUserCodeInvalid
+	}
+
+	if approve {
+		dc.Status = models.QRStatusConfirmed
+		dc.UserUID = userUID
+		grant := &models.OAuthGrant{UserUID: userUID, ClientID: dc.ClientID, Scope: dc.Scope}
+		_ = s.grantRepo.CreateOrUpdate(ctx, grant)
+	} else {
+		dc.Status = models.QRStatusCancelled
+	}
+
+	utils.LogInfo("OAUTH", "Device authorization decided", "client_id", dc.ClientID, "user_uid", userUID, "approved", approve)
+	return dc, nil
+}
+
+// ExchangeDeviceCode 设备端轮询换取 Token（RFC 8628 3.4/3.5）
+// 用户未处理时返回 ErrOAuthAuthorizationPending，轮询过快返回 ErrOAuthSlowDown（间隔随之增加），
+// 用户拒绝返回 ErrOAuthAccessDenied，请求过期返回 ErrOAuthExpiredToken
+func (s *OAuthService) ExchangeDeviceCode(ctx context.Context, deviceCode, clientID string) (*OAuthTokenResponse, string, error) {
+	dc, err := s.deviceCodeRepo.FindByDeviceCode(ctx, utils.HashToken(deviceCode))
+	if err != nil {
+		if errors.Is(err, models.ErrOAuthDeviceCodeNotFound) {
+			return nil, "", ErrOAuthInvalidGrant
+		}
+		return nil, "", err
+	}
+
+	if dc.ClientID != clientID {
+		return nil, "", ErrOAuthInvalidGrant
+	}
+
+	switch {
+	case dc.Status == models.QRStatusCancelled:
+		return nil, "", ErrOAuthAccessDenied
+	case dc.IsExpired():
+		return nil, "", ErrOAuthExpiredToken
+	case dc.Status == models.QRStatusPending:
+		interval, err := s.deviceCodeRepo.RecordPoll(ctx, dc.ID, oauthDeviceSlowDownStep)
+		if err != nil {
+			if errors.Is(err, models.ErrOAuthDeviceCodeNotFound) {
+				return nil, "", ErrOAuthInvalidGrant
+			}
+			return nil, "", err
+		}
+		if interval > dc.PollInterval {
+			return nil, "", ErrOAuthSlowDown
+		}
+		return nil, "", ErrOAuthAuthorizationPending
+	case dc.Status != models.QRStatusConfirmed:
+		return nil, "", ErrOAuthInvalidGrant
+	}
+
+	// 原子消费：并发轮询中只有一个请求能换取 Token
+	if err := s.deviceCodeRepo.Consume(ctx, dc.ID); err != nil {
+		if errors.Is(err, models.ErrOAuthDeviceCodeNotFound) {
+			return nil, "", ErrOAuthInvalidGrant
+		}
+		return nil, "", err
+	}
+
+	tokenResp, err := s.createTokenPair(ctx, dc.ClientID, dc.UserUID, dc.Scope)
+	if err != nil {
+		return nil, "", err
+	}
+	if dc.ConfirmedAt != nil {
+		tokenResp.AuthTime = *dc.ConfirmedAt
+	}
+
+	utils.LogInfo("OAUTH", "Device code exchanged", "client_id", clientID, "user_uid", dc.UserUID)
+	return tokenResp, dc.UserUID, nil
+}
+
+// generateUserCode 生成 XXXX-XXXX 格式的 user_code
+func generateUserCode() (string, error) {
+	raw := make([]byte, oauthUserCodeLength)
+	charLen := big.NewInt(int64(len(oauthUserCodeChars)))
+	for i := range raw {
+		n, err := rand.Int(rand.Reader, charLen)
+		if err != nil {
+			return "", err
+		}
+		raw[i] = oauthUserCodeChars[n.Int64()]
+	}
+	return string(raw[:oauthUserCodeLength/2]) + "-" + string(raw[oauthUserCodeLength/2:]), nil
+}
+
+// normalizeUserCode 规范化用户输入的 user_code：转大写并去除分隔符与空白
+func normalizeUserCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(code)))
+}
+
+// isValidUserCode 校验规范化后的 user_code 长度与字符集
+func isValidUserCode(code string) bool {
+	if len(code) != oauthUserCodeLength {
+		return false
+	}
+	for _, r := range code {
+		if !strings.ContainsRune(oauthUserCodeChars, r) {
+			return false
+		}
+	}
+	return true
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/hex"
 	"errors"
 	"testing"
+
+	"auth-system/internal/models"
 )
 
 func TestValidateRedirectURIScheme(t *testing.T) {
@@ -27,12 +29,13 @@ func TestValidateRedirectURIScheme(t *testing.T) {
 		{"blob rejected", "blob:https://x/y", true},
 		{"no scheme", "example.com/cb", true},
 		{"garbage", "not a uri:::", true},
+		{"fragment rejected", "https://app.example.com/cb#frag", true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := validateRedirectURIScheme(tt.uri)
+			err := models.ValidateOAuthRedirectURI(tt.uri)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("validateRedirectURIScheme(%q) error = %v, wantErr %v", tt.uri, err, tt.wantErr)
+				t.Errorf("ValidateOAuthRedirectURI(%q) error = %v, wantErr %v", tt.uri, err, tt.wantErr)
 			}
 			if tt.wantErr && !errors.Is(err, ErrOAuthInvalidRedirect) {
 				t.Errorf("expected ErrOAuthInvalidRedirect, got %v", err)
@@ -41,6 +44,54 @@ func TestValidateRedirectURIScheme(t *testing.T) {
 	}
 }
 
+func TestValidateRedirectURIMatching(t *testing.T) {
+	s := &OAuthService{}
+	client := &models.OAuthClient{RedirectURIs: []string{
+		"https://app.example.com/cb",
+		"https://app.example.com/alt",
+		"http://127.0.0.1/native/cb",
+	}}
+	tests := []struct {
+		uri  string
+		want bool
+	}{
+		{"https://app.example.com/cb", true},
+		{"https://app.example.com/alt", true},
+		{"https://app.example.com/cb/", false},
+		{"https://app.example.com:8443/cb", false},
+		{"http://127.0.0.1/native/cb", true},
+		// 回环地址允许任意端口（RFC 8252）
+		{"http://127.0.0.1:51234/native/cb", true},
+		{"http://127.0.0.1:51234/native/other", false},
+		{"http://127.0.0.1:51234/native/cb?x=1", false},
+		{"http://localhost:51234/native/cb", false},
+		{"http://evil@127.0.0.1:51234/native/cb", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := s.ValidateRedirectURI(client, tt.uri); got != tt.want {
+			t.Errorf("ValidateRedirectURI(%q) = %v, want %v", tt.uri, got, tt.want)
+		}
+	}
+	if s.ValidateRedirectURI(nil, "https://app.example.com/cb") {
+		t.Error("nil client should never match")
+	}
+}
+
+func TestIssueClientCredentialsTokenScopeChecks(t *testing.T) {
+	s := &OAuthService{}
+	ctx := t.Context()
+
+	if _, err := s.IssueClientCredentialsToken(ctx, &models.OAuthClient{ClientID: "web"}, ""); !errors.Is(err, ErrOAuthUnauthorizedClient) {
+		t.Errorf("client without scopes error = %v, want ErrOAuthUnauthorizedClient", err)
+	}
+
+	client := &models.OAuthClient{ClientID: "svc", ClientScopes: []string{"billing:read"}}
+	if _, err := s.IssueClientCredentialsToken(ctx, client, "billing:read billing:write"); !errors.Is(err, ErrOAuthInvalidScope) {
+		t.Errorf("unregistered scope error = %v, want ErrOAuthInvalidScope", err)
+	}
+}
+
 func TestGenerateRandomHex(t *testing.T) {
 	s := &OAuthService{}
 	for _, length := range []int{16, 32, 64} {
@@ -63,3 +114,28 @@ func TestGenerateRandomHex(t *testing.T) {
 		t.Error("two random hex values should differ")
 	}
 }
+
+func TestUserCodeGenerationAndNormalization(t *testing.T) {
+	code, err := generateUserCode()
+	if err != nil {
+		t.Fatalf("generateUserCode() error = %v", err)
+	}
+	if len(code) != oauthUserCodeLength+1 || code[oauthUserCodeLength/2] != '-' {
+		t.Errorf("generateUserCode() = %q, want XXXX-XXXX", code)
+	}
+	if !isValidUserCode(normalizeUserCode(code)) {
+		t.Errorf("generated code %q should be valid after normalization", code)
+	}
+
+	// 用户输入容忍小写、空格与分隔符
+	if got := normalizeUserCode(" bcdf - ghjk "); got != "BCDFGHJK" {
+		t.Errorf("normalizeUserCode() = %q, want BCDFGHJK", got)
+	}
+
+	s := &OAuthService{}
+	for _, input := range []string{"", "BCDF-GHJ", "BCDF-GHJA", "BCDF-GHJK-L"} {
+		if _, err := s.GetDeviceAuthorization(t.Context(), input); !errors.Is(err, ErrOAuthUserCodeInvalid) {
+			t.Errorf("GetDeviceAuthorization(%q) error = %v, want ErrOAuthUserCodeInvalid", input, err)
+		}
+	}
+}
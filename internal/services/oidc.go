@@ -0,0 +1,130 @@
+package services
+
+import (
+	"auth-system/internal/utils"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrIDTokenNilClaims = errors.New("id token claims is nil")
+	ErrInvalidIDToken   = errors.New("invalid id token")
+)
+
+// IDTokenClaims OIDC ID Token 声明（OpenID Connect Core 1.0 §2）
+type IDTokenClaims struct {
+	Nonce         string `json:"nonce,omitempty"`
+	AuthTime      int64  `json:"auth_time,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+	// Roles / Groups 仅在授权了 roles / groups scope 时下发，值已按客户端 claim 映射转换
+	Roles  []string `json:"roles,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// JWK 单个 JSON Web Key（RFC 7517），仅包含 EC 公钥字段
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// JWKSet JWKS 端点响应
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// SignIDToken 使用 Session 的 ES256 私钥签发 ID Token，header 携带 kid 供 RP 从 JWKS 选择公钥
+func (s *SessionService) SignIDToken(claims *IDTokenClaims) (string, error) {
+	if claims == nil {
+		return "", ErrIDTokenNilClaims
+	}
+
+	if s == nil || s.privateKey == nil {
+		utils.LogError("SESSION", "SignIDToken", fmt.Errorf("ECDSA private key is nil"))
+		return "", ErrTokenGenerationFailed
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = s.KeyID()
+
+	tokenString, err := token.SignedString(s.privateKey)
+	if err != nil {
+		utils.LogError("SESSION", "SignIDToken", err, "sub", claims.Subject)
+		return "", fmt.Errorf("%w: %v", ErrTokenGenerationFailed, err)
+	}
+
+	return tokenString, nil
+}
+
+// VerifyIDToken 校验本服务签发的 ID Token 签名并返回声明（RP 登出的 id_token_hint）
+// 按 RP-Initiated Logout 规范，已过期的 ID Token 仍可作为提示，因此不校验 exp；iss / aud 由调用方核对
+func (s *SessionService) VerifyIDToken(tokenString string) (*IDTokenClaims, error) {
+	if s == nil || s.privateKey == nil {
+		utils.LogError("SESSION", "VerifyIDToken", fmt.Errorf("ECDSA private key is nil"))
+		return nil, ErrInvalidIDToken
+	}
+
+	claims := &IDTokenClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		return &s.privateKey.PublicKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}), jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	return claims, nil
+}
+
+// JWKS 返回签名公钥集合（当前仅一把 ES256 密钥）
+func (s *SessionService) JWKS() *JWKSet {
+	if s == nil || s.privateKey == nil {
+		return &JWKSet{Keys: []JWK{}}
+	}
+
+	jwk := s.publicJWK()
+	jwk.Use = "sig"
+	jwk.Alg = "ES256"
+	jwk.Kid = s.KeyID()
+
+	return &JWKSet{Keys: []JWK{jwk}}
+}
+
+// KeyID 返回签名密钥的 kid：RFC 7638 JWK Thumbprint（SHA-256，base64url）
+// 由公钥确定性推导，密钥轮换后自动变化，无需额外配置
+func (s *SessionService) KeyID() string {
+	if s == nil || s.privateKey == nil {
+		return ""
+	}
+
+	jwk := s.publicJWK()
+	// 成员按字典序排列且无空白（RFC 7638 §3.2）
+	canonical := fmt.Sprintf(`{"crv":"%s","kty":"%s","x":"%s","y":"%s"}`, jwk.Crv, jwk.Kty, jwk.X, jwk.Y)
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// publicJWK 提取公钥坐标（未填充 use/alg/kid）
+func (s *SessionService) publicJWK() JWK {
+	pub := &s.privateKey.PublicKey
+	jwk := JWK{Kty: "EC", Crv: pub.Curve.Params().Name}
+
+	// 未压缩点编码：0x04 || X || Y，坐标定长
+	point, err := pub.Bytes()
+	if err != nil || len(point) < 3 {
+		utils.LogError("SESSION", "publicJWK", fmt.Errorf("failed to encode public key: %v", err))
+		return jwk
+	}
+	size := (len(point) - 1) / 2
+	jwk.X = base64.RawURLEncoding.EncodeToString(point[1 : 1+size])
+	jwk.Y = base64.RawURLEncoding.EncodeToString(point[1+size:])
+	return jwk
+}
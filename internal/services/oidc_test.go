@@ -0,0 +1,125 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// publicKeyFromJWK 从 JWK 坐标还原 ECDSA 公钥（模拟 RP 侧验签）
+func publicKeyFromJWK(t *testing.T, jwk JWK) *ecdsa.PublicKey {
+	t.Helper()
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		t.Fatalf("decode x: %v", err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		t.Fatalf("decode y: %v", err)
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+}
+
+func TestSignIDTokenVerifiableWithJWKS(t *testing.T) {
+	s := testSessionService(t, time.Hour)
+
+	jwks := s.JWKS()
+	if len(jwks.Keys) != 1 {
+		t.Fatalf("JWKS keys = %d, want 1", len(jwks.Keys))
+	}
+	jwk := jwks.Keys[0]
+	if jwk.Kty != "EC" || jwk.Crv != "P-256" || jwk.Alg != "ES256" || jwk.Use != "sig" {
+		t.Errorf("unexpected JWK = %+v", jwk)
+	}
+	if jwk.Kid == "" || jwk.Kid != s.KeyID() {
+		t.Errorf("kid = %q, KeyID() = %q", jwk.Kid, s.KeyID())
+	}
+
+	verified := true
+	signed, err := s.SignIDToken(&IDTokenClaims{
+		Nonce:         "nonce-1",
+		AuthTime:      1700000000,
+		EmailVerified: &verified,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "https://auth.example.com",
+			Subject:   "uid-1",
+			Audience:  jwt.ClaimStrings{"client-1"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	})
+	if err != nil {
+		t.Fatalf("SignIDToken() error = %v", err)
+	}
+
+	pub := publicKeyFromJWK(t, jwk)
+	parsed := &IDTokenClaims{}
+	token, err := jwt.ParseWithClaims(signed, parsed, func(tok *jwt.Token) (any, error) {
+		if tok.Header["kid"] != jwk.Kid {
+			return nil, errors.New("kid mismatch")
+		}
+		return pub, nil
+	}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithAudience("client-1"))
+	if err != nil || !token.Valid {
+		t.Fatalf("ParseWithClaims() error = %v", err)
+	}
+	if parsed.Subject != "uid-1" || parsed.Nonce != "nonce-1" || parsed.AuthTime != 1700000000 {
+		t.Errorf("claims = %+v", parsed)
+	}
+	if parsed.EmailVerified == nil || !*parsed.EmailVerified {
+		t.Error("email_verified should round-trip as true")
+	}
+}
+
+func TestSignIDTokenNilClaims(t *testing.T) {
+	s := testSessionService(t, time.Hour)
+	if _, err := s.SignIDToken(nil); !errors.Is(err, ErrIDTokenNilClaims) {
+		t.Errorf("SignIDToken(nil) error = %v, want ErrIDTokenNilClaims", err)
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	s := testSessionService(t, time.Hour)
+
+	// 已过期的 ID Token 仍可作为 id_token_hint
+	signed, err := s.SignIDToken(&IDTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "https://auth.example.com",
+			Subject:   "uid-1",
+			Audience:  jwt.ClaimStrings{"client-1"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("SignIDToken() error = %v", err)
+	}
+	claims, err := s.VerifyIDToken(signed)
+	if err != nil {
+		t.Fatalf("VerifyIDToken() error = %v", err)
+	}
+	if claims.Subject != "uid-1" || claims.Audience[0] != "client-1" {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	other := testSessionService(t, time.Hour)
+	if _, err := other.VerifyIDToken(signed); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("token signed by another key: error = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestKeyIDStable(t *testing.T) {
+	s := testSessionService(t, time.Hour)
+	if s.KeyID() != s.KeyID() {
+		t.Error("KeyID should be deterministic for the same key")
+	}
+	other := testSessionService(t, time.Hour)
+	if s.KeyID() == other.KeyID() {
+		t.Error("different keys should yield different kid")
+	}
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"auth-system/internal/utils"
+)
+
+// breachPrefixLen k-匿名查询使用的 SHA-1 十六进制前缀长度（与 Have I Been Pwned range API 一致）
+const breachPrefixLen = 5
+
+// PasswordBreachCorpus 离线泄露密码库：按 SHA-1 存储，查询时只按 5 位十六进制前缀取出同一桶的后缀再比对，
+// 与 HIBP range API 的 k-匿名模型一致，日后换成在线查询无需改动调用方。
+// 文件每行一个 "SHA1[:次数]"（HIBP "ordered by hash" 下载格式），空行与 # 开头的行忽略；
+// 整库常驻内存（每条 20 字节），完整 HIBP 库过大，部署时应先按出现次数裁剪
+type PasswordBreachCorpus struct {
+	hashes [][sha1.Size]byte // 升序
+}
+
+// LoadPasswordBreachCorpus 从本地文件加载泄露密码库
+func LoadPasswordBreachCorpus(path string) (*PasswordBreachCorpus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open breach corpus: %w", err)
+	}
+	defer f.Close()
+
+	corpus := &PasswordBreachCorpus{}
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		hexHash, _, _ := bytes.Cut(line, []byte(":"))
+		var h [sha1.Size]byte
+		if len(hexHash) != 2*sha1.Size {
+			return nil, fmt.Errorf("breach corpus line %d: invalid SHA-1 length", lineNo)
+		}
+		if _, err := hex.Decode(h[:], hexHash); err != nil {
+			return nil, fmt.Errorf("breach corpus line %d: %w", lineNo, err)
+		}
+		corpus.hashes = append(corpus.hashes, h)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read breach corpus: %w", err)
+	}
+
+	slices.SortFunc(corpus.hashes, func(a, b [sha1.Size]byte) int { return bytes.Compare(a[:], b[:]) })
+	corpus.hashes = slices.Compact(corpus.hashes)
+	return corpus, nil
+}
+
+// Len 库中的哈希数量
+func (c *PasswordBreachCorpus) Len() int {
+	return len(c.hashes)
+}
+
+// Range 返回 SHA-1 以 prefix（5 位十六进制，大小写不敏感）开头的全部哈希后缀（35 位大写十六进制）
+func (c *PasswordBreachCorpus) Range(prefix string) []string {
+	key, err := strconv.ParseUint(prefix, 16, 32)
+	if len(prefix) != breachPrefixLen || err != nil {
+		return nil
+	}
+
+	start, _ := slices.BinarySearchFunc(c.hashes, uint32(key), func(h [sha1.Size]byte, k uint32) int {
+		return int(breachHashPrefix(h)) - int(k)
+	})
+	var suffixes []string
+	for _, h := range c.hashes[start:] {
+		if breachHashPrefix(h) != uint32(key) {
+			break
+		}
+		suffixes = append(suffixes, strings.ToUpper(hex.EncodeToString(h[:]))[breachPrefixLen:])
+	}
+	return suffixes
+}
+
+// Contains 判断密码是否出现在泄露库中（只以前缀查询桶，再在桶内比对后缀）
+func (c *PasswordBreachCorpus) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	full := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return slices.Contains(c.Range(full[:breachPrefixLen]), full[breachPrefixLen:])
+}
+
+// breachHashPrefix 哈希的前 20 位（即 5 位十六进制前缀）
+func breachHashPrefix(h [sha1.Size]byte) uint32 {
+	return uint32(h[0])<<12 | uint32(h[1])<<4 | uint32(h[2])>>4
+}
+
+// PasswordScreenResult 新密码筛查结果
+type PasswordScreenResult struct {
+	Breached bool                   `json:"breached"`
+	Strength utils.PasswordStrength `json:"strength"`
+}
+
+// ErrorCode 未通过筛查时的错误码（泄露优先），通过时为空
+func (r *PasswordScreenResult) ErrorCode() string {
+	switch {
+	case r.Breached:
+		return utils.ErrPasswordBreached
+	case r.Strength.Score < utils.PasswordMinScore:
+		return utils.ErrPasswordTooWeak
+	default:
+		return ""
+	}
+}
+
+// PasswordScreenService 新密码筛查：比对离线泄露库并估算强度（用户名、邮箱参与扣分）
+type PasswordScreenService struct {
+	corpus *PasswordBreachCorpus
+}
+
+// NewPasswordScreenService 创建密码筛查服务；corpus 为 nil 时只做强度估算
+func NewPasswordScreenService(corpus *PasswordBreachCorpus) *PasswordScreenService {
+	return &PasswordScreenService{corpus: corpus}
+}
+
+// Screen 筛查新密码，userInputs 通常为用户名与邮箱
+func (s *PasswordScreenService) Screen(password string, userInputs ...string) *PasswordScreenResult {
+	result := &PasswordScreenResult{Strength: utils.EstimatePasswordStrength(password, userInputs...)}
+	if s.corpus != nil {
+		result.Breached = s.corpus.Contains(password)
+	}
+	return result
+}
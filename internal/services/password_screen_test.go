@@ -0,0 +1,103 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"auth-system/internal/utils"
+)
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func writeBreachCorpus(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPasswordBreachCorpus(t *testing.T) {
+	breached := "Tv8#quartz-Lumen-Otter"
+	hash := sha1Hex(breached)
+	path := writeBreachCorpus(t,
+		"# trimmed corpus",
+		hash+":42",
+		strings.ToLower(sha1Hex("another-password")),
+		"",
+		hash, // 重复行去重
+	)
+
+	corpus, err := LoadPasswordBreachCorpus(path)
+	if err != nil {
+		t.Fatalf("LoadPasswordBreachCorpus() error = %v", err)
+	}
+	if corpus.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", corpus.Len())
+	}
+
+	suffixes := corpus.Range(strings.ToLower(hash[:5]))
+	if len(suffixes) != 1 || suffixes[0] != hash[5:] {
+		t.Errorf("Range(%s) = %v, want [%s]", hash[:5], suffixes, hash[5:])
+	}
+	if got := corpus.Range("xyz"); got != nil {
+		t.Errorf("Range(invalid) = %v, want nil", got)
+	}
+
+	if !corpus.Contains(breached) {
+		t.Error("Contains(breached) = false")
+	}
+	if !corpus.Contains("another-password") {
+		t.Error("lowercase hashes should be accepted")
+	}
+	if corpus.Contains("kX9#mQ2$vL7&nR4!") {
+		t.Error("Contains(unlisted) = true")
+	}
+}
+
+func TestLoadPasswordBreachCorpusInvalid(t *testing.T) {
+	if _, err := LoadPasswordBreachCorpus(writeBreachCorpus(t, "not-a-hash:1")); err == nil {
+		t.Error("want error for malformed line")
+	}
+	if _, err := LoadPasswordBreachCorpus(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("want error for missing file")
+	}
+}
+
+func TestPasswordScreenService(t *testing.T) {
+	corpus, err := LoadPasswordBreachCorpus(writeBreachCorpus(t, sha1Hex("Tv8#quartz-Lumen-Otter")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewPasswordScreenService(corpus)
+
+	tests := []struct {
+		name     string
+		password string
+		want     string
+	}{
+		{"strong", "kX9#mQ2$vL7&nR4!", ""},
+		{"breached wins over strength", "Tv8#quartz-Lumen-Otter", utils.ErrPasswordBreached},
+		{"built from username", "Alice2024!Alice2024!", utils.ErrPasswordTooWeak},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Screen(tt.password, "alice", "alice@example.com").ErrorCode(); got != tt.want {
+				t.Errorf("ErrorCode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// 未配置泄露库时只做强度估算
+	if got := NewPasswordScreenService(nil).Screen("Tv8#quartz-Lumen-Otter"); got.Breached || got.ErrorCode() != "" {
+		t.Errorf("without corpus: %+v", got)
+	}
+}
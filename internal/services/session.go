@@ -17,6 +17,7 @@ import (
 	"auth-system/internal/config"
 
 	"github.com/golang-jwt/jwt/v5"
+	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -34,28 +35,76 @@ var (
 	ErrRefreshTokenExpired      = errors.New("REFRESH_TOKEN_EXPIRED")
 	ErrRefreshTokenReused       = errors.New("REFRESH_TOKEN_REUSED")
 	ErrRefreshTokenInvalid      = errors.New("INVALID_REFRESH_TOKEN")
+	ErrSessionNotFound          = errors.New("SESSION_NOT_FOUND")
+	ErrSessionRevoked           = errors.New("SESSION_REVOKED")
 )
 
 const (
 	defaultAccessTokenExpiry  = 1 * time.Hour
 	bannedAccessTokenExpiry   = 15 * time.Minute
+	impersonationTokenExpiry  = 30 * time.Minute
 	minAccessTokenExpiry      = 1 * time.Minute
 	maxAccessTokenExpiry      = 24 * time.Hour
 	defaultRefreshTokenExpiry = 30 * 24 * time.Hour
 	minRefreshTokenExpiry     = 1 * 24 * time.Hour
 	maxRefreshTokenExpiry     = 90 * 24 * time.Hour
 	refreshTokenByteSize      = 32
+	familyIDByteSize          = 16
+
+	// sessionActiveCacheTTL 会话有效的检查结果在本实例缓存的时间：本实例撤销会话时立即清除，
+	// 其他实例撤销的会话最迟在此时间后拒绝对应的 access_token
+	sessionActiveCacheTTL      = 30 * time.Second
+	sessionActiveCacheCapacity = 10000
 )
 
 // Claims JWT 声明
 //
 // 注意：封禁状态不写入 JWT claim。封禁检查由 BanCheckMiddleware 实时查库（含缓存）完成，
 // 写入 claim 只会让已签发 token 携带过期状态，且可能与查库结果不一致。
+//
+// SID 为签发该 token 的会话（refresh_token 家族）ID，用于在会话列表中标识当前设备；
+// 封禁用户的短期 token 不属于任何会话，SID 为空。
+//
+// AuthTime 为用户实际完成认证（登录）的时刻，轮转时沿用会话的登录时间，
+// 供 OIDC id_token 的 auth_time 使用；iat 只反映本次签发时间。
+//
+// Act 仅出现在超级管理员模拟登录签发的 token 中，标识实际操作者（RFC 8693 act claim）。
 type Claims struct {
-	UID string `json:"uid"`
+	UID string      `json:"uid"`
+	SID string      `json:"sid,omitempty"`
+	Act *ActorClaim `json:"act,omitempty"`
+	// AuthTime 旧版本签发的 token 无此声明，读取时用 LoginTime 回退到 iat
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// LoginTime 用户完成认证的时刻；缺少 auth_time 声明时以 iat 近似
+func (c *Claims) LoginTime() time.Time {
+	if c == nil {
+		return time.Time{}
+	}
+	if c.AuthTime != nil {
+		return c.AuthTime.Time
+	}
+	if c.IssuedAt != nil {
+		return c.IssuedAt.Time
+	}
+	return time.Time{}
+}
+
+// ActorClaim 代表 UID 实际执行操作的主体
+type ActorClaim struct {
+	Sub string `json:"sub"`
+}
+
+// Impersonator 返回模拟登录的管理员 UID，非模拟会话返回空字符串
+func (c *Claims) Impersonator() string {
+	if c == nil || c.Act == nil {
+		return ""
+	}
+	return c.Act.Sub
+}
+
 // SessionService Session 服务
 type SessionService struct {
 	privateKey         *ecdsa.PrivateKey
@@ -64,6 +113,8 @@ type SessionService struct {
 	jwtIssuer          string
 	jwtAudience        string
 	sessionTokenRepo   models.SessionTokenStore
+	// activeSessions 已确认有效的会话 ID -> 确认时间
+	activeSessions *lru.Cache[string, time.Time]
 }
 
 // NewSessionService 创建 Session 服务（带配置验证）
@@ -116,6 +167,11 @@ func NewSessionService(cfg *config.Config, pool *pgxpool.Pool) (*SessionService,
 		utils.LogWarn("SESSION", "Refresh token expiry adjusted to maximum", "new_expiry", refreshExpiry)
 	}
 
+	activeSessions, err := lru.New[string, time.Time](sessionActiveCacheCapacity)
+	if err != nil {
+		return nil, fmt.Errorf("create session cache: %w", err)
+	}
+
 	utils.LogInfo("SESSION", "Session service initialized", "access_expiry", accessExpiry, "refresh_expiry", refreshExpiry, "issuer", issuer, "audience", audience, "alg", "ES256")
 
 	return &SessionService{
@@ -125,25 +181,26 @@ func NewSessionService(cfg *config.Config, pool *pgxpool.Pool) (*SessionService,
 		jwtIssuer:          issuer,
 		jwtAudience:        audience,
 		sessionTokenRepo:   models.NewSessionTokenRepository(pool),
+		activeSessions:     activeSessions,
 	}, nil
 }
 
-// GenerateTokens 生成 access_token + refresh_token
-// banned: true = 封禁用户，只签发短期 access_token，不签发 refresh_token
-func (s *SessionService) GenerateTokens(ctx context.Context, uid string, banned bool) (accessToken string, refreshToken string, err error) {
+// GenerateTokens 生成 access_token + refresh_token，并返回新会话（token 家族）的 ID
+// banned: true = 封禁用户，只签发短期 access_token，不签发 refresh_token，也不属于任何会话（familyID 为空）
+func (s *SessionService) GenerateTokens(ctx context.Context, uid string, banned bool) (accessToken string, refreshToken string, familyID string, err error) {
 	if uid == "" {
 		utils.LogWarn("SESSION", "Invalid user UID for token generation", "uid", uid)
-		return "", "", ErrInvalidUser
+		return "", "", "", ErrInvalidUser
 	}
 
 	if s == nil {
 		utils.LogError("SESSION", "GenerateTokens", fmt.Errorf("session service is nil"))
-		return "", "", ErrTokenGenerationFailed
+		return "", "", "", ErrTokenGenerationFailed
 	}
 
 	if s.privateKey == nil {
 		utils.LogError("SESSION", "GenerateTokens", fmt.Errorf("ECDSA private key is nil"))
-		return "", "", ErrTokenGenerationFailed
+		return "", "", "", ErrTokenGenerationFailed
 	}
 
 	var accessExpiry time.Duration
@@ -153,26 +210,70 @@ func (s *SessionService) GenerateTokens(ctx context.Context, uid string, banned
 		accessExpiry = s.accessTokenExpiry
 	}
 
-	accessToken, err = s.generateAccessToken(uid, accessExpiry)
+	if banned {
+		accessToken, err = s.generateAccessToken(uid, "", time.Time{}, accessExpiry)
+		if err != nil {
+			return "", "", "", err
+		}
+		utils.LogInfo("SESSION", "Banned user access token generated", "uid", uid, "expiry", accessExpiry)
+		return accessToken, "", "", nil
+	}
+
+	// 每次登录开启一个新的会话（token 家族），后续轮转沿用同一家族
+	familyID, err = newFamilyID()
 	if err != nil {
-		return "", "", err
+		return "", "", "", err
 	}
 
-	if banned {
-		utils.LogInfo("SESSION", "Banned user access token generated", "uid", uid, "expiry", accessExpiry)
-		return accessToken, "", nil
+	refreshToken, err = s.generateRefreshToken(ctx, uid, false, familyID, time.Time{})
+	if err != nil {
+		return "", "", "", err
 	}
 
-	refreshToken, err = s.generateRefreshToken(ctx, uid, false)
+	accessToken, err = s.generateAccessToken(uid, familyID, time.Time{}, accessExpiry)
 	if err != nil {
-		return "", "", err
+		return "", "", "", err
+	}
+
+	utils.LogInfo("SESSION", "Tokens generated", "uid", uid, "family_id", familyID, "access_expiry", accessExpiry, "refresh_expiry", s.refreshTokenExpiry)
+	return accessToken, refreshToken, familyID, nil
+}
+
+// GenerateImpersonationToken 为超级管理员签发以 uid 身份访问的短期 access_token
+// token 带 act claim，并归属一个与其同时过期的独立会话（家族）：结束模拟、用户登出其他设备或管理员撤销会话时
+// 随之失效。家族内的 refresh_token 不返回给调用方，过期后需重新发起模拟
+func (s *SessionService) GenerateImpersonationToken(ctx context.Context, uid, impersonatorUID string) (string, time.Time, error) {
+	if uid == "" || impersonatorUID == "" {
+		utils.LogWarn("SESSION", "Invalid user UID for impersonation token", "uid", uid, "impersonator", impersonatorUID)
+		return "", time.Time{}, ErrInvalidUser
+	}
+
+	if s == nil || s.privateKey == nil {
+		utils.LogError("SESSION", "GenerateImpersonationToken", fmt.Errorf("session service is not configured"))
+		return "", time.Time{}, ErrTokenGenerationFailed
 	}
 
-	utils.LogInfo("SESSION", "Tokens generated", "uid", uid, "access_expiry", accessExpiry, "refresh_expiry", s.refreshTokenExpiry)
-	return accessToken, refreshToken, nil
+	familyID, err := newFamilyID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(impersonationTokenExpiry)
+	if err := s.createImpersonationSession(ctx, uid, familyID, expiresAt); err != nil {
+		return "", time.Time{}, err
+	}
+
+	accessToken, err := s.signAccessToken(&Claims{UID: uid, SID: familyID, Act: &ActorClaim{Sub: impersonatorUID}}, impersonationTokenExpiry)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	utils.LogInfo("SESSION", "Impersonation token generated", "uid", uid, "impersonator", impersonatorUID, "family_id", familyID, "expiry", impersonationTokenExpiry)
+	return accessToken, expiresAt, nil
 }
 
 // RefreshTokens 使用 refresh_token 轮转获取新的 token 对
+// 新 token 沿用原家族（同一设备会话），并刷新会话的最近使用时间与设备信息；
 // 检测到已使用的 refresh_token 被重放时，撤销整个 token 家族并返回错误
 func (s *SessionService) RefreshTokens(ctx context.Context, refreshTokenStr string) (newAccessToken string, newRefreshToken string, err error) {
 	if refreshTokenStr == "" {
@@ -200,6 +301,7 @@ func (s *SessionService) RefreshTokens(ctx context.Context, refreshTokenStr stri
 
 	if existing.Used {
 		s.sessionTokenRepo.RevokeFamily(ctx, existing.FamilyID)
+		s.activeSessions.Remove(existing.FamilyID)
 		utils.LogWarn("SESSION", "Refresh token reuse detected - family revoked", "user_uid", existing.UserUID, "family_id", existing.FamilyID)
 		return "", "", ErrRefreshTokenReused
 	}
@@ -207,6 +309,7 @@ func (s *SessionService) RefreshTokens(ctx context.Context, refreshTokenStr stri
 	if markErr := s.sessionTokenRepo.MarkUsed(ctx, existing.ID); markErr != nil {
 		if errors.Is(markErr, models.ErrSessionTokenReused) {
 			s.sessionTokenRepo.RevokeFamily(ctx, existing.FamilyID)
+			s.activeSessions.Remove(existing.FamilyID)
 			utils.LogWarn("SESSION", "Refresh token reuse detected - family revoked", "user_uid", existing.UserUID, "family_id", existing.FamilyID)
 			return "", "", ErrRefreshTokenReused
 		}
@@ -216,7 +319,7 @@ func (s *SessionService) RefreshTokens(ctx context.Context, refreshTokenStr stri
 	// 与登录策略一致：被封禁用户只签发短期 access_token，且不再续发 refresh_token，
 	// 会话在短期 token 过期后自然终止（重新登录可查看封禁页面）。
 	if existing.Banned {
-		newAccessToken, err = s.generateAccessToken(existing.UserUID, bannedAccessTokenExpiry)
+		newAccessToken, err = s.generateAccessToken(existing.UserUID, existing.FamilyID, existing.CreatedAt, bannedAccessTokenExpiry)
 		if err != nil {
 			return "", "", err
 		}
@@ -224,12 +327,13 @@ func (s *SessionService) RefreshTokens(ctx context.Context, refreshTokenStr stri
 		return newAccessToken, "", nil
 	}
 
-	newAccessToken, err = s.generateAccessToken(existing.UserUID, s.accessTokenExpiry)
+	newRefreshToken, err = s.generateRefreshToken(ctx, existing.UserUID, false, existing.FamilyID, existing.CreatedAt)
 	if err != nil {
 		return "", "", err
 	}
 
-	newRefreshToken, err = s.generateRefreshToken(ctx, existing.UserUID, false)
+	// 会话的 created_at 即登录时间（轮转时沿用），作为新 access_token 的 auth_time
+	newAccessToken, err = s.generateAccessToken(existing.UserUID, existing.FamilyID, existing.CreatedAt, s.accessTokenExpiry)
 	if err != nil {
 		return "", "", err
 	}
@@ -245,20 +349,80 @@ func (s *SessionService) RevokeUserTokens(ctx context.Context, uid string) error
 	}
 
 	_, err := s.sessionTokenRepo.RevokeUser(ctx, uid)
+	// 缓存按会话 ID 索引，无法按用户定位；用户级撤销不频繁，直接清空
+	s.activeSessions.Purge()
 	return err
 }
 
-// RevokeTokenFamily 撤销指定的 token 家族
+// RevokeTokenFamily 撤销用户指定的 token 家族（登出某一设备）
+// 家族不存在或不属于该用户时返回 ErrSessionNotFound
 func (s *SessionService) RevokeTokenFamily(ctx context.Context, uid string, familyID string) error {
 	if uid == "" {
 		return ErrInvalidUser
 	}
 	if familyID == "" {
-		return fmt.Errorf("family_id is empty")
+		return ErrSessionNotFound
 	}
 
-	_, err := s.sessionTokenRepo.RevokeFamily(ctx, familyID)
-	return err
+	count, err := s.sessionTokenRepo.RevokeUserFamily(ctx, uid, familyID)
+	s.activeSessions.Remove(familyID)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// ListSessions 列出用户当前有效的设备会话（按最近使用时间倒序）
+func (s *SessionService) ListSessions(ctx context.Context, uid string) ([]*models.SessionToken, error) {
+	if uid == "" {
+		return nil, ErrInvalidUser
+	}
+
+	return s.sessionTokenRepo.ListActiveByUser(ctx, uid)
+}
+
+// RevokeOtherSessions 撤销用户除 keepFamilyID 外的所有会话（登出其他设备），返回撤销的 token 数
+func (s *SessionService) RevokeOtherSessions(ctx context.Context, uid string, keepFamilyID string) (int64, error) {
+	if uid == "" {
+		return 0, ErrInvalidUser
+	}
+	if keepFamilyID == "" {
+		return 0, ErrSessionNotFound
+	}
+
+	count, err := s.sessionTokenRepo.RevokeUserExcept(ctx, uid, keepFamilyID)
+	s.activeSessions.Purge()
+	return count, err
+}
+
+// CheckSession 检查 access_token 所属会话（token 家族）是否仍有效：
+// 会话被撤销（登出设备、"不是我本人"、刷新令牌重放）后，未过期的 access_token 随之失效，返回 ErrSessionRevoked。
+// 无 SID 的 token（封禁用户短期 token）不属于任何会话，直接通过；
+// 查询失败时放行并记录日志，避免数据库故障使所有用户掉线
+func (s *SessionService) CheckSession(ctx context.Context, claims *Claims) error {
+	if claims == nil || claims.SID == "" {
+		return nil
+	}
+
+	if checkedAt, ok := s.activeSessions.Get(claims.SID); ok && time.Since(checkedAt) < sessionActiveCacheTTL {
+		return nil
+	}
+
+	active, err := s.sessionTokenRepo.FamilyActive(ctx, claims.SID)
+	if err != nil {
+		utils.LogWarn("SESSION", "Session check failed, allowing request", "uid", claims.UID, "error", err)
+		return nil
+	}
+	if !active {
+		s.activeSessions.Remove(claims.SID)
+		return ErrSessionRevoked
+	}
+
+	s.activeSessions.Add(claims.SID, time.Now())
+	return nil
 }
 
 // VerifyToken 验证 JWT Token（ES256 公钥验证）
@@ -326,24 +490,34 @@ func (s *SessionService) IsConfigured() bool {
 }
 
 // generateAccessToken 生成 access_token（JWT ES256）
-func (s *SessionService) generateAccessToken(uid string, expiry time.Duration) (string, error) {
+// authTime 为会话登录时间，零值表示本次签发即为登录
+func (s *SessionService) generateAccessToken(uid, familyID string, authTime time.Time, expiry time.Duration) (string, error) {
+	claims := &Claims{UID: uid, SID: familyID}
+	if !authTime.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(authTime)
+	}
+	return s.signAccessToken(claims, expiry)
+}
+
+// signAccessToken 补全标准声明并以 ES256 签名
+func (s *SessionService) signAccessToken(claims *Claims, expiry time.Duration) (string, error) {
 	now := time.Now()
-	claims := &Claims{
-		UID: uid,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
-			IssuedAt:  jwt.NewNumericDate(now),
-			NotBefore: jwt.NewNumericDate(now),
-			Issuer:    s.jwtIssuer,
-			Audience:  jwt.ClaimStrings{s.jwtAudience},
-		},
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Issuer:    s.jwtIssuer,
+		Audience:  jwt.ClaimStrings{s.jwtAudience},
+	}
+	if claims.AuthTime == nil {
+		claims.AuthTime = claims.IssuedAt
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
 
 	tokenString, err := token.SignedString(s.privateKey)
 	if err != nil {
-		utils.LogError("SESSION", "generateAccessToken", err, "uid", uid)
+		utils.LogError("SESSION", "generateAccessToken", err, "uid", claims.UID)
 		return "", fmt.Errorf("%w: %v", ErrTokenGenerationFailed, err)
 	}
 
@@ -351,7 +525,9 @@ func (s *SessionService) generateAccessToken(uid string, expiry time.Duration) (
 }
 
 // generateRefreshToken 生成 refresh_token 并写入数据库
-func (s *SessionService) generateRefreshToken(ctx context.Context, uid string, banned bool) (string, error) {
+// familyID 为所属会话；sessionCreatedAt 为会话登录时间（新会话传零值），
+// 设备信息取自 ctx 中由 ClientInfo 中间件注入的客户端 IP 与 User-Agent
+func (s *SessionService) generateRefreshToken(ctx context.Context, uid string, banned bool, familyID string, sessionCreatedAt time.Time) (string, error) {
 	bytes := make([]byte, refreshTokenByteSize)
 	if _, err := rand.Read(bytes); err != nil {
 		return "", utils.LogError("SESSION", "generateRefreshToken", err, "failed to generate random bytes")
@@ -360,18 +536,16 @@ func (s *SessionService) generateRefreshToken(ctx context.Context, uid string, b
 	tokenStr := hex.EncodeToString(bytes)
 	tokenHash := utils.HashToken(tokenStr)
 
-	familyIDBytes := make([]byte, 16)
-	if _, err := rand.Read(familyIDBytes); err != nil {
-		return "", utils.LogError("SESSION", "generateRefreshToken", err, "failed to generate family_id")
-	}
-	familyID := hex.EncodeToString(familyIDBytes)
-
+	client := utils.ClientInfoFrom(ctx)
 	sessionToken := &models.SessionToken{
 		TokenHash: tokenHash,
 		UserUID:   uid,
 		FamilyID:  familyID,
 		Banned:    banned,
 		ExpiresAt: time.Now().Add(s.refreshTokenExpiry),
+		CreatedAt: sessionCreatedAt,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
 	}
 
 	if err := s.sessionTokenRepo.Create(ctx, sessionToken); err != nil {
@@ -381,6 +555,34 @@ func (s *SessionService) generateRefreshToken(ctx context.Context, uid string, b
 	return tokenStr, nil
 }
 
+// createImpersonationSession 为模拟登录写入会话记录：令牌原文随机生成后即丢弃，
+// 仅用于让 CheckSession 能按家族判断模拟 token 是否已被撤销
+func (s *SessionService) createImpersonationSession(ctx context.Context, uid, familyID string, expiresAt time.Time) error {
+	bytes := make([]byte, refreshTokenByteSize)
+	if _, err := rand.Read(bytes); err != nil {
+		return utils.LogError("SESSION", "createImpersonationSession", err, "failed to generate random bytes")
+	}
+
+	client := utils.ClientInfoFrom(ctx)
+	return s.sessionTokenRepo.Create(ctx, &models.SessionToken{
+		TokenHash: utils.HashToken(hex.EncodeToString(bytes)),
+		UserUID:   uid,
+		FamilyID:  familyID,
+		ExpiresAt: expiresAt,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	})
+}
+
+// newFamilyID 生成新的 token 家族 ID（即会话 ID）
+func newFamilyID() (string, error) {
+	familyIDBytes := make([]byte, familyIDByteSize)
+	if _, err := rand.Read(familyIDBytes); err != nil {
+		return "", utils.LogError("SESSION", "newFamilyID", err, "failed to generate family_id")
+	}
+	return hex.EncodeToString(familyIDBytes), nil
+}
+
 // parseECDSAPrivateKey 解析 PEM 格式的 ECDSA 私钥
 func parseECDSAPrivateKey(pemData string) (*ecdsa.PrivateKey, error) {
 	block, _ := pem.Decode([]byte(pemData))
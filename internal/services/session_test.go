@@ -13,6 +13,7 @@ import (
 
 	"auth-system/internal/config"
 	"auth-system/internal/models"
+	"auth-system/internal/utils"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -101,15 +102,15 @@ func TestGenerateTokensBanned(t *testing.T) {
 	// 配置 30min，验证 banned 分支强制使用 bannedAccessTokenExpiry（15min）而非配置值
 	s := testSessionService(t, 30*time.Minute)
 
-	accessToken, refreshToken, err := s.GenerateTokens(t.Context(), "uid-123", true)
+	accessToken, refreshToken, familyID, err := s.GenerateTokens(t.Context(), "uid-123", true)
 	if err != nil {
 		t.Fatalf("GenerateTokens(banned) error = %v", err)
 	}
 	if accessToken == "" {
 		t.Error("banned user should still get an access token")
 	}
-	if refreshToken != "" {
-		t.Error("banned user should NOT get a refresh token")
+	if refreshToken != "" || familyID != "" {
+		t.Errorf("banned user should NOT get a refresh token or session, got family %q", familyID)
 	}
 
 	claims, err := s.VerifyToken(accessToken)
@@ -129,9 +130,75 @@ func TestGenerateTokensBanned(t *testing.T) {
 	}
 }
 
+func TestGenerateImpersonationToken(t *testing.T) {
+	s, repo := newSessionWithFakeRepo(t)
+
+	accessToken, expiresAt, err := s.GenerateImpersonationToken(t.Context(), "uid-target", "uid-root")
+	if err != nil {
+		t.Fatalf("GenerateImpersonationToken error = %v", err)
+	}
+
+	claims, err := s.VerifyToken(accessToken)
+	if err != nil {
+		t.Fatalf("impersonation token should verify: %v", err)
+	}
+	if claims.UID != "uid-target" || claims.Impersonator() != "uid-root" {
+		t.Errorf("claims uid = %q impersonator = %q", claims.UID, claims.Impersonator())
+	}
+	// 模拟 token 归属独立会话，会话与 token 同时过期，撤销后 CheckSession 拒绝
+	if len(repo.created) != 1 || claims.SID == "" || repo.created[0].FamilyID != claims.SID || repo.created[0].UserUID != "uid-target" {
+		t.Fatalf("impersonation session = %+v, sid = %q", repo.created, claims.SID)
+	}
+	if !repo.created[0].ExpiresAt.Equal(expiresAt) {
+		t.Errorf("session expires_at = %v, want %v", repo.created[0].ExpiresAt, expiresAt)
+	}
+	repo.familyInactive = true
+	if err := s.CheckSession(t.Context(), claims); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("CheckSession after revoke = %v, want ErrSessionRevoked", err)
+	}
+	if remaining := time.Until(expiresAt); remaining <= 0 || remaining > impersonationTokenExpiry {
+		t.Errorf("remaining %v should be within (0, %v]", remaining, impersonationTokenExpiry)
+	}
+
+	// 普通 token 不带 act
+	regular, _ := s.generateAccessToken("uid-target", "", time.Time{}, time.Minute)
+	if claims, _ := s.VerifyToken(regular); claims.Impersonator() != "" {
+		t.Errorf("regular token impersonator = %q, want empty", claims.Impersonator())
+	}
+
+	if _, _, err := s.GenerateImpersonationToken(t.Context(), "uid-target", ""); !errors.Is(err, ErrInvalidUser) {
+		t.Errorf("empty impersonator error = %v, want ErrInvalidUser", err)
+	}
+}
+
+func TestAccessTokenAuthTime(t *testing.T) {
+	s := testSessionService(t, 15*time.Minute)
+
+	// 新登录：auth_time 即签发时间
+	fresh, _ := s.generateAccessToken("user-a", "fam-1", time.Time{}, time.Minute)
+	claims, err := s.VerifyToken(fresh)
+	if err != nil {
+		t.Fatalf("VerifyToken error = %v", err)
+	}
+	if claims.AuthTime == nil || !claims.LoginTime().Equal(claims.IssuedAt.Time) {
+		t.Errorf("fresh token auth_time = %v, want iat %v", claims.AuthTime, claims.IssuedAt)
+	}
+
+	// 轮转：沿用会话登录时间
+	loginAt := time.Now().Add(-6 * time.Hour).Truncate(time.Second)
+	rotated, _ := s.generateAccessToken("user-a", "fam-1", loginAt, time.Minute)
+	claims, err = s.VerifyToken(rotated)
+	if err != nil {
+		t.Fatalf("VerifyToken error = %v", err)
+	}
+	if !claims.LoginTime().Equal(loginAt) {
+		t.Errorf("rotated token login time = %v, want %v", claims.LoginTime(), loginAt)
+	}
+}
+
 func TestGenerateTokensInvalidUser(t *testing.T) {
 	s := testSessionService(t, 15*time.Minute)
-	if _, _, err := s.GenerateTokens(t.Context(), "", false); !errors.Is(err, ErrInvalidUser) {
+	if _, _, _, err := s.GenerateTokens(t.Context(), "", false); !errors.Is(err, ErrInvalidUser) {
 		t.Errorf("empty uid error = %v, want ErrInvalidUser", err)
 	}
 }
@@ -140,7 +207,7 @@ func TestVerifyTokenLifecycle(t *testing.T) {
 	s := testSessionService(t, 15*time.Minute)
 
 	// 正常生成 + 验证（generateAccessToken 为纯 JWT，不依赖 DB）
-	accessToken, err := s.generateAccessToken("user-a", 15*time.Minute)
+	accessToken, err := s.generateAccessToken("user-a", "", time.Time{}, 15*time.Minute)
 	if err != nil {
 		t.Fatalf("generateAccessToken error = %v", err)
 	}
@@ -167,7 +234,7 @@ func TestVerifyTokenLifecycle(t *testing.T) {
 func TestVerifyTokenExpired(t *testing.T) {
 	s := testSessionService(t, 15*time.Minute)
 	// 直接用过期时长生成 token
-	token, err := s.generateAccessToken("user-a", -time.Minute)
+	token, err := s.generateAccessToken("user-a", "", time.Time{}, -time.Minute)
 	if err != nil {
 		t.Fatalf("generateAccessToken error = %v", err)
 	}
@@ -191,7 +258,7 @@ func TestVerifyTokenRejectsWrongAlgorithm(t *testing.T) {
 func TestVerifyTokenRejectsEmptyUID(t *testing.T) {
 	s := testSessionService(t, 15*time.Minute)
 	// 空 UID 的 claims → ErrInvalidUser
-	token, err := s.generateAccessToken("", 5*time.Minute)
+	token, err := s.generateAccessToken("", "", time.Time{}, 5*time.Minute)
 	if err != nil {
 		t.Fatalf("generateAccessToken error = %v", err)
 	}
@@ -241,6 +308,9 @@ type fakeSessionTokenStore struct {
 	created         []*models.SessionToken
 	revokedFamilies []string
 	revokedUsers    []string
+	familyRevokes   int64
+	activeChecks    int
+	familyInactive  bool
 }
 
 func (f *fakeSessionTokenStore) Create(_ context.Context, t *models.SessionToken) error {
@@ -258,11 +328,26 @@ func (f *fakeSessionTokenStore) RevokeFamily(_ context.Context, familyID string)
 	f.revokedFamilies = append(f.revokedFamilies, familyID)
 	return 0, nil
 }
+func (f *fakeSessionTokenStore) ListActiveByUser(context.Context, string) ([]*models.SessionToken, error) {
+	return f.created, nil
+}
+func (f *fakeSessionTokenStore) RevokeUserFamily(_ context.Context, _, familyID string) (int64, error) {
+	f.revokedFamilies = append(f.revokedFamilies, familyID)
+	return f.familyRevokes, nil
+}
+func (f *fakeSessionTokenStore) RevokeUserExcept(_ context.Context, userUID, _ string) (int64, error) {
+	f.revokedUsers = append(f.revokedUsers, userUID)
+	return 0, nil
+}
 func (f *fakeSessionTokenStore) RevokeUser(_ context.Context, userUID string) (int64, error) {
 	f.revokedUsers = append(f.revokedUsers, userUID)
 	return 0, nil
 }
 func (f *fakeSessionTokenStore) DeleteExpired(context.Context) (int64, error) { return 0, nil }
+func (f *fakeSessionTokenStore) FamilyActive(context.Context, string) (bool, error) {
+	f.activeChecks++
+	return !f.familyInactive, nil
+}
 
 // newSessionWithFakeRepo 构造注入 fake repo 的 SessionService
 func newSessionWithFakeRepo(t *testing.T) (*SessionService, *fakeSessionTokenStore) {
@@ -307,6 +392,67 @@ func TestRefreshTokensSuccess(t *testing.T) {
 	}
 }
 
+func TestRefreshTokensKeepsSessionFamily(t *testing.T) {
+	s, repo := newSessionWithFakeRepo(t)
+	existing := unexpiredSessionToken()
+	existing.CreatedAt = time.Now().Add(-48 * time.Hour)
+	repo.findResult = existing
+
+	ctx := utils.WithClientInfo(context.Background(), utils.ClientInfo{IP: "203.0.113.7", UserAgent: "test-agent"})
+	access, _, err := s.RefreshTokens(ctx, "refresh-token-str")
+	if err != nil {
+		t.Fatalf("RefreshTokens() error = %v", err)
+	}
+
+	// 轮转沿用原家族与登录时间，设备信息更新为本次请求
+	created := repo.created[0]
+	if created.FamilyID != "fam-1" || !created.CreatedAt.Equal(existing.CreatedAt) {
+		t.Errorf("rotated token family=%s created_at=%v, want fam-1 / %v", created.FamilyID, created.CreatedAt, existing.CreatedAt)
+	}
+	if created.IP != "203.0.113.7" || created.UserAgent != "test-agent" {
+		t.Errorf("rotated token device = %s / %s", created.IP, created.UserAgent)
+	}
+
+	claims, err := s.VerifyToken(access)
+	if err != nil {
+		t.Fatalf("VerifyToken() error = %v", err)
+	}
+	if claims.SID != "fam-1" {
+		t.Errorf("access token sid = %q, want fam-1", claims.SID)
+	}
+}
+
+func TestGenerateTokensStartsNewSession(t *testing.T) {
+	s, repo := newSessionWithFakeRepo(t)
+
+	access, _, familyID, err := s.GenerateTokens(context.Background(), "u1", false)
+	if err != nil {
+		t.Fatalf("GenerateTokens() error = %v", err)
+	}
+	claims, err := s.VerifyToken(access)
+	if err != nil {
+		t.Fatalf("VerifyToken() error = %v", err)
+	}
+	if len(repo.created) != 1 || claims.SID == "" || claims.SID != repo.created[0].FamilyID || familyID != claims.SID {
+		t.Errorf("access token sid = %q, returned family = %q, want refresh family %+v", claims.SID, familyID, repo.created)
+	}
+
+	// 封禁用户的短期 token 不属于任何会话
+	access, _, _, _ = s.GenerateTokens(context.Background(), "u1", true)
+	if claims, _ := s.VerifyToken(access); claims == nil || claims.SID != "" {
+		t.Errorf("banned access token should carry no sid, got %+v", claims)
+	}
+}
+
+// 家族不存在或属于其他用户时（删除 0 行）返回 ErrSessionNotFound
+func TestRevokeTokenFamilyNotFound(t *testing.T) {
+	s, _ := newSessionWithFakeRepo(t)
+
+	if err := s.RevokeTokenFamily(context.Background(), "u1", "fam-x"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RevokeTokenFamily() error = %v, want ErrSessionNotFound", err)
+	}
+}
+
 func TestRefreshTokensBannedShortLived(t *testing.T) {
 	s, repo := newSessionWithFakeRepo(t)
 	tok := unexpiredSessionToken()
@@ -417,6 +563,7 @@ func TestRevokeUserTokens(t *testing.T) {
 
 func TestRevokeTokenFamily(t *testing.T) {
 	s, repo := newSessionWithFakeRepo(t)
+	repo.familyRevokes = 1
 	if err := s.RevokeTokenFamily(context.Background(), "u1", "fam-1"); err != nil {
 		t.Fatalf("RevokeTokenFamily() error = %v", err)
 	}
@@ -427,3 +574,34 @@ func TestRevokeTokenFamily(t *testing.T) {
 		t.Error("RevokeTokenFamily(empty) should error")
 	}
 }
+
+func TestCheckSession(t *testing.T) {
+	s, repo := newSessionWithFakeRepo(t)
+	claims := &Claims{UID: "u1", SID: "fam-1"}
+
+	if err := s.CheckSession(context.Background(), claims); err != nil {
+		t.Fatalf("CheckSession(active) error = %v", err)
+	}
+	// 第二次命中缓存，不再查库
+	if err := s.CheckSession(context.Background(), claims); err != nil {
+		t.Fatalf("CheckSession(cached) error = %v", err)
+	}
+	if repo.activeChecks != 1 {
+		t.Errorf("activeChecks = %d, want 1", repo.activeChecks)
+	}
+
+	// 撤销后本实例缓存立即失效
+	repo.familyRevokes = 1
+	if err := s.RevokeTokenFamily(context.Background(), "u1", "fam-1"); err != nil {
+		t.Fatalf("RevokeTokenFamily() error = %v", err)
+	}
+	repo.familyInactive = true
+	if err := s.CheckSession(context.Background(), claims); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("CheckSession(revoked) error = %v, want ErrSessionRevoked", err)
+	}
+
+	// 无 sid 的旧 token 不查库
+	if err := s.CheckSession(context.Background(), &Claims{UID: "u1"}); err != nil {
+		t.Errorf("CheckSession(no sid) error = %v", err)
+	}
+}
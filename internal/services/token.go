@@ -30,6 +30,8 @@ const (
 	TokenTypeResetPassword  = "reset_password"
 	TokenTypeChangePassword = "change_password"
 	TokenTypeDeleteAccount  = "delete_account"
+	TokenTypeChangeEmail    = "change_email"     // 修改邮箱：发往当前邮箱，证明账户持有者
+	TokenTypeChangeEmailNew = "change_email_new" // 修改邮箱：发往新邮箱，证明新地址可收信
 
 	tokenExpiry = 5 * time.Minute
 )
@@ -415,6 +417,21 @@ func (s *TokenService) CleanupExpired(ctx context.Context) {
 		}
 	})
 
+	wg.Go(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				utils.LogError("TOKEN", "OAuthDeviceCodeCleanupPanic", fmt.Errorf("%v", r))
+			}
+		}()
+
+		repo := models.NewOAuthDeviceCodeRepository(s.pool)
+		if count, err := repo.DeleteExpired(ctx); err != nil {
+			utils.LogWarn("TOKEN", "Failed to cleanup OAuth device codes", "error", err)
+		} else if count > 0 {
+			utils.LogInfo("TOKEN", "Cleaned up expired OAuth device codes", "count", count)
+		}
+	})
+
 	wg.Go(func() {
 		defer func() {
 			if r := recover(); r != nil {
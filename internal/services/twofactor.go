@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"auth-system/internal/models"
+	"auth-system/internal/utils"
+)
+
+var (
+	ErrTwoFactorNotConfigured = errors.New("two-factor encryption key not configured")
+)
+
+const (
+	defaultTwoFactorChallengeTTL = 5 * time.Minute
+	// twoFactorMaxAttempts 单个登录挑战允许的验证码错误次数，超出后需重新输入密码
+	twoFactorMaxAttempts = 5
+
+	recoveryCodeCount = 10
+	// recoveryCodeChars 去除易混淆字符（0/o/1/l/i）的小写字母数字
+	recoveryCodeChars  = "23456789abcdefghjkmnpqrstuvwxyz"
+	recoveryCodeLength = 10 // 展示为 xxxxx-xxxxx
+)
+
+// TOTPEnrollment 绑定两步验证时生成的密钥信息
+type TOTPEnrollment struct {
+	Secret          string // base32 明文密钥，仅返回给用户一次
+	EncryptedSecret string // 入库的加密密钥
+	URI             string // otpauth:// 绑定 URI，前端渲染为二维码
+}
+
+type twoFactorChallenge struct {
+	UserUID   string    `json:"user_uid"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Attempts  int       `json:"attempts"`
+}
+
+// TwoFactorService 两步验证服务：TOTP 密钥加解密与校验、恢复码生成、登录挑战管理
+// 登录挑战存于流程状态存储（多实例部署为 oauth_flow_states 表），密码验证与验证码提交可由不同实例处理
+type TwoFactorService struct {
+	key    []byte // 为空表示未配置加密密钥
+	issuer string
+	store  models.OAuthFlowStateStore
+}
+
+// NewTwoFactorService 创建两步验证服务，encryptionKey 为空时仍可处理登录挑战与恢复码，但无法绑定或校验 TOTP
+func NewTwoFactorService(encryptionKey, issuer string, store models.OAuthFlowStateStore) (*TwoFactorService, error) {
+	if store == nil {
+		return nil, fmt.Errorf("two-factor challenge store is required")
+	}
+
+	var key []byte
+	if encryptionKey != "" {
+		derived, err := utils.DeriveKeyFromString(encryptionKey, "nebula-totp-secret")
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive totp key: %w", err)
+		}
+		key = derived
+	}
+
+	svc := &TwoFactorService{
+		key:    key,
+		issuer: issuer,
+		store:  store,
+	}
+
+	utils.LogInfo("TWO_FACTOR", "Service initialized", "configured", key != nil)
+	return svc, nil
+}
+
+// IsConfigured 是否配置了 TOTP 加密密钥
+func (s *TwoFactorService) IsConfigured() bool {
+	return s.key != nil
+}
+
+// NewEnrollment 为账户生成新的 TOTP 密钥及绑定 URI
+func (s *TwoFactorService) NewEnrollment(accountName string) (*TOTPEnrollment, error) {
+	if !s.IsConfigured() {
+		return nil, ErrTwoFactorNotConfigured
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := utils.EncryptAESGCM([]byte(secret), s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret:          secret,
+		EncryptedSecret: encrypted,
+		URI:             utils.TOTPProvisioningURI(s.issuer, accountName, secret),
+	}, nil
+}
+
+// VerifyCode 解密密钥并校验 TOTP 验证码，成功返回命中的时间步（需由调用方持久化以防重放）
+func (s *TwoFactorService) VerifyCode(encryptedSecret, code string, afterStep int64) (int64, bool, error) {
+	if !s.IsConfigured() {
+		return 0, false, ErrTwoFactorNotConfigured
+	}
+
+	secret, err := utils.DecryptAESGCM(encryptedSecret, s.key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	step, ok := utils.VerifyTOTP(string(secret), code, time.Now(), afterStep)
+	return step, ok, nil
+}
+
+// GenerateRecoveryCodes 生成一组一次性恢复码，返回明文（仅展示一次）与对应哈希（入库）
+func (s *TwoFactorService) GenerateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	charLen := big.NewInt(int64(len(recoveryCodeChars)))
+
+	for range recoveryCodeCount {
+		raw := make([]byte, recoveryCodeLength)
+		for i := range raw {
+			n, err := rand.Int(rand.Reader, charLen)
+			if err != nil {
+				return nil, nil, utils.LogError("TWO_FACTOR", "GenerateRecoveryCodes", err)
+			}
+			raw[i] = recoveryCodeChars[n.Int64()]
+		}
+		code := string(raw[:recoveryCodeLength/2]) + "-" + string(raw[recoveryCodeLength/2:])
+		codes = append(codes, code)
+		hashes = append(hashes, s.HashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode 规范化（去空白/连字符、转小写）后计算恢复码哈希
+func (s *TwoFactorService) HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	if normalized == "" {
+		return ""
+	}
+	return utils.HashToken(normalized)
+}
+
+// CreateChallenge 密码验证通过后为用户创建短期登录挑战
+func (s *TwoFactorService) CreateChallenge(ctx context.Context, userUID string) (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(bytes)
+
+	entry := &twoFactorChallenge{
+		UserUID:   userUID,
+		ExpiresAt: time.Now().Add(defaultTwoFactorChallengeTTL),
+	}
+	if err := s.saveChallenge(ctx, token, entry); err != nil {
+		return "", err
+	}
+
+	utils.LogInfo("TWO_FACTOR", "Challenge created", "user_uid", userUID)
+	return token, nil
+}
+
+// AttemptChallenge 在校验验证码之前为挑战计入一次尝试，返回挑战对应的用户；过期、不存在或次数用尽返回 false。
+// 先原子取出再以剩余有效期写回：并发提交同一挑战时只有取到挑战的一方继续校验，其余请求视为挑战无效，
+// 因此并行请求无法绕过次数上限。第 twoFactorMaxAttempts 次尝试不再写回，失败即作废挑战
+func (s *TwoFactorService) AttemptChallenge(ctx context.Context, token string) (string, bool) {
+	entry, ok := s.loadChallenge(ctx, token, true)
+	if !ok {
+		return "", false
+	}
+
+	entry.Attempts++
+	if entry.Attempts > twoFactorMaxAttempts {
+		utils.LogWarn("TWO_FACTOR", "Challenge revoked after too many failures", "user_uid", entry.UserUID)
+		return "", false
+	}
+	if entry.Attempts < twoFactorMaxAttempts {
+		if err := s.saveChallenge(ctx, token, entry); err != nil {
+			utils.LogWarn("TWO_FACTOR", "Failed to save challenge attempts, challenge revoked", "user_uid", entry.UserUID)
+		}
+	}
+	return entry.UserUID, true
+}
+
+// ConsumeChallenge 验证成功后作废挑战（一次性）
+func (s *TwoFactorService) ConsumeChallenge(ctx context.Context, token string) {
+	if err := s.store.Delete(ctx, flowKindTwoFactorChallenge, token); err != nil {
+		utils.LogWarn("TWO_FACTOR", "Failed to delete challenge", "error", err)
+	}
+}
+
+func (s *TwoFactorService) saveChallenge(ctx context.Context, token string, entry *twoFactorChallenge) error {
+	ttl := time.Until(entry.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return utils.LogError("TWO_FACTOR", "saveChallenge", err)
+	}
+	return s.store.Save(ctx, flowKindTwoFactorChallenge, token, payload, ttl)
+}
+
+// loadChallenge 读取挑战，remove 为 true 时原子地取出并删除
+func (s *TwoFactorService) loadChallenge(ctx context.Context, token string, remove bool) (*twoFactorChallenge, bool) {
+	if token == "" {
+		return nil, false
+	}
+
+	var payload []byte
+	var err error
+	if remove {
+		payload, err = s.store.GetAndDelete(ctx, flowKindTwoFactorChallenge, token)
+	} else {
+		payload, err = s.store.Get(ctx, flowKindTwoFactorChallenge, token)
+	}
+	if err != nil {
+		if !errors.Is(err, models.ErrOAuthFlowStateNotFound) {
+			utils.LogWarn("TWO_FACTOR", "Failed to load challenge", "error", err)
+		}
+		return nil, false
+	}
+
+	entry := &twoFactorChallenge{}
+	if err := json.Unmarshal(payload, entry); err != nil || entry.UserUID == "" || !time.Now().Before(entry.ExpiresAt) {
+		return nil, false
+	}
+	return entry, true
+}
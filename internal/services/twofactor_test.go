@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"auth-system/internal/utils"
+)
+
+func newTwoFactorForTest(t *testing.T, key string) *TwoFactorService {
+	t.Helper()
+	store, err := NewMemoryFlowStateStore()
+	if err != nil {
+		t.Fatalf("NewMemoryFlowStateStore() error = %v", err)
+	}
+	svc, err := NewTwoFactorService(key, "Nebula Test", store)
+	if err != nil {
+		t.Fatalf("NewTwoFactorService() error = %v", err)
+	}
+	return svc
+}
+
+func TestTwoFactorEnrollmentAndVerify(t *testing.T) {
+	svc := newTwoFactorForTest(t, "totp-test-key")
+
+	enrollment, err := svc.NewEnrollment("alice@example.com")
+	if err != nil {
+		t.Fatalf("NewEnrollment() error = %v", err)
+	}
+	if strings.Contains(enrollment.EncryptedSecret, enrollment.Secret) {
+		t.Error("stored secret should be encrypted")
+	}
+	if !strings.HasPrefix(enrollment.URI, "otpauth://totp/") {
+		t.Errorf("URI = %s", enrollment.URI)
+	}
+
+	step := utils.TOTPStep(time.Now())
+	code, _ := utils.TOTPCodeAt(enrollment.Secret, step)
+	got, ok, err := svc.VerifyCode(enrollment.EncryptedSecret, code, 0)
+	if err != nil || !ok || got < step-1 {
+		t.Fatalf("VerifyCode() = (%d, %v, %v)", got, ok, err)
+	}
+
+	// 已使用的时间步不可重放
+	if _, ok, _ := svc.VerifyCode(enrollment.EncryptedSecret, code, got); ok {
+		t.Error("code should not verify again after its step is consumed")
+	}
+}
+
+func TestTwoFactorNotConfigured(t *testing.T) {
+	svc := newTwoFactorForTest(t, "")
+	if svc.IsConfigured() {
+		t.Fatal("service without key should not be configured")
+	}
+	if _, err := svc.NewEnrollment("alice@example.com"); !errors.Is(err, ErrTwoFactorNotConfigured) {
+		t.Errorf("NewEnrollment() error = %v, want ErrTwoFactorNotConfigured", err)
+	}
+}
+
+func TestTwoFactorRecoveryCodes(t *testing.T) {
+	svc := newTwoFactorForTest(t, "totp-test-key")
+
+	codes, hashes, err := svc.GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes() error = %v", err)
+	}
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("got %d codes / %d hashes", len(codes), len(hashes))
+	}
+	if len(codes[0]) != recoveryCodeLength+1 || codes[0][5] != '-' {
+		t.Errorf("unexpected code format %q", codes[0])
+	}
+	// 输入时大小写、连字符不影响匹配
+	loose := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	if svc.HashRecoveryCode(loose) != hashes[0] {
+		t.Error("normalized recovery code should hash to the stored value")
+	}
+	if svc.HashRecoveryCode("  ") != "" {
+		t.Error("blank recovery code should hash to empty string")
+	}
+}
+
+func TestTwoFactorChallengeLifecycle(t *testing.T) {
+	svc := newTwoFactorForTest(t, "")
+	ctx := context.Background()
+
+	token, err := svc.CreateChallenge(ctx, "u1")
+	if err != nil {
+		t.Fatalf("CreateChallenge() error = %v", err)
+	}
+	if uid, ok := svc.AttemptChallenge(ctx, token); !ok || uid != "u1" {
+		t.Fatalf("AttemptChallenge() = (%q, %v)", uid, ok)
+	}
+
+	svc.ConsumeChallenge(ctx, token)
+	if _, ok := svc.AttemptChallenge(ctx, token); ok {
+		t.Error("consumed challenge should not resolve")
+	}
+}
+
+func TestTwoFactorChallengeMaxAttempts(t *testing.T) {
+	svc := newTwoFactorForTest(t, "")
+	ctx := context.Background()
+
+	token, _ := svc.CreateChallenge(ctx, "u1")
+	for i := range twoFactorMaxAttempts {
+		if _, ok := svc.AttemptChallenge(ctx, token); !ok {
+			t.Fatalf("attempt %d should be allowed", i+1)
+		}
+	}
+	if _, ok := svc.AttemptChallenge(ctx, token); ok {
+		t.Error("challenge should be revoked after too many attempts")
+	}
+}
+
+func TestTwoFactorChallengeParallelAttempts(t *testing.T) {
+	svc := newTwoFactorForTest(t, "")
+	ctx := context.Background()
+	token, _ := svc.CreateChallenge(ctx, "u1")
+
+	// 并行提交同一挑战：成功取得挑战（进入验证码校验）的总次数不超过上限
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Go(func() {
+			if _, ok := svc.AttemptChallenge(ctx, token); ok {
+				allowed.Add(1)
+			}
+		})
+	}
+	wg.Wait()
+	for {
+		if _, ok := svc.AttemptChallenge(ctx, token); !ok {
+			break
+		}
+		allowed.Add(1)
+	}
+	if n := allowed.Load(); n > twoFactorMaxAttempts {
+		t.Errorf("allowed %d attempts, want at most %d", n, twoFactorMaxAttempts)
+	}
+}
+
+func TestTwoFactorChallengeSharedStore(t *testing.T) {
+	store, err := NewMemoryFlowStateStore()
+	if err != nil {
+		t.Fatalf("NewMemoryFlowStateStore() error = %v", err)
+	}
+	// 共享同一存储的两个实例：密码验证与验证码提交可由不同实例处理
+	first, _ := NewTwoFactorService("", "Nebula Test", store)
+	second, _ := NewTwoFactorService("", "Nebula Test", store)
+	ctx := context.Background()
+
+	token, err := first.CreateChallenge(ctx, "u1")
+	if err != nil {
+		t.Fatalf("CreateChallenge() error = %v", err)
+	}
+	if uid, ok := second.AttemptChallenge(ctx, token); !ok || uid != "u1" {
+		t.Fatalf("AttemptChallenge() on another instance = (%q, %v)", uid, ok)
+	}
+	second.ConsumeChallenge(ctx, token)
+	if _, ok := first.AttemptChallenge(ctx, token); ok {
+		t.Error("challenge consumed on another instance should not resolve")
+	}
+}
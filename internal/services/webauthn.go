@@ -0,0 +1,368 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"auth-system/internal/models"
+	"auth-system/internal/utils"
+)
+
+var (
+	ErrWebAuthnChallengeInvalid = errors.New("webauthn challenge invalid or expired")
+	ErrWebAuthnOriginMismatch   = errors.New("webauthn origin mismatch")
+	ErrWebAuthnRPIDMismatch     = errors.New("webauthn rp id mismatch")
+	ErrWebAuthnUserNotVerified  = errors.New("webauthn user not verified")
+	ErrWebAuthnUserMismatch     = errors.New("webauthn user handle mismatch")
+	ErrWebAuthnSignCount        = errors.New("webauthn sign count did not increase")
+)
+
+const (
+	defaultWebAuthnChallengeTTL = 5 * time.Minute
+	webAuthnChallengeSize       = 32
+
+	webAuthnCeremonyCreate = "webauthn.create"
+	webAuthnCeremonyGet    = "webauthn.get"
+)
+
+// WebAuthnRelyingParty RP 信息
+type WebAuthnRelyingParty struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+// WebAuthnUserEntity 注册时的用户信息（id 为 base64url 编码的用户 UID）
+type WebAuthnUserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// WebAuthnCredentialParam 支持的公钥算法
+type WebAuthnCredentialParam struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+// WebAuthnCredentialDescriptor 凭据描述（id 为 base64url）
+type WebAuthnCredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// WebAuthnAuthenticatorSelection 认证器要求
+type WebAuthnAuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+// WebAuthnRegistrationOptions PublicKeyCredentialCreationOptions（二进制字段均为 base64url，由前端解码）
+type WebAuthnRegistrationOptions struct {
+	Challenge              string                         `json:"challenge"`
+	RP                     WebAuthnRelyingParty           `json:"rp"`
+	User                   WebAuthnUserEntity             `json:"user"`
+	PubKeyCredParams       []WebAuthnCredentialParam      `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout"`
+	ExcludeCredentials     []WebAuthnCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection WebAuthnAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                         `json:"attestation"`
+}
+
+// WebAuthnLoginOptions PublicKeyCredentialRequestOptions（可发现凭据，不指定 allowCredentials）
+type WebAuthnLoginOptions struct {
+	Challenge        string `json:"challenge"`
+	RPID             string `json:"rpId"`
+	Timeout          int64  `json:"timeout"`
+	UserVerification string `json:"userVerification"`
+}
+
+// WebAuthnRegistrationResponse 浏览器 navigator.credentials.create() 的结果（base64url 编码）
+type WebAuthnRegistrationResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON"`
+		AttestationObject string   `json:"attestationObject"`
+		Transports        []string `json:"transports"`
+	} `json:"response"`
+}
+
+// WebAuthnAssertionResponse 浏览器 navigator.credentials.get() 的结果（base64url 编码）
+type WebAuthnAssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// WebAuthnNewCredential 注册仪式校验通过后待入库的凭据
+type WebAuthnNewCredential struct {
+	CredentialID   []byte
+	PublicKey      []byte // COSE_Key
+	SignCount      uint32
+	AAGUID         []byte
+	Transports     []string
+	BackupEligible bool
+	BackedUp       bool
+}
+
+type webAuthnChallenge struct {
+	Ceremony string `json:"ceremony"`
+	UserUID  string `json:"user_uid"` // 注册仪式绑定的用户；登录仪式为空
+}
+
+// WebAuthnService Passkey 服务：生成注册/登录仪式参数，校验浏览器返回的凭据。
+// 挑战以自身（base64url）为键存入流程状态存储（多实例部署为 oauth_flow_states 表），
+// 校验时从 clientDataJSON 取出并原子地一次性消费。
+type WebAuthnService struct {
+	rpID   string
+	rpName string
+	origin string
+	store  models.OAuthFlowStateStore
+}
+
+// NewWebAuthnService 创建 Passkey 服务。origin 取自 baseURL；rpID 为空时使用 baseURL 的主机名。
+func NewWebAuthnService(baseURL, rpID, rpName string, store models.OAuthFlowStateStore) (*WebAuthnService, error) {
+	if store == nil {
+		return nil, fmt.Errorf("webauthn challenge store is required")
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base url for webauthn: %q", baseURL)
+	}
+	if rpID == "" {
+		rpID = u.Hostname()
+	}
+
+	svc := &WebAuthnService{
+		rpID:   rpID,
+		rpName: rpName,
+		origin: u.Scheme + "://" + u.Host,
+		store:  store,
+	}
+
+	utils.LogInfo("WEBAUTHN", "Service initialized", "rp_id", rpID, "origin", svc.origin)
+	return svc, nil
+}
+
+// BeginRegistration 生成注册仪式参数，exclude 为用户已有凭据（防止同一认证器重复注册）
+func (s *WebAuthnService) BeginRegistration(ctx context.Context, userUID, userName, displayName string, exclude []*models.WebAuthnCredential) (*WebAuthnRegistrationOptions, error) {
+	challenge, err := s.newChallenge(ctx, webAuthnCeremonyCreate, userUID)
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([]WebAuthnCredentialParam, 0, len(utils.WebAuthnSupportedAlgorithms))
+	for _, alg := range utils.WebAuthnSupportedAlgorithms {
+		params = append(params, WebAuthnCredentialParam{Type: "public-key", Alg: alg})
+	}
+
+	excludeCredentials := make([]WebAuthnCredentialDescriptor, 0, len(exclude))
+	for _, cred := range exclude {
+		excludeCredentials = append(excludeCredentials, WebAuthnCredentialDescriptor{
+			Type:       "public-key",
+			ID:         utils.EncodeWebAuthnBase64(cred.CredentialID),
+			Transports: cred.Transports,
+		})
+	}
+
+	return &WebAuthnRegistrationOptions{
+		Challenge:          challenge,
+		RP:                 WebAuthnRelyingParty{ID: s.rpID, Name: s.rpName},
+		User:               WebAuthnUserEntity{ID: utils.EncodeWebAuthnBase64([]byte(userUID)), Name: userName, DisplayName: displayName},
+		PubKeyCredParams:   params,
+		Timeout:            defaultWebAuthnChallengeTTL.Milliseconds(),
+		ExcludeCredentials: excludeCredentials,
+		// Passkey 需为可发现凭据并强制用户验证，才能作为无密码登录的唯一因素
+		AuthenticatorSelection: WebAuthnAuthenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   "required",
+		},
+		Attestation: "none",
+	}, nil
+}
+
+// FinishRegistration 校验注册仪式结果，返回待入库的凭据
+func (s *WebAuthnService) FinishRegistration(ctx context.Context, userUID string, resp *WebAuthnRegistrationResponse) (*WebAuthnNewCredential, error) {
+	clientDataJSON, err := utils.DecodeWebAuthnBase64(resp.Response.ClientDataJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%w: client data encoding", utils.ErrWebAuthnMalformed)
+	}
+	if err := s.verifyClientData(ctx, clientDataJSON, webAuthnCeremonyCreate, userUID); err != nil {
+		return nil, err
+	}
+
+	attestationObject, err := utils.DecodeWebAuthnBase64(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestation object encoding", utils.ErrWebAuthnMalformed)
+	}
+	authData, _, err := utils.ParseWebAuthnAttestation(attestationObject)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyAuthData(authData); err != nil {
+		return nil, err
+	}
+
+	// 拒绝本服务无法校验签名的公钥，避免注册后无法登录
+	if _, _, err := utils.ParseCOSEPublicKey(authData.PublicKey); err != nil {
+		return nil, err
+	}
+
+	return &WebAuthnNewCredential{
+		CredentialID:   authData.CredentialID,
+		PublicKey:      authData.PublicKey,
+		SignCount:      authData.SignCount,
+		AAGUID:         authData.AAGUID,
+		Transports:     resp.Response.Transports,
+		BackupEligible: authData.HasFlag(utils.WebAuthnFlagBackupEligible),
+		BackedUp:       authData.HasFlag(utils.WebAuthnFlagBackedUp),
+	}, nil
+}
+
+// BeginLogin 生成无密码登录仪式参数
+func (s *WebAuthnService) BeginLogin(ctx context.Context) (*WebAuthnLoginOptions, error) {
+	challenge, err := s.newChallenge(ctx, webAuthnCeremonyGet, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebAuthnLoginOptions{
+		Challenge:        challenge,
+		RPID:             s.rpID,
+		Timeout:          defaultWebAuthnChallengeTTL.Milliseconds(),
+		UserVerification: "required",
+	}, nil
+}
+
+// FinishLogin 使用已存储的凭据校验登录断言，返回认证器上报的新签名计数
+func (s *WebAuthnService) FinishLogin(ctx context.Context, resp *WebAuthnAssertionResponse, cred *models.WebAuthnCredential) (uint32, error) {
+	clientDataJSON, err := utils.DecodeWebAuthnBase64(resp.Response.ClientDataJSON)
+	if err != nil {
+		return 0, fmt.Errorf("%w: client data encoding", utils.ErrWebAuthnMalformed)
+	}
+	if err := s.verifyClientData(ctx, clientDataJSON, webAuthnCeremonyGet, ""); err != nil {
+		return 0, err
+	}
+
+	if resp.Response.UserHandle != "" {
+		userHandle, err := utils.DecodeWebAuthnBase64(resp.Response.UserHandle)
+		if err != nil || string(userHandle) != cred.UserUID {
+			return 0, ErrWebAuthnUserMismatch
+		}
+	}
+
+	rawAuthData, err := utils.DecodeWebAuthnBase64(resp.Response.AuthenticatorData)
+	if err != nil {
+		return 0, fmt.Errorf("%w: authenticator data encoding", utils.ErrWebAuthnMalformed)
+	}
+	authData, err := utils.ParseWebAuthnAuthData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.verifyAuthData(authData); err != nil {
+		return 0, err
+	}
+
+	signature, err := utils.DecodeWebAuthnBase64(resp.Response.Signature)
+	if err != nil {
+		return 0, fmt.Errorf("%w: signature encoding", utils.ErrWebAuthnMalformed)
+	}
+	if err := utils.VerifyWebAuthnSignature(cred.PublicKey, rawAuthData, clientDataJSON, signature); err != nil {
+		return 0, err
+	}
+
+	// 计数器均为 0 表示认证器不支持计数（多数同步 Passkey）；否则必须递增，回退视为凭据被克隆
+	if (authData.SignCount != 0 || cred.SignCount != 0) && int64(authData.SignCount) <= cred.SignCount {
+		utils.LogWarn("WEBAUTHN", "Sign count did not increase, possible cloned authenticator",
+			"credential_id", cred.ID, "stored", cred.SignCount, "received", authData.SignCount)
+		return 0, ErrWebAuthnSignCount
+	}
+
+	return authData.SignCount, nil
+}
+
+// verifyClientData 校验 clientDataJSON 的仪式类型、来源，并一次性消费其中的挑战
+func (s *WebAuthnService) verifyClientData(ctx context.Context, raw []byte, ceremony, userUID string) error {
+	clientData, err := utils.ParseWebAuthnClientData(raw)
+	if err != nil {
+		return err
+	}
+	if clientData.Type != ceremony {
+		return fmt.Errorf("%w: unexpected ceremony type %q", utils.ErrWebAuthnMalformed, clientData.Type)
+	}
+	if clientData.Origin != s.origin || clientData.CrossOrigin {
+		return ErrWebAuthnOriginMismatch
+	}
+
+	if !s.consumeChallenge(ctx, clientData.Challenge, ceremony, userUID) {
+		return ErrWebAuthnChallengeInvalid
+	}
+	return nil
+}
+
+// verifyAuthData 校验 RP ID 哈希与用户在场/用户验证标志
+func (s *WebAuthnService) verifyAuthData(authData *utils.WebAuthnAuthData) error {
+	expected := sha256.Sum256([]byte(s.rpID))
+	if !bytes.Equal(authData.RPIDHash, expected[:]) {
+		return ErrWebAuthnRPIDMismatch
+	}
+	if !authData.HasFlag(utils.WebAuthnFlagUserPresent) || !authData.HasFlag(utils.WebAuthnFlagUserVerified) {
+		return ErrWebAuthnUserNotVerified
+	}
+	return nil
+}
+
+func (s *WebAuthnService) newChallenge(ctx context.Context, ceremony, userUID string) (string, error) {
+	raw := make([]byte, webAuthnChallengeSize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", utils.LogError("WEBAUTHN", "newChallenge", err)
+	}
+	challenge := utils.EncodeWebAuthnBase64(raw)
+
+	payload, err := json.Marshal(&webAuthnChallenge{Ceremony: ceremony, UserUID: userUID})
+	if err != nil {
+		return "", utils.LogError("WEBAUTHN", "newChallenge", err)
+	}
+	if err := s.store.Save(ctx, flowKindWebAuthnChallenge, challenge, payload, defaultWebAuthnChallengeTTL); err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// consumeChallenge 原子地取出并删除挑战（过期由存储的 TTL 判定），仪式类型或绑定用户不符时同样作废
+func (s *WebAuthnService) consumeChallenge(ctx context.Context, challenge, ceremony, userUID string) bool {
+	if challenge == "" {
+		return false
+	}
+
+	payload, err := s.store.GetAndDelete(ctx, flowKindWebAuthnChallenge, challenge)
+	if err != nil {
+		if !errors.Is(err, models.ErrOAuthFlowStateNotFound) {
+			utils.LogWarn("WEBAUTHN", "Failed to load challenge", "error", err)
+		}
+		return false
+	}
+
+	entry := &webAuthnChallenge{}
+	if err := json.Unmarshal(payload, entry); err != nil {
+		return false
+	}
+	return entry.Ceremony == ceremony && entry.UserUID == userUID
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"auth-system/internal/models"
+	"auth-system/internal/utils"
+)
+
+const webAuthnTestOrigin = "https://id.example.com"
+
+// testAuthenticator 模拟持有 P-256 密钥的认证器，按 WebAuthn 规范构造注册与断言数据
+type testAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	signCount    uint32
+}
+
+func newTestAuthenticator(t *testing.T) *testAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	return &testAuthenticator{key: key, credentialID: []byte("test-credential-id")}
+}
+
+// cborHead 编码 CBOR 数据项头部（测试仅需长度 < 65536）
+func cborHead(major byte, n int) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 256:
+		return []byte{major<<5 | 24, byte(n)}
+	default:
+		return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+	}
+}
+
+func cborInt(v int64) []byte {
+	if v >= 0 {
+		return cborHead(0, int(v))
+	}
+	return cborHead(1, int(-1-v))
+}
+
+func cborBytes(b []byte) []byte { return append(cborHead(2, len(b)), b...) }
+func cborText(s string) []byte  { return append(cborHead(3, len(s)), s...) }
+
+func (a *testAuthenticator) coseKey() []byte {
+	pub, _ := a.key.PublicKey.Bytes()
+	out := cborHead(5, 5)
+	out = append(out, cborInt(1)...)
+	out = append(out, cborInt(2)...) // kty: EC2
+	out = append(out, cborInt(3)...)
+	out = append(out, cborInt(utils.COSEAlgES256)...)
+	out = append(out, cborInt(-1)...)
+	out = append(out, cborInt(1)...) // crv: P-256
+	out = append(out, cborInt(-2)...)
+	out = append(out, cborBytes(pub[1:33])...)
+	out = append(out, cborInt(-3)...)
+	out = append(out, cborBytes(pub[33:65])...)
+	return out
+}
+
+func (a *testAuthenticator) authData(rpID string, flags byte, attested bool) []byte {
+	rpHash := sha256.Sum256([]byte(rpID))
+	out := append([]byte{}, rpHash[:]...)
+	if attested {
+		flags |= utils.WebAuthnFlagAttestedCredData
+	}
+	out = append(out, flags)
+	out = binary.BigEndian.AppendUint32(out, a.signCount)
+	if attested {
+		out = append(out, make([]byte, 16)...) // AAGUID
+		out = binary.BigEndian.AppendUint16(out, uint16(len(a.credentialID)))
+		out = append(out, a.credentialID...)
+		out = append(out, a.coseKey()...)
+	}
+	return out
+}
+
+func clientDataJSON(t *testing.T, ceremony, challenge, origin string) []byte {
+	t.Helper()
+	raw, err := json.Marshal(utils.WebAuthnClientData{Type: ceremony, Challenge: challenge, Origin: origin})
+	if err != nil {
+		t.Fatalf("marshal client data: %v", err)
+	}
+	return raw
+}
+
+func (a *testAuthenticator) register(t *testing.T, rpID, challenge, origin string) *WebAuthnRegistrationResponse {
+	t.Helper()
+	att := cborHead(5, 3)
+	att = append(att, cborText("fmt")...)
+	att = append(att, cborText("none")...)
+	att = append(att, cborText("attStmt")...)
+	att = append(att, cborHead(5, 0)...)
+	att = append(att, cborText("authData")...)
+	att = append(att, cborBytes(a.authData(rpID, utils.WebAuthnFlagUserPresent|utils.WebAuthnFlagUserVerified, true))...)
+
+	resp := &WebAuthnRegistrationResponse{Type: "public-key"}
+	resp.ID = utils.EncodeWebAuthnBase64(a.credentialID)
+	resp.RawID = resp.ID
+	resp.Response.ClientDataJSON = utils.EncodeWebAuthnBase64(clientDataJSON(t, webAuthnCeremonyCreate, challenge, origin))
+	resp.Response.AttestationObject = utils.EncodeWebAuthnBase64(att)
+	resp.Response.Transports = []string{"internal"}
+	return resp
+}
+
+func (a *testAuthenticator) assert(t *testing.T, rpID, challenge, userUID string) *WebAuthnAssertionResponse {
+	t.Helper()
+	a.signCount++
+	authData := a.authData(rpID, utils.WebAuthnFlagUserPresent|utils.WebAuthnFlagUserVerified, false)
+	cdj := clientDataJSON(t, webAuthnCeremonyGet, challenge, webAuthnTestOrigin)
+
+	clientHash := sha256.Sum256(cdj)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("SignASN1() error = %v", err)
+	}
+
+	resp := &WebAuthnAssertionResponse{Type: "public-key"}
+	resp.ID = utils.EncodeWebAuthnBase64(a.credentialID)
+	resp.RawID = resp.ID
+	resp.Response.ClientDataJSON = utils.EncodeWebAuthnBase64(cdj)
+	resp.Response.AuthenticatorData = utils.EncodeWebAuthnBase64(authData)
+	resp.Response.Signature = utils.EncodeWebAuthnBase64(sig)
+	resp.Response.UserHandle = utils.EncodeWebAuthnBase64([]byte(userUID))
+	return resp
+}
+
+func newWebAuthnForTest(t *testing.T) *WebAuthnService {
+	t.Helper()
+	store, err := NewMemoryFlowStateStore()
+	if err != nil {
+		t.Fatalf("NewMemoryFlowStateStore() error = %v", err)
+	}
+	svc, err := NewWebAuthnService(webAuthnTestOrigin+"/", "", "Nebula Test", store)
+	if err != nil {
+		t.Fatalf("NewWebAuthnService() error = %v", err)
+	}
+	return svc
+}
+
+// registerForTest 完成一次注册仪式，返回与入库后等价的凭据
+func registerForTest(t *testing.T, svc *WebAuthnService, auth *testAuthenticator, userUID string) *models.WebAuthnCredential {
+	t.Helper()
+	ctx := context.Background()
+	opts, err := svc.BeginRegistration(ctx, userUID, "alice@example.com", "alice", nil)
+	if err != nil {
+		t.Fatalf("BeginRegistration() error = %v", err)
+	}
+	newCred, err := svc.FinishRegistration(ctx, userUID, auth.register(t, opts.RP.ID, opts.Challenge, webAuthnTestOrigin))
+	if err != nil {
+		t.Fatalf("FinishRegistration() error = %v", err)
+	}
+	return &models.WebAuthnCredential{
+		ID:           1,
+		UserUID:      userUID,
+		CredentialID: newCred.CredentialID,
+		PublicKey:    newCred.PublicKey,
+		SignCount:    int64(newCred.SignCount),
+	}
+}
+
+func TestWebAuthnRegistrationAndLogin(t *testing.T) {
+	svc := newWebAuthnForTest(t)
+	auth := newTestAuthenticator(t)
+	ctx := context.Background()
+
+	cred := registerForTest(t, svc, auth, "u1")
+	if string(cred.CredentialID) != string(auth.credentialID) {
+		t.Errorf("CredentialID = %q, want %q", cred.CredentialID, auth.credentialID)
+	}
+
+	opts, err := svc.BeginLogin(ctx)
+	if err != nil {
+		t.Fatalf("BeginLogin() error = %v", err)
+	}
+	if opts.RPID != "id.example.com" {
+		t.Errorf("RPID = %s, want id.example.com", opts.RPID)
+	}
+
+	resp := auth.assert(t, opts.RPID, opts.Challenge, "u1")
+	signCount, err := svc.FinishLogin(ctx, resp, cred)
+	if err != nil {
+		t.Fatalf("FinishLogin() error = %v", err)
+	}
+	if signCount != 1 {
+		t.Errorf("signCount = %d, want 1", signCount)
+	}
+
+	// 挑战一次性使用，同一断言不可重放
+	if _, err := svc.FinishLogin(ctx, resp, cred); !errors.Is(err, ErrWebAuthnChallengeInvalid) {
+		t.Errorf("replayed assertion error = %v, want ErrWebAuthnChallengeInvalid", err)
+	}
+}
+
+func TestWebAuthnRegistrationRejectsWrongOriginAndUser(t *testing.T) {
+	svc := newWebAuthnForTest(t)
+	auth := newTestAuthenticator(t)
+	ctx := context.Background()
+
+	opts, _ := svc.BeginRegistration(ctx, "u1", "alice@example.com", "alice", nil)
+	if _, err := svc.FinishRegistration(ctx, "u1", auth.register(t, opts.RP.ID, opts.Challenge, "https://evil.example.com")); !errors.Is(err, ErrWebAuthnOriginMismatch) {
+		t.Errorf("wrong origin error = %v, want ErrWebAuthnOriginMismatch", err)
+	}
+
+	// 挑战绑定发起注册的用户，其他用户无法使用
+	opts, _ = svc.BeginRegistration(ctx, "u1", "alice@example.com", "alice", nil)
+	if _, err := svc.FinishRegistration(ctx, "u2", auth.register(t, opts.RP.ID, opts.Challenge, webAuthnTestOrigin)); !errors.Is(err, ErrWebAuthnChallengeInvalid) {
+		t.Errorf("foreign user error = %v, want ErrWebAuthnChallengeInvalid", err)
+	}
+
+	opts, _ = svc.BeginRegistration(ctx, "u1", "alice@example.com", "alice", nil)
+	if _, err := svc.FinishRegistration(ctx, "u1", auth.register(t, "other.example.com", opts.Challenge, webAuthnTestOrigin)); !errors.Is(err, ErrWebAuthnRPIDMismatch) {
+		t.Errorf("wrong rp id error = %v, want ErrWebAuthnRPIDMismatch", err)
+	}
+}
+
+func TestWebAuthnLoginRejectsBadSignatureAndCounterRollback(t *testing.T) {
+	svc := newWebAuthnForTest(t)
+	auth := newTestAuthenticator(t)
+	ctx := context.Background()
+	cred := registerForTest(t, svc, auth, "u1")
+
+	opts, _ := svc.BeginLogin(ctx)
+	resp := auth.assert(t, opts.RPID, opts.Challenge, "u1")
+	resp.Response.Signature = utils.EncodeWebAuthnBase64([]byte("not-a-signature"))
+	if _, err := svc.FinishLogin(ctx, resp, cred); !errors.Is(err, utils.ErrWebAuthnInvalidSignature) {
+		t.Errorf("bad signature error = %v, want ErrWebAuthnInvalidSignature", err)
+	}
+
+	opts, _ = svc.BeginLogin(ctx)
+	if _, err := svc.FinishLogin(ctx, auth.assert(t, opts.RPID, opts.Challenge, "u2"), cred); !errors.Is(err, ErrWebAuthnUserMismatch) {
+		t.Errorf("user handle error = %v, want ErrWebAuthnUserMismatch", err)
+	}
+
+	// 存储的计数已超过认证器上报值：视为克隆
+	cred.SignCount = 100
+	opts, _ = svc.BeginLogin(ctx)
+	if _, err := svc.FinishLogin(ctx, auth.assert(t, opts.RPID, opts.Challenge, "u1"), cred); !errors.Is(err, ErrWebAuthnSignCount) {
+		t.Errorf("counter rollback error = %v, want ErrWebAuthnSignCount", err)
+	}
+}
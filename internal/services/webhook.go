@@ -0,0 +1,327 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"auth-system/internal/models"
+	"auth-system/internal/utils"
+)
+
+// 出站请求头：接收方用 X-Webhook-Signature 校验来源，用 X-Webhook-Id（事件 ID）去重
+const (
+	WebhookHeaderSignature = "X-Webhook-Signature"
+	WebhookHeaderEventID   = "X-Webhook-Id"
+	WebhookHeaderEventType = "X-Webhook-Event"
+	WebhookHeaderDelivery  = "X-Webhook-Delivery"
+)
+
+const (
+	webhookUserAgent         = "auth-system-webhook/1.0"
+	webhookSecretPrefix      = "whsec_"
+	webhookRequestTimeout    = 10 * time.Second
+	webhookDeliveryBatchSize = 20
+	webhookDeliveryLease     = 2 * time.Minute // 须大于 webhookRequestTimeout，避免投递中的记录被重复领取
+	webhookMaxAttempts       = 8
+	webhookRetryBaseDelay    = 30 * time.Second
+	webhookRetryMaxDelay     = 6 * time.Hour
+	webhookDeliveryRetention = 30 * 24 * time.Hour
+	webhookMaxErrorLength    = 500
+	webhookMaxResponseRead   = 1024
+)
+
+// ErrWebhookBlockedAddress 端点解析到本机或内网地址，拒绝投递
+var ErrWebhookBlockedAddress = errors.New("webhook endpoint resolves to a blocked address")
+
+// WebhookEvent 投递给端点的 JSON 请求体
+type WebhookEvent struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// WebhookService 出站 Webhook 服务：发布事件写入持久化投递队列，后台任务领取后签名投递，
+// 失败按指数退避重试，超过最大次数标记为 failed。投递语义为至少一次，接收方应按事件 ID 去重
+type WebhookService struct {
+	repo   models.WebhookStore
+	client *http.Client
+}
+
+// NewWebhookService 创建 Webhook 服务
+func NewWebhookService(repo models.WebhookStore) *WebhookService {
+	return &WebhookService{
+		repo:   repo,
+		client: newWebhookHTTPClient(webhookDialControl),
+	}
+}
+
+// newWebhookHTTPClient 创建投递用 HTTP 客户端，control 在建立每个连接前检查目标地址（nil 表示不检查）
+func newWebhookHTTPClient(control func(network, address string, conn syscall.RawConn) error) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// 不走环境变量代理：经代理连接时 control 只能看到代理地址，内网检查失效
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout:   webhookRequestTimeout,
+		KeepAlive: 30 * time.Second,
+		Control:   control,
+	}).DialContext
+
+	return &http.Client{
+		Timeout:   webhookRequestTimeout,
+		Transport: transport,
+		// 不跟随重定向：3xx 视为投递失败，避免被引导到未配置的地址
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// webhookDialControl 拒绝连接本机、内网与链路本地地址（如 169.254.169.254 元数据服务）。
+// 在 DNS 解析之后、建立连接之前检查实际 IP，域名解析到内网或 DNS rebinding 均无法绕过
+func webhookDialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || utils.IsBlockedIP(ip) {
+		return ErrWebhookBlockedAddress
+	}
+	return nil
+}
+
+// Publish 发布事件：为每个订阅了 eventType 的已启用端点写入一条待投递记录
+func (s *WebhookService) Publish(ctx context.Context, eventType string, data any) error {
+	eventID, err := generateWebhookToken("evt_", 16)
+	if err != nil {
+		return utils.LogError("WEBHOOK", "Publish", fmt.Errorf("generate event id: %w", err))
+	}
+
+	payload, err := json.Marshal(WebhookEvent{
+		ID:        eventID,
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return utils.LogError("WEBHOOK", "Publish", fmt.Errorf("marshal payload: %w", err), "event_type", eventType)
+	}
+
+	count, err := s.repo.Enqueue(ctx, eventID, eventType, payload)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		utils.LogInfoCtx(ctx, "WEBHOOK", "Event published", "event_id", eventID, "event_type", eventType, "deliveries", count)
+	}
+	return nil
+}
+
+// ListEndpoints 获取全部端点
+func (s *WebhookService) ListEndpoints(ctx context.Context) ([]*models.WebhookEndpoint, error) {
+	return s.repo.FindAll(ctx)
+}
+
+// GetEndpoint 获取端点
+func (s *WebhookService) GetEndpoint(ctx context.Context, id int64) (*models.WebhookEndpoint, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+// CreateEndpoint 生成签名密钥并创建端点，返回密钥明文（仅此一次）
+func (s *WebhookService) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) (string, error) {
+	secret, err := generateWebhookToken(webhookSecretPrefix, 32)
+	if err != nil {
+		return "", fmt.Errorf("generate webhook secret: %w", err)
+	}
+	endpoint.Secret = secret
+
+	if err := s.repo.Create(ctx, endpoint); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// UpdateEndpoint 更新端点
+func (s *WebhookService) UpdateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	return s.repo.Update(ctx, endpoint)
+}
+
+// DeleteEndpoint 删除端点及其投递记录
+func (s *WebhookService) DeleteEndpoint(ctx context.Context, id int64) (*models.WebhookEndpoint, error) {
+	return s.repo.Delete(ctx, id)
+}
+
+// RegenerateSecret 重新生成签名密钥，返回新密钥明文
+func (s *WebhookService) RegenerateSecret(ctx context.Context, id int64) (string, error) {
+	secret, err := generateWebhookToken(webhookSecretPrefix, 32)
+	if err != nil {
+		return "", fmt.Errorf("generate webhook secret: %w", err)
+	}
+	if err := s.repo.UpdateSecret(ctx, id, secret); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// ListDeliveries 查询端点的投递日志
+func (s *WebhookService) ListDeliveries(ctx context.Context, endpointID int64, page, pageSize int) ([]*models.WebhookDelivery, int64, error) {
+	return s.repo.ListDeliveries(ctx, endpointID, page, pageSize)
+}
+
+// Redeliver 手动重新投递：以原事件 ID 与 payload 新建一条待投递记录。
+// 投递记录不属于 endpointID 时返回 ErrWebhookDeliveryNotFound，端点已禁用时返回 ErrWebhookDisabled
+func (s *WebhookService) Redeliver(ctx context.Context, endpointID, deliveryID int64) (*models.WebhookDelivery, error) {
+	delivery, err := s.repo.FindDeliveryByID(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.EndpointID != endpointID {
+		return nil, models.ErrWebhookDeliveryNotFound
+	}
+
+	endpoint, err := s.repo.FindByID(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	if !endpoint.IsEnabled {
+		return nil, models.ErrWebhookDisabled
+	}
+
+	return s.repo.Redeliver(ctx, deliveryID)
+}
+
+// DeliverDue 领取一批到期的投递并发送，返回本批处理的条数
+func (s *WebhookService) DeliverDue(ctx context.Context) (int, error) {
+	deliveries, err := s.repo.ClaimDue(ctx, webhookDeliveryBatchSize, webhookDeliveryLease)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Go(func() {
+			attempt := s.deliver(ctx, delivery)
+			if err := s.repo.RecordAttempt(ctx, delivery.ID, attempt); err != nil {
+				utils.LogWarn("WEBHOOK", "Failed to record delivery attempt", "id", delivery.ID, "error", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+// CleanupDeliveries 删除超过保留期且已结束的投递记录
+func (s *WebhookService) CleanupDeliveries(ctx context.Context) (int64, error) {
+	return s.repo.DeleteFinishedBefore(ctx, time.Now().Add(-webhookDeliveryRetention))
+}
+
+// deliver 发送一次投递，2xx 视为成功；失败时按已尝试次数决定重试时间或放弃
+func (s *WebhookService) deliver(ctx context.Context, delivery *models.WebhookDelivery) *models.WebhookAttempt {
+	attempts := delivery.Attempts + 1
+
+	responseStatus, err := s.send(ctx, delivery)
+	if err == nil {
+		utils.LogInfo("WEBHOOK", "Delivery succeeded", "id", delivery.ID, "event_id", delivery.EventID, "status", *responseStatus)
+		return &models.WebhookAttempt{
+			Status:         models.WebhookDeliverySucceeded,
+			NextAttemptAt:  time.Now(),
+			ResponseStatus: responseStatus,
+		}
+	}
+
+	attempt := &models.WebhookAttempt{
+		Status:         models.WebhookDeliveryPending,
+		NextAttemptAt:  time.Now().Add(webhookRetryDelay(attempts)),
+		ResponseStatus: responseStatus,
+		Error:          truncateWebhookError(err.Error()),
+	}
+	if attempts >= webhookMaxAttempts {
+		attempt.Status = models.WebhookDeliveryFailed
+		attempt.NextAttemptAt = time.Now()
+		utils.LogWarn("WEBHOOK", "Delivery failed permanently", "id", delivery.ID, "event_id", delivery.EventID, "attempts", attempts, "error", err)
+	} else {
+		utils.LogWarn("WEBHOOK", "Delivery failed, will retry", "id", delivery.ID, "event_id", delivery.EventID, "attempts", attempts, "next_attempt_at", attempt.NextAttemptAt, "error", err)
+	}
+	return attempt
+}
+
+// send 签名并 POST payload；收到响应时返回其状态码（非 2xx 时同时返回错误）
+func (s *WebhookService) send(ctx context.Context, delivery *models.WebhookDelivery) (*int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", webhookUserAgent)
+	req.Header.Set(WebhookHeaderEventID, delivery.EventID)
+	req.Header.Set(WebhookHeaderEventType, delivery.EventType)
+	req.Header.Set(WebhookHeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(WebhookHeaderSignature, SignWebhookPayload(delivery.Secret, time.Now().Unix(), delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// 响应体只读取丢弃以便复用连接，不写入投递记录：否则可借投递详情读取目标的响应内容
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, webhookMaxResponseRead))
+	status := resp.StatusCode
+	if status < 200 || status >= 300 {
+		return &status, fmt.Errorf("unexpected status %d", status)
+	}
+	return &status, nil
+}
+
+// SignWebhookPayload 计算签名头的值 "t={unix 秒},v1={hex(HMAC-SHA256(secret, "{t}.{body}"))}"。
+// 接收方应使用原始请求体重新计算并做常量时间比较，同时拒绝时间戳偏差过大的请求以防重放
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	t := strconv.FormatInt(timestamp, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryDelay 第 attempts 次尝试失败后的等待时间：30s 起按 2 的幂递增，最长 6 小时
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := 1; i < attempts && delay < webhookRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookRetryMaxDelay)
+}
+
+func truncateWebhookError(msg string) string {
+	if len(msg) <= webhookMaxErrorLength {
+		return msg
+	}
+	return strings.ToValidUTF8(msg[:webhookMaxErrorLength], "")
+}
+
+// generateWebhookToken 生成 prefix + hex 随机串（事件 ID、签名密钥）
+func generateWebhookToken(prefix string, length int) (string, error) {
+	bytes := make([]byte, length)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(bytes), nil
+}
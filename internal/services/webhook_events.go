@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"auth-system/internal/models"
+	"auth-system/internal/utils"
+)
+
+// user.deleted 事件的删除发起方
+const (
+	WebhookDeletedBySelf  = "self"
+	WebhookDeletedByAdmin = "admin"
+)
+
+// WebhookUserData user.registered / user.unbanned 事件数据
+type WebhookUserData struct {
+	UserUID string `json:"user_uid"`
+}
+
+// WebhookUsernameChangedData user.username_changed 事件数据
+type WebhookUsernameChangedData struct {
+	UserUID     string `json:"user_uid"`
+	OldUsername string `json:"old_username"`
+	NewUsername string `json:"new_username"`
+}
+
+// WebhookEmailChangedData user.email_changed 事件数据
+type WebhookEmailChangedData struct {
+	UserUID  string `json:"user_uid"`
+	OldEmail string `json:"old_email"`
+	NewEmail string `json:"new_email"`
+}
+
+// WebhookUserBannedData user.banned 事件数据
+type WebhookUserBannedData struct {
+	UserUID string     `json:"user_uid"`
+	Reason  string     `json:"reason"`
+	UnbanAt *time.Time `json:"unban_at,omitempty"` // nil 表示永久封禁
+}
+
+// WebhookUserDeletedData user.deleted 事件数据
+type WebhookUserDeletedData struct {
+	UserUID   string `json:"user_uid"`
+	DeletedBy string `json:"deleted_by"` // self / admin
+}
+
+// WebhookGrantRevokedData oauth.grant_revoked 事件数据
+type WebhookGrantRevokedData struct {
+	UserUID    string `json:"user_uid"`
+	ClientID   string `json:"client_id"`
+	ClientName string `json:"client_name"`
+}
+
+// WebhookUserLogStore 在用户日志之上发布 Webhook 事件：账户生命周期事件与用户日志一一对应，
+// 包装 UserLogStore 后所有写日志的调用点自动发布，无需逐个 Handler 接入。
+// 事件已发生，故无论日志是否写入成功都会发布；发布失败只记录警告，不影响原调用
+type WebhookUserLogStore struct {
+	models.UserLogStore
+	publisher WebhookPublisher
+}
+
+// NewWebhookUserLogStore 包装用户日志仓库；publisher 为 nil 时原样返回
+func NewWebhookUserLogStore(store models.UserLogStore, publisher WebhookPublisher) models.UserLogStore {
+	if publisher == nil {
+		return store
+	}
+	return &WebhookUserLogStore{UserLogStore: store, publisher: publisher}
+}
+
+// LogRegister 记录注册并发布 user.registered
+func (s *WebhookUserLogStore) LogRegister(ctx context.Context, userUID string) error {
+	err := s.UserLogStore.LogRegister(ctx, userUID)
+	s.publish(ctx, models.WebhookEventUserRegistered, WebhookUserData{UserUID: userUID})
+	return err
+}
+
+// LogChangeUsername 记录修改用户名并发布 user.username_changed
+func (s *WebhookUserLogStore) LogChangeUsername(ctx context.Context, userUID string, oldUsername, newUsername string) error {
+	err := s.UserLogStore.LogChangeUsername(ctx, userUID, oldUsername, newUsername)
+	s.publish(ctx, models.WebhookEventUserUsernameChanged, WebhookUsernameChangedData{
+		UserUID:     userUID,
+		OldUsername: oldUsername,
+		NewUsername: newUsername,
+	})
+	return err
+}
+
+// LogChangeEmail 记录修改邮箱并发布 user.email_changed
+func (s *WebhookUserLogStore) LogChangeEmail(ctx context.Context, userUID string, oldEmail, newEmail string) error {
+	err := s.UserLogStore.LogChangeEmail(ctx, userUID, oldEmail, newEmail)
+	s.publish(ctx, models.WebhookEventUserEmailChanged, WebhookEmailChangedData{
+		UserUID:  userUID,
+		OldEmail: oldEmail,
+		NewEmail: newEmail,
+	})
+	return err
+}
+
+// LogBanned 记录被封禁并发布 user.banned
+func (s *WebhookUserLogStore) LogBanned(ctx context.Context, userUID string, reason string, unbanAt *time.Time) error {
+	err := s.UserLogStore.LogBanned(ctx, userUID, reason, unbanAt)
+	s.publish(ctx, models.WebhookEventUserBanned, WebhookUserBannedData{UserUID: userUID, Reason: reason, UnbanAt: unbanAt})
+	return err
+}
+
+// LogUnbanned 记录被解封并发布 user.unbanned
+func (s *WebhookUserLogStore) LogUnbanned(ctx context.Context, userUID string) error {
+	err := s.UserLogStore.LogUnbanned(ctx, userUID)
+	s.publish(ctx, models.WebhookEventUserUnbanned, WebhookUserData{UserUID: userUID})
+	return err
+}
+
+// LogDeleteAccount 记录用户自行注销并发布 user.deleted
+func (s *WebhookUserLogStore) LogDeleteAccount(ctx context.Context, userUID string) error {
+	err := s.UserLogStore.LogDeleteAccount(ctx, userUID)
+	s.publish(ctx, models.WebhookEventUserDeleted, WebhookUserDeletedData{UserUID: userUID, DeletedBy: WebhookDeletedBySelf})
+	return err
+}
+
+// LogOAuthRevoke 记录撤销 OAuth 授权并发布 oauth.grant_revoked
+func (s *WebhookUserLogStore) LogOAuthRevoke(ctx context.Context, userUID string, clientID, clientName string) error {
+	err := s.UserLogStore.LogOAuthRevoke(ctx, userUID, clientID, clientName)
+	s.publish(ctx, models.WebhookEventOAuthGrantRevoked, WebhookGrantRevokedData{
+		UserUID:    userUID,
+		ClientID:   clientID,
+		ClientName: clientName,
+	})
+	return err
+}
+
+func (s *WebhookUserLogStore) publish(ctx context.Context, eventType string, data any) {
+	if err := s.publisher.Publish(ctx, eventType, data); err != nil {
+		utils.LogWarnCtx(ctx, "WEBHOOK", "Failed to publish event", "event_type", eventType, "error", err)
+	}
+}
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"auth-system/internal/models"
+)
+
+// fakeWebhookStore 本地 Webhook 仓库 fake（services 测试不能 import testutil：会循环依赖）
+type fakeWebhookStore struct {
+	models.WebhookStore
+	endpoints map[int64]*models.WebhookEndpoint
+	due       []*models.WebhookDelivery
+	attempts  map[int64]*models.WebhookAttempt
+	enqueued  []json.RawMessage
+	requeued  []int64
+}
+
+func newFakeWebhookStore() *fakeWebhookStore {
+	return &fakeWebhookStore{endpoints: map[int64]*models.WebhookEndpoint{}, attempts: map[int64]*models.WebhookAttempt{}}
+}
+
+func (f *fakeWebhookStore) Enqueue(_ context.Context, _, _ string, payload json.RawMessage) (int64, error) {
+	f.enqueued = append(f.enqueued, payload)
+	return 1, nil
+}
+
+func (f *fakeWebhookStore) ClaimDue(context.Context, int, time.Duration) ([]*models.WebhookDelivery, error) {
+	due := f.due
+	f.due = nil
+	return due, nil
+}
+
+func (f *fakeWebhookStore) RecordAttempt(_ context.Context, id int64, attempt *models.WebhookAttempt) error {
+	f.attempts[id] = attempt
+	return nil
+}
+
+func (f *fakeWebhookStore) FindByID(_ context.Context, id int64) (*models.WebhookEndpoint, error) {
+	if endpoint, ok := f.endpoints[id]; ok {
+		return endpoint, nil
+	}
+	return nil, models.ErrWebhookNotFound
+}
+
+func (f *fakeWebhookStore) FindDeliveryByID(_ context.Context, id int64) (*models.WebhookDelivery, error) {
+	if id == 100 {
+		return &models.WebhookDelivery{ID: 100, EndpointID: 1}, nil
+	}
+	return nil, models.ErrWebhookDeliveryNotFound
+}
+
+func (f *fakeWebhookStore) Redeliver(_ context.Context, id int64) (*models.WebhookDelivery, error) {
+	f.requeued = append(f.requeued, id)
+	return &models.WebhookDelivery{ID: 101, EndpointID: 1, Status: models.WebhookDeliveryPending}, nil
+}
+
+type recordingPublisher struct {
+	events []string
+	data   []any
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, eventType string, data any) error {
+	p.events = append(p.events, eventType)
+	p.data = append(p.data, data)
+	return nil
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	body := []byte(`{"id":"evt_1"}`)
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "t=1700000000,v1=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := SignWebhookPayload("whsec_test", 1700000000, body); got != want {
+		t.Errorf("SignWebhookPayload = %q, want %q", got, want)
+	}
+	if SignWebhookPayload("other", 1700000000, body) == want {
+		t.Error("signature should depend on secret")
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{20, webhookRetryMaxDelay},
+	}
+	for _, tt := range tests {
+		if got := webhookRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("webhookRetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookPublishPayload(t *testing.T) {
+	store := newFakeWebhookStore()
+	svc := NewWebhookService(store)
+
+	if err := svc.Publish(context.Background(), models.WebhookEventUserBanned, WebhookUserBannedData{UserUID: "u1", Reason: "spam"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if len(store.enqueued) != 1 {
+		t.Fatalf("enqueued = %d, want 1", len(store.enqueued))
+	}
+
+	var event struct {
+		ID   string                `json:"id"`
+		Type string                `json:"type"`
+		Data WebhookUserBannedData `json:"data"`
+	}
+	if err := json.Unmarshal(store.enqueued[0], &event); err != nil {
+		t.Fatalf("unmarshal payload: %v", err)
+	}
+	if !strings.HasPrefix(event.ID, "evt_") || event.Type != models.WebhookEventUserBanned || event.Data.UserUID != "u1" || event.Data.Reason != "spam" {
+		t.Errorf("payload = %s", store.enqueued[0])
+	}
+}
+
+func TestWebhookDeliverDue(t *testing.T) {
+	var gotSignature, gotEvent string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			gotSignature = r.Header.Get(WebhookHeaderSignature)
+			gotEvent = r.Header.Get(WebhookHeaderEventType)
+			gotBody, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		case "/redirect":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		default:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	payload := json.RawMessage(`{"id":"evt_1","type":"user.registered"}`)
+	store := newFakeWebhookStore()
+	store.due = []*models.WebhookDelivery{
+		{ID: 1, EventID: "evt_1", EventType: models.WebhookEventUserRegistered, Payload: payload, URL: server.URL + "/ok", Secret: "s1"},
+		{ID: 2, EventID: "evt_1", Payload: payload, URL: server.URL + "/fail", Secret: "s1", Attempts: 2},
+		{ID: 3, EventID: "evt_1", Payload: payload, URL: server.URL + "/fail", Secret: "s1", Attempts: webhookMaxAttempts - 1},
+		{ID: 4, EventID: "evt_1", Payload: payload, URL: server.URL + "/redirect", Secret: "s1"},
+	}
+
+	svc := NewWebhookService(store)
+	svc.client = newWebhookHTTPClient(nil) // httptest 服务监听在回环地址
+	start := time.Now()
+	n, err := svc.DeliverDue(context.Background())
+	if err != nil || n != 4 {
+		t.Fatalf("DeliverDue = %d, %v", n, err)
+	}
+
+	ok := store.attempts[1]
+	if ok.Status != models.WebhookDeliverySucceeded || ok.ResponseStatus == nil || *ok.ResponseStatus != http.StatusNoContent {
+		t.Errorf("delivery 1 = %+v", ok)
+	}
+	if gotEvent != models.WebhookEventUserRegistered || string(gotBody) != string(payload) {
+		t.Errorf("request event = %q body = %s", gotEvent, gotBody)
+	}
+	ts := strings.TrimPrefix(strings.Split(gotSignature, ",")[0], "t=")
+	mac := hmac.New(sha256.New, []byte("s1"))
+	mac.Write([]byte(ts + "." + string(payload)))
+	if !strings.HasSuffix(gotSignature, ",v1="+hex.EncodeToString(mac.Sum(nil))) {
+		t.Errorf("signature %q does not verify", gotSignature)
+	}
+
+	retry := store.attempts[2]
+	// 只记录状态码，不记录响应体
+	if retry.Status != models.WebhookDeliveryPending || *retry.ResponseStatus != http.StatusInternalServerError || strings.Contains(retry.Error, "boom") {
+		t.Errorf("delivery 2 = %+v", retry)
+	}
+	if wait := retry.NextAttemptAt.Sub(start); wait < webhookRetryDelay(3) || wait > webhookRetryDelay(3)+time.Minute {
+		t.Errorf("delivery 2 retry in %v, want ~%v", wait, webhookRetryDelay(3))
+	}
+
+	if store.attempts[3].Status != models.WebhookDeliveryFailed {
+		t.Errorf("delivery 3 status = %q, want failed after max attempts", store.attempts[3].Status)
+	}
+
+	redirect := store.attempts[4]
+	if redirect.Status != models.WebhookDeliveryPending || *redirect.ResponseStatus != http.StatusFound {
+		t.Errorf("redirect should not be followed: %+v", redirect)
+	}
+}
+
+func TestWebhookDeliverBlocksInternalAddresses(t *testing.T) {
+	var hit bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+	}))
+	defer server.Close()
+
+	store := newFakeWebhookStore()
+	store.due = []*models.WebhookDelivery{
+		{ID: 1, EventID: "evt_1", Payload: json.RawMessage(`{}`), URL: server.URL + "/hooks", Secret: "s1"},
+	}
+
+	svc := NewWebhookService(store)
+	if _, err := svc.DeliverDue(context.Background()); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	attempt := store.attempts[1]
+	if hit || attempt.ResponseStatus != nil || !strings.Contains(attempt.Error, ErrWebhookBlockedAddress.Error()) {
+		t.Errorf("loopback delivery should be refused before connecting: hit = %v attempt = %+v", hit, attempt)
+	}
+
+	for _, addr := range []string{"127.0.0.1:80", "10.1.2.3:443", "192.168.0.1:80", "169.254.169.254:80", "[::1]:443", "0.0.0.0:80"} {
+		if err := webhookDialControl("tcp", addr, nil); !errors.Is(err, ErrWebhookBlockedAddress) {
+			t.Errorf("webhookDialControl(%s) = %v, want ErrWebhookBlockedAddress", addr, err)
+		}
+	}
+	if err := webhookDialControl("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("public address should be allowed: %v", err)
+	}
+}
+
+func TestWebhookRedeliver(t *testing.T) {
+	store := newFakeWebhookStore()
+	store.endpoints[1] = &models.WebhookEndpoint{ID: 1, IsEnabled: true}
+	svc := NewWebhookService(store)
+	ctx := context.Background()
+
+	if _, err := svc.Redeliver(ctx, 2, 100); !errors.Is(err, models.ErrWebhookDeliveryNotFound) {
+		t.Errorf("redeliver under other endpoint: err = %v", err)
+	}
+
+	store.endpoints[1].IsEnabled = false
+	if _, err := svc.Redeliver(ctx, 1, 100); !errors.Is(err, models.ErrWebhookDisabled) {
+		t.Errorf("redeliver to disabled endpoint: err = %v", err)
+	}
+
+	store.endpoints[1].IsEnabled = true
+	delivery, err := svc.Redeliver(ctx, 1, 100)
+	if err != nil || delivery.ID != 101 || len(store.requeued) != 1 || store.requeued[0] != 100 {
+		t.Errorf("Redeliver = %+v, %v (requeued %v)", delivery, err, store.requeued)
+	}
+}
+
+// noopUserLogStore 只实现被包装的方法，其余方法不会被调用
+type noopUserLogStore struct {
+	models.UserLogStore
+	err error
+}
+
+func (s noopUserLogStore) LogRegister(context.Context, string) error { return s.err }
+func (s noopUserLogStore) LogChangeUsername(context.Context, string, string, string) error {
+	return s.err
+}
+func (s noopUserLogStore) LogChangeEmail(context.Context, string, string, string) error {
+	return s.err
+}
+func (s noopUserLogStore) LogDeleteAccount(context.Context, string) error  { return s.err }
+func (s noopUserLogStore) LogChangePassword(context.Context, string) error { return s.err }
+func (s noopUserLogStore) LogBanned(context.Context, string, string, *time.Time) error {
+	return s.err
+}
+func (s noopUserLogStore) LogRegisterPasskey(context.Context, string, int64, string) error {
+	return s.err
+}
+func (s noopUserLogStore) LogOAuthAuthorize(context.Context, string, string, string, string) error {
+	return s.err
+}
+
+func TestWebhookUserLogStore(t *testing.T) {
+	logErr := errors.New("db down")
+	publisher := &recordingPublisher{}
+	store := NewWebhookUserLogStore(noopUserLogStore{err: logErr}, publisher)
+	ctx := context.Background()
+
+	if err := store.LogRegister(ctx, "u1"); !errors.Is(err, logErr) {
+		t.Errorf("LogRegister err = %v, want log error passed through", err)
+	}
+	_ = store.LogChangeUsername(ctx, "u1", "old", "new")
+	_ = store.LogChangeEmail(ctx, "u1", "old@example.com", "new@example.com")
+	_ = store.LogDeleteAccount(ctx, "u1")
+
+	want := []string{models.WebhookEventUserRegistered, models.WebhookEventUserUsernameChanged, models.WebhookEventUserEmailChanged, models.WebhookEventUserDeleted}
+	if strings.Join(publisher.events, ",") != strings.Join(want, ",") {
+		t.Fatalf("published = %v, want %v (日志写入失败也应发布)", publisher.events, want)
+	}
+	if data := publisher.data[2].(WebhookEmailChangedData); data.NewEmail != "new@example.com" {
+		t.Errorf("email changed data = %+v", data)
+	}
+	if data := publisher.data[3].(WebhookUserDeletedData); data.DeletedBy != WebhookDeletedBySelf {
+		t.Errorf("deleted data = %+v", data)
+	}
+
+	if plain := NewWebhookUserLogStore(noopUserLogStore{}, nil); plain != (noopUserLogStore{}) {
+		t.Error("nil publisher should return the store unwrapped")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"auth-system/internal/models"
 	"auth-system/internal/utils"
 	"context"
+	"crypto/rand"
 	"encoding/json"
 	"errors"
 	"hash/maphash"
@@ -38,6 +39,18 @@ const (
 	sendBufferSize    = 256
 	readBufferSize    = 1024
 	writeBufferSize   = 1024
+
+	// 跨实例广播：实例每 wsPresenceInterval 广播一次本地连接数，
+	// 超过 wsPeerTTL 未收到心跳的实例视为已下线，不计入集群统计
+	wsPresenceInterval     = 15 * time.Second
+	wsPeerTTL              = 3 * wsPresenceInterval
+	wsBrokerPublishTimeout = 2 * time.Second
+)
+
+// 跨实例广播的消息类型
+const (
+	wsEnvelopeStatus   = "status"
+	wsEnvelopePresence = "presence"
 )
 
 // WSClient WebSocket 客户端
@@ -67,6 +80,27 @@ type WebSocketService struct {
 	upgrader       websocket.Upgrader
 	qrLoginRepo    models.QRLoginStore
 	tokenDecrypter func(string) (string, error) // 解密加密 token 为原始 token，用于 DB 校验
+
+	broker     WSBroker // 跨实例广播，nil 表示单实例
+	instanceID string
+	peers      map[string]wsPeer // 其他实例的最近心跳
+	peersMu    sync.Mutex
+}
+
+// wsPeer 其他实例的心跳信息
+type wsPeer struct {
+	connections int
+	seenAt      time.Time
+}
+
+// wsBrokerEnvelope 跨实例广播的消息
+type wsBrokerEnvelope struct {
+	Kind        string          `json:"kind"`
+	Origin      string          `json:"origin"`
+	Token       string          `json:"token,omitempty"`
+	Message     json.RawMessage `json:"message,omitempty"`
+	Connections int             `json:"connections,omitempty"`
+	Leaving     bool            `json:"leaving,omitempty"`
 }
 
 // WSMessage WebSocket 消息
@@ -77,7 +111,8 @@ type WSMessage struct {
 }
 
 // NewWebSocketService 创建 WebSocket 服务
-func NewWebSocketService(cfg *config.Config, qrLoginRepo models.QRLoginStore) *WebSocketService {
+// broker 为 nil 时状态变更只推送给本实例的连接（单实例部署）
+func NewWebSocketService(cfg *config.Config, qrLoginRepo models.QRLoginStore, broker WSBroker) *WebSocketService {
 	ws := &WebSocketService{
 		shutdown: make(chan struct{}),
 		upgrader: websocket.Upgrader{
@@ -112,6 +147,9 @@ func NewWebSocketService(cfg *config.Config, qrLoginRepo models.QRLoginStore) *W
 			},
 		},
 		qrLoginRepo: qrLoginRepo,
+		broker:      broker,
+		instanceID:  rand.Text(),
+		peers:       make(map[string]wsPeer),
 	}
 
 	for i := range wsShardCount {
@@ -123,7 +161,13 @@ func NewWebSocketService(cfg *config.Config, qrLoginRepo models.QRLoginStore) *W
 	ws.wg.Add(1)
 	go ws.cleanup()
 
-	utils.LogInfo("WS", "WebSocket service initialized", "shards", wsShardCount, "max_connections", maxConnections)
+	if broker != nil {
+		broker.Subscribe(ws.handleBrokerMessage)
+		ws.wg.Add(1)
+		go ws.presence()
+	}
+
+	utils.LogInfo("WS", "WebSocket service initialized", "shards", wsShardCount, "max_connections", maxConnections, "fanout", broker != nil)
 
 	return ws
 }
@@ -230,6 +274,7 @@ func (ws *WebSocketService) HandleQRLogin(c *gin.Context) {
 }
 
 // NotifyStatusChange 通知状态变更
+// 连接不在本实例时经 broker 广播，由持有连接的实例推送
 func (ws *WebSocketService) NotifyStatusChange(token, status string, data map[string]string) {
 	if ws.IsShutdown() {
 		return
@@ -240,16 +285,6 @@ func (ws *WebSocketService) NotifyStatusChange(token, status string, data map[st
 		return
 	}
 
-	shard := ws.getShard(token)
-
-	shard.mu.RLock()
-	client, ok := shard.clients[token]
-	shard.mu.RUnlock()
-
-	if !ok {
-		return
-	}
-
 	message := map[string]any{
 		"type":   "status",
 		"status": status,
@@ -265,9 +300,111 @@ func (ws *WebSocketService) NotifyStatusChange(token, status string, data map[st
 		return
 	}
 
-	if err := ws.sendToClient(client, jsonData); err != nil {
+	if ws.deliverLocal(token, jsonData) || ws.broker == nil {
+		return
+	}
+
+	ws.publish(wsBrokerEnvelope{Kind: wsEnvelopeStatus, Token: token, Message: jsonData})
+}
+
+// deliverLocal 推送给本实例持有的连接，返回该 token 是否连接在本实例
+func (ws *WebSocketService) deliverLocal(token string, message []byte) bool {
+	shard := ws.getShard(token)
+
+	shard.mu.RLock()
+	client, ok := shard.clients[token]
+	shard.mu.RUnlock()
+
+	if !ok {
+		return false
+	}
+
+	if err := ws.sendToClient(client, message); err != nil {
 		utils.LogWarn("WS", "Failed to send message", "token", utils.TruncateIdentifier(token))
 	}
+	return true
+}
+
+// publish 经 broker 广播消息，失败只记录日志
+func (ws *WebSocketService) publish(envelope wsBrokerEnvelope) {
+	envelope.Origin = ws.instanceID
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		utils.LogError("WS", "BrokerPublish", err, "kind", envelope.Kind)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), wsBrokerPublishTimeout)
+	defer cancel()
+	_ = ws.broker.Publish(ctx, payload)
+}
+
+// handleBrokerMessage 处理其他实例的广播：状态消息推送给本地连接，心跳更新集群统计
+func (ws *WebSocketService) handleBrokerMessage(payload []byte) {
+	var envelope wsBrokerEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		utils.LogWarn("WS", "Invalid broker message", "error", err)
+		return
+	}
+	if envelope.Origin == ws.instanceID || ws.IsShutdown() {
+		return
+	}
+
+	switch envelope.Kind {
+	case wsEnvelopeStatus:
+		if envelope.Token != "" && len(envelope.Message) > 0 {
+			ws.deliverLocal(envelope.Token, envelope.Message)
+		}
+	case wsEnvelopePresence:
+		ws.peersMu.Lock()
+		if envelope.Leaving {
+			delete(ws.peers, envelope.Origin)
+		} else {
+			ws.peers[envelope.Origin] = wsPeer{connections: envelope.Connections, seenAt: time.Now()}
+		}
+		ws.peersMu.Unlock()
+	}
+}
+
+// presence 定期广播本实例连接数
+func (ws *WebSocketService) presence() {
+	defer ws.wg.Done()
+
+	ticker := time.NewTicker(wsPresenceInterval)
+	defer ticker.Stop()
+
+	ws.publishPresence(false)
+	for {
+		select {
+		case <-ws.shutdown:
+			return
+		case <-ticker.C:
+			ws.publishPresence(false)
+		}
+	}
+}
+
+func (ws *WebSocketService) publishPresence(leaving bool) {
+	ws.publish(wsBrokerEnvelope{Kind: wsEnvelopePresence, Connections: ws.GetConnectionCount(), Leaving: leaving})
+}
+
+// clusterStats 汇总未过期的其他实例心跳，返回集群连接数与实例数（含本实例）
+func (ws *WebSocketService) clusterStats() (connections, instances int) {
+	connections, instances = ws.GetConnectionCount(), 1
+
+	ws.peersMu.Lock()
+	defer ws.peersMu.Unlock()
+
+	now := time.Now()
+	for id, peer := range ws.peers {
+		if now.Sub(peer.seenAt) > wsPeerTTL {
+			delete(ws.peers, id)
+			continue
+		}
+		connections += peer.connections
+		instances++
+	}
+	return connections, instances
 }
 
 // GetConnectionCount 获取当前连接数
@@ -303,9 +440,15 @@ func (ws *WebSocketService) Shutdown(ctx context.Context) {
 
 		ws.closeAllConnections()
 
-		// 等待清理协程结束
+		// 等待清理与心跳协程结束
 		ws.wg.Wait()
 
+		// 通知其他实例本实例下线，再停止监听
+		if ws.broker != nil {
+			ws.publishPresence(true)
+			ws.broker.Close()
+		}
+
 		utils.LogInfo("WS", "WebSocket service shutdown complete")
 		close(done)
 	}()
@@ -318,12 +461,17 @@ func (ws *WebSocketService) Shutdown(ctx context.Context) {
 }
 
 // GetStats 获取服务统计信息
+// connectionCount / shardStats 为本实例数据；clusterConnectionCount / instanceCount 汇总所有在线实例
 func (ws *WebSocketService) GetStats() map[string]any {
+	clusterConnections, instances := ws.clusterStats()
 	stats := map[string]any{
-		"connectionCount": ws.GetConnectionCount(),
-		"maxConnections":  maxConnections,
-		"shardCount":      wsShardCount,
-		"isShutdown":      ws.IsShutdown(),
+		"connectionCount":        ws.GetConnectionCount(),
+		"clusterConnectionCount": clusterConnections,
+		"instanceCount":          instances,
+		"instanceId":             ws.instanceID,
+		"maxConnections":         maxConnections,
+		"shardCount":             wsShardCount,
+		"isShutdown":             ws.IsShutdown(),
 	}
 
 	shardStats := make([]int, wsShardCount)
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"auth-system/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WSBroker WebSocket 跨实例消息桥接
+//
+// 多实例部署时 PC 端的 WebSocket 与手机端的确认请求可能落在不同实例上，
+// 状态变更需经广播送达持有该连接的实例。Publish 的消息会投递给所有订阅者（包括发布者自身），
+// 订阅者按消息中的实例 ID 自行去重。
+type WSBroker interface {
+	Publish(ctx context.Context, payload []byte) error
+	// Subscribe 开始接收广播，handler 在单个后台协程中串行调用；只能调用一次
+	Subscribe(handler func(payload []byte))
+	// Close 停止接收并释放连接
+	Close()
+}
+
+const (
+	// wsNotifyChannel PostgreSQL NOTIFY 频道名
+	wsNotifyChannel = "qr_login_ws"
+	// pgListenRetryDelay LISTEN 连接断开后的重连间隔
+	pgListenRetryDelay = 3 * time.Second
+)
+
+// PGNotifyBroker 基于 PostgreSQL LISTEN/NOTIFY 的广播实现，复用现有连接池
+//
+// 订阅端从池中取出一条连接独占使用（Hijack，不再归还，避免残留 LISTEN 的连接被其他查询复用），
+// 断线后自动重连；重连期间的消息会丢失，前端轮询兜底。NOTIFY 负载上限约 8000 字节，状态消息远小于此。
+type PGNotifyBroker struct {
+	pool    *pgxpool.Pool
+	channel string
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewPGNotifyBroker 创建 PostgreSQL 广播
+func NewPGNotifyBroker(pool *pgxpool.Pool) *PGNotifyBroker {
+	return &PGNotifyBroker{pool: pool, channel: wsNotifyChannel}
+}
+
+// Publish 通过 pg_notify 广播消息（随事务提交送达，此处为自动提交）
+func (b *PGNotifyBroker) Publish(ctx context.Context, payload []byte) error {
+	if _, err := b.pool.Exec(ctx, `SELECT pg_notify($1, $2)`, b.channel, string(payload)); err != nil {
+		return utils.LogError("WS", "BrokerPublish", err, "channel", b.channel)
+	}
+	return nil
+}
+
+// Subscribe 启动监听协程
+func (b *PGNotifyBroker) Subscribe(handler func(payload []byte)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+	b.done = make(chan struct{})
+
+	go b.listen(ctx, handler)
+}
+
+// Close 停止监听并等待协程退出
+func (b *PGNotifyBroker) Close() {
+	if b.cancel == nil {
+		return
+	}
+	b.cancel()
+	<-b.done
+}
+
+// listen 监听循环，连接出错时按固定间隔重连
+func (b *PGNotifyBroker) listen(ctx context.Context, handler func(payload []byte)) {
+	defer close(b.done)
+
+	for {
+		err := b.listenOnce(ctx, handler)
+		if ctx.Err() != nil {
+			utils.LogInfo("WS", "Broker listener stopped", "channel", b.channel)
+			return
+		}
+		utils.LogError("WS", "BrokerListen", err, "channel", b.channel, "retry_in", pgListenRetryDelay.String())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pgListenRetryDelay):
+		}
+	}
+}
+
+// listenOnce 占用一条连接执行 LISTEN 并持续接收通知，直到出错或 ctx 取消
+func (b *PGNotifyBroker) listenOnce(ctx context.Context, handler func(payload []byte)) error {
+	pooled, err := b.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	conn := pooled.Hijack()
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = conn.Close(closeCtx)
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{b.channel}.Sanitize()); err != nil {
+		return err
+	}
+	utils.LogInfo("WS", "Broker listening", "channel", b.channel)
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		handler([]byte(notification.Payload))
+	}
+}
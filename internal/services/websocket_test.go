@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"auth-system/internal/config"
+)
+
+// fakeBrokerHub 进程内广播：Publish 同步投递给所有已订阅的 fakeBroker（含发布者自身）
+type fakeBrokerHub struct {
+	mu       sync.Mutex
+	handlers []func([]byte)
+}
+
+type fakeBroker struct{ hub *fakeBrokerHub }
+
+func (b *fakeBroker) Publish(_ context.Context, payload []byte) error {
+	b.hub.mu.Lock()
+	handlers := append([]func([]byte){}, b.hub.handlers...)
+	b.hub.mu.Unlock()
+	for _, h := range handlers {
+		h(payload)
+	}
+	return nil
+}
+
+func (b *fakeBroker) Subscribe(handler func([]byte)) {
+	b.hub.mu.Lock()
+	defer b.hub.mu.Unlock()
+	b.hub.handlers = append(b.hub.handlers, handler)
+}
+
+func (b *fakeBroker) Close() {}
+
+// attachFakeClient 在服务上登记一个没有底层连接的客户端，仅用于观察 send 通道
+func attachFakeClient(t *testing.T, ws *WebSocketService, token string) *WSClient {
+	t.Helper()
+	client := &WSClient{token: token, send: make(chan []byte, sendBufferSize), createdAt: time.Now()}
+	if !ws.register(client) {
+		t.Fatalf("register %s failed", token)
+	}
+	t.Cleanup(func() { ws.unregister(client) })
+	return client
+}
+
+func TestNotifyStatusChangeFansOutAcrossInstances(t *testing.T) {
+	hub := &fakeBrokerHub{}
+	cfg := &config.Config{}
+	pc := NewWebSocketService(cfg, nil, &fakeBroker{hub})
+	mobile := NewWebSocketService(cfg, nil, &fakeBroker{hub})
+
+	client := attachFakeClient(t, pc, "enc-token")
+
+	// 确认请求落在另一实例，经广播送达 PC 端连接所在实例
+	mobile.NotifyStatusChange("enc-token", "confirmed", map[string]string{"ticket": "t1"})
+
+	select {
+	case raw := <-client.send:
+		var msg map[string]string
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if msg["type"] != "status" || msg["status"] != "confirmed" || msg["ticket"] != "t1" {
+			t.Errorf("message = %v", msg)
+		}
+	default:
+		t.Fatal("remote instance did not deliver status message")
+	}
+
+	// 本地持有连接时直接推送，不经广播重复投递
+	pc.NotifyStatusChange("enc-token", "scanned", nil)
+	if got := len(client.send); got != 1 {
+		t.Errorf("local notify queued %d messages, want 1", got)
+	}
+}
+
+func TestGetStatsReportsClusterConnections(t *testing.T) {
+	hub := &fakeBrokerHub{}
+	cfg := &config.Config{}
+	a := NewWebSocketService(cfg, nil, &fakeBroker{hub})
+	b := NewWebSocketService(cfg, nil, &fakeBroker{hub})
+
+	attachFakeClient(t, a, "t1")
+	attachFakeClient(t, b, "t2")
+	attachFakeClient(t, b, "t3")
+	a.publishPresence(false)
+	b.publishPresence(false)
+
+	stats := a.GetStats()
+	if stats["connectionCount"] != 1 || stats["clusterConnectionCount"] != 3 || stats["instanceCount"] != 2 {
+		t.Errorf("stats = %v, want local 1, cluster 3, instances 2", stats)
+	}
+
+	// 心跳过期或收到下线通知后不再计入
+	a.peersMu.Lock()
+	for id, peer := range a.peers {
+		peer.seenAt = time.Now().Add(-wsPeerTTL - time.Second)
+		a.peers[id] = peer
+	}
+	a.peersMu.Unlock()
+	if stats := a.GetStats(); stats["clusterConnectionCount"] != 1 || stats["instanceCount"] != 1 {
+		t.Errorf("stale peer counted: %v", stats)
+	}
+
+	b.publishPresence(false)
+	b.publishPresence(true)
+	if stats := a.GetStats(); stats["instanceCount"] != 1 {
+		t.Errorf("leaving peer counted: %v", stats)
+	}
+}
@@ -6,6 +6,8 @@ package testutil
 import (
 	"context"
 	"database/sql"
+	"slices"
+	"strings"
 	"time"
 
 	"auth-system/internal/cache"
@@ -29,6 +31,8 @@ type FakeUserRepo struct {
 	BanCalls        []BannedUsers
 	UnbanCalls      []string
 	PasswordUpdates []string
+	TOTPDisabled    []string
+	UnlockTokens    map[string]string // 解锁 token 哈希 -> UID
 }
 
 // NewFakeUserRepo 创建空的内存用户仓库
@@ -76,10 +80,6 @@ func (f *FakeUserRepo) FindByEmailOrUsername(_ context.Context, identifier strin
 func (f *FakeUserRepo) FindByUsername(_ context.Context, username string) (*models.User, error) {
 	return f.Usernames[username], nil
 }
-func (f *FakeUserRepo) FindByMicrosoftID(context.Context, string) (*models.User, error) {
-	return nil, nil
-}
-func (f *FakeUserRepo) FindByGoogleID(context.Context, string) (*models.User, error) { return nil, nil }
 func (f *FakeUserRepo) Create(_ context.Context, user *models.User) error {
 	if f.CreateErr != nil {
 		return f.CreateErr
@@ -88,9 +88,32 @@ func (f *FakeUserRepo) Create(_ context.Context, user *models.User) error {
 	f.Seed(user)
 	return nil
 }
-func (f *FakeUserRepo) Update(context.Context, string, map[string]any) error { return nil }
+func (f *FakeUserRepo) Update(_ context.Context, uid string, updates map[string]any) error {
+	u := f.UIDs[uid]
+	if u == nil {
+		return &utils.DatabaseError{Operation: "Update", NotFound: true}
+	}
+	// 仅应用用户名、邮箱（同步索引）与重置密码标记（SCIM / 资料修改 / 登录提醒断言用）
+	if username, ok := updates["username"].(string); ok {
+		delete(f.Usernames, u.Username)
+		u.Username = username
+		f.Usernames[username] = u
+	}
+	if email, ok := updates["email"].(string); ok {
+		delete(f.Emails, u.Email)
+		u.Email = email
+		f.Emails[email] = u
+	}
+	if required, ok := updates["password_reset_required"].(bool); ok {
+		u.PasswordResetRequired = required
+	}
+	return nil
+}
 func (f *FakeUserRepo) UpdatePassword(_ context.Context, uid, plainPassword string) error {
 	f.PasswordUpdates = append(f.PasswordUpdates, uid)
+	if u := f.UIDs[uid]; u != nil {
+		u.PasswordResetRequired = false
+	}
 	return nil
 }
 func (f *FakeUserRepo) Delete(context.Context, string) error { return nil }
@@ -114,6 +137,16 @@ func (f *FakeUserRepo) FindAll(context.Context, int, int, string) ([]*models.Use
 	}
 	return users, int64(len(users)), nil
 }
+func (f *FakeUserRepo) FindRange(_ context.Context, offset, limit int) ([]*models.User, int64, error) {
+	users := make([]*models.User, 0, len(f.UIDs))
+	for _, u := range f.UIDs {
+		users = append(users, u)
+	}
+	slices.SortFunc(users, func(a, b *models.User) int { return strings.Compare(a.UID, b.UID) })
+	total := int64(len(users))
+	users = users[min(offset, len(users)):]
+	return users[:min(limit, len(users))], total, nil
+}
 func (f *FakeUserRepo) GetStats(context.Context) (*models.UserStats, error) {
 	return &models.UserStats{TotalUsers: int64(len(f.UIDs))}, nil
 }
@@ -121,6 +154,7 @@ func (f *FakeUserRepo) Ban(_ context.Context, userUID, adminUID string, reason s
 	f.BanCalls = append(f.BanCalls, BannedUsers{UserUID: userUID, AdminUID: adminUID, Reason: reason, UnbanAt: unbanAt})
 	if u := f.UIDs[userUID]; u != nil {
 		u.IsBanned = true
+		u.BanReason = sql.NullString{Valid: true, String: reason}
 		if unbanAt != nil {
 			u.UnbanAt = sql.NullTime{Valid: true, Time: *unbanAt}
 		}
@@ -131,10 +165,117 @@ func (f *FakeUserRepo) Unban(_ context.Context, userUID string) error {
 	f.UnbanCalls = append(f.UnbanCalls, userUID)
 	if u := f.UIDs[userUID]; u != nil {
 		u.IsBanned = false
+		u.BanReason = sql.NullString{}
 	}
 	return nil
 }
 
+// ---- UserTwoFactorStore（两步验证，直接修改 seed 的用户对象） ----
+
+var _ models.UserTwoFactorStore = (*FakeUserRepo)(nil)
+
+func (f *FakeUserRepo) SetPendingTOTPSecret(_ context.Context, uid, encryptedSecret string) error {
+	u := f.UIDs[uid]
+	if u == nil {
+		return &utils.DatabaseError{Operation: "SetPendingTOTPSecret", NotFound: true}
+	}
+	if u.TOTPEnabled {
+		return models.ErrTOTPAlreadyEnabled
+	}
+	u.TOTPSecret = sql.NullString{Valid: true, String: encryptedSecret}
+	return nil
+}
+func (f *FakeUserRepo) EnableTOTP(_ context.Context, uid string, hashes []string, step int64) error {
+	u := f.UIDs[uid]
+	if u == nil || u.TOTPEnabled || !u.TOTPSecret.Valid {
+		return models.ErrTOTPNotPending
+	}
+	u.TOTPEnabled = true
+	u.TOTPRecoveryCodes = hashes
+	u.TOTPLastStep = step
+	return nil
+}
+func (f *FakeUserRepo) DisableTOTP(_ context.Context, uid string) error {
+	f.TOTPDisabled = append(f.TOTPDisabled, uid)
+	if u := f.UIDs[uid]; u != nil {
+		u.TOTPEnabled = false
+		u.TOTPSecret = sql.NullString{}
+		u.TOTPRecoveryCodes = nil
+		u.TOTPLastStep = 0
+	}
+	return nil
+}
+func (f *FakeUserRepo) AdvanceTOTPStep(_ context.Context, uid string, step int64) (bool, error) {
+	u := f.UIDs[uid]
+	if u == nil || !u.TOTPEnabled || step <= u.TOTPLastStep {
+		return false, nil
+	}
+	u.TOTPLastStep = step
+	return true, nil
+}
+func (f *FakeUserRepo) ConsumeRecoveryCode(_ context.Context, uid, codeHash string) (int, bool, error) {
+	u := f.UIDs[uid]
+	if u == nil || !u.TOTPEnabled {
+		return 0, false, nil
+	}
+	for i, h := range u.TOTPRecoveryCodes {
+		if h == codeHash {
+			u.TOTPRecoveryCodes = append(u.TOTPRecoveryCodes[:i:i], u.TOTPRecoveryCodes[i+1:]...)
+			return len(u.TOTPRecoveryCodes), true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// ---- UserLoginLockStore（登录失败计数与锁定，直接修改 seed 的用户对象） ----
+
+var _ models.UserLoginLockStore = (*FakeUserRepo)(nil)
+
+func (f *FakeUserRepo) RecordLoginFailure(_ context.Context, uid string, windowStart time.Time) (int, error) {
+	u := f.UIDs[uid]
+	if u == nil {
+		return 0, &utils.DatabaseError{Operation: "RecordLoginFailure", NotFound: true}
+	}
+	if !u.LastFailedLoginAt.Valid || u.LastFailedLoginAt.Time.Before(windowStart) {
+		u.FailedLoginCount = 0
+	}
+	u.FailedLoginCount++
+	u.LastFailedLoginAt = sql.NullTime{Valid: true, Time: time.Now()}
+	return u.FailedLoginCount, nil
+}
+func (f *FakeUserRepo) LockLogin(_ context.Context, uid string, until time.Time, unlockTokenHash string) error {
+	u := f.UIDs[uid]
+	if u == nil {
+		return &utils.DatabaseError{Operation: "LockLogin", NotFound: true}
+	}
+	u.LockedUntil = sql.NullTime{Valid: true, Time: until}
+	if f.UnlockTokens == nil {
+		f.UnlockTokens = make(map[string]string)
+	}
+	f.UnlockTokens[unlockTokenHash] = uid
+	return nil
+}
+func (f *FakeUserRepo) ResetLoginFailures(_ context.Context, uid string) error {
+	if u := f.UIDs[uid]; u != nil {
+		u.FailedLoginCount = 0
+		u.LastFailedLoginAt = sql.NullTime{}
+		u.LockedUntil = sql.NullTime{}
+	}
+	for hash, owner := range f.UnlockTokens {
+		if owner == uid {
+			delete(f.UnlockTokens, hash)
+		}
+	}
+	return nil
+}
+func (f *FakeUserRepo) UnlockLogin(ctx context.Context, unlockTokenHash string) (string, error) {
+	uid, ok := f.UnlockTokens[unlockTokenHash]
+	if !ok || !f.UIDs[uid].IsLoginLocked(time.Now()) {
+		return "", models.ErrUnlockTokenNotFound
+	}
+	return uid, f.ResetLoginFailures(ctx, uid)
+}
+
 // ---------- FakeTokenManager: services.TokenManager ----------
 
 // FakeTokenManager 验证码管理器 fake，成功与否由 VerifyCodeErr 开关控制，其余参数不参与判定
@@ -185,17 +326,28 @@ func (f *FakeTokenManager) GetTokenExpiry() time.Duration  { return time.Hour }
 
 // ---------- FakeSessionManager: services.SessionManager ----------
 
+// FakeSessionManager 会话 fake：Sessions 为预置的设备会话（每个 token 家族一条）
 type FakeSessionManager struct {
 	GenerateErr  error
 	AccessToken  string
 	RefreshToken string
+	FamilyID     string
 	VerifyErr    error
 	VerifyResult *services.Claims
+	Sessions     []*models.SessionToken
+	// RevokedSIDs CheckSession 视为已撤销的会话 ID
+	RevokedSIDs []string
+	// RevokedUIDs 记录 RevokeUserTokens 撤销过全部会话的用户
+	RevokedUIDs []string
+
+	// 最近一次 GenerateImpersonationToken 的参数
+	ImpersonatedUID string
+	ImpersonatorUID string
 }
 
-func (f *FakeSessionManager) GenerateTokens(_ context.Context, _ string, _ bool) (string, string, error) {
+func (f *FakeSessionManager) GenerateTokens(_ context.Context, _ string, _ bool) (string, string, string, error) {
 	if f.GenerateErr != nil {
-		return "", "", f.GenerateErr
+		return "", "", "", f.GenerateErr
 	}
 	if f.AccessToken == "" {
 		f.AccessToken = "fake-access-token"
@@ -203,19 +355,253 @@ func (f *FakeSessionManager) GenerateTokens(_ context.Context, _ string, _ bool)
 	if f.RefreshToken == "" {
 		f.RefreshToken = "fake-refresh-token"
 	}
-	return f.AccessToken, f.RefreshToken, nil
+	if f.FamilyID == "" {
+		f.FamilyID = "fake-family-id"
+	}
+	return f.AccessToken, f.RefreshToken, f.FamilyID, nil
+}
+func (f *FakeSessionManager) GenerateImpersonationToken(_ context.Context, uid, impersonatorUID string) (string, time.Time, error) {
+	if f.GenerateErr != nil {
+		return "", time.Time{}, f.GenerateErr
+	}
+	f.ImpersonatedUID, f.ImpersonatorUID = uid, impersonatorUID
+	return "fake-impersonation-token", time.Now().Add(30 * time.Minute), nil
 }
 func (f *FakeSessionManager) RefreshTokens(context.Context, string) (string, string, error) {
 	return "", "", nil
 }
-func (f *FakeSessionManager) RevokeUserTokens(context.Context, string) error          { return nil }
-func (f *FakeSessionManager) RevokeTokenFamily(context.Context, string, string) error { return nil }
+func (f *FakeSessionManager) RevokeUserTokens(_ context.Context, uid string) error {
+	f.RevokedUIDs = append(f.RevokedUIDs, uid)
+	return nil
+}
+func (f *FakeSessionManager) RevokeTokenFamily(_ context.Context, uid, familyID string) error {
+	for i, t := range f.Sessions {
+		if t.UserUID == uid && t.FamilyID == familyID {
+			f.Sessions = append(f.Sessions[:i], f.Sessions[i+1:]...)
+			return nil
+		}
+	}
+	return services.ErrSessionNotFound
+}
+func (f *FakeSessionManager) ListSessions(_ context.Context, uid string) ([]*models.SessionToken, error) {
+	var out []*models.SessionToken
+	for _, t := range f.Sessions {
+		if t.UserUID == uid {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+func (f *FakeSessionManager) RevokeOtherSessions(_ context.Context, uid, keepFamilyID string) (int64, error) {
+	var kept []*models.SessionToken
+	for _, t := range f.Sessions {
+		if t.UserUID != uid || t.FamilyID == keepFamilyID {
+			kept = append(kept, t)
+		}
+	}
+	count := int64(len(f.Sessions) - len(kept))
+	f.Sessions = kept
+	return count, nil
+}
 func (f *FakeSessionManager) VerifyToken(string) (*services.Claims, error) {
 	if f.VerifyErr != nil {
 		return nil, f.VerifyErr
 	}
 	return f.VerifyResult, nil
 }
+func (f *FakeSessionManager) CheckSession(_ context.Context, claims *services.Claims) error {
+	if claims != nil && slices.Contains(f.RevokedSIDs, claims.SID) {
+		return services.ErrSessionRevoked
+	}
+	return nil
+}
+
+// ---------- FakeIDTokenSigner: services.IDTokenSigner ----------
+
+// FakeIDTokenSigner 记录签发的 ID Token 声明，返回固定 token；
+// VerifyIDToken 仅接受 Issued 中登记的 token
+type FakeIDTokenSigner struct {
+	SignErr error
+	Signed  []*services.IDTokenClaims
+	Issued  map[string]*services.IDTokenClaims
+}
+
+func (f *FakeIDTokenSigner) SignIDToken(claims *services.IDTokenClaims) (string, error) {
+	if f.SignErr != nil {
+		return "", f.SignErr
+	}
+	f.Signed = append(f.Signed, claims)
+	return "fake-id-token", nil
+}
+func (f *FakeIDTokenSigner) VerifyIDToken(token string) (*services.IDTokenClaims, error) {
+	if claims, ok := f.Issued[token]; ok {
+		return claims, nil
+	}
+	return nil, services.ErrInvalidIDToken
+}
+func (f *FakeIDTokenSigner) JWKS() *services.JWKSet {
+	return &services.JWKSet{Keys: []services.JWK{{Kty: "EC", Crv: "P-256", Use: "sig", Alg: "ES256", Kid: "fake-kid"}}}
+}
+
+// ---------- FakeTwoFactor: services.TwoFactorManager ----------
+
+// FakeTwoFactor 两步验证 fake：ValidCode 视为正确的 TOTP 码（命中时间步固定为 Step），
+// 恢复码哈希为 "hash:" + 原文，挑战以 Challenges 映射到用户 UID
+type FakeTwoFactor struct {
+	NotConfigured bool
+	ValidCode     string
+	Step          int64
+	Challenges    map[string]string
+	Attempts      []string
+}
+
+func (f *FakeTwoFactor) IsConfigured() bool { return !f.NotConfigured }
+func (f *FakeTwoFactor) NewEnrollment(accountName string) (*services.TOTPEnrollment, error) {
+	if f.NotConfigured {
+		return nil, services.ErrTwoFactorNotConfigured
+	}
+	return &services.TOTPEnrollment{
+		Secret:          "FAKESECRET",
+		EncryptedSecret: "enc:FAKESECRET",
+		URI:             "otpauth://totp/Test:" + accountName + "?secret=FAKESECRET",
+	}, nil
+}
+func (f *FakeTwoFactor) VerifyCode(_ string, code string, afterStep int64) (int64, bool, error) {
+	if f.NotConfigured {
+		return 0, false, services.ErrTwoFactorNotConfigured
+	}
+	if code != f.ValidCode || f.Step <= afterStep {
+		return 0, false, nil
+	}
+	return f.Step, true, nil
+}
+func (f *FakeTwoFactor) GenerateRecoveryCodes() ([]string, []string, error) {
+	codes := []string{"aaaaa-bbbbb", "ccccc-ddddd"}
+	return codes, []string{f.HashRecoveryCode(codes[0]), f.HashRecoveryCode(codes[1])}, nil
+}
+func (f *FakeTwoFactor) HashRecoveryCode(code string) string { return "hash:" + code }
+func (f *FakeTwoFactor) CreateChallenge(_ context.Context, userUID string) (string, error) {
+	if f.Challenges == nil {
+		f.Challenges = make(map[string]string)
+	}
+	token := "challenge-" + userUID
+	f.Challenges[token] = userUID
+	return token, nil
+}
+func (f *FakeTwoFactor) AttemptChallenge(_ context.Context, token string) (string, bool) {
+	uid, ok := f.Challenges[token]
+	if ok {
+		f.Attempts = append(f.Attempts, token)
+	}
+	return uid, ok
+}
+func (f *FakeTwoFactor) ConsumeChallenge(_ context.Context, token string) {
+	delete(f.Challenges, token)
+}
+
+// ---------- FakeWebAuthnRepo: models.WebAuthnCredentialStore ----------
+
+// FakeWebAuthnRepo 内存版 Passkey 仓库，按自增 ID 存储
+type FakeWebAuthnRepo struct {
+	Creds  map[int64]*models.WebAuthnCredential
+	nextID int64
+}
+
+// NewFakeWebAuthnRepo 创建空的内存 Passkey 仓库
+func NewFakeWebAuthnRepo() *FakeWebAuthnRepo {
+	return &FakeWebAuthnRepo{Creds: make(map[int64]*models.WebAuthnCredential)}
+}
+
+func (f *FakeWebAuthnRepo) Create(_ context.Context, cred *models.WebAuthnCredential) error {
+	for _, c := range f.Creds {
+		if string(c.CredentialID) == string(cred.CredentialID) {
+			return models.ErrWebAuthnCredentialExists
+		}
+	}
+	f.nextID++
+	cred.ID = f.nextID
+	cred.CreatedAt = time.Now()
+	f.Creds[cred.ID] = cred
+	return nil
+}
+func (f *FakeWebAuthnRepo) FindByCredentialID(_ context.Context, credentialID []byte) (*models.WebAuthnCredential, error) {
+	for _, c := range f.Creds {
+		if string(c.CredentialID) == string(credentialID) {
+			return c, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+func (f *FakeWebAuthnRepo) FindByID(_ context.Context, userUID string, id int64) (*models.WebAuthnCredential, error) {
+	if c := f.Creds[id]; c != nil && c.UserUID == userUID {
+		return c, nil
+	}
+	return nil, sql.ErrNoRows
+}
+func (f *FakeWebAuthnRepo) ListByUser(_ context.Context, userUID string) ([]*models.WebAuthnCredential, error) {
+	creds := []*models.WebAuthnCredential{}
+	for id := int64(1); id <= f.nextID; id++ {
+		if c := f.Creds[id]; c != nil && c.UserUID == userUID {
+			creds = append(creds, c)
+		}
+	}
+	return creds, nil
+}
+func (f *FakeWebAuthnRepo) Rename(ctx context.Context, userUID string, id int64, name string) error {
+	c, err := f.FindByID(ctx, userUID, id)
+	if err != nil {
+		return err
+	}
+	c.Name = name
+	return nil
+}
+func (f *FakeWebAuthnRepo) Delete(ctx context.Context, userUID string, id int64) error {
+	if _, err := f.FindByID(ctx, userUID, id); err != nil {
+		return err
+	}
+	delete(f.Creds, id)
+	return nil
+}
+func (f *FakeWebAuthnRepo) UpdateUsage(_ context.Context, id int64, signCount int64) error {
+	if c := f.Creds[id]; c != nil {
+		now := time.Now()
+		c.SignCount = signCount
+		c.LastUsedAt = &now
+	}
+	return nil
+}
+
+// ---------- FakeWebAuthn: services.WebAuthnManager ----------
+
+// FakeWebAuthn Passkey 服务 fake：不做密码学校验，
+// 注册返回 NewCredential，登录返回 SignCount，错误由 FinishErr 注入
+type FakeWebAuthn struct {
+	NewCredential *services.WebAuthnNewCredential
+	SignCount     uint32
+	FinishErr     error
+}
+
+func (f *FakeWebAuthn) BeginRegistration(_ context.Context, userUID, userName, displayName string, exclude []*models.WebAuthnCredential) (*services.WebAuthnRegistrationOptions, error) {
+	return &services.WebAuthnRegistrationOptions{
+		Challenge: "register-challenge",
+		User:      services.WebAuthnUserEntity{ID: userUID, Name: userName, DisplayName: displayName},
+	}, nil
+}
+func (f *FakeWebAuthn) FinishRegistration(context.Context, string, *services.WebAuthnRegistrationResponse) (*services.WebAuthnNewCredential, error) {
+	if f.FinishErr != nil {
+		return nil, f.FinishErr
+	}
+	return f.NewCredential, nil
+}
+func (f *FakeWebAuthn) BeginLogin(context.Context) (*services.WebAuthnLoginOptions, error) {
+	return &services.WebAuthnLoginOptions{Challenge: "login-challenge"}, nil
+}
+func (f *FakeWebAuthn) FinishLogin(context.Context, *services.WebAuthnAssertionResponse, *models.WebAuthnCredential) (uint32, error) {
+	if f.FinishErr != nil {
+		return 0, f.FinishErr
+	}
+	return f.SignCount, nil
+}
 
 // ---------- FakeCaptcha: services.CaptchaVerifier ----------
 // 模拟已启用且验证通过的验证码服务：默认放行（返回 nil），验证失败由 VerifyErr 开关控制。
@@ -232,9 +618,10 @@ func (f *FakeCaptcha) GetSiteKey() string                                      {
 
 // ---------- FakeEmailSender: services.EmailSender ----------
 
-// FakeEmailSender 记录异步发送请求的邮箱发送器
+// FakeEmailSender 记录异步发送请求的邮箱发送器；通知邮件按 "{类型}:{收件人}" 记入 Notices
 type FakeEmailSender struct {
 	SentEmails []string
+	Notices    []string
 }
 
 func (f *FakeEmailSender) VerifyConnection() error { return nil }
@@ -242,8 +629,57 @@ func (f *FakeEmailSender) SendVerificationEmailAsync(to, _, _, _, _ string) {
 	f.SentEmails = append(f.SentEmails, to)
 }
 func (f *FakeEmailSender) SendVerificationEmail(string, string, string, string) error { return nil }
-func (f *FakeEmailSender) IsConfigured() bool                                         { return false }
-func (f *FakeEmailSender) Close()                                                     {}
+func (f *FakeEmailSender) SendNewLoginAlert(to, _ string, _ *services.NewLoginEmailData) {
+	f.Notices = append(f.Notices, services.EmailTypeNewLogin+":"+to)
+}
+func (f *FakeEmailSender) SendPasswordChangedNotice(to, _ string, _ *services.PasswordChangedEmailData) {
+	f.Notices = append(f.Notices, services.EmailTypePasswordChanged+":"+to)
+}
+func (f *FakeEmailSender) SendAccountBannedNotice(to, _ string, _ *services.AccountBannedEmailData) {
+	f.Notices = append(f.Notices, services.EmailTypeAccountBanned+":"+to)
+}
+func (f *FakeEmailSender) SendOAuthAuthorizedNotice(to, _ string, _ *services.OAuthAuthorizedEmailData) {
+	f.Notices = append(f.Notices, services.EmailTypeOAuthAuthorized+":"+to)
+}
+func (f *FakeEmailSender) SendExportReadyNotice(to, _ string, _ *services.ExportReadyEmailData) {
+	f.Notices = append(f.Notices, services.EmailTypeExportReady+":"+to)
+}
+func (f *FakeEmailSender) SendAccountLockedNotice(to, _ string, _ *services.AccountLockedEmailData) {
+	f.Notices = append(f.Notices, services.EmailTypeAccountLocked+":"+to)
+}
+func (f *FakeEmailSender) SendPasskeyAddedNotice(to, _ string, _ *services.PasskeyAddedEmailData) {
+	f.Notices = append(f.Notices, services.EmailTypePasskeyAdded+":"+to)
+}
+func (f *FakeEmailSender) IsConfigured() bool { return false }
+func (f *FakeEmailSender) Close()             {}
+
+// ---------- FakeEmailQueue: services.EmailQueueManager ----------
+
+// FakeEmailQueue 返回固定统计的邮件队列
+type FakeEmailQueue struct {
+	Stats *models.EmailQueueStats
+}
+
+func (f *FakeEmailQueue) QueueStats(context.Context) (*models.EmailQueueStats, error) {
+	return f.Stats, nil
+}
+func (f *FakeEmailQueue) CleanupMessages(context.Context) (int64, error) { return 0, nil }
+
+// ---------- FakeEmailPreviewer: services.EmailTemplatePreviewer ----------
+
+// FakeEmailPreviewer 仅认识 Types 中的邮件类型，预览结果的主题为 "{类型}/{语言}"
+type FakeEmailPreviewer struct {
+	Types []string
+}
+
+func (f *FakeEmailPreviewer) EmailTemplateTypes() []string { return f.Types }
+func (f *FakeEmailPreviewer) EmailLanguages() []string     { return []string{"zh-CN", "en"} }
+func (f *FakeEmailPreviewer) PreviewEmail(emailType, language string) (*services.RenderedEmail, error) {
+	if !slices.Contains(f.Types, emailType) {
+		return nil, services.ErrEmailUnknownType
+	}
+	return &services.RenderedEmail{Subject: emailType + "/" + language, HTMLBody: "<p>" + emailType + "</p>", TextBody: emailType}, nil
+}
 
 // ---------- FakeUserLogStore: models.UserLogStore ----------
 
@@ -255,6 +691,9 @@ func (f *FakeUserLogStore) LogRegister(context.Context, string) error       { re
 func (f *FakeUserLogStore) LogChangeUsername(context.Context, string, string, string) error {
 	return nil
 }
+func (f *FakeUserLogStore) LogChangeEmail(context.Context, string, string, string) error {
+	return nil
+}
 func (f *FakeUserLogStore) LogChangeAvatar(context.Context, string, string, string) error {
 	return nil
 }
@@ -268,13 +707,32 @@ func (f *FakeUserLogStore) LogUnlinkMicrosoft(context.Context, string, string, s
 }
 func (f *FakeUserLogStore) LogLinkGoogle(context.Context, string, string, string) error   { return nil }
 func (f *FakeUserLogStore) LogUnlinkGoogle(context.Context, string, string, string) error { return nil }
-func (f *FakeUserLogStore) LogDeleteAccount(context.Context, string) error                { return nil }
-func (f *FakeUserLogStore) LogBanned(context.Context, string, string, *time.Time) error   { return nil }
-func (f *FakeUserLogStore) LogUnbanned(context.Context, string) error                     { return nil }
+func (f *FakeUserLogStore) LogLinkIdentity(context.Context, string, string, string, string) error {
+	return nil
+}
+func (f *FakeUserLogStore) LogUnlinkIdentity(context.Context, string, string, string, string) error {
+	return nil
+}
+func (f *FakeUserLogStore) LogDeleteAccount(context.Context, string) error              { return nil }
+func (f *FakeUserLogStore) LogBanned(context.Context, string, string, *time.Time) error { return nil }
+func (f *FakeUserLogStore) LogUnbanned(context.Context, string) error                   { return nil }
 func (f *FakeUserLogStore) LogOAuthAuthorize(context.Context, string, string, string, string) error {
 	return nil
 }
 func (f *FakeUserLogStore) LogOAuthRevoke(context.Context, string, string, string) error { return nil }
+func (f *FakeUserLogStore) LogEnable2FA(context.Context, string) error                   { return nil }
+func (f *FakeUserLogStore) LogDisable2FA(context.Context, string, string) error          { return nil }
+func (f *FakeUserLogStore) LogUse2FARecoveryCode(context.Context, string, int) error     { return nil }
+func (f *FakeUserLogStore) LogRegisterPasskey(context.Context, string, int64, string) error {
+	return nil
+}
+func (f *FakeUserLogStore) LogDeletePasskey(context.Context, string, int64, string) error { return nil }
+func (f *FakeUserLogStore) LogSecureAccount(context.Context, string, string, string, string) error {
+	return nil
+}
+func (f *FakeUserLogStore) LogLoginFailed(context.Context, string, string, string, bool) error {
+	return nil
+}
 func (f *FakeUserLogStore) FindByUserUID(context.Context, string, int, int) ([]*models.UserLog, int64, error) {
 	return nil, 0, nil
 }
@@ -344,21 +802,27 @@ func (f *FakeEmailWhitelist) InitDefaultWhitelist(context.Context, string) error
 type FakeLimiter struct {
 	EmailAllowed bool
 	EmailWait    int
+	Limited      map[string][]models.LimitedKey
+}
+
+func noopHandler(c *gin.Context)                                  { c.Next() }
+func (f *FakeLimiter) LoginRateLimit() gin.HandlerFunc            { return noopHandler }
+func (f *FakeLimiter) RegisterRateLimit() gin.HandlerFunc         { return noopHandler }
+func (f *FakeLimiter) ResetPasswordRateLimit() gin.HandlerFunc    { return noopHandler }
+func (f *FakeLimiter) OAuthTokenRateLimit() gin.HandlerFunc       { return noopHandler }
+func (f *FakeLimiter) VerifyCodeRateLimit() gin.HandlerFunc       { return noopHandler }
+func (f *FakeLimiter) QRLoginRateLimit() gin.HandlerFunc          { return noopHandler }
+func (f *FakeLimiter) PasswordStrengthRateLimit() gin.HandlerFunc { return noopHandler }
+func (f *FakeLimiter) EmailAllow(string) bool                     { return f.EmailAllowed }
+func (f *FakeLimiter) EmailWaitTime(string) int                   { return f.EmailWait }
+func (f *FakeLimiter) DataExportAllow(string) bool                { return true }
+func (f *FakeLimiter) DataExportWaitTime(string) int              { return 0 }
+func (f *FakeLimiter) Backend() string                            { return "memory" }
+func (f *FakeLimiter) StopAll()                                   {}
+func (f *FakeLimiter) LimitedKeys(context.Context) (map[string][]models.LimitedKey, error) {
+	return f.Limited, nil
 }
 
-func noopHandler(c *gin.Context)                               { c.Next() }
-func (f *FakeLimiter) LoginRateLimit() gin.HandlerFunc         { return noopHandler }
-func (f *FakeLimiter) RegisterRateLimit() gin.HandlerFunc      { return noopHandler }
-func (f *FakeLimiter) ResetPasswordRateLimit() gin.HandlerFunc { return noopHandler }
-func (f *FakeLimiter) OAuthTokenRateLimit() gin.HandlerFunc    { return noopHandler }
-func (f *FakeLimiter) VerifyCodeRateLimit() gin.HandlerFunc    { return noopHandler }
-func (f *FakeLimiter) QRLoginRateLimit() gin.HandlerFunc       { return noopHandler }
-func (f *FakeLimiter) EmailAllow(string) bool                  { return f.EmailAllowed }
-func (f *FakeLimiter) EmailWaitTime(string) int                { return f.EmailWait }
-func (f *FakeLimiter) DataExportAllow(string) bool             { return true }
-func (f *FakeLimiter) DataExportWaitTime(string) int           { return 0 }
-func (f *FakeLimiter) StopAll()                                {}
-
 // ---------- FakeStorageService: services.StorageService ----------
 
 // FakeStorageService 头像存储 fake，Configured 控制 IsConfigured
@@ -415,7 +879,18 @@ func (f *FakeExportToken) Stop()                                    {}
 
 // ---------- FakeAdminLogStore: models.AdminLogStore ----------
 
-type FakeAdminLogStore struct{}
+// FakeAdminLogStore 管理员日志 fake；SCIMLogs 记录 SCIM 写操作日志（action → 目标 UID），供断言审计
+type FakeAdminLogStore struct {
+	SCIMLogs []SCIMLog
+}
+
+// SCIMLog 一条 SCIM 操作日志
+type SCIMLog struct {
+	Action     string
+	ClientID   string
+	TargetUID  string
+	Attributes []string
+}
 
 func (f *FakeAdminLogStore) Create(context.Context, *models.AdminLog) error { return nil }
 func (f *FakeAdminLogStore) LogSetRole(context.Context, string, string, string, int, int) error {
@@ -428,6 +903,7 @@ func (f *FakeAdminLogStore) LogBanUser(context.Context, string, string, string,
 	return nil
 }
 func (f *FakeAdminLogStore) LogUnbanUser(context.Context, string, string, string) error { return nil }
+func (f *FakeAdminLogStore) LogReset2FA(context.Context, string, string, string) error  { return nil }
 func (f *FakeAdminLogStore) LogOAuthClientCreate(context.Context, string, int64, string, string) error {
 	return nil
 }
@@ -443,6 +919,15 @@ func (f *FakeAdminLogStore) LogOAuthClientRegenerateSecret(context.Context, stri
 func (f *FakeAdminLogStore) LogOAuthClientToggle(context.Context, string, int64, string, string, bool) error {
 	return nil
 }
+func (f *FakeAdminLogStore) LogOAuthScopeCreate(context.Context, string, *models.OAuthScope) error {
+	return nil
+}
+func (f *FakeAdminLogStore) LogOAuthScopeUpdate(context.Context, string, *models.OAuthScope) error {
+	return nil
+}
+func (f *FakeAdminLogStore) LogOAuthScopeDelete(context.Context, string, *models.OAuthScope) error {
+	return nil
+}
 func (f *FakeAdminLogStore) LogEmailWhitelistCreate(context.Context, string, *models.EmailWhitelist) error {
 	return nil
 }
@@ -452,10 +937,321 @@ func (f *FakeAdminLogStore) LogEmailWhitelistUpdate(context.Context, string, *mo
 func (f *FakeAdminLogStore) LogEmailWhitelistDelete(context.Context, string, int64) error { return nil }
 func (f *FakeAdminLogStore) LogDataExport(context.Context, string, int, int) error        { return nil }
 func (f *FakeAdminLogStore) LogDataImport(context.Context, string, int, int) error        { return nil }
+func (f *FakeAdminLogStore) LogUserGroupCreate(context.Context, string, *models.UserGroup) error {
+	return nil
+}
+func (f *FakeAdminLogStore) LogUserGroupUpdate(context.Context, string, *models.UserGroup) error {
+	return nil
+}
+func (f *FakeAdminLogStore) LogUserGroupDelete(context.Context, string, *models.UserGroup) error {
+	return nil
+}
+func (f *FakeAdminLogStore) LogUserGroupAddMember(context.Context, string, string, string, *models.UserGroup) error {
+	return nil
+}
+func (f *FakeAdminLogStore) LogUserGroupRemoveMember(context.Context, string, string, string, *models.UserGroup) error {
+	return nil
+}
+func (f *FakeAdminLogStore) LogImpersonationStart(context.Context, string, string, string, string, time.Time) error {
+	return nil
+}
+func (f *FakeAdminLogStore) LogImpersonationStop(context.Context, string, string, string) error {
+	return nil
+}
+func (f *FakeAdminLogStore) LogWebhookCreate(context.Context, string, *models.WebhookEndpoint) error {
+	return nil
+}
+func (f *FakeAdminLogStore) LogWebhookUpdate(context.Context, string, *models.WebhookEndpoint) error {
+	return nil
+}
+func (f *FakeAdminLogStore) LogWebhookDelete(context.Context, string, *models.WebhookEndpoint) error {
+	return nil
+}
+func (f *FakeAdminLogStore) LogWebhookRegenerateSecret(context.Context, string, *models.WebhookEndpoint) error {
+	return nil
+}
+func (f *FakeAdminLogStore) LogWebhookRedeliver(context.Context, string, *models.WebhookEndpoint, int64) error {
+	return nil
+}
+func (f *FakeAdminLogStore) LogSCIMUserCreate(_ context.Context, client *models.OAuthClient, targetUID, _ string) error {
+	f.SCIMLogs = append(f.SCIMLogs, SCIMLog{Action: models.ActionSCIMUserCreate, ClientID: client.ClientID, TargetUID: targetUID})
+	return nil
+}
+func (f *FakeAdminLogStore) LogSCIMUserUpdate(_ context.Context, client *models.OAuthClient, targetUID, _ string, attributes []string) error {
+	f.SCIMLogs = append(f.SCIMLogs, SCIMLog{Action: models.ActionSCIMUserUpdate, ClientID: client.ClientID, TargetUID: targetUID, Attributes: attributes})
+	return nil
+}
+func (f *FakeAdminLogStore) LogSCIMUserDeactivate(_ context.Context, client *models.OAuthClient, targetUID, _ string) error {
+	f.SCIMLogs = append(f.SCIMLogs, SCIMLog{Action: models.ActionSCIMUserDeactivate, ClientID: client.ClientID, TargetUID: targetUID})
+	return nil
+}
+func (f *FakeAdminLogStore) LogSCIMUserReactivate(_ context.Context, client *models.OAuthClient, targetUID, _ string) error {
+	f.SCIMLogs = append(f.SCIMLogs, SCIMLog{Action: models.ActionSCIMUserReactivate, ClientID: client.ClientID, TargetUID: targetUID})
+	return nil
+}
 func (f *FakeAdminLogStore) FindAll(context.Context, int, int) ([]*models.AdminLogPublic, int64, error) {
 	return nil, 0, nil
 }
 
+// ---------- FakeUserGroupRepo: models.UserGroupStore（内存实现） ----------
+
+type FakeUserGroupRepo struct {
+	nextID  int64
+	groups  map[int64]*models.UserGroup
+	members map[int64][]string
+	PermErr error // 非 nil 时 PermissionsForUser 返回该错误
+	FindErr error // 非 nil 时 FindByUser 返回该错误
+}
+
+func NewFakeUserGroupRepo() *FakeUserGroupRepo {
+	return &FakeUserGroupRepo{groups: map[int64]*models.UserGroup{}, members: map[int64][]string{}}
+}
+
+// Seed 直接写入一个用户组及其成员，返回分配的 ID
+func (f *FakeUserGroupRepo) Seed(group *models.UserGroup, memberUIDs ...string) int64 {
+	f.nextID++
+	group.ID = f.nextID
+	f.groups[group.ID] = group
+	f.members[group.ID] = append([]string{}, memberUIDs...)
+	return group.ID
+}
+
+// Members 返回用户组当前成员 UID
+func (f *FakeUserGroupRepo) Members(id int64) []string {
+	return append([]string{}, f.members[id]...)
+}
+
+func (f *FakeUserGroupRepo) PermissionsForUser(_ context.Context, uid string) ([]string, error) {
+	if f.PermErr != nil {
+		return nil, f.PermErr
+	}
+	var perms []string
+	for id, uids := range f.members {
+		if slices.Contains(uids, uid) {
+			perms = append(perms, f.groups[id].Permissions...)
+		}
+	}
+	return perms, nil
+}
+func (f *FakeUserGroupRepo) FindAll(context.Context) ([]*models.UserGroup, error) {
+	groups := make([]*models.UserGroup, 0, len(f.groups))
+	for _, g := range f.groups {
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+func (f *FakeUserGroupRepo) FindByID(_ context.Context, id int64) (*models.UserGroup, error) {
+	g, ok := f.groups[id]
+	if !ok {
+		return nil, models.ErrUserGroupNotFound
+	}
+	copied := *g
+	return &copied, nil
+}
+func (f *FakeUserGroupRepo) FindByUser(_ context.Context, uid string) ([]*models.UserGroup, error) {
+	if f.FindErr != nil {
+		return nil, f.FindErr
+	}
+	var groups []*models.UserGroup
+	for id, uids := range f.members {
+		if slices.Contains(uids, uid) {
+			groups = append(groups, f.groups[id])
+		}
+	}
+	slices.SortFunc(groups, func(a, b *models.UserGroup) int { return strings.Compare(a.Name, b.Name) })
+	return groups, nil
+}
+func (f *FakeUserGroupRepo) Create(_ context.Context, group *models.UserGroup) error {
+	if err := models.ValidateUserGroup(group); err != nil {
+		return err
+	}
+	for _, g := range f.groups {
+		if g.Name == group.Name {
+			return models.ErrUserGroupExists
+		}
+	}
+	f.Seed(group)
+	return nil
+}
+func (f *FakeUserGroupRepo) Update(_ context.Context, group *models.UserGroup) error {
+	if err := models.ValidateUserGroup(group); err != nil {
+		return err
+	}
+	if _, ok := f.groups[group.ID]; !ok {
+		return models.ErrUserGroupNotFound
+	}
+	f.groups[group.ID] = group
+	return nil
+}
+func (f *FakeUserGroupRepo) Delete(_ context.Context, id int64) (*models.UserGroup, error) {
+	g, ok := f.groups[id]
+	if !ok {
+		return nil, models.ErrUserGroupNotFound
+	}
+	delete(f.groups, id)
+	delete(f.members, id)
+	return g, nil
+}
+func (f *FakeUserGroupRepo) ListMembers(context.Context, int64) ([]*models.UserGroupMember, error) {
+	return nil, nil
+}
+func (f *FakeUserGroupRepo) AddMember(_ context.Context, id int64, uid string) error {
+	if _, ok := f.groups[id]; !ok {
+		return models.ErrUserGroupNotFound
+	}
+	if !slices.Contains(f.members[id], uid) {
+		f.members[id] = append(f.members[id], uid)
+	}
+	return nil
+}
+func (f *FakeUserGroupRepo) RemoveMember(_ context.Context, id int64, uid string) error {
+	i := slices.Index(f.members[id], uid)
+	if i < 0 {
+		return models.ErrUserGroupMemberNotFound
+	}
+	f.members[id] = slices.Delete(f.members[id], i, i+1)
+	return nil
+}
+
+// ---------- FakeWebhookManager: services.WebhookManager（内存实现） ----------
+
+// PublishedWebhook 记录一次 Publish 调用
+type PublishedWebhook struct {
+	EventType string
+	Data      any
+}
+
+type FakeWebhookManager struct {
+	nextID     int64
+	endpoints  map[int64]*models.WebhookEndpoint
+	Deliveries map[int64]*models.WebhookDelivery
+	Published  []PublishedWebhook
+}
+
+func NewFakeWebhookManager() *FakeWebhookManager {
+	return &FakeWebhookManager{endpoints: map[int64]*models.WebhookEndpoint{}, Deliveries: map[int64]*models.WebhookDelivery{}}
+}
+
+// Seed 直接写入一个端点，返回分配的 ID
+func (f *FakeWebhookManager) Seed(endpoint *models.WebhookEndpoint) int64 {
+	f.nextID++
+	endpoint.ID = f.nextID
+	f.endpoints[endpoint.ID] = endpoint
+	return endpoint.ID
+}
+
+func (f *FakeWebhookManager) Publish(_ context.Context, eventType string, data any) error {
+	f.Published = append(f.Published, PublishedWebhook{EventType: eventType, Data: data})
+	return nil
+}
+func (f *FakeWebhookManager) ListEndpoints(context.Context) ([]*models.WebhookEndpoint, error) {
+	endpoints := make([]*models.WebhookEndpoint, 0, len(f.endpoints))
+	for _, e := range f.endpoints {
+		endpoints = append(endpoints, e)
+	}
+	return endpoints, nil
+}
+func (f *FakeWebhookManager) GetEndpoint(_ context.Context, id int64) (*models.WebhookEndpoint, error) {
+	e, ok := f.endpoints[id]
+	if !ok {
+		return nil, models.ErrWebhookNotFound
+	}
+	return e, nil
+}
+func (f *FakeWebhookManager) CreateEndpoint(_ context.Context, endpoint *models.WebhookEndpoint) (string, error) {
+	if err := models.ValidateWebhookEndpoint(endpoint); err != nil {
+		return "", err
+	}
+	endpoint.Secret = "whsec_fake"
+	f.Seed(endpoint)
+	return endpoint.Secret, nil
+}
+func (f *FakeWebhookManager) UpdateEndpoint(_ context.Context, endpoint *models.WebhookEndpoint) error {
+	if err := models.ValidateWebhookEndpoint(endpoint); err != nil {
+		return err
+	}
+	if _, ok := f.endpoints[endpoint.ID]; !ok {
+		return models.ErrWebhookNotFound
+	}
+	f.endpoints[endpoint.ID] = endpoint
+	return nil
+}
+func (f *FakeWebhookManager) DeleteEndpoint(_ context.Context, id int64) (*models.WebhookEndpoint, error) {
+	e, ok := f.endpoints[id]
+	if !ok {
+		return nil, models.ErrWebhookNotFound
+	}
+	delete(f.endpoints, id)
+	return e, nil
+}
+func (f *FakeWebhookManager) RegenerateSecret(_ context.Context, id int64) (string, error) {
+	e, ok := f.endpoints[id]
+	if !ok {
+		return "", models.ErrWebhookNotFound
+	}
+	e.Secret = "whsec_rotated"
+	return e.Secret, nil
+}
+func (f *FakeWebhookManager) ListDeliveries(_ context.Context, endpointID int64, _, _ int) ([]*models.WebhookDelivery, int64, error) {
+	deliveries := make([]*models.WebhookDelivery, 0)
+	for _, d := range f.Deliveries {
+		if d.EndpointID == endpointID {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, int64(len(deliveries)), nil
+}
+func (f *FakeWebhookManager) Redeliver(_ context.Context, endpointID, deliveryID int64) (*models.WebhookDelivery, error) {
+	d, ok := f.Deliveries[deliveryID]
+	if !ok || d.EndpointID != endpointID {
+		return nil, models.ErrWebhookDeliveryNotFound
+	}
+	if !f.endpoints[endpointID].IsEnabled {
+		return nil, models.ErrWebhookDisabled
+	}
+	copied := *d
+	copied.ID = deliveryID + 1000
+	copied.Status = models.WebhookDeliveryPending
+	copied.Attempts = 0
+	f.Deliveries[copied.ID] = &copied
+	return &copied, nil
+}
+func (f *FakeWebhookManager) DeliverDue(context.Context) (int, error)          { return 0, nil }
+func (f *FakeWebhookManager) CleanupDeliveries(context.Context) (int64, error) { return 0, nil }
+
+// ---------- FakeLoginRiskChecker: services.LoginRiskChecker ----------
+
+// LoginCheck 一次 CheckLogin 调用的参数
+type LoginCheck struct {
+	UserUID  string
+	FamilyID string
+	Client   utils.ClientInfo
+}
+
+// FakeLoginRiskChecker 记录登录检查；Alerts 为预置的提醒（明文 token -> 提醒），消费后删除
+type FakeLoginRiskChecker struct {
+	Checks    []LoginCheck
+	Alerts    map[string]*models.LoginAlert
+	Forgotten []int64
+}
+
+func (f *FakeLoginRiskChecker) CheckLogin(_ context.Context, userUID, familyID string, client utils.ClientInfo) {
+	f.Checks = append(f.Checks, LoginCheck{UserUID: userUID, FamilyID: familyID, Client: client})
+}
+func (f *FakeLoginRiskChecker) ConsumeLoginAlert(_ context.Context, token string) (*models.LoginAlert, error) {
+	alert, ok := f.Alerts[token]
+	if !ok {
+		return nil, models.ErrLoginAlertNotFound
+	}
+	delete(f.Alerts, token)
+	return alert, nil
+}
+func (f *FakeLoginRiskChecker) ForgetDevice(_ context.Context, _ string, deviceID int64) error {
+	f.Forgotten = append(f.Forgotten, deviceID)
+	return nil
+}
+func (f *FakeLoginRiskChecker) CleanupExpired(context.Context) (int64, error) { return 0, nil }
+
 // ---------- FakeExportManager: services.ExportManager（OTA 文件导出） ----------
 
 type FakeExportManager struct{}
@@ -498,12 +1294,14 @@ type OAuthToggleCall struct {
 	Enabled bool
 }
 
-// FakeOAuthAdmin OAuth 客户端管理 fake，记录创建/删除/切换调用
+// FakeOAuthAdmin OAuth 客户端管理 fake，记录创建/删除/切换调用；自定义 scope 保存在内存中
 type FakeOAuthAdmin struct {
-	Created []string
-	Deleted []int64
-	Toggled []OAuthToggleCall
-	Client  *models.OAuthClient
+	Created   []string
+	Deleted   []int64
+	Toggled   []OAuthToggleCall
+	Client    *models.OAuthClient
+	UpdateErr error
+	Scopes    []*models.OAuthScope
 }
 
 func (f *FakeOAuthAdmin) GetClients(context.Context, int, int, string) ([]*models.OAuthClient, int64, error) {
@@ -515,12 +1313,12 @@ func (f *FakeOAuthAdmin) GetClient(context.Context, int64) (*models.OAuthClient,
 	}
 	return nil, &utils.DatabaseError{Operation: "GetClient", NotFound: true}
 }
-func (f *FakeOAuthAdmin) CreateClient(_ context.Context, name, _, _ string) (*models.OAuthClient, string, error) {
+func (f *FakeOAuthAdmin) CreateClient(_ context.Context, name, _ string, redirectURIs, postLogoutRedirectURIs, clientScopes, allowedScopes []string, claimMapping models.OAuthClaimMapping) (*models.OAuthClient, string, error) {
 	f.Created = append(f.Created, name)
-	return &models.OAuthClient{ID: 1, Name: name}, "generated-secret", nil
+	return &models.OAuthClient{ID: 1, Name: name, RedirectURIs: redirectURIs, PostLogoutRedirectURIs: postLogoutRedirectURIs, ClientScopes: clientScopes, AllowedScopes: allowedScopes, ClaimMapping: claimMapping}, "generated-secret", nil
 }
-func (f *FakeOAuthAdmin) UpdateClient(context.Context, int64, string, *string, string) error {
-	return nil
+func (f *FakeOAuthAdmin) UpdateClient(context.Context, int64, string, *string, []string, *[]string, *[]string, *[]string, *models.OAuthClaimMapping) error {
+	return f.UpdateErr
 }
 func (f *FakeOAuthAdmin) DeleteClient(_ context.Context, id int64) error {
 	f.Deleted = append(f.Deleted, id)
@@ -533,6 +1331,46 @@ func (f *FakeOAuthAdmin) ToggleClient(_ context.Context, id int64, enabled bool)
 	f.Toggled = append(f.Toggled, OAuthToggleCall{ID: id, Enabled: enabled})
 	return nil
 }
+func (f *FakeOAuthAdmin) ListScopes(context.Context) ([]*models.OAuthScope, error) {
+	return f.Scopes, nil
+}
+func (f *FakeOAuthAdmin) CreateScope(_ context.Context, name string, descriptions map[string]string) (*models.OAuthScope, error) {
+	if err := models.ValidateOAuthScopeName(name); err != nil {
+		return nil, err
+	}
+	if err := models.ValidateOAuthScopeDescriptions(descriptions); err != nil {
+		return nil, err
+	}
+	for _, s := range f.Scopes {
+		if s.Name == name {
+			return nil, models.ErrOAuthScopeExists
+		}
+	}
+	scope := &models.OAuthScope{ID: int64(len(f.Scopes) + 1), Name: name, Descriptions: descriptions}
+	f.Scopes = append(f.Scopes, scope)
+	return scope, nil
+}
+func (f *FakeOAuthAdmin) UpdateScope(_ context.Context, id int64, descriptions map[string]string) (*models.OAuthScope, error) {
+	if err := models.ValidateOAuthScopeDescriptions(descriptions); err != nil {
+		return nil, err
+	}
+	for _, s := range f.Scopes {
+		if s.ID == id {
+			s.Descriptions = descriptions
+			return s, nil
+		}
+	}
+	return nil, models.ErrOAuthScopeNotFound
+}
+func (f *FakeOAuthAdmin) DeleteScope(_ context.Context, id int64) (*models.OAuthScope, error) {
+	for i, s := range f.Scopes {
+		if s.ID == id {
+			f.Scopes = append(f.Scopes[:i], f.Scopes[i+1:]...)
+			return s, nil
+		}
+	}
+	return nil, models.ErrOAuthScopeNotFound
+}
 
 // ---------- FakeOAuthProvider: services.OAuthProviderStore ----------
 
@@ -546,9 +1384,21 @@ type FakeOAuthProvider struct {
 	RefreshResp     *services.OAuthTokenResponse
 	RefreshUserUID  string
 	RefreshErr      error
+	ClientCredsErr  error
 	AccessToken     *models.OAuthAccessToken
 	AccessTokenErr  error
+	TokenInfo       *services.OAuthTokenInfo
+	IntrospectErr   error
 	Revoked         []string
+	Scopes          []*models.OAuthScope
+	CodeAuthTime    time.Time // 最近一次 CreateAuthorizationCode 收到的登录时间
+
+	// 设备授权：DeviceCode 为 nil 时 user_code 视为无效；DeviceDecisions 记录用户的确认/拒绝
+	DeviceCode        *models.OAuthDeviceCode
+	DeviceDecisions   []bool
+	DeviceResp        *services.OAuthTokenResponse
+	DeviceUserUID     string
+	DeviceExchangeErr error
 }
 
 func (f *FakeOAuthProvider) ValidateClientID(context.Context, string) (*models.OAuthClient, error) {
@@ -558,7 +1408,8 @@ func (f *FakeOAuthProvider) ValidateClientID(context.Context, string) (*models.O
 	return f.Client, nil
 }
 func (f *FakeOAuthProvider) ValidateRedirectURI(*models.OAuthClient, string) bool { return true }
-func (f *FakeOAuthProvider) CreateAuthorizationCode(context.Context, string, string, string, string, string, string) (string, error) {
+func (f *FakeOAuthProvider) CreateAuthorizationCode(_ context.Context, _, _, _, _, _, _, _ string, authTime time.Time) (string, error) {
+	f.CodeAuthTime = authTime
 	return "auth-code", nil
 }
 func (f *FakeOAuthProvider) ValidateClient(context.Context, string, string) (*models.OAuthClient, error) {
@@ -579,16 +1430,72 @@ func (f *FakeOAuthProvider) RefreshAccessToken(context.Context, string, string)
 	}
 	return f.RefreshResp, f.RefreshUserUID, nil
 }
+func (f *FakeOAuthProvider) IssueClientCredentialsToken(_ context.Context, client *models.OAuthClient, scope string) (*services.OAuthTokenResponse, error) {
+	if f.ClientCredsErr != nil {
+		return nil, f.ClientCredsErr
+	}
+	if scope == "" {
+		scope = strings.Join(client.ClientScopes, " ")
+	}
+	return &services.OAuthTokenResponse{AccessToken: "client-token", TokenType: "Bearer", ExpiresIn: 3600, Scope: scope}, nil
+}
 func (f *FakeOAuthProvider) ValidateAccessToken(context.Context, string) (*models.OAuthAccessToken, error) {
 	if f.AccessTokenErr != nil {
 		return nil, f.AccessTokenErr
 	}
 	return f.AccessToken, nil
 }
+func (f *FakeOAuthProvider) IntrospectToken(context.Context, string, string) (*services.OAuthTokenInfo, error) {
+	if f.IntrospectErr != nil {
+		return nil, f.IntrospectErr
+	}
+	if f.TokenInfo == nil {
+		return nil, services.ErrOAuthTokenNotFound
+	}
+	return f.TokenInfo, nil
+}
 func (f *FakeOAuthProvider) RevokeToken(_ context.Context, token string) error {
 	f.Revoked = append(f.Revoked, token)
 	return nil
 }
+func (f *FakeOAuthProvider) GetScopes(_ context.Context, names []string) ([]*models.OAuthScope, error) {
+	scopes := make([]*models.OAuthScope, 0)
+	for _, s := range f.Scopes {
+		if slices.Contains(names, s.Name) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes, nil
+}
+
+func (f *FakeOAuthProvider) CreateDeviceAuthorization(context.Context, string, string) (*services.OAuthDeviceAuthorization, error) {
+	return &services.OAuthDeviceAuthorization{DeviceCode: "device-code", UserCode: "BCDF-GHJK", ExpiresIn: 600, Interval: 5}, nil
+}
+func (f *FakeOAuthProvider) GetDeviceAuthorization(context.Context, string) (*models.OAuthDeviceCode, error) {
+	if f.DeviceCode == nil || f.DeviceCode.Status != models.QRStatusPending {
+		return nil, services.ErrOAuthUserCodeInvalid
+	}
+	return f.DeviceCode, nil
+}
+func (f *FakeOAuthProvider) DecideDeviceAuthorization(ctx context.Context, userCode, userUID string, approve bool) (*models.OAuthDeviceCode, error) {
+	dc, err := f.GetDeviceAuthorization(ctx, userCode)
+	if err != nil {
+		return nil, err
+	}
+	f.DeviceDecisions = append(f.DeviceDecisions, approve)
+	dc.UserUID = userUID
+	dc.Status = models.QRStatusCancelled
+	if approve {
+		dc.Status = models.QRStatusConfirmed
+	}
+	return dc, nil
+}
+func (f *FakeOAuthProvider) ExchangeDeviceCode(context.Context, string, string) (*services.OAuthTokenResponse, string, error) {
+	if f.DeviceExchangeErr != nil {
+		return nil, "", f.DeviceExchangeErr
+	}
+	return f.DeviceResp, f.DeviceUserUID, nil
+}
 
 // ---------- FakeQRLoginStore: models.QRLoginStore ----------
 
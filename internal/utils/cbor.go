@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrCBORTruncated   = errors.New("cbor: unexpected end of data")
+	ErrCBORUnsupported = errors.New("cbor: unsupported item")
+)
+
+// cborMaxDepth 嵌套深度上限，防止恶意输入导致栈溢出
+const cborMaxDepth = 16
+
+// DecodeCBOR 解码一个 CBOR 数据项（RFC 8949 子集，满足 WebAuthn 的 attestationObject / COSE_Key 解析），
+// 返回解码值与剩余未读字节。
+//
+// 类型映射：无符号/负整数 -> int64，字节串 -> []byte，文本串 -> string，数组 -> []any，
+// 映射 -> map[any]any（键为 int64 或 string），true/false -> bool，null/undefined -> nil。
+// 不支持不定长编码、浮点数与标签以外的扩展类型（标签会被剥离，仅保留内容）。
+func DecodeCBOR(data []byte) (any, []byte, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (any, []byte, error) {
+	if depth > cborMaxDepth {
+		return nil, nil, fmt.Errorf("%w: nesting too deep", ErrCBORUnsupported)
+	}
+	if len(data) == 0 {
+		return nil, nil, ErrCBORTruncated
+	}
+
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+	data = data[1:]
+
+	// 简单值（major 7）单独处理：附加信息不表示长度
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22, 23:
+			return nil, data, nil
+		default:
+			return nil, nil, fmt.Errorf("%w: simple value %d", ErrCBORUnsupported, info)
+		}
+	}
+
+	arg, data, err := readCBORArgument(info, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > 1<<63-1 {
+			return nil, nil, fmt.Errorf("%w: integer overflow", ErrCBORUnsupported)
+		}
+		return int64(arg), data, nil
+	case 1:
+		if arg > 1<<63-1 {
+			return nil, nil, fmt.Errorf("%w: integer overflow", ErrCBORUnsupported)
+		}
+		return -1 - int64(arg), data, nil
+	case 2, 3:
+		if arg > uint64(len(data)) {
+			return nil, nil, ErrCBORTruncated
+		}
+		b := data[:arg]
+		if major == 3 {
+			return string(b), data[arg:], nil
+		}
+		return append([]byte(nil), b...), data[arg:], nil
+	case 4:
+		// 每个元素至少占 1 字节，长度超过剩余数据必然非法（同时避免超大预分配）
+		if arg > uint64(len(data)) {
+			return nil, nil, ErrCBORTruncated
+		}
+		items := make([]any, 0, arg)
+		for range arg {
+			var item any
+			item, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, data, nil
+	case 5:
+		if arg > uint64(len(data))/2 {
+			return nil, nil, ErrCBORTruncated
+		}
+		m := make(map[any]any, arg)
+		for range arg {
+			var key, value any
+			key, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("%w: map key type %T", ErrCBORUnsupported, key)
+			}
+			value, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			m[key] = value
+		}
+		return m, data, nil
+	case 6:
+		return decodeCBORItem(data, depth+1)
+	}
+
+	return nil, nil, fmt.Errorf("%w: major type %d", ErrCBORUnsupported, major)
+}
+
+// readCBORArgument 读取数据项头部的参数（长度或整数值）
+func readCBORArgument(info byte, data []byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24:
+		if len(data) < 1 {
+			return 0, nil, ErrCBORTruncated
+		}
+		return uint64(data[0]), data[1:], nil
+	case info == 25:
+		if len(data) < 2 {
+			return 0, nil, ErrCBORTruncated
+		}
+		return uint64(binary.BigEndian.Uint16(data)), data[2:], nil
+	case info == 26:
+		if len(data) < 4 {
+			return 0, nil, ErrCBORTruncated
+		}
+		return uint64(binary.BigEndian.Uint32(data)), data[4:], nil
+	case info == 27:
+		if len(data) < 8 {
+			return 0, nil, ErrCBORTruncated
+		}
+		return binary.BigEndian.Uint64(data), data[8:], nil
+	default:
+		return 0, nil, fmt.Errorf("%w: indefinite length or reserved info %d", ErrCBORUnsupported, info)
+	}
+}
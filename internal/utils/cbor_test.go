@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// RFC 8949 附录 A 的部分编码示例
+func TestDecodeCBORExamples(t *testing.T) {
+	cases := []struct {
+		name string
+		in   []byte
+		want any
+	}{
+		{"zero", []byte{0x00}, int64(0)},
+		{"uint16", []byte{0x19, 0x03, 0xe8}, int64(1000)},
+		{"negative", []byte{0x38, 0x63}, int64(-100)},
+		{"text", []byte{0x64, 0x49, 0x45, 0x54, 0x46}, "IETF"},
+		{"true", []byte{0xf5}, true},
+		{"null", []byte{0xf6}, nil},
+	}
+	for _, tc := range cases {
+		got, rest, err := DecodeCBOR(tc.in)
+		if err != nil {
+			t.Fatalf("%s: DecodeCBOR() error = %v", tc.name, err)
+		}
+		if got != tc.want || len(rest) != 0 {
+			t.Errorf("%s: DecodeCBOR() = (%v, %x), want %v", tc.name, got, rest, tc.want)
+		}
+	}
+}
+
+func TestDecodeCBORMapAndRemainder(t *testing.T) {
+	// {1: h'0102', "a": [2, 3]} 后跟 1 字节尾随数据
+	in := []byte{0xa2, 0x01, 0x42, 0x01, 0x02, 0x61, 0x61, 0x82, 0x02, 0x03, 0xff}
+	got, rest, err := DecodeCBOR(in)
+	if err != nil {
+		t.Fatalf("DecodeCBOR() error = %v", err)
+	}
+	m, ok := got.(map[any]any)
+	if !ok {
+		t.Fatalf("DecodeCBOR() = %T, want map", got)
+	}
+	if b, _ := m[int64(1)].([]byte); !bytes.Equal(b, []byte{0x01, 0x02}) {
+		t.Errorf("m[1] = %v", m[int64(1)])
+	}
+	if arr, _ := m["a"].([]any); len(arr) != 2 || arr[1] != int64(3) {
+		t.Errorf(`m["a"] = %v`, m["a"])
+	}
+	if !bytes.Equal(rest, []byte{0xff}) {
+		t.Errorf("rest = %x, want ff", rest)
+	}
+}
+
+func TestDecodeCBORRejectsMalformed(t *testing.T) {
+	cases := map[string][]byte{
+		"truncated bytes":   {0x45, 0x01},
+		"oversized array":   {0x9a, 0xff, 0xff, 0xff, 0xff},
+		"indefinite length": {0x5f},
+		"float":             {0xf9, 0x3c, 0x00},
+	}
+	for name, in := range cases {
+		if _, _, err := DecodeCBOR(in); !errors.Is(err, ErrCBORTruncated) && !errors.Is(err, ErrCBORUnsupported) {
+			t.Errorf("%s: DecodeCBOR() error = %v, want decode error", name, err)
+		}
+	}
+
+	deep := bytes.Repeat([]byte{0x81}, cborMaxDepth+2)
+	if _, _, err := DecodeCBOR(append(deep, 0x00)); !errors.Is(err, ErrCBORUnsupported) {
+		t.Errorf("deep nesting error = %v, want ErrCBORUnsupported", err)
+	}
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ua-parser/uap-go/uaparser"
+)
+
+// maxUserAgentLength 入库 User-Agent 的最大长度（超长截断，防止异常请求头撑大存储）
+const maxUserAgentLength = 512
+
+// uaParser 全局 UAParser 实例（线程安全，内置 LRU 缓存）
+var uaParser = uaparser.NewFromSaved()
+
+// ParseUserAgent 解析 User-Agent 提取浏览器和操作系统信息，无法识别时返回 "Unknown"
+func ParseUserAgent(userAgent string) (browser, os string) {
+	browser = "Unknown"
+	os = "Unknown"
+
+	if userAgent == "" {
+		return
+	}
+
+	client := uaParser.Parse(userAgent)
+
+	if client.UserAgent.Family != "" {
+		browser = client.UserAgent.Family
+	}
+
+	if client.Os.Family != "" {
+		os = client.Os.Family
+	}
+
+	return
+}
+
+// ClientInfo 发起请求的客户端设备信息（用于会话记录与登录风险判断）
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+	Location  ClientLocation
+}
+
+type clientInfoCtxKey struct{}
+
+// WithClientInfo 将客户端设备信息存入 context.Context，User-Agent 超长时截断。
+// 按字节截断可能切开多字节字符，且请求头本身可能不是合法 UTF-8（PostgreSQL 会拒绝写入），统一清理无效字节
+func WithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	if len(info.UserAgent) > maxUserAgentLength {
+		info.UserAgent = info.UserAgent[:maxUserAgentLength]
+	}
+	info.UserAgent = strings.ToValidUTF8(info.UserAgent, "")
+	return context.WithValue(ctx, clientInfoCtxKey{}, info)
+}
+
+// ClientInfoFrom 从 context.Context 中取出客户端设备信息，未设置时返回零值。
+func ClientInfoFrom(ctx context.Context) ClientInfo {
+	if ctx == nil {
+		return ClientInfo{}
+	}
+	if info, ok := ctx.Value(clientInfoCtxKey{}).(ClientInfo); ok {
+		return info
+	}
+	return ClientInfo{}
+}
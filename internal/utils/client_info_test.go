@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestWithClientInfoTruncatesUserAgent(t *testing.T) {
+	// 511 字节 ASCII + 3 字节汉字：按字节截断到 512 会切开汉字
+	ua := strings.Repeat("a", maxUserAgentLength-1) + "浏览器"
+	info := ClientInfoFrom(WithClientInfo(context.Background(), ClientInfo{UserAgent: ua}))
+	if len(info.UserAgent) > maxUserAgentLength {
+		t.Errorf("user agent length = %d, want <= %d", len(info.UserAgent), maxUserAgentLength)
+	}
+	if !utf8.ValidString(info.UserAgent) {
+		t.Error("truncated user agent should be valid UTF-8")
+	}
+
+	info = ClientInfoFrom(WithClientInfo(context.Background(), ClientInfo{UserAgent: "Mozilla/5.0 \xff"}))
+	if info.UserAgent != "Mozilla/5.0 " {
+		t.Errorf("user agent = %q, want invalid bytes removed", info.UserAgent)
+	}
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"net"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -73,3 +74,59 @@ func GetClientIP(c *gin.Context) string {
 	}
 	return ip
 }
+
+// maxCityLength 城市名最大长度（超长截断）
+const maxCityLength = 100
+
+// ClientLocation 客户端的大致地理位置（来自 Cloudflare 访客位置头），未知字段为零值
+type ClientLocation struct {
+	Country   string   // ISO 3166-1 alpha-2 国家代码（大写）
+	City      string   // 城市名
+	Latitude  *float64 // 纬度
+	Longitude *float64 // 经度
+}
+
+// HasCoordinates 经纬度是否都已知
+func (l ClientLocation) HasCoordinates() bool {
+	return l.Latitude != nil && l.Longitude != nil
+}
+
+// GetClientLocation 读取 Cloudflare 访客位置头（CF-IPCountry / CF-IPCity / CF-IPLatitude / CF-IPLongitude）
+//
+// 与 GetClientIP 相同，仅信任来自本地回环的请求；非法值丢弃，
+// 国家代码 "XX"（未知）与 "T1"（Tor）视为未知。
+func GetClientLocation(c *gin.Context) ClientLocation {
+	var loc ClientLocation
+	if c == nil || !isLocalOrigin(c.Request.RemoteAddr) {
+		return loc
+	}
+
+	country := strings.ToUpper(strings.TrimSpace(c.GetHeader("CF-IPCountry")))
+	if len(country) == 2 && country != "XX" && country != "T1" && isASCIIUpper(country) {
+		loc.Country = country
+	}
+
+	city := strings.TrimSpace(c.GetHeader("CF-IPCity"))
+	if len(city) > maxCityLength {
+		city = city[:maxCityLength]
+	}
+	loc.City = strings.ToValidUTF8(city, "")
+
+	lat, latErr := strconv.ParseFloat(strings.TrimSpace(c.GetHeader("CF-IPLatitude")), 64)
+	lon, lonErr := strconv.ParseFloat(strings.TrimSpace(c.GetHeader("CF-IPLongitude")), 64)
+	if latErr == nil && lonErr == nil && lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180 {
+		loc.Latitude = &lat
+		loc.Longitude = &lon
+	}
+	return loc
+}
+
+// isASCIIUpper 判断字符串是否全部为 A-Z
+func isASCIIUpper(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'A' || s[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
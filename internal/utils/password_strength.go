@@ -0,0 +1,422 @@
+package utils
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// 强度估算反馈中的警告码，前端据此翻译展示
+const (
+	PasswordWarningUserInputs = "user_inputs"
+	PasswordWarningCommon     = "common_password"
+	PasswordWarningKeyboard   = "keyboard_pattern"
+	PasswordWarningSequence   = "sequence"
+	PasswordWarningRepeat     = "repeat"
+	PasswordWarningDates      = "dates"
+)
+
+// 强度估算反馈中的建议码
+const (
+	PasswordSuggestAvoidPersonal  = "avoid_personal_info"
+	PasswordSuggestAvoidCommon    = "avoid_common_words"
+	PasswordSuggestAvoidKeyboard  = "avoid_keyboard_patterns"
+	PasswordSuggestAvoidSequences = "avoid_sequences"
+	PasswordSuggestAvoidRepeats   = "avoid_repeats"
+	PasswordSuggestAvoidDates     = "avoid_dates"
+	PasswordSuggestAddWords       = "add_words"
+)
+
+// PasswordMinScore 新密码要求的最低强度评分（0-4）
+const PasswordMinScore = 3
+
+// 评分阈值（log10 猜测次数），与 zxcvbn 一致：< 10^3 为 0 分，>= 10^10 为 4 分
+var passwordScoreThresholds = [...]float64{3, 6, 8, 10}
+
+// PasswordStrength 密码强度估算结果
+type PasswordStrength struct {
+	Score        int              `json:"score"`
+	GuessesLog10 float64          `json:"guessesLog10"`
+	Feedback     PasswordFeedback `json:"feedback"`
+}
+
+// PasswordFeedback 结构化反馈：Warning 为最主要的问题，Suggestions 为改进建议（均为翻译码）
+type PasswordFeedback struct {
+	Warning     string   `json:"warning,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// pwMatch 密码中被识别为某种模式的片段 [i, j)（按 rune 下标）
+type pwMatch struct {
+	i, j    int
+	pattern string
+	guesses float64
+}
+
+// commonPasswordWords 常见密码及其组成词，按流行程度排序（下标越小越容易被猜到）
+var commonPasswordWords = []string{
+	"password", "123456", "qwerty", "admin", "welcome", "letmein", "iloveyou", "monkey",
+	"dragon", "football", "baseball", "master", "sunshine", "princess", "shadow", "superman",
+	"trustno1", "login", "hello", "freedom", "whatever", "passw0rd", "starwars", "computer",
+	"michael", "jennifer", "charlie", "secret", "summer", "winter", "spring", "autumn",
+	"love", "pass", "root", "user", "test", "guest", "changeme", "default",
+	"abc", "asdf", "zxcv", "qazwsx", "azerty", "pokemon", "batman", "soccer",
+	"hockey", "killer", "flower", "cookie", "cheese", "coffee", "orange", "banana",
+	"purple", "yellow", "silver", "golden", "diamond", "angel", "lucky", "happy",
+	"family", "friend", "forever", "internet", "google", "apple", "samsung", "microsoft",
+	"nebula", "account", "security", "access", "private", "mypass", "mypassword", "letmein1",
+}
+
+// keyboardRows 键盘相邻键序列（含 Shift 行），连续 4 个及以上视为键盘模式
+var keyboardRows = []string{
+	"`1234567890-=", "~!@#$%^&*()_+",
+	"qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./",
+	"qazwsxedcrfvtgbyhnujmikolp",
+}
+
+// l33tTable 常见字符替换还原
+var l33tTable = map[rune]rune{
+	'4': 'a', '@': 'a', '3': 'e', '1': 'i', '!': 'i', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't',
+}
+
+// EstimatePasswordStrength 估算密码被猜中所需的次数并评分（zxcvbn 风格）：
+// 识别常见密码词、用户名/邮箱等个人信息（含大小写变化、l33t 替换与倒序）、键盘序列、
+// 字母数字顺序、重复与年份日期，取覆盖整个密码的最低猜测次数组合。
+// userInputs 通常为用户名与邮箱，命中时按极易猜测处理
+func EstimatePasswordStrength(password string, userInputs ...string) PasswordStrength {
+	runes := []rune(password)
+	if len(runes) == 0 {
+		return PasswordStrength{Feedback: PasswordFeedback{Suggestions: []string{PasswordSuggestAddWords}}}
+	}
+
+	guessesLog10, used := passwordGuessesLog10(runes, passwordUserTokens(userInputs))
+	score := 0
+	for score < len(passwordScoreThresholds) && guessesLog10 >= passwordScoreThresholds[score] {
+		score++
+	}
+
+	strength := PasswordStrength{Score: score, GuessesLog10: math.Round(guessesLog10*100) / 100}
+	if score < PasswordMinScore {
+		strength.Feedback = passwordFeedback(used)
+	}
+	return strength
+}
+
+// passwordGuessesLog10 识别全部模式并求最小 log10 猜测次数，返回所用的模式片段
+func passwordGuessesLog10(runes []rune, userTokens []string) (float64, []pwMatch) {
+	matches := dictionaryMatches(runes, userTokens)
+	matches = append(matches, keyboardMatches(runes)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, repeatMatches(runes, func(block []rune) float64 {
+		// 重复片段本身按同样规则估算（如用户名 + 年份重复两次）
+		log10, _ := passwordGuessesLog10(block, userTokens)
+		return log10
+	})...)
+	matches = append(matches, dateMatches(runes)...)
+	return minimumGuesses(runes, matches)
+}
+
+// minimumGuesses 动态规划求覆盖整个密码的最小 log10 猜测次数；未被模式覆盖的字符按每字符 10 种暴力计
+func minimumGuesses(runes []rune, matches []pwMatch) (float64, []pwMatch) {
+	n := len(runes)
+	best := make([]float64, n+1)
+	via := make([]*pwMatch, n+1)
+	byEnd := make(map[int][]pwMatch, len(matches))
+	for _, m := range matches {
+		byEnd[m.j] = append(byEnd[m.j], m)
+	}
+
+	for k := 1; k <= n; k++ {
+		best[k] = best[k-1] + 1
+		via[k] = nil
+		for idx := range byEnd[k] {
+			m := &byEnd[k][idx]
+			// 每多一个模式片段，攻击者需额外尝试组合方式，加 log10(2) 作为拼接代价
+			if cost := best[m.i] + math.Log10(max(m.guesses, 1)) + math.Log10(2); cost < best[k] {
+				best[k] = cost
+				via[k] = m
+			}
+		}
+	}
+
+	var used []pwMatch
+	for k := n; k > 0; {
+		if m := via[k]; m != nil {
+			used = append(used, *m)
+			k = m.i
+		} else {
+			k--
+		}
+	}
+	return best[n], used
+}
+
+// passwordFeedback 按所用模式生成反馈，警告取覆盖最长的片段
+func passwordFeedback(used []pwMatch) PasswordFeedback {
+	feedback := PasswordFeedback{}
+	seen := make(map[string]bool)
+	longest := 0
+	for _, m := range used {
+		if m.j-m.i > longest {
+			longest = m.j - m.i
+			feedback.Warning = m.pattern
+		}
+		if seen[m.pattern] {
+			continue
+		}
+		seen[m.pattern] = true
+		switch m.pattern {
+		case PasswordWarningUserInputs:
+			feedback.Suggestions = append(feedback.Suggestions, PasswordSuggestAvoidPersonal)
+		case PasswordWarningCommon:
+			feedback.Suggestions = append(feedback.Suggestions, PasswordSuggestAvoidCommon)
+		case PasswordWarningKeyboard:
+			feedback.Suggestions = append(feedback.Suggestions, PasswordSuggestAvoidKeyboard)
+		case PasswordWarningSequence:
+			feedback.Suggestions = append(feedback.Suggestions, PasswordSuggestAvoidSequences)
+		case PasswordWarningRepeat:
+			feedback.Suggestions = append(feedback.Suggestions, PasswordSuggestAvoidRepeats)
+		case PasswordWarningDates:
+			feedback.Suggestions = append(feedback.Suggestions, PasswordSuggestAvoidDates)
+		}
+	}
+	feedback.Suggestions = append(feedback.Suggestions, PasswordSuggestAddWords)
+	return feedback
+}
+
+// passwordUserTokens 从用户名、邮箱中提取用于比对的词：整体、邮箱本地部分、域名主体及按分隔符拆出的片段
+func passwordUserTokens(userInputs []string) []string {
+	var tokens []string
+	add := func(s string) {
+		if len([]rune(s)) >= 3 {
+			tokens = append(tokens, s)
+		}
+	}
+	for _, input := range userInputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+		if input == "" {
+			continue
+		}
+		local, domain, isEmail := strings.Cut(input, "@")
+		add(local)
+		if isEmail {
+			add(strings.Split(domain, ".")[0])
+		}
+		parts := strings.FieldsFunc(local, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		if len(parts) > 1 {
+			for _, p := range parts {
+				add(p)
+			}
+		}
+	}
+	return tokens
+}
+
+// dictionaryMatches 在小写、l33t 还原及倒序后的密码中查找常见词与用户信息
+func dictionaryMatches(runes []rune, userTokens []string) []pwMatch {
+	lower := []rune(strings.ToLower(string(runes)))
+	if len(lower) != len(runes) {
+		return nil
+	}
+	unl33t := make([]rune, len(lower))
+	l33ted := false
+	for i, r := range lower {
+		if sub, ok := l33tTable[r]; ok {
+			unl33t[i] = sub
+			l33ted = true
+		} else {
+			unl33t[i] = r
+		}
+	}
+
+	type dictWord struct {
+		word    string
+		rank    float64
+		pattern string
+	}
+	var words []dictWord
+	for _, token := range userTokens {
+		words = append(words, dictWord{token, 1, PasswordWarningUserInputs})
+	}
+	for rank, w := range commonPasswordWords {
+		words = append(words, dictWord{w, float64(rank + 1), PasswordWarningCommon})
+	}
+
+	var matches []pwMatch
+	find := func(text []rune, word dictWord, factor float64, reversed bool) {
+		w := []rune(word.word)
+		if reversed {
+			w = reverseRunes(w)
+		}
+		for i := 0; i+len(w) <= len(text); i++ {
+			if string(text[i:i+len(w)]) != string(w) {
+				continue
+			}
+			j := i + len(w)
+			guesses := word.rank * factor * uppercaseVariations(runes[i:j])
+			matches = append(matches, pwMatch{i: i, j: j, pattern: word.pattern, guesses: guesses})
+		}
+	}
+	for _, word := range words {
+		find(lower, word, 1, false)
+		find(lower, word, 2, true)
+		// l33t 替换只让猜测次数翻倍
+		if l33ted {
+			find(unl33t, word, 2, false)
+			find(unl33t, word, 4, true)
+		}
+	}
+	return matches
+}
+
+// uppercaseVariations 大小写变化带来的额外猜测倍数：全小写 1，首字母或全大写 2，其余按大写字母组合数
+func uppercaseVariations(segment []rune) float64 {
+	upper := 0
+	for _, r := range segment {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+	switch {
+	case upper == 0:
+		return 1
+	case upper == len(segment), upper == 1 && unicode.IsUpper(segment[0]):
+		return 2
+	default:
+		return math.Min(math.Pow(2, float64(upper)), 1e4)
+	}
+}
+
+// keyboardMatches 查找长度 >= 4 的键盘相邻键序列（正向或反向）
+func keyboardMatches(runes []rune) []pwMatch {
+	lower := []rune(strings.ToLower(string(runes)))
+	var matches []pwMatch
+	for _, row := range keyboardRows {
+		for _, line := range []string{row, string(reverseRunes([]rune(row)))} {
+			for i := 0; i < len(lower); {
+				j := i
+				for j < len(lower) && j-i < len(line) && strings.Contains(line, string(lower[i:j+1])) {
+					j++
+				}
+				if j-i >= 4 {
+					matches = append(matches, pwMatch{i: i, j: j, pattern: PasswordWarningKeyboard, guesses: 40 * float64(j-i) * uppercaseVariations(runes[i:j])})
+					i = j
+				} else {
+					i++
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// sequenceMatches 查找长度 >= 3、步长为 ±1 的字母或数字顺序（abc、987）
+func sequenceMatches(runes []rune) []pwMatch {
+	lower := []rune(strings.ToLower(string(runes)))
+	var matches []pwMatch
+	for i := 0; i < len(lower)-2; {
+		delta := lower[i+1] - lower[i]
+		if (delta != 1 && delta != -1) || !sameCharClass(lower[i], lower[i+1]) {
+			i++
+			continue
+		}
+		j := i + 2
+		for j < len(lower) && lower[j]-lower[j-1] == delta && sameCharClass(lower[j-1], lower[j]) {
+			j++
+		}
+		if j-i >= 3 {
+			base := 26.0
+			switch first := lower[i]; {
+			case first == 'a' || first == 'z' || first == '0' || first == '1' || first == '9':
+				base = 4
+			case unicode.IsDigit(first):
+				base = 10
+			}
+			if delta < 0 {
+				base *= 2
+			}
+			matches = append(matches, pwMatch{i: i, j: j, pattern: PasswordWarningSequence, guesses: base * float64(j-i)})
+		}
+		i = j - 1
+	}
+	return matches
+}
+
+// repeatMatches 查找连续重复的单字符（aaa）或片段（abcabc）：每处取最小重复单元的最长重复，
+// blockLog10 估算重复单元的 log10 猜测次数
+func repeatMatches(runes []rune, blockLog10 func([]rune) float64) []pwMatch {
+	var matches []pwMatch
+	n := len(runes)
+	for i := 0; i < n; {
+		end := i + 1
+		for size := 1; i+2*size <= n; size++ {
+			block := string(runes[i : i+size])
+			count := 1
+			for i+(count+1)*size <= n && string(runes[i+count*size:i+(count+1)*size]) == block {
+				count++
+			}
+			if count < 2 || count*size < 3 {
+				continue
+			}
+			guesses := math.Pow(10, blockLog10(runes[i:i+size])) * float64(count)
+			matches = append(matches, pwMatch{i: i, j: i + count*size, pattern: PasswordWarningRepeat, guesses: guesses})
+			end = i + count*size
+			break
+		}
+		i = end
+	}
+	return matches
+}
+
+// dateMatches 查找 1900-2099 年份与 8 位数字日期（YYYYMMDD、DDMMYYYY、MMDDYYYY）
+func dateMatches(runes []rune) []pwMatch {
+	var matches []pwMatch
+	n := len(runes)
+	for i := 0; i+4 <= n; i++ {
+		if year, ok := digitsValue(runes[i : i+4]); ok && year >= 1900 && year <= 2099 {
+			matches = append(matches, pwMatch{i: i, j: i + 4, pattern: PasswordWarningDates, guesses: 200})
+		}
+		if i+8 > n {
+			continue
+		}
+		if _, ok := digitsValue(runes[i : i+8]); !ok {
+			continue
+		}
+		head, _ := digitsValue(runes[i : i+4])
+		tail, _ := digitsValue(runes[i+4 : i+8])
+		if (head >= 1900 && head <= 2099 && validMonthDay(tail)) || (tail >= 1900 && tail <= 2099 && (validMonthDay(head) || validMonthDay(head%100*100+head/100))) {
+			matches = append(matches, pwMatch{i: i, j: i + 8, pattern: PasswordWarningDates, guesses: 200 * 365})
+		}
+	}
+	return matches
+}
+
+// validMonthDay 判断 MMDD 形式的四位数是否为合法月日
+func validMonthDay(v int) bool {
+	month, day := v/100, v%100
+	return month >= 1 && month <= 12 && day >= 1 && day <= 31
+}
+
+// digitsValue 全为 ASCII 数字时返回其数值
+func digitsValue(runes []rune) (int, bool) {
+	v := 0
+	for _, r := range runes {
+		if r < '0' || r > '9' {
+			return 0, false
+		}
+		v = v*10 + int(r-'0')
+	}
+	return v, true
+}
+
+func sameCharClass(a, b rune) bool {
+	return (unicode.IsDigit(a) && unicode.IsDigit(b)) || (unicode.IsLower(a) && unicode.IsLower(b))
+}
+
+func reverseRunes(r []rune) []rune {
+	out := make([]rune, len(r))
+	for i, c := range r {
+		out[len(r)-1-i] = c
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEstimatePasswordStrength(t *testing.T) {
+	userInputs := []string{"alice", "alice.wong@example.com"}
+
+	tests := []struct {
+		name        string
+		password    string
+		wantMin     int
+		wantMax     int
+		wantWarning string
+	}{
+		{"random", "kX9#mQ2$vL7&nR4!", 4, 4, ""},
+		{"passphrase", "Tv8#quartz-Lumen-Otter", 4, 4, ""},
+		{"keyboard walk", "Qwertyuiop123456!", 0, 2, PasswordWarningKeyboard},
+		{"single char repeat", "aaaaaaaaaaaaaaaa1A!", 0, 2, PasswordWarningRepeat},
+		{"repeated username and year", "Alice2024!Alice2024!", 0, 2, PasswordWarningRepeat},
+		{"common word with date", "19900101Password!", 0, 2, PasswordWarningCommon},
+		{"l33t common word", "P@ssw0rd!Summer2023", 0, 2, PasswordWarningCommon},
+		{"email in password", "alice.wong@example.com1", 0, 2, PasswordWarningUserInputs},
+		{"reversed username", "ecila-ecila-ECILA-1", 0, 2, PasswordWarningRepeat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EstimatePasswordStrength(tt.password, userInputs...)
+			if got.Score < tt.wantMin || got.Score > tt.wantMax {
+				t.Errorf("Score = %d (log10 %.2f), want %d..%d", got.Score, got.GuessesLog10, tt.wantMin, tt.wantMax)
+			}
+			if got.Feedback.Warning != tt.wantWarning {
+				t.Errorf("Warning = %q, want %q", got.Feedback.Warning, tt.wantWarning)
+			}
+			if got.Score < PasswordMinScore && !slices.Contains(got.Feedback.Suggestions, PasswordSuggestAddWords) {
+				t.Errorf("weak password should suggest %s, got %v", PasswordSuggestAddWords, got.Feedback.Suggestions)
+			}
+		})
+	}
+}
+
+func TestEstimatePasswordStrengthPenalizesUserInputs(t *testing.T) {
+	password := "Wongfamily#Alice88"
+	without := EstimatePasswordStrength(password)
+	with := EstimatePasswordStrength(password, "alice", "alice.wong@example.com")
+	if with.GuessesLog10 >= without.GuessesLog10 {
+		t.Errorf("user inputs should lower the estimate: with=%.2f without=%.2f", with.GuessesLog10, without.GuessesLog10)
+	}
+	if with.Score >= PasswordMinScore {
+		t.Errorf("Score = %d, want below %d when built from username and email", with.Score, PasswordMinScore)
+	}
+	if !slices.Contains(with.Feedback.Suggestions, PasswordSuggestAvoidPersonal) {
+		t.Errorf("Suggestions = %v, want %s", with.Feedback.Suggestions, PasswordSuggestAvoidPersonal)
+	}
+}
+
+func TestEstimatePasswordStrengthLongInput(t *testing.T) {
+	// 最长 64 字符的高度重复输入也应快速完成
+	got := EstimatePasswordStrength(strings.Repeat("ab", 32))
+	if got.Score != 1 || got.Feedback.Warning != PasswordWarningRepeat {
+		t.Errorf("got %+v", got)
+	}
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录 B 的 SHA1 测试向量（8 位码取低 6 位）
+func TestTOTPCodeAtRFC6238Vectors(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tc := range cases {
+		got, err := TOTPCodeAt(secret, TOTPStep(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCodeAt(%d) error = %v", tc.unix, err)
+		}
+		if got != tc.want {
+			t.Errorf("TOTPCodeAt(%d) = %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+}
+
+func TestVerifyTOTPWindowAndReplay(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() error = %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	step := TOTPStep(now)
+
+	prev, _ := TOTPCodeAt(secret, step-1)
+	if got, ok := VerifyTOTP(secret, prev, now, 0); !ok || got != step-1 {
+		t.Errorf("previous step code should be accepted, got step=%d ok=%v", got, ok)
+	}
+
+	old, _ := TOTPCodeAt(secret, step-2)
+	if _, ok := VerifyTOTP(secret, old, now, 0); ok {
+		t.Error("code outside skew window should be rejected")
+	}
+
+	cur, _ := TOTPCodeAt(secret, step)
+	if _, ok := VerifyTOTP(secret, cur, now, step); ok {
+		t.Error("code for an already used step should be rejected")
+	}
+
+	if _, ok := VerifyTOTP(secret, "12345", now, 0); ok {
+		t.Error("short code should be rejected")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("Nebula Account", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/Nebula%20Account:alice@example.com?") {
+		t.Fatalf("unexpected uri prefix: %s", uri)
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+	q := u.Query()
+	if q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "Nebula Account" || q.Get("digits") != "6" {
+		t.Errorf("unexpected query: %v", q)
+	}
+}
+
+func TestIsTOTPCodeFormat(t *testing.T) {
+	if !IsTOTPCodeFormat("012345") {
+		t.Error("6 digits should be TOTP format")
+	}
+	for _, s := range []string{"", "12345", "1234567", "abcdef", "abcde-fghij"} {
+		if IsTOTPCodeFormat(s) {
+			t.Errorf("%q should not be TOTP format", s)
+		}
+	}
+}
@@ -26,6 +26,8 @@ const (
 	ErrPasswordNoNumber  = "PASSWORD_NO_NUMBER"
 	ErrPasswordNoSpecial = "PASSWORD_NO_SPECIAL"
 	ErrPasswordNoCase    = "PASSWORD_NO_CASE"
+	ErrPasswordBreached  = "PASSWORD_BREACHED"
+	ErrPasswordTooWeak   = "PASSWORD_TOO_WEAK"
 )
 
 const (
@@ -289,7 +291,7 @@ func isBlockedHost(hostname string) bool {
 
 	ip := net.ParseIP(hostname)
 	if ip != nil {
-		return isBlockedIP(ip)
+		return IsBlockedIP(ip)
 	}
 
 	ips, err := net.LookupIP(hostname)
@@ -299,7 +301,7 @@ func isBlockedHost(hostname string) bool {
 	}
 
 	for _, resolvedIP := range ips {
-		if isBlockedIP(resolvedIP) {
+		if IsBlockedIP(resolvedIP) {
 			LogWarn("VALIDATOR", "Blocked domain pointing to internal IP", "hostname", hostname, "ip", resolvedIP.String())
 			return true
 		}
@@ -308,8 +310,8 @@ func isBlockedHost(hostname string) bool {
 	return false
 }
 
-// isBlockedIP 检查单个 IP 是否为受限的内网或本地地址
-func isBlockedIP(ip net.IP) bool {
+// IsBlockedIP 检查单个 IP 是否为受限的内网或本地地址（服务端发起的出站请求不得访问）
+func IsBlockedIP(ip net.IP) bool {
 	if ip.IsLoopback() {
 		return true
 	}
@@ -0,0 +1,234 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var (
+	ErrWebAuthnMalformed        = errors.New("webauthn: malformed data")
+	ErrWebAuthnUnsupportedKey   = errors.New("webauthn: unsupported public key")
+	ErrWebAuthnInvalidSignature = errors.New("webauthn: invalid signature")
+)
+
+// 认证器数据标志位（WebAuthn Level 2 §6.1）
+const (
+	WebAuthnFlagUserPresent      byte = 0x01
+	WebAuthnFlagUserVerified     byte = 0x04
+	WebAuthnFlagBackupEligible   byte = 0x08
+	WebAuthnFlagBackedUp         byte = 0x10
+	WebAuthnFlagAttestedCredData byte = 0x40
+	WebAuthnFlagExtensionData    byte = 0x80
+)
+
+// COSE 算法标识（本服务支持的子集）
+const (
+	COSEAlgES256 int64 = -7
+	COSEAlgEdDSA int64 = -8
+	COSEAlgRS256 int64 = -257
+)
+
+// WebAuthnSupportedAlgorithms 注册时向浏览器声明的算法（按优先级）
+var WebAuthnSupportedAlgorithms = []int64{COSEAlgES256, COSEAlgEdDSA, COSEAlgRS256}
+
+// WebAuthnClientData 解析后的 clientDataJSON
+type WebAuthnClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// WebAuthnAuthData 解析后的认证器数据
+type WebAuthnAuthData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte // 仅注册时存在
+	CredentialID []byte // 仅注册时存在
+	PublicKey    []byte // COSE_Key 原始字节，仅注册时存在
+}
+
+// HasFlag 判断是否设置了指定标志位
+func (d *WebAuthnAuthData) HasFlag(flag byte) bool {
+	return d.Flags&flag != 0
+}
+
+// DecodeWebAuthnBase64 解码 base64url（兼容带填充与标准 base64 字符集）
+func DecodeWebAuthnBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	s = strings.NewReplacer("+", "-", "/", "_").Replace(s)
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// EncodeWebAuthnBase64 编码为无填充 base64url
+func EncodeWebAuthnBase64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParseWebAuthnClientData 解析 clientDataJSON
+func ParseWebAuthnClientData(raw []byte) (*WebAuthnClientData, error) {
+	var cd WebAuthnClientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return nil, fmt.Errorf("%w: client data: %v", ErrWebAuthnMalformed, err)
+	}
+	return &cd, nil
+}
+
+// ParseWebAuthnAuthData 解析认证器数据（rpIdHash | flags | signCount | [attestedCredentialData] | [extensions]）
+func ParseWebAuthnAuthData(data []byte) (*WebAuthnAuthData, error) {
+	if len(data) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrWebAuthnMalformed)
+	}
+
+	ad := &WebAuthnAuthData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+
+	if !ad.HasFlag(WebAuthnFlagAttestedCredData) {
+		return ad, nil
+	}
+
+	rest := data[37:]
+	if len(rest) < 18 {
+		return nil, fmt.Errorf("%w: attested credential data too short", ErrWebAuthnMalformed)
+	}
+	ad.AAGUID = rest[:16]
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+		return nil, fmt.Errorf("%w: invalid credential id length", ErrWebAuthnMalformed)
+	}
+	ad.CredentialID = rest[:idLen]
+	rest = rest[idLen:]
+
+	// 公钥为 CBOR 编码的 COSE_Key，其后可能紧跟扩展数据
+	_, after, err := DecodeCBOR(rest)
+	if err != nil {
+		return nil, fmt.Errorf("%w: credential public key: %v", ErrWebAuthnMalformed, err)
+	}
+	ad.PublicKey = rest[:len(rest)-len(after)]
+
+	return ad, nil
+}
+
+// ParseWebAuthnAttestation 解析 attestationObject，返回认证器数据与证明格式。
+// 本服务注册时声明 attestation=none，不校验证明声明（attStmt），仅使用其中的凭据公钥。
+func ParseWebAuthnAttestation(attestationObject []byte) (*WebAuthnAuthData, string, error) {
+	decoded, _, err := DecodeCBOR(attestationObject)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: attestation object: %v", ErrWebAuthnMalformed, err)
+	}
+	obj, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: attestation object is not a map", ErrWebAuthnMalformed)
+	}
+
+	format, _ := obj["fmt"].(string)
+	rawAuthData, ok := obj["authData"].([]byte)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: missing authData", ErrWebAuthnMalformed)
+	}
+
+	authData, err := ParseWebAuthnAuthData(rawAuthData)
+	if err != nil {
+		return nil, "", err
+	}
+	if !authData.HasFlag(WebAuthnFlagAttestedCredData) || authData.PublicKey == nil {
+		return nil, "", fmt.Errorf("%w: missing attested credential data", ErrWebAuthnMalformed)
+	}
+
+	return authData, format, nil
+}
+
+// ParseCOSEPublicKey 将 COSE_Key 转换为 Go 公钥，返回公钥与算法标识
+func ParseCOSEPublicKey(coseKey []byte) (crypto.PublicKey, int64, error) {
+	decoded, _, err := DecodeCBOR(coseKey)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrWebAuthnUnsupportedKey, err)
+	}
+	m, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: key is not a map", ErrWebAuthnUnsupportedKey)
+	}
+
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == COSEAlgES256:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, fmt.Errorf("%w: invalid P-256 key", ErrWebAuthnUnsupportedKey)
+		}
+		pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{0x04}, x...), y...))
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", ErrWebAuthnUnsupportedKey, err)
+		}
+		return pub, alg, nil
+
+	case kty == 1 && alg == COSEAlgEdDSA:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, fmt.Errorf("%w: invalid Ed25519 key", ErrWebAuthnUnsupportedKey)
+		}
+		return ed25519.PublicKey(x), alg, nil
+
+	case kty == 3 && alg == COSEAlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, fmt.Errorf("%w: invalid RSA key", ErrWebAuthnUnsupportedKey)
+		}
+		exp := new(big.Int).SetBytes(e)
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, alg, nil
+	}
+
+	return nil, 0, fmt.Errorf("%w: kty=%d alg=%d", ErrWebAuthnUnsupportedKey, kty, alg)
+}
+
+// VerifyWebAuthnSignature 使用 COSE 公钥校验断言签名（签名数据为 authenticatorData || SHA-256(clientDataJSON)）
+func VerifyWebAuthnSignature(coseKey, authData, clientDataJSON, signature []byte) error {
+	pub, alg, err := ParseCOSEPublicKey(coseKey)
+	if err != nil {
+		return err
+	}
+
+	clientHash := sha256.Sum256(clientDataJSON)
+	signed := make([]byte, 0, len(authData)+len(clientHash))
+	signed = append(signed, authData...)
+	signed = append(signed, clientHash[:]...)
+
+	var ok bool
+	switch alg {
+	case COSEAlgES256:
+		digest := sha256.Sum256(signed)
+		ok = ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), digest[:], signature)
+	case COSEAlgEdDSA:
+		ok = ed25519.Verify(pub.(ed25519.PublicKey), signed, signature)
+	case COSEAlgRS256:
+		digest := sha256.Sum256(signed)
+		ok = rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	}
+
+	if !ok {
+		return ErrWebAuthnInvalidSignature
+	}
+	return nil
+}
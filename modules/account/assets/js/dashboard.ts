@@ -8,12 +8,14 @@
  * - 微软账户绑定/解绑
  * - 修改密码
  * - 删除账户（需验证码和密码确认）
+ * - 模拟登录横幅（超级管理员以用户身份查看时）
  * - 登出
  */
 
 // ==================== 模块导入 ====================
 import { initLanguageSwitcher, updatePageTitle, hidePageLoader, waitForTranslations } from '../../../../shared/js/utils/language-switcher.ts';
 import { initPublicNoticeBanner } from './lib/policy/public-notice.ts';
+import { initImpersonationBanner } from './lib/ui/impersonation-banner.ts';
 import { checkPolicyConsent } from './lib/policy/policy-consent.ts';
 import { verifySession, logout } from './lib/api/auth.ts';
 import { fetchApi } from './lib/api/fetch.ts';
@@ -24,6 +26,7 @@ import { validateAvatarUrl, validatePassword } from './lib/validators.ts';
 import { startCountdown, resumeCountdown, clearCountdown } from './lib/utils/countdown.ts';
 import { isMobileDevice } from '../../../../shared/js/utils/device.ts';
 import { QRCanvas, QRCamera, frameLoop } from '../../../../shared/js/lib/vendor.ts';
+import { scopeDisplayName, getCsrfToken, type ConsentInfo } from './lib/oauth/consent.ts';
 import type { User, PcInfo } from '../../../../shared/js/types/auth.ts';
 
 // 翻译函数（动态获取，确保 translations.js 加载后也能正确翻译）
@@ -294,6 +297,7 @@ function showAvatarModal(user: User, onSuccess: (newAvatarUrl: string) => void):
       showAlert(t('dashboard.avatarUpdateSuccess'));
     } else {
       const errorMessages: Record<string, string> = {
+        'IMPERSONATION_FORBIDDEN': 'error.impersonationForbidden',
         'INVALID_IMAGE_URL': 'dashboard.invalidImageUrl',
         'INVALID_URL': 'dashboard.invalidUrl',
         'URL_TOO_LONG': 'dashboard.invalidUrl'
@@ -326,7 +330,7 @@ function showAvatarModal(user: User, onSuccess: (newAvatarUrl: string) => void):
         body: JSON.stringify({ avatar_url: 'microsoft' })
       });
       if (!result.success) {
-        errorEl!.textContent = t('dashboard.avatarUpdateFailed');
+        errorEl!.textContent = t(result.errorCode === 'IMPERSONATION_FORBIDDEN' ? 'error.impersonationForbidden' : 'dashboard.avatarUpdateFailed');
         errorEl!.classList.remove('is-hidden');
         return;
       }
@@ -350,7 +354,7 @@ function showAvatarModal(user: User, onSuccess: (newAvatarUrl: string) => void):
       onSuccess(result.avatar_url);
       showAlert(t('dashboard.avatarUpdateSuccess'));
     } else {
-      errorEl!.textContent = t('dashboard.avatarUpdateFailed');
+      errorEl!.textContent = t(result.errorCode === 'IMPERSONATION_FORBIDDEN' ? 'error.impersonationForbidden' : 'dashboard.avatarUpdateFailed');
       errorEl!.classList.remove('is-hidden');
     }
   };
@@ -386,10 +390,15 @@ document.addEventListener('DOMContentLoaded', async () => {
       return;
     }
 
+    // 模拟登录会话不代替用户同意政策（后端也会拒绝）
+    const impersonation = sessionResult.data.impersonation;
+
     // 检查政策同意状态（拒绝则阻断，弹窗内已登出并跳转登录页）
-    const consented = await checkPolicyConsent(t);
-    if (!consented) {
-      return;
+    if (!impersonation) {
+      const consented = await checkPolicyConsent(t);
+      if (!consented) {
+        return;
+      }
     }
 
     // 隐藏页面加载遮罩
@@ -399,6 +408,9 @@ document.addEventListener('DOMContentLoaded', async () => {
     const mainEl = document.querySelector('.dashboard-main');
     if (mainEl) {
       initPublicNoticeBanner(mainEl as HTMLElement);
+      if (impersonation) {
+        initImpersonationBanner(mainEl as HTMLElement, impersonation);
+      }
     }
 
     const user = { current: sessionResult.data };
@@ -414,8 +426,11 @@ document.addEventListener('DOMContentLoaded', async () => {
       'error:microsoft_already_linked': 'dashboard.microsoftAlreadyLinked',
       'success:google_linked': 'dashboard.linkSuccessGoogle',
       'error:google_already_linked': 'dashboard.googleAlreadyLinked',
+      'success:identity_linked': 'dashboard.linkSuccessProvider',
+      'error:identity_already_linked': 'dashboard.identityAlreadyLinked',
       'error:session_expired': 'error.sessionExpired',
-      'error:user_banned': 'error.userBanned'
+      'error:user_banned': 'error.userBanned',
+      'error:impersonation_forbidden': 'error.impersonationForbidden'
     };
     const key = success ? `success:${success}` : error ? `error:${error}` : '';
     if (messages[key]) {
@@ -658,6 +673,105 @@ document.addEventListener('DOMContentLoaded', async () => {
       });
     }
 
+    // ==================== 通用 OIDC 账户绑定/解绑 ====================
+
+    let oidcIdentities: OidcIdentity[] = [];
+
+    /**
+     * 渲染通用 OIDC Provider 绑定项（插入在 Google 绑定项之后）
+     */
+    function renderOidcIdentities(): void {
+      document.querySelectorAll('.oidc-link-item').forEach(el => el.remove());
+
+      let anchor: Element | null = googleLinkItem;
+      for (const identity of oidcIdentities) {
+        const item = document.createElement('div');
+        item.className = 'info-item clickable oidc-link-item';
+        item.innerHTML = `
+          <div class="info-icon"></div>
+          <div class="info-content">
+            <span class="info-label"></span>
+            <span class="info-value ${identity.linked ? 'is-linked' : 'is-not-linked'}"></span>
+          </div>
+          <div class="info-arrow">
+            <svg viewBox="0 0 24 24" fill="currentColor"><path d="M8.59 16.59L13.17 12 8.59 7.41 10 6l6 6-6 6-1.41-1.41z"/></svg>
+          </div>
+        `;
+
+        const iconEl = item.querySelector('.info-icon');
+        if (iconEl) {
+          if (identity.icon_url) {
+            const img = document.createElement('img');
+            img.src = identity.icon_url;
+            img.alt = identity.display_name;
+            img.width = 24;
+            img.height = 24;
+            iconEl.appendChild(img);
+          } else {
+            iconEl.innerHTML = OIDC_DEFAULT_ICON;
+          }
+        }
+        const labelEl = item.querySelector('.info-label');
+        if (labelEl) { labelEl.textContent = t('dashboard.providerAccount').replace('{provider}', identity.display_name); }
+        const valueEl = item.querySelector('.info-value');
+        if (valueEl) {
+          valueEl.textContent = identity.linked ? (identity.account_name || t('dashboard.linked')) : t('dashboard.notLinked');
+        }
+
+        item.addEventListener('click', () => { void toggleOidcIdentity(identity); });
+
+        if (anchor) {
+          anchor.after(item);
+          anchor = item;
+        }
+      }
+    }
+
+    /**
+     * 加载当前用户的通用 OIDC 绑定状态
+     */
+    async function loadOidcIdentities(): Promise<void> {
+      const result = await fetchApi<{ identities: OidcIdentity[] }>('/api/user/identities');
+      if (!result.success || !result.data) { return; }
+      oidcIdentities = result.data.identities || [];
+      renderOidcIdentities();
+      requestAnimationFrame(() => requestAnimationFrame(adjustInfoListHeight));
+    }
+
+    /**
+     * 绑定或解绑通用 OIDC Provider 账户
+     */
+    async function toggleOidcIdentity(identity: OidcIdentity): Promise<void> {
+      const basePath = `/api/auth/oidc/${encodeURIComponent(identity.name)}`;
+
+      if (identity.linked) {
+        // 解绑流程
+        const confirmed = await showConfirm(
+          t('dashboard.confirmUnlinkProvider').replace('{provider}', identity.display_name),
+          t('dashboard.unlinkThirdParty')
+        );
+        if (!confirmed) { return; }
+
+        const result = await fetchApi(`${basePath}/unlink`, { method: 'POST' });
+        if (result.success) {
+          await loadOidcIdentities();
+          showAlert(t('dashboard.unlinkSuccessProvider').replace('{provider}', identity.display_name));
+        } else {
+          showAlert(t('dashboard.unlinkFailed'));
+        }
+      } else {
+        // 绑定流程
+        const confirmed = await showConfirm(
+          t('dashboard.confirmLinkProvider').replace('{provider}', identity.display_name),
+          t('dashboard.linkThirdParty')
+        );
+        if (!confirmed) { return; }
+        window.location.href = `${basePath}?action=link`;
+      }
+    }
+
+    void loadOidcIdentities();
+
     // 更新页面标题
     updatePageTitle();
 
@@ -684,6 +798,7 @@ document.addEventListener('DOMContentLoaded', async () => {
       // 语言切换后重新应用微软账户状态和按钮文本
       updateMicrosoftStatus(!!user.current.microsoft_id, user.current.microsoft_name || null);
       updateGoogleStatus(!!user.current.google_id, user.current.google_name || null);
+      renderOidcIdentities();
       // 语言切换后重新应用封禁状态
       updateBannedDisplay();
       // 触发高度过渡动画
@@ -917,7 +1032,9 @@ function showDeleteAccountModal(): void {
       });
     } else {
       sendCodeBtn!.disabled = false;
-      if (result.errorCode === 'CAPTCHA_FAILED') {
+      if (result.errorCode === 'IMPERSONATION_FORBIDDEN') {
+        showAlert(t('error.impersonationForbidden'));
+      } else if (result.errorCode === 'CAPTCHA_FAILED') {
         showAlert(t('register.humanVerifyFailed'));
       } else if (result.errorCode === 'RATE_LIMIT') {
         showAlert(t('error.rateLimitExceeded'));
@@ -1000,7 +1117,9 @@ function showDeleteAccountModal(): void {
         window.location.href = '/account/login';
       }, 1500);
     } else {
-      if (result.errorCode === 'INVALID_CODE' || result.errorCode === 'CODE_EXPIRED') {
+      if (result.errorCode === 'IMPERSONATION_FORBIDDEN') {
+        showAlert(t('error.impersonationForbidden'));
+      } else if (result.errorCode === 'INVALID_CODE' || result.errorCode === 'CODE_EXPIRED') {
         codeError!.textContent = t(result.errorCode === 'CODE_EXPIRED' ? 'dashboard.codeExpired' : 'dashboard.invalidCode');
         codeError!.classList.remove('is-hidden');
         codeInput!.classList.add('is-error');
@@ -1161,13 +1280,21 @@ function showChangePasswordModal(): void {
         logout();
       }, 1500);
     } else {
-      if (result.errorCode === 'WRONG_PASSWORD') {
+      if (result.errorCode === 'IMPERSONATION_FORBIDDEN') {
+        showAlert(t('error.impersonationForbidden'));
+      } else if (result.errorCode === 'WRONG_PASSWORD') {
         currentPasswordError!.textContent = t('dashboard.wrongPassword');
         currentPasswordError!.classList.remove('is-hidden');
         currentPasswordInput!.classList.add('is-error');
       } else if (result.errorCode === 'SAME_PASSWORD') {
         confirmPasswordError!.textContent = t('dashboard.samePassword');
         confirmPasswordError!.classList.remove('is-hidden');
+      } else if (result.errorCode === 'PASSWORD_BREACHED') {
+        confirmPasswordError!.textContent = t('register.passwordBreached');
+        confirmPasswordError!.classList.remove('is-hidden');
+      } else if (result.errorCode === 'PASSWORD_TOO_WEAK') {
+        confirmPasswordError!.textContent = t('register.passwordTooWeak');
+        confirmPasswordError!.classList.remove('is-hidden');
       } else if (result.errorCode === 'CAPTCHA_FAILED') {
         showAlert(t('register.humanVerifyFailed'));
       } else if (result.errorCode === 'NETWORK_ERROR') {
@@ -1323,7 +1450,9 @@ function showChangeUsernameModal(user: User, onSuccess: (newUsername: string) =>
       onSuccess(result.username);
       showAlert(t('dashboard.usernameUpdateSuccess'));
     } else {
-      if (result.errorCode === 'USERNAME_ALREADY_EXISTS') {
+      if (result.errorCode === 'IMPERSONATION_FORBIDDEN') {
+        showAlert(t('error.impersonationForbidden'));
+      } else if (result.errorCode === 'USERNAME_ALREADY_EXISTS') {
         usernameError!.textContent = t('register.usernameExists');
         usernameError!.classList.remove('is-hidden');
         usernameInput!.classList.add('is-error');
@@ -1465,12 +1594,41 @@ async function showQrScanModal(onClose: () => void): Promise<void> {
     return true;
   }
 
+  /**
+   * 解析设备授权二维码（verification_uri_complete），仅接受本站 /account/device 链接
+   */
+  function parseDeviceUserCode(data: string): string | null {
+    let url: URL;
+    try {
+      url = new URL(data);
+    } catch {
+      return null;
+    }
+    if (url.origin !== window.location.origin || url.pathname !== '/account/device') { return null; }
+    return url.searchParams.get('user_code');
+  }
+
   /**
    * 处理扫描到的二维码
    */
   async function handleQrCodeScanned(data: string): Promise<void> {
     if (controller.isCleanedUp()) { return; }
 
+    const userCode = parseDeviceUserCode(data);
+    if (userCode) {
+      updateStatus('dashboard.scanQrProcessing', 'normal');
+      const infoResult = await fetchApi<{ data: ConsentInfo }>(`/oauth/device/info?user_code=${encodeURIComponent(userCode)}`);
+      if (infoResult.success && infoResult.data) {
+        controller.close();
+        showDeviceAuthorizeConfirmModal(userCode, infoResult.data);
+      } else {
+        console.error('[QR-SCAN] Device authorization lookup failed:', infoResult.errorCode);
+        updateStatus('dashboard.scanQrInvalid', 'error');
+        setTimeout(() => controller.close(), 2000);
+      }
+      return;
+    }
+
     if (!isValidQrToken(data)) {
       console.warn('[QR-SCAN] Invalid QR code format, rejected');
       updateStatus('dashboard.scanQrInvalid', 'error');
@@ -1644,6 +1802,72 @@ function showQrLoginConfirmModal(token: string, pcInfo: PcInfo): void {
   controller.open();
 }
 
+// ==================== 扫码设备授权确认弹窗 ====================
+
+/**
+ * 显示设备授权确认弹窗（扫描 CLI / 电视等设备上显示的授权二维码）
+ */
+function showDeviceAuthorizeConfirmModal(userCode: string, info: ConsentInfo): void {
+  const appEl = document.getElementById('device-authorize-app');
+  const codeEl = document.getElementById('device-authorize-code');
+  const scopesEl = document.getElementById('device-authorize-scopes');
+  const confirmBtn = document.getElementById('device-authorize-confirm-btn') as HTMLButtonElement | null;
+  const cancelBtn = document.getElementById('device-authorize-cancel-btn') as HTMLButtonElement | null;
+
+  const controller = createModalController({
+    modalId: 'device-authorize-confirm-modal',
+    confirmBtnId: 'device-authorize-confirm-btn',
+    cancelBtnId: 'device-authorize-cancel-btn',
+    closeOnOverlay: false  // 点击遮罩层不关闭，需要明确选择
+  });
+
+  if (!controller.modal) { return; }
+
+  if (appEl) { appEl.textContent = info.clientName || '-'; }
+  if (codeEl) { codeEl.textContent = userCode.toUpperCase(); }
+  if (scopesEl) { scopesEl.textContent = info.scopes.map(scope => scopeDisplayName(scope, t)).join(', ') || '-'; }
+
+  if (confirmBtn) { confirmBtn.disabled = false; }
+  if (cancelBtn) { cancelBtn.disabled = false; }
+
+  // 授权
+  controller.onConfirm(async () => {
+    if (confirmBtn) { confirmBtn.disabled = true; }
+
+    const result = await fetchApi('/oauth/device', {
+      method: 'POST',
+      headers: { 'X-CSRF-Token': getCsrfToken() },
+      body: JSON.stringify({ user_code: userCode, decision: 'approve' })
+    });
+
+    if (result.success) {
+      controller.close();
+      showAlert(t('dashboard.deviceAuthorizeSuccess'));
+    } else {
+      if (confirmBtn) { confirmBtn.disabled = false; }
+      if (result.errorCode === 'NETWORK_ERROR') {
+        showAlert(t('error.networkError'));
+      } else if (result.errorCode === 'SERVER_ERROR') {
+        showAlert(t('error.serverError'));
+      } else {
+        showAlert(t('dashboard.deviceAuthorizeFailed'));
+      }
+    }
+  });
+
+  // 拒绝
+  controller.onCancel(async () => {
+    if (cancelBtn) { cancelBtn.disabled = true; }
+
+    await fetchApi('/oauth/device', {
+      method: 'POST',
+      headers: { 'X-CSRF-Token': getCsrfToken() },
+      body: JSON.stringify({ user_code: userCode, decision: 'deny' })
+    });
+  });
+
+  controller.open();
+}
 
 // ==================== 操作日志弹窗 ====================
 
@@ -1654,6 +1878,8 @@ interface UserLogItem {
   details?: {
     old_username?: string;
     new_username?: string;
+    old_email?: string;
+    new_email?: string;
     old_avatar_url?: string;
     new_avatar_url?: string;
     provider?: string;
@@ -1661,10 +1887,26 @@ interface UserLogItem {
     microsoft_name?: string;
     google_id?: string;
     google_name?: string;
+    provider_name?: string;
+    ip?: string;
   };
   created_at: string;
 }
 
+/** /api/user/identities 返回的通用 OIDC Provider 绑定状态 */
+interface OidcIdentity {
+  name: string;
+  display_name: string;
+  icon_url?: string;
+  linked: boolean;
+  account_name?: string;
+  email?: string;
+  linked_at?: string;
+}
+
+// 未配置图标的通用 OIDC Provider 使用的默认钥匙图标
+const OIDC_DEFAULT_ICON = '<svg viewBox="0 0 24 24" fill="currentColor"><path d="M12.65 10C11.83 7.67 9.61 6 7 6c-3.31 0-6 2.69-6 6s2.69 6 6 6c2.61 0 4.83-1.67 5.65-4H17v4h4v-4h2v-4H12.65zM7 14c-1.1 0-2-.9-2-2s.9-2 2-2 2 .9 2 2-.9 2-2 2z"/></svg>';
+
 /**
  * 获取操作对应的图标 SVG
  */
@@ -1682,6 +1924,10 @@ function getLogActionIcon(action: string): { svg: string; type: 'normal' | 'dang
       svg: '<svg viewBox="0 0 24 24" fill="currentColor"><path d="M12 12c2.21 0 4-1.79 4-4s-1.79-4-4-4-4 1.79-4 4 1.79 4 4 4zm0 2c-2.67 0-8 1.34-8 4v2h16v-2c0-2.66-5.33-4-8-4z"/></svg>',
       type: 'normal'
     },
+    change_email: {
+      svg: '<svg viewBox="0 0 24 24" fill="currentColor"><path d="M20 4H4c-1.1 0-1.99.9-1.99 2L2 18c0 1.1.9 2 2 2h16c1.1 0 2-.9 2-2V6c0-1.1-.9-2-2-2zm0 4l-8 5-8-5V6l8 5 8-5v2z"/></svg>',
+      type: 'normal'
+    },
     change_avatar: {
       svg: '<svg viewBox="0 0 24 24" fill="currentColor"><path d="M21 19V5c0-1.1-.9-2-2-2H5c-1.1 0-2 .9-2 2v14c0 1.1.9 2 2 2h14c1.1 0 2-.9 2-2zM8.5 13.5l2.5 3.01L14.5 12l4.5 6H5l3.5-4.5z"/></svg>',
       type: 'normal'
@@ -1702,6 +1948,14 @@ function getLogActionIcon(action: string): { svg: string; type: 'normal' | 'dang
       svg: '<img src="{{CDN_URL}}/images/logo/google/Symbol.svg" alt="Google" width="20" height="20">',
       type: 'danger'
     },
+    link_identity: {
+      svg: OIDC_DEFAULT_ICON,
+      type: 'success'
+    },
+    unlink_identity: {
+      svg: OIDC_DEFAULT_ICON,
+      type: 'danger'
+    },
     enable_avatar_sync: {
       svg: '<svg viewBox="0 0 24 24" fill="currentColor"><path d="M12 4V1L8 5l4 4V6c3.31 0 6 2.69 6 6 0 1.01-.25 1.97-.7 2.8l1.46 1.46C19.54 15.03 20 13.57 20 12c0-4.42-3.58-8-8-8zm0 14c-3.31 0-6-2.69-6-6 0-1.01.25-1.97.7-2.8L5.24 7.74C4.46 8.97 4 10.43 4 12c0 4.42 3.58 8 8 8v3l4-4-4-4v3z"/></svg>',
       type: 'success'
@@ -1734,6 +1988,11 @@ function formatLogDetails(action: string, details?: UserLogItem['details']): str
         return `${escapeHtml(details.old_username)} → ${escapeHtml(details.new_username)}`;
       }
       break;
+    case 'change_email':
+      if (details.old_email && details.new_email) {
+        return `${escapeHtml(details.old_email)} → ${escapeHtml(details.new_email)}`;
+      }
+      break;
     case 'link_microsoft':
     case 'unlink_microsoft':
       if (details.microsoft_name) {
@@ -1748,6 +2007,18 @@ function formatLogDetails(action: string, details?: UserLogItem['details']): str
         return escapeHtml(details.google_name);
       }
       break;
+    case 'link_identity':
+    case 'unlink_identity':
+      if (details.provider_name && details.provider) {
+        return `${escapeHtml(details.provider_name)} (${escapeHtml(details.provider)})`;
+      }
+      break;
+    case 'secure_account':
+    case 'login_failed':
+      if (details.ip) {
+        return escapeHtml(details.ip);
+      }
+      break;
   }
   return '';
 }
@@ -0,0 +1,247 @@
+/**
+ * assets/js/device.ts
+ * OAuth 设备授权页面逻辑（RFC 8628）
+ *
+ * 功能：
+ * - 输入设备上显示的代码（或由 verification_uri_complete 预填）
+ * - 显示第三方应用请求的权限
+ * - 用户授权或拒绝，结果提示回到设备继续
+ * - 错误通过弹窗提示
+ */
+
+// ==================== 模块导入 ====================
+import { initLanguageSwitcher, updatePageTitle, hidePageLoader, waitForTranslations } from '../../../../shared/js/utils/language-switcher.ts';
+import { initPublicNoticeBanner } from './lib/policy/public-notice.ts';
+import { checkPolicyConsent } from './lib/policy/policy-consent.ts';
+import { showAlert as showAlertBase } from './lib/ui/feedback.ts';
+import { fetchApi } from './lib/api/fetch.ts';
+import { adjustCardHeight, delayedExecution, enableCardAutoResize } from './lib/ui/card.ts';
+import { getUrlParameter } from './lib/utils/url.ts';
+import { renderScopes, setUserAvatar, getCsrfToken, type ConsentInfo, type ScopeDescriptions } from './lib/oauth/consent.ts';
+
+// 翻译函数（动态获取，确保 translations.js 加载后也能正确翻译）
+const t = (key: string): string => window.t ? window.t(key) : key;
+
+// 错误消息映射
+const errorMessages: Record<string, string> = {
+  'invalid_request': 'oauth.error.invalidRequest',
+  'invalid_user_code': 'oauth.error.invalidUserCode',
+  'invalid_client': 'oauth.error.invalidClient',
+  'server_error': 'oauth.error.serverError',
+  'unauthorized': 'oauth.error.unauthorized',
+  'NETWORK_ERROR': 'error.networkError'
+};
+
+// ==================== 弹窗封装 ====================
+
+/**
+ * 显示提示弹窗
+ */
+function showAlert(message: string): void {
+  showAlertBase(message, '', t);
+}
+
+/**
+ * 显示错误弹窗
+ */
+function showError(errorCode: string): void {
+  const messageKey = errorMessages[errorCode] || 'oauth.error.unknown';
+  showAlert(t(messageKey));
+}
+
+// ==================== 页面状态 ====================
+
+let currentUserCode = '';
+let currentScopes: string[] = [];
+let currentScopeDescriptions: ScopeDescriptions = {};
+let resultKey = '';
+
+/**
+ * 切换显示的步骤
+ */
+function showStep(step: 'code' | 'consent' | 'result'): void {
+  document.getElementById('code-step')?.classList.toggle('is-hidden', step !== 'code');
+  document.getElementById('consent-step')?.classList.toggle('is-hidden', step !== 'consent');
+  document.getElementById('result-step')?.classList.toggle('is-hidden', step !== 'result');
+  document.getElementById('device-subtitle')?.classList.toggle('is-hidden', step === 'result');
+
+  const card = document.querySelector('.card') as HTMLElement | null;
+  if (card) { delayedExecution(() => adjustCardHeight(card)); }
+}
+
+/**
+ * 未登录时跳转登录页，登录后带着当前代码回到本页
+ */
+function redirectToLogin(userCode: string): void {
+  const returnPath = '/account/device' + (userCode ? '?user_code=' + encodeURIComponent(userCode) : '');
+  window.location.href = '/account/login?return=' + encodeURIComponent(returnPath);
+}
+
+// ==================== 业务逻辑 ====================
+
+/**
+ * 按代码查询设备授权请求并进入确认步骤
+ */
+async function lookupUserCode(userCode: string): Promise<void> {
+  const result = await fetchApi<{ data: ConsentInfo }>(`/oauth/device/info?user_code=${encodeURIComponent(userCode)}`, {
+    skipAuthRedirect: true
+  });
+
+  if (!result.success) {
+    if (result.errorCode === 'SESSION_EXPIRED') {
+      redirectToLogin(userCode);
+      return;
+    }
+    showError(result.errorCode || 'server_error');
+    return;
+  }
+
+  if (!result.data) {
+    showError('server_error');
+    return;
+  }
+
+  const { clientName, clientDescription, scopes, scopeDescriptions, username, userAvatar } = result.data;
+
+  currentUserCode = userCode;
+  currentScopes = scopes;
+  currentScopeDescriptions = scopeDescriptions || {};
+
+  const appNameEl = document.getElementById('app-name');
+  const appDescEl = document.getElementById('app-desc');
+  const userNameEl = document.getElementById('user-name');
+  if (appNameEl) appNameEl.textContent = clientName;
+  if (appDescEl) appDescEl.textContent = clientDescription || '';
+  if (userNameEl) userNameEl.textContent = username;
+
+  setUserAvatar(userAvatar || '', username);
+  renderScopes(document.getElementById('scope-list'), scopes, currentScopeDescriptions, t);
+
+  showStep('consent');
+}
+
+/**
+ * 提交授权决定
+ */
+async function submitDecision(decision: 'approve' | 'deny'): Promise<boolean> {
+  const result = await fetchApi('/oauth/device', {
+    method: 'POST',
+    headers: { 'X-CSRF-Token': getCsrfToken() },
+    body: JSON.stringify({ user_code: currentUserCode, decision }),
+    skipAuthRedirect: true
+  });
+
+  if (!result.success) {
+    if (result.errorCode === 'SESSION_EXPIRED') {
+      redirectToLogin(currentUserCode);
+      return false;
+    }
+    showError(result.errorCode || 'server_error');
+    return false;
+  }
+
+  resultKey = decision === 'approve' ? 'oauth.device.approved' : 'oauth.device.denied';
+  const resultEl = document.getElementById('result-message');
+  if (resultEl) resultEl.textContent = t(resultKey);
+  showStep('result');
+  return true;
+}
+
+// ==================== 页面初始化 ====================
+
+document.addEventListener('DOMContentLoaded', async () => {
+  try {
+    // 等待翻译加载完成
+    await waitForTranslations();
+
+    // 获取卡片元素
+    const card = document.querySelector('.card') as HTMLElement | null;
+
+    // 初始化公示期横幅（插入到 .card 之前）
+    if (card) {
+      initPublicNoticeBanner(card, 'beforebegin');
+    }
+
+    // 初始化语言切换器
+    initLanguageSwitcher(() => {
+      updatePageTitle();
+      if (currentScopes.length > 0) {
+        renderScopes(document.getElementById('scope-list'), currentScopes, currentScopeDescriptions, t);
+      }
+      const resultEl = document.getElementById('result-message');
+      if (resultEl && resultKey) resultEl.textContent = t(resultKey);
+      if (card) { delayedExecution(() => adjustCardHeight(card)); }
+    });
+
+    // 启用卡片自动调整大小
+    if (card) { enableCardAutoResize(card); }
+
+    // 更新页面标题
+    updatePageTitle();
+
+    // 检查政策同意状态（拒绝则阻断，弹窗内已登出并跳转登录页）
+    const consented = await checkPolicyConsent(t);
+    if (!consented) {
+      return;
+    }
+
+    // ==================== 表单与按钮事件 ====================
+
+    const codeForm = document.getElementById('code-step') as HTMLFormElement | null;
+    const codeInput = document.getElementById('user-code-input') as HTMLInputElement | null;
+    const continueBtn = document.getElementById('continue-btn') as HTMLButtonElement | null;
+    const authorizeBtn = document.getElementById('authorize-btn') as HTMLButtonElement | null;
+    const denyBtn = document.getElementById('deny-btn') as HTMLButtonElement | null;
+
+    // 输入代码后继续
+    if (codeForm && codeInput) {
+      codeForm.addEventListener('submit', async (e) => {
+        e.preventDefault();
+        const userCode = codeInput.value.trim().toUpperCase();
+        if (!userCode) return;
+
+        if (continueBtn) continueBtn.disabled = true;
+        try {
+          await lookupUserCode(userCode);
+        } catch {
+          showAlert(t('error.networkError'));
+        } finally {
+          if (continueBtn) continueBtn.disabled = false;
+        }
+      });
+    }
+
+    // 授权 / 拒绝（失败时恢复按钮，便于重试）
+    const decide = async (decision: 'approve' | 'deny'): Promise<void> => {
+      if (authorizeBtn) authorizeBtn.disabled = true;
+      if (denyBtn) denyBtn.disabled = true;
+      try {
+        if (await submitDecision(decision)) return;
+      } catch {
+        showAlert(t('error.networkError'));
+      }
+      if (authorizeBtn) authorizeBtn.disabled = false;
+      if (denyBtn) denyBtn.disabled = false;
+    };
+    authorizeBtn?.addEventListener('click', () => { void decide('approve'); });
+    denyBtn?.addEventListener('click', () => { void decide('deny'); });
+
+    // verification_uri_complete 预填代码时直接查询
+    const prefilled = getUrlParameter('user_code');
+    if (prefilled && codeInput) {
+      codeInput.value = prefilled.toUpperCase();
+      try {
+        await lookupUserCode(codeInput.value);
+      } catch {
+        showAlert(t('error.networkError'));
+      }
+    }
+
+    hidePageLoader();
+
+  } catch (error) {
+    console.error('[DEVICE] ERROR: Page initialization failed:', (error as Error).message);
+    hidePageLoader();
+    showAlert(t('error.networkError'));
+  }
+});
@@ -47,6 +47,8 @@ const resetPasswordErrorMap: Record<string, string> = {
   'CODE_EXPIRED': 'forgotPassword.codeExpired',
   'USER_NOT_FOUND': 'forgotPassword.emailNotFound',
   'SAME_PASSWORD': 'forgotPassword.samePassword',
+  'PASSWORD_BREACHED': 'register.passwordBreached',
+  'PASSWORD_TOO_WEAK': 'register.passwordTooWeak',
   'RESET_FAILED': 'forgotPassword.resetFailed',
   'NETWORK_ERROR': 'error.networkError',
   'SERVER_ERROR': 'error.serverError'
@@ -4,14 +4,15 @@
  * 功能：
  * - 发送验证码
  * - 用户注册
- * - 用户登录
+ * - 用户登录（含两步验证）
  * - 会话验证
  * - 登出
+ * - 新密码强度检测
  * - 错误码映射
  */
 
 import { fetchApi } from './fetch.ts';
-import type { User, RegisterFormData, AuthResponse, SendCodeResponse } from '../../../../../../shared/js/types/auth.ts';
+import type { User, RegisterFormData, AuthResponse, LoginResponse, TwoFactorChallenge, SendCodeResponse } from '../../../../../../shared/js/types/auth.ts';
 
 // ==================== API 调用 ====================
 
@@ -62,14 +63,14 @@ export async function register(formData: RegisterFormData): Promise<{ success: t
 }
 
 /**
- * 用户登录
+ * 用户登录（已启用两步验证时返回挑战，需再调用 loginTwoFactor）
  */
 export async function login(
   email: string,
   password: string,
   captchaToken: string
-): Promise<AuthResponse> {
-  const result = await fetchApi<{ data: User }>('/api/auth/login', {
+): Promise<LoginResponse> {
+  const result = await fetchApi<{ data: User | TwoFactorChallenge }>('/api/auth/login', {
     method: 'POST',
     body: JSON.stringify({
       email: email,
@@ -78,6 +79,25 @@ export async function login(
     })
   });
 
+  if (result.success) {
+    if ('twoFactorRequired' in result.data && result.data.twoFactorRequired) {
+      return { success: true, twoFactor: result.data };
+    }
+    return { success: true, data: result.data as User };
+  } else {
+    return { success: false, errorCode: result.errorCode };
+  }
+}
+
+/**
+ * 两步验证登录：提交挑战与 TOTP 验证码（或恢复码）
+ */
+export async function loginTwoFactor(challenge: string, code: string): Promise<AuthResponse> {
+  const result = await fetchApi<{ data: User }>('/api/auth/login/2fa', {
+    method: 'POST',
+    body: JSON.stringify({ challenge, code })
+  });
+
   if (result.success) {
     return { success: true, data: result.data };
   } else {
@@ -110,6 +130,47 @@ export async function logout(): Promise<void> {
   window.location.href = '/account/login';
 }
 
+/** 密码强度反馈（warning / suggestions 为代码，前端按 passwordStrength.* 翻译） */
+export interface PasswordStrengthFeedback {
+  warning?: string;
+  suggestions?: string[];
+}
+
+/** 密码强度检测结果 */
+export interface PasswordStrengthResult {
+  /** 评分 0-4，低于 3 不被接受 */
+  score: number;
+  feedback: PasswordStrengthFeedback;
+  /** 出现在泄露密码库中 */
+  breached: boolean;
+  /** 是否满足服务端筛查要求 */
+  acceptable: boolean;
+}
+
+/**
+ * 检测新密码强度（用户名、邮箱参与扣分），失败时返回 null
+ */
+export async function checkPasswordStrength(
+  password: string,
+  username: string = '',
+  email: string = ''
+): Promise<PasswordStrengthResult | null> {
+  const result = await fetchApi<PasswordStrengthResult>('/api/auth/password-strength', {
+    method: 'POST',
+    body: JSON.stringify({ password, username, email })
+  });
+
+  if (!result.success) {
+    return null;
+  }
+  return {
+    score: result.score,
+    feedback: result.feedback || {},
+    breached: result.breached,
+    acceptable: result.acceptable
+  };
+}
+
 // ==================== 错误码映射 ====================
 
 /**
@@ -157,11 +218,20 @@ export const errorCodeMap: Record<string, string> = {
   'PASSWORD_NO_CASE': 'register.passwordCase',
   'WRONG_PASSWORD': 'error.wrongPassword',
   'SAME_PASSWORD': 'error.samePassword',
+  'PASSWORD_BREACHED': 'register.passwordBreached',
+  'PASSWORD_TOO_WEAK': 'register.passwordTooWeak',
 
   // 注册/登录
   'REGISTER_FAILED': 'register.failed',
   'INVALID_CREDENTIALS': 'login.invalidCredentials',
   'LOGIN_FAILED': 'login.failed',
+  'PASSWORD_RESET_REQUIRED': 'login.passwordResetRequired',
+  'ACCOUNT_LOCKED': 'login.accountLocked',
+  'LOGIN_DELAYED': 'login.loginDelayed',
+
+  // 两步验证
+  'TWO_FACTOR_CHALLENGE_EXPIRED': 'login.twoFactorExpired',
+  'INVALID_TWO_FACTOR_CODE': 'login.twoFactorInvalid',
 
   // 会话相关
   'NO_TOKEN': 'error.sessionExpired',
@@ -181,6 +251,7 @@ export const errorCodeMap: Record<string, string> = {
   'DELETE_FAILED': 'error.deleteFailed',
   'RESET_FAILED': 'error.resetFailed',
   'INVALID_AVATAR_URL': 'error.invalidAvatarUrl',
+  'IMPERSONATION_FORBIDDEN': 'error.impersonationForbidden',
 
   // OAuth 相关
   'OAUTH_NOT_CONFIGURED': 'error.oauthNotConfigured',
@@ -0,0 +1,124 @@
+/**
+ * OAuth 授权确认 UI 模块（授权码页面、设备授权页面、扫码确认弹窗共用）
+ *
+ * 功能：
+ * - scope 名称与描述（内置 scope 走翻译文件，自定义 scope 走后端多语言描述）
+ * - 权限列表渲染
+ * - 用户头像
+ * - CSRF Token 读取
+ */
+
+import { getCurrentLanguage } from '../../../../../../shared/js/utils/language-switcher.ts';
+import { escapeHtml } from '../../../../../../shared/js/utils/escape-html.ts';
+
+// ==================== 类型定义 ====================
+
+type TranslateFunction = (key: string) => string;
+
+/** 自定义 scope 的多语言描述：scope 名称 → 语言 → 描述 */
+export type ScopeDescriptions = Record<string, Record<string, string>>;
+
+/** /oauth/authorize/info 与 /oauth/device/info 的返回数据 */
+export interface ConsentInfo {
+  clientName: string;
+  clientDescription?: string;
+  scopes: string[];
+  scopeDescriptions?: ScopeDescriptions;
+  username: string;
+  userAvatar?: string;
+}
+
+// ==================== 常量 ====================
+
+// 内置 scope（名称与描述由翻译文件提供）
+const BUILTIN_SCOPES = ['openid', 'profile', 'email', 'roles', 'groups'];
+
+// 自定义 scope 描述缺失当前语言时的回退顺序（与后端 OAuthScope.Description 一致）
+const SCOPE_FALLBACK_LANGUAGES = ['en', 'zh-CN'];
+
+// Scope 图标映射（custom 用于管理员定义的 scope）
+const scopeIcons: Record<string, string> = {
+  openid: '<svg viewBox="0 0 24 24" fill="currentColor"><path d="M12 2C6.48 2 2 6.48 2 12s4.48 10 10 10 10-4.48 10-10S17.52 2 12 2zm0 3c1.66 0 3 1.34 3 3s-1.34 3-3 3-3-1.34-3-3 1.34-3 3-3zm0 14.2c-2.5 0-4.71-1.28-6-3.22.03-1.99 4-3.08 6-3.08 1.99 0 5.97 1.09 6 3.08-1.29 1.94-3.5 3.22-6 3.22z"/></svg>',
+  profile: '<svg viewBox="0 0 24 24" fill="currentColor"><path d="M12 12c2.21 0 4-1.79 4-4s-1.79-4-4-4-4 1.79-4 4 1.79 4 4 4zm0 2c-2.67 0-8 1.34-8 4v2h16v-2c0-2.66-5.33-4-8-4z"/></svg>',
+  email: '<svg viewBox="0 0 24 24" fill="currentColor"><path d="M20 4H4c-1.1 0-1.99.9-1.99 2L2 18c0 1.1.9 2 2 2h16c1.1 0 2-.9 2-2V6c0-1.1-.9-2-2-2zm0 4l-8 5-8-5V6l8 5 8-5v2z"/></svg>',
+  roles: '<svg viewBox="0 0 24 24" fill="currentColor"><path d="M12 1L3 5v6c0 5.55 3.84 10.74 9 12 5.16-1.26 9-6.45 9-12V5l-9-4zm0 10.99h7c-.53 4.12-3.28 7.79-7 8.94V12H5V6.3l7-3.11v8.8z"/></svg>',
+  groups: '<svg viewBox="0 0 24 24" fill="currentColor"><path d="M16 11c1.66 0 2.99-1.34 2.99-3S17.66 5 16 5c-1.66 0-3 1.34-3 3s1.34 3 3 3zm-8 0c1.66 0 2.99-1.34 2.99-3S9.66 5 8 5C6.34 5 5 6.34 5 8s1.34 3 3 3zm0 2c-2.33 0-7 1.17-7 3.5V19h14v-2.5c0-2.33-4.67-3.5-7-3.5zm8 0c-.29 0-.62.02-.97.05 1.16.84 1.97 1.97 1.97 3.45V19h6v-2.5c0-2.33-4.67-3.5-7-3.5z"/></svg>',
+  custom: '<svg viewBox="0 0 24 24" fill="currentColor"><path d="M12.65 10C11.83 7.67 9.61 6 7 6c-3.31 0-6 2.69-6 6s2.69 6 6 6c2.61 0 4.83-1.67 5.65-4H17v4h4v-4h2v-4H12.65zM7 14c-1.1 0-2-.9-2-2s.9-2 2-2 2 .9 2 2-.9 2-2 2z"/></svg>'
+};
+
+// ==================== scope 展示 ====================
+
+/**
+ * 获取自定义 scope 在当前语言下的描述，缺失时依次回退到 en、zh-CN
+ */
+function customScopeDescription(descriptions: Record<string, string> | undefined): string {
+  if (!descriptions) return '';
+  for (const lang of [getCurrentLanguage(), ...SCOPE_FALLBACK_LANGUAGES]) {
+    if (descriptions[lang]) return descriptions[lang];
+  }
+  return '';
+}
+
+/**
+ * 获取 scope 的展示名称（内置 scope 翻译，自定义 scope 原样展示）
+ */
+export function scopeDisplayName(scope: string, t: TranslateFunction): string {
+  return BUILTIN_SCOPES.includes(scope) ? t(`oauth.scope.${scope}.name`) : scope;
+}
+
+/**
+ * 渲染权限列表
+ */
+export function renderScopes(scopeList: HTMLElement | null, scopes: string[], descriptions: ScopeDescriptions, t: TranslateFunction): void {
+  if (!scopeList) return;
+
+  scopeList.innerHTML = '';
+
+  for (const scope of scopes) {
+    const li = document.createElement('li');
+    li.className = 'oauth-scope-item';
+
+    const builtin = BUILTIN_SCOPES.includes(scope);
+    const icon = builtin ? scopeIcons[scope] : scopeIcons.custom;
+    const scopeName = scopeDisplayName(scope, t);
+    const scopeDesc = builtin ? t(`oauth.scope.${scope}.desc`) : customScopeDescription(descriptions[scope]);
+
+    li.innerHTML = `
+      <div class="oauth-scope-icon">${icon}</div>
+      <div class="oauth-scope-text">
+        <div class="oauth-scope-name">${escapeHtml(scopeName)}</div>
+        <div class="oauth-scope-desc">${escapeHtml(scopeDesc)}</div>
+      </div>
+    `;
+
+    scopeList.appendChild(li);
+  }
+}
+
+// ==================== 其他 ====================
+
+/**
+ * 设置用户头像
+ */
+export function setUserAvatar(avatarUrl: string, username: string): void {
+  const avatarEl = document.getElementById('user-avatar');
+  if (!avatarEl) return;
+
+  if (avatarUrl) {
+    const img = document.createElement('img');
+    img.src = avatarUrl;
+    img.alt = username;
+    avatarEl.textContent = '';
+    avatarEl.appendChild(img);
+  } else if (username) {
+    avatarEl.textContent = username.charAt(0).toUpperCase();
+  }
+}
+
+/**
+ * 获取 CSRF Token（Double Submit Cookie，由 /oauth 下的 GET 请求写入）
+ */
+export function getCsrfToken(): string {
+  const match = document.cookie.match(/(^|;)\s*csrf_token\s*=\s*([^;]+)/);
+  return match ? match[2] : '';
+}
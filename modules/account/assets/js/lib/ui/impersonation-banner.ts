@@ -0,0 +1,51 @@
+/**
+ * 模拟登录横幅（account 模块专用）
+ *
+ * 功能：
+ * - 超级管理员以用户身份查看时，在页面顶部提示当前为模拟会话
+ * - 结束模拟：通知后端记录日志并清除模拟 token，再用管理员自己的 refresh_token 恢复会话返回后台
+ */
+
+import { fetchApi } from '../api/fetch.ts';
+import { refreshSession } from '../../../../../../shared/js/utils/session-refresh.ts';
+import type { ImpersonationInfo } from '../../../../../../shared/js/types/auth.ts';
+
+/**
+ * 初始化模拟登录横幅
+ * @param target - 目标元素（插入到容器内部最前面）
+ * @param info - /api/auth/me 返回的模拟登录信息
+ */
+export function initImpersonationBanner(target: HTMLElement, info: ImpersonationInfo): void {
+  const t: (key: string) => string = window.t ?? ((k: string): string => k);
+
+  const banner = document.createElement('div');
+  banner.className = 'notice-banner is-closable impersonation-banner';
+
+  const content = document.createElement('div');
+  content.className = 'notice-banner__content';
+  const text = document.createElement('span');
+  text.textContent = t('impersonation.banner').replace('{admin}', info.impersonator_username || info.impersonator_uid);
+  content.appendChild(text);
+  banner.appendChild(content);
+
+  const stopBtn = document.createElement('button');
+  stopBtn.className = 'button-secondary';
+  stopBtn.setAttribute('data-i18n', 'impersonation.stop');
+  stopBtn.textContent = t('impersonation.stop');
+  stopBtn.addEventListener('click', async () => {
+    stopBtn.disabled = true;
+    await stopImpersonation();
+  });
+  banner.appendChild(stopBtn);
+
+  target.insertAdjacentElement('afterbegin', banner);
+}
+
+/**
+ * 结束模拟登录并返回后台；管理员会话无法恢复时跳转登录页
+ */
+async function stopImpersonation(): Promise<void> {
+  await fetchApi('/admin/api/impersonation/stop', { method: 'POST', skipAuthRedirect: true });
+  const outcome = await refreshSession();
+  window.location.href = outcome === 'fail' ? '/account/login' : '/admin';
+}
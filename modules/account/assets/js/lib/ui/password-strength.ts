@@ -0,0 +1,105 @@
+/**
+ * 密码强度提示模块
+ *
+ * 功能：
+ * - 输入新密码时防抖调用后端强度检测（泄露库比对 + 强度估算）
+ * - 显示评分条、警告与建议（代码经 passwordStrength.* 翻译）
+ */
+
+import { checkPasswordStrength, type PasswordStrengthResult } from '../api/auth.ts';
+import { escapeHtml } from '../../../../../../shared/js/utils/escape-html.ts';
+
+/** 翻译函数类型 */
+type TranslateFunction = (key: string) => string;
+
+/** 强度提示配置 */
+export interface PasswordStrengthMeterConfig {
+  /** 密码输入框 */
+  input: HTMLInputElement;
+  /** 提示容器 */
+  container: HTMLElement;
+  /** 返回参与扣分的个人信息（用户名、邮箱） */
+  getUserInputs?: () => { username?: string; email?: string };
+  /** 提示内容变化后回调（如调整卡片高度） */
+  onUpdate?: () => void;
+  t?: TranslateFunction;
+}
+
+/** 输入停止后多久发起检测（毫秒） */
+const CHECK_DEBOUNCE_MS = 400;
+
+/** 评分达到该值才会被服务端接受，与后端 utils.PasswordMinScore 保持一致 */
+const MIN_ACCEPTABLE_SCORE = 3;
+
+/**
+ * 渲染强度提示
+ */
+function renderStrength(container: HTMLElement, result: PasswordStrengthResult, t: TranslateFunction): void {
+  const level = result.acceptable ? 'is-strong' : (result.score >= MIN_ACCEPTABLE_SCORE - 1 ? 'is-fair' : 'is-weak');
+  const lines: string[] = [];
+  if (result.breached) {
+    lines.push(t('passwordStrength.breached'));
+  } else if (result.feedback.warning) {
+    lines.push(t(`passwordStrength.warning.${result.feedback.warning}`));
+  }
+  for (const suggestion of result.feedback.suggestions || []) {
+    lines.push(t(`passwordStrength.suggestion.${suggestion}`));
+  }
+
+  container.className = `password-strength ${level} score-${result.score}`;
+  container.innerHTML = `
+    <div class="password-strength__bar"><span></span></div>
+    <div class="password-strength__label">${escapeHtml(t(`passwordStrength.score.${result.score}`))}</div>
+    ${lines.map((line) => `<div class="password-strength__hint">${escapeHtml(line)}</div>`).join('')}
+  `;
+}
+
+/**
+ * 绑定密码强度提示，返回重置函数（清空输入或关闭弹窗时调用）
+ */
+export function attachPasswordStrengthMeter(config: PasswordStrengthMeterConfig): () => void {
+  const { input, container, getUserInputs, onUpdate } = config;
+  const t = config.t ?? ((key: string): string => window.t ? window.t(key) : key);
+
+  let timer: ReturnType<typeof setTimeout> | null = null;
+  // 丢弃过期响应：只渲染最后一次请求的结果
+  let seq = 0;
+
+  const reset = (): void => {
+    if (timer) {
+      clearTimeout(timer);
+      timer = null;
+    }
+    seq++;
+    const wasVisible = !container.classList.contains('is-hidden');
+    container.className = 'password-strength is-hidden';
+    container.innerHTML = '';
+    if (wasVisible) {onUpdate?.();}
+  };
+
+  input.addEventListener('input', () => {
+    if (timer) {clearTimeout(timer);}
+    const password = input.value;
+    if (!password) {
+      reset();
+      return;
+    }
+
+    timer = setTimeout(async () => {
+      timer = null;
+      const current = ++seq;
+      const { username = '', email = '' } = getUserInputs?.() ?? {};
+      const result = await checkPasswordStrength(password, username, email);
+      if (current !== seq) {return;}
+      // 检测失败（如超长、网络错误）时隐藏提示，提交时由服务端给出具体错误
+      if (!result) {
+        reset();
+        return;
+      }
+      renderStrength(container, result, t);
+      onUpdate?.();
+    }, CHECK_DEBOUNCE_MS);
+  });
+
+  return reset;
+}
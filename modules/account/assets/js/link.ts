@@ -1,9 +1,10 @@
 /**
  * assets/js/link.ts
- * 微软账户绑定确认页面逻辑
+ * 第三方账户绑定确认页面逻辑（Microsoft / Google / 通用 OIDC Provider）
  *
  * 功能：
- * - 显示待绑定的微软账户和已有账户信息
+ * - 按 ?provider= 读取对应 Provider 的待绑定信息
+ * - 显示待绑定的第三方账户和已有账户信息
  * - 用户确认后执行绑定操作
  * - 取消则返回登录页
  */
@@ -13,10 +14,14 @@ import { initLanguageSwitcher, updatePageTitle, hidePageLoader, waitForTranslati
 import { showAlert as showAlertBase } from './lib/ui/feedback.ts';
 import { adjustCardHeight, delayedExecution, enableCardAutoResize } from './lib/ui/card.ts';
 import { fetchApi } from './lib/api/fetch.ts';
+import { getUrlParameter } from './lib/utils/url.ts';
 
 // 翻译函数（动态获取，确保 translations.js 加载后也能正确翻译）
 const t = (key: string): string => window.t ? window.t(key) : key;
 
+// 允许的 Provider 路由段（与后端 ProviderSpec.RoutePath 一致），缺省为 microsoft 兼容旧链接
+const PROVIDER_PATTERN = /^(microsoft|google|oidc\/[a-z0-9][a-z0-9-]{0,31})$/;
+
 // ==================== 错误码映射 ====================
 
 /**
@@ -36,6 +41,8 @@ const confirmLinkErrorMap: Record<string, string> = {
   'INVALID_TOKEN': 'linkConfirm.invalidLink',
   'TOKEN_EXPIRED': 'linkConfirm.linkExpired',
   'MICROSOFT_ALREADY_LINKED': 'dashboard.microsoftAlreadyLinked',
+  'GOOGLE_ALREADY_LINKED': 'dashboard.googleAlreadyLinked',
+  'IDENTITY_ALREADY_LINKED': 'dashboard.identityAlreadyLinked',
   'USER_NOT_FOUND': 'error.sessionError',
   'USER_BANNED': 'linkConfirm.userBanned',
   'NETWORK_ERROR': 'error.networkError',
@@ -45,8 +52,9 @@ const confirmLinkErrorMap: Record<string, string> = {
 // ==================== 类型定义 =======================
 
 interface PendingLinkData {
-  microsoftName: string;
-  microsoftAvatar?: string;
+  providerLabel: string;
+  providerName: string;
+  providerAvatar?: string;
   username: string;
   userAvatar?: string;
 }
@@ -60,6 +68,22 @@ function showAlert(message: string): void {
   showAlertBase(message, '', t);
 }
 
+/**
+ * 在头像容器中显示图片，无图片时显示名称首字母
+ */
+function renderAvatar(el: HTMLElement | null, url: string | undefined, name: string): void {
+  if (!el) return;
+  if (url) {
+    const img = document.createElement('img');
+    img.src = url;
+    img.alt = name;
+    el.textContent = '';
+    el.appendChild(img);
+  } else if (name) {
+    el.textContent = name.charAt(0).toUpperCase();
+  }
+}
+
 // ==================== 页面初始化 ====================
 
 document.addEventListener('DOMContentLoaded', async () => {
@@ -82,14 +106,25 @@ document.addEventListener('DOMContentLoaded', async () => {
     // 更新页面标题
     updatePageTitle();
 
+    // 解析 Provider（非法值按无效链接处理，避免拼接任意路径）
+    const provider = getUrlParameter('provider') || 'microsoft';
+    if (!PROVIDER_PATTERN.test(provider)) {
+      hidePageLoader();
+      showAlert(t('linkConfirm.invalidLink'));
+      setTimeout(() => {
+        window.location.href = '/account/login';
+      }, 2000);
+      return;
+    }
+
     // 获取待绑定信息
-    const microsoftNameEl = document.getElementById('microsoft-name');
-    const microsoftAvatarEl = document.getElementById('microsoft-avatar');
+    const providerNameEl = document.getElementById('provider-name');
+    const providerAvatarEl = document.getElementById('provider-avatar');
     const userUsernameEl = document.getElementById('user-username');
     const userAvatarEl = document.getElementById('user-avatar');
 
     try {
-      const result = await fetchApi<{ data: PendingLinkData }>('/api/auth/microsoft/pending-link');
+      const result = await fetchApi<{ data: PendingLinkData }>(`/api/auth/${provider}/pending-link`);
 
       if (!result.success) {
         hidePageLoader();
@@ -109,37 +144,16 @@ document.addEventListener('DOMContentLoaded', async () => {
         return;
       }
 
-      const { microsoftName, microsoftAvatar, username, userAvatar } = result.data;
-
-      // 显示账户信息
-      if (microsoftNameEl) {microsoftNameEl.textContent = microsoftName || '-';}
-      if (userUsernameEl) {userUsernameEl.textContent = username || '-';}
+      const { providerLabel, providerName, providerAvatar, username, userAvatar } = result.data;
 
-      // 显示用户头像
-      if (userAvatarEl) {
-        if (userAvatar) {
-          const img = document.createElement('img');
-          img.src = userAvatar;
-          img.alt = username;
-          userAvatarEl.textContent = '';
-          userAvatarEl.appendChild(img);
-        } else if (username) {
-          userAvatarEl.textContent = username.charAt(0).toUpperCase();
-        }
+      // 显示账户信息（第三方账户名称后附 Provider 名称，区分同名账户来源）
+      if (providerNameEl) {
+        providerNameEl.textContent = providerName ? `${providerName} (${providerLabel})` : '-';
       }
+      if (userUsernameEl) {userUsernameEl.textContent = username || '-';}
 
-      // 显示微软账户头像
-      if (microsoftAvatarEl) {
-        if (microsoftAvatar) {
-          const img = document.createElement('img');
-          img.src = microsoftAvatar;
-          img.alt = microsoftName;
-          microsoftAvatarEl.textContent = '';
-          microsoftAvatarEl.appendChild(img);
-        } else if (microsoftName) {
-          microsoftAvatarEl.textContent = microsoftName.charAt(0).toUpperCase();
-        }
-      }
+      renderAvatar(userAvatarEl, userAvatar, username);
+      renderAvatar(providerAvatarEl, providerAvatar, providerName);
 
       // 数据全部渲染完成，隐藏 loading 遮罩
       hidePageLoader();
@@ -162,7 +176,7 @@ document.addEventListener('DOMContentLoaded', async () => {
       confirmBtn.addEventListener('click', async () => {
         confirmBtn.disabled = true;
 
-        const result = await fetchApi('/api/auth/microsoft/confirm-link', {
+        const result = await fetchApi(`/api/auth/${provider}/confirm-link`, {
           method: 'POST'
         });
 
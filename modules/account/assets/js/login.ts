@@ -4,18 +4,21 @@
  * 功能：
  * - 用户登录表单处理
  * - 人机验证（Turnstile/hCaptcha）
+ * - 两步验证（TOTP / 恢复码）
  * - OAuth 错误处理
+ * - 通用 OIDC Provider 登录按钮（按 /api/auth/providers 动态渲染）
  * - 会话检查（已登录自动跳转）
  */
 
-import { initializeModals, showAlert } from './lib/ui/feedback.ts';
+import { initializeModals, showAlert, createModalController } from './lib/ui/feedback.ts';
 import { adjustCardHeight, delayedExecution, enableCardAutoResize } from './lib/ui/card.ts';
 import { validateLoginForm } from './lib/validators.ts';
-import { login, errorCodeMap } from './lib/api/auth.ts';
+import { login, loginTwoFactor, errorCodeMap } from './lib/api/auth.ts';
 import { initLanguageSwitcher, waitForTranslations, updatePageTitle, hidePageLoader } from '../../../../shared/js/utils/language-switcher.ts';
 import { loadCaptchaConfig, getCaptchaSiteKey, initCaptcha, clearCaptcha, getCaptchaToken } from './lib/captcha.ts';
 import { initQrLogin } from './lib/qr.ts';
 import { checkPolicyConsent } from './lib/policy/policy-consent.ts';
+import { fetchApi } from './lib/api/fetch.ts';
 
 // ==================== 类型定义 ====================
 
@@ -24,6 +27,13 @@ interface PendingLoginData {
   password: string;
 }
 
+/** /api/auth/providers 返回的通用 OIDC Provider */
+interface OidcProvider {
+  name: string;
+  display_name: string;
+  icon_url?: string;
+}
+
 // ==================== 全局变量 ====================
 
 const t = (key: string): string => window.t ? window.t(key) : key;
@@ -34,6 +44,47 @@ let pendingLogin: PendingLoginData | null = null;
 
 // ==================== 工具函数 ====================
 
+// 未配置图标的 Provider 使用的默认钥匙图标
+const DEFAULT_PROVIDER_ICON = '<svg viewBox="0 0 24 24" width="20" height="20" fill="currentColor"><path d="M12.65 10C11.83 7.67 9.61 6 7 6c-3.31 0-6 2.69-6 6s2.69 6 6 6c2.61 0 4.83-1.67 5.65-4H17v4h4v-4h2v-4H12.65zM7 14c-1.1 0-2-.9-2-2s.9-2 2-2 2 .9 2 2-.9 2-2 2z"/></svg>';
+
+/**
+ * 渲染通用 OIDC Provider 登录按钮（插入在扫码登录按钮之前）
+ */
+async function renderOidcProviderButtons(): Promise<void> {
+  const container = document.querySelector('.oauth-buttons');
+  if (!container) return;
+
+  const result = await fetchApi<{ providers: OidcProvider[] }>('/api/auth/providers', { skipAuthRedirect: true });
+  if (!result.success || !result.data?.providers?.length) return;
+
+  const qrButton = document.getElementById('qr-login-btn');
+  for (const provider of result.data.providers) {
+    const link = document.createElement('a');
+    link.href = `/api/auth/oidc/${encodeURIComponent(provider.name)}`;
+    link.className = 'button-secondary';
+    link.dataset.provider = provider.name;
+    link.dataset.displayName = provider.display_name;
+
+    if (provider.icon_url) {
+      const img = document.createElement('img');
+      img.src = provider.icon_url;
+      img.alt = provider.display_name;
+      img.width = 20;
+      img.height = 20;
+      link.appendChild(img);
+    } else {
+      link.insertAdjacentHTML('beforeend', DEFAULT_PROVIDER_ICON);
+    }
+
+    const label = document.createElement('span');
+    label.className = 'oidc-provider-label';
+    label.textContent = t('login.providerLogin').replace('{provider}', provider.display_name);
+    link.appendChild(label);
+
+    container.insertBefore(link, qrButton);
+  }
+}
+
 /**
  * 重置验证码状态
  */
@@ -54,6 +105,78 @@ function resetCaptchaState(container: HTMLElement | null, card: HTMLElement | nu
   }
 }
 
+/**
+ * 登录成功后跳转（仅允许同源 return 参数）
+ */
+function redirectAfterLogin(): void {
+  const urlParams = new URLSearchParams(window.location.search);
+  const returnUrl = urlParams.get('return');
+  if (returnUrl) {
+    try {
+      const decodedUrl = decodeURIComponent(returnUrl);
+      const url = new URL(decodedUrl, window.location.origin);
+      if (url.origin === window.location.origin) {
+        window.location.href = decodedUrl;
+        return;
+      }
+    } catch {
+      // URL 解析失败，继续使用默认跳转
+    }
+  }
+  window.location.href = '/account/dashboard';
+}
+
+/**
+ * 弹出两步验证窗口，校验通过返回 true；取消或挑战失效返回 false
+ */
+function promptTwoFactor(challenge: string): Promise<boolean> {
+  const codeInput = document.getElementById('two-factor-code') as HTMLInputElement | null;
+  const confirmBtn = document.getElementById('two-factor-confirm-btn') as HTMLButtonElement | null;
+
+  return new Promise((resolve) => {
+    const controller = createModalController({
+      modalId: 'two-factor-modal',
+      confirmBtnId: 'two-factor-confirm-btn',
+      cancelBtnId: 'two-factor-cancel-btn',
+      closeOnOverlay: false,
+      onCleanup: () => {
+        if (codeInput) {codeInput.value = '';}
+        if (confirmBtn) {confirmBtn.disabled = false;}
+      }
+    });
+
+    controller.onCancel(() => resolve(false));
+    controller.onConfirm(async () => {
+      const code = codeInput?.value.trim() || '';
+      if (!code) {return;}
+
+      if (confirmBtn) {confirmBtn.disabled = true;}
+      const result = await loginTwoFactor(challenge, code);
+      if (result.success) {
+        controller.close();
+        resolve(true);
+        return;
+      }
+
+      const translationKey = errorCodeMap[result.errorCode || ''] || 'login.failed';
+      // 挑战已失效（超时或错误次数过多）需重新输入密码
+      if (result.errorCode !== 'INVALID_TWO_FACTOR_CODE') {
+        controller.close();
+        showAlertWithTranslation(t(translationKey));
+        resolve(false);
+        return;
+      }
+
+      showAlertWithTranslation(t(translationKey));
+      if (codeInput) {codeInput.value = '';}
+      if (confirmBtn) {confirmBtn.disabled = false;}
+    });
+
+    controller.open();
+    codeInput?.focus();
+  });
+}
+
 // ==================== 页面初始化 ====================
 
 document.addEventListener('DOMContentLoaded', async () => {
@@ -109,6 +232,14 @@ document.addEventListener('DOMContentLoaded', async () => {
         const result = await login(email, password, token || '');
 
         if (result.success) {
+          // 已启用两步验证：校验 TOTP / 恢复码后才签发会话
+          if ('twoFactor' in result) {
+            const verified = await promptTwoFactor(result.twoFactor.challenge);
+            if (!verified) {
+              return;
+            }
+          }
+
           // 检查政策同意状态（拒绝则阻断，弹窗内已登出并跳转）
           const consented = await checkPolicyConsent(t);
           if (!consented) {
@@ -116,21 +247,7 @@ document.addEventListener('DOMContentLoaded', async () => {
           }
 
           // token 已通过 httpOnly cookie 存储，跳转
-          const urlParams = new URLSearchParams(window.location.search);
-          const returnUrl = urlParams.get('return');
-          if (returnUrl) {
-            try {
-              const decodedUrl = decodeURIComponent(returnUrl);
-              const url = new URL(decodedUrl, window.location.origin);
-              if (url.origin === window.location.origin) {
-                window.location.href = decodedUrl;
-                return;
-              }
-            } catch {
-              // URL 解析失败，继续使用默认跳转
-            }
-          }
-          window.location.href = '/account/dashboard';
+          redirectAfterLogin();
         } else {
           const translationKey = errorCodeMap[result.errorCode || ''] || 'login.failed';
           showAlertWithTranslation(t(translationKey));
@@ -214,7 +331,14 @@ document.addEventListener('DOMContentLoaded', async () => {
       window.history.replaceState({}, document.title, window.location.pathname);
     }
 
-    // 更新"创建账户"、"忘记密码"和微软登录链接，携带 return 参数
+    // 渲染通用 OIDC Provider 登录按钮（失败不影响其他登录方式）
+    try {
+      await renderOidcProviderButtons();
+    } catch {
+      console.warn('[LOGIN] WARN: Failed to load OIDC providers');
+    }
+
+    // 更新"创建账户"、"忘记密码"和第三方登录链接，携带 return 参数
     const returnUrl = urlParams.get('return');
     if (returnUrl) {
       const createAccountLink = document.querySelector('.footer-links a[href="/account/register"]');
@@ -225,10 +349,9 @@ document.addEventListener('DOMContentLoaded', async () => {
       if (forgotPasswordLink) {
         forgotPasswordLink.setAttribute('href', '/account/forgot?return=' + encodeURIComponent(returnUrl));
       }
-      const microsoftLoginLink = document.querySelector('.oauth-buttons a[href="/api/auth/microsoft"]');
-      if (microsoftLoginLink) {
-        microsoftLoginLink.setAttribute('href', '/api/auth/microsoft?return=' + encodeURIComponent(returnUrl));
-      }
+      document.querySelectorAll('.oauth-buttons a[href^="/api/auth/"]').forEach((link) => {
+        link.setAttribute('href', link.getAttribute('href') + '?return=' + encodeURIComponent(returnUrl));
+      });
     }
 
     // 更新页面标题
@@ -244,6 +367,10 @@ document.addEventListener('DOMContentLoaded', async () => {
     initLanguageSwitcher(() => {
       initializeModals(t);
       updatePageTitle();
+      document.querySelectorAll<HTMLAnchorElement>('.oauth-buttons a[data-provider]').forEach((link) => {
+        const label = link.querySelector('.oidc-provider-label');
+        if (label) label.textContent = t('login.providerLogin').replace('{provider}', link.dataset.displayName || '');
+      });
       if (card) {delayedExecution(() => adjustCardHeight(card));}
     });
 
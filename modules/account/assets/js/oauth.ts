@@ -17,7 +17,7 @@ import { showAlert as showAlertBase } from './lib/ui/feedback.ts';
 import { fetchApi } from './lib/api/fetch.ts';
 import { adjustCardHeight, delayedExecution, enableCardAutoResize } from './lib/ui/card.ts';
 import { getUrlParameter } from './lib/utils/url.ts';
-import { escapeHtml } from '../../../../shared/js/utils/escape-html.ts';
+import { renderScopes, setUserAvatar, getCsrfToken, type ConsentInfo, type ScopeDescriptions } from './lib/oauth/consent.ts';
 
 // 翻译函数（动态获取，确保 translations.js 加载后也能正确翻译）
 const t = (key: string): string => window.t ? window.t(key) : key;
@@ -30,13 +30,6 @@ interface AuthorizePostResponse {
   redirect_url?: string;
 }
 
-// Scope 图标映射
-const scopeIcons: Record<string, string> = {
-  openid: '<svg viewBox="0 0 24 24" fill="currentColor"><path d="M12 2C6.48 2 2 6.48 2 12s4.48 10 10 10 10-4.48 10-10S17.52 2 12 2zm0 3c1.66 0 3 1.34 3 3s-1.34 3-3 3-3-1.34-3-3 1.34-3 3-3zm0 14.2c-2.5 0-4.71-1.28-6-3.22.03-1.99 4-3.08 6-3.08 1.99 0 5.97 1.09 6 3.08-1.29 1.94-3.5 3.22-6 3.22z"/></svg>',
-  profile: '<svg viewBox="0 0 24 24" fill="currentColor"><path d="M12 12c2.21 0 4-1.79 4-4s-1.79-4-4-4-4 1.79-4 4 1.79 4 4 4zm0 2c-2.67 0-8 1.34-8 4v2h16v-2c0-2.66-5.33-4-8-4z"/></svg>',
-  email: '<svg viewBox="0 0 24 24" fill="currentColor"><path d="M20 4H4c-1.1 0-1.99.9-1.99 2L2 18c0 1.1.9 2 2 2h16c1.1 0 2-.9 2-2V6c0-1.1-.9-2-2-2zm0 4l-8 5-8-5V6l8 5 8-5v2z"/></svg>'
-};
-
 // 错误消息映射
 const errorMessages: Record<string, string> = {
   'invalid_request': 'oauth.error.invalidRequest',
@@ -67,61 +60,6 @@ function showError(errorCode: string): void {
 
 // ==================== 辅助函数 ====================
 
-/**
- * 渲染权限列表
- */
-function renderScopes(scopes: string[]): void {
-  const scopeList = document.getElementById('scope-list');
-  if (!scopeList) return;
-
-  scopeList.innerHTML = '';
-
-  for (const scope of scopes) {
-    const li = document.createElement('li');
-    li.className = 'oauth-scope-item';
-
-    const icon = scopeIcons[scope] || scopeIcons.openid;
-    const scopeName = t(`oauth.scope.${scope}.name`);
-    const scopeDesc = t(`oauth.scope.${scope}.desc`);
-
-    li.innerHTML = `
-      <div class="oauth-scope-icon">${icon}</div>
-      <div class="oauth-scope-text">
-        <div class="oauth-scope-name">${escapeHtml(scopeName)}</div>
-        <div class="oauth-scope-desc">${escapeHtml(scopeDesc)}</div>
-      </div>
-    `;
-
-    scopeList.appendChild(li);
-  }
-}
-
-/**
- * 设置用户头像
- */
-function setUserAvatar(avatarUrl: string, username: string): void {
-  const avatarEl = document.getElementById('user-avatar');
-  if (!avatarEl) return;
-
-  if (avatarUrl) {
-    const img = document.createElement('img');
-    img.src = avatarUrl;
-    img.alt = username;
-    avatarEl.textContent = '';
-    avatarEl.appendChild(img);
-  } else if (username) {
-    avatarEl.textContent = username.charAt(0).toUpperCase();
-  }
-}
-
-/**
- * 获取 CSRF Token
- */
-function getCsrfToken(): string {
-  const match = document.cookie.match(/(^|;)\s*csrf_token\s*=\s*([^;]+)/);
-  return match ? match[2] : '';
-}
-
 /**
  * 提交授权决定
  * 使用 fetch() 替代表单提交，规避 CSP form-action 限制
@@ -134,6 +72,7 @@ async function submitDecision(decision: 'approve' | 'deny'): Promise<void> {
   const state = getUrlParameter('state');
   const codeChallenge = getUrlParameter('code_challenge');
   const codeChallengeMethod = getUrlParameter('code_challenge_method');
+  const nonce = getUrlParameter('nonce');
   const csrfToken = getCsrfToken();
 
   const params = new URLSearchParams();
@@ -143,6 +82,7 @@ async function submitDecision(decision: 'approve' | 'deny'): Promise<void> {
   if (state) params.append('state', state);
   if (codeChallenge) params.append('code_challenge', codeChallenge);
   if (codeChallengeMethod) params.append('code_challenge_method', codeChallengeMethod);
+  if (nonce) params.append('nonce', nonce);
   params.append('decision', decision);
   params.append('csrf_token', csrfToken);
 
@@ -182,6 +122,7 @@ async function submitDecision(decision: 'approve' | 'deny'): Promise<void> {
 // ==================== 页面初始化 ====================
 
 let currentScopes: string[] = [];
+let currentScopeDescriptions: ScopeDescriptions = {};
 
 document.addEventListener('DOMContentLoaded', async () => {
   try {
@@ -200,7 +141,7 @@ document.addEventListener('DOMContentLoaded', async () => {
     initLanguageSwitcher(() => {
       updatePageTitle();
       if (currentScopes.length > 0) {
-        renderScopes(currentScopes);
+        renderScopes(document.getElementById('scope-list'), currentScopes, currentScopeDescriptions, t);
       }
       if (card) { delayedExecution(() => adjustCardHeight(card)); }
     });
@@ -249,7 +190,7 @@ document.addEventListener('DOMContentLoaded', async () => {
         scope: scope
       });
 
-      const result = await fetchApi<{ data: { clientName: string; clientDescription?: string; scopes: string[]; username: string; userAvatar?: string } }>(`/oauth/authorize/info?${params.toString()}`);
+      const result = await fetchApi<{ data: ConsentInfo }>(`/oauth/authorize/info?${params.toString()}`);
 
       if (!result.success) {
         hidePageLoader();
@@ -263,10 +204,11 @@ document.addEventListener('DOMContentLoaded', async () => {
         return;
       }
 
-      const { clientName, clientDescription, scopes, username, userAvatar } = result.data;
+      const { clientName, clientDescription, scopes, scopeDescriptions, username, userAvatar } = result.data;
 
       // 保存 scopes 供语言切换时使用
       currentScopes = scopes;
+      currentScopeDescriptions = scopeDescriptions || {};
 
       // 显示应用信息
       if (appNameEl) appNameEl.textContent = clientName;
@@ -277,7 +219,7 @@ document.addEventListener('DOMContentLoaded', async () => {
       setUserAvatar(userAvatar || '', username);
 
       // 渲染权限列表
-      renderScopes(scopes);
+      renderScopes(document.getElementById('scope-list'), scopes, currentScopeDescriptions, t);
 
       // 数据全部渲染完成，隐藏 loading 遮罩
       hidePageLoader();
@@ -18,6 +18,7 @@ import { startCountdown, resumeCountdown, isCountingDown, clearCodeExpiryTimer,
 import { loadEmailWhitelist, validateEmail, getEmailProviders, isUsernameTooLong, validateRegisterForm } from './lib/validators.ts';
 import { loadCaptchaConfig, getCaptchaSiteKey, initCaptcha, clearCaptcha, getCaptchaToken } from './lib/captcha.ts';
 import { sendVerificationCode, register, verifySession, errorCodeMap } from './lib/api/auth.ts';
+import { attachPasswordStrengthMeter } from './lib/ui/password-strength.ts';
 import { initLanguageSwitcher, waitForTranslations, updatePageTitle, hidePageLoader } from '../../../../shared/js/utils/language-switcher.ts';
 
 // ==================== 全局变量 ====================
@@ -407,6 +408,18 @@ document.addEventListener('DOMContentLoaded', async () => {
       document.getElementById('req-case')?.classList.toggle('is-valid', /[A-Z]/.test(password) && /[a-z]/.test(password));
     });
 
+    // 服务端强度评分（泄露库 + 强度估算，用户名与邮箱参与扣分）
+    const strengthContainer = document.getElementById('register-password-strength');
+    if (strengthContainer) {
+      attachPasswordStrengthMeter({
+        input: passwordInput,
+        container: strengthContainer,
+        getUserInputs: () => ({ username: usernameInput.value.trim(), email: emailInput.value.trim() }),
+        onUpdate: () => { if (card) {delayedExecution(() => adjustCardHeight(card));} },
+        t
+      });
+    }
+
     // 注册表单提交
     document.getElementById('register-form')?.addEventListener('submit', handleRegister);
 
@@ -0,0 +1,151 @@
+/**
+ * "不是我本人" 页面逻辑
+ *
+ * 功能：
+ * - 读取新设备登录提醒邮件链接中的 token
+ * - 用户确认后登出可疑设备并要求重置密码
+ * - 成功后引导前往找回密码页面
+ * - 错误状态处理
+ */
+
+import { initLanguageSwitcher, applyTranslations, waitForTranslations, updatePageTitle, hidePageLoader } from '../../../../shared/js/utils/language-switcher.ts';
+import { adjustCardHeight, delayedExecution, enableCardAutoResize } from './lib/ui/card.ts';
+import { getHashParameter } from './lib/utils/url.ts';
+import { fetchApi } from './lib/api/fetch.ts';
+
+// 翻译函数（动态获取，确保 translations.js 加载后也能正确翻译）
+const t = (key: string): string => window.t ? window.t(key) : key;
+
+// ==================== 错误码映射 ====================
+
+/**
+ * 错误码到翻译键的映射
+ */
+const errorCodeMap: Record<string, string> = {
+  'NO_TOKEN': 'secure.errorNoToken',
+  'MISSING_TOKEN': 'secure.errorNoToken',
+  'INVALID_OR_EXPIRED_TOKEN': 'secure.errorInvalidToken',
+  'RATE_LIMIT': 'secure.errorRateLimit',
+  'NETWORK_ERROR': 'secure.errorNetwork'
+};
+
+// ==================== 状态管理 ====================
+
+/**
+ * 切换显示状态（loading 由 page-loader 统一处理）
+ */
+function showState(state: 'confirm' | 'success' | 'error', card: HTMLElement | null): void {
+  const confirmState = document.getElementById('confirm-state');
+  const successState = document.getElementById('success-state');
+  const errorState = document.getElementById('error-state');
+
+  if (confirmState) { confirmState.classList.toggle('is-hidden', state !== 'confirm'); }
+  if (successState) { successState.classList.toggle('is-hidden', state !== 'success'); }
+  if (errorState) { errorState.classList.toggle('is-hidden', state !== 'error'); }
+
+  if (card) {delayedExecution(() => adjustCardHeight(card));}
+}
+
+/**
+ * 显示错误状态
+ */
+function showError(errorCode: string, card: HTMLElement | null): void {
+  const translationKey = errorCodeMap[errorCode] || 'secure.errorDefault';
+  const errorMessage = t(translationKey);
+
+  const errorElement = document.getElementById('error-message') as HTMLElement | null;
+  if (errorElement) {
+    errorElement.textContent = errorMessage;
+    errorElement.dataset.errorCode = errorCode;
+  }
+  showState('error', card);
+}
+
+// ==================== API 调用 ====================
+
+/**
+ * 提交 token：登出可疑设备并要求重置密码
+ */
+async function secureAccount(token: string, card: HTMLElement | null): Promise<void> {
+  const secureBtn = document.getElementById('secure-btn') as HTMLButtonElement | null;
+  if (secureBtn) { secureBtn.disabled = true; }
+
+  try {
+    const result = await fetchApi('/api/auth/secure-account', {
+      method: 'POST',
+      body: JSON.stringify({ token })
+    });
+
+    if (result.success) {
+      showState('success', card);
+    } else {
+      showError(result.errorCode || 'SERVER_ERROR', card);
+    }
+  } catch (error) {
+    console.error('[SECURE] ERROR: Secure account failed:', (error as Error).message);
+    showError('NETWORK_ERROR', card);
+  } finally {
+    if (secureBtn) { secureBtn.disabled = false; }
+  }
+}
+
+// ==================== 页面初始化 ====================
+
+document.addEventListener('DOMContentLoaded', async () => {
+  try {
+    // 等待翻译系统就绪
+    await waitForTranslations();
+
+    const card = document.querySelector('.card') as HTMLElement | null;
+
+    // 初始化语言切换器
+    initLanguageSwitcher(() => {
+      applyTranslations();
+      updatePageTitle();
+
+      // 重新显示错误信息（如果有）
+      const errorMessage = document.getElementById('error-message') as HTMLElement | null;
+      if (errorMessage && errorMessage.dataset.errorCode) {
+        showError(errorMessage.dataset.errorCode, card);
+      }
+
+      if (card) {delayedExecution(() => adjustCardHeight(card));}
+    });
+
+    // 应用翻译
+    applyTranslations();
+    updatePageTitle();
+
+    // token 只保存在内存中，立即从地址栏移除，避免留在浏览历史
+    const token = getHashParameter('token');
+    if (token) {
+      window.history.replaceState({}, '', window.location.pathname + window.location.search);
+    }
+
+    document.getElementById('secure-btn')?.addEventListener('click', () => {
+      if (token) { void secureAccount(token, card); }
+    });
+    document.getElementById('cancel-btn')?.addEventListener('click', () => window.close());
+    document.getElementById('error-back-btn')?.addEventListener('click', () => window.close());
+    document.getElementById('reset-btn')?.addEventListener('click', () => {
+      window.location.href = '/account/forgot';
+    });
+
+    hidePageLoader();
+    if (token) {
+      showState('confirm', card);
+    } else {
+      showError('NO_TOKEN', card);
+    }
+
+    // 调整卡片高度
+    if (card) {
+      setTimeout(() => adjustCardHeight(card), 100);
+      enableCardAutoResize(card);
+    }
+  } catch (error) {
+    console.error('[SECURE] ERROR: Page initialization failed:', (error as Error).message);
+    hidePageLoader();
+    showError('NETWORK_ERROR', null);
+  }
+});
@@ -0,0 +1,152 @@
+/**
+ * 账户解锁页面逻辑
+ *
+ * 功能：
+ * - 读取账户锁定通知邮件链接中的 token
+ * - 用户确认后解除登录锁定
+ * - 成功后引导前往登录页面
+ * - 错误状态处理
+ */
+
+import { initLanguageSwitcher, applyTranslations, waitForTranslations, updatePageTitle, hidePageLoader } from '../../../../shared/js/utils/language-switcher.ts';
+import { adjustCardHeight, delayedExecution, enableCardAutoResize } from './lib/ui/card.ts';
+import { getHashParameter } from './lib/utils/url.ts';
+import { fetchApi } from './lib/api/fetch.ts';
+
+// 翻译函数（动态获取，确保 translations.js 加载后也能正确翻译）
+const t = (key: string): string => window.t ? window.t(key) : key;
+
+// ==================== 错误码映射 ====================
+
+/**
+ * 错误码到翻译键的映射
+ */
+const errorCodeMap: Record<string, string> = {
+  'NO_TOKEN': 'unlock.errorNoToken',
+  'MISSING_TOKEN': 'unlock.errorNoToken',
+  'INVALID_OR_EXPIRED_TOKEN': 'unlock.errorInvalidToken',
+  'RATE_LIMIT': 'unlock.errorRateLimit',
+  'NETWORK_ERROR': 'unlock.errorNetwork'
+};
+
+// ==================== 状态管理 ====================
+
+/**
+ * 切换显示状态（loading 由 page-loader 统一处理）
+ */
+function showState(state: 'confirm' | 'success' | 'error', card: HTMLElement | null): void {
+  const confirmState = document.getElementById('confirm-state');
+  const successState = document.getElementById('success-state');
+  const errorState = document.getElementById('error-state');
+
+  if (confirmState) { confirmState.classList.toggle('is-hidden', state !== 'confirm'); }
+  if (successState) { successState.classList.toggle('is-hidden', state !== 'success'); }
+  if (errorState) { errorState.classList.toggle('is-hidden', state !== 'error'); }
+
+  if (card) {delayedExecution(() => adjustCardHeight(card));}
+}
+
+/**
+ * 显示错误状态
+ */
+function showError(errorCode: string, card: HTMLElement | null): void {
+  const translationKey = errorCodeMap[errorCode] || 'unlock.errorDefault';
+  const errorMessage = t(translationKey);
+
+  const errorElement = document.getElementById('error-message') as HTMLElement | null;
+  if (errorElement) {
+    errorElement.textContent = errorMessage;
+    errorElement.dataset.errorCode = errorCode;
+  }
+  showState('error', card);
+}
+
+// ==================== API 调用 ====================
+
+/**
+ * 提交 token：解除登录锁定
+ */
+async function unlockAccount(token: string, card: HTMLElement | null): Promise<void> {
+  const unlockBtn = document.getElementById('unlock-btn') as HTMLButtonElement | null;
+  if (unlockBtn) { unlockBtn.disabled = true; }
+
+  try {
+    const result = await fetchApi('/api/auth/unlock', {
+      method: 'POST',
+      body: JSON.stringify({ token })
+    });
+
+    if (result.success) {
+      showState('success', card);
+    } else {
+      showError(result.errorCode || 'SERVER_ERROR', card);
+    }
+  } catch (error) {
+    console.error('[UNLOCK] ERROR: Unlock account failed:', (error as Error).message);
+    showError('NETWORK_ERROR', card);
+  } finally {
+    if (unlockBtn) { unlockBtn.disabled = false; }
+  }
+}
+
+// ==================== 页面初始化 ====================
+
+document.addEventListener('DOMContentLoaded', async () => {
+  try {
+    // 等待翻译系统就绪
+    await waitForTranslations();
+
+    const card = document.querySelector('.card') as HTMLElement | null;
+
+    // 初始化语言切换器
+    initLanguageSwitcher(() => {
+      applyTranslations();
+      updatePageTitle();
+
+      // 重新显示错误信息（如果有）
+      const errorMessage = document.getElementById('error-message') as HTMLElement | null;
+      if (errorMessage && errorMessage.dataset.errorCode) {
+        showError(errorMessage.dataset.errorCode, card);
+      }
+
+      if (card) {delayedExecution(() => adjustCardHeight(card));}
+    });
+
+    // 应用翻译
+    applyTranslations();
+    updatePageTitle();
+
+    // token 只保存在内存中，立即从地址栏移除，避免留在浏览历史
+    const token = getHashParameter('token');
+    if (token) {
+      window.history.replaceState({}, '', window.location.pathname + window.location.search);
+    }
+
+    document.getElementById('unlock-btn')?.addEventListener('click', () => {
+      if (token) { void unlockAccount(token, card); }
+    });
+    document.getElementById('error-back-btn')?.addEventListener('click', () => {
+      window.location.href = '/account/login';
+    });
+    document.getElementById('login-btn')?.addEventListener('click', () => {
+      window.location.href = '/account/login';
+    });
+
+    hidePageLoader();
+    if (token) {
+      showState('confirm', card);
+    } else {
+      showError('NO_TOKEN', card);
+    }
+
+    // 调整卡片高度
+    if (card) {
+      setTimeout(() => adjustCardHeight(card), 100);
+      enableCardAutoResize(card);
+    }
+  } catch (error) {
+    console.error('[UNLOCK] ERROR: Page initialization failed:', (error as Error).message);
+    hidePageLoader();
+    showError('NETWORK_ERROR', null);
+  }
+});
@@ -14,10 +14,13 @@ import {
   hideModal,
   userModal,
   confirmModal,
-  confirmCancel
+  confirmCancel,
+  setCurrentPermissions,
+  setCurrentRole,
+  hasPermission
 } from './common';
 import { loadStats } from './stats';
-import { loadUsers, setCurrentUserRole, initUsersPage } from './users';
+import { loadUsers, initUsersPage } from './users';
 import { loadLogs } from './logs';
 import { loadOAuthClients, initOAuthPage } from './oauth';
 import { initWhitelistPage } from './email-whitelist';
@@ -32,6 +35,8 @@ const navItems = document.querySelectorAll('.nav-item[data-page]') as NodeListOf
 const pageTitle = document.getElementById('page-title') as HTMLElement | null;
 const currentAvatarEl = document.getElementById('current-avatar') as HTMLElement | null;
 const logoutBtn = document.getElementById('logout-btn') as HTMLButtonElement | null;
+const navDashboard = document.getElementById('nav-dashboard') as HTMLAnchorElement | null;
+const navUsers = document.getElementById('nav-users') as HTMLAnchorElement | null;
 const navLogs = document.getElementById('nav-logs') as HTMLAnchorElement | null;
 const navOAuth = document.getElementById('nav-oauth') as HTMLAnchorElement | null;
 const navWhitelist = document.getElementById('nav-whitelist') as HTMLAnchorElement | null;
@@ -79,6 +84,12 @@ function navigateTo(page: string): void {
   }
 }
 
+/** 第一个可见导航对应的页面 */
+function defaultPage(): string {
+  const first = Array.from(navItems).find(item => !item.classList.contains('is-hidden'));
+  return first?.dataset.page || 'dashboard';
+}
+
 // ==================== 初始化 ====================
 
 async function init(): Promise<void> {
@@ -107,26 +118,20 @@ async function init(): Promise<void> {
     window.location.href = '/account/login';
     return;
   }
-  setCurrentUserRole(user.role);
-
-  // 显示日志导航（所有管理员可见）
-  if (navLogs) {
-    navLogs.classList.remove('is-hidden');
-  }
-
-  // 显示 OAuth 导航（超级管理员可见）
-  if (user.role >= 2 && navOAuth) {
-    navOAuth.classList.remove('is-hidden');
-  }
-
-  // 显示白名单导航（超级管理员可见）
-  if (user.role >= 2 && navWhitelist) {
-    navWhitelist.classList.remove('is-hidden');
-  }
-
-  // 显示数据管理导航（超级管理员可见）
-  if (user.role >= 2 && navData) {
-    navData.classList.remove('is-hidden');
+  setCurrentPermissions(user.permissions || []);
+  setCurrentRole(user.role);
+
+  // 按权限显示导航，无权访问的页面不展示入口
+  const navPermissions: [HTMLAnchorElement | null, boolean][] = [
+    [navDashboard, hasPermission('stats.read')],
+    [navUsers, hasPermission('users.read')],
+    [navLogs, hasPermission('logs.read')],
+    [navOAuth, hasPermission('oauth.clients.read')],
+    [navWhitelist, hasPermission('email_whitelist.read')],
+    [navData, hasPermission('data.export') || hasPermission('data.import')]
+  ];
+  for (const [nav, allowed] of navPermissions) {
+    nav?.classList.toggle('is-hidden', !allowed);
   }
 
   // 显示头像
@@ -145,8 +150,8 @@ async function init(): Promise<void> {
     pageLoader.classList.add('is-hidden');
   }
 
-  // 初始化路由
-  const hash = window.location.hash.slice(1) || 'dashboard';
+  // 初始化路由（无指定页面时进入第一个有权限的页面）
+  const hash = window.location.hash.slice(1) || defaultPage();
   navigateTo(hash);
 
   // 绑定导航事件
@@ -208,7 +213,7 @@ async function init(): Promise<void> {
 
   // 监听 hash 变化
   window.addEventListener('hashchange', () => {
-    const hash = window.location.hash.slice(1) || 'dashboard';
+    const hash = window.location.hash.slice(1) || defaultPage();
     navigateTo(hash);
   });
 }
@@ -23,6 +23,7 @@ export interface UserPublic {
   email: string;
   avatar_url: string;
   role: number;
+  permissions?: string[];
   microsoft_id?: string;
   microsoft_name?: string;
   microsoft_avatar_url?: string;
@@ -30,6 +31,8 @@ export interface UserPublic {
   ban_reason?: string;
   banned_at?: string;
   unban_at?: string;
+  failed_login_count?: number;
+  locked_until?: string;
   created_at?: string;
 }
 
@@ -38,6 +41,15 @@ export interface StatsResponse {
   todayNewUsers: number;
   adminCount: number;
   bannedCount: number;
+  emailQueue?: EmailQueueStats;
+}
+
+export interface EmailQueueStats {
+  pending: number;
+  oldestPendingAt?: string;
+  sent24h: number;
+  bounced24h: number;
+  failed24h: number;
 }
 
 export interface UserListResponse {
@@ -107,11 +119,55 @@ export const ACTION_NAMES: Record<string, string> = {
   'oauth_client_delete': '删除OAuth应用',
   'oauth_client_regenerate_secret': '重新生成密钥',
   'oauth_client_toggle': '启用/禁用应用',
+  'oauth_scope_create': '创建OAuth Scope',
+  'oauth_scope_update': '更新OAuth Scope',
+  'oauth_scope_delete': '删除OAuth Scope',
   'email_whitelist_create': '创建白名单',
   'email_whitelist_update': '更新白名单',
-  'email_whitelist_delete': '删除白名单'
+  'email_whitelist_delete': '删除白名单',
+  'user_group_create': '创建用户组',
+  'user_group_update': '更新用户组',
+  'user_group_delete': '删除用户组',
+  'user_group_add_member': '加入用户组',
+  'user_group_remove_member': '移出用户组',
+  'impersonation_start': '开始模拟登录',
+  'impersonation_stop': '结束模拟登录',
+  'webhook_create': '创建Webhook',
+  'webhook_update': '更新Webhook',
+  'webhook_delete': '删除Webhook',
+  'webhook_regenerate_secret': '重新生成Webhook密钥',
+  'webhook_redeliver': '重新投递Webhook',
+  'scim_user_create': 'SCIM创建用户',
+  'scim_user_update': 'SCIM更新用户',
+  'scim_user_deactivate': 'SCIM停用用户',
+  'scim_user_reactivate': 'SCIM启用用户'
 };
 
+// ==================== 权限 ====================
+
+/** 当前管理员的有效后台权限（来自 /api/auth/me） */
+let currentPermissions: string[] = [];
+
+export function setCurrentPermissions(permissions: string[]): void {
+  currentPermissions = permissions;
+}
+
+/** 是否拥有指定权限；仅用于隐藏无权操作的入口，实际鉴权由后端完成 */
+export function hasPermission(permission: string): boolean {
+  return currentPermissions.includes(permission);
+}
+
+/** 当前管理员的角色（来自 /api/auth/me），模拟登录等仅超级管理员可用的入口据此显示 */
+let currentRole = 0;
+
+export function setCurrentRole(role: number): void {
+  currentRole = role;
+}
+
+export function isSuperAdmin(): boolean {
+  return currentRole >= 2;
+}
+
 // ==================== DOM 元素 ====================
 
 export const toastContainer = document.getElementById('toast-container') as HTMLElement | null;
@@ -69,7 +69,7 @@ async function getWhitelist(page: number): Promise<EmailWhitelistListResponse |
   const params = new URLSearchParams({ page: String(page), pageSize: '20' });
   const result = await fetchApi<EmailWhitelistListResponse>(`/admin/api/email-whitelist?${params}`);
   if (!result.success) {
-    return result.errorCode === 'FORBIDDEN' || result.errorCode === 'ACCESS_DENIED' ? 'forbidden' : null;
+    return result.errorCode === 'FORBIDDEN' || result.errorCode === 'ACCESS_DENIED' || result.errorCode === 'PERMISSION_DENIED' ? 'forbidden' : null;
   }
   return result.data;
 }
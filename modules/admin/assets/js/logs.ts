@@ -33,7 +33,7 @@ async function getLogs(page: number): Promise<LogListResponse | null | 'forbidde
   const params = new URLSearchParams({ page: String(page), pageSize: '20' });
   const result = await fetchApi<LogListResponse>(`/admin/api/logs?${params}`);
   if (!result.success) {
-    return result.errorCode === 'FORBIDDEN' ? 'forbidden' : null;
+    return result.errorCode === 'FORBIDDEN' || result.errorCode === 'PERMISSION_DENIED' ? 'forbidden' : null;
   }
   return result.data!;
 }
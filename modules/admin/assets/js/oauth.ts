@@ -39,7 +39,10 @@ export interface OAuthClient {
   client_id: string;
   name: string;
   description: string;
-  redirect_uri: string;
+  redirect_uris: string[];
+  post_logout_redirect_uris: string[];
+  client_scopes: string[];
+  allowed_scopes: string[];
   is_enabled: boolean;
   created_at: string;
   updated_at: string;
@@ -92,7 +95,10 @@ const oauthFormTitle = document.getElementById('oauth-form-title') as HTMLElemen
 const oauthForm = document.getElementById('oauth-form') as HTMLFormElement | null;
 const oauthNameInput = document.getElementById('oauth-name') as HTMLInputElement | null;
 const oauthDescInput = document.getElementById('oauth-description') as HTMLTextAreaElement | null;
-const oauthRedirectInput = document.getElementById('oauth-redirect-uri') as HTMLInputElement | null;
+const oauthRedirectInput = document.getElementById('oauth-redirect-uris') as HTMLTextAreaElement | null;
+const oauthLogoutRedirectInput = document.getElementById('oauth-post-logout-redirect-uris') as HTMLTextAreaElement | null;
+const oauthClientScopesInput = document.getElementById('oauth-client-scopes') as HTMLInputElement | null;
+const oauthAllowedScopesInput = document.getElementById('oauth-allowed-scopes') as HTMLInputElement | null;
 const oauthFormCancel = document.getElementById('oauth-form-cancel') as HTMLButtonElement | null;
 const oauthFormSubmit = document.getElementById('oauth-form-submit') as HTMLButtonElement | null;
 const oauthFormClose = document.getElementById('oauth-form-close') as HTMLButtonElement | null;
@@ -111,7 +117,7 @@ async function getClients(page: number, search: string): Promise<OAuthClientList
   const result = await fetchApi<OAuthClientListResponse>(`/admin/api/oauth/clients?${params}`);
   if (!result.success) {
     console.warn('[ADMIN][OAUTH] getClients failed:', result.errorCode);
-    return result.errorCode === 'FORBIDDEN' || result.errorCode === 'ACCESS_DENIED' ? 'forbidden' : null;
+    return result.errorCode === 'FORBIDDEN' || result.errorCode === 'ACCESS_DENIED' || result.errorCode === 'PERMISSION_DENIED' ? 'forbidden' : null;
   }
   return result.data!;
 }
@@ -121,18 +127,18 @@ async function getClient(id: number): Promise<OAuthClient | null> {
   return result.success ? result.data! : null;
 }
 
-async function createClient(name: string, description: string, redirectUri: string): Promise<CreateClientResponse | null> {
+async function createClient(name: string, description: string, redirectUris: string[], postLogoutRedirectUris: string[], clientScopes: string[], allowedScopes: string[]): Promise<CreateClientResponse | null> {
   const result = await fetchApi<CreateClientResponse>('/admin/api/oauth/clients', {
     method: 'POST',
-    body: JSON.stringify({ name, description, redirect_uri: redirectUri })
+    body: JSON.stringify({ name, description, redirect_uris: redirectUris, post_logout_redirect_uris: postLogoutRedirectUris, client_scopes: clientScopes, allowed_scopes: allowedScopes })
   });
   return result.success ? result.data! : null;
 }
 
-async function updateClient(id: number, name: string, description: string, redirectUri: string): Promise<boolean> {
+async function updateClient(id: number, name: string, description: string, redirectUris: string[], postLogoutRedirectUris: string[], clientScopes: string[], allowedScopes: string[]): Promise<boolean> {
   const result = await fetchApi(`/admin/api/oauth/clients/${id}`, {
     method: 'PUT',
-    body: JSON.stringify({ name, description, redirect_uri: redirectUri })
+    body: JSON.stringify({ name, description, redirect_uris: redirectUris, post_logout_redirect_uris: postLogoutRedirectUris, client_scopes: clientScopes, allowed_scopes: allowedScopes })
   });
   return result.success;
 }
@@ -292,6 +298,11 @@ const clientDetailSkeleton = `
   </div>
 `;
 
+/** 渲染回调地址列表（每行一个） */
+function renderUriList(uris: string[] | null | undefined): string {
+  return uris && uris.length > 0 ? uris.map(escapeHtml).join('<br>') : '-';
+}
+
 function renderClientDetailContent(client: OAuthClient, cachedAt?: number, isRefreshing?: boolean): string {
   return `
     <div class="detail">
@@ -309,7 +320,19 @@ function renderClientDetailContent(client: OAuthClient, cachedAt?: number, isRef
       </div>
       <div class="detail-row">
         <span class="detail-label">回调地址</span>
-        <span class="detail-value mono">${escapeHtml(client.redirect_uri)}</span>
+        <span class="detail-value mono">${renderUriList(client.redirect_uris)}</span>
+      </div>
+      <div class="detail-row">
+        <span class="detail-label">登出后回调地址</span>
+        <span class="detail-value mono">${renderUriList(client.post_logout_redirect_uris)}</span>
+      </div>
+      <div class="detail-row">
+        <span class="detail-label">客户端 Scope</span>
+        <span class="detail-value mono">${client.client_scopes && client.client_scopes.length > 0 ? escapeHtml(client.client_scopes.join(' ')) : '-'}</span>
+      </div>
+      <div class="detail-row">
+        <span class="detail-label">用户授权 Scope</span>
+        <span class="detail-value mono">${client.allowed_scopes && client.allowed_scopes.length > 0 ? escapeHtml(client.allowed_scopes.join(' ')) : '-'}</span>
       </div>
       <div class="detail-row">
         <span class="detail-label">状态</span>
@@ -424,14 +447,56 @@ function showForm(client?: OAuthClient): void {
   if (client) {
     oauthNameInput!.value = client.name;
     oauthDescInput!.value = client.description || '';
-    oauthRedirectInput!.value = client.redirect_uri;
+    oauthRedirectInput!.value = (client.redirect_uris || []).join('\n');
+    oauthLogoutRedirectInput!.value = (client.post_logout_redirect_uris || []).join('\n');
+    oauthClientScopesInput!.value = (client.client_scopes || []).join(' ');
+    oauthAllowedScopesInput!.value = (client.allowed_scopes || []).join(' ');
   } else {
     oauthForm.reset();
+    oauthAllowedScopesInput!.value = DEFAULT_ALLOWED_SCOPES.join(' ');
   }
 
   showModal(oauthFormModal);
 }
 
+/** 新建应用默认的用户授权 scope（与服务端 DefaultOAuthAllowedScopes 一致） */
+const DEFAULT_ALLOWED_SCOPES = ['openid', 'profile', 'email'];
+
+/**
+ * 解析以空格分隔的 scope 输入（忽略重复项）
+ */
+function parseScopeInput(value: string): string[] {
+  return Array.from(new Set(value.split(/\s+/).filter((scope) => scope !== '')));
+}
+
+/** 每类回调地址上限（与服务端 MaxOAuthClientRedirectURIs 一致） */
+const MAX_REDIRECT_URIS = 10;
+
+/**
+ * 解析多行回调地址输入（每行一个，忽略空行与重复项）
+ */
+function parseUriLines(value: string): string[] {
+  const uris = value.split('\n').map((line) => line.trim()).filter((line) => line !== '');
+  return Array.from(new Set(uris));
+}
+
+/**
+ * 校验回调地址（与服务端 ValidateOAuthRedirectURI 规则一致：
+ * 仅 https（需有主机名）或 http 且仅限本地回环，拒绝 javascript:/data: 等危险 scheme 及锚点）
+ */
+function isValidRedirectUri(uri: string): boolean {
+  try {
+    const parsed = new URL(uri);
+    const host = parsed.hostname.toLowerCase();
+    const schemeOk =
+      parsed.protocol === 'https:' && host !== '' ||
+      parsed.protocol === 'http:' && (host === 'localhost' || host === '127.0.0.1' || host === '[::1]');
+    return schemeOk && !parsed.hash;
+  } catch {
+    return false;
+  }
+}
+
 /**
  * 处理表单提交
  */
@@ -439,41 +504,41 @@ async function handleFormSubmit(): Promise<void> {
   const localOauthNameInput = oauthNameInput;
   const localOauthDescInput = oauthDescInput;
   const localOauthRedirectInput = oauthRedirectInput;
+  const localOauthLogoutRedirectInput = oauthLogoutRedirectInput;
+  const localOauthClientScopesInput = oauthClientScopesInput;
+  const localOauthAllowedScopesInput = oauthAllowedScopesInput;
   const localOauthFormSubmit = oauthFormSubmit;
   
-  if (!localOauthNameInput || !localOauthDescInput || !localOauthRedirectInput || !localOauthFormSubmit) {
+  if (!localOauthNameInput || !localOauthDescInput || !localOauthRedirectInput || !localOauthLogoutRedirectInput || !localOauthClientScopesInput || !localOauthAllowedScopesInput || !localOauthFormSubmit) {
     console.error('[ADMIN][OAUTH] Form elements not found for handleFormSubmit');
     return;
   }
   
   const name = localOauthNameInput.value.trim();
   const description = localOauthDescInput.value.trim();
-  const redirectUri = localOauthRedirectInput.value.trim();
+  const redirectUris = parseUriLines(localOauthRedirectInput.value);
+  const postLogoutRedirectUris = parseUriLines(localOauthLogoutRedirectInput.value);
+  const clientScopes = parseScopeInput(localOauthClientScopesInput.value);
+  const allowedScopes = parseScopeInput(localOauthAllowedScopesInput.value);
 
   if (!name) {
     showToast('请输入应用名称', 'error');
     return;
   }
 
-  if (!redirectUri) {
-    showToast('请输入回调地址', 'error');
+  if (redirectUris.length === 0) {
+    showToast('请输入至少一个回调地址', 'error');
     return;
   }
 
-  // 验证 URL 格式（与服务端 validateRedirectURIScheme 规则一致：
-  // 仅 https（需有主机名）或 http 且仅限本地回环，拒绝 javascript:/data: 等危险 scheme 及锚点）
-  try {
-    const parsed = new URL(redirectUri);
-    const host = parsed.hostname.toLowerCase();
-    const schemeOk =
-      parsed.protocol === 'https:' && host !== '' ||
-      parsed.protocol === 'http:' && (host === 'localhost' || host === '127.0.0.1' || host === '::1');
-    if (!schemeOk || parsed.hash) {
-      showToast('回调地址必须为 https（或 http 且仅限本地回环），且不能包含 # 锚点', 'error');
-      return;
-    }
-  } catch {
-    showToast('回调地址格式不正确', 'error');
+  if (redirectUris.length > MAX_REDIRECT_URIS || postLogoutRedirectUris.length > MAX_REDIRECT_URIS) {
+    showToast(`每类回调地址最多 ${MAX_REDIRECT_URIS} 个`, 'error');
+    return;
+  }
+
+  const invalidUri = [...redirectUris, ...postLogoutRedirectUris].find((uri) => !isValidRedirectUri(uri));
+  if (invalidUri !== undefined) {
+    showToast(`回调地址 ${invalidUri} 不合法：必须为 https（或 http 且仅限本地回环），且不能包含 # 锚点`, 'error');
     return;
   }
 
@@ -483,7 +548,7 @@ async function handleFormSubmit(): Promise<void> {
   try {
     if (editingClientId) {
       // 编辑模式
-      const success = await updateClient(editingClientId, name, description, redirectUri);
+      const success = await updateClient(editingClientId, name, description, redirectUris, postLogoutRedirectUris, clientScopes, allowedScopes);
       if (success) {
         showToast('应用已更新', 'success');
         hideModal(oauthFormModal);
@@ -493,7 +558,7 @@ async function handleFormSubmit(): Promise<void> {
       }
     } else {
       // 创建模式
-      const result = await createClient(name, description, redirectUri);
+      const result = await createClient(name, description, redirectUris, postLogoutRedirectUris, clientScopes, allowedScopes);
       if (result) {
         hideModal(oauthFormModal);
         showSecretModal(result.client_secret);
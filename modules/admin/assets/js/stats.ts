@@ -5,9 +5,10 @@
  * 功能：
  * - 加载统计数据
  * - 渲染统计卡片
+ * - 邮件队列深度与最近 24 小时退信/失败数
  */
 
-import { fetchApi, StatsResponse } from './common';
+import { fetchApi, StatsResponse, hasPermission } from './common';
 
 // ==================== DOM 元素 ====================
 
@@ -15,6 +16,10 @@ const statTotalUsers = document.getElementById('stat-total-users');
 const statTodayUsers = document.getElementById('stat-today-users');
 const statAdminCount = document.getElementById('stat-admin-count');
 const statBannedCount = document.getElementById('stat-banned-count');
+const statEmailQueueCard = document.getElementById('stat-email-queue-card');
+const statEmailFailedCard = document.getElementById('stat-email-failed-card');
+const statEmailPending = document.getElementById('stat-email-pending');
+const statEmailFailed = document.getElementById('stat-email-failed');
 
 // ==================== API ====================
 
@@ -26,6 +31,8 @@ async function getStats(): Promise<StatsResponse | null> {
 // ==================== 公开函数 ====================
 
 export async function loadStats(): Promise<void> {
+  if (!hasPermission('stats.read')) return;
+
   const stats = await getStats();
   if (!stats) {
     console.warn('[ADMIN][STATS] Stats data is null');
@@ -44,4 +51,26 @@ export async function loadStats(): Promise<void> {
       el.textContent = String(value);
     }
   }
+
+  renderEmailQueue(stats);
+}
+
+// ==================== 邮件队列 ====================
+
+function renderEmailQueue(stats: StatsResponse): void {
+  const queue = stats.emailQueue;
+  statEmailQueueCard?.classList.toggle('is-hidden', !queue);
+  statEmailFailedCard?.classList.toggle('is-hidden', !queue);
+  if (!queue) return;
+
+  if (statEmailPending) {
+    statEmailPending.textContent = String(queue.pending);
+    statEmailPending.title = queue.oldestPendingAt
+      ? `最早入队：${new Date(queue.oldestPendingAt).toLocaleString()}`
+      : '';
+  }
+  if (statEmailFailed) {
+    statEmailFailed.textContent = `${queue.bounced24h} / ${queue.failed24h}`;
+    statEmailFailed.title = `24h 已发送 ${queue.sent24h}`;
+  }
 }
@@ -5,7 +5,7 @@
  * 功能：
  * - 用户列表（分页、搜索）
  * - 用户详情弹窗
- * - 用户操作（设置角色、删除）
+ * - 用户操作（设置角色、删除、模拟登录）
  * - 用户数据缓存
  */
 
@@ -35,7 +35,9 @@ import {
   animateTableRow,
   initSearch,
   renderRoleBadge,
-  showDetailWithCache
+  showDetailWithCache,
+  hasPermission,
+  isSuperAdmin
 } from './common';
 import { loadStats } from './stats';
 
@@ -44,7 +46,8 @@ function translateBanReason(reason: string): string {
     'violation': '违反服务条款',
     'abuse': '滥用服务',
     'malicious': '恶意行为',
-    'spam': '垃圾信息'
+    'spam': '垃圾信息',
+    'deprovisioned': '已停用（SCIM）'
   };
   return reasonMap[reason] || reason;
 }
@@ -53,7 +56,6 @@ function translateBanReason(reason: string): string {
 
 let currentPage = 1;
 let currentSearch = '';
-let currentUserRole = 0;
 const usersCache = new DataCache<UserPublic>();
 
 // ==================== DOM 元素 ====================
@@ -71,7 +73,7 @@ async function getUsers(page: number, search: string): Promise<UserListResponse
 
   const result = await fetchApi<UserListResponse>(`/admin/api/users?${params}`);
   if (!result.success) {
-    return result.errorCode === 'FORBIDDEN' || result.errorCode === 'ACCESS_DENIED' ? 'forbidden' : null;
+    return result.errorCode === 'FORBIDDEN' || result.errorCode === 'ACCESS_DENIED' || result.errorCode === 'PERMISSION_DENIED' ? 'forbidden' : null;
   }
   return result.data!;
 }
@@ -111,6 +113,14 @@ async function unbanUser(uid: string): Promise<boolean> {
   return result.success;
 }
 
+async function impersonateUser(uid: string): Promise<boolean> {
+  const result = await fetchApi(`/admin/api/users/${uid}/impersonate`, {
+    method: 'POST',
+    body: JSON.stringify({})
+  });
+  return result.success;
+}
+
 // ==================== 用户列表 ====================
 
 /**
@@ -273,6 +283,18 @@ function renderUserDetailContent(user: UserPublic, cachedAt?: number, isRefreshi
     </div>
   ` : '';
 
+  const isLocked = !!user.locked_until && new Date(user.locked_until) > new Date();
+  const lockStatusHtml = isLocked ? `
+    <div class="detail-row">
+      <span class="detail-label">登录锁定</span>
+      <span class="detail-value">锁定至 ${formatDate(user.locked_until)}</span>
+    </div>
+    <div class="detail-row">
+      <span class="detail-label">连续登录失败</span>
+      <span class="detail-value">${user.failed_login_count ?? 0} 次</span>
+    </div>
+  ` : '';
+
   return `
     <div class="detail">
       <div class="detail-row">
@@ -302,6 +324,7 @@ function renderUserDetailContent(user: UserPublic, cachedAt?: number, isRefreshi
         <span class="detail-value">${formatDate(user.created_at)}</span>
       </div>
       ${banStatusHtml}
+      ${lockStatusHtml}
     </div>
     <div class="detail-meta" id="user-detail-meta">
       ${cachedAt ? `数据更新于 ${formatRelativeTime(cachedAt)}` : ''}${isRefreshing ? ' · 刷新中...' : ''}
@@ -313,7 +336,7 @@ function renderUserDetailFooter(user: UserPublic): string {
   const isBanned = checkUserBanned(user);
   let footerHtml = '<button class="btn btn-secondary" data-close-modal>关闭</button>';
 
-  if (currentUserRole >= 1 && user.role < 1) {
+  if (hasPermission('users.ban') && user.role < 1) {
     if (isBanned) {
       footerHtml += `<button class="btn btn-success" id="unban-user" data-user-uid="${user.uid}">解除封禁</button>`;
     } else {
@@ -321,12 +344,19 @@ function renderUserDetailFooter(user: UserPublic): string {
     }
   }
 
-  if (currentUserRole >= 2 && user.role < 2) {
+  if (hasPermission('users.role') && user.role < 2) {
     if (user.role === 0 && !isBanned) {
       footerHtml += `<button class="btn btn-warning" id="promote-user" data-user-uid="${user.uid}">设为管理员</button>`;
     } else if (user.role === 1) {
       footerHtml += `<button class="btn btn-secondary" id="demote-user" data-user-uid="${user.uid}">撤销管理员</button>`;
     }
+  }
+
+  if (isSuperAdmin() && user.role < 2) {
+    footerHtml += `<button class="btn btn-secondary" id="impersonate-user" data-user-uid="${user.uid}">以该用户身份查看</button>`;
+  }
+
+  if (hasPermission('users.delete') && user.role < 2) {
     footerHtml += `<button class="btn btn-danger" id="delete-user" data-user-uid="${user.uid}">删除用户</button>`;
   }
 
@@ -383,6 +413,17 @@ function bindUserDetailEvents(user: UserPublic, modal: HTMLElement): void {
     });
   });
 
+  document.getElementById('impersonate-user')?.addEventListener('click', async () => {
+    showConfirm('确认模拟登录', `将以 ${user.username} 的身份进入账户页面（30 分钟内有效），期间无法修改密码、删除账户或授权第三方应用。结束后可返回后台。`, async () => {
+      const success = await impersonateUser(user.uid);
+      if (success) {
+        window.location.href = '/account/dashboard';
+      } else {
+        showToast('操作失败', 'error');
+      }
+    });
+  });
+
   document.getElementById('delete-user')?.addEventListener('click', async () => {
     showConfirm('确认删除', `确定要删除用户 ${user.username} 吗？此操作不可恢复！`, async () => {
       const success = await deleteUser(user.uid);
@@ -503,10 +544,6 @@ function showUserDetail(userUid: string): void {
 
 // ==================== 初始化 ====================
 
-export function setCurrentUserRole(role: number): void {
-  currentUserRole = role;
-}
-
 export function initUsersPage(): void {
   if (searchBtn && userSearch) {
     initSearch(userSearch, searchBtn, (query) => {
@@ -53,6 +53,13 @@ export interface User {
   ban_reason?: string | null;
   banned_at?: string | null;
   unban_at?: string | null;
+  impersonation?: ImpersonationInfo;
+}
+
+/** 模拟登录信息：超级管理员以该用户身份查看时由 /api/auth/me 返回 */
+export interface ImpersonationInfo {
+  impersonator_uid: string;
+  impersonator_username: string;
 }
 
 // ==================== 表单数据 ====================
@@ -81,6 +88,17 @@ export type AuthResponse =
   | { success: true; data: User; message?: string }
   | { success: false; errorCode: string; message?: string };
 
+/** 需要两步验证时登录接口返回的挑战 */
+export interface TwoFactorChallenge {
+  twoFactorRequired: true;
+  challenge: string;
+}
+
+/** 密码登录响应：成功、需要两步验证或失败 */
+export type LoginResponse =
+  | AuthResponse
+  | { success: true; twoFactor: TwoFactorChallenge };
+
 /** 发送验证码响应 */
 export interface SendCodeResponse {
   success: boolean;